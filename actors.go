@@ -0,0 +1,210 @@
+package storage
+
+import (
+	"fmt"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// The names of the standard collections of actors, which are used as the last path segment of their
+// IRIs when the actors don't have them set.
+const (
+	InboxCollection     = "inbox"
+	OutboxCollection    = "outbox"
+	FollowersCollection = "followers"
+	FollowingCollection = "following"
+	LikedCollection     = "liked"
+)
+
+// ActorCollections sets the missing inbox, outbox, followers, following and liked properties of the "it"
+// actor to IRIs under its ID, and returns the corresponding empty collections.
+// The actor is modified in place, so it needs to be passed as a pointer for the change to be visible.
+func ActorCollections(it pub.Item) ([]pub.CollectionInterface, error) {
+	if pub.IsNil(it) || !pub.ActorTypes.Contains(it.GetType()) {
+		return nil, fmt.Errorf("%w: %T is not an actor", ErrNotValid, it)
+	}
+	if len(it.GetLink()) == 0 {
+		return nil, fmt.Errorf("%w: unable to create the collections of an actor without an ID", ErrNotValid)
+	}
+	cols := make([]pub.CollectionInterface, 0, 5)
+	err := pub.OnActor(it, func(a *pub.Actor) error {
+		for _, prop := range []struct {
+			name string
+			col  *pub.Item
+			typ  pub.ActivityVocabularyType
+		}{
+			{name: InboxCollection, col: &a.Inbox, typ: pub.OrderedCollectionType},
+			{name: OutboxCollection, col: &a.Outbox, typ: pub.OrderedCollectionType},
+			{name: FollowersCollection, col: &a.Followers, typ: pub.CollectionType},
+			{name: FollowingCollection, col: &a.Following, typ: pub.CollectionType},
+			{name: LikedCollection, col: &a.Liked, typ: pub.OrderedCollectionType},
+		} {
+			if pub.IsNil(*prop.col) || len((*prop.col).GetLink()) == 0 {
+				*prop.col = a.ID.AddPath(prop.name)
+			}
+			iri := (*prop.col).GetLink()
+			if prop.typ == pub.CollectionType {
+				cols = append(cols, pub.CollectionNew(iri))
+			} else {
+				cols = append(cols, pub.OrderedCollectionNew(iri))
+			}
+		}
+		return nil
+	})
+	return cols, err
+}
+
+// CreateActorCollections creates in "cs" the standard collections of the "it" actor that don't
+// exist in "s" yet, see ActorCollections.
+func CreateActorCollections(s ReadStore, cs CollectionStore, it pub.Item) error {
+	cols, err := ActorCollections(it)
+	if err != nil {
+		return err
+	}
+	for _, col := range cols {
+		exists, err := Exists(s, col.GetLink())
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		if _, err := cs.Create(col); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ActorStore is a Store with helpers for the standard collections of actors, which it creates
+// when an actor is saved for the first time.
+type ActorStore struct {
+	Store
+}
+
+// Actors returns an ActorStore wrapping "s", which needs to be a CollectionStore.
+func Actors(s Store) *ActorStore {
+	return &ActorStore{Store: s}
+}
+
+// Save saves "it", and, if it's a new actor, sets its missing collection properties and creates the collections.
+func (a *ActorStore) Save(it pub.Item) (pub.Item, error) {
+	if pub.IsNil(it) || !pub.ActorTypes.Contains(it.GetType()) || len(it.GetLink()) == 0 {
+		return a.Store.Save(it)
+	}
+	exists, err := Exists(a.Store, it.GetLink())
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return a.Store.Save(it)
+	}
+	if _, err := ActorCollections(it); err != nil {
+		return nil, err
+	}
+	saved, err := a.Store.Save(it)
+	if err != nil {
+		return saved, err
+	}
+	return saved, CreateActorCollections(a.Store, asCollectionStore(a.Store), it)
+}
+
+// Create creates the "col" collection in the underlying store.
+func (a *ActorStore) Create(col pub.CollectionInterface) (pub.CollectionInterface, error) {
+	return asCollectionStore(a.Store).Create(col)
+}
+
+// AddTo adds "it" to the "col" collection of the underlying store.
+func (a *ActorStore) AddTo(col pub.IRI, it pub.Item) error {
+	return asCollectionStore(a.Store).AddTo(col, it)
+}
+
+// RemoveFrom removes "it" from the "col" collection of the underlying store.
+func (a *ActorStore) RemoveFrom(col pub.IRI, it pub.Item) error {
+	return asCollectionStore(a.Store).RemoveFrom(col, it)
+}
+
+// collection returns the IRI of the "name" collection of "actor", which is loaded if it's only an IRI.
+func (a *ActorStore) collection(actor pub.Item, name string) (pub.IRI, error) {
+	if pub.IsNil(actor) {
+		return "", fmt.Errorf("%w: nil actor", ErrNotValid)
+	}
+	if pub.IsIRI(actor) {
+		loaded, err := a.Store.Load(actor.GetLink())
+		if err != nil {
+			return "", err
+		}
+		actor = loaded
+	}
+	if !pub.ActorTypes.Contains(actor.GetType()) {
+		return "", fmt.Errorf("%w: %s is not an actor", ErrNotValid, actor.GetLink())
+	}
+	var iri pub.IRI
+	pub.OnActor(actor, func(act *pub.Actor) error {
+		var col pub.Item
+		switch name {
+		case InboxCollection:
+			col = act.Inbox
+		case OutboxCollection:
+			col = act.Outbox
+		case FollowersCollection:
+			col = act.Followers
+		case FollowingCollection:
+			col = act.Following
+		case LikedCollection:
+			col = act.Liked
+		}
+		if !pub.IsNil(col) {
+			iri = col.GetLink()
+		}
+		return nil
+	})
+	if len(iri) == 0 {
+		iri = actor.GetLink().AddPath(name)
+	}
+	return iri, nil
+}
+
+func (a *ActorStore) addTo(actor pub.Item, name string, it pub.Item) error {
+	col, err := a.collection(actor, name)
+	if err != nil {
+		return err
+	}
+	return a.AddTo(col, it)
+}
+
+func (a *ActorStore) members(actor pub.Item, name string, f Filterable) (pub.ItemCollection, string, error) {
+	col, err := a.collection(actor, name)
+	if err != nil {
+		return nil, "", err
+	}
+	if f == nil {
+		f = col
+	}
+	return LoadCollection(a.Store, col, f)
+}
+
+// AddToInbox adds "it" to the inbox of "actor", which can be an IRI.
+func (a *ActorStore) AddToInbox(actor pub.Item, it pub.Item) error {
+	return a.addTo(actor, InboxCollection, it)
+}
+
+// AddToOutbox adds "it" to the outbox of "actor", which can be an IRI.
+func (a *ActorStore) AddToOutbox(actor pub.Item, it pub.Item) error {
+	return a.addTo(actor, OutboxCollection, it)
+}
+
+// Followers returns the followers of "actor" matching "f", see LoadCollection.
+func (a *ActorStore) Followers(actor pub.Item, f Filterable) (pub.ItemCollection, string, error) {
+	return a.members(actor, FollowersCollection, f)
+}
+
+// Following returns the actors followed by "actor" matching "f", see LoadCollection.
+func (a *ActorStore) Following(actor pub.Item, f Filterable) (pub.ItemCollection, string, error) {
+	return a.members(actor, FollowingCollection, f)
+}
+
+// Liked returns the objects liked by "actor" matching "f", see LoadCollection.
+func (a *ActorStore) Liked(actor pub.Item, f Filterable) (pub.ItemCollection, string, error) {
+	return a.members(actor, LikedCollection, f)
+}
@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// loadableCollectionMapStore is a collectionMapStore that loads its collections with their members.
+type loadableCollectionMapStore struct {
+	*collectionMapStore
+}
+
+func (l loadableCollectionMapStore) Load(iri pub.IRI) (pub.Item, error) {
+	members, ok := l.members[iri]
+	if !ok {
+		return l.mapStore.Load(iri)
+	}
+	col := pub.OrderedCollectionNew(iri)
+	for _, member := range members {
+		col.OrderedItems = append(col.OrderedItems, member)
+	}
+	return col, nil
+}
+
+func TestActorCollections(t *testing.T) {
+	actor := &pub.Actor{ID: "https://example.com/actors/jdoe", Type: pub.PersonType}
+	actor.Inbox = pub.IRI("https://example.com/inboxes/jdoe")
+
+	cols, err := ActorCollections(actor)
+	if err != nil {
+		t.Fatalf("ActorCollections returned error: %s", err)
+	}
+	want := pub.IRIs{
+		"https://example.com/inboxes/jdoe",
+		"https://example.com/actors/jdoe/outbox",
+		"https://example.com/actors/jdoe/followers",
+		"https://example.com/actors/jdoe/following",
+		"https://example.com/actors/jdoe/liked",
+	}
+	if len(cols) != len(want) {
+		t.Fatalf("ActorCollections returned %d collections, expected %d", len(cols), len(want))
+	}
+	for i, col := range cols {
+		if col.GetLink() != want[i] {
+			t.Errorf("ActorCollections returned %s, expected %s", col.GetLink(), want[i])
+		}
+	}
+	if actor.Outbox.GetLink() != want[1] || actor.Followers.GetLink() != want[2] || actor.Liked.GetLink() != want[4] {
+		t.Errorf("ActorCollections should set the missing collection properties of the actor")
+	}
+	if cols[2].GetType() != pub.CollectionType || cols[1].GetType() != pub.OrderedCollectionType {
+		t.Errorf("ActorCollections returned %s and %s, expected an unordered followers collection", cols[2].GetType(), cols[1].GetType())
+	}
+
+	if _, err := ActorCollections(note("https://example.com/objects/1", "hello")); !errors.Is(err, ErrNotValid) {
+		t.Errorf("ActorCollections of an object returned %v, expected %s", err, ErrNotValid)
+	}
+	if _, err := ActorCollections(&pub.Actor{Type: pub.PersonType}); !errors.Is(err, ErrNotValid) {
+		t.Errorf("ActorCollections of an actor without ID returned %v, expected %s", err, ErrNotValid)
+	}
+}
+
+func TestActorStore(t *testing.T) {
+	store := loadableCollectionMapStore{newCollectionMapStore()}
+	a := Actors(store)
+	jdoe := &pub.Actor{ID: "https://example.com/actors/jdoe", Type: pub.PersonType}
+	if _, err := a.Save(jdoe); err != nil {
+		t.Fatalf("Save returned error: %s", err)
+	}
+	if len(store.members) != 5 {
+		t.Fatalf("Save of a new actor created %d collections, expected 5", len(store.members))
+	}
+
+	alice := pub.IRI("https://example.com/actors/alice")
+	store.AddTo(jdoe.Followers.GetLink(), alice)
+	// saving the actor again must not reset its collections
+	if _, err := a.Save(jdoe); err != nil {
+		t.Fatalf("Save returned error: %s", err)
+	}
+	followers, _, err := a.Followers(jdoe.ID, nil)
+	if err != nil {
+		t.Fatalf("Followers returned error: %s", err)
+	}
+	if len(followers) != 1 || followers[0].GetLink() != alice {
+		t.Errorf("Followers returned %v, expected %s", followers, alice)
+	}
+
+	ob := note("https://example.com/objects/1", "hello")
+	if err := a.AddToOutbox(jdoe, ob); err != nil {
+		t.Errorf("AddToOutbox returned error: %s", err)
+	}
+	if err := a.AddToInbox(jdoe.ID, ob); err != nil {
+		t.Errorf("AddToInbox returned error: %s", err)
+	}
+	if members := store.members[jdoe.Outbox.GetLink()]; len(members) != 1 || members[0] != ob.ID {
+		t.Errorf("the outbox has members %v, expected %s", members, ob.ID)
+	}
+	if members := store.members[jdoe.Inbox.GetLink()]; len(members) != 1 || members[0] != ob.ID {
+		t.Errorf("the inbox has members %v, expected %s", members, ob.ID)
+	}
+	if liked, _, err := a.Liked(jdoe, nil); err != nil || len(liked) != 0 {
+		t.Errorf("Liked returned %v, %v, expected an empty collection", liked, err)
+	}
+
+	a.Save(ob)
+	if err := a.AddToInbox(ob.ID, ob); !errors.Is(err, ErrNotValid) {
+		t.Errorf("AddToInbox of an object returned %v, expected %s", err, ErrNotValid)
+	}
+	if _, _, err := a.Following(alice, nil); err == nil {
+		t.Errorf("Following of a missing actor should fail")
+	}
+}
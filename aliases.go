@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// AliasNamespace is the MetadataStore namespace under which AliasStore stores the new IRI of each moved item.
+const AliasNamespace = "alias"
+
+// MaxAliasHops is the maximum number of aliases an AliasStore follows to resolve an IRI, for the items
+// which have been moved multiple times.
+const MaxAliasHops = 8
+
+// MovedError is returned for the items which have been moved to another IRI. It wraps ErrMovedPermanently.
+type MovedError struct {
+	// IRI is the IRI of the moved item.
+	IRI pub.IRI
+	// Target is the IRI to which the item has been moved.
+	Target pub.IRI
+}
+
+func (e *MovedError) Error() string {
+	return fmt.Sprintf("%s has been moved to %s", e.IRI, e.Target)
+}
+
+// Is reports ErrMovedPermanently as the kind of the error.
+func (e *MovedError) Is(target error) bool {
+	return target == ErrMovedPermanently
+}
+
+// AliasStore is a Store which keeps the new IRIs of the moved items, like migrated actors, or the objects
+// of a domain which has changed, as the AliasNamespace metadata of their old IRIs in a MetadataStore.
+// Loading a moved item either returns a MovedError, so the callers can redirect to its new IRI,
+// or loads it from its new IRI.
+type AliasStore struct {
+	Store
+	m      MetadataStore
+	follow bool
+}
+
+// Aliases returns an AliasStore keeping the aliases of the items of "s" in "m", which is usually "s" itself.
+// When "follow" is true, loading a moved item loads it from its new IRI, otherwise it returns a MovedError.
+func Aliases(s Store, m MetadataStore, follow bool) *AliasStore {
+	return &AliasStore{Store: s, m: m, follow: follow}
+}
+
+// alias returns the IRI to which "iri" has been moved, or an empty IRI if it hasn't.
+func (a *AliasStore) alias(iri pub.IRI) (pub.IRI, error) {
+	data, err := a.m.LoadMetadata(iri, AliasNamespace)
+	if errors.Is(err, ErrNotFound) {
+		return "", nil
+	}
+	return pub.IRI(data), err
+}
+
+// Resolve returns the IRI to which "iri" has been moved, following the aliases of the items moved multiple
+// times, or "iri" itself if it hasn't been moved.
+func (a *AliasStore) Resolve(iri pub.IRI) (pub.IRI, error) {
+	target := iri
+	for i := 0; i < MaxAliasHops; i++ {
+		next, err := a.alias(target)
+		if err != nil || len(next) == 0 {
+			return target, err
+		}
+		target = next
+	}
+	return "", fmt.Errorf("%w: too many aliases resolving %s", ErrNotValid, iri)
+}
+
+// Alias records that the "old" item has been moved to the "new" IRI. Aliases which would
+// resolve back to "old" return an error wrapping ErrConflict.
+func (a *AliasStore) Alias(old, new pub.IRI) error {
+	if len(old) == 0 || len(new) == 0 || old.Equals(new, false) {
+		return fmt.Errorf("%w alias from %q to %q", ErrNotValid, old, new)
+	}
+	target, err := a.Resolve(new)
+	if err != nil {
+		return err
+	}
+	if target.Equals(old, false) {
+		return fmt.Errorf("%w: %s is an alias of %s", ErrConflict, new, old)
+	}
+	return a.m.SaveMetadata(old, AliasNamespace, []byte(new))
+}
+
+// Move renames the "old" item to the "new" IRI, see Rename, and records the alias.
+func (a *AliasStore) Move(old, new pub.IRI) error {
+	if err := Rename(a.Store, old, new); err != nil {
+		return err
+	}
+	return a.Alias(old, new)
+}
+
+// Load loads the "iri" item from the underlying store. If it's missing, and it has been moved,
+// it loads it from its new IRI, or returns a MovedError.
+func (a *AliasStore) Load(iri pub.IRI) (pub.Item, error) {
+	it, err := a.Store.Load(iri)
+	if !errors.Is(err, ErrNotFound) {
+		return it, err
+	}
+	target, aerr := a.Resolve(iri)
+	if aerr != nil {
+		return nil, aerr
+	}
+	if target == iri {
+		return it, err
+	}
+	if !a.follow {
+		return nil, &MovedError{IRI: iri, Target: target}
+	}
+	return a.Store.Load(target)
+}
+
+// Create creates the "col" collection in the underlying store, which needs to be a CollectionStore.
+func (a *AliasStore) Create(col pub.CollectionInterface) (pub.CollectionInterface, error) {
+	return asCollectionStore(a.Store).Create(col)
+}
+
+// AddTo adds "it" to the "col" collection in the underlying store, which needs to be a CollectionStore.
+func (a *AliasStore) AddTo(col pub.IRI, it pub.Item) error {
+	return asCollectionStore(a.Store).AddTo(col, it)
+}
+
+// RemoveFrom removes "it" from the "col" collection in the underlying store, which needs to be a CollectionStore.
+func (a *AliasStore) RemoveFrom(col pub.IRI, it pub.Item) error {
+	return asCollectionStore(a.Store).RemoveFrom(col, it)
+}
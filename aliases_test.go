@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+func TestAliases(t *testing.T) {
+	ms := newMapStore()
+	meta := metadataMap{}
+	old, moved, current := pub.IRI("https://old.example/objects/1"), pub.IRI("https://example.org/objects/1"), pub.IRI("https://example.com/objects/1")
+	ms.Save(note(current, "hello"))
+
+	redirects := Aliases(ms, meta, false)
+	for _, alias := range [][2]pub.IRI{{old, moved}, {moved, current}} {
+		if err := redirects.Alias(alias[0], alias[1]); err != nil {
+			t.Fatalf("Alias returned error: %s", err)
+		}
+	}
+	if err := redirects.Alias(current, old); !errors.Is(err, ErrConflict) {
+		t.Errorf("Alias creating a cycle returned %v, expected %s", err, ErrConflict)
+	}
+	if err := redirects.Alias(old, old); !errors.Is(err, ErrNotValid) {
+		t.Errorf("Alias of an IRI to itself returned %v, expected %s", err, ErrNotValid)
+	}
+	if target, err := redirects.Resolve(old); err != nil || target != current {
+		t.Errorf("Resolve returned %s, %v, expected %s", target, err, current)
+	}
+
+	_, err := redirects.Load(old)
+	moveErr := &MovedError{}
+	if !errors.Is(err, ErrMovedPermanently) || !errors.As(err, &moveErr) || moveErr.Target != current {
+		t.Errorf("Load of a moved item returned %v, expected a MovedError to %s", err, current)
+	}
+	if _, err := redirects.Load("https://example.com/objects/2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Load of a missing item returned %v, expected %s", err, ErrNotFound)
+	}
+
+	it, err := Aliases(ms, meta, true).Load(old)
+	if err != nil || it.GetLink() != current {
+		t.Errorf("Load following the aliases returned %v, %v, expected %s", it, err, current)
+	}
+}
+
+func TestAliasStore_Move(t *testing.T) {
+	s := newBackendMapStore()
+	old, renamed := pub.IRI("https://old.example/objects/1"), pub.IRI("https://example.com/objects/1")
+	s.Save(note(old, "hello"))
+
+	if err := Aliases(s, s, false).Move(old, renamed); err != nil {
+		t.Fatalf("Move returned error: %s", err)
+	}
+	if it, err := s.Load(renamed); err != nil || it.GetLink() != renamed {
+		t.Errorf("Load of the new IRI returned %v, %v", it, err)
+	}
+	if _, err := Aliases(s, s, false).Load(old); !errors.Is(err, ErrMovedPermanently) {
+		t.Errorf("Load of the old IRI returned %v, expected %s", err, ErrMovedPermanently)
+	}
+	if it, err := Aliases(s, s, true).Load(old); err != nil || it.GetLink() != renamed {
+		t.Errorf("Load following the alias returned %v, %v, expected %s", it, err, renamed)
+	}
+}
@@ -0,0 +1,405 @@
+// Package archive exports the data of an actor as an archive, and imports such archives,
+// including the account archives exported by Mastodon, into a storage backend.
+//
+// An archive is a gzipped tarball, or a zip file, containing:
+//
+//   - actor.json: the actor.
+//   - outbox.json: the OrderedCollection of the activities of the actor, with their objects embedded.
+//   - media.json: the manifest of the media attached to the objects, see Media.
+//
+// Mastodon archives don't have a media manifest, it gets built from the attachments of the objects.
+// Any other files, like the media themselves, are not loaded.
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage"
+)
+
+// The names of the files of an archive.
+const (
+	ActorFile  = "actor.json"
+	OutboxFile = "outbox.json"
+	MediaFile  = "media.json"
+)
+
+// Media is an entry of the media manifest of an archive.
+type Media struct {
+	// URL is the location of the media, which, in Mastodon archives, is a path relative to the archive.
+	URL pub.IRI `json:"url"`
+	// MediaType is the MIME type of the media, if known.
+	MediaType pub.MimeType `json:"mediaType,omitempty"`
+	// Object is the IRI of the object to which the media is attached.
+	Object pub.IRI `json:"object"`
+	// Path is the name of the file holding the media in the archive, for the media included in it.
+	Path string `json:"path,omitempty"`
+}
+
+// Archive holds the data of an actor.
+type Archive struct {
+	Actor pub.Item
+	// Outbox holds the activities of the actor, with their objects embedded.
+	Outbox pub.ItemCollection
+	Media  []Media
+}
+
+// Result reports the items saved by Import.
+type Result struct {
+	Actor      pub.IRI
+	Activities uint
+	Objects    uint
+}
+
+// Export loads the "actor", and the activities of its outbox, from the "s" store.
+func Export(s storage.ReadStore, actor pub.IRI) (*Archive, error) {
+	it, err := s.Load(actor)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load actor %s: %w", actor, err)
+	}
+	a := &Archive{Actor: it, Outbox: make(pub.ItemCollection, 0), Media: make([]Media, 0)}
+	var outbox pub.Item
+	pub.OnActor(it, func(act *pub.Actor) error {
+		outbox = act.Outbox
+		return nil
+	})
+	if pub.IsNil(outbox) {
+		return a, nil
+	}
+	loaded, err := s.Load(outbox.GetLink())
+	if errors.Is(err, storage.ErrNotFound) {
+		return a, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to load outbox %s: %w", outbox.GetLink(), err)
+	}
+	col, ok := loaded.(pub.CollectionInterface)
+	if !ok {
+		return nil, fmt.Errorf("%w: outbox %s is not a collection", storage.ErrNotValid, outbox.GetLink())
+	}
+	for _, act := range col.Collection() {
+		if pub.IsIRI(act) {
+			continue
+		}
+		pub.OnActivity(act, func(act *pub.Activity) error {
+			if pub.IsIRI(act.Object) {
+				if ob, err := s.Load(act.Object.GetLink()); err == nil {
+					act.Object = ob
+				}
+			}
+			return nil
+		})
+		a.Outbox = append(a.Outbox, act)
+	}
+	a.Media = manifest(a.Outbox)
+	return a, nil
+}
+
+// manifest returns the media attached to the objects of the "activities".
+func manifest(activities pub.ItemCollection) []Media {
+	media := make([]Media, 0)
+	for _, ob := range objects(activities) {
+		pub.OnObject(ob, func(o *pub.Object) error {
+			attachments := pub.ItemCollection{o.Attachment}
+			if pub.IsItemCollection(o.Attachment) {
+				pub.OnItemCollection(o.Attachment, func(c *pub.ItemCollection) error {
+					attachments = *c
+					return nil
+				})
+			}
+			for _, att := range attachments {
+				if m, ok := mediaOf(att, o.ID); ok {
+					media = append(media, m)
+				}
+			}
+			return nil
+		})
+	}
+	return media
+}
+
+func mediaOf(att pub.Item, object pub.IRI) (Media, bool) {
+	if pub.IsNil(att) {
+		return Media{}, false
+	}
+	m := Media{URL: att.GetLink(), Object: object}
+	if !pub.IsIRI(att) {
+		pub.OnObject(att, func(o *pub.Object) error {
+			m.MediaType = o.MediaType
+			if !pub.IsNil(o.URL) {
+				m.URL = o.URL.GetLink()
+			}
+			return nil
+		})
+	}
+	if len(m.URL) == 0 {
+		return Media{}, false
+	}
+	// the relative URLs are the paths of the files in the archive
+	if u, err := m.URL.URL(); err == nil && len(u.Host) == 0 {
+		m.Path = strings.TrimPrefix(path.Clean("/"+u.Path), "/")
+	}
+	return m, true
+}
+
+// objects returns the objects embedded in the "activities".
+func objects(activities pub.ItemCollection) pub.ItemCollection {
+	obs := make(pub.ItemCollection, 0)
+	for _, act := range activities {
+		pub.OnActivity(act, func(a *pub.Activity) error {
+			if !pub.IsNil(a.Object) && !pub.IsIRI(a.Object) && len(a.Object.GetLink()) > 0 {
+				obs = append(obs, a.Object)
+			}
+			return nil
+		})
+	}
+	return obs
+}
+
+// WriteTo writes the archive to "w" as a gzipped tarball.
+func (a *Archive) WriteTo(w io.Writer) (int64, error) {
+	cw := &countWriter{w: w}
+	gz := gzip.NewWriter(cw)
+	tw := tar.NewWriter(gz)
+
+	outbox := pub.OrderedCollectionNew(OutboxFile)
+	outbox.OrderedItems = a.Outbox
+	outbox.TotalItems = uint(len(a.Outbox))
+	files := []struct {
+		name string
+		v    interface{}
+	}{
+		{name: ActorFile, v: a.Actor},
+		{name: OutboxFile, v: outbox},
+		{name: MediaFile, v: a.Media},
+	}
+	now := time.Now()
+	for _, f := range files {
+		var data []byte
+		var err error
+		if it, ok := f.v.(pub.Item); ok {
+			data, err = pub.MarshalJSON(it)
+		} else {
+			data, err = json.Marshal(f.v)
+		}
+		if err != nil {
+			return cw.n, fmt.Errorf("unable to encode %s: %w", f.name, err)
+		}
+		hdr := &tar.Header{Name: f.name, Mode: 0o600, Size: int64(len(data)), ModTime: now}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return cw.n, err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return cw.n, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return cw.n, err
+	}
+	err := gz.Close()
+	return cw.n, err
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Read reads an archive from "r", which is a tarball, either gzipped or not.
+// For zip files, use ReadZip, or Open.
+func Read(r io.Reader) (*Archive, error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	} else {
+		r = br
+	}
+	files := make(map[string][]byte)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if name := path.Clean(hdr.Name); isDataFile(name) {
+			if files[name], err = io.ReadAll(tr); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return decode(files)
+}
+
+// ReadZip reads an archive from the "r" zip file, of "size" bytes.
+func ReadZip(r io.ReaderAt, size int64) (*Archive, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string][]byte)
+	for _, f := range zr.File {
+		name := path.Clean(f.Name)
+		if !isDataFile(name) {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		files[name], err = io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return decode(files)
+}
+
+// Open reads the archive from the "name" file, which is a zip file, or a tarball.
+func Open(name string) (*Archive, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(f, magic); err == nil && bytes.Equal(magic, []byte("PK\x03\x04")) {
+		fi, err := f.Stat()
+		if err != nil {
+			return nil, err
+		}
+		return ReadZip(f, fi.Size())
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return Read(f)
+}
+
+func isDataFile(name string) bool {
+	return name == ActorFile || name == OutboxFile || name == MediaFile
+}
+
+func decode(files map[string][]byte) (*Archive, error) {
+	data, ok := files[ActorFile]
+	if !ok {
+		return nil, fmt.Errorf("%w archive: missing %s", storage.ErrNotValid, ActorFile)
+	}
+	actor, err := pub.UnmarshalJSON(data)
+	if err != nil || pub.IsNil(actor) {
+		return nil, fmt.Errorf("%w archive: unable to decode %s: %v", storage.ErrNotValid, ActorFile, err)
+	}
+	a := &Archive{Actor: actor, Outbox: make(pub.ItemCollection, 0)}
+	if data, ok := files[OutboxFile]; ok {
+		outbox, err := pub.UnmarshalJSON(data)
+		if err != nil {
+			return nil, fmt.Errorf("%w archive: unable to decode %s: %s", storage.ErrNotValid, OutboxFile, err)
+		}
+		pub.OnCollectionIntf(outbox, func(col pub.CollectionInterface) error {
+			a.Outbox = col.Collection()
+			return nil
+		})
+	}
+	if data, ok := files[MediaFile]; ok {
+		if err := json.Unmarshal(data, &a.Media); err != nil {
+			return nil, fmt.Errorf("%w archive: unable to decode %s: %s", storage.ErrNotValid, MediaFile, err)
+		}
+	} else {
+		a.Media = manifest(a.Outbox)
+	}
+	return a, nil
+}
+
+// Import saves the actor, the objects, and the activities of the archive in the "s" store.
+// The activities are saved with their properties as IRIs, and, if "s" is a CollectionStore,
+// added to the outbox of the actor. It stops at the first error, and returns what has been saved until then.
+func (a *Archive) Import(s storage.WriteStore) (Result, error) {
+	res := Result{}
+	if pub.IsNil(a.Actor) {
+		return res, fmt.Errorf("%w archive: missing actor", storage.ErrNotValid)
+	}
+	if _, err := s.Save(a.Actor); err != nil {
+		return res, fmt.Errorf("unable to import actor %s: %w", a.Actor.GetLink(), err)
+	}
+	res.Actor = a.Actor.GetLink()
+	for _, ob := range objects(a.Outbox) {
+		if _, err := s.Save(ob); err != nil {
+			return res, fmt.Errorf("unable to import %s: %w", ob.GetLink(), err)
+		}
+		res.Objects++
+	}
+
+	var outbox pub.IRI
+	pub.OnActor(a.Actor, func(act *pub.Actor) error {
+		if !pub.IsNil(act.Outbox) {
+			outbox = act.Outbox.GetLink()
+		}
+		return nil
+	})
+	cs, isCollectionStore := s.(storage.CollectionStore)
+	if isCollectionStore && len(outbox) > 0 {
+		if _, err := cs.Create(pub.OrderedCollectionNew(outbox)); err != nil {
+			return res, fmt.Errorf("unable to create outbox %s: %w", outbox, err)
+		}
+	}
+	for _, act := range a.Outbox {
+		if pub.IsIRI(act) || len(act.GetLink()) == 0 {
+			continue
+		}
+		flat, err := flatten(act)
+		if err != nil {
+			return res, fmt.Errorf("unable to import %s: %w", act.GetLink(), err)
+		}
+		if _, err := s.Save(flat); err != nil {
+			return res, fmt.Errorf("unable to import %s: %w", act.GetLink(), err)
+		}
+		if isCollectionStore && len(outbox) > 0 {
+			if err := cs.AddTo(outbox, act.GetLink()); err != nil {
+				return res, fmt.Errorf("unable to add %s to outbox: %w", act.GetLink(), err)
+			}
+		}
+		res.Activities++
+	}
+	return res, nil
+}
+
+// flatten returns a copy of "it" with its properties replaced by IRIs.
+func flatten(it pub.Item) (pub.Item, error) {
+	raw, err := pub.MarshalJSON(it)
+	if err != nil {
+		return nil, err
+	}
+	cp, err := pub.UnmarshalJSON(raw)
+	if err != nil {
+		return nil, err
+	}
+	return pub.FlattenProperties(cp), nil
+}
@@ -0,0 +1,138 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage/memory"
+)
+
+const mastodonOutbox = `{
+  "@context": "https://www.w3.org/ns/activitystreams",
+  "id": "outbox.json",
+  "type": "OrderedCollection",
+  "totalItems": 1,
+  "orderedItems": [{
+    "id": "https://mastodon.example/users/jdoe/statuses/1/activity",
+    "type": "Create",
+    "actor": "https://mastodon.example/users/jdoe",
+    "object": {
+      "id": "https://mastodon.example/users/jdoe/statuses/1",
+      "type": "Note",
+      "content": "<p>hello</p>",
+      "attachment": [{
+        "type": "Document",
+        "mediaType": "image/png",
+        "url": "/media_attachments/files/000/001/original/cat.png"
+      }]
+    }
+  }]
+}`
+
+const mastodonActor = `{
+  "@context": "https://www.w3.org/ns/activitystreams",
+  "id": "https://mastodon.example/users/jdoe",
+  "type": "Person",
+  "preferredUsername": "jdoe",
+  "outbox": "https://mastodon.example/users/jdoe/outbox"
+}`
+
+func mastodonArchive(t *testing.T) []byte {
+	buf := bytes.Buffer{}
+	zw := zip.NewWriter(&buf)
+	for name, data := range map[string]string{ActorFile: mastodonActor, OutboxFile: mastodonOutbox, "likes.json": "{}"} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("unable to create %s: %s", name, err)
+		}
+		w.Write([]byte(data))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("unable to write the archive: %s", err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadZip_Mastodon(t *testing.T) {
+	data := mastodonArchive(t)
+	a, err := ReadZip(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("ReadZip returned error: %s", err)
+	}
+	if len(a.Outbox) != 1 {
+		t.Fatalf("ReadZip returned %d activities, expected 1", len(a.Outbox))
+	}
+	want := Media{
+		URL:       "/media_attachments/files/000/001/original/cat.png",
+		MediaType: "image/png",
+		Object:    "https://mastodon.example/users/jdoe/statuses/1",
+		Path:      "media_attachments/files/000/001/original/cat.png",
+	}
+	if len(a.Media) != 1 || a.Media[0] != want {
+		t.Errorf("ReadZip returned the %+v media, expected %+v", a.Media, want)
+	}
+
+	s := memory.New()
+	res, err := a.Import(s)
+	if err != nil {
+		t.Fatalf("Import returned error: %s", err)
+	}
+	if res.Activities != 1 || res.Objects != 1 {
+		t.Errorf("Import returned %+v, expected 1 activity and 1 object", res)
+	}
+	for _, iri := range (pub.IRIs{
+		"https://mastodon.example/users/jdoe",
+		"https://mastodon.example/users/jdoe/statuses/1",
+		"https://mastodon.example/users/jdoe/statuses/1/activity",
+	}) {
+		if _, err := s.Load(iri); err != nil {
+			t.Errorf("Load(%s) returned error: %s", iri, err)
+		}
+	}
+	act := pub.IRI("https://mastodon.example/users/jdoe/statuses/1/activity")
+	if ok, _ := s.IsMember("https://mastodon.example/users/jdoe/outbox", act); !ok {
+		t.Errorf("the activity has not been added to the outbox")
+	}
+	if pub.IsIRI(a.Outbox[0]) {
+		t.Errorf("Import should not modify the archive")
+	}
+}
+
+func TestExport(t *testing.T) {
+	data := mastodonArchive(t)
+	a, err := ReadZip(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("ReadZip returned error: %s", err)
+	}
+	s := memory.New()
+	if _, err := a.Import(s); err != nil {
+		t.Fatalf("Import returned error: %s", err)
+	}
+
+	exported, err := Export(s, "https://mastodon.example/users/jdoe")
+	if err != nil {
+		t.Fatalf("Export returned error: %s", err)
+	}
+	buf := bytes.Buffer{}
+	if _, err := exported.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo returned error: %s", err)
+	}
+	read, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("Read returned error: %s", err)
+	}
+	if !read.Actor.GetLink().Equals("https://mastodon.example/users/jdoe", false) {
+		t.Errorf("Read returned the %s actor", read.Actor.GetLink())
+	}
+	if len(read.Outbox) != 1 || len(read.Media) != 1 {
+		t.Fatalf("Read returned %d activities and %d media, expected 1 of each", len(read.Outbox), len(read.Media))
+	}
+	pub.OnActivity(read.Outbox[0], func(act *pub.Activity) error {
+		if pub.IsIRI(act.Object) {
+			t.Errorf("the exported activity should embed its object")
+		}
+		return nil
+	})
+}
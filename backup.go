@@ -0,0 +1,221 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// BackupStore is implemented by the backends that can write a consistent snapshot of their
+// contents, and restore it.
+type BackupStore interface {
+	// Backup writes a snapshot of the stored objects and collections to "w", in the Export format.
+	Backup(w io.Writer) error
+	// Restore loads the objects and collections of a backup from "r", overwriting the stored ones
+	// with the same IRIs.
+	Restore(r io.Reader) error
+}
+
+// IncrementalBackupStore is implemented by the backends that can back up only the items modified
+// after a previous backup, which makes frequent backups of large storages cheap.
+//
+// A full backup followed by the incremental ones taken after it can be restored in order using Restore.
+// The deleted items are not part of the incremental backups, so restoring them doesn't remove
+// the items deleted after the full backup.
+type IncrementalBackupStore interface {
+	// BackupSince writes to "w", in the Export format, the objects and collections that have been
+	// stored, or modified, at, or after, the "since" moment.
+	// Callers should use the moment they started the previous backup, so no modifications get lost.
+	BackupSince(w io.Writer, since time.Time) error
+}
+
+// Backup writes the objects and collections of the "s" store to "w", using its BackupStore
+// implementation, or else Export, which doesn't guarantee a consistent snapshot if "s" gets modified
+// while it runs.
+func Backup(s ReadStore, w io.Writer) error {
+	if bs, ok := s.(BackupStore); ok {
+		return bs.Backup(w)
+	}
+	return Export(s, w)
+}
+
+// BackupSince writes the objects and collections of the "s" store modified since the "since" moment
+// to "w", using its IncrementalBackupStore implementation, or else ExportSince.
+func BackupSince(s ReadStore, w io.Writer, since time.Time) error {
+	if bs, ok := s.(IncrementalBackupStore); ok {
+		return bs.BackupSince(w, since)
+	}
+	return ExportSince(s, w, since)
+}
+
+// Restore loads a backup from "r" into the "s" store, using its BackupStore implementation,
+// or else Import.
+func Restore(s Store, r io.Reader) error {
+	if bs, ok := s.(BackupStore); ok {
+		return bs.Restore(r)
+	}
+	return Import(s, r)
+}
+
+// Export writes the objects of the "s" store, followed by the collections they reference,
+// see CollectionsOf, to "w" in a portable format that can be imported in any backend.
+//
+// The format is line delimited JSON-LD: each line holds the document of an object, or of a collection
+// in the canonical CollectionDocument format, with the members as IRIs.
+func Export(s ReadStore, w io.Writer) error {
+	return export(s, w, func(pub.Item) bool { return true })
+}
+
+// ExportSince writes to "w", like Export, the objects of the "s" store that have been updated,
+// or else published, at, or after, the "since" moment, and the objects without dates.
+// As the modifications of the collections are not dated, all of them are written.
+func ExportSince(s ReadStore, w io.Writer, since time.Time) error {
+	return export(s, w, func(it pub.Item) bool {
+		return !ModifiedAt(it).Before(since)
+	})
+}
+
+// ModifiedAt returns the moment "it" has been last updated, or else published,
+// which is zero for items that have neither.
+func ModifiedAt(it pub.Item) time.Time {
+	var t time.Time
+	pub.OnObject(it, func(o *pub.Object) error {
+		t = o.Updated
+		if t.IsZero() {
+			t = o.Published
+		}
+		return nil
+	})
+	return t
+}
+
+// export writes the objects of "s" for which "keep" returns true to "w", followed by all the collections.
+func export(s ReadStore, w io.Writer, keep func(pub.Item) bool) error {
+	bw := bufio.NewWriter(w)
+	cols := make(pub.IRIs, 0)
+	seen := make(map[pub.IRI]struct{})
+	err := Each(s, pub.IRI(""), func(it pub.Item) error {
+		if pub.CollectionTypes.Contains(it.GetType()) {
+			if _, ok := seen[it.GetLink()]; !ok {
+				seen[it.GetLink()] = struct{}{}
+				cols = append(cols, it.GetLink())
+			}
+			return nil
+		}
+		for _, col := range CollectionsOf(it) {
+			if _, ok := seen[col]; !ok {
+				seen[col] = struct{}{}
+				cols = append(cols, col)
+			}
+		}
+		if !keep(it) {
+			return nil
+		}
+		return WriteExport(bw, it)
+	})
+	if err != nil {
+		return err
+	}
+	for _, iri := range cols {
+		it, err := s.Load(iri)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := WriteExport(bw, it); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// WriteExport writes "it" to "w" as a line of the Export format.
+func WriteExport(w io.Writer, it pub.Item) error {
+	var raw []byte
+	var err error
+	if col, ok := it.(pub.CollectionInterface); ok && pub.CollectionTypes.Contains(it.GetType()) {
+		raw, err = MarshalCollection(col)
+	} else {
+		raw, err = pub.MarshalJSON(it)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to export %s: %w", it.GetLink(), err)
+	}
+	return writeExportLine(w, raw)
+}
+
+func writeExportLine(w io.Writer, raw []byte) error {
+	if _, err := w.Write(raw); err != nil {
+		return err
+	}
+	_, err := w.Write([]byte{'\n'})
+	return err
+}
+
+// ReadExport calls "fn" for each item read from "r", which is in the Export format.
+// The collections are returned with their members as IRIs.
+func ReadExport(r io.Reader, fn func(pub.Item) error) error {
+	br := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		raw, err := br.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if raw = bytes.TrimSpace(raw); len(raw) > 0 {
+			it, uerr := decodeExport(raw)
+			if uerr != nil {
+				return fmt.Errorf("%w document on line %d: %s", ErrNotValid, line, uerr)
+			}
+			if ferr := fn(it); ferr != nil {
+				return ferr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+func decodeExport(raw []byte) (pub.Item, error) {
+	if pub.CollectionTypes.Contains(RawType(raw)) {
+		return UnmarshalCollection(raw)
+	}
+	it, err := pub.UnmarshalJSON(raw)
+	if err == nil && pub.IsNil(it) {
+		err = errors.New("empty document")
+	}
+	return it, err
+}
+
+// Import saves in the "s" store the items read from "r", which is in the Export format.
+// The collections are created, and their members added, if "s" is a CollectionStore, or else saved.
+func Import(s Store, r io.Reader) error {
+	cs, isCollectionStore := s.(CollectionStore)
+	return ReadExport(r, func(it pub.Item) error {
+		col, ok := it.(pub.CollectionInterface)
+		if !ok || !isCollectionStore {
+			if _, err := s.Save(it); err != nil {
+				return fmt.Errorf("unable to import %s: %w", it.GetLink(), err)
+			}
+			return nil
+		}
+		members := col.Collection()
+		if _, err := cs.Create(col); err != nil {
+			return fmt.Errorf("unable to import %s: %w", col.GetLink(), err)
+		}
+		// the collection exists already if Create returned it without the members, so they get added
+		for _, member := range members {
+			if err := cs.AddTo(col.GetLink(), member); err != nil {
+				return fmt.Errorf("unable to import %s: %w", col.GetLink(), err)
+			}
+		}
+		return nil
+	})
+}
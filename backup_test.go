@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+func TestWriteExport(t *testing.T) {
+	ob := note("https://example.com/objects/1", "hello")
+	col := pub.OrderedCollectionNew("https://example.com/actors/jdoe/outbox")
+	col.OrderedItems = pub.ItemCollection{ob}
+
+	buf := bytes.Buffer{}
+	for _, it := range (pub.ItemCollection{ob, col}) {
+		if err := WriteExport(&buf, it); err != nil {
+			t.Fatalf("WriteExport returned error: %s", err)
+		}
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != 2 {
+		t.Errorf("WriteExport wrote %d lines, expected 2", lines)
+	}
+
+	s := newMapStore()
+	if err := Import(s, &buf); err != nil {
+		t.Fatalf("Import returned error: %s", err)
+	}
+	if it, err := s.Load(ob.ID); err != nil || contentOf(it) != "hello" {
+		t.Errorf("Load returned %v, %v, expected the imported object", it, err)
+	}
+	it, err := s.Load(col.ID)
+	if err != nil {
+		t.Fatalf("Load returned error: %s", err)
+	}
+	c, ok := it.(pub.CollectionInterface)
+	if !ok || len(c.Collection()) != 1 || !pub.IsIRI(c.Collection()[0]) {
+		t.Errorf("Load returned %v, expected the collection with its member as an IRI", it)
+	}
+}
+
+func TestReadExport(t *testing.T) {
+	in := "{\"id\":\"https://example.com/objects/1\",\"type\":\"Note\"}\n\n[1, 2\n"
+	read := 0
+	err := ReadExport(strings.NewReader(in), func(pub.Item) error {
+		read++
+		return nil
+	})
+	if !errors.Is(err, ErrNotValid) || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("ReadExport returned %v, expected %s on line 3", err, ErrNotValid)
+	}
+	if read != 1 {
+		t.Errorf("ReadExport read %d items, expected 1", read)
+	}
+}
+
+func TestModifiedAt(t *testing.T) {
+	published := time.Date(2022, 5, 1, 10, 0, 0, 0, time.UTC)
+	ob := note("https://example.com/objects/1", "hello")
+	if got := ModifiedAt(ob); !got.IsZero() {
+		t.Errorf("ModifiedAt returned %s for an object without dates", got)
+	}
+	ob.Published = published
+	if got := ModifiedAt(ob); !got.Equal(published) {
+		t.Errorf("ModifiedAt returned %s, expected the publishing date %s", got, published)
+	}
+	ob.Updated = published.Add(time.Hour)
+	if got := ModifiedAt(ob); !got.Equal(ob.Updated) {
+		t.Errorf("ModifiedAt returned %s, expected the update date %s", got, ob.Updated)
+	}
+}
@@ -0,0 +1,198 @@
+// Package badger implements a storage backend on top of Badger, a pure Go key-value store built
+// as a log-structured merge tree, which suits the write-heavy workloads of federated servers.
+//
+// The items are stored in the bucket matching their type, see storage.Bucket, as keys prefixed by
+// the name of the bucket, and the collections in the storage.CollectionDocument format.
+package badger
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/go-ap/storage"
+	"github.com/go-ap/storage/internal/kv"
+)
+
+// Config holds the options for the Badger storage.
+type Config struct {
+	// Path is the directory in which the database files are stored.
+	Path string
+	// InMemory keeps the database in memory, without writing it to Path, eg: for tests.
+	InMemory bool
+	// CreateIfMissing creates the database if the c.Path directory doesn't hold one, otherwise New fails
+	// for it, so a wrong path isn't mistaken for an empty storage. See Bootstrap.
+	CreateIfMissing bool
+	// Timeout is how long New waits for the database to be closed by the process which has it open,
+	// before failing. It fails right away if it's not set.
+	Timeout time.Duration
+	// ReadOnly opens an existing database without modifying it, with all the write operations returning
+	// storage.ErrReadOnly, eg: for maintenance tools. Unlike the writers, multiple processes can open
+	// the same database in read-only mode.
+	ReadOnly bool
+	// IDGen generates the IDs of new items, using storage.ULIDs if not set.
+	IDGen storage.IDGenFn
+	// Logger reports the messages of the Badger engine. They are discarded if it's not set.
+	Logger storage.Logger
+}
+
+type repo struct {
+	*kv.Store
+}
+
+var (
+	_ storage.Store                  = &repo{}
+	_ storage.CollectionStore        = &repo{}
+	_ storage.OrderedCollectionStore = &repo{}
+	_ storage.IDGenerator            = &repo{}
+	_ storage.ChangeFeed             = &repo{}
+	_ storage.SchemaStore            = &repo{}
+	_ storage.BackupStore            = &repo{}
+	_ io.Closer                      = &repo{}
+)
+
+// New opens the Badger database in the c.Path directory, creating it if it doesn't exist and
+// c.CreateIfMissing is set.
+// Only one process can have the database open at the same time, see Config.Timeout.
+func New(c Config) (*repo, error) {
+	if len(c.Path) == 0 && !c.InMemory {
+		return nil, fmt.Errorf("%w: the path of the storage is empty", storage.ErrNotValid)
+	}
+	l := c.Logger
+	if l == nil {
+		l = storage.NopLogger
+	}
+	if !c.InMemory && (!c.CreateIfMissing || c.ReadOnly) {
+		if _, err := os.Stat(filepath.Join(c.Path, badger.ManifestFilename)); err != nil {
+			return nil, fmt.Errorf("unable to open the badger storage %s, see Bootstrap: %w", c.Path, err)
+		}
+	}
+	opts := badger.DefaultOptions(c.Path)
+	if c.InMemory {
+		opts = badger.DefaultOptions("").WithInMemory(true)
+	}
+	b, err := open(opts.WithLogger(logger{Logger: l}).WithReadOnly(c.ReadOnly), c.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open the badger storage %s: %w", c.Path, err)
+	}
+	s := kv.New(db{DB: b}, kv.Options{IDGen: c.IDGen, ReadOnly: c.ReadOnly})
+	if err := s.Upgrade(); err != nil {
+		s.Close()
+		return nil, fmt.Errorf("unable to open the badger storage %s: %w", c.Path, err)
+	}
+	return &repo{Store: s}, nil
+}
+
+// lockRetry is the interval at which open retries to acquire the lock of the database.
+const lockRetry = 50 * time.Millisecond
+
+// open opens the database, retrying while it's locked by another process, until "timeout" passes.
+func open(opts badger.Options, timeout time.Duration) (*badger.DB, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		b, err := badger.Open(opts)
+		if err == nil || !isLocked(err) || time.Now().Add(lockRetry).After(deadline) {
+			return b, err
+		}
+		time.Sleep(lockRetry)
+	}
+}
+
+// isLocked reports whether "err" is the failure to acquire the lock of the database directory, which
+// Badger doesn't return as a distinct error.
+func isLocked(err error) bool {
+	return strings.Contains(err.Error(), "Cannot acquire directory lock")
+}
+
+// Bootstrap creates the Badger database in the c.Path directory, if it doesn't exist, and records the
+// version of its layout, so the first run of a service doesn't need any manual setup.
+func Bootstrap(c Config) error {
+	c.CreateIfMissing = true
+	r, err := New(c)
+	if err != nil {
+		return err
+	}
+	return r.Close()
+}
+
+// db is the kv.DB of a Badger database.
+type db struct {
+	*badger.DB
+}
+
+func (d db) View(fn func(kv.Tx) error) error {
+	return d.DB.View(func(txn *badger.Txn) error {
+		return fn(tx{Txn: txn})
+	})
+}
+
+// Update runs "fn" in a read-write transaction, again if it conflicts with a concurrent one.
+func (d db) Update(fn func(kv.Tx) error) error {
+	for {
+		err := d.DB.Update(func(txn *badger.Txn) error {
+			return fn(tx{Txn: txn})
+		})
+		if !errors.Is(err, badger.ErrConflict) {
+			return err
+		}
+	}
+}
+
+type tx struct {
+	*badger.Txn
+}
+
+func (t tx) Get(key []byte) ([]byte, error) {
+	item, err := t.Txn.Get(key)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, kv.ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return item.ValueCopy(nil)
+}
+
+func (t tx) Scan(prefix []byte, reverse bool, fn func(key, value []byte) bool) error {
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = prefix
+	opts.Reverse = reverse
+	it := t.Txn.NewIterator(opts)
+	defer it.Close()
+
+	seek := prefix
+	if reverse {
+		// the reverse iteration starts from the greatest key lower than, or equal to, the seek key
+		seek = append(append([]byte{}, prefix...), 0xff)
+	}
+	for it.Seek(seek); it.Valid(); it.Next() {
+		item := it.Item()
+		value, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		if !fn(item.Key(), value) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// logger adapts a storage.Logger to the Badger logger.
+type logger struct {
+	storage.Logger
+}
+
+func message(format string, v ...interface{}) string {
+	return strings.TrimSpace(fmt.Sprintf(format, v...))
+}
+
+func (l logger) Errorf(format string, v ...interface{})   { l.Error(message(format, v...), nil) }
+func (l logger) Warningf(format string, v ...interface{}) { l.Warn(message(format, v...), nil) }
+func (l logger) Infof(format string, v ...interface{})    { l.Info(message(format, v...), nil) }
+func (l logger) Debugf(format string, v ...interface{})   { l.Debug(message(format, v...), nil) }
@@ -0,0 +1,187 @@
+package badger
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage"
+	"github.com/go-ap/storage/storagetest"
+)
+
+func newTestRepo(t *testing.T) *repo {
+	r, err := New(Config{InMemory: true})
+	if err != nil {
+		t.Fatalf("New returned error: %s", err)
+	}
+	t.Cleanup(func() { r.Close() })
+	return r
+}
+
+func TestConformance(t *testing.T) {
+	storagetest.RunStoreTests(t, func() storage.Store { return newTestRepo(t) })
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(Config{}); !errors.Is(err, storage.ErrNotValid) {
+		t.Errorf("New without a path returned %v, expected %s", err, storage.ErrNotValid)
+	}
+
+	dir := t.TempDir()
+	r, err := New(Config{Path: dir, CreateIfMissing: true})
+	if err != nil {
+		t.Fatalf("New returned error: %s", err)
+	}
+	if _, err := New(Config{Path: dir}); err == nil {
+		t.Errorf("New of a storage opened by another process should fail")
+	}
+	outbox := pub.IRI("https://example.com/actors/jdoe/outbox")
+	ob := &pub.Object{ID: "https://example.com/objects/1", Type: pub.NoteType}
+	r.Save(ob)
+	r.Create(pub.OrderedCollectionNew(outbox))
+	r.AddTo(outbox, ob)
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close returned error: %s", err)
+	}
+
+	r, err = New(Config{Path: dir})
+	if err != nil {
+		t.Fatalf("New returned error: %s", err)
+	}
+	defer r.Close()
+	it, err := r.Load(outbox)
+	if err != nil {
+		t.Fatalf("Load returned error: %s", err)
+	}
+	col, ok := it.(*pub.OrderedCollection)
+	if !ok || len(col.OrderedItems) != 1 || col.OrderedItems[0].GetType() != pub.NoteType {
+		t.Errorf("Load after reopening the storage returned %#v, expected the collection with the object", it)
+	}
+}
+
+func TestNew_timeout(t *testing.T) {
+	dir := t.TempDir()
+	r, err := New(Config{Path: dir, CreateIfMissing: true})
+	if err != nil {
+		t.Fatalf("New returned error: %s", err)
+	}
+	start := time.Now()
+	if _, err := New(Config{Path: dir, Timeout: 200 * time.Millisecond}); err == nil {
+		t.Errorf("New of a storage opened by another process should fail after the timeout")
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("New failed after %s, expected it to wait for the timeout", elapsed)
+	}
+
+	time.AfterFunc(100*time.Millisecond, func() { r.Close() })
+	s, err := New(Config{Path: dir, Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatalf("New returned error after the storage has been closed: %s", err)
+	}
+	s.Close()
+}
+
+func TestBootstrap(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "storage")
+	if _, err := New(Config{Path: dir}); err == nil {
+		t.Errorf("New of a missing storage should fail without CreateIfMissing")
+	}
+	if err := Bootstrap(Config{Path: dir}); err != nil {
+		t.Fatalf("Bootstrap returned error: %s", err)
+	}
+	r, err := New(Config{Path: dir})
+	if err != nil {
+		t.Fatalf("New after Bootstrap returned error: %s", err)
+	}
+	defer r.Close()
+	if v, err := r.SchemaVersion(); err != nil || v == 0 {
+		t.Errorf("SchemaVersion returned %d, %v, expected Bootstrap to record the version", v, err)
+	}
+}
+
+func TestLoadCollection(t *testing.T) {
+	r := newTestRepo(t)
+	outbox := pub.IRI("https://example.com/actors/jdoe/outbox")
+	r.Create(pub.OrderedCollectionNew(outbox))
+	r.Create(pub.OrderedCollectionNew(outbox + "/archived"))
+	r.AddTo(outbox+"/archived", pub.IRI("https://example.com/objects/0"))
+	for _, id := range []pub.IRI{"https://example.com/objects/1", "https://example.com/objects/2", "https://example.com/objects/3"} {
+		r.AddTo(outbox, id)
+	}
+
+	items, next, err := r.LoadCollection(outbox, storage.Page{IRI: outbox, Max: 2})
+	if err != nil {
+		t.Fatalf("LoadCollection returned error: %s", err)
+	}
+	if len(items) != 2 || items[0].GetLink() != "https://example.com/objects/3" || items[1].GetLink() != "https://example.com/objects/2" {
+		t.Errorf("LoadCollection returned %v, expected the 2 most recent members", items)
+	}
+	items, next, err = r.LoadCollection(outbox, storage.Page{IRI: outbox, Max: 2, After: next})
+	if err != nil || len(items) != 1 || items[0].GetLink() != "https://example.com/objects/1" || len(next) > 0 {
+		t.Errorf("LoadCollection returned %v, %q, %v, expected the oldest member and no next page", items, next, err)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := New(Config{Path: filepath.Join(dir, "missing"), ReadOnly: true}); err == nil {
+		t.Errorf("New in read-only mode should fail for a missing storage")
+	}
+	w, err := New(Config{Path: dir, CreateIfMissing: true})
+	if err != nil {
+		t.Fatalf("New returned error: %s", err)
+	}
+	ob := &pub.Object{ID: "https://example.com/objects/1", Type: pub.NoteType}
+	w.Save(ob)
+	w.Close()
+
+	r, err := New(Config{Path: dir, ReadOnly: true})
+	if err != nil {
+		t.Fatalf("New returned error: %s", err)
+	}
+	defer r.Close()
+	if _, err := r.Load(ob.ID); err != nil {
+		t.Errorf("Load returned error: %s", err)
+	}
+	if _, err := r.Save(ob); !errors.Is(err, storage.ErrReadOnly) {
+		t.Errorf("Save returned %v, expected %s", err, storage.ErrReadOnly)
+	}
+	if err := r.Delete(ob); !errors.Is(err, storage.ErrReadOnly) {
+		t.Errorf("Delete returned %v, expected %s", err, storage.ErrReadOnly)
+	}
+	if _, err := r.Create(pub.OrderedCollectionNew("https://example.com/actors/jdoe/outbox")); !errors.Is(err, storage.ErrReadOnly) {
+		t.Errorf("Create returned %v, expected %s", err, storage.ErrReadOnly)
+	}
+	if err := r.AddTo("https://example.com/actors/jdoe/outbox", ob); !errors.Is(err, storage.ErrReadOnly) {
+		t.Errorf("AddTo returned %v, expected %s", err, storage.ErrReadOnly)
+	}
+}
+
+func TestBackupRestore(t *testing.T) {
+	r := newTestRepo(t)
+	outbox := pub.IRI("https://example.com/actors/jdoe/outbox")
+	ob := &pub.Object{ID: "https://example.com/objects/1", Type: pub.NoteType}
+	r.Save(ob)
+	r.Create(pub.OrderedCollectionNew(outbox))
+	r.AddTo(outbox, ob)
+
+	buf := bytes.Buffer{}
+	if err := storage.Backup(r, &buf); err != nil {
+		t.Fatalf("Backup returned error: %s", err)
+	}
+	restored := newTestRepo(t)
+	if err := storage.Restore(restored, bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("Restore returned error: %s", err)
+	}
+	it, err := restored.Load(outbox)
+	if err != nil {
+		t.Fatalf("Load returned error: %s", err)
+	}
+	col, ok := it.(*pub.OrderedCollection)
+	if !ok || len(col.OrderedItems) != 1 || col.OrderedItems[0].GetType() != pub.NoteType {
+		t.Errorf("Load after Restore returned %#v, expected the collection with the restored object", it)
+	}
+}
@@ -0,0 +1,46 @@
+package storage
+
+import (
+	pub "github.com/go-ap/activitypub"
+)
+
+// BatchStore can write multiple items at once, which allows backends to apply bursts of writes,
+// like the activities received by a busy inbox, in a single transaction.
+type BatchStore interface {
+	// SaveAll saves all the "items", and returns them together with any properties populated by the
+	// method's side effects. Backends that support transactions should save either all or none of them.
+	SaveAll(items []pub.Item) ([]pub.Item, error)
+	// DeleteAll deletes completely from storage all the "items".
+	DeleteAll(items []pub.Item) error
+}
+
+// SaveAll saves "items" to the "s" store, using a single batch if it's a BatchStore, or saving them
+// one by one otherwise, in which case it stops at the first error.
+func SaveAll(s WriteStore, items []pub.Item) ([]pub.Item, error) {
+	if bs, ok := s.(BatchStore); ok {
+		return bs.SaveAll(items)
+	}
+	saved := make([]pub.Item, 0, len(items))
+	for _, it := range items {
+		it, err := s.Save(it)
+		if err != nil {
+			return saved, err
+		}
+		saved = append(saved, it)
+	}
+	return saved, nil
+}
+
+// DeleteAll deletes "items" from the "s" store, using a single batch if it's a BatchStore, or deleting
+// them one by one otherwise, in which case it stops at the first error.
+func DeleteAll(s WriteStore, items []pub.Item) error {
+	if bs, ok := s.(BatchStore); ok {
+		return bs.DeleteAll(items)
+	}
+	for _, it := range items {
+		if err := s.Delete(it); err != nil {
+			return err
+		}
+	}
+	return nil
+}
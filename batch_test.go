@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// batchStore records the batches it receives.
+type batchStore struct {
+	*mapStore
+	batches int
+}
+
+func (b *batchStore) SaveAll(items []pub.Item) ([]pub.Item, error) {
+	b.batches++
+	for _, it := range items {
+		b.mapStore.Save(it)
+	}
+	return items, nil
+}
+
+func (b *batchStore) DeleteAll(items []pub.Item) error {
+	b.batches++
+	for _, it := range items {
+		b.mapStore.Delete(it)
+	}
+	return nil
+}
+
+// failingStore fails saving the items without an ID.
+type failingStore struct {
+	*mapStore
+}
+
+func (f failingStore) Save(it pub.Item) (pub.Item, error) {
+	if len(it.GetLink()) == 0 {
+		return nil, errors.New("missing ID")
+	}
+	return f.mapStore.Save(it)
+}
+
+func TestSaveAll(t *testing.T) {
+	items := []pub.Item{
+		note("https://example.com/objects/1", "1"),
+		note("https://example.com/objects/2", "2"),
+	}
+
+	bs := &batchStore{mapStore: newMapStore()}
+	if _, err := SaveAll(bs, items); err != nil {
+		t.Fatalf("SaveAll returned error: %s", err)
+	}
+	if bs.batches != 1 || len(bs.items) != 2 {
+		t.Errorf("expected a single batch with 2 items, got %d batches and %d items", bs.batches, len(bs.items))
+	}
+	if err := DeleteAll(bs, items); err != nil {
+		t.Fatalf("DeleteAll returned error: %s", err)
+	}
+	if bs.batches != 2 || len(bs.items) != 0 {
+		t.Errorf("expected a second batch removing all items, got %d batches and %d items", bs.batches, len(bs.items))
+	}
+
+	fs := failingStore{newMapStore()}
+	saved, err := SaveAll(fs, append(items, note("", "3"), note("https://example.com/objects/4", "4")))
+	if err == nil {
+		t.Errorf("SaveAll should return the error of the failed Save")
+	}
+	if len(saved) != 2 || len(fs.items) != 2 {
+		t.Errorf("SaveAll should stop at the first error, saved %d items", len(fs.items))
+	}
+	if err := DeleteAll(fs, items); err != nil || len(fs.items) != 0 {
+		t.Errorf("DeleteAll should delete the items one by one, %d left: %v", len(fs.items), err)
+	}
+}
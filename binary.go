@@ -0,0 +1,22 @@
+package storage
+
+import (
+	"io"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// BinaryStore keeps binary data, like the media attached to the objects, under the IRIs of the objects
+// representing them, eg: the Image or Document objects of an attachment.
+//
+// The data is owned by its object: deleting the object deletes the data too.
+type BinaryStore interface {
+	// SaveBinary stores the data read from "r", which has the "contentType" MIME type, under "iri",
+	// replacing any previous data.
+	SaveBinary(iri pub.IRI, r io.Reader, contentType string) error
+	// LoadBinary returns a reader for the data stored under "iri", which the caller needs to close,
+	// and its MIME type, or an error wrapping ErrNotFound.
+	LoadBinary(iri pub.IRI) (io.ReadCloser, string, error)
+	// DeleteBinary removes the data stored under "iri", leaving its object in place.
+	DeleteBinary(iri pub.IRI) error
+}
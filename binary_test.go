@@ -0,0 +1,26 @@
+package storage
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+func TestBinaryStore_deleted(t *testing.T) {
+	s := newBackendMapStore()
+	img := &pub.Object{ID: "https://example.com/media/1", Type: pub.ImageType, MediaType: "image/png"}
+	s.Save(img)
+	if err := s.SaveBinary(img.ID, strings.NewReader("png"), "image/png"); err != nil {
+		t.Fatalf("SaveBinary returned error: %s", err)
+	}
+
+	// replacing the object with its Tombstone deletes the data it owns
+	if err := SoftDelete(s).Delete(img.ID); err != nil {
+		t.Fatalf("Delete returned error: %s", err)
+	}
+	if _, _, err := s.LoadBinary(img.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("LoadBinary of a deleted object returned %v, expected %s", err, ErrNotFound)
+	}
+}
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"net/url"
+	"strings"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// Bucket is the name of the group in which backends store a category of items.
+type Bucket string
+
+const (
+	// BucketActors holds the Actor objects.
+	BucketActors Bucket = "actors"
+	// BucketActivities holds the Activity and IntransitiveActivity objects.
+	BucketActivities Bucket = "activities"
+	// BucketObjects holds all other objects.
+	BucketObjects Bucket = "objects"
+	// BucketCollections holds the collections, as CollectionDocument records.
+	BucketCollections Bucket = "collections"
+)
+
+// CollectionPaths contains the path segments of the ActivityPub collections, which identify
+// an IRI as belonging to the collections bucket.
+var CollectionPaths = []string{
+	"inbox",
+	"outbox",
+	"followers",
+	"following",
+	"liked",
+	"likes",
+	"shares",
+	"replies",
+}
+
+// BucketFor returns the bucket in which the item identified by "iri" is stored, based on its path:
+// IRIs ending in one of the CollectionPaths are collections, the others belong to the bucket
+// matching the first path segment which is the name of a bucket,
+// eg: "https://example.com/actors/jdoe/outbox" is a collection, "https://example.com/actors/jdoe" is an actor.
+//
+// When no bucket can be determined it returns an empty value, and backends are expected to look
+// for the item in all of them.
+func BucketFor(iri pub.IRI) Bucket {
+	u, err := url.Parse(iri.String())
+	if err != nil {
+		return ""
+	}
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	last := segments[len(segments)-1]
+	for _, col := range CollectionPaths {
+		if last == col {
+			return BucketCollections
+		}
+	}
+	for _, seg := range segments {
+		switch b := Bucket(seg); b {
+		case BucketActors, BucketActivities, BucketObjects, BucketCollections:
+			return b
+		}
+	}
+	return ""
+}
+
+// BucketForItem returns the bucket in which "it" is stored, based on its type, falling back
+// to BucketFor when the type doesn't identify one.
+func BucketForItem(it pub.Item) Bucket {
+	if pub.IsNil(it) {
+		return ""
+	}
+	typ := it.GetType()
+	switch {
+	case pub.ActorTypes.Contains(typ):
+		return BucketActors
+	case pub.ActivityTypes.Contains(typ), pub.IntransitiveActivityTypes.Contains(typ):
+		return BucketActivities
+	case pub.CollectionTypes.Contains(typ):
+		return BucketCollections
+	case pub.ObjectTypes.Contains(typ):
+		return BucketObjects
+	}
+	return BucketFor(it.GetLink())
+}
@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+func TestBucketFor(t *testing.T) {
+	tests := []struct {
+		iri  pub.IRI
+		want Bucket
+	}{
+		{iri: "", want: ""},
+		{iri: "https://example.com", want: ""},
+		{iri: "https://example.com/", want: ""},
+		{iri: "https://example.com/inbox", want: BucketCollections},
+		{iri: "https://example.com/actors", want: BucketActors},
+		{iri: "https://example.com/actors/jdoe", want: BucketActors},
+		{iri: "https://example.com/actors/jdoe/", want: BucketActors},
+		{iri: "https://example.com/actors/jdoe/outbox", want: BucketCollections},
+		{iri: "https://example.com/activities/1", want: BucketActivities},
+		{iri: "https://example.com/objects/1", want: BucketObjects},
+		{iri: "https://example.com/objects/1/replies", want: BucketCollections},
+		{iri: "https://example.com/objects/1?page=2", want: BucketObjects},
+		{iri: "https://example.com/~jdoe/notes/1", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.iri), func(t *testing.T) {
+			if got := BucketFor(tt.iri); got != tt.want {
+				t.Errorf("BucketFor(%q) = %q, want %q", tt.iri, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBucketForItem(t *testing.T) {
+	tests := []struct {
+		name string
+		item pub.Item
+		want Bucket
+	}{
+		{name: "nil", item: nil, want: ""},
+		{name: "actor", item: pub.PersonNew("https://example.com/~jdoe"), want: BucketActors},
+		{name: "activity", item: pub.CreateNew("https://example.com/1", nil), want: BucketActivities},
+		{name: "question", item: pub.QuestionNew("https://example.com/1"), want: BucketActivities},
+		{name: "object", item: pub.ObjectNew(pub.NoteType), want: BucketObjects},
+		{name: "collection", item: pub.OrderedCollectionNew("https://example.com/1"), want: BucketCollections},
+		{name: "iri", item: pub.IRI("https://example.com/objects/1"), want: BucketObjects},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := BucketForItem(tt.item); got != tt.want {
+				t.Errorf("BucketForItem() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+// Package bulk imports large dumps of ActivityStreams objects into a storage backend, writing them
+// in batches from parallel workers, eg: for seeding a new instance, or for migrations of hundreds of
+// thousands of objects.
+//
+// A dump is in the storage.Export format, newline-delimited JSON-LD documents with the collections
+// as storage.CollectionDocument, or a tarball, either gzipped or not, of files in that format.
+package bulk
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage"
+)
+
+// DefaultBatchSize is the number of items written at once when Options.BatchSize isn't set.
+const DefaultBatchSize = 1000
+
+// Options control an import.
+type Options struct {
+	// BatchSize is the number of items each worker writes at once, see storage.SaveAll.
+	BatchSize int
+	// Workers is the number of batches written in parallel, the number of CPUs if not set.
+	Workers int
+	// OnProgress, if set, gets called after each batch has been written. The calls are serialized.
+	OnProgress func(Progress)
+}
+
+// Progress reports the state of an import.
+type Progress struct {
+	// Objects is the number of saved objects.
+	Objects uint64
+	// Collections is the number of imported collections.
+	Collections uint64
+	// Elapsed is the time since the import started.
+	Elapsed time.Duration
+}
+
+// Rate returns the number of items imported per second.
+func (p Progress) Rate() float64 {
+	if p.Elapsed <= 0 {
+		return 0
+	}
+	return float64(p.Objects+p.Collections) / p.Elapsed.Seconds()
+}
+
+// Import saves in the "s" store the items of the dump read from "r". The collections are created,
+// and their members added, if "s" is a storage.CollectionStore, or else saved.
+// The order in which the items are written isn't deterministic. It stops at the first error,
+// and returns the progress until that moment.
+func Import(s storage.WriteStore, r io.Reader, o Options) (Progress, error) {
+	if o.BatchSize <= 0 {
+		o.BatchSize = DefaultBatchSize
+	}
+	if o.Workers <= 0 {
+		o.Workers = runtime.NumCPU()
+	}
+	im := &importer{s: s, o: o, start: time.Now(), batches: make(chan []pub.Item), done: make(chan struct{})}
+	im.cs, _ = s.(storage.CollectionStore)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < o.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range im.batches {
+				im.write(batch)
+			}
+		}()
+	}
+
+	batch := make([]pub.Item, 0, o.BatchSize)
+	err := read(r, func(it pub.Item) error {
+		if batch = append(batch, it); len(batch) < o.BatchSize {
+			return nil
+		}
+		if !im.send(batch) {
+			return errStopped
+		}
+		batch = make([]pub.Item, 0, o.BatchSize)
+		return nil
+	})
+	if err == nil && len(batch) > 0 {
+		im.send(batch)
+	}
+	close(im.batches)
+	wg.Wait()
+
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	im.p.Elapsed = time.Since(im.start)
+	if im.err != nil {
+		return im.p, im.err
+	}
+	return im.p, err
+}
+
+// errStopped stops reading the dump when writing a batch failed.
+var errStopped = errors.New("import stopped")
+
+type importer struct {
+	s     storage.WriteStore
+	cs    storage.CollectionStore
+	o     Options
+	start time.Time
+
+	batches chan []pub.Item
+	done    chan struct{}
+
+	mu  sync.Mutex
+	p   Progress
+	err error
+}
+
+// send queues "batch" for the workers, and reports false if the import failed.
+func (im *importer) send(batch []pub.Item) bool {
+	select {
+	case im.batches <- batch:
+		return true
+	case <-im.done:
+		return false
+	}
+}
+
+func (im *importer) write(batch []pub.Item) {
+	select {
+	case <-im.done:
+		return
+	default:
+	}
+	objects := make([]pub.Item, 0, len(batch))
+	var cols uint64
+	for _, it := range batch {
+		col, ok := it.(pub.CollectionInterface)
+		if !ok || im.cs == nil {
+			objects = append(objects, it)
+			continue
+		}
+		if err := im.collection(col); err != nil {
+			im.fail(err)
+			return
+		}
+		cols++
+	}
+	if _, err := storage.SaveAll(im.s, objects); err != nil {
+		im.fail(fmt.Errorf("unable to save a batch of %d objects: %w", len(objects), err))
+		return
+	}
+
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	if im.err != nil {
+		return
+	}
+	im.p.Objects += uint64(len(objects))
+	im.p.Collections += cols
+	im.p.Elapsed = time.Since(im.start)
+	if im.o.OnProgress != nil {
+		im.o.OnProgress(im.p)
+	}
+}
+
+func (im *importer) collection(col pub.CollectionInterface) error {
+	members := col.Collection()
+	if _, err := im.cs.Create(col); err != nil {
+		return fmt.Errorf("unable to import %s: %w", col.GetLink(), err)
+	}
+	// the collection exists already if Create returned it without the members, so they get added
+	for _, member := range members {
+		if err := im.cs.AddTo(col.GetLink(), member); err != nil {
+			return fmt.Errorf("unable to import %s: %w", col.GetLink(), err)
+		}
+	}
+	return nil
+}
+
+func (im *importer) fail(err error) {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	if im.err == nil {
+		im.err = err
+		close(im.done)
+	}
+}
+
+// read calls "fn" for each item of the dump read from "r", which is either in the storage.Export format,
+// or a tarball of such files, optionally gzipped.
+func read(r io.Reader, fn func(pub.Item) error) error {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		br = bufio.NewReader(gz)
+	}
+	// the magic of the ustar format is at offset 257 of the header
+	if magic, _ := br.Peek(262); len(magic) == 262 && string(magic[257:]) == "ustar" {
+		return readTar(br, fn)
+	}
+	return storage.ReadExport(br, fn)
+}
+
+func readTar(r io.Reader, fn func(pub.Item) error) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg || strings.HasPrefix(path.Base(hdr.Name), ".") {
+			continue
+		}
+		if err := storage.ReadExport(tr, fn); err != nil {
+			return fmt.Errorf("%s: %w", hdr.Name, err)
+		}
+	}
+}
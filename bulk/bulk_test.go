@@ -0,0 +1,97 @@
+package bulk
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage"
+	"github.com/go-ap/storage/memory"
+)
+
+func dump(t *testing.T, objects int) []byte {
+	buf := bytes.Buffer{}
+	outbox := pub.OrderedCollectionNew("https://example.com/actors/jdoe/outbox")
+	for i := 0; i < objects; i++ {
+		ob := &pub.Object{ID: pub.IRI(fmt.Sprintf("https://example.com/objects/%d", i)), Type: pub.NoteType}
+		if err := storage.WriteExport(&buf, ob); err != nil {
+			t.Fatalf("unable to write the dump: %s", err)
+		}
+		outbox.OrderedItems.Append(ob.ID)
+	}
+	if err := storage.WriteExport(&buf, outbox); err != nil {
+		t.Fatalf("unable to write the dump: %s", err)
+	}
+	return buf.Bytes()
+}
+
+func TestImport(t *testing.T) {
+	r := memory.New()
+	batches := 0
+	p, err := Import(r, bytes.NewReader(dump(t, 10)), Options{BatchSize: 3, Workers: 2, OnProgress: func(Progress) {
+		batches++
+	}})
+	if err != nil {
+		t.Fatalf("Import returned error: %s", err)
+	}
+	if p.Objects != 10 || p.Collections != 1 || batches != 4 || p.Rate() <= 0 {
+		t.Errorf("Import returned %+v after %d batches", p, batches)
+	}
+	col, err := r.Load("https://example.com/actors/jdoe/outbox")
+	if err != nil {
+		t.Fatalf("unable to load the imported collection: %s", err)
+	}
+	if c, ok := col.(pub.CollectionInterface); !ok || c.Count() != 10 {
+		t.Errorf("the imported collection should have 10 members, got %v", col)
+	}
+}
+
+func TestImport_tarball(t *testing.T) {
+	buf := bytes.Buffer{}
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for i, name := range []string{"dump/1.jsonl", "dump/2.jsonl"} {
+		data := dump(t, i+1)
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0o600, Size: int64(len(data)), Typeflag: tar.TypeReg})
+		tw.Write(data)
+	}
+	tw.Close()
+	gz.Close()
+
+	r := memory.New()
+	p, err := Import(r, &buf, Options{})
+	if err != nil {
+		t.Fatalf("Import returned error: %s", err)
+	}
+	if p.Objects != 3 || p.Collections != 2 {
+		t.Errorf("Import returned %+v, expected 3 objects and 2 collections", p)
+	}
+}
+
+type failingStore struct {
+	storage.Store
+	saves int32
+}
+
+func (f *failingStore) Save(it pub.Item) (pub.Item, error) {
+	if atomic.AddInt32(&f.saves, 1) > 4 {
+		return nil, storage.ErrConflict
+	}
+	return f.Store.Save(it)
+}
+
+func TestImport_failure(t *testing.T) {
+	s := &failingStore{Store: memory.New()}
+	p, err := Import(s, bytes.NewReader(dump(t, 100)), Options{BatchSize: 2, Workers: 1})
+	if !errors.Is(err, storage.ErrConflict) {
+		t.Errorf("Import returned %v, expected %s", err, storage.ErrConflict)
+	}
+	if p.Objects != 4 || atomic.LoadInt32(&s.saves) > 6 {
+		t.Errorf("Import should stop after the failed batch, returned %+v after %d saves", p, s.saves)
+	}
+}
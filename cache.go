@@ -0,0 +1,193 @@
+package storage
+
+import (
+	"container/list"
+	"sync"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// Cache returns a Store that keeps the "size" most recently used objects of "s" in memory.
+//
+// The cache guarantees read-your-writes: once Save returns, any Load of the same IRI returns the saved
+// value, even when a Load that started before the Save finishes after it. Save updates the cached entry
+// synchronously instead of just invalidating it, and results of loads that raced with a write are
+// never cached.
+//
+// Only single objects are cached, loads returning collections always reach the backend.
+// The objects are cached encoded, so the changes the callers make to the items they save,
+// or load, don't affect the cached values.
+// When "s" is a CollectionStore, so is the returned Store, with the operations on collections
+// forwarded to it.
+func Cache(s Store, size int) Store {
+	if size < 1 {
+		size = 1
+	}
+	return &cache{
+		Store: s,
+		size:  size,
+		lru:   list.New(),
+		items: make(map[pub.IRI]*list.Element),
+	}
+}
+
+type cache struct {
+	Store
+	size int
+
+	mu    sync.Mutex
+	lru   *list.List
+	items map[pub.IRI]*list.Element
+	loads inflight
+}
+
+// inflight holds the loads that missed a cache and are waiting for the backend, so the writes
+// happening in the meantime can prevent them from caching what could be a stale value.
+// It needs to be used with the lock of its cache held.
+type inflight map[pub.IRI][]*cacheLoad
+
+type cacheLoad struct {
+	stale bool
+}
+
+// start records a load of "iri" that missed the cache.
+func (l *inflight) start(iri pub.IRI) *cacheLoad {
+	if *l == nil {
+		*l = make(inflight)
+	}
+	load := &cacheLoad{}
+	(*l)[iri] = append((*l)[iri], load)
+	return load
+}
+
+// invalidate marks the loads of "iri" in progress as stale.
+func (l inflight) invalidate(iri pub.IRI) {
+	for _, load := range l[iri] {
+		load.stale = true
+	}
+}
+
+// done ends the "load" of "iri", and reports if its result can be cached.
+func (l inflight) done(iri pub.IRI, load *cacheLoad) bool {
+	loads := l[iri]
+	for i, other := range loads {
+		if other == load {
+			loads = append(loads[:i], loads[i+1:]...)
+			break
+		}
+	}
+	if len(loads) == 0 {
+		delete(l, iri)
+	} else {
+		l[iri] = loads
+	}
+	return !load.stale
+}
+
+type cacheEntry struct {
+	iri pub.IRI
+	raw []byte
+}
+
+// Load returns the cached object for "iri", or loads it from the backend store.
+func (c *cache) Load(iri pub.IRI) (pub.Item, error) {
+	c.mu.Lock()
+	if el, ok := c.items[iri]; ok {
+		c.lru.MoveToFront(el)
+		raw := el.Value.(*cacheEntry).raw
+		c.mu.Unlock()
+		return JSON.Unmarshal(raw)
+	}
+	load := c.loads.start(iri)
+	c.mu.Unlock()
+
+	it, err := c.Store.Load(iri)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.loads.done(iri, load) && err == nil && cacheable(iri, it) {
+		c.set(iri, it)
+	}
+	return it, err
+}
+
+// Save saves "it" in the backend store and updates the cached value before returning.
+func (c *cache) Save(it pub.Item) (pub.Item, error) {
+	saved, err := c.Store.Save(it)
+	if err != nil {
+		return saved, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	iri := it.GetLink()
+	if !pub.IsNil(saved) {
+		iri = saved.GetLink()
+	}
+	c.loads.invalidate(iri)
+	if cacheable(iri, saved) {
+		c.set(iri, saved)
+	} else {
+		c.remove(iri)
+	}
+	return saved, nil
+}
+
+// Delete deletes "it" from the backend store and removes it from the cache.
+func (c *cache) Delete(it pub.Item) error {
+	err := c.Store.Delete(it)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	iri := it.GetLink()
+	c.loads.invalidate(iri)
+	c.remove(iri)
+	return err
+}
+
+// Create creates the "col" collection in the backend store, which needs to be a CollectionStore.
+func (c *cache) Create(col pub.CollectionInterface) (pub.CollectionInterface, error) {
+	return asCollectionStore(c.Store).Create(col)
+}
+
+// AddTo adds "it" to the "col" collection in the backend store, which needs to be a CollectionStore.
+func (c *cache) AddTo(col pub.IRI, it pub.Item) error {
+	return asCollectionStore(c.Store).AddTo(col, it)
+}
+
+// RemoveFrom removes "it" from the "col" collection in the backend store, which needs to be a CollectionStore.
+func (c *cache) RemoveFrom(col pub.IRI, it pub.Item) error {
+	return asCollectionStore(c.Store).RemoveFrom(col, it)
+}
+
+// cacheable reports if "it", loaded from "iri", can be cached: collections are not, as their members
+// change without the collections being saved.
+func cacheable(iri pub.IRI, it pub.Item) bool {
+	return len(iri) > 0 && !pub.IsNil(it) && !pub.IsItemCollection(it) && it.GetLink() == iri &&
+		!pub.CollectionTypes.Contains(it.GetType())
+}
+
+// set caches the encoded "it", or removes the "iri" entry if it can't be encoded.
+func (c *cache) set(iri pub.IRI, it pub.Item) {
+	raw, err := JSON.Marshal(it)
+	if err != nil {
+		c.remove(iri)
+		return
+	}
+	if el, ok := c.items[iri]; ok {
+		el.Value.(*cacheEntry).raw = raw
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.items[iri] = c.lru.PushFront(&cacheEntry{iri: iri, raw: raw})
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back().Value.(*cacheEntry).iri)
+	}
+}
+
+func (c *cache) remove(iri pub.IRI) {
+	if el, ok := c.items[iri]; ok {
+		c.lru.Remove(el)
+		delete(c.items, iri)
+	}
+}
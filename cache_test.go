@@ -0,0 +1,211 @@
+package storage
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// mapStore is a minimal Store used for testing the Store decorators.
+type mapStore struct {
+	mu    sync.RWMutex
+	items map[pub.IRI]pub.Item
+	loads int
+	// beforeLoad, when set, gets called by Load after reading the value, but before returning it.
+	beforeLoad func(iri pub.IRI)
+}
+
+func newMapStore() *mapStore {
+	return &mapStore{items: make(map[pub.IRI]pub.Item)}
+}
+
+func (m *mapStore) Load(iri pub.IRI) (pub.Item, error) {
+	m.mu.Lock()
+	m.loads++
+	it, ok := m.items[iri]
+	m.mu.Unlock()
+	if m.beforeLoad != nil {
+		m.beforeLoad(iri)
+	}
+	if !ok {
+		return nil, fmt.Errorf("not found %s: %w", iri, ErrNotFound)
+	}
+	return it, nil
+}
+
+func (m *mapStore) Close() error {
+	return nil
+}
+
+func (m *mapStore) Save(it pub.Item) (pub.Item, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.items[it.GetLink()] = it
+	return it, nil
+}
+
+func (m *mapStore) Delete(it pub.Item) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.items, it.GetLink())
+	return nil
+}
+
+func note(iri pub.IRI, content string) *pub.Object {
+	o := pub.ObjectNew(pub.NoteType)
+	o.ID = iri
+	o.Content.Set(pub.NilLangRef, pub.Content(content))
+	return o
+}
+
+func contentOf(it pub.Item) string {
+	content := ""
+	pub.OnObject(it, func(o *pub.Object) error {
+		content = o.Content.First().Value.String()
+		return nil
+	})
+	return content
+}
+
+func TestCache_Load(t *testing.T) {
+	iri := pub.IRI("https://example.com/objects/1")
+	backend := newMapStore()
+	backend.Save(note(iri, "v1"))
+
+	c := Cache(backend, 10)
+	for i := 0; i < 3; i++ {
+		it, err := c.Load(iri)
+		if err != nil {
+			t.Fatalf("Load returned error: %s", err)
+		}
+		if got := contentOf(it); got != "v1" {
+			t.Errorf("Load returned %q, expected %q", got, "v1")
+		}
+	}
+	if backend.loads != 1 {
+		t.Errorf("expected backend to be loaded once, got %d loads", backend.loads)
+	}
+	if _, err := c.Load("https://example.com/objects/missing"); err == nil {
+		t.Errorf("Load of missing IRI should return the backend error")
+	}
+}
+
+func TestCache_Evict(t *testing.T) {
+	backend := newMapStore()
+	c := Cache(backend, 2)
+	for i := 1; i <= 3; i++ {
+		c.Save(note(pub.IRI(fmt.Sprintf("https://example.com/objects/%d", i)), "v1"))
+	}
+	c.Load("https://example.com/objects/3")
+	c.Load("https://example.com/objects/2")
+	if backend.loads != 0 {
+		t.Errorf("expected most recently used items to be cached, got %d backend loads", backend.loads)
+	}
+	c.Load("https://example.com/objects/1")
+	if backend.loads != 1 {
+		t.Errorf("expected least recently used item to be evicted, got %d backend loads", backend.loads)
+	}
+}
+
+func TestCache_ReadYourWrites(t *testing.T) {
+	iri := pub.IRI("https://example.com/objects/1")
+	backend := newMapStore()
+	backend.Save(note(iri, "v1"))
+
+	loaded := make(chan struct{})
+	release := make(chan struct{})
+	backend.beforeLoad = func(_ pub.IRI) {
+		// the first load reads "v1" and gets stuck until the Save has completed
+		loaded <- struct{}{}
+		<-release
+	}
+
+	c := Cache(backend, 10)
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		it, _ := c.Load(iri)
+		if got := contentOf(it); got != "v1" {
+			t.Errorf("in flight Load returned %q, expected %q", got, "v1")
+		}
+	}()
+	<-loaded
+	backend.beforeLoad = nil
+
+	if _, err := c.Save(note(iri, "v2")); err != nil {
+		t.Fatalf("Save returned error: %s", err)
+	}
+	it, _ := c.Load(iri)
+	if got := contentOf(it); got != "v2" {
+		t.Errorf("Load after Save returned %q, expected %q", got, "v2")
+	}
+
+	close(release)
+	wg.Wait()
+
+	it, _ = c.Load(iri)
+	if got := contentOf(it); got != "v2" {
+		t.Errorf("Load after the stale load finished returned %q, expected %q", got, "v2")
+	}
+}
+
+func TestCache_Delete(t *testing.T) {
+	iri := pub.IRI("https://example.com/objects/1")
+	backend := newMapStore()
+	c := Cache(backend, 10)
+
+	c.Save(note(iri, "v1"))
+	if err := c.Delete(note(iri, "")); err != nil {
+		t.Fatalf("Delete returned error: %s", err)
+	}
+	if it, err := c.Load(iri); err == nil {
+		t.Errorf("Load after Delete returned %v, expected error", it)
+	}
+}
+
+func TestCache_Collections(t *testing.T) {
+	inbox := pub.IRI("https://example.com/actors/jdoe/inbox")
+	backend := loadableCollectionMapStore{newCollectionMapStore()}
+	c := Cache(backend, 10)
+
+	cs, ok := c.(CollectionStore)
+	if !ok {
+		t.Fatalf("Cache should be a CollectionStore when the backend is one")
+	}
+	if _, err := cs.Create(pub.OrderedCollectionNew(inbox)); err != nil {
+		t.Fatalf("Create returned error: %s", err)
+	}
+	c.Load(inbox)
+	backend.AddTo(inbox, pub.IRI("https://example.com/objects/1"))
+
+	it, err := c.Load(inbox)
+	if err != nil {
+		t.Fatalf("Load returned error: %s", err)
+	}
+	if col, ok := it.(pub.CollectionInterface); !ok || col.Count() != 1 {
+		t.Errorf("collections should not be cached, got %v", it)
+	}
+}
+
+func TestCache_Copies(t *testing.T) {
+	iri := pub.IRI("https://example.com/objects/1")
+	c := Cache(newMapStore(), 10)
+
+	ob := note(iri, "v1")
+	c.Save(ob)
+	ob.Content.Set(pub.NilLangRef, pub.Content("changed after saving"))
+	it, _ := c.Load(iri)
+	if got := contentOf(it); got != "v1" {
+		t.Errorf("changing a saved item should not change the cached value, got %q", got)
+	}
+	pub.OnObject(it, func(o *pub.Object) error {
+		o.Content.Set(pub.NilLangRef, pub.Content("changed after loading"))
+		return nil
+	})
+	if it, _ = c.Load(iri); contentOf(it) != "v1" {
+		t.Errorf("changing a loaded item should not change the cached value, got %q", contentOf(it))
+	}
+}
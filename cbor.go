@@ -0,0 +1,233 @@
+package storage
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+
+	pub "github.com/go-ap/activitypub"
+	"github.com/valyala/fastjson"
+)
+
+// cborCodec transcodes the JSON documents of the items to CBOR, using the definite length encodings,
+// integers for the numbers without a fractional part, and double precision floats for the rest.
+type cborCodec struct{}
+
+const (
+	cborUint   = 0
+	cborNegint = 1
+	cborText   = 3
+	cborArray  = 4
+	cborMap    = 5
+	cborSimple = 7
+
+	cborFalse   = 0xf4
+	cborTrue    = 0xf5
+	cborNull    = 0xf6
+	cborFloat32 = 0xfa
+	cborFloat64 = 0xfb
+)
+
+func (c cborCodec) Marshal(it pub.Item) ([]byte, error) {
+	raw, err := pub.MarshalJSON(it)
+	if err != nil {
+		return nil, err
+	}
+	val, err := fastjson.ParseBytes(raw)
+	if err != nil {
+		return nil, err
+	}
+	return appendCBOR(make([]byte, 0, len(raw)), val), nil
+}
+
+func (c cborCodec) Unmarshal(raw []byte) (pub.Item, error) {
+	doc, err := c.ToJSON(raw)
+	if err != nil {
+		return nil, err
+	}
+	return pub.UnmarshalJSON(doc)
+}
+
+// ToJSON converts the "raw" CBOR document to JSON.
+func (c cborCodec) ToJSON(raw []byte) ([]byte, error) {
+	doc, rest, err := appendJSON(make([]byte, 0, len(raw)*2), raw)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("%w: %d bytes after the CBOR document", ErrNotValid, len(rest))
+	}
+	return doc, nil
+}
+
+func appendCBORHead(b []byte, major byte, n uint64) []byte {
+	size := 0
+	switch {
+	case n < 24:
+		return append(b, major<<5|byte(n))
+	case n <= math.MaxUint8:
+		b, size = append(b, major<<5|24), 1
+	case n <= math.MaxUint16:
+		b, size = append(b, major<<5|25), 2
+	case n <= math.MaxUint32:
+		b, size = append(b, major<<5|26), 4
+	default:
+		b, size = append(b, major<<5|27), 8
+	}
+	for i := size - 1; i >= 0; i-- {
+		b = append(b, byte(n>>(8*i)))
+	}
+	return b
+}
+
+func appendCBOR(b []byte, val *fastjson.Value) []byte {
+	switch val.Type() {
+	case fastjson.TypeObject:
+		ob := val.GetObject()
+		b = appendCBORHead(b, cborMap, uint64(ob.Len()))
+		ob.Visit(func(key []byte, v *fastjson.Value) {
+			b = appendCBORHead(b, cborText, uint64(len(key)))
+			b = append(b, key...)
+			b = appendCBOR(b, v)
+		})
+		return b
+	case fastjson.TypeArray:
+		arr := val.GetArray()
+		b = appendCBORHead(b, cborArray, uint64(len(arr)))
+		for _, v := range arr {
+			b = appendCBOR(b, v)
+		}
+		return b
+	case fastjson.TypeString:
+		s := val.GetStringBytes()
+		return append(appendCBORHead(b, cborText, uint64(len(s))), s...)
+	case fastjson.TypeNumber:
+		if i, err := strconv.ParseInt(val.String(), 10, 64); err == nil {
+			if i < 0 {
+				return appendCBORHead(b, cborNegint, uint64(-(i + 1)))
+			}
+			return appendCBORHead(b, cborUint, uint64(i))
+		}
+		f := math.Float64bits(val.GetFloat64())
+		b = append(b, cborFloat64)
+		for i := 7; i >= 0; i-- {
+			b = append(b, byte(f>>(8*i)))
+		}
+		return b
+	case fastjson.TypeTrue:
+		return append(b, cborTrue)
+	case fastjson.TypeFalse:
+		return append(b, cborFalse)
+	}
+	return append(b, cborNull)
+}
+
+// readCBORHead returns the major type and the argument of the data item at the start of "raw",
+// and the rest of the bytes.
+func readCBORHead(raw []byte) (byte, uint64, []byte, error) {
+	if len(raw) == 0 {
+		return 0, 0, nil, fmt.Errorf("%w: truncated CBOR document", ErrNotValid)
+	}
+	major, info := raw[0]>>5, raw[0]&0x1f
+	raw = raw[1:]
+	size := 0
+	switch {
+	case info < 24:
+		return major, uint64(info), raw, nil
+	case info == 24:
+		size = 1
+	case info == 25:
+		size = 2
+	case info == 26:
+		size = 4
+	case info == 27:
+		size = 8
+	default:
+		return 0, 0, nil, fmt.Errorf("%w: unsupported CBOR encoding %#x", ErrNotValid, info)
+	}
+	if len(raw) < size {
+		return 0, 0, nil, fmt.Errorf("%w: truncated CBOR document", ErrNotValid)
+	}
+	n := uint64(0)
+	for _, c := range raw[:size] {
+		n = n<<8 | uint64(c)
+	}
+	return major, n, raw[size:], nil
+}
+
+func appendJSON(b []byte, raw []byte) ([]byte, []byte, error) {
+	if len(raw) > 0 && raw[0]>>5 == cborSimple {
+		switch raw[0] {
+		case cborFalse:
+			return append(b, "false"...), raw[1:], nil
+		case cborTrue:
+			return append(b, "true"...), raw[1:], nil
+		case cborNull:
+			return append(b, "null"...), raw[1:], nil
+		case cborFloat32:
+			if len(raw) < 5 {
+				break
+			}
+			f := math.Float32frombits(binary.BigEndian.Uint32(raw[1:]))
+			return strconv.AppendFloat(b, float64(f), 'g', -1, 32), raw[5:], nil
+		case cborFloat64:
+			if len(raw) < 9 {
+				break
+			}
+			f := math.Float64frombits(binary.BigEndian.Uint64(raw[1:]))
+			return strconv.AppendFloat(b, f, 'g', -1, 64), raw[9:], nil
+		}
+		return nil, nil, fmt.Errorf("%w: unsupported CBOR value %#x", ErrNotValid, raw[0])
+	}
+	major, n, raw, err := readCBORHead(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch major {
+	case cborUint:
+		return strconv.AppendUint(b, n, 10), raw, nil
+	case cborNegint:
+		return strconv.AppendInt(b, -1-int64(n), 10), raw, nil
+	case cborText:
+		if uint64(len(raw)) < n {
+			return nil, nil, fmt.Errorf("%w: truncated CBOR document", ErrNotValid)
+		}
+		s, err := json.Marshal(string(raw[:n]))
+		if err != nil {
+			return nil, nil, err
+		}
+		return append(b, s...), raw[n:], nil
+	case cborArray:
+		b = append(b, '[')
+		for i := uint64(0); i < n; i++ {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			if b, raw, err = appendJSON(b, raw); err != nil {
+				return nil, nil, err
+			}
+		}
+		return append(b, ']'), raw, nil
+	case cborMap:
+		b = append(b, '{')
+		for i := uint64(0); i < n; i++ {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			if len(raw) == 0 || raw[0]>>5 != cborText {
+				return nil, nil, fmt.Errorf("%w: CBOR map keys need to be text", ErrNotValid)
+			}
+			if b, raw, err = appendJSON(b, raw); err != nil {
+				return nil, nil, err
+			}
+			b = append(b, ':')
+			if b, raw, err = appendJSON(b, raw); err != nil {
+				return nil, nil, err
+			}
+		}
+		return append(b, '}'), raw, nil
+	}
+	return nil, nil, fmt.Errorf("%w: unsupported CBOR major type %d", ErrNotValid, major)
+}
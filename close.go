@@ -0,0 +1,15 @@
+package storage
+
+import (
+	"io"
+)
+
+// Close releases the resources held by the "s" store, like file handles, locks or connections,
+// if it's an io.Closer, like all the Store implementations, for the callers holding only a ReadStore.
+// After it, the operations of the store return ErrClosed.
+func Close(s ReadStore) error {
+	if c, ok := s.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+)
+
+type closingMapStore struct {
+	*mapStore
+	closed int
+}
+
+func (c *closingMapStore) Close() error {
+	c.closed++
+	return errors.New("closed")
+}
+
+func TestClose(t *testing.T) {
+	if err := Close(newMapStore()); err != nil {
+		t.Errorf("Close of a store without resources returned error: %s", err)
+	}
+	c := &closingMapStore{mapStore: newMapStore()}
+	if err := Close(c); err == nil || c.closed != 1 {
+		t.Errorf("Close should close io.Closer stores, and return their error")
+	}
+}
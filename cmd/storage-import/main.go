@@ -0,0 +1,78 @@
+// Command storage-import imports dumps of ActivityStreams objects into a filesystem storage.
+//
+// Usage:
+//
+//	storage-import -path /var/lib/fedbox [-batch 1000] [-workers 8] [dump...]
+//
+// The dumps are in the storage.Export format, or tarballs of such files, see the bulk package.
+// Without arguments, the dump is read from the standard input.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/go-ap/storage"
+	"github.com/go-ap/storage/bulk"
+	"github.com/go-ap/storage/fs"
+)
+
+func main() {
+	path := flag.String("path", "", "the directory of the filesystem storage")
+	batch := flag.Int("batch", bulk.DefaultBatchSize, "the number of items written at once")
+	workers := flag.Int("workers", 0, "the number of batches written in parallel, the number of CPUs if 0")
+	flag.Parse()
+
+	log.SetFlags(0)
+	if len(*path) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	s, err := fs.New(fs.Config{Path: *path})
+	if err != nil {
+		log.Fatalf("unable to open the storage: %s", err)
+	}
+	defer s.Close()
+
+	last := time.Now()
+	o := bulk.Options{BatchSize: *batch, Workers: *workers, OnProgress: func(p bulk.Progress) {
+		if time.Since(last) >= time.Second {
+			last = time.Now()
+			log.Print(summary(p))
+		}
+	}}
+	names := flag.Args()
+	if len(names) == 0 {
+		names = []string{"-"}
+	}
+	for _, name := range names {
+		if err := importFile(s, name, o); err != nil {
+			s.Close()
+			log.Fatalf("unable to import %s: %s", name, err)
+		}
+	}
+}
+
+func importFile(s storage.WriteStore, name string, o bulk.Options) error {
+	var r io.Reader = os.Stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	p, err := bulk.Import(s, r, o)
+	log.Printf("%s: %s", name, summary(p))
+	return err
+}
+
+func summary(p bulk.Progress) string {
+	return fmt.Sprintf("imported %d objects and %d collections in %s, %.0f items/s",
+		p.Objects, p.Collections, p.Elapsed.Round(time.Millisecond), p.Rate())
+}
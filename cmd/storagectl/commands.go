@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage"
+	"github.com/go-ap/storage/bulk"
+	"github.com/go-ap/storage/fs"
+	"github.com/go-ap/storage/migrate"
+)
+
+var errUsage = errors.New("invalid arguments")
+
+// iriArg returns the single IRI argument of a command.
+func iriArg(args []string) (pub.IRI, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("%w: expected an IRI", errUsage)
+	}
+	return pub.IRI(args[0]), nil
+}
+
+// input opens the file named by the optional argument of a command, or returns the standard input.
+func input(args []string) (io.ReadCloser, error) {
+	if len(args) == 0 || args[0] == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	return os.Open(args[0])
+}
+
+// local returns the function reporting the IRIs under one of the comma separated "bases" as local.
+func local(bases string) (func(pub.IRI) bool, error) {
+	iris := make(pub.IRIs, 0)
+	for _, base := range strings.Split(bases, ",") {
+		if base = strings.TrimSpace(base); len(base) > 0 {
+			iris = append(iris, pub.IRI(base))
+		}
+	}
+	if len(iris) == 0 {
+		return nil, fmt.Errorf("%w: missing -local", errUsage)
+	}
+	return func(iri pub.IRI) bool {
+		for _, base := range iris {
+			if storage.UnderIRI(iri, base) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+func printJSON(v interface{}) error {
+	e := json.NewEncoder(os.Stdout)
+	e.SetIndent("", "  ")
+	return e.Encode(v)
+}
+
+func get(s storage.Store, args []string) error {
+	iri, err := iriArg(args)
+	if err != nil {
+		return err
+	}
+	it, err := s.Load(iri)
+	if err != nil {
+		return err
+	}
+	raw, err := pub.MarshalJSON(it)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(os.Stdout, "%s\n", raw)
+	return err
+}
+
+func put(s storage.Store, args []string) error {
+	r, err := input(args)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	it, err := pub.UnmarshalJSON(raw)
+	if err != nil {
+		return err
+	}
+	if pub.IsNil(it) {
+		return fmt.Errorf("%w: empty document", storage.ErrNotValid)
+	}
+	saved, err := s.Save(it)
+	if err != nil {
+		return err
+	}
+	if !pub.IsNil(saved) {
+		it = saved
+	}
+	fmt.Println(it.GetLink())
+	return nil
+}
+
+func del(s storage.Store, args []string) error {
+	iri, err := iriArg(args)
+	if err != nil {
+		return err
+	}
+	return s.Delete(iri)
+}
+
+func lsCollection(s storage.Store, args []string) error {
+	flags := flag.NewFlagSet("ls-collection", flag.ContinueOnError)
+	max := flags.Int("max", 0, "the maximum number of members to print, all of them if 0")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	iri, err := iriArg(flags.Args())
+	if err != nil {
+		return err
+	}
+	items, _, err := storage.LoadCollection(s, iri, iri)
+	if err != nil {
+		return err
+	}
+	for i, it := range items {
+		if *max > 0 && i >= *max {
+			break
+		}
+		fmt.Println(it.GetLink())
+	}
+	return nil
+}
+
+func export(s storage.Store, args []string) error {
+	if len(args) == 0 || args[0] == "-" {
+		return storage.Backup(s, os.Stdout)
+	}
+	f, err := os.Create(args[0])
+	if err != nil {
+		return err
+	}
+	if err := storage.Backup(s, f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func importDumps(s storage.Store, args []string) error {
+	if len(args) == 0 {
+		args = []string{"-"}
+	}
+	for _, name := range args {
+		r, err := input([]string{name})
+		if err != nil {
+			return err
+		}
+		p, err := bulk.Import(s, r, bulk.Options{})
+		r.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		fmt.Fprintf(os.Stderr, "%s: imported %d objects and %d collections\n", name, p.Objects, p.Collections)
+	}
+	return nil
+}
+
+func verify(s storage.Store, args []string) error {
+	flags := flag.NewFlagSet("verify", flag.ContinueOnError)
+	bases := flags.String("local", "", "the comma separated IRIs under which the local objects are stored")
+	repair := flags.Bool("repair", false, "fix the problems which can be fixed")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	isLocal, err := local(*bases)
+	if err != nil {
+		return err
+	}
+	report, err := storage.Verify(s, storage.VerifyOptions{Repair: *repair, Local: isLocal})
+	if err != nil {
+		return err
+	}
+	return printJSON(report)
+}
+
+func gc(s storage.Store, args []string) error {
+	flags := flag.NewFlagSet("gc", flag.ContinueOnError)
+	bases := flags.String("local", "", "the comma separated IRIs under which the local objects are stored")
+	age := flags.Duration("age", 30*24*time.Hour, "the age of the remote objects to collect")
+	dryRun := flags.Bool("dry-run", false, "only report the objects which would be collected")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	isLocal, err := local(*bases)
+	if err != nil {
+		return err
+	}
+	report, err := storage.GC(s, storage.GCOptions{Local: isLocal, Before: time.Now().Add(-*age), DryRun: *dryRun})
+	for _, iri := range report.Collected {
+		fmt.Println(iri)
+	}
+	fmt.Fprintf(os.Stderr, "collected %d objects, kept %d\n", len(report.Collected), report.Kept)
+	return err
+}
+
+func migrateTo(s storage.Store, args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	resume := flags.Bool("resume", false, "skip the objects present in the destination already")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("%w: expected the destination directory", errUsage)
+	}
+	src, ok := s.(migrate.Source)
+	if !ok {
+		return fmt.Errorf("%w: the storage can't be iterated", errUsage)
+	}
+	dst, err := fs.New(fs.Config{Path: flags.Arg(0)})
+	if err != nil {
+		return fmt.Errorf("unable to open the destination: %w", err)
+	}
+	defer dst.Close()
+	p, err := migrate.Copy(src, dst, migrate.Options{Resume: *resume})
+	fmt.Fprintf(os.Stderr, "copied %d objects and %d collections, skipped %d\n", p.Objects, p.Collections, p.Skipped)
+	return err
+}
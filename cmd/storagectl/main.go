@@ -0,0 +1,125 @@
+// Command storagectl inspects and fixes the data of a filesystem storage, or of any storage served over HTTP
+// by the handler of the rest package.
+//
+// Usage:
+//
+//	storagectl [-backend fs] -path DIR COMMAND [ARGS]
+//	storagectl -backend rest -url URL [-token TOKEN] COMMAND [ARGS]
+//
+// The commands are:
+//
+//	get IRI                                load an item, and print it as JSON-LD
+//	put [FILE]                             save the JSON-LD document read from FILE, or the standard input
+//	del IRI                                delete an item
+//	ls-collection [-max N] IRI             print the IRIs of the members of a collection, most recent first
+//	export [FILE]                          write the items in the storage.Export format, see storage.Backup
+//	import [FILE...]                       import dumps, see the bulk package
+//	verify -local IRIS [-repair]           check the consistency of the storage, see storage.Verify
+//	gc -local IRIS [-age D] [-dry-run]     delete the orphaned copies of remote objects, and print their IRIs
+//	migrate [-resume] DIR                  copy the storage to the one in DIR, see migrate.Copy
+//
+// The -local flags take a comma separated list of IRIs, under which the local objects are stored.
+// The export, verify, gc and migrate commands need to iterate the storage, which the rest backend can't do.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/go-ap/storage"
+	"github.com/go-ap/storage/fs"
+	"github.com/go-ap/storage/rest"
+)
+
+type command struct {
+	name string
+	run  func(s storage.Store, args []string) error
+}
+
+var commands = []command{
+	{name: "get", run: get},
+	{name: "put", run: put},
+	{name: "del", run: del},
+	{name: "ls-collection", run: lsCollection},
+	{name: "export", run: export},
+	{name: "import", run: importDumps},
+	{name: "verify", run: verify},
+	{name: "gc", run: gc},
+	{name: "migrate", run: migrateTo},
+}
+
+func usage() {
+	names := make([]string, 0, len(commands))
+	for _, c := range commands {
+		names = append(names, c.name)
+	}
+	fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-backend fs] -path DIR COMMAND [ARGS]\n"+
+		"       %s -backend rest -url URL [-token TOKEN] COMMAND [ARGS]\n\nCommands: %s\n\nFlags:\n",
+		os.Args[0], os.Args[0], strings.Join(names, ", "))
+	flag.PrintDefaults()
+}
+
+// open opens the storage of the "backend" type, at "path" for the filesystem ones, or "url" for the rest ones.
+func open(backend, path, url, token string) (storage.Store, error) {
+	switch backend {
+	case "fs":
+		if len(path) == 0 {
+			return nil, fmt.Errorf("%w: missing -path", errUsage)
+		}
+		return fs.New(fs.Config{Path: path})
+	case "rest":
+		if len(url) == 0 {
+			return nil, fmt.Errorf("%w: missing -url", errUsage)
+		}
+		return rest.New(rest.Config{URL: url, Token: token})
+	}
+	return nil, fmt.Errorf("%w: unknown backend %q, expected fs or rest", errUsage, backend)
+}
+
+func main() {
+	backend := flag.String("backend", "fs", "the type of the storage: fs, or rest")
+	path := flag.String("path", "", "the directory of the filesystem storage")
+	url := flag.String("url", "", "the base URL of the rest storage")
+	token := flag.String("token", os.Getenv("STORAGE_TOKEN"), "the bearer token of the rest storage, $STORAGE_TOKEN by default")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	name := flag.Arg(0)
+	for _, c := range commands {
+		if c.name != name {
+			continue
+		}
+		s, err := open(*backend, *path, *url, *token)
+		if errors.Is(err, errUsage) {
+			fmt.Fprintln(os.Stderr, err)
+			flag.Usage()
+			os.Exit(2)
+		}
+		if err != nil {
+			fail(fmt.Errorf("unable to open the storage: %w", err))
+		}
+		err = c.run(s, flag.Args()[1:])
+		if cerr := storage.Close(s); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			fail(fmt.Errorf("%s: %w", name, err))
+		}
+		return
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n", name)
+	flag.Usage()
+	os.Exit(2)
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"bytes"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// Codec encodes the items into the documents that backends store, and decodes them back.
+type Codec interface {
+	Marshal(it pub.Item) ([]byte, error)
+	Unmarshal(raw []byte) (pub.Item, error)
+}
+
+// JSONTranscoder is implemented by the Codecs which can convert their documents to JSON without
+// decoding the items, see RawJSON.
+type JSONTranscoder interface {
+	ToJSON(raw []byte) ([]byte, error)
+}
+
+var (
+	// JSON encodes the items as plain JSON documents, without a JSON-LD context.
+	JSON Codec = jsonCodec{}
+	// JSONLD encodes the items as JSON-LD documents, with the ActivityStreams context.
+	JSONLD Codec = jsonldCodec{}
+	// CBOR encodes the items as CBOR (RFC 8949) documents, which are smaller than their JSON equivalents.
+	CBOR Codec = cborCodec{}
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(it pub.Item) ([]byte, error) {
+	return pub.MarshalJSON(it)
+}
+
+func (jsonCodec) Unmarshal(raw []byte) (pub.Item, error) {
+	return pub.UnmarshalJSON(raw)
+}
+
+type jsonldCodec struct{}
+
+var activityStreamsContext = []byte(`"@context":"https://www.w3.org/ns/activitystreams"`)
+
+func (jsonldCodec) Marshal(it pub.Item) ([]byte, error) {
+	raw, err := pub.MarshalJSON(it)
+	if err != nil || len(raw) < 2 || raw[0] != '{' {
+		return raw, err
+	}
+	doc := make([]byte, 0, len(raw)+len(activityStreamsContext)+1)
+	doc = append(doc, '{')
+	doc = append(doc, activityStreamsContext...)
+	if !bytes.Equal(raw, []byte("{}")) {
+		doc = append(doc, ',')
+	}
+	return append(doc, raw[1:]...), nil
+}
+
+func (jsonldCodec) Unmarshal(raw []byte) (pub.Item, error) {
+	return pub.UnmarshalJSON(raw)
+}
+
+// RawJSON returns the JSON document corresponding to the "raw" document encoded with "c", so it can be
+// checked using raw filters, see MatchRaw. Documents of the JSON and JSONLD codecs are returned unchanged.
+func RawJSON(c Codec, raw []byte) ([]byte, error) {
+	switch tc := c.(type) {
+	case jsonCodec, jsonldCodec:
+		return raw, nil
+	case JSONTranscoder:
+		return tc.ToJSON(raw)
+	}
+	it, err := c.Unmarshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	return pub.MarshalJSON(it)
+}
+
+// MatchEncoded works like MatchDocument, for documents encoded with "c".
+func MatchEncoded(f Filterable, c Codec, raw []byte) (pub.Item, bool) {
+	it, ok, _ := DecodeMatching(f, c, raw)
+	return it, ok
+}
+
+// DecodeMatching works like MatchEncoded, but it returns the error for the documents which can't
+// be decoded, instead of reporting them as not matching, so the backends can log them.
+func DecodeMatching(f Filterable, c Codec, raw []byte) (pub.Item, bool, error) {
+	doc, err := RawJSON(c, raw)
+	if err != nil {
+		return nil, false, err
+	}
+	return matchDocument(f, doc)
+}
@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+func TestCodecs(t *testing.T) {
+	ob := note("https://example.com/objects/1", "<p>Hello \"world\" ✨</p>")
+	ob.Published = time.Date(2022, time.May, 1, 12, 0, 0, 0, time.UTC)
+	ob.To = pub.ItemCollection{pub.PublicNS}
+	actor := &pub.Actor{ID: "https://example.com/actors/jdoe", Type: pub.PersonType, Inbox: pub.IRI("https://example.com/actors/jdoe/inbox")}
+	question := &pub.Question{ID: "https://example.com/questions/1", Type: pub.QuestionType, OneOf: pub.ItemCollection{note("", "yes"), note("", "no")}}
+	place := &pub.Place{ID: "https://example.com/places/1", Type: pub.PlaceType, Latitude: -45.5, Longitude: 120, Radius: 3}
+
+	codecs := map[string]Codec{"JSON": JSON, "JSONLD": JSONLD, "CBOR": CBOR}
+	for name, c := range codecs {
+		t.Run(name, func(t *testing.T) {
+			for _, it := range []pub.Item{ob, actor, question, place} {
+				raw, err := c.Marshal(it)
+				if err != nil {
+					t.Fatalf("Marshal returned error: %s", err)
+				}
+				decoded, err := c.Unmarshal(raw)
+				if err != nil {
+					t.Fatalf("Unmarshal returned error: %s", err)
+				}
+				want, _ := pub.MarshalJSON(it)
+				got, _ := pub.MarshalJSON(decoded)
+				if !bytes.Equal(got, want) {
+					t.Errorf("the decoded item is %s, expected %s", got, want)
+				}
+				doc, err := RawJSON(c, raw)
+				if err != nil {
+					t.Fatalf("RawJSON returned error: %s", err)
+				}
+				if RawType(doc) != it.GetType() {
+					t.Errorf("RawJSON returned a document of type %q, expected %q", RawType(doc), it.GetType())
+				}
+			}
+		})
+	}
+}
+
+func TestJSONLD(t *testing.T) {
+	raw, _ := JSONLD.Marshal(note("https://example.com/objects/1", "hello"))
+	if !bytes.HasPrefix(raw, []byte(`{"@context":"https://www.w3.org/ns/activitystreams","id":`)) {
+		t.Errorf("Marshal returned %s, expected the ActivityStreams context", raw)
+	}
+}
+
+func TestCBOR(t *testing.T) {
+	raw, _ := CBOR.Marshal(note("https://example.com/objects/1", "hello"))
+	json, _ := JSON.Marshal(note("https://example.com/objects/1", "hello"))
+	if len(raw) >= len(json) {
+		t.Errorf("the CBOR document has %d bytes, expected less than the %d of the JSON one", len(raw), len(json))
+	}
+
+	tests := []struct {
+		name string
+		raw  []byte
+		want string
+	}{
+		{name: "small uint", raw: []byte{0x17}, want: "23"},
+		{name: "uint16", raw: []byte{0x19, 0x03, 0xe8}, want: "1000"},
+		{name: "negint", raw: []byte{0x38, 0x63}, want: "-100"},
+		{name: "float64", raw: []byte{0xfb, 0x3f, 0xf1, 0x99, 0x99, 0x99, 0x99, 0x99, 0x9a}, want: "1.1"},
+		{name: "text", raw: []byte{0x62, 0x22, 0x5c}, want: `"\"\\"`},
+		{name: "array", raw: []byte{0x83, 0x01, 0xf5, 0xf6}, want: "[1,true,null]"},
+		{name: "map", raw: []byte{0xa1, 0x61, 0x61, 0x80}, want: `{"a":[]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CBOR.(JSONTranscoder).ToJSON(tt.raw)
+			if err != nil {
+				t.Fatalf("ToJSON returned error: %s", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("ToJSON returned %s, expected %s", got, tt.want)
+			}
+		})
+	}
+
+	for _, invalid := range [][]byte{{}, {0x62, 0x61}, {0xa1, 0x01, 0x01}, {0x5f}, {0x01, 0x02}} {
+		if _, err := CBOR.Unmarshal(invalid); err == nil {
+			t.Errorf("Unmarshal of %x should fail", invalid)
+		}
+	}
+}
+
+func TestMatchEncoded(t *testing.T) {
+	raw, _ := CBOR.Marshal(note("https://example.com/objects/1", "hello"))
+	if _, ok := MatchEncoded(Filter{Type: pub.ActivityVocabularyTypes{pub.NoteType}, Text: []string{"hello"}}, CBOR, raw); !ok {
+		t.Errorf("MatchEncoded should match the CBOR document")
+	}
+	if _, ok := MatchEncoded(Filter{Type: pub.ActivityVocabularyTypes{pub.CreateType}}, CBOR, raw); ok {
+		t.Errorf("MatchEncoded should check the type of the CBOR document")
+	}
+}
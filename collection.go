@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// CollectionDocument is the canonical format in which backends persist collections.
+//
+// The members of the collection are stored only as an ordered list of IRIs, the objects themselves
+// being saved separately. The same document is used both when a collection is created and when
+// it's loaded, so backends don't need to guess the format of what they read back.
+//
+// Its JSON representation looks like this:
+//
+//	{
+//	  "id": "https://example.com/actors/jdoe/outbox",
+//	  "type": "OrderedCollection",
+//	  "totalItems": 2,
+//	  "orderedItems": ["https://example.com/activities/2", "https://example.com/activities/1"],
+//	  "first": "https://example.com/actors/jdoe/outbox?maxItems=100"
+//	}
+type CollectionDocument struct {
+	ID         pub.IRI                    `json:"id,omitempty"`
+	Type       pub.ActivityVocabularyType `json:"type"`
+	TotalItems uint                       `json:"totalItems"`
+	Items      []pub.IRI                  `json:"orderedItems"`
+	First      pub.IRI                    `json:"first,omitempty"`
+	Last       pub.IRI                    `json:"last,omitempty"`
+}
+
+// MarshalCollection encodes "col" to the canonical CollectionDocument format.
+// The members of the collection are replaced with their IRIs.
+func MarshalCollection(col pub.CollectionInterface) ([]byte, error) {
+	if pub.IsNil(col) {
+		return nil, fmt.Errorf("unable to marshal nil collection")
+	}
+	return json.Marshal(DocumentOf(col))
+}
+
+// DocumentOf returns the CollectionDocument of the non nil "col" collection, for the backends
+// that keep the documents themselves, see MarshalCollection.
+func DocumentOf(col pub.CollectionInterface) *CollectionDocument {
+	doc := &CollectionDocument{
+		ID:    col.GetLink(),
+		Type:  col.GetType(),
+		Items: make([]pub.IRI, 0),
+	}
+	for _, it := range col.Collection() {
+		if pub.IsNil(it) || len(it.GetLink()) == 0 {
+			continue
+		}
+		doc.Items = append(doc.Items, it.GetLink())
+	}
+	doc.TotalItems = uint(len(doc.Items))
+	switch doc.Type {
+	case pub.OrderedCollectionType:
+		pub.OnOrderedCollection(col, func(c *pub.OrderedCollection) error {
+			doc.First = linkOf(c.First)
+			doc.Last = linkOf(c.Last)
+			return nil
+		})
+	case pub.CollectionType:
+		pub.OnCollection(col, func(c *pub.Collection) error {
+			doc.First = linkOf(c.First)
+			doc.Last = linkOf(c.Last)
+			return nil
+		})
+	default:
+		// Everything that is not explicitly an unordered Collection gets stored
+		// as an OrderedCollection, which preserves the order in which items have been added.
+		doc.Type = pub.OrderedCollectionType
+	}
+	return doc
+}
+
+// UnmarshalCollection decodes "data" from the canonical CollectionDocument format to an
+// OrderedCollection, or Collection, with the members represented as IRIs.
+//
+// For compatibility with databases created before the canonical format was introduced, a plain
+// JSON array of IRIs is also accepted, in which case the ID of the returned collection is empty.
+func UnmarshalCollection(data []byte) (pub.CollectionInterface, error) {
+	doc := CollectionDocument{}
+	if data = bytes.TrimSpace(data); len(data) > 0 && data[0] == '[' {
+		if err := json.Unmarshal(data, &doc.Items); err != nil {
+			return nil, err
+		}
+		doc.TotalItems = uint(len(doc.Items))
+	} else if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return doc.Collection(), nil
+}
+
+// Collection returns the collection stored in "doc", with the members represented as IRIs.
+func (doc CollectionDocument) Collection() pub.CollectionInterface {
+	items := make(pub.ItemCollection, 0, len(doc.Items))
+	for _, iri := range doc.Items {
+		items = append(items, iri)
+	}
+	if doc.Type == pub.CollectionType {
+		return &pub.Collection{
+			ID:         doc.ID,
+			Type:       doc.Type,
+			TotalItems: doc.TotalItems,
+			Items:      items,
+			First:      itemOf(doc.First),
+			Last:       itemOf(doc.Last),
+		}
+	}
+	if len(doc.Type) == 0 {
+		doc.Type = pub.OrderedCollectionType
+	}
+	return &pub.OrderedCollection{
+		ID:           doc.ID,
+		Type:         doc.Type,
+		TotalItems:   doc.TotalItems,
+		OrderedItems: items,
+		First:        itemOf(doc.First),
+		Last:         itemOf(doc.Last),
+	}
+}
+
+func linkOf(it pub.Item) pub.IRI {
+	if pub.IsNil(it) {
+		return ""
+	}
+	return it.GetLink()
+}
+
+func itemOf(iri pub.IRI) pub.Item {
+	if len(iri) == 0 {
+		return nil
+	}
+	return iri
+}
+
+// CollectionsOf returns the IRIs of the collections referenced by the "it" item: the inbox, outbox,
+// followers, following and liked collections of actors, and the replies, likes and shares of objects.
+func CollectionsOf(it pub.Item) pub.IRIs {
+	if pub.IsNil(it) {
+		return nil
+	}
+	props := make([]pub.Item, 0, 8)
+	if pub.ActorTypes.Contains(it.GetType()) {
+		pub.OnActor(it, func(a *pub.Actor) error {
+			props = append(props, a.Inbox, a.Outbox, a.Followers, a.Following, a.Liked)
+			return nil
+		})
+	}
+	if pub.IsObject(it) {
+		pub.OnObject(it, func(o *pub.Object) error {
+			props = append(props, o.Replies, o.Likes, o.Shares)
+			return nil
+		})
+	}
+	iris := make(pub.IRIs, 0, len(props))
+	for _, prop := range props {
+		if !pub.IsNil(prop) && len(prop.GetLink()) > 0 {
+			iris = append(iris, prop.GetLink())
+		}
+	}
+	return iris
+}
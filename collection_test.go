@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+func TestMarshalCollection(t *testing.T) {
+	col := pub.OrderedCollectionNew("https://example.com/actors/jdoe/outbox")
+	col.OrderedItems = pub.ItemCollection{
+		pub.IRI("https://example.com/activities/2"),
+		&pub.Activity{ID: "https://example.com/activities/1", Type: pub.CreateType},
+	}
+	col.TotalItems = 666
+	col.First = pub.IRI("https://example.com/actors/jdoe/outbox?maxItems=100")
+
+	data, err := MarshalCollection(col)
+	if err != nil {
+		t.Fatalf("MarshalCollection returned error: %s", err)
+	}
+	want := `{"id":"https://example.com/actors/jdoe/outbox","type":"OrderedCollection","totalItems":2,` +
+		`"orderedItems":["https://example.com/activities/2","https://example.com/activities/1"],` +
+		`"first":"https://example.com/actors/jdoe/outbox?maxItems=100"}`
+	if string(data) != want {
+		t.Errorf("MarshalCollection\n got: %s\nwant: %s", data, want)
+	}
+
+	if _, err := MarshalCollection(nil); err == nil {
+		t.Errorf("MarshalCollection should fail for nil collections")
+	}
+}
+
+func TestUnmarshalCollection(t *testing.T) {
+	t.Run("round trip", func(t *testing.T) {
+		in := &pub.Collection{
+			ID:    "https://example.com/actors/jdoe/followers",
+			Type:  pub.CollectionType,
+			Items: pub.ItemCollection{pub.IRI("https://example.com/actors/alice"), pub.IRI("https://example.com/actors/bob")},
+		}
+		data, err := MarshalCollection(in)
+		if err != nil {
+			t.Fatalf("MarshalCollection returned error: %s", err)
+		}
+		out, err := UnmarshalCollection(data)
+		if err != nil {
+			t.Fatalf("UnmarshalCollection returned error: %s", err)
+		}
+		c, ok := out.(*pub.Collection)
+		if !ok {
+			t.Fatalf("UnmarshalCollection returned %T, expected %T", out, in)
+		}
+		if c.ID != in.ID || c.TotalItems != 2 || !c.Items.Equals(in.Items) {
+			t.Errorf("UnmarshalCollection returned %#v, expected %#v", c, in)
+		}
+	})
+	t.Run("legacy IRI list", func(t *testing.T) {
+		out, err := UnmarshalCollection([]byte(` ["https://example.com/1", "https://example.com/2"]`))
+		if err != nil {
+			t.Fatalf("UnmarshalCollection returned error: %s", err)
+		}
+		c, ok := out.(*pub.OrderedCollection)
+		if !ok {
+			t.Fatalf("UnmarshalCollection returned %T, expected %T", out, c)
+		}
+		if c.TotalItems != 2 || len(c.OrderedItems) != 2 || c.OrderedItems[1].GetLink() != "https://example.com/2" {
+			t.Errorf("UnmarshalCollection returned invalid collection %#v", c)
+		}
+	})
+	t.Run("invalid", func(t *testing.T) {
+		if _, err := UnmarshalCollection([]byte(`{"id":`)); err == nil {
+			t.Errorf("UnmarshalCollection should fail for invalid documents")
+		}
+	})
+}
+
+func TestMarshalCollection_Empty(t *testing.T) {
+	data, err := MarshalCollection(&pub.OrderedCollection{})
+	if err != nil {
+		t.Fatalf("MarshalCollection returned error: %s", err)
+	}
+	if want := `{"type":"OrderedCollection","totalItems":0,"orderedItems":[]}`; string(data) != want {
+		t.Errorf("MarshalCollection\n got: %s\nwant: %s", data, want)
+	}
+}
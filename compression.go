@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sync"
+
+	pub "github.com/go-ap/activitypub"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression is the algorithm used for compressing the encoded documents, see Compress.
+type Compression string
+
+const (
+	NoCompression   Compression = "none"
+	GzipCompression Compression = "gzip"
+	ZstdCompression Compression = "zstd"
+)
+
+// gzipMagic and zstdMagic are the headers of the gzip and zstd streams, which allow the documents stored
+// before enabling the compression, or with another algorithm, to be decoded.
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// Compress returns a Codec that compresses the documents of "c" with the "comp" algorithm.
+// The documents that are not compressed, like the ones stored before enabling the compression, are decoded as they are,
+// and the ones compressed with another algorithm are decompressed with it.
+func Compress(c Codec, comp Compression) (Codec, error) {
+	switch comp {
+	case NoCompression, "":
+		return c, nil
+	case GzipCompression:
+		return gzipCodec{Codec: c}, nil
+	case ZstdCompression:
+		return zstdCodec{Codec: c}, nil
+	}
+	return nil, fmt.Errorf("%w: unsupported compression %q", ErrNotValid, comp)
+}
+
+type gzipCodec struct {
+	Codec
+}
+
+var gzipWriters = sync.Pool{
+	New: func() interface{} {
+		return gzip.NewWriter(nil)
+	},
+}
+
+func (g gzipCodec) Marshal(it pub.Item) ([]byte, error) {
+	raw, err := g.Codec.Marshal(it)
+	if err != nil {
+		return nil, err
+	}
+	buf := bytes.Buffer{}
+	w := gzipWriters.Get().(*gzip.Writer)
+	defer gzipWriters.Put(w)
+	w.Reset(&buf)
+	if _, err := w.Write(raw); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decompress returns the "raw" document decompressed with the algorithm identified by its header,
+// or as it is, if it's not compressed.
+func decompress(raw []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(raw, gzipMagic):
+		r, err := gzip.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	case bytes.HasPrefix(raw, zstdMagic):
+		d, err := zstdDecoder()
+		if err != nil {
+			return nil, err
+		}
+		return d.DecodeAll(raw, nil)
+	}
+	return raw, nil
+}
+
+func (g gzipCodec) Unmarshal(raw []byte) (pub.Item, error) {
+	doc, err := decompress(raw)
+	if err != nil {
+		return nil, err
+	}
+	return g.Codec.Unmarshal(doc)
+}
+
+// ToJSON decompresses the "raw" document, and converts it to JSON using the compressed codec.
+func (g gzipCodec) ToJSON(raw []byte) ([]byte, error) {
+	doc, err := decompress(raw)
+	if err != nil {
+		return nil, err
+	}
+	return RawJSON(g.Codec, doc)
+}
+
+type zstdCodec struct {
+	Codec
+}
+
+// zstdEncoder and zstdDecoder return the zstd encoder and decoder shared by the codecs, which are
+// created the first time they're needed, as they allocate their buffers up front.
+var (
+	zstdEncoder = sync.OnceValues(func() (*zstd.Encoder, error) {
+		return zstd.NewWriter(nil)
+	})
+	zstdDecoder = sync.OnceValues(func() (*zstd.Decoder, error) {
+		return zstd.NewReader(nil)
+	})
+)
+
+func (z zstdCodec) Marshal(it pub.Item) ([]byte, error) {
+	raw, err := z.Codec.Marshal(it)
+	if err != nil {
+		return nil, err
+	}
+	e, err := zstdEncoder()
+	if err != nil {
+		return nil, err
+	}
+	return e.EncodeAll(raw, nil), nil
+}
+
+func (z zstdCodec) Unmarshal(raw []byte) (pub.Item, error) {
+	doc, err := decompress(raw)
+	if err != nil {
+		return nil, err
+	}
+	return z.Codec.Unmarshal(doc)
+}
+
+// ToJSON decompresses the "raw" document, and converts it to JSON using the compressed codec.
+func (z zstdCodec) ToJSON(raw []byte) ([]byte, error) {
+	doc, err := decompress(raw)
+	if err != nil {
+		return nil, err
+	}
+	return RawJSON(z.Codec, doc)
+}
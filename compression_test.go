@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+func TestCompress(t *testing.T) {
+	ob := note("https://example.com/objects/1", strings.Repeat("hello world ", 100))
+	for _, tc := range []struct {
+		inner Codec
+		comp  Compression
+	}{{JSON, GzipCompression}, {CBOR, GzipCompression}, {JSON, ZstdCompression}, {CBOR, ZstdCompression}} {
+		inner := tc.inner
+		c, err := Compress(inner, tc.comp)
+		if err != nil {
+			t.Fatalf("Compress returned error: %s", err)
+		}
+		raw, err := c.Marshal(ob)
+		if err != nil {
+			t.Fatalf("Marshal returned error: %s", err)
+		}
+		uncompressed, _ := inner.Marshal(ob)
+		if len(raw)*5 > len(uncompressed) {
+			t.Errorf("the compressed document has %d bytes, from %d", len(raw), len(uncompressed))
+		}
+		for _, doc := range [][]byte{raw, uncompressed} {
+			it, err := c.Unmarshal(doc)
+			if err != nil {
+				t.Fatalf("Unmarshal returned error: %s", err)
+			}
+			if contentOf(it) != contentOf(ob) {
+				t.Errorf("Unmarshal returned %q, expected %q", contentOf(it), contentOf(ob))
+			}
+		}
+		json, err := RawJSON(c, raw)
+		if err != nil || !bytes.HasPrefix(json, []byte("{")) || RawType(json) != pub.NoteType {
+			t.Errorf("RawJSON returned %s, %v, expected the JSON document", json, err)
+		}
+	}
+
+	if c, _ := Compress(JSON, NoCompression); c != JSON {
+		t.Errorf("Compress without compression should return the codec unchanged")
+	}
+	gz, _ := Compress(JSON, GzipCompression)
+	zs, _ := Compress(JSON, ZstdCompression)
+	raw, _ := gz.Marshal(ob)
+	if it, err := zs.Unmarshal(raw); err != nil || contentOf(it) != contentOf(ob) {
+		t.Errorf("Unmarshal of a gzip document with the zstd codec returned %v, %v", it, err)
+	}
+	if _, err := Compress(JSON, "lz4"); !errors.Is(err, ErrNotValid) {
+		t.Errorf("Compress with an unsupported algorithm returned %v, expected %s", err, ErrNotValid)
+	}
+}
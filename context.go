@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// ContextStore is a Store that can abort its operations when the context.Context they receive
+// is cancelled or reaches its deadline.
+type ContextStore interface {
+	ContextReadStore
+	ContextWriteStore
+}
+
+// ContextReadStore is the context aware counterpart of ReadStore.
+type ContextReadStore interface {
+	// LoadContext returns an Item or an ItemCollection from an IRI
+	LoadContext(context.Context, pub.IRI) (pub.Item, error)
+}
+
+// ContextWriteStore is the context aware counterpart of WriteStore.
+type ContextWriteStore interface {
+	// SaveContext saves the incoming ActivityStreams Object, and returns it together with any properties
+	// populated by the method's side effects.
+	SaveContext(context.Context, pub.Item) (pub.Item, error)
+	// DeleteContext deletes completely from storage the ActivityStreams Object
+	DeleteContext(context.Context, pub.Item) error
+}
+
+// ContextCollectionStore is the context aware counterpart of CollectionStore.
+type ContextCollectionStore interface {
+	// CreateContext creates the "col" collection.
+	CreateContext(ctx context.Context, col pub.CollectionInterface) (pub.CollectionInterface, error)
+	// AddToContext adds "it" element to the "col" collection.
+	AddToContext(ctx context.Context, col pub.IRI, it pub.Item) error
+	// RemoveFromContext removes "it" item from "col" collection
+	RemoveFromContext(ctx context.Context, col pub.IRI, it pub.Item) error
+}
+
+// ContextOrderedCollectionStore is the context aware counterpart of OrderedCollectionStore.
+// When the context is done before all the members have been read, the members read until then
+// are returned, with an ErrPartialResult error, if the filter opts in, see FilterablePartial.
+type ContextOrderedCollectionStore interface {
+	LoadCollectionContext(ctx context.Context, iri pub.IRI, f Filterable) (pub.ItemCollection, string, error)
+}
+
+// ContextIterateStore is the context aware counterpart of IterateStore. When the context is done
+// the iteration stops, and it returns an ErrPartialResult error, if the filter opts in, see FilterablePartial.
+type ContextIterateStore interface {
+	EachContext(ctx context.Context, f Filterable, fn func(pub.Item) error) error
+}
+
+// The following functions call the context aware method of the store when it's available,
+// otherwise they check that the context is still valid before calling the plain method.
+
+// LoadContext loads "iri" from the "s" store, see ContextReadStore.
+func LoadContext(ctx context.Context, s ReadStore, iri pub.IRI) (pub.Item, error) {
+	if cs, ok := s.(ContextReadStore); ok {
+		return cs.LoadContext(ctx, iri)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.Load(iri)
+}
+
+// SaveContext saves "it" to the "s" store, see ContextWriteStore.
+func SaveContext(ctx context.Context, s WriteStore, it pub.Item) (pub.Item, error) {
+	if cs, ok := s.(ContextWriteStore); ok {
+		return cs.SaveContext(ctx, it)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.Save(it)
+}
+
+// DeleteContext deletes "it" from the "s" store, see ContextWriteStore.
+func DeleteContext(ctx context.Context, s WriteStore, it pub.Item) error {
+	if cs, ok := s.(ContextWriteStore); ok {
+		return cs.DeleteContext(ctx, it)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Delete(it)
+}
+
+// CreateContext creates the "col" collection in the "s" store, which needs to implement
+// either ContextCollectionStore or CollectionStore.
+func CreateContext(ctx context.Context, s interface{}, col pub.CollectionInterface) (pub.CollectionInterface, error) {
+	if cs, ok := s.(ContextCollectionStore); ok {
+		return cs.CreateContext(ctx, col)
+	}
+	cs, ok := s.(CollectionStore)
+	if !ok {
+		return nil, fmt.Errorf("storage %T is not a CollectionStore", s)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return cs.Create(col)
+}
+
+// AddToContext adds "it" to the "col" collection in the "s" store, which needs to implement
+// either ContextCollectionStore or CollectionStore.
+func AddToContext(ctx context.Context, s interface{}, col pub.IRI, it pub.Item) error {
+	if cs, ok := s.(ContextCollectionStore); ok {
+		return cs.AddToContext(ctx, col, it)
+	}
+	cs, ok := s.(CollectionStore)
+	if !ok {
+		return fmt.Errorf("storage %T is not a CollectionStore", s)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return cs.AddTo(col, it)
+}
+
+// RemoveFromContext removes "it" from the "col" collection in the "s" store, which needs to implement
+// either ContextCollectionStore or CollectionStore.
+func RemoveFromContext(ctx context.Context, s interface{}, col pub.IRI, it pub.Item) error {
+	if cs, ok := s.(ContextCollectionStore); ok {
+		return cs.RemoveFromContext(ctx, col, it)
+	}
+	cs, ok := s.(CollectionStore)
+	if !ok {
+		return fmt.Errorf("storage %T is not a CollectionStore", s)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return cs.RemoveFrom(col, it)
+}
+
+// LoadCollectionContext returns the members of the "iri" collection in the "s" store,
+// see ContextOrderedCollectionStore. For the other stores the context is checked only before loading
+// the collection, so the result is never partial.
+func LoadCollectionContext(ctx context.Context, s ReadStore, iri pub.IRI, f Filterable) (pub.ItemCollection, string, error) {
+	if cs, ok := s.(ContextOrderedCollectionStore); ok {
+		return cs.LoadCollectionContext(ctx, iri, f)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, "", err
+	}
+	return LoadCollection(s, iri, f)
+}
+
+// EachContext calls "fn" for every item matching "f" in the "s" store, see ContextIterateStore.
+// For the other stores the context is checked before each call of "fn".
+func EachContext(ctx context.Context, s ReadStore, f Filterable, fn func(pub.Item) error) error {
+	if cs, ok := s.(ContextIterateStore); ok {
+		return cs.EachContext(ctx, f, fn)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return Each(s, f, func(it pub.Item) error {
+		if err := ctx.Err(); err != nil {
+			return Interrupted(f, err)
+		}
+		return fn(it)
+	})
+}
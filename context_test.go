@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+type ctxStore struct {
+	*mapStore
+	calls int
+}
+
+func (c *ctxStore) LoadContext(ctx context.Context, iri pub.IRI) (pub.Item, error) {
+	c.calls++
+	return c.Load(iri)
+}
+
+func (c *ctxStore) SaveContext(ctx context.Context, it pub.Item) (pub.Item, error) {
+	c.calls++
+	return c.Save(it)
+}
+
+func (c *ctxStore) DeleteContext(ctx context.Context, it pub.Item) error {
+	c.calls++
+	return c.Delete(it)
+}
+
+func TestLoadContext(t *testing.T) {
+	iri := pub.IRI("https://example.com/objects/1")
+	s := newMapStore()
+	s.Save(note(iri, "test"))
+
+	if _, err := LoadContext(context.Background(), s, iri); err != nil {
+		t.Errorf("LoadContext returned error: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := LoadContext(ctx, s, iri); !errors.Is(err, context.Canceled) {
+		t.Errorf("LoadContext with cancelled context returned %v, expected %s", err, context.Canceled)
+	}
+	if s.loads != 1 {
+		t.Errorf("the store should not be called with a cancelled context, got %d loads", s.loads)
+	}
+
+	cs := &ctxStore{mapStore: s}
+	if _, err := LoadContext(ctx, cs, iri); err != nil {
+		t.Errorf("LoadContext returned error: %s", err)
+	}
+	if cs.calls != 1 {
+		t.Errorf("expected context aware method to be called")
+	}
+}
+
+func TestSaveDeleteContext(t *testing.T) {
+	iri := pub.IRI("https://example.com/objects/1")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	s := newMapStore()
+	if _, err := SaveContext(ctx, s, note(iri, "test")); !errors.Is(err, context.Canceled) {
+		t.Errorf("SaveContext with cancelled context returned %v, expected %s", err, context.Canceled)
+	}
+	if err := DeleteContext(ctx, s, iri); !errors.Is(err, context.Canceled) {
+		t.Errorf("DeleteContext with cancelled context returned %v, expected %s", err, context.Canceled)
+	}
+
+	cs := &ctxStore{mapStore: s}
+	if _, err := SaveContext(ctx, cs, note(iri, "test")); err != nil {
+		t.Errorf("SaveContext returned error: %s", err)
+	}
+	if err := DeleteContext(ctx, cs, iri); err != nil {
+		t.Errorf("DeleteContext returned error: %s", err)
+	}
+	if cs.calls != 2 {
+		t.Errorf("expected context aware methods to be called, got %d calls", cs.calls)
+	}
+}
+
+func TestCollectionContext(t *testing.T) {
+	s := newMapStore()
+	if err := AddToContext(context.Background(), s, "https://example.com/inbox", pub.IRI("https://example.com/1")); err == nil {
+		t.Errorf("AddToContext should fail for stores that are not a CollectionStore")
+	}
+}
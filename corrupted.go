@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// CorruptedItemError is returned for a stored item that can't be decoded. It wraps ErrCorrupted.
+type CorruptedItemError struct {
+	// IRI is the IRI of the item, if the backend knows it.
+	IRI pub.IRI
+	// Path locates the corrupted data in the backend, eg: the name of a file.
+	Path string
+	// Err is the decoding error.
+	Err error
+}
+
+func (e *CorruptedItemError) Error() string {
+	name := e.Path
+	if len(e.IRI) > 0 {
+		name = e.IRI.String()
+	}
+	return fmt.Sprintf("unable to decode %s: %s", name, e.Err)
+}
+
+// Is reports ErrCorrupted as the kind of the error.
+func (e *CorruptedItemError) Is(target error) bool {
+	return target == ErrCorrupted
+}
+
+// Unwrap returns the decoding error.
+func (e *CorruptedItemError) Unwrap() error {
+	return e.Err
+}
+
+// CorruptionError is returned by the backends reporting the corrupted items, for the loads and iterations
+// which skipped some. The items that could be decoded are still returned, or iterated, together with it.
+// It wraps ErrCorrupted.
+type CorruptionError struct {
+	Items []*CorruptedItemError
+}
+
+func (e *CorruptionError) Error() string {
+	errs := make([]string, 0, len(e.Items))
+	for _, it := range e.Items {
+		errs = append(errs, it.Error())
+	}
+	return fmt.Sprintf("skipped %d corrupted items: %s", len(e.Items), strings.Join(errs, "; "))
+}
+
+// Is reports ErrCorrupted as the kind of the error.
+func (e *CorruptionError) Is(target error) bool {
+	return target == ErrCorrupted
+}
+
+// Add appends "err" to the corrupted items.
+func (e *CorruptionError) Add(err *CorruptedItemError) {
+	e.Items = append(e.Items, err)
+}
+
+// Err returns "e", if it has any corrupted items, or else nil.
+func (e *CorruptionError) Err() error {
+	if e == nil || len(e.Items) == 0 {
+		return nil
+	}
+	return e
+}
+
+// RepairFn is the type of the callbacks the backends run for each corrupted item they find, eg: for
+// moving it out of the way, into a quarantine.
+type RepairFn func(err *CorruptedItemError) error
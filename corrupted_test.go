@@ -0,0 +1,27 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestCorruptionError(t *testing.T) {
+	errs := &CorruptionError{}
+	if errs.Err() != nil {
+		t.Errorf("Err should be nil without corrupted items")
+	}
+	decoding := fmt.Errorf("unexpected end of input")
+	errs.Add(&CorruptedItemError{IRI: "https://example.com/1", Err: decoding})
+	errs.Add(&CorruptedItemError{Path: "/tmp/object.json", Err: decoding})
+
+	err := errs.Err()
+	if !errors.Is(err, ErrCorrupted) || !errors.Is(errs.Items[0], decoding) {
+		t.Errorf("CorruptionError should match ErrCorrupted, and its items their decoding errors")
+	}
+	expected := "skipped 2 corrupted items: unable to decode https://example.com/1: unexpected end of input; " +
+		"unable to decode /tmp/object.json: unexpected end of input"
+	if err.Error() != expected {
+		t.Errorf("Error returned %q, expected %q", err.Error(), expected)
+	}
+}
@@ -0,0 +1,26 @@
+package storage
+
+import (
+	pub "github.com/go-ap/activitypub"
+)
+
+// CountStore can count the stored items matching a filter, which allows populating the totalItems
+// of collections without loading their items.
+type CountStore interface {
+	// Count returns the number of items matching "f", with the same semantics as IterateStore.Each.
+	Count(f Filterable) (uint, error)
+}
+
+// Count returns the number of items matching "f" in the "s" store. For stores that are not
+// CountStores, it goes through the matching items using Each.
+func Count(s ReadStore, f Filterable) (uint, error) {
+	if cs, ok := s.(CountStore); ok {
+		return cs.Count(f)
+	}
+	count := uint(0)
+	err := Each(s, f, func(pub.Item) error {
+		count++
+		return nil
+	})
+	return count, err
+}
@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+type countStore struct {
+	*mapStore
+}
+
+func (c countStore) Count(Filterable) (uint, error) {
+	return 42, nil
+}
+
+func TestCount(t *testing.T) {
+	s := newMapStore()
+	outbox := pub.IRI("https://example.com/actors/jdoe/outbox")
+	col := pub.OrderedCollectionNew(outbox)
+	col.OrderedItems = pub.ItemCollection{
+		note("https://example.com/objects/1", "hello"),
+		note("https://example.com/objects/2", "goodbye"),
+	}
+	s.Save(col)
+
+	tests := []struct {
+		name string
+		s    ReadStore
+		f    Filterable
+		want uint
+	}{
+		{name: "collection", s: s, f: outbox, want: 2},
+		{name: "filter", s: s, f: Filter{IRI: outbox, Text: []string{"bye"}}, want: 1},
+		{name: "count store", s: countStore{s}, f: outbox, want: 42},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Count(tt.s, tt.f)
+			if err != nil {
+				t.Fatalf("Count returned error: %s", err)
+			}
+			if got != tt.want {
+				t.Errorf("Count returned %d, expected %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	pub "github.com/go-ap/activitypub"
+)
+
+// CounterStore maintains named integer counters for stored objects, separately from the objects themselves.
+// It allows frequently updated values, like the number of likes, shares or replies, to be changed
+// without re-serializing the whole object.
+type CounterStore interface {
+	// IncrementCounter adds "delta" to the "field" counter of the object identified by "iri",
+	// and returns the resulting value.
+	// The increment must be atomic: concurrent calls for the same counter must not lose updates.
+	IncrementCounter(iri pub.IRI, field string, delta int) (int, error)
+	// LoadCounters returns all the counters that have been stored for the object identified by "iri".
+	LoadCounters(iri pub.IRI) (map[string]int, error)
+}
+
+// MergeCounters sets the TotalItems property of the collections of "it" that have a counter with
+// the same name as their JSON-LD property (eg, "likes", "shares", "replies", "followers").
+// Collections which are present only as IRIs are replaced with an OrderedCollection having that IRI.
+//
+// The item is modified in place, so it needs to be passed as a pointer for the change to be visible.
+func MergeCounters(it pub.Item, counters map[string]int) pub.Item {
+	if pub.IsNil(it) || len(counters) == 0 {
+		return it
+	}
+	if pub.ActorTypes.Contains(it.GetType()) {
+		pub.OnActor(it, func(a *pub.Actor) error {
+			a.Inbox = withTotalItems(a.Inbox, counters, "inbox")
+			a.Outbox = withTotalItems(a.Outbox, counters, "outbox")
+			a.Followers = withTotalItems(a.Followers, counters, "followers")
+			a.Following = withTotalItems(a.Following, counters, "following")
+			a.Liked = withTotalItems(a.Liked, counters, "liked")
+			return nil
+		})
+	}
+	pub.OnObject(it, func(o *pub.Object) error {
+		o.Likes = withTotalItems(o.Likes, counters, "likes")
+		o.Shares = withTotalItems(o.Shares, counters, "shares")
+		o.Replies = withTotalItems(o.Replies, counters, "replies")
+		return nil
+	})
+	return it
+}
+
+func withTotalItems(col pub.Item, counters map[string]int, field string) pub.Item {
+	cnt, ok := counters[field]
+	if !ok || pub.IsNil(col) {
+		return col
+	}
+	if cnt < 0 {
+		cnt = 0
+	}
+	if pub.IsIRI(col) {
+		oc := pub.OrderedCollectionNew(col.GetLink())
+		oc.TotalItems = uint(cnt)
+		return oc
+	}
+	switch col.GetType() {
+	case pub.OrderedCollectionType:
+		pub.OnOrderedCollection(col, func(c *pub.OrderedCollection) error {
+			c.TotalItems = uint(cnt)
+			col = c
+			return nil
+		})
+	case pub.CollectionType:
+		pub.OnCollection(col, func(c *pub.Collection) error {
+			c.TotalItems = uint(cnt)
+			col = c
+			return nil
+		})
+	}
+	return col
+}
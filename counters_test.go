@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+func totalItems(t *testing.T, col pub.Item) uint {
+	switch c := col.(type) {
+	case *pub.OrderedCollection:
+		return c.TotalItems
+	case *pub.Collection:
+		return c.TotalItems
+	}
+	t.Fatalf("unable to get totalItems from %T", col)
+	return 0
+}
+
+func TestMergeCounters(t *testing.T) {
+	t.Run("object with IRI collections", func(t *testing.T) {
+		ob := pub.ObjectNew(pub.NoteType)
+		ob.ID = "https://example.com/objects/1"
+		ob.Likes = ob.ID.AddPath("likes")
+		ob.Shares = ob.ID.AddPath("shares")
+
+		MergeCounters(ob, map[string]int{"likes": 3, "replies": 2})
+
+		if !pub.IsObject(ob.Likes) {
+			t.Fatalf("expected likes to be converted to a collection, got %T", ob.Likes)
+		}
+		if ob.Likes.GetLink() != ob.ID.AddPath("likes") {
+			t.Errorf("invalid likes IRI %s", ob.Likes.GetLink())
+		}
+		if total := totalItems(t, ob.Likes); total != 3 {
+			t.Errorf("invalid likes totalItems %d, expected %d", total, 3)
+		}
+		if !pub.IsIRI(ob.Shares) {
+			t.Errorf("expected shares to remain an IRI without a counter, got %T", ob.Shares)
+		}
+		if ob.Replies != nil {
+			t.Errorf("expected missing replies to remain nil, got %T", ob.Replies)
+		}
+	})
+	t.Run("object with collection", func(t *testing.T) {
+		ob := pub.ObjectNew(pub.NoteType)
+		ob.Replies = &pub.Collection{ID: "https://example.com/objects/1/replies", Type: pub.CollectionType}
+
+		MergeCounters(ob, map[string]int{"replies": 5})
+
+		if total := totalItems(t, ob.Replies); total != 5 {
+			t.Errorf("invalid replies totalItems %d, expected %d", total, 5)
+		}
+	})
+	t.Run("actor collections", func(t *testing.T) {
+		act := pub.PersonNew("https://example.com/actors/jdoe")
+		act.Followers = act.ID.AddPath("followers")
+		act.Likes = act.ID.AddPath("likes")
+
+		MergeCounters(act, map[string]int{"followers": 42, "likes": -1})
+
+		if total := totalItems(t, act.Followers); total != 42 {
+			t.Errorf("invalid followers totalItems %d, expected %d", total, 42)
+		}
+		if total := totalItems(t, act.Likes); total != 0 {
+			t.Errorf("invalid likes totalItems %d, expected negative counters to be clamped to %d", total, 0)
+		}
+	})
+}
@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// ReceivedNamespace is the MetadataStore namespace under which ReceivedDeliveries stores the inboxes
+// to which each activity has been delivered.
+const ReceivedNamespace = "received"
+
+// DedupStore records the deliveries of activities to inboxes, so the ones replayed by remote servers
+// can be recognized.
+type DedupStore interface {
+	// MarkReceived records the delivery of "activity" to "inbox", or returns an error wrapping
+	// ErrDuplicate if it's already been recorded.
+	MarkReceived(activity, inbox pub.IRI) error
+	// Received reports if the delivery of "activity" to "inbox" has been recorded.
+	Received(activity, inbox pub.IRI) (bool, error)
+}
+
+// ReceivedDeliveries returns a DedupStore keeping the inboxes each activity has been delivered to
+// in its ReceivedNamespace metadata, in the "m" store. As the metadata of the items are removed
+// together with them, the records of the deleted activities don't accumulate.
+func ReceivedDeliveries(m MetadataStore) DedupStore {
+	return &metadataDedup{m: m}
+}
+
+type metadataDedup struct {
+	m  MetadataStore
+	mu sync.Mutex
+}
+
+func (d *metadataDedup) inboxes(activity pub.IRI) ([]pub.IRI, error) {
+	data, err := d.m.LoadMetadata(activity, ReceivedNamespace)
+	if errors.Is(err, ErrNotFound) || (err == nil && len(data) == 0) {
+		return []pub.IRI{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	inboxes := make([]pub.IRI, 0)
+	if err := json.Unmarshal(data, &inboxes); err != nil {
+		return nil, fmt.Errorf("invalid deliveries of %s: %w", activity, err)
+	}
+	return inboxes, nil
+}
+
+func (d *metadataDedup) Received(activity, inbox pub.IRI) (bool, error) {
+	inboxes, err := d.inboxes(activity)
+	if err != nil {
+		return false, err
+	}
+	for _, iri := range inboxes {
+		if iri.Equals(inbox, false) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (d *metadataDedup) MarkReceived(activity, inbox pub.IRI) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	inboxes, err := d.inboxes(activity)
+	if err != nil {
+		return err
+	}
+	for _, iri := range inboxes {
+		if iri.Equals(inbox, false) {
+			return fmt.Errorf("%w: %s has already been delivered to %s", ErrDuplicate, activity, inbox)
+		}
+	}
+	data, err := json.Marshal(append(inboxes, inbox))
+	if err != nil {
+		return err
+	}
+	return d.m.SaveMetadata(activity, ReceivedNamespace, data)
+}
+
+// IdempotentStore is a Store which records the deliveries to the inboxes in a DedupStore,
+// so the activities replayed by remote servers don't produce duplicate inbox entries.
+type IdempotentStore struct {
+	Store
+	received DedupStore
+	mu       sync.Mutex
+}
+
+// Idempotent returns an IdempotentStore recording the deliveries to the inboxes of "s" in "received".
+func Idempotent(s Store, received DedupStore) *IdempotentStore {
+	return &IdempotentStore{Store: s, received: received}
+}
+
+// SaveIfAbsent saves the "it" activity, and adds it to the "inbox" collection, unless it's already
+// been delivered there, in which case it returns an error wrapping ErrDuplicate. The underlying store
+// needs to be a CollectionStore. The delivery is recorded only once it's been added to the inbox,
+// so the failed ones can be retried.
+func (i *IdempotentStore) SaveIfAbsent(inbox pub.IRI, it pub.Item) (pub.Item, error) {
+	if pub.IsNil(it) || len(it.GetLink()) == 0 {
+		return it, fmt.Errorf("%w: unable to deliver an activity without an ID", ErrNotValid)
+	}
+	activity := it.GetLink()
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	received, err := i.received.Received(activity, inbox)
+	if err != nil {
+		return it, err
+	}
+	if received {
+		return it, fmt.Errorf("%w: %s has already been delivered to %s", ErrDuplicate, activity, inbox)
+	}
+	saved, err := i.Store.Save(it)
+	if err != nil {
+		return saved, err
+	}
+	if err := asCollectionStore(i.Store).AddTo(inbox, activity); err != nil {
+		return saved, err
+	}
+	return saved, i.received.MarkReceived(activity, inbox)
+}
+
+// Create creates the "col" collection in the underlying store, which needs to be a CollectionStore.
+func (i *IdempotentStore) Create(col pub.CollectionInterface) (pub.CollectionInterface, error) {
+	return asCollectionStore(i.Store).Create(col)
+}
+
+// AddTo adds "it" to the "col" collection in the underlying store, which needs to be a CollectionStore.
+func (i *IdempotentStore) AddTo(col pub.IRI, it pub.Item) error {
+	return asCollectionStore(i.Store).AddTo(col, it)
+}
+
+// RemoveFrom removes "it" from the "col" collection in the underlying store, which needs to be a CollectionStore.
+func (i *IdempotentStore) RemoveFrom(col pub.IRI, it pub.Item) error {
+	return asCollectionStore(i.Store).RemoveFrom(col, it)
+}
@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+func TestIdempotent(t *testing.T) {
+	cs := newCollectionMapStore()
+	s := Idempotent(cs, ReceivedDeliveries(metadataMap{}))
+	jdoe := pub.IRI("https://example.com/actors/jdoe/inbox")
+	alice := pub.IRI("https://example.com/actors/alice/inbox")
+	for _, inbox := range []pub.IRI{jdoe, alice} {
+		s.Create(pub.OrderedCollectionNew(inbox))
+	}
+	create := pub.CreateNew("https://example.org/activities/1", note("https://example.org/objects/1", "hello"))
+
+	if _, err := s.SaveIfAbsent("https://example.com/actors/bob/inbox", create); err == nil {
+		t.Errorf("SaveIfAbsent to a missing inbox should fail")
+	}
+	if _, err := s.SaveIfAbsent(jdoe, create); err != nil {
+		t.Fatalf("SaveIfAbsent returned error: %s", err)
+	}
+	if _, err := s.SaveIfAbsent(jdoe, create); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("SaveIfAbsent of a replayed delivery returned %v, expected %s", err, ErrDuplicate)
+	}
+	if _, err := s.SaveIfAbsent(alice, create); err != nil {
+		t.Errorf("SaveIfAbsent to another inbox returned error: %s", err)
+	}
+	for _, inbox := range []pub.IRI{jdoe, alice} {
+		if members := cs.members[inbox]; len(members) != 1 || members[0] != create.ID {
+			t.Errorf("%s has members %v, expected only %s", inbox, members, create.ID)
+		}
+	}
+	if _, err := s.SaveIfAbsent(jdoe, pub.CreateNew("", nil)); !errors.Is(err, ErrNotValid) {
+		t.Errorf("SaveIfAbsent of an activity without an ID returned %v, expected %s", err, ErrNotValid)
+	}
+}
@@ -0,0 +1,27 @@
+package storage
+
+// BulkDeleteStore allows deleting all the items that match a filter, for example during moderation
+// actions like removing all the objects attributed to an actor.
+type BulkDeleteStore interface {
+	// DeleteMatching deletes all the items matching "f", removes them from the collections referencing them,
+	// and returns the number of deleted items.
+	//
+	// By default the items are replaced with Tombstones. If "f" implements FilterablePurge and
+	// opts in, they are removed completely.
+	// Backends should process the items in batches, so a large deletion doesn't require a single
+	// long-running transaction.
+	DeleteMatching(f Filterable) (uint, error)
+}
+
+// FilterablePurge can be implemented by filters used for deleting items, to request removing them
+// completely instead of replacing them with Tombstones.
+type FilterablePurge interface {
+	Filterable
+	Purge() bool
+}
+
+// Purges checks if the "f" filter requests deleted items to be removed completely.
+func Purges(f Filterable) bool {
+	p, ok := f.(FilterablePurge)
+	return ok && p.Purge()
+}
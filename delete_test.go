@@ -0,0 +1,29 @@
+package storage
+
+import (
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+type purgeFilter struct {
+	pub.IRI
+	purge bool
+}
+
+func (p purgeFilter) Purge() bool {
+	return p.purge
+}
+
+func TestPurges(t *testing.T) {
+	iri := pub.IRI("https://example.com/objects")
+	if Purges(iri) {
+		t.Errorf("plain IRI filters should not purge the deleted items")
+	}
+	if Purges(purgeFilter{IRI: iri}) {
+		t.Errorf("filter should not purge the deleted items when not opting in")
+	}
+	if !Purges(purgeFilter{IRI: iri, purge: true}) {
+		t.Errorf("filter should purge the deleted items when opting in")
+	}
+}
@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// Delivery is an activity waiting to be delivered to a remote inbox.
+type Delivery struct {
+	// ID identifies the delivery in the queue.
+	ID       string
+	Activity pub.IRI
+	Inbox    pub.IRI
+	// Attempts is the number of failed delivery attempts.
+	Attempts int
+	// LastError is the reason of the last failed attempt.
+	LastError string
+	// NextAttempt is the moment after which the delivery can be dequeued.
+	NextAttempt time.Time
+}
+
+// DeliveryQueueStore persists the deliveries of activities to remote inboxes, so they survive restarts,
+// together with their retry state.
+type DeliveryQueueStore interface {
+	// Enqueue adds the delivery of "activity" to "inbox" to the queue. Enqueuing a delivery that is
+	// already pending returns the existing one.
+	Enqueue(activity, inbox pub.IRI) (Delivery, error)
+	// Dequeue returns up to "max" of the deliveries that are due, oldest first, and leases them
+	// for the "lease" duration. Deliveries that are not marked as delivered, or failed, before the
+	// lease expires can be dequeued again.
+	Dequeue(max int, lease time.Duration) ([]Delivery, error)
+	// MarkDelivered removes the "id" delivery from the queue.
+	MarkDelivered(id string) error
+	// MarkFailed records a failed attempt of the "id" delivery, which can be dequeued again
+	// after "retry". A zero "retry" means giving up on the delivery, which is removed from the queue.
+	MarkFailed(id string, reason error, retry time.Time) error
+}
+
+// Backoff returns how long to wait before retrying a delivery after "attempts" failed attempts.
+// The delay starts at "base" and doubles after each attempt, up to "max".
+func Backoff(attempts int, base, max time.Duration) time.Duration {
+	d := base
+	for i := 1; i < attempts; i++ {
+		if d >= max/2 {
+			return max
+		}
+		d *= 2
+	}
+	if d > max {
+		return max
+	}
+	return d
+}
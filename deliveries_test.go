@@ -0,0 +1,25 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 0, want: time.Minute},
+		{attempts: 1, want: time.Minute},
+		{attempts: 2, want: 2 * time.Minute},
+		{attempts: 4, want: 8 * time.Minute},
+		{attempts: 7, want: time.Hour},
+		{attempts: 1000, want: time.Hour},
+	}
+	for _, tt := range tests {
+		if got := Backoff(tt.attempts, time.Minute, time.Hour); got != tt.want {
+			t.Errorf("Backoff(%d) = %s, expected %s", tt.attempts, got, tt.want)
+		}
+	}
+}
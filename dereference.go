@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// maxDocumentSize is the size limit of the documents fetched by a DereferenceStore.
+const maxDocumentSize = 10 << 20
+
+// FetchOptions control how a DereferenceStore fetches the remote objects.
+type FetchOptions struct {
+	// Client does the requests, using http.DefaultClient if not set.
+	Client *http.Client
+	// Sign, if set, gets called for each request before it's sent, eg: for adding an HTTP signature.
+	Sign func(*http.Request) error
+	// MaxAge is how long the fetched objects are cached, when the responses don't specify it.
+	MaxAge time.Duration
+}
+
+// DereferenceStore is a ReadStore that fetches over HTTP the objects missing from the local storage,
+// and keeps them in a CacheStore.
+// The fetched documents are accepted only when their ID is the requested IRI, or the URL to which
+// the request has been redirected.
+type DereferenceStore struct {
+	ReadStore
+	cache CacheStore
+	o     FetchOptions
+}
+
+// Dereference returns a DereferenceStore loading the objects from "s", or else from "cache", when they're
+// fresh, or else from the remote servers. The "cache" can be nil, in which case the objects are always fetched.
+func Dereference(s ReadStore, cache CacheStore, o FetchOptions) *DereferenceStore {
+	if o.Client == nil {
+		o.Client = http.DefaultClient
+	}
+	return &DereferenceStore{ReadStore: s, cache: cache, o: o}
+}
+
+// Load returns the "iri" item from the local storage, or else the remote object it identifies.
+// Stale cached objects are revalidated using their ETag.
+func (d *DereferenceStore) Load(iri pub.IRI) (pub.Item, error) {
+	it, err := d.ReadStore.Load(iri)
+	if err == nil || !errors.Is(err, ErrNotFound) {
+		return it, err
+	}
+	var stale pub.Item
+	var f Freshness
+	if d.cache != nil {
+		cached, cf, err := d.cache.LoadRemote(iri)
+		if err == nil && cf.Fresh(time.Now()) {
+			return cached, nil
+		}
+		if err == nil {
+			stale, f = cached, cf
+		}
+	}
+	return d.fetch(iri, stale, f)
+}
+
+// fetch requests the "iri" object, and caches it. When the "stale" cached version is still
+// valid, according to the ETag of its freshness "f", it's returned.
+func (d *DereferenceStore) fetch(iri pub.IRI, stale pub.Item, f Freshness) (pub.Item, error) {
+	u, err := iri.URL()
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || len(u.Host) == 0 {
+		return nil, fmt.Errorf("%w IRI %q: not an HTTP URL", ErrNotValid, iri)
+	}
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", `application/activity+json, application/ld+json; profile="https://www.w3.org/ns/activitystreams"`)
+	if !pub.IsNil(stale) && len(f.ETag) > 0 {
+		req.Header.Set("If-None-Match", f.ETag)
+	}
+	if d.o.Sign != nil {
+		if err := d.o.Sign(req); err != nil {
+			return nil, fmt.Errorf("unable to sign the request for %s: %w", iri, err)
+		}
+	}
+	res, err := d.o.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch %s: %w", iri, err)
+	}
+	defer res.Body.Close()
+
+	fetched := time.Now()
+	switch {
+	case res.StatusCode == http.StatusNotModified && !pub.IsNil(stale):
+		// the headers of the response, when present, update the previous ones
+		revalidated := FreshnessFromHeaders(res.Header, fetched, f.MaxAge)
+		if len(revalidated.ETag) == 0 {
+			revalidated.ETag = f.ETag
+		}
+		return stale, d.save(stale, revalidated)
+	case res.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("unable to fetch %s: %w", iri, ErrNotFound)
+	case res.StatusCode == http.StatusGone:
+		return nil, fmt.Errorf("unable to fetch %s: %w", iri, ErrGone)
+	case res.StatusCode < 200 || res.StatusCode >= 300:
+		return nil, fmt.Errorf("unable to fetch %s: %s", iri, res.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxDocumentSize))
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch %s: %w", iri, err)
+	}
+	it, err := pub.UnmarshalJSON(data)
+	if err != nil || pub.IsNil(it) {
+		return nil, fmt.Errorf("%w document for %s: %v", ErrNotValid, iri, err)
+	}
+	// a server can only serve the object that has been requested, or the one it redirected to
+	if id := it.GetLink(); id != iri && (res.Request == nil || id != pub.IRI(res.Request.URL.String())) {
+		return nil, fmt.Errorf("%w document for %s: it has the %s ID", ErrNotValid, iri, id)
+	}
+	return it, d.save(it, FreshnessFromHeaders(res.Header, fetched, d.o.MaxAge))
+}
+
+func (d *DereferenceStore) save(it pub.Item, f Freshness) error {
+	if d.cache == nil || f.MaxAge <= 0 {
+		return nil
+	}
+	return d.cache.SaveRemote(it, f)
+}
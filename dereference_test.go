@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// mapCache is a CacheStore keeping the remote objects in memory.
+type mapCache struct {
+	mu    sync.Mutex
+	items map[pub.IRI]pub.Item
+	f     map[pub.IRI]Freshness
+}
+
+func newMapCache() *mapCache {
+	return &mapCache{items: make(map[pub.IRI]pub.Item), f: make(map[pub.IRI]Freshness)}
+}
+
+func (m *mapCache) SaveRemote(it pub.Item, f Freshness) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.items[it.GetLink()], m.f[it.GetLink()] = it, f
+	return nil
+}
+
+func (m *mapCache) LoadRemote(iri pub.IRI) (pub.Item, Freshness, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	it, ok := m.items[iri]
+	if !ok {
+		return nil, Freshness{}, ErrNotFound
+	}
+	return it, m.f[iri], nil
+}
+
+func (m *mapCache) DeleteRemote(iri pub.IRI) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.items, iri)
+	return nil
+}
+
+func (m *mapCache) Expire(time.Time) (uint, error) {
+	return 0, nil
+}
+
+func TestDereferenceStore_Load(t *testing.T) {
+	requests := 0
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("Signature") != "signed" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/objects/1":
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"v1"`)
+			w.Header().Set("Cache-Control", "max-age=60")
+			fmt.Fprintf(w, `{"id":"%s/objects/1","type":"Note","content":"remote"}`, srv.URL)
+		case "/objects/spoofed":
+			fmt.Fprint(w, `{"id":"https://example.com/objects/1","type":"Note"}`)
+		case "/objects/other":
+			fmt.Fprintf(w, `{"id":"%s/objects/1","type":"Note"}`, srv.URL)
+		case "/objects/moved":
+			http.Redirect(w, r, "/objects/1", http.StatusMovedPermanently)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	local := newMapStore()
+	local.Save(note("https://example.com/objects/1", "local"))
+	cache := newMapCache()
+	d := Dereference(local, cache, FetchOptions{
+		Sign: func(r *http.Request) error {
+			r.Header.Set("Signature", "signed")
+			return nil
+		},
+	})
+
+	if it, err := d.Load("https://example.com/objects/1"); err != nil || contentOf(it) != "local" {
+		t.Errorf("Load returned %v, %v, expected the local object", it, err)
+	}
+	remote := pub.IRI(srv.URL + "/objects/1")
+	for i := 0; i < 2; i++ {
+		if it, err := d.Load(remote); err != nil || contentOf(it) != "remote" {
+			t.Fatalf("Load returned %v, %v, expected the remote object", it, err)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("the remote object has been requested %d times, expected it to be cached", requests)
+	}
+
+	cache.f[remote] = Freshness{FetchedAt: time.Now().Add(-time.Hour), ETag: `"v1"`, MaxAge: time.Minute}
+	if it, err := d.Load(remote); err != nil || contentOf(it) != "remote" {
+		t.Errorf("Load returned %v, %v, expected the revalidated object", it, err)
+	}
+	if f := cache.f[remote]; requests != 2 || !f.Fresh(time.Now()) {
+		t.Errorf("the stale object should have been revalidated, got %d requests and %+v", requests, f)
+	}
+
+	if _, err := d.Load(pub.IRI(srv.URL + "/objects/missing")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Load of a missing object returned %v, expected %s", err, ErrNotFound)
+	}
+	if _, err := d.Load(pub.IRI(srv.URL + "/objects/spoofed")); !errors.Is(err, ErrNotValid) {
+		t.Errorf("Load of an object with a foreign ID returned %v, expected %s", err, ErrNotValid)
+	}
+	if _, err := d.Load(pub.IRI(srv.URL + "/objects/other")); !errors.Is(err, ErrNotValid) {
+		t.Errorf("Load of an object with another ID on the same host returned %v, expected %s", err, ErrNotValid)
+	}
+	if it, err := d.Load(pub.IRI(srv.URL + "/objects/moved")); err != nil || it.GetLink() != remote {
+		t.Errorf("Load of a redirected object returned %v, %v, expected %s", it, err, remote)
+	}
+	if _, err := d.Load("urn:example:1"); !errors.Is(err, ErrNotValid) {
+		t.Errorf("Load of a non HTTP IRI returned %v, expected %s", err, ErrNotValid)
+	}
+}
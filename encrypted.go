@@ -0,0 +1,202 @@
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// EncryptedMediaType is the media type of the envelopes in which EncryptedStore saves the encrypted objects.
+const EncryptedMediaType pub.MimeType = "application/vnd.go-ap.encrypted"
+
+// EncryptionKey is an AES key, of 16, 24 or 32 bytes, identified by ID in the envelopes of the
+// objects it encrypts, so it can be replaced without re-encrypting everything at once.
+type EncryptionKey struct {
+	ID  string
+	Key []byte
+}
+
+// EncryptedStore is a Store that encrypts the objects, using AES-GCM, before saving them to the
+// underlying store, and decrypts them when loading them.
+//
+// The encrypted objects are saved as envelopes which keep only their ID and type, with the key ID
+// and the encrypted JSON document as the content. Collections are saved as they are, since their
+// members are only IRIs, and the objects stored before enabling the encryption are loaded as they are.
+// The underlying store can't filter the objects by their contents, so the filters need to be
+// applied after loading them.
+type EncryptedStore struct {
+	Store
+	current string
+	aeads   map[string]cipher.AEAD
+}
+
+// Encrypted returns an EncryptedStore wrapping "s", which encrypts the objects with the "current" key,
+// and can decrypt the objects encrypted with any of the "previous" keys.
+func Encrypted(s Store, current EncryptionKey, previous ...EncryptionKey) (*EncryptedStore, error) {
+	e := &EncryptedStore{Store: s, current: current.ID, aeads: make(map[string]cipher.AEAD)}
+	for _, k := range append([]EncryptionKey{current}, previous...) {
+		if len(k.ID) == 0 || strings.Contains(k.ID, ".") {
+			return nil, fmt.Errorf("%w: invalid encryption key ID %q", ErrNotValid, k.ID)
+		}
+		block, err := aes.NewCipher(k.Key)
+		if err != nil {
+			return nil, fmt.Errorf("%w: encryption key %s: %s", ErrNotValid, k.ID, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		e.aeads[k.ID] = aead
+	}
+	return e, nil
+}
+
+// seal returns the envelope of "it", encrypted with the current key. The IRI of the object is used
+// as additional data, so an envelope can't be moved to another IRI.
+func (e *EncryptedStore) seal(it pub.Item) (pub.Item, error) {
+	raw, err := pub.MarshalJSON(it)
+	if err != nil {
+		return nil, err
+	}
+	aead := e.aeads[e.current]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(raw)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	sealed := aead.Seal(nonce, nonce, raw, []byte(it.GetLink()))
+
+	env := pub.ObjectNew(it.GetType())
+	env.ID = it.GetLink()
+	env.MediaType = EncryptedMediaType
+	env.Content.Set(pub.NilLangRef, pub.Content(e.current+"."+base64.RawStdEncoding.EncodeToString(sealed)))
+	return env, nil
+}
+
+// isEnvelope checks if "it" is an envelope saved by an EncryptedStore.
+func isEnvelope(it pub.Item) bool {
+	is := false
+	if pub.IsObject(it) && !pub.CollectionTypes.Contains(it.GetType()) {
+		pub.OnObject(it, func(o *pub.Object) error {
+			is = o.MediaType == EncryptedMediaType
+			return nil
+		})
+	}
+	return is
+}
+
+// open decrypts "it", if it's an envelope.
+func (e *EncryptedStore) open(it pub.Item) (pub.Item, error) {
+	if !isEnvelope(it) {
+		return it, nil
+	}
+	var content string
+	pub.OnObject(it, func(o *pub.Object) error {
+		content = o.Content.First().Value.String()
+		return nil
+	})
+	keyID, data, ok := strings.Cut(content, ".")
+	if !ok {
+		return nil, fmt.Errorf("%w: invalid envelope of %s", ErrNotValid, it.GetLink())
+	}
+	aead, ok := e.aeads[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown encryption key %s for %s", ErrNotValid, keyID, it.GetLink())
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(data)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("%w: invalid envelope of %s", ErrNotValid, it.GetLink())
+	}
+	raw, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(it.GetLink()))
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to decrypt %s: %s", ErrNotValid, it.GetLink(), err)
+	}
+	return pub.UnmarshalJSON(raw)
+}
+
+// keyOf returns the ID of the key that encrypted the "it" envelope.
+func keyOf(it pub.Item) string {
+	var keyID string
+	pub.OnObject(it, func(o *pub.Object) error {
+		keyID, _, _ = strings.Cut(o.Content.First().Value.String(), ".")
+		return nil
+	})
+	return keyID
+}
+
+// Load loads "iri" from the underlying store, and decrypts it and, for collections, their members.
+func (e *EncryptedStore) Load(iri pub.IRI) (pub.Item, error) {
+	it, err := e.Store.Load(iri)
+	if err != nil || pub.IsNil(it) {
+		return it, err
+	}
+	if pub.CollectionTypes.Contains(it.GetType()) || pub.IsItemCollection(it) {
+		err := pub.OnCollectionIntf(it, func(col pub.CollectionInterface) error {
+			items := col.Collection()
+			for i, member := range items {
+				if items[i], err = e.open(member); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		return it, err
+	}
+	return e.open(it)
+}
+
+// Save encrypts "it" and saves its envelope to the underlying store. Collections are saved unencrypted.
+func (e *EncryptedStore) Save(it pub.Item) (pub.Item, error) {
+	if pub.IsNil(it) || pub.IsIRI(it) || pub.CollectionTypes.Contains(it.GetType()) {
+		return e.Store.Save(it)
+	}
+	env, err := e.seal(it)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := e.Store.Save(env); err != nil {
+		return nil, err
+	}
+	return it, nil
+}
+
+// Reencrypt saves the "iri" object again with the current key, if it's not encrypted with it already,
+// which allows removing the previous keys once all the objects have been re-encrypted.
+func (e *EncryptedStore) Reencrypt(iri pub.IRI) error {
+	stored, err := e.Store.Load(iri)
+	if err != nil {
+		return err
+	}
+	if pub.IsNil(stored) || pub.CollectionTypes.Contains(stored.GetType()) || pub.IsItemCollection(stored) {
+		return nil
+	}
+	if isEnvelope(stored) && keyOf(stored) == e.current {
+		return nil
+	}
+	it, err := e.open(stored)
+	if err != nil {
+		return err
+	}
+	_, err = e.Save(it)
+	return err
+}
+
+// Create creates the "col" collection in the underlying store.
+func (e *EncryptedStore) Create(col pub.CollectionInterface) (pub.CollectionInterface, error) {
+	return asCollectionStore(e.Store).Create(col)
+}
+
+// AddTo adds the IRI of "it" to the "col" collection of the underlying store, so it doesn't
+// receive the unencrypted item.
+func (e *EncryptedStore) AddTo(col pub.IRI, it pub.Item) error {
+	return asCollectionStore(e.Store).AddTo(col, it.GetLink())
+}
+
+// RemoveFrom removes "it" from the "col" collection of the underlying store.
+func (e *EncryptedStore) RemoveFrom(col pub.IRI, it pub.Item) error {
+	return asCollectionStore(e.Store).RemoveFrom(col, it.GetLink())
+}
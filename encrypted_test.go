@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+func testKey32(id string, b byte) EncryptionKey {
+	return EncryptionKey{ID: id, Key: bytes.Repeat([]byte{b}, 32)}
+}
+
+func TestEncrypted(t *testing.T) {
+	if _, err := Encrypted(newMapStore(), EncryptionKey{ID: "k1", Key: []byte("short")}); !errors.Is(err, ErrNotValid) {
+		t.Errorf("Encrypted with an invalid key returned %v, expected %s", err, ErrNotValid)
+	}
+	if _, err := Encrypted(newMapStore(), testKey32("k.1", 1)); !errors.Is(err, ErrNotValid) {
+		t.Errorf("Encrypted with an invalid key ID returned %v, expected %s", err, ErrNotValid)
+	}
+
+	s := newMapStore()
+	e, err := Encrypted(s, testKey32("k1", 1))
+	if err != nil {
+		t.Fatalf("Encrypted returned error: %s", err)
+	}
+	ob := note("https://example.com/objects/1", "a private message")
+	if _, err := e.Save(ob); err != nil {
+		t.Fatalf("Save returned error: %s", err)
+	}
+
+	stored := s.items[ob.ID]
+	raw, _ := pub.MarshalJSON(stored)
+	if bytes.Contains(raw, []byte("private")) || !isEnvelope(stored) {
+		t.Errorf("the underlying store should receive the encrypted envelope, got %s", raw)
+	}
+	if stored.GetLink() != ob.ID || stored.GetType() != ob.Type {
+		t.Errorf("the envelope should keep the ID and the type of the object")
+	}
+	it, err := e.Load(ob.ID)
+	if err != nil {
+		t.Fatalf("Load returned error: %s", err)
+	}
+	if contentOf(it) != "a private message" {
+		t.Errorf("Load returned %q, expected the decrypted object", contentOf(it))
+	}
+
+	// an envelope moved to another IRI can't be decrypted
+	moved := pub.ObjectNew(pub.NoteType)
+	pub.OnObject(stored, func(o *pub.Object) error {
+		*moved = *o
+		return nil
+	})
+	moved.ID = "https://example.com/objects/2"
+	s.Save(moved)
+	if _, err := e.Load(moved.ID); !errors.Is(err, ErrNotValid) {
+		t.Errorf("Load of a moved envelope returned %v, expected %s", err, ErrNotValid)
+	}
+
+	plain := note("https://example.com/objects/3", "stored before encryption")
+	s.Save(plain)
+	if it, err := e.Load(plain.ID); err != nil || contentOf(it) != "stored before encryption" {
+		t.Errorf("Load of an unencrypted object returned %v, %v", it, err)
+	}
+}
+
+func TestEncrypted_rotation(t *testing.T) {
+	s := newMapStore()
+	old, _ := Encrypted(s, testKey32("k1", 1))
+	ob := note("https://example.com/objects/1", "hello")
+	old.Save(ob)
+
+	rotated, err := Encrypted(s, testKey32("k2", 2), testKey32("k1", 1))
+	if err != nil {
+		t.Fatalf("Encrypted returned error: %s", err)
+	}
+	if it, err := rotated.Load(ob.ID); err != nil || contentOf(it) != "hello" {
+		t.Fatalf("Load with the previous key returned %v, %v", it, err)
+	}
+	if err := rotated.Reencrypt(ob.ID); err != nil {
+		t.Fatalf("Reencrypt returned error: %s", err)
+	}
+	if keyOf(s.items[ob.ID]) != "k2" {
+		t.Errorf("Reencrypt should encrypt the object with the current key, got %s", keyOf(s.items[ob.ID]))
+	}
+
+	current, _ := Encrypted(s, testKey32("k2", 2))
+	if it, err := current.Load(ob.ID); err != nil || contentOf(it) != "hello" {
+		t.Errorf("Load after the rotation returned %v, %v", it, err)
+	}
+	if _, err := old.Load(ob.ID); !errors.Is(err, ErrNotValid) {
+		t.Errorf("Load without the current key returned %v, expected %s", err, ErrNotValid)
+	}
+}
+
+func TestEncrypted_collections(t *testing.T) {
+	s := newMapStore()
+	e, _ := Encrypted(s, testKey32("k1", 1))
+	ob := note("https://example.com/objects/1", "hello")
+	e.Save(ob)
+
+	envelope := s.items[ob.ID]
+	col := pub.OrderedCollectionNew("https://example.com/actors/jdoe/outbox")
+	col.OrderedItems = pub.ItemCollection{envelope, pub.IRI("https://example.com/objects/2")}
+	e.Save(col)
+
+	it, err := e.Load(col.ID)
+	if err != nil {
+		t.Fatalf("Load returned error: %s", err)
+	}
+	loaded := it.(*pub.OrderedCollection)
+	if contentOf(loaded.OrderedItems[0]) != "hello" || !pub.IsIRI(loaded.OrderedItems[1]) {
+		t.Errorf("Load should decrypt the members of collections, got %v", loaded.OrderedItems)
+	}
+}
@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// The errors returned by the backends wrap one of the following, so the callers can check
+// the kind of failure using errors.Is, regardless of the backend.
+var (
+	// ErrNotFound is returned when the requested item is not in the storage.
+	// It wraps os.ErrNotExist, so errors.Is(err, os.ErrNotExist) also matches it.
+	ErrNotFound = fmt.Errorf("not found: %w", os.ErrNotExist)
+	// ErrConflict is returned when a write conflicts with the current state of the storage,
+	// like an item that already exists, or that has been modified concurrently.
+	ErrConflict = errors.New("conflict")
+	// ErrGone is returned for items that have been deleted, and replaced by a Tombstone.
+	ErrGone = errors.New("gone")
+	// ErrNotValid is returned for items, or operations, that the storage can't accept,
+	// like an object without an ID, or an invalid IRI.
+	ErrNotValid = errors.New("not valid")
+	// ErrTxClosed is returned by the operations on a transaction that has already been
+	// committed or rolled back, see TxStore.
+	ErrTxClosed = errors.New("transaction is closed")
+	// ErrClosed is returned by the operations of a Store that has been closed.
+	ErrClosed = errors.New("storage is closed")
+	// ErrReadOnly is returned by the write operations of a Store that has been opened in read-only mode.
+	ErrReadOnly = errors.New("storage is read-only")
+	// ErrQuotaExceeded is returned for the writes which would take the usage of an actor beyond its quota,
+	// see QuotaStore.
+	ErrQuotaExceeded = errors.New("quota exceeded")
+	// ErrCorrupted is returned for stored items that can't be decoded, see CorruptedItemError.
+	ErrCorrupted = errors.New("corrupted")
+	// ErrDuplicate is returned for the deliveries of activities which have already been received,
+	// see IdempotentStore. It wraps ErrConflict.
+	ErrDuplicate = fmt.Errorf("duplicate: %w", ErrConflict)
+	// ErrMovedPermanently is returned for items which have been moved to another IRI, see MovedError.
+	ErrMovedPermanently = errors.New("moved permanently")
+)
@@ -0,0 +1,30 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		is   error
+	}{
+		{name: "not found", err: ErrNotFound, is: os.ErrNotExist},
+		{name: "duplicate vote", err: ErrDuplicateVote, is: ErrConflict},
+		{name: "duplicate delivery", err: ErrDuplicate, is: ErrConflict},
+		{name: "moved", err: &MovedError{IRI: "https://example.com/1", Target: "https://example.com/2"}, is: ErrMovedPermanently},
+		{name: "poll closed", err: ErrPollClosed, is: ErrNotValid},
+		{name: "invalid vote", err: ValidateVote(nil, "yes"), is: ErrNotValid},
+		{name: "schema version", err: ErrSchemaVersion, is: ErrNotValid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !errors.Is(tt.err, tt.is) {
+				t.Errorf("%v should match %v", tt.err, tt.is)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	pub "github.com/go-ap/activitypub"
+)
+
+// SkipIRI checks if the object with the "iri" IRI can't match "f", because it's not under
+// one of the Prefixes of a FilterablePrefixes filter, or it's under one of the Excluded IRIs
+// of a FilterableExclusions filter.
+// It allows backends to skip objects by their key, before loading and decoding them.
+func SkipIRI(f Filterable, iri pub.IRI) bool {
+	if pf, ok := f.(FilterablePrefixes); ok {
+		if prefixes := pf.Prefixes(); len(prefixes) > 0 && !underAny(prefixes, iri) {
+			return true
+		}
+	}
+	if ef, ok := f.(FilterableExclusions); ok && underAny(ef.Excluded(), iri) {
+		return true
+	}
+	return false
+}
+
+// MatchExclusions checks that "it" satisfies the Prefixes and Excluded IRIs of "f", see SkipIRI.
+// Besides its IRI, the actor of activities and the attributedTo of objects can't be under an excluded IRI.
+func MatchExclusions(f Filterable, it pub.Item) bool {
+	if pub.IsNil(it) || SkipIRI(f, it.GetLink()) {
+		return false
+	}
+	ef, ok := f.(FilterableExclusions)
+	if !ok || len(ef.Excluded()) == 0 || !pub.IsObject(it) {
+		return true
+	}
+	excluded := ef.Excluded()
+	match := true
+	if pub.ActivityTypes.Contains(it.GetType()) || pub.IntransitiveActivityTypes.Contains(it.GetType()) {
+		pub.OnIntransitiveActivity(it, func(a *pub.IntransitiveActivity) error {
+			match = !underAny(excluded, links(a.Actor)...)
+			return nil
+		})
+	}
+	if match {
+		pub.OnObject(it, func(o *pub.Object) error {
+			match = !underAny(excluded, links(o.AttributedTo)...)
+			return nil
+		})
+	}
+	return match
+}
+
+// links returns the IRIs of "it", which can be an item collection.
+func links(it pub.Item) pub.IRIs {
+	if pub.IsNil(it) {
+		return nil
+	}
+	iris := make(pub.IRIs, 0)
+	if pub.IsItemCollection(it) {
+		pub.OnItemCollection(it, func(col *pub.ItemCollection) error {
+			for _, it := range *col {
+				if !pub.IsNil(it) {
+					iris = append(iris, it.GetLink())
+				}
+			}
+			return nil
+		})
+		return iris
+	}
+	return append(iris, it.GetLink())
+}
+
+// underAny checks if any of the "iris" is under one of the "bases".
+func underAny(bases pub.IRIs, iris ...pub.IRI) bool {
+	for _, base := range bases {
+		if len(base) == 0 {
+			continue
+		}
+		for _, iri := range iris {
+			if UnderIRI(iri, base) {
+				return true
+			}
+		}
+	}
+	return false
+}
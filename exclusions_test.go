@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+func TestSkipIRI(t *testing.T) {
+	tests := []struct {
+		name string
+		f    Filterable
+		iri  pub.IRI
+		want bool
+	}{
+		{name: "IRI filter", f: pub.IRI("https://example.com/objects"), iri: "https://example.org/objects/1", want: false},
+		{name: "no prefixes", f: Filter{}, iri: "https://example.com/objects/1", want: false},
+		{name: "under prefix", f: Filter{Prefix: pub.IRIs{"https://example.org", "https://example.com/objects"}}, iri: "https://example.com/objects/1", want: false},
+		{name: "not under prefix", f: Filter{Prefix: pub.IRIs{"https://example.com/objects"}}, iri: "https://example.com/objectsX/1", want: true},
+		{name: "excluded", f: Filter{Exclude: pub.IRIs{"https://spam.example"}}, iri: "https://spam.example/objects/1", want: true},
+		{name: "excluded itself", f: Filter{Exclude: pub.IRIs{"https://example.com/objects/1"}}, iri: "https://example.com/objects/1", want: true},
+		{name: "not excluded", f: Filter{Exclude: pub.IRIs{"https://spam.example"}}, iri: "https://spam.example.com/objects/1", want: false},
+		{name: "excluded under prefix", f: Filter{Prefix: pub.IRIs{"https://example.com"}, Exclude: pub.IRIs{"https://example.com/private"}}, iri: "https://example.com/private/1", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SkipIRI(tt.f, tt.iri); got != tt.want {
+				t.Errorf("SkipIRI() = %t, expected %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchExclusions(t *testing.T) {
+	blocked := pub.IRI("https://example.com/actors/blocked")
+	f := Filter{Exclude: pub.IRIs{blocked, "https://spam.example"}}
+
+	create := pub.ActivityNew("https://example.com/activities/1", pub.CreateType, nil)
+	create.Actor = blocked
+	arrive := pub.IntransitiveActivityNew("https://example.com/activities/2", pub.ArriveType)
+	arrive.Actor = pub.ItemCollection{pub.IRI("https://example.com/actors/jdoe"), pub.IRI("https://spam.example/actors/1")}
+	attributed := note("https://example.com/objects/1", "hello")
+	attributed.AttributedTo = blocked
+	allowed := pub.ActivityNew("https://example.com/activities/3", pub.CreateType, nil)
+	allowed.Actor = pub.IRI("https://example.com/actors/jdoe")
+
+	tests := []struct {
+		name string
+		it   pub.Item
+		want bool
+	}{
+		{name: "activity of an excluded actor", it: create, want: false},
+		{name: "intransitive activity of an excluded server", it: arrive, want: false},
+		{name: "object attributed to an excluded actor", it: attributed, want: false},
+		{name: "allowed activity", it: allowed, want: true},
+		{name: "IRI", it: pub.IRI("https://example.com/objects/2"), want: true},
+		{name: "excluded IRI", it: pub.IRI("https://spam.example/objects/2"), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MatchExclusions(f, tt.it); got != tt.want {
+				t.Errorf("MatchExclusions() = %t, expected %t", got, tt.want)
+			}
+			if pub.IsIRI(tt.it) {
+				return
+			}
+			if got := f.Match(tt.it); got != tt.want {
+				t.Errorf("Match() = %t, expected %t", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package storage
+
+import (
+	"errors"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// ExistsStore can check if an item is stored without loading it, which makes deduplicating
+// the incoming activities cheaper.
+type ExistsStore interface {
+	// Exists reports if an item having the "iri" IRI is present in the storage.
+	Exists(iri pub.IRI) (bool, error)
+}
+
+// Exists reports if "iri" is present in the "s" store. For stores that are not ExistsStores,
+// it loads the item, and considers ErrNotFound as the item missing.
+func Exists(s ReadStore, iri pub.IRI) (bool, error) {
+	if es, ok := s.(ExistsStore); ok {
+		return es.Exists(iri)
+	}
+	it, err := s.Load(iri)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return !pub.IsNil(it), nil
+}
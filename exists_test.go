@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+type brokenStore struct {
+	*mapStore
+}
+
+func (b brokenStore) Load(pub.IRI) (pub.Item, error) {
+	return nil, errors.New("broken")
+}
+
+func TestExists(t *testing.T) {
+	s := newMapStore()
+	s.Save(note("https://example.com/objects/1", "hello"))
+
+	tests := []struct {
+		name    string
+		s       ReadStore
+		iri     pub.IRI
+		want    bool
+		wantErr bool
+	}{
+		{name: "stored", s: s, iri: "https://example.com/objects/1", want: true},
+		{name: "missing", s: s, iri: "https://example.com/objects/2", want: false},
+		{name: "error", s: brokenStore{s}, iri: "https://example.com/objects/1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Exists(tt.s, tt.iri)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Exists returned error %v, expected error %t", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Exists returned %t, expected %t", got, tt.want)
+			}
+		})
+	}
+}
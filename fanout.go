@@ -0,0 +1,37 @@
+package storage
+
+import (
+	pub "github.com/go-ap/activitypub"
+)
+
+// FanOutStore can add an item to multiple collections at once, like an activity delivered to a shared
+// inbox, which needs to be added to the inboxes of all the local recipients.
+type FanOutStore interface {
+	// FanOut adds "it" to all the "cols" collections. Backends that support transactions should
+	// add it to either all or none of them.
+	FanOut(it pub.Item, cols ...pub.IRI) error
+}
+
+// FanOut adds "it" to the "cols" collections of the "s" store, in a single operation if it's a FanOutStore,
+// or in a single transaction if it's a TxStore. Otherwise it's added to them one by one, in which case
+// it stops at the first error. The store needs to be a CollectionStore.
+func FanOut(s Store, it pub.Item, cols ...pub.IRI) error {
+	if fs, ok := s.(FanOutStore); ok {
+		return fs.FanOut(it, cols...)
+	}
+	if ts, ok := s.(TxStore); ok {
+		return ts.WithTx(func(tx Store) error {
+			return fanOut(asCollectionStore(tx), it, cols)
+		})
+	}
+	return fanOut(asCollectionStore(s), it, cols)
+}
+
+func fanOut(cs CollectionStore, it pub.Item, cols pub.IRIs) error {
+	for _, col := range cols {
+		if err := cs.AddTo(col, it); err != nil {
+			return err
+		}
+	}
+	return nil
+}
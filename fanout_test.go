@@ -0,0 +1,28 @@
+package storage
+
+import (
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+func TestFanOut(t *testing.T) {
+	cs := newCollectionMapStore()
+	ob := note("https://example.com/objects/1", "1")
+	inboxes := pub.IRIs{"https://example.com/actors/jdoe/inbox", "https://example.com/actors/alice/inbox"}
+	for _, inbox := range inboxes {
+		cs.Create(pub.OrderedCollectionNew(inbox))
+	}
+
+	if err := FanOut(cs, ob, inboxes...); err != nil {
+		t.Fatalf("FanOut returned error: %s", err)
+	}
+	for _, inbox := range inboxes {
+		if members := cs.members[inbox]; len(members) != 1 || members[0] != ob.ID {
+			t.Errorf("%s has members %v, expected only %s", inbox, members, ob.ID)
+		}
+	}
+	if err := FanOut(newMapStore(), ob, inboxes...); err == nil {
+		t.Errorf("FanOut should fail for a store which isn't a CollectionStore")
+	}
+}
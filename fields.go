@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"strings"
+
+	pub "github.com/go-ap/activitypub"
+	"github.com/valyala/fastjson"
+)
+
+// RawFilterFn is a predicate that gets applied to the raw JSON document of a stored item,
+// which allows backends to discard items before unmarshalling them.
+type RawFilterFn func(raw []byte) bool
+
+// MissingField returns a predicate that matches the JSON documents where the "name" property
+// is absent or empty: null, an empty string, an empty array or an empty object.
+//
+// Nested properties can be specified using a dot separated path, eg: "publicKey.publicKeyPem",
+// and the elements of arrays can be referenced by their index, eg: "tag.0.name".
+func MissingField(name string) RawFilterFn {
+	path := strings.Split(name, ".")
+	return func(raw []byte) bool {
+		val, err := fastjson.ParseBytes(raw)
+		if err != nil {
+			return false
+		}
+		return emptyJSONValue(val.Get(path...))
+	}
+}
+
+func emptyJSONValue(val *fastjson.Value) bool {
+	if val == nil {
+		return true
+	}
+	switch val.Type() {
+	case fastjson.TypeNull:
+		return true
+	case fastjson.TypeString:
+		return len(val.GetStringBytes()) == 0
+	case fastjson.TypeArray:
+		return len(val.GetArray()) == 0
+	case fastjson.TypeObject:
+		return val.GetObject().Len() == 0
+	}
+	return false
+}
+
+// MatchRaw checks the raw JSON document against all the "filters" predicates.
+func MatchRaw(raw []byte, filters ...RawFilterFn) bool {
+	for _, fn := range filters {
+		if fn != nil && !fn(raw) {
+			return false
+		}
+	}
+	return true
+}
+
+// RawType returns the type of the item stored in the "raw" JSON document, without unmarshalling it.
+func RawType(raw []byte) pub.ActivityVocabularyType {
+	val, err := fastjson.ParseBytes(raw)
+	if err != nil {
+		return ""
+	}
+	return pub.ActivityVocabularyType(val.GetStringBytes("type"))
+}
+
+// MatchRawType checks if the "raw" JSON document is of one of the "f" filter's Types,
+// if it's a FilterableTypes, so backends can discard items before unmarshalling them.
+func MatchRawType(f Filterable, raw []byte) bool {
+	tf, ok := f.(FilterableTypes)
+	if !ok {
+		return true
+	}
+	types := tf.Types()
+	return len(types) == 0 || types.Contains(RawType(raw))
+}
@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+func TestMissingField(t *testing.T) {
+	doc := []byte(`{
+		"id": "https://example.com/actors/jdoe",
+		"type": "Person",
+		"name": "",
+		"summary": null,
+		"tag": [{"type": "Mention", "name": "@alice"}, {"type": "Hashtag"}],
+		"attachment": [],
+		"endpoints": {},
+		"publicKey": {
+			"id": "https://example.com/actors/jdoe#main-key",
+			"owner": "https://example.com/actors/jdoe",
+			"publicKeyPem": ""
+		}
+	}`)
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{name: "id", want: false},
+		{name: "type", want: false},
+		{name: "published", want: true},
+		{name: "name", want: true},
+		{name: "summary", want: true},
+		{name: "attachment", want: true},
+		{name: "endpoints", want: true},
+		{name: "endpoints.sharedInbox", want: true},
+		{name: "publicKey", want: false},
+		{name: "publicKey.owner", want: false},
+		{name: "publicKey.publicKeyPem", want: true},
+		{name: "publicKey.owner.id", want: true},
+		{name: "tag.0.name", want: false},
+		{name: "tag.1.name", want: true},
+		{name: "tag.2", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MissingField(tt.name)(doc); got != tt.want {
+				t.Errorf("MissingField(%q) = %t, want %t", tt.name, got, tt.want)
+			}
+		})
+	}
+	t.Run("invalid json", func(t *testing.T) {
+		if MissingField("id")([]byte(`{"id":`)) {
+			t.Errorf("MissingField should not match invalid documents")
+		}
+	})
+}
+
+func TestMatchRaw(t *testing.T) {
+	doc := []byte(`{"id": "https://example.com/1", "type": "Note"}`)
+	if !MatchRaw(doc) {
+		t.Errorf("MatchRaw without filters should match")
+	}
+	if !MatchRaw(doc, MissingField("published"), MissingField("content")) {
+		t.Errorf("MatchRaw should match when all filters match")
+	}
+	if MatchRaw(doc, MissingField("published"), MissingField("type")) {
+		t.Errorf("MatchRaw should not match when one of the filters doesn't match")
+	}
+}
+
+func TestMatchRawType(t *testing.T) {
+	create := []byte(`{"id": "https://example.com/activities/1", "type": "Create", "actor": "https://example.com/actors/jdoe"}`)
+	tests := []struct {
+		name string
+		f    Filterable
+		raw  []byte
+		want bool
+	}{
+		{name: "IRI filter", f: pub.IRI("https://example.com"), raw: create, want: true},
+		{name: "no types", f: Filter{}, raw: create, want: true},
+		{name: "matching type", f: Filter{Type: pub.ActivityVocabularyTypes{pub.LikeType, pub.CreateType}}, raw: create, want: true},
+		{name: "other type", f: Filter{Type: pub.ActivityVocabularyTypes{pub.LikeType}}, raw: create, want: false},
+		{name: "missing type", f: Filter{Type: pub.ActivityVocabularyTypes{pub.LikeType}}, raw: []byte(`{"id": "https://example.com"}`), want: false},
+		{name: "invalid document", f: Filter{Type: pub.ActivityVocabularyTypes{pub.LikeType}}, raw: []byte(`{`), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MatchRawType(tt.f, tt.raw); got != tt.want {
+				t.Errorf("MatchRawType() = %t, expected %t", got, tt.want)
+			}
+		})
+	}
+}
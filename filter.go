@@ -0,0 +1,252 @@
+package storage
+
+import (
+	"bytes"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// Filter is a generic filter for ActivityStreams objects, which implements the FilterableObject,
+// FilterablePublished, FilterablePrefixes and FilterableExclusions interfaces.
+//
+// Each field that is not empty adds a condition that the matching objects must satisfy, and
+// a condition with multiple values is satisfied if any of them matches.
+// Backends that can't translate the conditions to native queries can check the loaded items
+// using the Match method.
+type Filter struct {
+	// IRI is the IRI of the collection, or endpoint, the objects are loaded from.
+	IRI pub.IRI
+	// ID matches the IDs of the objects.
+	ID pub.IRIs
+	// Prefix matches the objects having their IDs under one of the IRIs.
+	Prefix pub.IRIs
+	// Exclude skips the objects having their IDs, actor, or attributedTo properties under one of the IRIs.
+	Exclude pub.IRIs
+	// Type matches the types of the objects.
+	Type pub.ActivityVocabularyTypes
+	// Author matches the attributedTo property of the objects.
+	Author pub.IRIs
+	// Parent matches the inReplyTo property of the objects.
+	Parent pub.IRIs
+	// Recipient matches any of the to, cc, bto, bcc and audience properties of the objects.
+	Recipient pub.IRIs
+	// InContext matches the context property of the objects.
+	InContext pub.IRIs
+	// GeneratedBy matches the generator property of the objects.
+	GeneratedBy pub.IRIs
+	// URL matches the url property of the objects.
+	URL pub.IRIs
+	// MediaType matches the mediaType property of the objects.
+	MediaType []pub.MimeType
+	// Name matches the objects having one of the values as a case-insensitive substring of their name,
+	// or, for actors, their preferredUsername.
+	Name []string
+	// Text matches the objects having one of the values as a case-insensitive substring of their content.
+	Text []string
+	// After matches the objects published after this moment.
+	After time.Time
+	// Before matches the objects published before this moment.
+	Before time.Time
+	// Order is the order in which the members of the IRI collection are read.
+	Order SortOrder
+	// Pins are the members of the IRI collection returned first, see FilterablePinned.
+	Pins pub.IRIs
+	// Partial opts in to the items gathered until the context of the load is done, see FilterablePartial.
+	Partial bool
+}
+
+func (f Filter) GetLink() pub.IRI {
+	return f.IRI
+}
+
+func (f Filter) IRIs() pub.IRIs {
+	return f.ID
+}
+
+func (f Filter) Prefixes() pub.IRIs {
+	return f.Prefix
+}
+
+func (f Filter) Excluded() pub.IRIs {
+	return f.Exclude
+}
+
+func (f Filter) Types() pub.ActivityVocabularyTypes {
+	return f.Type
+}
+
+func (f Filter) AttributedTo() pub.IRIs {
+	return f.Author
+}
+
+func (f Filter) InReplyTo() pub.IRIs {
+	return f.Parent
+}
+
+func (f Filter) MediaTypes() []pub.MimeType {
+	return f.MediaType
+}
+
+func (f Filter) Names() []string {
+	return f.Name
+}
+
+func (f Filter) Content() []string {
+	return f.Text
+}
+
+func (f Filter) URLs() pub.IRIs {
+	return f.URL
+}
+
+func (f Filter) Audience() pub.IRIs {
+	return f.Recipient
+}
+
+func (f Filter) Context() pub.IRIs {
+	return f.InContext
+}
+
+func (f Filter) Generator() pub.IRIs {
+	return f.GeneratedBy
+}
+
+func (f Filter) PublishedBefore() time.Time {
+	return f.Before
+}
+
+func (f Filter) PublishedAfter() time.Time {
+	return f.After
+}
+
+func (f Filter) SortOrder() SortOrder {
+	return f.Order
+}
+
+func (f Filter) Pinned() pub.IRIs {
+	return f.Pins
+}
+
+func (f Filter) AllowPartial() bool {
+	return f.Partial
+}
+
+// Match checks if "it" satisfies all the conditions of the filter.
+func (f Filter) Match(it pub.Item) bool {
+	if pub.IsNil(it) {
+		return false
+	}
+	if len(f.ID) > 0 && !f.ID.Contains(it.GetLink()) {
+		return false
+	}
+	if len(f.Type) > 0 && !f.Type.Contains(it.GetType()) {
+		return false
+	}
+	if !MatchExclusions(f, it) {
+		return false
+	}
+	match := true
+	err := pub.OnObject(it, func(o *pub.Object) error {
+		match = matchIRIs(f.Author, o.AttributedTo) &&
+			matchIRIs(f.Parent, o.InReplyTo) &&
+			matchIRIs(f.Recipient, o.To, o.CC, o.Bto, o.BCC, o.Audience) &&
+			matchIRIs(f.InContext, o.Context) &&
+			matchIRIs(f.GeneratedBy, o.Generator) &&
+			matchIRIs(f.URL, o.URL) &&
+			matchMediaType(f.MediaType, o.MediaType) &&
+			matchText(f.Text, o.Content) &&
+			matchPublished(f.After, f.Before, o.Published)
+		return nil
+	})
+	if err != nil || !match {
+		return false
+	}
+	if len(f.Name) == 0 {
+		return true
+	}
+	names := make([]pub.NaturalLanguageValues, 0)
+	pub.OnObject(it, func(o *pub.Object) error {
+		names = append(names, o.Name)
+		return nil
+	})
+	if pub.ActorTypes.Contains(it.GetType()) {
+		pub.OnActor(it, func(a *pub.Actor) error {
+			names = append(names, a.PreferredUsername)
+			return nil
+		})
+	}
+	return matchText(f.Name, names...)
+}
+
+// matchIRIs checks if any of the "props" values links to one of the "iris".
+func matchIRIs(iris pub.IRIs, props ...pub.Item) bool {
+	if len(iris) == 0 {
+		return true
+	}
+	for _, prop := range props {
+		if pub.IsNil(prop) {
+			continue
+		}
+		if pub.IsItemCollection(prop) {
+			match := false
+			pub.OnItemCollection(prop, func(col *pub.ItemCollection) error {
+				for _, it := range *col {
+					if !pub.IsNil(it) && iris.Contains(it.GetLink()) {
+						match = true
+						break
+					}
+				}
+				return nil
+			})
+			if match {
+				return true
+			}
+			continue
+		}
+		if iris.Contains(prop.GetLink()) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchMediaType(types []pub.MimeType, typ pub.MimeType) bool {
+	if len(types) == 0 {
+		return true
+	}
+	for _, t := range types {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// matchText checks if any of the "terms" is a case-insensitive substring of any of the "values".
+func matchText(terms []string, values ...pub.NaturalLanguageValues) bool {
+	if len(terms) == 0 {
+		return true
+	}
+	for _, nlv := range values {
+		for _, v := range nlv {
+			content := bytes.ToLower(v.Value)
+			for _, term := range terms {
+				if bytes.Contains(content, bytes.ToLower([]byte(term))) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func matchPublished(after, before, published time.Time) bool {
+	if !after.IsZero() && !published.After(after) {
+		return false
+	}
+	if !before.IsZero() && !published.Before(before) {
+		return false
+	}
+	return true
+}
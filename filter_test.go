@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+var (
+	_ FilterableObject    = Filter{}
+	_ FilterablePublished = Filter{}
+)
+
+func TestFilter_Match(t *testing.T) {
+	published := time.Date(2022, 5, 29, 12, 0, 0, 0, time.UTC)
+	alice := pub.IRI("https://example.com/actors/alice")
+	bob := pub.IRI("https://example.com/actors/bob")
+
+	ob := pub.ObjectNew(pub.NoteType)
+	ob.ID = "https://example.com/objects/1"
+	ob.AttributedTo = alice
+	ob.InReplyTo = pub.ItemCollection{pub.IRI("https://example.com/objects/0")}
+	ob.To = pub.ItemCollection{pub.PublicNS}
+	ob.CC = pub.ItemCollection{bob}
+	ob.Name.Set("en", pub.Content("Release Notes"))
+	ob.Content.Set("en", pub.Content("<p>A new version is available</p>"))
+	ob.MediaType = "text/html"
+	ob.Published = published
+
+	actor := pub.PersonNew(bob)
+	actor.PreferredUsername.Set(pub.NilLangRef, pub.Content("bobby"))
+
+	tests := []struct {
+		name   string
+		filter Filter
+		item   pub.Item
+		want   bool
+	}{
+		{name: "empty filter", filter: Filter{}, item: ob, want: true},
+		{name: "nil item", filter: Filter{}, item: nil, want: false},
+		{name: "id", filter: Filter{ID: pub.IRIs{ob.ID}}, item: ob, want: true},
+		{name: "other id", filter: Filter{ID: pub.IRIs{"https://example.com/objects/2"}}, item: ob, want: false},
+		{name: "type", filter: Filter{Type: pub.ActivityVocabularyTypes{pub.ArticleType, pub.NoteType}}, item: ob, want: true},
+		{name: "other type", filter: Filter{Type: pub.ActivityVocabularyTypes{pub.CreateType}}, item: ob, want: false},
+		{name: "author", filter: Filter{Author: pub.IRIs{alice}}, item: ob, want: true},
+		{name: "other author", filter: Filter{Author: pub.IRIs{bob}}, item: ob, want: false},
+		{name: "parent", filter: Filter{Parent: pub.IRIs{"https://example.com/objects/0"}}, item: ob, want: true},
+		{name: "public recipient", filter: Filter{Recipient: pub.IRIs{pub.PublicNS}}, item: ob, want: true},
+		{name: "cc recipient", filter: Filter{Recipient: pub.IRIs{bob}}, item: ob, want: true},
+		{name: "other recipient", filter: Filter{Recipient: pub.IRIs{alice}}, item: ob, want: false},
+		{name: "media type", filter: Filter{MediaType: []pub.MimeType{"text/markdown"}}, item: ob, want: false},
+		{name: "name substring", filter: Filter{Name: []string{"notes"}}, item: ob, want: true},
+		{name: "name no match", filter: Filter{Name: []string{"changelog"}}, item: ob, want: false},
+		{name: "actor username", filter: Filter{Name: []string{"BOB"}}, item: actor, want: true},
+		{name: "content substring", filter: Filter{Text: []string{"NEW VERSION"}}, item: ob, want: true},
+		{name: "content no match", filter: Filter{Text: []string{"old version"}}, item: ob, want: false},
+		{name: "published in range", filter: Filter{After: published.Add(-time.Hour), Before: published.Add(time.Hour)}, item: ob, want: true},
+		{name: "published before range", filter: Filter{After: published.Add(time.Hour)}, item: ob, want: false},
+		{name: "published after range", filter: Filter{Before: published}, item: ob, want: false},
+		{name: "not published", filter: Filter{After: published}, item: actor, want: false},
+		{name: "all conditions", filter: Filter{Type: pub.ActivityVocabularyTypes{pub.NoteType}, Author: pub.IRIs{alice}, Text: []string{"version"}}, item: ob, want: true},
+		{name: "one failing condition", filter: Filter{Type: pub.ActivityVocabularyTypes{pub.NoteType}, Author: pub.IRIs{alice}, Text: []string{"nope"}}, item: ob, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(tt.item); got != tt.want {
+				t.Errorf("Match() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// FilterList is the name of a list of the actors, or domains, whose activities an actor doesn't want to see.
+type FilterList string
+
+const (
+	// BlockList holds the IRIs of the actors blocked by an actor.
+	BlockList FilterList = "blocks"
+	// MuteList holds the IRIs of the actors muted by an actor.
+	MuteList FilterList = "mutes"
+	// DomainBlockList holds the hosts blocked by an actor, eg: "spam.example".
+	DomainBlockList FilterList = "domain-blocks"
+)
+
+// FilterListStore persists the filter lists of the actors, which need to be checked for every delivery
+// to their inboxes, so the membership checks don't need to load the whole lists.
+type FilterListStore interface {
+	// AddToList adds the "entries" to the "list" of the "owner" actor.
+	AddToList(owner pub.IRI, list FilterList, entries ...string) error
+	// RemoveFromList removes the "entries" from the "list" of the "owner" actor.
+	RemoveFromList(owner pub.IRI, list FilterList, entries ...string) error
+	// LoadList returns the sorted entries of the "list" of the "owner" actor, which is empty if it doesn't have any.
+	LoadList(owner pub.IRI, list FilterList) ([]string, error)
+	// InList reports if any of the "entries" is in the "list" of the "owner" actor.
+	InList(owner pub.IRI, list FilterList, entries ...string) (bool, error)
+}
+
+// Blocked reports if the "owner" actor has blocked the "actor", or its domain.
+func Blocked(s FilterListStore, owner, actor pub.IRI) (bool, error) {
+	if ok, err := s.InList(owner, BlockList, actor.String()); ok || err != nil {
+		return ok, err
+	}
+	u, err := url.Parse(actor.String())
+	if err != nil || len(u.Host) == 0 {
+		return false, nil
+	}
+	return s.InList(owner, DomainBlockList, strings.ToLower(u.Host))
+}
+
+// FilterListNamespace returns the MetadataStore namespace under which FilterLists stores the "list".
+func FilterListNamespace(list FilterList) string {
+	return "filter-list-" + string(list)
+}
+
+// FilterLists returns a FilterListStore that keeps each list of an actor as a sorted JSON array
+// in the FilterListNamespace metadata of the "m" store, so the membership checks are binary searches.
+func FilterLists(m MetadataStore) FilterListStore {
+	return &metadataFilterLists{m: m}
+}
+
+type metadataFilterLists struct {
+	m  MetadataStore
+	mu sync.Mutex
+}
+
+func (f *metadataFilterLists) LoadList(owner pub.IRI, list FilterList) ([]string, error) {
+	data, err := f.m.LoadMetadata(owner, FilterListNamespace(list))
+	if errors.Is(err, ErrNotFound) || (err == nil && len(data) == 0) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]string, 0)
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("invalid %s list for %s: %w", list, owner, err)
+	}
+	return entries, nil
+}
+
+func (f *metadataFilterLists) InList(owner pub.IRI, list FilterList, entries ...string) (bool, error) {
+	stored, err := f.LoadList(owner, list)
+	if err != nil {
+		return false, err
+	}
+	for _, e := range entries {
+		if i := sort.SearchStrings(stored, e); i < len(stored) && stored[i] == e {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *metadataFilterLists) AddToList(owner pub.IRI, list FilterList, entries ...string) error {
+	return f.update(owner, list, func(stored map[string]struct{}) {
+		for _, e := range entries {
+			stored[e] = struct{}{}
+		}
+	})
+}
+
+func (f *metadataFilterLists) RemoveFromList(owner pub.IRI, list FilterList, entries ...string) error {
+	return f.update(owner, list, func(stored map[string]struct{}) {
+		for _, e := range entries {
+			delete(stored, e)
+		}
+	})
+}
+
+func (f *metadataFilterLists) update(owner pub.IRI, list FilterList, fn func(map[string]struct{})) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entries, err := f.LoadList(owner, list)
+	if err != nil {
+		return err
+	}
+	stored := make(map[string]struct{}, len(entries))
+	for _, e := range entries {
+		stored[e] = struct{}{}
+	}
+	fn(stored)
+	entries = make([]string, 0, len(stored))
+	for e := range stored {
+		if len(e) > 0 {
+			entries = append(entries, e)
+		}
+	}
+	sort.Strings(entries)
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	return f.m.SaveMetadata(owner, FilterListNamespace(list), data)
+}
@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+func TestFilterLists(t *testing.T) {
+	fl := FilterLists(metadataMap{})
+	owner := pub.IRI("https://example.com/actors/jdoe")
+	spammer := pub.IRI("https://spam.example/actors/spammer")
+	troll := pub.IRI("https://example.org/actors/troll")
+
+	entries, err := fl.LoadList(owner, BlockList)
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("LoadList of an empty list returned %v, %v", entries, err)
+	}
+	if err := fl.AddToList(owner, BlockList, troll.String(), "https://example.org/actors/another", troll.String()); err != nil {
+		t.Fatalf("AddToList returned error: %s", err)
+	}
+	if err := fl.AddToList(owner, DomainBlockList, "spam.example"); err != nil {
+		t.Fatalf("AddToList returned error: %s", err)
+	}
+	if err := fl.AddToList(owner, MuteList, spammer.String()); err != nil {
+		t.Fatalf("AddToList returned error: %s", err)
+	}
+	entries, err = fl.LoadList(owner, BlockList)
+	if err != nil {
+		t.Fatalf("LoadList returned error: %s", err)
+	}
+	if expected := []string{"https://example.org/actors/another", troll.String()}; !reflect.DeepEqual(entries, expected) {
+		t.Errorf("LoadList returned %v, expected %v", entries, expected)
+	}
+
+	tests := []struct {
+		actor   pub.IRI
+		blocked bool
+	}{
+		{actor: troll, blocked: true},
+		{actor: spammer, blocked: true},
+		{actor: "https://SPAM.example/actors/other", blocked: true},
+		{actor: "https://example.com/actors/friend"},
+	}
+	for _, tt := range tests {
+		if ok, err := Blocked(fl, owner, tt.actor); err != nil || ok != tt.blocked {
+			t.Errorf("Blocked(%s) returned %t, %v, expected %t", tt.actor, ok, err, tt.blocked)
+		}
+	}
+	if ok, _ := fl.InList(owner, MuteList, troll.String(), spammer.String()); !ok {
+		t.Errorf("InList should find %s in the mutes", spammer)
+	}
+	if ok, _ := Blocked(fl, "https://example.com/actors/other", troll); ok {
+		t.Errorf("Blocked should only use the lists of the owner")
+	}
+
+	if err := fl.RemoveFromList(owner, BlockList, troll.String()); err != nil {
+		t.Fatalf("RemoveFromList returned error: %s", err)
+	}
+	if ok, _ := Blocked(fl, owner, troll); ok {
+		t.Errorf("Blocked returned true for %s after its removal", troll)
+	}
+}
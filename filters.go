@@ -1,6 +1,8 @@
 package storage
 
 import (
+	"time"
+
 	pub "github.com/go-ap/activitypub"
 )
 
@@ -12,12 +14,56 @@ type Filterable interface {
 	GetLink() pub.IRI
 }
 
-type FilterableItems interface {
+// FilterableTypes can filter items by their type
+type FilterableTypes interface {
 	Filterable
+	// Types returns the list of types of which the matching items must have one.
+	// An empty list doesn't restrict the items.
 	Types() pub.ActivityVocabularyTypes
+}
+
+type FilterableItems interface {
+	FilterableTypes
 	IRIs() pub.IRIs
 }
 
+// Matcher is implemented by filters that can check by themselves if an item satisfies them, like Filter.
+type Matcher interface {
+	Match(pub.Item) bool
+}
+
+// FilterableRaw can filter items based on their raw JSON representation, for example
+// selecting the ones that are missing a property using MissingField.
+type FilterableRaw interface {
+	Filterable
+	RawFilters() []RawFilterFn
+}
+
+// FilterablePrefixes can select objects from multiple IRI hierarchies
+type FilterablePrefixes interface {
+	Filterable
+	// Prefixes returns the IRIs of which the matching objects must be, or be nested under, one of.
+	// An empty list doesn't restrict the objects.
+	Prefixes() pub.IRIs
+}
+
+// FilterableExclusions can exclude objects from the results, like the ones of blocked actors or servers
+type FilterableExclusions interface {
+	Filterable
+	// Excluded returns the IRIs of which the matching objects can't be, or be nested under, and under
+	// which their actor, or attributedTo, properties can't be either.
+	Excluded() pub.IRIs
+}
+
+// FilterableLanguage can filter objects by the language of their content
+type FilterableLanguage interface {
+	Filterable
+	// Language returns the list of language tags of which at least one must be present in the
+	// matching objects' contentMap, nameMap or summaryMap. The UnknownLanguage tag allows
+	// objects whose values are not language tagged. See MatchLanguage.
+	Language() []string
+}
+
 // FilterableCollection can filter collections
 type FilterableCollection interface {
 	FilterableObject
@@ -59,3 +105,14 @@ type FilterableObject interface {
 	// Generator returns the list of IRIs to check against an Object's Generator property.
 	Generator() pub.IRIs
 }
+
+// FilterablePublished can filter objects by the moment they have been published
+type FilterablePublished interface {
+	Filterable
+	// PublishedBefore returns the moment before which the matching objects were published.
+	// A zero value means no upper limit.
+	PublishedBefore() time.Time
+	// PublishedAfter returns the moment after which the matching objects were published.
+	// A zero value means no lower limit.
+	PublishedAfter() time.Time
+}
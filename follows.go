@@ -0,0 +1,223 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+const (
+	// FollowRequestNamespace is the MetadataStore namespace under which FollowRequests stores the state
+	// of each Follow activity.
+	FollowRequestNamespace = "follow-request"
+	// PendingFollowsNamespace is the MetadataStore namespace under which FollowRequests stores the IRIs
+	// of the pending Follow activities of each followed actor.
+	PendingFollowsNamespace = "pending-follows"
+)
+
+// FollowState is the state of a follow request.
+type FollowState string
+
+const (
+	// FollowPending is the state of the follow requests waiting for the followed actor to decide.
+	FollowPending FollowState = "pending"
+	// FollowAccepted is the state of the follow requests the followed actor has accepted.
+	FollowAccepted FollowState = "accepted"
+	// FollowRejected is the state of the follow requests the followed actor has rejected.
+	FollowRejected FollowState = "rejected"
+)
+
+// FollowRequest is the state of a Follow activity, addressed to an actor which manually approves its followers.
+type FollowRequest struct {
+	// Activity is the IRI of the Follow activity.
+	Activity pub.IRI `json:"activity"`
+	// Actor is the IRI of the actor who wants to follow the Object.
+	Actor pub.IRI `json:"actor"`
+	// Object is the IRI of the followed actor.
+	Object pub.IRI     `json:"object"`
+	State  FollowState `json:"state"`
+	// Created is the moment the request has been created.
+	Created time.Time `json:"created"`
+	// Updated is the moment the request has been accepted, or rejected.
+	Updated time.Time `json:"updated,omitempty"`
+}
+
+// FollowRequestStore tracks the state of the Follow activities, which start as pending, and can be
+// accepted, or rejected, once. Adding the follower to the followers collection is left to the callers.
+type FollowRequestStore interface {
+	// Create records the "follow" activity as a pending request. Creating a request which already
+	// exists returns the existing one.
+	Create(follow pub.Item) (FollowRequest, error)
+	// Load returns the request of the "follow" activity, or an error wrapping ErrNotFound.
+	Load(follow pub.IRI) (FollowRequest, error)
+	// Accept marks the pending request of the "follow" activity as accepted. Deciding a request which
+	// is not pending returns an error wrapping ErrConflict.
+	Accept(follow pub.IRI) (FollowRequest, error)
+	// Reject marks the pending request of the "follow" activity as rejected.
+	Reject(follow pub.IRI) (FollowRequest, error)
+	// ListPending returns the pending requests to follow the "actor", oldest first.
+	ListPending(actor pub.IRI) ([]FollowRequest, error)
+}
+
+// FollowRequests returns a FollowRequestStore that keeps each request as a JSON document in the
+// FollowRequestNamespace metadata of its Follow activity, and the pending requests of each actor
+// in its PendingFollowsNamespace metadata, in the "m" store.
+func FollowRequests(m MetadataStore) FollowRequestStore {
+	return &metadataFollows{m: m}
+}
+
+type metadataFollows struct {
+	m  MetadataStore
+	mu sync.Mutex
+}
+
+func (f *metadataFollows) Create(follow pub.Item) (FollowRequest, error) {
+	r := FollowRequest{State: FollowPending}
+	if pub.IsNil(follow) || follow.GetType() != pub.FollowType {
+		return r, fmt.Errorf("%w follow request: not a Follow activity", ErrNotValid)
+	}
+	pub.OnActivity(follow, func(a *pub.Activity) error {
+		r.Activity = a.GetLink()
+		if !pub.IsNil(a.Actor) {
+			r.Actor = a.Actor.GetLink()
+		}
+		if !pub.IsNil(a.Object) {
+			r.Object = a.Object.GetLink()
+		}
+		return nil
+	})
+	if len(r.Activity) == 0 || len(r.Actor) == 0 || len(r.Object) == 0 {
+		return r, fmt.Errorf("%w follow request: missing ID, actor, or object", ErrNotValid)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if existing, err := f.load(r.Activity); err == nil {
+		return existing, nil
+	} else if !errors.Is(err, ErrNotFound) {
+		return r, err
+	}
+	pending, err := f.pending(r.Object)
+	if err != nil {
+		return r, err
+	}
+	r.Created = time.Now().UTC()
+	if err := f.save(r); err != nil {
+		return r, err
+	}
+	return r, f.savePending(r.Object, append(pending, r.Activity))
+}
+
+func (f *metadataFollows) Load(follow pub.IRI) (FollowRequest, error) {
+	return f.load(follow)
+}
+
+func (f *metadataFollows) Accept(follow pub.IRI) (FollowRequest, error) {
+	return f.decide(follow, FollowAccepted)
+}
+
+func (f *metadataFollows) Reject(follow pub.IRI) (FollowRequest, error) {
+	return f.decide(follow, FollowRejected)
+}
+
+func (f *metadataFollows) ListPending(actor pub.IRI) ([]FollowRequest, error) {
+	pending, err := f.pending(actor)
+	if err != nil {
+		return nil, err
+	}
+	requests := make([]FollowRequest, 0, len(pending))
+	for _, iri := range pending {
+		r, err := f.load(iri)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, r)
+	}
+	return requests, nil
+}
+
+func (f *metadataFollows) decide(follow pub.IRI, state FollowState) (FollowRequest, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	r, err := f.load(follow)
+	if err != nil {
+		return r, err
+	}
+	if r.State != FollowPending {
+		return r, fmt.Errorf("%w: follow request %s is already %s", ErrConflict, follow, r.State)
+	}
+	pending, err := f.pending(r.Object)
+	if err != nil {
+		return r, err
+	}
+	r.State = state
+	r.Updated = time.Now().UTC()
+	if err := f.save(r); err != nil {
+		return r, err
+	}
+	remaining := make([]pub.IRI, 0, len(pending))
+	for _, iri := range pending {
+		if iri != follow {
+			remaining = append(remaining, iri)
+		}
+	}
+	return r, f.savePending(r.Object, remaining)
+}
+
+func (f *metadataFollows) load(follow pub.IRI) (FollowRequest, error) {
+	r := FollowRequest{}
+	data, err := f.m.LoadMetadata(follow, FollowRequestNamespace)
+	if err == nil && len(data) == 0 {
+		err = ErrNotFound
+	}
+	if errors.Is(err, ErrNotFound) {
+		return r, fmt.Errorf("unable to find follow request %s: %w", follow, ErrNotFound)
+	}
+	if err != nil {
+		return r, err
+	}
+	if err := json.Unmarshal(data, &r); err != nil {
+		return r, fmt.Errorf("invalid follow request %s: %w", follow, err)
+	}
+	return r, nil
+}
+
+func (f *metadataFollows) save(r FollowRequest) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return f.m.SaveMetadata(r.Activity, FollowRequestNamespace, data)
+}
+
+func (f *metadataFollows) pending(actor pub.IRI) ([]pub.IRI, error) {
+	data, err := f.m.LoadMetadata(actor, PendingFollowsNamespace)
+	if errors.Is(err, ErrNotFound) || (err == nil && len(data) == 0) {
+		return []pub.IRI{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	pending := make([]pub.IRI, 0)
+	if err := json.Unmarshal(data, &pending); err != nil {
+		return nil, fmt.Errorf("invalid pending follow requests for %s: %w", actor, err)
+	}
+	return pending, nil
+}
+
+func (f *metadataFollows) savePending(actor pub.IRI, pending []pub.IRI) error {
+	data, err := json.Marshal(pending)
+	if err != nil {
+		return err
+	}
+	return f.m.SaveMetadata(actor, PendingFollowsNamespace, data)
+}
@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+func testFollow(id, actor, object pub.IRI) *pub.Activity {
+	follow := pub.FollowNew(id, object)
+	follow.Actor = actor
+	return follow
+}
+
+func TestFollowRequests(t *testing.T) {
+	requests := FollowRequests(metadataMap{})
+	jdoe := pub.IRI("https://example.com/actors/jdoe")
+	first := testFollow("https://example.org/activities/1", "https://example.org/actors/alice", jdoe)
+	second := testFollow("https://example.org/activities/2", "https://example.org/actors/bob", jdoe)
+
+	if _, err := requests.Create(pub.CreateNew("https://example.org/activities/3", jdoe)); !errors.Is(err, ErrNotValid) {
+		t.Errorf("Create of a Create activity returned %v, expected %s", err, ErrNotValid)
+	}
+	for _, follow := range []*pub.Activity{first, second, first} {
+		r, err := requests.Create(follow)
+		if err != nil {
+			t.Fatalf("Create returned error: %s", err)
+		}
+		if r.Activity != follow.ID || r.Object != jdoe || r.State != FollowPending {
+			t.Errorf("Create returned %v, expected a pending request for %s", r, follow.ID)
+		}
+	}
+	pending, err := requests.ListPending(jdoe)
+	if err != nil {
+		t.Fatalf("ListPending returned error: %s", err)
+	}
+	if len(pending) != 2 || pending[0].Activity != first.ID || pending[1].Activity != second.ID {
+		t.Errorf("ListPending returned %v, expected the two requests, oldest first", pending)
+	}
+
+	if r, err := requests.Accept(first.ID); err != nil || r.State != FollowAccepted || r.Updated.IsZero() {
+		t.Errorf("Accept returned %v, %v, expected an accepted request", r, err)
+	}
+	if _, err := requests.Reject(first.ID); !errors.Is(err, ErrConflict) {
+		t.Errorf("Reject of an accepted request returned %v, expected %s", err, ErrConflict)
+	}
+	if r, err := requests.Reject(second.ID); err != nil || r.State != FollowRejected {
+		t.Errorf("Reject returned %v, %v, expected a rejected request", r, err)
+	}
+	if _, err := requests.Accept("https://example.org/activities/4"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Accept of a missing request returned %v, expected %s", err, ErrNotFound)
+	}
+	if pending, _ := requests.ListPending(jdoe); len(pending) != 0 {
+		t.Errorf("ListPending returned %v, expected no pending requests", pending)
+	}
+	if r, err := requests.Load(first.ID); err != nil || r.State != FollowAccepted || r.Actor != first.Actor.GetLink() {
+		t.Errorf("Load returned %v, %v, expected the accepted request", r, err)
+	}
+}
@@ -0,0 +1,1611 @@
+// Package fs implements a storage backend that keeps each object as a JSON-LD document,
+// in a directory hierarchy mirroring the paths of the objects' IRIs.
+//
+// An object with the "https://example.com/objects/1" IRI is stored in the
+// "<root>/example.com/objects/1/object.json" file, while collections are stored as index files,
+// containing the canonical storage.CollectionDocument, eg: "<root>/example.com/actors/jdoe/outbox/index.json".
+// The metadata of an item is stored in hidden files next to it, one for each namespace, as are its counters
+// and votes, and the previous versions of an object in a hidden directory,
+// eg: "<root>/example.com/objects/1/.versions/00000001.json".
+// The binary data of an object, like the contents of an Image, is stored in a "binary" file next to it.
+// The items whose IRIs have a query, or a fragment, are stored in nested directories named after them,
+// eg: "https://example.com/outbox?page=2" in "<root>/example.com/outbox/%3Fpage=2".
+// The version of this layout is recorded in the "<root>/.schema" file.
+package fs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage"
+)
+
+const (
+	objectFile = "object.json"
+	indexFile  = "index.json"
+	// metadataPrefix is the prefix of the names of the metadata files, which are followed
+	// by the escaped namespace.
+	metadataPrefix = ".metadata."
+	versionsDir    = ".versions"
+	schemaFile     = ".schema"
+	binaryFile     = "binary"
+	// binaryTypeFile holds the MIME type of the binary data.
+	binaryTypeFile = ".binary-type"
+	// countersFile holds the counters of the object, see storage.CounterStore.
+	countersFile = ".counters"
+	// votesFile holds the options each voter has voted for in a Question, see storage.VoteStore.
+	votesFile = ".votes"
+)
+
+// Config holds the options for the filesystem storage.
+type Config struct {
+	// Path is the directory in which the objects are stored.
+	Path string
+	// IDGen generates the IDs of new items, using storage.UUIDs if not set.
+	IDGen storage.IDGenFn
+	// UniqueIDTries makes GenerateID check that the generated IDs aren't already stored, generating
+	// new ones up to this many times, see storage.UniqueIDs. The IDs aren't checked if it's 0.
+	UniqueIDTries int
+	// CaseInsensitivePaths makes the IRIs that differ only by the case of their paths identify the same item,
+	// for peers which aren't consistent about it. The stored objects keep their original IDs.
+	// It can't be changed for an existing storage, as the items are stored under the lower case paths.
+	CaseInsensitivePaths bool
+	// Tombstones makes Delete replace the objects with their Tombstones, as ActivityPub servers are
+	// expected to do, and remove them from the collections referencing them, see storage.TombstoneOf.
+	Tombstones bool
+	// CreateActorCollections enables the creation of the inbox, outbox, followers, following and liked
+	// collections of the actors when they're saved for the first time, see storage.ActorCollections.
+	CreateActorCollections bool
+	// Codec encodes the stored objects, using storage.JSON if not set.
+	// It can't be changed for an existing storage, as the objects are decoded using the same codec.
+	Codec storage.Codec
+	// Compression is the algorithm used for compressing the encoded objects, eg: storage.ZstdCompression.
+	// The objects stored before enabling it, or with another algorithm, are still loaded.
+	Compression storage.Compression
+	// ReadOnly opens an existing storage without modifying it, with all the write operations
+	// returning storage.ErrReadOnly, eg: for maintenance tools, or replicas serving GET requests.
+	ReadOnly bool
+	// Logger reports the stored objects which can't be decoded, and are skipped when loading
+	// collections, or iterating. The messages are discarded if it's not set.
+	Logger storage.Logger
+	// ReportCorrupted makes the loads of collections, and the iterations, which skipped objects that
+	// can't be decoded return a storage.CorruptionError, after the objects that could be decoded.
+	ReportCorrupted bool
+	// Repair is called for each object that can't be decoded, eg: Quarantine. It can run while
+	// the storage is locked for reading, so it must not use its write operations.
+	Repair storage.RepairFn
+	// MergeCounters makes the loads set the totalItems of the collections of the objects from their
+	// counters with the same names, eg: "likes", or "replies", see storage.MergeCounters.
+	MergeCounters bool
+}
+
+type repo struct {
+	path             string
+	idGen            storage.IDGenFn
+	actorCollections bool
+	codec            storage.Codec
+	readOnly         bool
+	l                storage.Logger
+	strict           bool
+	repair           storage.RepairFn
+	mergeCounters    bool
+	foldCase         bool
+	tombstones       bool
+	mu               sync.RWMutex
+	// closed is set to 1 by Close, and read atomically, as itemPath can be called without the lock.
+	closed int32
+}
+
+var (
+	_ storage.Store                         = &repo{}
+	_ storage.CollectionStore               = &repo{}
+	_ storage.IterateStore                  = &repo{}
+	_ storage.ExistsStore                   = &repo{}
+	_ storage.MetadataStore                 = &repo{}
+	_ storage.OrderedCollectionStore        = &repo{}
+	_ storage.MembershipStore               = &repo{}
+	_ storage.UpdateStore                   = &repo{}
+	_ storage.VersionedStore                = &repo{}
+	_ storage.IDGenerator                   = &repo{}
+	_ storage.IDVerifier                    = &repo{}
+	_ storage.SchemaStore                   = &repo{}
+	_ storage.IncrementalBackupStore        = &repo{}
+	_ storage.BinaryStore                   = &repo{}
+	_ storage.PrepareStore                  = &repo{}
+	_ storage.CollectionPageStore           = &repo{}
+	_ storage.CounterStore                  = &repo{}
+	_ storage.VoteStore                     = &repo{}
+	_ storage.BulkDeleteStore               = &repo{}
+	_ storage.ContextStore                  = &repo{}
+	_ storage.ContextCollectionStore        = &repo{}
+	_ storage.ContextIterateStore           = &repo{}
+	_ storage.ContextOrderedCollectionStore = &repo{}
+	_ io.Closer                             = &repo{}
+)
+
+// New returns a filesystem storage rooted in the c.Path directory, which gets created if it doesn't exist.
+// The data of existing storages with an older layout gets upgraded, see Upgrade.
+//
+// In read-only mode the directory needs to exist, and to contain data with the current layout.
+func New(c Config) (*repo, error) {
+	if len(c.Path) == 0 {
+		return nil, fmt.Errorf("missing storage path")
+	}
+	p, err := filepath.Abs(c.Path)
+	if err != nil {
+		return nil, err
+	}
+	if c.ReadOnly {
+		if fi, err := os.Stat(p); err != nil {
+			return nil, err
+		} else if !fi.IsDir() {
+			return nil, fmt.Errorf("%w storage path %s: not a directory", storage.ErrNotValid, p)
+		}
+	} else if err := os.MkdirAll(p, 0o700); err != nil {
+		return nil, err
+	}
+	idGen := c.IDGen
+	if idGen == nil {
+		idGen = storage.UUIDs()
+	}
+	codec := c.Codec
+	if codec == nil {
+		codec = storage.JSON
+	}
+	if codec, err = storage.Compress(codec, c.Compression); err != nil {
+		return nil, err
+	}
+	l := c.Logger
+	if l == nil {
+		l = storage.NopLogger
+	}
+	r := &repo{path: p, idGen: idGen, actorCollections: c.CreateActorCollections, codec: codec, readOnly: c.ReadOnly, l: l}
+	r.strict, r.repair = c.ReportCorrupted, c.Repair
+	r.mergeCounters, r.foldCase, r.tombstones = c.MergeCounters, c.CaseInsensitivePaths, c.Tombstones
+	if c.UniqueIDTries > 0 {
+		r.idGen = storage.UniqueIDs(idGen, r.Exists, c.UniqueIDTries)
+	}
+	if err := r.Upgrade(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Bootstrap prepares the c.Path directory for storing objects, creating it if it's missing,
+// and recording the version of the layout, so the first run of a service doesn't need any manual setup.
+// Opening the storage with New does the same, Bootstrap allows doing it separately, eg: from an install step.
+func Bootstrap(c Config) error {
+	_, err := New(c)
+	return err
+}
+
+// upgrades returns the steps migrating the stored data between the versions of the layout,
+// see storage.RunUpgrades.
+func (r *repo) upgrades() []storage.UpgradeFn {
+	return []storage.UpgradeFn{
+		// the storages created before recording the schema version have the same layout as the first version
+		nil,
+	}
+}
+
+// SchemaVersion returns the version of the layout of the stored data, which is 0 for
+// the storages created before recording it.
+func (r *repo) SchemaVersion() (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.schemaVersion()
+}
+
+func (r *repo) schemaVersion() (int, error) {
+	data, err := os.ReadFile(filepath.Join(r.path, schemaFile))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid %s file: %s", storage.ErrSchemaVersion, schemaFile, err)
+	}
+	return v, nil
+}
+
+// Upgrade migrates the stored data to the current version of the layout.
+// It returns storage.ErrSchemaVersion if the data has been stored by a newer version of the package,
+// and storage.ErrReadOnly if the data needs to be upgraded, but the storage is read-only.
+func (r *repo) Upgrade() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	v, err := r.schemaVersion()
+	if err != nil {
+		return err
+	}
+	if r.readOnly && v < len(r.upgrades()) {
+		return fmt.Errorf("unable to upgrade from schema version %d: %w", v, storage.ErrReadOnly)
+	}
+	return storage.RunUpgrades(v, r.upgrades(), func(v int) error {
+		return writeFile(filepath.Join(r.path, schemaFile), []byte(strconv.Itoa(v)))
+	})
+}
+
+// GenerateID returns a new ID for "it" under the "partOf" collection, using the configured IDGen strategy,
+// which isn't used by any stored item if UniqueIDTries is set.
+func (r *repo) GenerateID(it pub.Item, partOf pub.IRI, by pub.Item) (pub.ID, error) {
+	return r.idGen(it, partOf, by)
+}
+
+// VerifyUniqueIDs returns the IRIs that are stored more than once: the ones stored both as an object and as
+// a collection, and the IDs of the objects found in more than one directory, eg: after copying their files.
+// The files that can't be decoded are skipped.
+func (r *repo) VerifyUniqueIDs() (pub.IRIs, error) {
+	if atomic.LoadInt32(&r.closed) == 1 {
+		return nil, storage.ErrClosed
+	}
+	seen := make(map[pub.IRI]int)
+	dups := make(pub.IRIs, 0)
+	err := filepath.WalkDir(r.path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || (d.Name() != objectFile && d.Name() != indexFile) {
+			return nil
+		}
+		r.mu.RLock()
+		iri, err := r.storedID(p)
+		r.mu.RUnlock()
+		if os.IsNotExist(err) || len(iri) == 0 {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if seen[iri]++; seen[iri] == 2 {
+			dups = append(dups, iri)
+		}
+		return nil
+	})
+	return dups, err
+}
+
+// storedID returns the IRI of the object or of the collection stored in the "name" file.
+// It needs to be called with the lock held.
+func (r *repo) storedID(name string) (pub.IRI, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+	var it pub.Item
+	if filepath.Base(name) == indexFile {
+		it, err = storage.UnmarshalCollection(data)
+	} else {
+		it, err = r.codec.Unmarshal(data)
+	}
+	if err != nil || pub.IsNil(it) {
+		return "", nil
+	}
+	return it.GetLink(), nil
+}
+
+// Close closes the storage. After it, the operations on stored items return storage.ErrClosed.
+func (r *repo) Close() error {
+	atomic.StoreInt32(&r.closed, 1)
+	return nil
+}
+
+// itemPath returns the directory corresponding to "iri".
+// It returns storage.ErrClosed after the storage has been closed, which all the operations on items go through.
+//
+// The query and the fragment of the IRI are stored as additional path segments, escaped as they would be
+// in a path, and prefixed with their escaped separators, eg: "https://example.com/outbox?page=2" is stored in
+// "<root>/example.com/outbox/%3Fpage=2", so they don't overwrite the item without them.
+func (r *repo) itemPath(iri pub.IRI) (string, error) {
+	if atomic.LoadInt32(&r.closed) == 1 {
+		return "", storage.ErrClosed
+	}
+	if r.foldCase {
+		iri = storage.FoldPathCase(iri)
+	}
+	u, err := url.Parse(iri.String())
+	if err != nil {
+		return "", fmt.Errorf("%w IRI %s: %s", storage.ErrNotValid, iri, err)
+	}
+	if len(u.Host) == 0 {
+		return "", fmt.Errorf("%w IRI %q: missing host", storage.ErrNotValid, iri)
+	}
+	// the host is used as a path segment, so it can't be one that would escape the root
+	if u.Host == "." || u.Host == ".." || strings.ContainsAny(u.Host, `/\`) || strings.ContainsRune(u.Host, filepath.Separator) {
+		return "", fmt.Errorf("%w IRI %q: invalid host", storage.ErrNotValid, iri)
+	}
+	// cleaning the path as an absolute one removes any ".." elements that would escape the root
+	p := path.Clean("/" + u.Path)
+	if len(u.RawQuery) > 0 || u.ForceQuery {
+		p = path.Join(p, "%3F"+url.PathEscape(u.RawQuery))
+	}
+	if len(u.Fragment) > 0 {
+		p = path.Join(p, "%23"+url.PathEscape(u.Fragment))
+	}
+	return filepath.Join(r.path, u.Host, filepath.FromSlash(p)), nil
+}
+
+func notFound(iri pub.IRI) error {
+	return fmt.Errorf("unable to find %s: %w", iri, storage.ErrNotFound)
+}
+
+// Exists reports if "iri" is stored, either as an object or as a collection, without reading it.
+func (r *repo) Exists(iri pub.IRI) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	p, err := r.itemPath(iri)
+	if err != nil {
+		return false, err
+	}
+	for _, name := range []string{objectFile, indexFile} {
+		_, err := os.Stat(filepath.Join(p, name))
+		if err == nil {
+			return true, nil
+		}
+		if !os.IsNotExist(err) {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// Load returns the object, or the collection with its members, identified by "iri".
+func (r *repo) Load(iri pub.IRI) (pub.Item, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	p, err := r.itemPath(iri)
+	if err != nil {
+		return nil, err
+	}
+	// the IRIs of collections are looked up first as collections, and the others as objects,
+	// which saves reading a missing file for most loads, see storage.BucketFor
+	collection := storage.BucketFor(iri) == storage.BucketCollections
+	if !collection {
+		if it, err := r.loadObject(iri); !os.IsNotExist(err) {
+			return r.object(it, err)
+		}
+	}
+	if col, err := r.loadCollection(p); err == nil {
+		errs := &storage.CorruptionError{}
+		items := col.Collection()
+		for i, it := range items {
+			ob, err := r.loadObject(it.GetLink())
+			if err == nil {
+				items[i] = r.counted(ob)
+			}
+			r.corrupted(errs, err)
+		}
+		return col, r.reported(errs)
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	if !collection {
+		return nil, notFound(iri)
+	}
+	it, err := r.loadObject(iri)
+	if os.IsNotExist(err) {
+		return nil, notFound(iri)
+	}
+	return r.object(it, err)
+}
+
+// object returns the result of loading the "it" object, which had the "err" error.
+func (r *repo) object(it pub.Item, err error) (pub.Item, error) {
+	r.corrupted(nil, err)
+	if err != nil {
+		return nil, err
+	}
+	return r.counted(it), nil
+}
+
+func (r *repo) loadObject(iri pub.IRI) (pub.Item, error) {
+	p, err := r.itemPath(iri)
+	if err != nil {
+		return nil, err
+	}
+	name := filepath.Join(p, objectFile)
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	it, err := r.codec.Unmarshal(data)
+	if err != nil {
+		return nil, &storage.CorruptedItemError{IRI: iri, Path: name, Err: err}
+	}
+	return it, nil
+}
+
+func (r *repo) loadCollection(p string) (pub.CollectionInterface, error) {
+	data, err := os.ReadFile(filepath.Join(p, indexFile))
+	if err != nil {
+		return nil, err
+	}
+	return storage.UnmarshalCollection(data)
+}
+
+func (r *repo) saveCollection(p string, col pub.CollectionInterface) error {
+	data, err := storage.MarshalCollection(col)
+	if err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(p, indexFile), data)
+}
+
+// writeFile replaces the contents of the "name" file atomically, by writing them to a temporary
+// file first, and then moving it in place.
+func writeFile(name string, data []byte) error {
+	tmp, err := writeTemp(filepath.Dir(name), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	return os.Rename(tmp, name)
+}
+
+// writeTemp writes the data read from "r" to a new temporary file in the "dir" directory,
+// and returns its name. The caller needs to move, or remove, the file.
+func writeTemp(dir string, r io.Reader) (string, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// Save saves "it" as a JSON-LD document, or as an index file if it's a collection.
+// The item needs to have an ID.
+func (r *repo) Save(it pub.Item) (pub.Item, error) {
+	if r.readOnly {
+		return nil, storage.ErrReadOnly
+	}
+	if pub.IsNil(it) {
+		return nil, fmt.Errorf("%w: unable to save nil item", storage.ErrNotValid)
+	}
+	if len(it.GetLink()) == 0 {
+		return nil, fmt.Errorf("%w: unable to save %s item without an ID", storage.ErrNotValid, it.GetType())
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, err := r.itemPath(it.GetLink())
+	if err != nil {
+		return nil, err
+	}
+	if pub.CollectionTypes.Contains(it.GetType()) {
+		col, ok := it.(pub.CollectionInterface)
+		if !ok {
+			return nil, fmt.Errorf("%w collection %T", storage.ErrNotValid, it)
+		}
+		return it, r.saveCollection(p, col)
+	}
+	var cols []pub.CollectionInterface
+	if r.actorCollections && pub.ActorTypes.Contains(it.GetType()) {
+		if _, err := os.Stat(filepath.Join(p, objectFile)); os.IsNotExist(err) {
+			if cols, err = storage.ActorCollections(it); err != nil {
+				return nil, err
+			}
+		}
+	}
+	data, err := r.codec.Marshal(it)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeObject(p, data); err != nil {
+		return nil, err
+	}
+	for _, col := range cols {
+		cp, err := r.itemPath(col.GetLink())
+		if err != nil {
+			return nil, err
+		}
+		if _, err := r.loadCollection(cp); err == nil {
+			continue
+		}
+		if err := r.saveCollection(cp, col); err != nil {
+			return nil, err
+		}
+	}
+	return it, nil
+}
+
+// writeObject saves "data" as the JSON-LD document of the object stored in the "p" directory,
+// after copying its current version, if any, to the versions directory.
+func writeObject(p string, data []byte) error {
+	name := filepath.Join(p, objectFile)
+	old, err := os.ReadFile(name)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		dir := filepath.Join(p, versionsDir)
+		versions, err := os.ReadDir(dir)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err := writeFile(filepath.Join(dir, fmt.Sprintf("%08d.json", len(versions)+1)), old); err != nil {
+			return err
+		}
+	}
+	return writeFile(name, data)
+}
+
+// LoadVersions returns the previous versions of the "iri" object, most recent first.
+func (r *repo) LoadVersions(iri pub.IRI) (pub.ItemCollection, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	p, err := r.itemPath(iri)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(filepath.Join(p, objectFile)); os.IsNotExist(err) {
+		return nil, notFound(iri)
+	}
+	versions, err := os.ReadDir(filepath.Join(p, versionsDir))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	items := make(pub.ItemCollection, 0, len(versions))
+	// the names of the versions are zero padded sequence numbers, so they sort in the order they've been saved
+	for i := len(versions) - 1; i >= 0; i-- {
+		data, err := os.ReadFile(filepath.Join(p, versionsDir, versions[i].Name()))
+		if err != nil {
+			return nil, err
+		}
+		it, err := r.codec.Unmarshal(data)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	return items, nil
+}
+
+// Update saves "it" if the stored version has the "expected" revision, see storage.UpdateStore.
+func (r *repo) Update(it pub.Item, expected time.Time) (pub.Item, error) {
+	if r.readOnly {
+		return nil, storage.ErrReadOnly
+	}
+	if pub.IsNil(it) || pub.CollectionTypes.Contains(it.GetType()) {
+		return nil, fmt.Errorf("%w: unable to update %T", storage.ErrNotValid, it)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, err := r.itemPath(it.GetLink())
+	if err != nil {
+		return nil, err
+	}
+	stored, err := r.loadObject(it.GetLink())
+	if os.IsNotExist(err) {
+		return nil, notFound(it.GetLink())
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := storage.CheckRevision(stored, expected); err != nil {
+		return nil, err
+	}
+	if err := storage.SetRevision(it, stored, time.Now()); err != nil {
+		return nil, err
+	}
+	data, err := r.codec.Marshal(it)
+	if err != nil {
+		return nil, err
+	}
+	return it, writeObject(p, data)
+}
+
+// Delete removes the "it" object, or collection, from the storage.
+// The directories of the objects that have nested items, like the collections of an actor, are kept.
+//
+// With the Tombstones option, the objects are replaced with their Tombstones instead, and removed from
+// the collections referencing them, which requires reading the index of every collection.
+func (r *repo) Delete(it pub.Item) error {
+	if r.readOnly {
+		return storage.ErrReadOnly
+	}
+	if pub.IsNil(it) {
+		return nil
+	}
+	if r.tombstones {
+		t, err := storage.TombstoneOf(r, it.GetLink(), time.Now())
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			return nil
+		case errors.Is(err, storage.ErrNotValid):
+			// the collections don't have Tombstones, they get removed
+		case err != nil:
+			return err
+		default:
+			if err := r.bury(t, false, t.Deleted); err != nil {
+				return err
+			}
+			return r.unreference(map[pub.IRI]struct{}{t.ID: {}})
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, err := r.itemPath(it.GetLink())
+	if err != nil {
+		return err
+	}
+	return remove(p)
+}
+
+// remove removes the files of the item stored in the "p" directory, and the directory, if it's empty.
+func remove(p string) error {
+	names := []string{
+		filepath.Join(p, objectFile), filepath.Join(p, indexFile),
+		filepath.Join(p, binaryFile), filepath.Join(p, binaryTypeFile),
+		filepath.Join(p, countersFile), filepath.Join(p, votesFile),
+	}
+	metadata, _ := filepath.Glob(filepath.Join(p, metadataPrefix+"*"))
+	for _, name := range append(names, metadata...) {
+		if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.RemoveAll(filepath.Join(p, versionsDir)); err != nil {
+		return err
+	}
+	// this fails, on purpose, when the directory is not empty
+	os.Remove(p)
+	return nil
+}
+
+// DeleteMatching replaces all the objects matching "f" with Tombstones, or removes them if "f" requests it,
+// and removes them from all the collections, see storage.BulkDeleteStore.
+// The matching objects are found first, then they are deleted one at a time, and then removed from
+// the collections in a single pass over them, so the storage isn't locked for the whole operation.
+func (r *repo) DeleteMatching(f storage.Filterable) (uint, error) {
+	if r.readOnly {
+		return 0, storage.ErrReadOnly
+	}
+	matching := make(pub.ItemCollection, 0)
+	err := r.Each(f, func(it pub.Item) error {
+		if it.GetType() != pub.TombstoneType {
+			matching = append(matching, it)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, storage.ErrCorrupted) {
+		return 0, err
+	}
+	purge := storage.Purges(f)
+	now := time.Now()
+	deleted := make(map[pub.IRI]struct{}, len(matching))
+	for _, it := range matching {
+		if err := r.bury(it, purge, now); err != nil {
+			return uint(len(deleted)), err
+		}
+		deleted[it.GetLink()] = struct{}{}
+	}
+	return uint(len(deleted)), r.unreference(deleted)
+}
+
+// bury replaces the stored "it" object with its Tombstone, or removes it, if "purge" is set.
+// Either way, none of the stored files of the object are kept.
+func (r *repo) bury(it pub.Item, purge bool, deleted time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, err := r.itemPath(it.GetLink())
+	if err != nil {
+		return err
+	}
+	if err := remove(p); err != nil {
+		return err
+	}
+	if purge {
+		return nil
+	}
+	data, err := r.codec.Marshal(storage.Tombstone(it, deleted))
+	if err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(p, objectFile), data)
+}
+
+// unreference removes the "iris" from all the stored collections.
+func (r *repo) unreference(iris map[pub.IRI]struct{}) error {
+	if len(iris) == 0 {
+		return nil
+	}
+	return filepath.WalkDir(r.path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || d.Name() != indexFile {
+			return nil
+		}
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		dir := filepath.Dir(p)
+		col, err := r.loadCollection(dir)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		removed := make(pub.IRIs, 0)
+		for _, member := range col.Collection() {
+			if _, ok := iris[member.GetLink()]; ok {
+				removed = append(removed, member.GetLink())
+			}
+		}
+		if len(removed) == 0 {
+			return nil
+		}
+		for _, iri := range removed {
+			switch c := col.(type) {
+			case *pub.OrderedCollection:
+				c.OrderedItems.Remove(iri)
+			case *pub.Collection:
+				c.Items.Remove(iri)
+			}
+		}
+		return r.saveCollection(dir, col)
+	})
+}
+
+// SaveBinary stores the data read from "rd" in the binary file of the "iri" item, which gets
+// written to a temporary file first, so the storage is not locked while reading it.
+func (r *repo) SaveBinary(iri pub.IRI, rd io.Reader, contentType string) error {
+	if r.readOnly {
+		return storage.ErrReadOnly
+	}
+	p, err := r.itemPath(iri)
+	if err != nil {
+		return err
+	}
+	tmp, err := writeTemp(p, rd)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := writeFile(filepath.Join(p, binaryTypeFile), []byte(contentType)); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(p, binaryFile))
+}
+
+// LoadBinary opens the binary file of the "iri" item, and returns it with its MIME type.
+func (r *repo) LoadBinary(iri pub.IRI) (io.ReadCloser, string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	p, err := r.itemPath(iri)
+	if err != nil {
+		return nil, "", err
+	}
+	f, err := os.Open(filepath.Join(p, binaryFile))
+	if os.IsNotExist(err) {
+		return nil, "", fmt.Errorf("unable to find binary data for %s: %w", iri, storage.ErrNotFound)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	contentType, err := os.ReadFile(filepath.Join(p, binaryTypeFile))
+	if err != nil && !os.IsNotExist(err) {
+		f.Close()
+		return nil, "", err
+	}
+	return f, string(contentType), nil
+}
+
+// DeleteBinary removes the binary file of the "iri" item.
+func (r *repo) DeleteBinary(iri pub.IRI) error {
+	if r.readOnly {
+		return storage.ErrReadOnly
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, err := r.itemPath(iri)
+	if err != nil {
+		return err
+	}
+	for _, name := range []string{binaryFile, binaryTypeFile} {
+		if err := os.Remove(filepath.Join(p, name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	// this fails, on purpose, when the directory is not empty
+	os.Remove(p)
+	return nil
+}
+
+// IncrementCounter adds "delta" to the "field" counter of the "iri" object and returns the new value.
+// The counters of an object are stored together in its counters file, which is rewritten with the storage locked.
+func (r *repo) IncrementCounter(iri pub.IRI, field string, delta int) (int, error) {
+	if r.readOnly {
+		return 0, storage.ErrReadOnly
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, err := r.itemPath(iri)
+	if err != nil {
+		return 0, err
+	}
+	counters, err := loadCounters(p)
+	if err != nil {
+		return 0, err
+	}
+	counters[field] += delta
+	data, err := json.Marshal(counters)
+	if err != nil {
+		return 0, err
+	}
+	return counters[field], writeFile(filepath.Join(p, countersFile), data)
+}
+
+// LoadCounters returns the counters of the "iri" object.
+func (r *repo) LoadCounters(iri pub.IRI) (map[string]int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	p, err := r.itemPath(iri)
+	if err != nil {
+		return nil, err
+	}
+	return loadCounters(p)
+}
+
+func loadCounters(p string) (map[string]int, error) {
+	counters := make(map[string]int)
+	data, err := os.ReadFile(filepath.Join(p, countersFile))
+	if os.IsNotExist(err) {
+		return counters, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &counters); err != nil {
+		return nil, fmt.Errorf("%w counters file in %s: %s", storage.ErrNotValid, p, err)
+	}
+	return counters, nil
+}
+
+// counted returns "it" with the counters merged into its collections, if the storage is configured to do so.
+// It needs to be called with the lock held.
+func (r *repo) counted(it pub.Item) pub.Item {
+	if !r.mergeCounters || pub.IsNil(it) {
+		return it
+	}
+	p, err := r.itemPath(it.GetLink())
+	if err != nil {
+		return it
+	}
+	counters, err := loadCounters(p)
+	if err != nil {
+		r.l.Warn("unable to load counters", storage.Fields{"iri": it.GetLink(), "err": err})
+		return it
+	}
+	return storage.MergeCounters(it, counters)
+}
+
+// RecordVote saves the vote of "voter" for "option" in the "question" poll, which needs to be stored.
+func (r *repo) RecordVote(question pub.IRI, voter pub.IRI, option string) error {
+	if r.readOnly {
+		return storage.ErrReadOnly
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	q, err := r.loadObject(question)
+	if os.IsNotExist(err) {
+		return notFound(question)
+	}
+	if err != nil {
+		return err
+	}
+	if err := storage.ValidateVote(q, option); err != nil {
+		return err
+	}
+	_, multiple := storage.PollOptions(q)
+
+	p, err := r.itemPath(question)
+	if err != nil {
+		return err
+	}
+	votes, err := loadVotes(p)
+	if err != nil {
+		return err
+	}
+	for _, voted := range votes[voter] {
+		if !multiple || voted == option {
+			return storage.ErrDuplicateVote
+		}
+	}
+	votes[voter] = append(votes[voter], option)
+	data, err := json.Marshal(votes)
+	if err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(p, votesFile), data)
+}
+
+// PollResults returns the number of votes for each option of the "question" poll.
+func (r *repo) PollResults(question pub.IRI) (map[string]uint, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	p, err := r.itemPath(question)
+	if err != nil {
+		return nil, err
+	}
+	results := make(map[string]uint)
+	if q, err := r.loadObject(question); err == nil {
+		options, _ := storage.PollOptions(q)
+		for _, o := range options {
+			results[o] = 0
+		}
+	}
+	votes, err := loadVotes(p)
+	if err != nil {
+		return nil, err
+	}
+	for _, options := range votes {
+		for _, o := range options {
+			results[o]++
+		}
+	}
+	return results, nil
+}
+
+func loadVotes(p string) (map[pub.IRI][]string, error) {
+	votes := make(map[pub.IRI][]string)
+	data, err := os.ReadFile(filepath.Join(p, votesFile))
+	if os.IsNotExist(err) {
+		return votes, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &votes); err != nil {
+		return nil, fmt.Errorf("%w votes file in %s: %s", storage.ErrNotValid, p, err)
+	}
+	return votes, nil
+}
+
+func metadataFile(namespace string) string {
+	return metadataPrefix + url.PathEscape(namespace)
+}
+
+// LoadMetadata returns the "namespace" metadata of the "iri" item.
+func (r *repo) LoadMetadata(iri pub.IRI, namespace string) ([]byte, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	p, err := r.itemPath(iri)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(p, metadataFile(namespace)))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("unable to find %s metadata for %s: %w", namespace, iri, storage.ErrNotFound)
+	}
+	return data, err
+}
+
+// SaveMetadata saves "data" as the "namespace" metadata of the "iri" item, in a file readable
+// only by the owner.
+func (r *repo) SaveMetadata(iri pub.IRI, namespace string, data []byte) error {
+	if r.readOnly {
+		return storage.ErrReadOnly
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, err := r.itemPath(iri)
+	if err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(p, metadataFile(namespace)), data)
+}
+
+// LoadCollection returns the members of the "iri" collection matching "f", most recent first.
+func (r *repo) LoadCollection(iri pub.IRI, f storage.Filterable) (pub.ItemCollection, string, error) {
+	p, err := r.itemPath(iri)
+	if err != nil {
+		return nil, "", err
+	}
+	return r.loadMembers(context.Background(), p, iri, f)
+}
+
+// LoadCollectionContext returns the members of the "iri" collection matching "f", most recent first,
+// see storage.ContextOrderedCollectionStore.
+// When "ctx" is done, the result is limited to the members that have been read before reaching that point.
+func (r *repo) LoadCollectionContext(ctx context.Context, iri pub.IRI, f storage.Filterable) (pub.ItemCollection, string, error) {
+	p, err := r.itemPath(iri)
+	if err != nil {
+		return nil, "", err
+	}
+	return r.loadMembers(ctx, p, iri, f)
+}
+
+// loadMembers returns the members, matching "f", of the "iri" collection stored in the "p" directory.
+func (r *repo) loadMembers(ctx context.Context, p string, iri pub.IRI, f storage.Filterable) (pub.ItemCollection, string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if atomic.LoadInt32(&r.closed) == 1 {
+		return nil, "", storage.ErrClosed
+	}
+	col, err := r.loadCollection(p)
+	if os.IsNotExist(err) {
+		return nil, "", notFound(iri)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	rf, _ := f.(storage.FilterableRaw)
+	errs := &storage.CorruptionError{}
+	members := make(pub.ItemCollection, 0, col.Count())
+	for _, member := range col.Collection() {
+		if err := ctx.Err(); err != nil {
+			if !storage.AllowsPartial(f) {
+				return nil, "", err
+			}
+			members, next, oerr := storage.OrderMembers(members, f)
+			if oerr != nil {
+				return nil, "", oerr
+			}
+			return members, next, storage.Partial(err)
+		}
+		if storage.SkipIRI(f, member.GetLink()) {
+			continue
+		}
+		mp, err := r.itemPath(member.GetLink())
+		if err != nil {
+			continue
+		}
+		name := filepath.Join(mp, objectFile)
+		data, err := os.ReadFile(name)
+		if err != nil {
+			members = append(members, member.GetLink())
+			continue
+		}
+		if rf != nil {
+			doc, err := storage.RawJSON(r.codec, data)
+			if err != nil {
+				r.corrupted(errs, &storage.CorruptedItemError{IRI: member.GetLink(), Path: name, Err: err})
+				continue
+			}
+			if !storage.MatchRaw(doc, rf.RawFilters()...) {
+				continue
+			}
+		}
+		it, err := r.codec.Unmarshal(data)
+		if err != nil {
+			r.corrupted(errs, &storage.CorruptedItemError{IRI: member.GetLink(), Path: name, Err: err})
+			continue
+		}
+		members = append(members, r.counted(it))
+	}
+	members, next, err := storage.OrderMembers(members, f)
+	if err != nil {
+		return members, next, err
+	}
+	return members, next, r.reported(errs)
+}
+
+// LoadCollectionPage returns the page of the collection requested by "p", most recent first.
+// Only the index of the collection, whose totalItems is updated together with its members,
+// and the objects of the page are read. As the sort keys of the objects aren't maintained,
+// the other orders than the default one need to read all the members.
+func (r *repo) LoadCollectionPage(p storage.FilterablePage) (*pub.OrderedCollectionPage, error) {
+	iri := p.GetLink()
+	cp, err := r.itemPath(iri)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if atomic.LoadInt32(&r.closed) == 1 {
+		return nil, storage.ErrClosed
+	}
+	col, err := r.loadCollection(cp)
+	if os.IsNotExist(err) {
+		return nil, notFound(iri)
+	}
+	if err != nil {
+		return nil, err
+	}
+	items := col.Collection()
+	members := make(pub.IRIs, 0, len(items))
+	for i := len(items) - 1; i >= 0; i-- {
+		if !pub.IsNil(items[i]) {
+			members = append(members, items[i].GetLink())
+		}
+	}
+	total := uint(len(members))
+	if oc, ok := col.(*pub.OrderedCollection); ok {
+		total = oc.TotalItems
+	} else if c, ok := col.(*pub.Collection); ok {
+		total = c.TotalItems
+	}
+	if o := storage.SortOrderOf(p); o != (storage.SortOrder{}) {
+		storage.SortIRIs(members, o, func(member pub.IRI) string {
+			mp, err := r.itemPath(member)
+			if err != nil {
+				return ""
+			}
+			data, err := os.ReadFile(filepath.Join(mp, objectFile))
+			if err != nil {
+				return ""
+			}
+			it, _ := r.codec.Unmarshal(data)
+			return storage.SortKeyOf(it, o.By)
+		})
+	}
+	page, err := storage.CollectionPage(iri, members, total, p)
+	if err != nil {
+		return nil, err
+	}
+	errs := &storage.CorruptionError{}
+	for i, member := range page.OrderedItems {
+		mp, err := r.itemPath(member.GetLink())
+		if err != nil {
+			continue
+		}
+		name := filepath.Join(mp, objectFile)
+		data, err := os.ReadFile(name)
+		if err != nil {
+			continue
+		}
+		it, err := r.codec.Unmarshal(data)
+		if err != nil {
+			r.corrupted(errs, &storage.CorruptedItemError{IRI: member.GetLink(), Path: name, Err: err})
+			continue
+		}
+		page.OrderedItems[i] = r.counted(it)
+	}
+	return page, r.reported(errs)
+}
+
+// Create creates the "col" collection, if it doesn't exist already.
+func (r *repo) Create(col pub.CollectionInterface) (pub.CollectionInterface, error) {
+	if r.readOnly {
+		return nil, storage.ErrReadOnly
+	}
+	if pub.IsNil(col) || len(col.GetLink()) == 0 {
+		return nil, fmt.Errorf("%w: unable to create collection without an ID", storage.ErrNotValid)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, err := r.itemPath(col.GetLink())
+	if err != nil {
+		return nil, err
+	}
+	if existing, err := r.loadCollection(p); err == nil {
+		return existing, nil
+	}
+	return col, r.saveCollection(p, col)
+}
+
+// AddTo appends "it" to the "col" collection. Adding an item that is already in the collection is a no-op.
+func (r *repo) AddTo(col pub.IRI, it pub.Item) error {
+	if pub.IsNil(it) {
+		return fmt.Errorf("%w: unable to add nil item to %s", storage.ErrNotValid, col)
+	}
+	return r.updateCollection(col, func(c pub.CollectionInterface) error {
+		if c.Contains(it.GetLink()) {
+			return nil
+		}
+		return c.Append(it.GetLink())
+	})
+}
+
+// IsMember reports if "it" is a member of the "col" collection, reading only its index.
+func (r *repo) IsMember(col pub.IRI, it pub.Item) (bool, error) {
+	if pub.IsNil(it) {
+		return false, fmt.Errorf("%w: unable to check the membership of nil item in %s", storage.ErrNotValid, col)
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	p, err := r.itemPath(col)
+	if err != nil {
+		return false, err
+	}
+	c, err := r.loadCollection(p)
+	if os.IsNotExist(err) {
+		return false, notFound(col)
+	}
+	if err != nil {
+		return false, err
+	}
+	return c.Contains(it.GetLink()), nil
+}
+
+// RemoveFrom removes "it" from the "col" collection.
+func (r *repo) RemoveFrom(col pub.IRI, it pub.Item) error {
+	if pub.IsNil(it) {
+		return fmt.Errorf("%w: unable to remove nil item from %s", storage.ErrNotValid, col)
+	}
+	return r.updateCollection(col, func(c pub.CollectionInterface) error {
+		switch cc := c.(type) {
+		case *pub.OrderedCollection:
+			cc.OrderedItems.Remove(it.GetLink())
+		case *pub.Collection:
+			cc.Items.Remove(it.GetLink())
+		}
+		return nil
+	})
+}
+
+func (r *repo) updateCollection(col pub.IRI, fn func(pub.CollectionInterface) error) error {
+	if r.readOnly {
+		return storage.ErrReadOnly
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, err := r.itemPath(col)
+	if err != nil {
+		return err
+	}
+	c, err := r.loadCollection(p)
+	if os.IsNotExist(err) {
+		return notFound(col)
+	}
+	if err != nil {
+		return err
+	}
+	if err := fn(c); err != nil {
+		return err
+	}
+	return r.saveCollection(p, c)
+}
+
+// Each calls "fn" for every object matching "f", see storage.IterateStore.
+// The objects are read one at a time, so "fn" can modify the storage.
+func (r *repo) Each(f storage.Filterable, fn func(pub.Item) error) error {
+	return r.EachContext(context.Background(), f, fn)
+}
+
+// EachContext calls "fn" for every object matching "f", until "ctx" is done, see storage.ContextIterateStore.
+func (r *repo) EachContext(ctx context.Context, f storage.Filterable, fn func(pub.Item) error) error {
+	p, err := r.dir(f)
+	if err != nil {
+		return err
+	}
+	errs := &storage.CorruptionError{}
+	if err := r.iterateIn(ctx, p, f, fn, errs); err != nil {
+		return err
+	}
+	return r.reported(errs)
+}
+
+// dir returns the directory holding the items matching "f", which is the root of the storage
+// if "f" doesn't have an IRI.
+func (r *repo) dir(f storage.Filterable) (string, error) {
+	if len(f.GetLink()) == 0 {
+		if atomic.LoadInt32(&r.closed) == 1 {
+			return "", storage.ErrClosed
+		}
+		return r.path, nil
+	}
+	return r.itemPath(f.GetLink())
+}
+
+// iterateIn calls "fn" for the members matching "f" of the collection stored in the "p" directory,
+// or, if it's not a collection, for the objects matching "f" stored in its hierarchy.
+func (r *repo) iterateIn(ctx context.Context, p string, f storage.Filterable, fn func(pub.Item) error, errs *storage.CorruptionError) error {
+	if atomic.LoadInt32(&r.closed) == 1 {
+		return storage.ErrClosed
+	}
+	r.mu.RLock()
+	col, err := r.loadCollection(p)
+	r.mu.RUnlock()
+	if os.IsNotExist(err) {
+		return r.walk(ctx, p, f, fn, errs)
+	}
+	if err != nil {
+		return err
+	}
+	for _, member := range col.Collection() {
+		if err := ctx.Err(); err != nil {
+			return storage.Interrupted(f, err)
+		}
+		mp, err := r.itemPath(member.GetLink())
+		if err != nil {
+			continue
+		}
+		if err := r.each(filepath.Join(mp, objectFile), f, fn, errs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Prepare returns a storage.Plan for "f" which resolves once the directory of its items.
+func (r *repo) Prepare(f storage.Filterable) (storage.Plan, error) {
+	p, err := r.dir(f)
+	if err != nil {
+		return nil, err
+	}
+	return &plan{r: r, f: f, dir: p}, nil
+}
+
+type plan struct {
+	r   *repo
+	f   storage.Filterable
+	dir string
+}
+
+func (p *plan) Each(fn func(pub.Item) error) error {
+	errs := &storage.CorruptionError{}
+	if err := p.r.iterateIn(context.Background(), p.dir, p.f, fn, errs); err != nil {
+		return err
+	}
+	return p.r.reported(errs)
+}
+
+func (p *plan) Page(max int, cursor string) (pub.ItemCollection, string, error) {
+	items, _, err := p.r.loadMembers(context.Background(), p.dir, p.f.GetLink(), p.f)
+	if err != nil && !errors.Is(err, storage.ErrCorrupted) {
+		return nil, "", err
+	}
+	page, next, perr := storage.Paginate(items, storage.Page{IRI: p.f.GetLink(), Max: max, After: cursor})
+	if perr != nil {
+		return nil, "", perr
+	}
+	return page, next, err
+}
+
+// walk calls "fn" for the objects matching "f" stored in the "root" directory hierarchy.
+func (r *repo) walk(ctx context.Context, root string, f storage.Filterable, fn func(pub.Item) error, errs *storage.CorruptionError) error {
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || d.Name() != objectFile {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return storage.Interrupted(f, err)
+		}
+		return r.each(p, f, fn, errs)
+	})
+}
+
+// each calls "fn" for the object stored in the "name" file, if it matches "f".
+// Missing files are skipped, as they might have been deleted during the iteration.
+func (r *repo) each(name string, f storage.Filterable, fn func(pub.Item) error, errs *storage.CorruptionError) error {
+	r.mu.RLock()
+	data, err := os.ReadFile(name)
+	r.mu.RUnlock()
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	it, ok, err := storage.DecodeMatching(f, r.codec, data)
+	if err != nil {
+		r.corrupted(errs, &storage.CorruptedItemError{Path: name, Err: err})
+		return nil
+	}
+	if ok {
+		return fn(it)
+	}
+	return nil
+}
+
+// corrupted logs and repairs the object that couldn't be decoded, if "err" is a storage.CorruptedItemError,
+// and adds it to "errs", if it's not nil.
+func (r *repo) corrupted(errs *storage.CorruptionError, err error) {
+	ce := (*storage.CorruptedItemError)(nil)
+	if !errors.As(err, &ce) {
+		return
+	}
+	r.l.Warn("skipping object which can't be decoded", storage.Fields{"iri": ce.IRI, "file": ce.Path, "err": ce.Err})
+	if r.repair != nil {
+		if err := r.repair(ce); err != nil {
+			r.l.Error("unable to repair corrupted object", storage.Fields{"file": ce.Path, "err": err})
+		}
+	}
+	if errs != nil {
+		errs.Add(ce)
+	}
+}
+
+// reported returns "errs", if the corrupted objects need to be reported, and there are any.
+func (r *repo) reported(errs *storage.CorruptionError) error {
+	if !r.strict {
+		return nil
+	}
+	return errs.Err()
+}
+
+// Quarantine returns a storage.RepairFn moving the files of the corrupted objects into the "dir" directory,
+// so they stop being skipped on every load, and they can be inspected.
+func Quarantine(dir string) storage.RepairFn {
+	return func(ce *storage.CorruptedItemError) error {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return err
+		}
+		h := fnv.New64a()
+		h.Write([]byte(ce.Path))
+		err := os.Rename(ce.Path, filepath.Join(dir, fmt.Sprintf("%016x-%s", h.Sum64(), filepath.Base(ce.Path))))
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+}
+
+// BackupSince writes to "w", in the storage.Export format, the objects, followed by the collections,
+// whose files have been modified at, or after, the "since" moment.
+// The storage isn't locked for the whole backup, so the items modified while it runs might be missing.
+func (r *repo) BackupSince(w io.Writer, since time.Time) error {
+	if atomic.LoadInt32(&r.closed) == 1 {
+		return storage.ErrClosed
+	}
+	bw := bufio.NewWriter(w)
+	indexes := make([]string, 0)
+	err := filepath.WalkDir(r.path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || (d.Name() != objectFile && d.Name() != indexFile) {
+			return nil
+		}
+		fi, err := d.Info()
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if fi.ModTime().Before(since) {
+			return nil
+		}
+		if d.Name() == indexFile {
+			indexes = append(indexes, filepath.Dir(p))
+			return nil
+		}
+		r.mu.RLock()
+		data, err := os.ReadFile(p)
+		r.mu.RUnlock()
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		it, err := r.codec.Unmarshal(data)
+		if err != nil {
+			return fmt.Errorf("unable to decode %s: %w", p, err)
+		}
+		return storage.WriteExport(bw, it)
+	})
+	if err != nil {
+		return err
+	}
+	for _, p := range indexes {
+		r.mu.RLock()
+		col, err := r.loadCollection(p)
+		r.mu.RUnlock()
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := storage.WriteExport(bw, col); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// The context aware methods of the operations on single items check that the context is still valid,
+// as the files they read, or write, are small enough not to need interrupting.
+
+func (r *repo) LoadContext(ctx context.Context, iri pub.IRI) (pub.Item, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.Load(iri)
+}
+
+func (r *repo) SaveContext(ctx context.Context, it pub.Item) (pub.Item, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.Save(it)
+}
+
+func (r *repo) DeleteContext(ctx context.Context, it pub.Item) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return r.Delete(it)
+}
+
+func (r *repo) CreateContext(ctx context.Context, col pub.CollectionInterface) (pub.CollectionInterface, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.Create(col)
+}
+
+func (r *repo) AddToContext(ctx context.Context, col pub.IRI, it pub.Item) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return r.AddTo(col, it)
+}
+
+func (r *repo) RemoveFromContext(ctx context.Context, col pub.IRI, it pub.Item) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return r.RemoveFrom(col, it)
+}
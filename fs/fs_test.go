@@ -0,0 +1,1037 @@
+package fs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage"
+	"github.com/go-ap/storage/storagetest"
+)
+
+func newTestRepo(t *testing.T) *repo {
+	r, err := New(Config{Path: t.TempDir()})
+	if err != nil {
+		t.Fatalf("unable to initialize storage: %s", err)
+	}
+	return r
+}
+
+func note(iri pub.IRI, content string) *pub.Object {
+	o := pub.ObjectNew(pub.NoteType)
+	o.ID = iri
+	o.Content.Set(pub.NilLangRef, pub.Content(content))
+	return o
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(Config{}); err == nil {
+		t.Errorf("New should fail without a path")
+	}
+	p := filepath.Join(t.TempDir(), "nested", "storage")
+	if _, err := New(Config{Path: p}); err != nil {
+		t.Fatalf("New returned error: %s", err)
+	}
+	if fi, err := os.Stat(p); err != nil || !fi.IsDir() {
+		t.Errorf("New should create the storage directory %s", p)
+	}
+}
+
+func TestBootstrap(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "storage")
+	if err := Bootstrap(Config{Path: p}); err != nil {
+		t.Fatalf("Bootstrap returned error: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(p, schemaFile)); err != nil {
+		t.Errorf("Bootstrap should record the schema version: %s", err)
+	}
+	if err := Bootstrap(Config{}); err == nil {
+		t.Errorf("Bootstrap should fail without a path")
+	}
+}
+
+func TestRepo_Upgrade(t *testing.T) {
+	p := t.TempDir()
+	r, err := New(Config{Path: p})
+	if err != nil {
+		t.Fatalf("New returned error: %s", err)
+	}
+	current := len(r.upgrades())
+	if v, err := r.SchemaVersion(); err != nil || v != current {
+		t.Errorf("SchemaVersion returned %d, %v, expected %d", v, err, current)
+	}
+
+	// a storage created before recording the schema version
+	os.Remove(filepath.Join(p, schemaFile))
+	if v, _ := r.SchemaVersion(); v != 0 {
+		t.Errorf("SchemaVersion returned %d for an unversioned storage, expected 0", v)
+	}
+	if err := r.Upgrade(); err != nil {
+		t.Fatalf("Upgrade returned error: %s", err)
+	}
+	if v, _ := r.SchemaVersion(); v != current {
+		t.Errorf("SchemaVersion returned %d after Upgrade, expected %d", v, current)
+	}
+
+	os.WriteFile(filepath.Join(p, schemaFile), []byte(strconv.Itoa(current+1)), 0o600)
+	if _, err := New(Config{Path: p}); !errors.Is(err, storage.ErrSchemaVersion) {
+		t.Errorf("New returned %v for a newer schema version, expected %v", err, storage.ErrSchemaVersion)
+	}
+}
+
+func TestRepo_ReadOnly(t *testing.T) {
+	p := t.TempDir()
+	if _, err := New(Config{Path: filepath.Join(p, "missing"), ReadOnly: true}); err == nil {
+		t.Errorf("New in read-only mode should fail for a missing directory")
+	}
+	w, _ := New(Config{Path: p})
+	ob := pub.ObjectNew(pub.NoteType)
+	ob.ID = "https://example.com/objects/1"
+	w.Save(ob)
+
+	r, err := New(Config{Path: p, ReadOnly: true})
+	if err != nil {
+		t.Fatalf("New returned error: %s", err)
+	}
+	if _, err := r.Load(ob.ID); err != nil {
+		t.Errorf("Load returned error: %s", err)
+	}
+	if _, err := r.Save(ob); !errors.Is(err, storage.ErrReadOnly) {
+		t.Errorf("Save returned %v, expected %s", err, storage.ErrReadOnly)
+	}
+	if err := r.Delete(ob); !errors.Is(err, storage.ErrReadOnly) {
+		t.Errorf("Delete returned %v, expected %s", err, storage.ErrReadOnly)
+	}
+	if _, err := r.Create(pub.OrderedCollectionNew("https://example.com/outbox")); !errors.Is(err, storage.ErrReadOnly) {
+		t.Errorf("Create returned %v, expected %s", err, storage.ErrReadOnly)
+	}
+	if err := r.AddTo("https://example.com/outbox", ob); !errors.Is(err, storage.ErrReadOnly) {
+		t.Errorf("AddTo returned %v, expected %s", err, storage.ErrReadOnly)
+	}
+	if err := r.SaveMetadata(ob.ID, "test", []byte("data")); !errors.Is(err, storage.ErrReadOnly) {
+		t.Errorf("SaveMetadata returned %v, expected %s", err, storage.ErrReadOnly)
+	}
+
+	os.Remove(filepath.Join(p, schemaFile))
+	if _, err := New(Config{Path: p, ReadOnly: true}); !errors.Is(err, storage.ErrReadOnly) {
+		t.Errorf("New in read-only mode returned %v for a storage needing an upgrade, expected %s", err, storage.ErrReadOnly)
+	}
+}
+
+func TestRepo_GenerateID(t *testing.T) {
+	partOf := pub.IRI("https://example.com/objects")
+	id, err := newTestRepo(t).GenerateID(pub.ObjectNew(pub.NoteType), partOf, nil)
+	if err != nil || !strings.HasPrefix(id.String(), partOf.String()+"/") {
+		t.Errorf("GenerateID returned %s, %v, expected an UUID under %s", id, err, partOf)
+	}
+
+	r, _ := New(Config{Path: t.TempDir(), IDGen: storage.ContentHashIDs()})
+	first, _ := r.GenerateID(pub.ObjectNew(pub.NoteType), partOf, nil)
+	second, _ := r.GenerateID(pub.ObjectNew(pub.NoteType), partOf, nil)
+	if first != second {
+		t.Errorf("GenerateID should use the configured strategy, got %s and %s", first, second)
+	}
+
+	r, _ = New(Config{Path: t.TempDir(), IDGen: storage.ContentHashIDs(), UniqueIDTries: 2})
+	ob := pub.ObjectNew(pub.NoteType)
+	if ob.ID, err = r.GenerateID(ob, partOf, nil); err != nil {
+		t.Fatalf("GenerateID returned error: %s", err)
+	}
+	r.Save(ob)
+	if id, err := r.GenerateID(pub.ObjectNew(pub.NoteType), partOf, nil); err == nil {
+		t.Errorf("GenerateID returned the stored ID %s, expected an error", id)
+	}
+}
+
+func TestRepo_VerifyUniqueIDs(t *testing.T) {
+	dir := t.TempDir()
+	r, _ := New(Config{Path: dir})
+	ob := pub.ObjectNew(pub.NoteType)
+	ob.ID = "https://example.com/objects/1"
+	r.Save(ob)
+	if dups, err := r.VerifyUniqueIDs(); err != nil || len(dups) > 0 {
+		t.Fatalf("VerifyUniqueIDs returned %v, %v, expected no duplicates", dups, err)
+	}
+
+	// an IRI stored both as an object and as a collection
+	both := pub.IRI("https://example.com/objects/2")
+	r.Save(&pub.Object{ID: both, Type: pub.NoteType})
+	r.Create(pub.OrderedCollectionNew(both))
+	// an object copied to the directory of another IRI
+	data, _ := os.ReadFile(filepath.Join(dir, "example.com", "objects", "1", objectFile))
+	os.MkdirAll(filepath.Join(dir, "example.com", "copies", "1"), 0700)
+	os.WriteFile(filepath.Join(dir, "example.com", "copies", "1", objectFile), data, 0600)
+
+	dups, err := r.VerifyUniqueIDs()
+	if err != nil {
+		t.Fatalf("VerifyUniqueIDs returned error: %s", err)
+	}
+	if len(dups) != 2 || !dups.Contains(ob.ID) || !dups.Contains(both) {
+		t.Errorf("VerifyUniqueIDs returned %v, expected %s and %s", dups, ob.ID, both)
+	}
+	r.Close()
+	if _, err := r.VerifyUniqueIDs(); !errors.Is(err, storage.ErrClosed) {
+		t.Errorf("VerifyUniqueIDs of a closed storage returned %v, expected %s", err, storage.ErrClosed)
+	}
+}
+
+func TestRepo_CreateActorCollections(t *testing.T) {
+	r, _ := New(Config{Path: t.TempDir(), CreateActorCollections: true})
+	actor := &pub.Actor{ID: "https://example.com/actors/jdoe", Type: pub.PersonType}
+	if _, err := r.Save(actor); err != nil {
+		t.Fatalf("Save returned error: %s", err)
+	}
+	r.AddTo(actor.Followers.GetLink(), pub.IRI("https://example.com/actors/alice"))
+	r.Save(actor)
+
+	for _, col := range []pub.Item{actor.Inbox, actor.Outbox, actor.Followers, actor.Following, actor.Liked} {
+		if pub.IsNil(col) {
+			t.Fatalf("Save should set the collections of a new actor")
+		}
+		if _, err := r.Load(col.GetLink()); err != nil {
+			t.Errorf("Load of the %s collection returned error: %s", col.GetLink(), err)
+		}
+	}
+	if ok, _ := r.IsMember(actor.Followers.GetLink(), pub.IRI("https://example.com/actors/alice")); !ok {
+		t.Errorf("saving an existing actor should keep its collections")
+	}
+	it, _ := r.Load(actor.ID)
+	if loaded, err := pub.ToActor(it); err != nil || pub.IsNil(loaded.Outbox) || loaded.Outbox.GetLink() != actor.ID.AddPath("outbox") {
+		t.Errorf("the stored actor should have the IRIs of its collections")
+	}
+
+	other := newTestRepo(t)
+	plain := &pub.Actor{ID: "https://example.com/actors/jdoe", Type: pub.PersonType}
+	other.Save(plain)
+	if !pub.IsNil(plain.Inbox) {
+		t.Errorf("the collections should be created only when enabled")
+	}
+}
+
+func TestRepo_SaveLoad(t *testing.T) {
+	r := newTestRepo(t)
+
+	ob := pub.ObjectNew(pub.NoteType)
+	ob.ID = "https://example.com/objects/1"
+	ob.Content.Set(pub.NilLangRef, pub.Content("Hello"))
+
+	if _, err := r.Save(ob); err != nil {
+		t.Fatalf("Save returned error: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(r.path, "example.com", "objects", "1", objectFile)); err != nil {
+		t.Errorf("object file was not created: %s", err)
+	}
+
+	it, err := r.Load(ob.ID)
+	if err != nil {
+		t.Fatalf("Load returned error: %s", err)
+	}
+	if !ob.Equals(it) {
+		t.Errorf("Load returned %#v, expected %#v", it, ob)
+	}
+
+	if _, err := r.Load("https://example.com/objects/2"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Load of missing object returned %v, expected %s", err, os.ErrNotExist)
+	}
+	if _, err := r.Load("https://example.com/objects/2"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Load of missing object returned %v, expected %s", err, storage.ErrNotFound)
+	}
+	if _, err := r.Save(pub.ObjectNew(pub.NoteType)); !errors.Is(err, storage.ErrNotValid) {
+		t.Errorf("Save of an object without ID returned %v, expected %s", err, storage.ErrNotValid)
+	}
+}
+
+func TestRepo_Load_buckets(t *testing.T) {
+	r := newTestRepo(t)
+	ob := note("https://example.com/objects/1/replies", "not a collection")
+	col := pub.OrderedCollectionNew("https://example.com/lists/1")
+	for _, it := range []pub.Item{ob, col} {
+		r.Save(it)
+		loaded, err := r.Load(it.GetLink())
+		if err != nil {
+			t.Fatalf("Load returned error: %s", err)
+		}
+		if loaded.GetType() != it.GetType() {
+			t.Errorf("Load of %s returned a %s, expected a %s", it.GetLink(), loaded.GetType(), it.GetType())
+		}
+	}
+	for _, iri := range []pub.IRI{"https://example.com/objects/2", "https://example.com/objects/2/replies"} {
+		if _, err := r.Load(iri); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("Load of missing %s returned %v, expected %s", iri, err, storage.ErrNotFound)
+		}
+	}
+}
+
+func TestRepo_itemPath(t *testing.T) {
+	r := newTestRepo(t)
+	p, err := r.itemPath("https://example.com/objects/../../../etc/passwd")
+	if err != nil {
+		t.Fatalf("itemPath returned error: %s", err)
+	}
+	if want := filepath.Join(r.path, "example.com", "etc", "passwd"); p != want {
+		t.Errorf("itemPath returned %s, expected %s", p, want)
+	}
+	if _, err := r.itemPath("/objects/1"); err == nil {
+		t.Errorf("itemPath should fail for IRIs without host")
+	}
+	for _, iri := range []pub.IRI{"https://../escaped/x", "https://./x"} {
+		if p, err := r.itemPath(iri); !errors.Is(err, storage.ErrNotValid) {
+			t.Errorf("itemPath(%s) returned %s, %v, expected %s", iri, p, err, storage.ErrNotValid)
+		}
+	}
+	for iri, want := range map[pub.IRI]string{
+		"https://example.com/outbox?page=2":             "example.com/outbox/%3Fpage=2",
+		"https://example.com/actors/jdoe#main-key":      "example.com/actors/jdoe/%23main-key",
+		"https://example.com/objects?next=../../../etc": "example.com/objects/%3Fnext=..%2F..%2F..%2Fetc",
+	} {
+		if p, err := r.itemPath(iri); err != nil || p != filepath.Join(r.path, filepath.FromSlash(want)) {
+			t.Errorf("itemPath(%s) returned %s, %v, expected %s", iri, p, err, want)
+		}
+	}
+}
+
+func TestRepo_Save_escaping(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "storage")
+	r, _ := New(Config{Path: root})
+
+	ob := note("https://../escaped/x", "Hello")
+	if _, err := r.Save(ob); !errors.Is(err, storage.ErrNotValid) {
+		t.Errorf("Save of an object with an invalid host returned %v, expected %s", err, storage.ErrNotValid)
+	}
+	if _, err := os.Stat(filepath.Join(root, "..", "escaped")); !os.IsNotExist(err) {
+		t.Errorf("Save should not write outside the storage root: %v", err)
+	}
+
+	outbox := pub.OrderedCollectionNew("https://example.com/outbox")
+	outbox.OrderedItems = pub.ItemCollection{pub.IRI("https://example.com/objects/1")}
+	r.Save(outbox)
+	page := pub.OrderedCollectionPageNew(outbox)
+	page.ID = "https://example.com/outbox?page=2"
+	if _, err := r.Save(page); err != nil {
+		t.Fatalf("Save of a collection page returned error: %s", err)
+	}
+	it, err := r.Load(outbox.ID)
+	if err != nil {
+		t.Fatalf("Load returned error: %s", err)
+	}
+	if it.GetLink() != outbox.ID || it.(pub.CollectionInterface).Count() != 1 {
+		t.Errorf("saving a page should not overwrite its collection, got %#v", it)
+	}
+	if it, err := r.Load(page.ID); err != nil || it.GetLink() != page.ID {
+		t.Errorf("Load of the page returned %v, %v", it, err)
+	}
+}
+
+func TestRepo_CaseInsensitivePaths(t *testing.T) {
+	r, _ := New(Config{Path: t.TempDir(), CaseInsensitivePaths: true})
+	ob := pub.ObjectNew(pub.NoteType)
+	ob.ID = "https://example.com/Objects/ABC"
+	if _, err := r.Save(ob); err != nil {
+		t.Fatalf("Save returned error: %s", err)
+	}
+	it, err := r.Load("https://example.com/objects/abc")
+	if err != nil {
+		t.Fatalf("Load with a different path case returned error: %s", err)
+	}
+	if it.GetLink() != ob.ID {
+		t.Errorf("the loaded object should keep its original ID %s, got %s", ob.ID, it.GetLink())
+	}
+
+	if _, err := newTestRepo(t).Load("https://example.com/objects/abc"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("the paths should be case sensitive by default, got %v", err)
+	}
+}
+
+func TestRepo_Delete(t *testing.T) {
+	r := newTestRepo(t)
+
+	actor := pub.PersonNew("https://example.com/actors/jdoe")
+	r.Save(actor)
+	r.Create(pub.OrderedCollectionNew(actor.ID.AddPath("outbox")))
+
+	if err := r.Delete(actor); err != nil {
+		t.Fatalf("Delete returned error: %s", err)
+	}
+	if _, err := r.Load(actor.ID); err == nil {
+		t.Errorf("Load after Delete should fail")
+	}
+	if _, err := r.Load(actor.ID.AddPath("outbox")); err != nil {
+		t.Errorf("Delete should not remove nested collections: %s", err)
+	}
+	if err := r.Delete(actor); err != nil {
+		t.Errorf("Delete of a missing item returned error: %s", err)
+	}
+}
+
+func TestRepo_Delete_tombstones(t *testing.T) {
+	r, _ := New(Config{Path: t.TempDir(), Tombstones: true})
+	outbox := pub.IRI("https://example.com/actors/jdoe/outbox")
+	r.Create(pub.OrderedCollectionNew(outbox))
+	ob := note("https://example.com/objects/1", "Hello")
+	ob.To = pub.ItemCollection{pub.PublicNS}
+	r.Save(ob)
+	r.AddTo(outbox, ob)
+
+	// the Tombstone keeps the properties of the stored version, not the ones of the deleted item
+	if err := r.Delete(ob.ID); err != nil {
+		t.Fatalf("Delete returned error: %s", err)
+	}
+	it, err := r.Load(ob.ID)
+	if err != nil {
+		t.Fatalf("Load after Delete returned error: %s", err)
+	}
+	tomb, err := pub.ToTombstone(it)
+	if err != nil || tomb.FormerType != pub.NoteType || !tomb.To.Contains(pub.PublicNS) {
+		t.Errorf("Delete should replace the object with its Tombstone, got %#v", it)
+	}
+	if ok, _ := r.IsMember(outbox, ob); ok {
+		t.Errorf("Delete should remove the object from the collections")
+	}
+	if err := r.Delete(pub.IRI("https://example.com/objects/2")); err != nil {
+		t.Errorf("Delete of a missing item returned error: %s", err)
+	}
+	if err := r.Delete(outbox); err != nil {
+		t.Fatalf("Delete of a collection returned error: %s", err)
+	}
+	if _, err := r.Load(outbox); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Delete should remove the collections, got %v", err)
+	}
+}
+
+func TestRepo_Collections(t *testing.T) {
+	r := newTestRepo(t)
+	outbox := pub.IRI("https://example.com/actors/jdoe/outbox")
+
+	if err := r.AddTo(outbox, pub.IRI("https://example.com/activities/1")); err == nil {
+		t.Errorf("AddTo a missing collection should fail")
+	}
+	if _, err := r.Create(pub.OrderedCollectionNew(outbox)); err != nil {
+		t.Fatalf("Create returned error: %s", err)
+	}
+
+	act := pub.CreateNew("https://example.com/activities/1", pub.IRI("https://example.com/objects/1"))
+	r.Save(act)
+	for _, it := range []pub.Item{act, pub.IRI("https://example.com/activities/2"), act} {
+		if err := r.AddTo(outbox, it); err != nil {
+			t.Fatalf("AddTo returned error: %s", err)
+		}
+	}
+
+	it, err := r.Load(outbox)
+	if err != nil {
+		t.Fatalf("Load returned error: %s", err)
+	}
+	col, ok := it.(*pub.OrderedCollection)
+	if !ok {
+		t.Fatalf("Load returned %T, expected %T", it, col)
+	}
+	if col.TotalItems != 2 || len(col.OrderedItems) != 2 {
+		t.Fatalf("expected 2 items in the collection, got %d", col.TotalItems)
+	}
+	if col.OrderedItems[0].GetType() != pub.CreateType {
+		t.Errorf("expected stored members to be loaded, got %T", col.OrderedItems[0])
+	}
+	if !pub.IsIRI(col.OrderedItems[1]) {
+		t.Errorf("expected members that are not stored to be returned as IRIs, got %T", col.OrderedItems[1])
+	}
+
+	if ok, err := r.IsMember(outbox, act); err != nil || !ok {
+		t.Errorf("IsMember returned %t, %v, expected the activity to be a member", ok, err)
+	}
+	if ok, _ := r.IsMember(outbox, pub.IRI("https://example.com/activities/3")); ok {
+		t.Errorf("IsMember returned true for an item that is not a member")
+	}
+	if _, err := r.IsMember("https://example.com/actors/jdoe/inbox", act); err == nil {
+		t.Errorf("IsMember should fail for a missing collection")
+	}
+
+	if err := r.RemoveFrom(outbox, act); err != nil {
+		t.Fatalf("RemoveFrom returned error: %s", err)
+	}
+	it, _ = r.Load(outbox)
+	col = it.(*pub.OrderedCollection)
+	if col.TotalItems != 1 || col.OrderedItems[0].GetLink() != "https://example.com/activities/2" {
+		t.Errorf("invalid collection after RemoveFrom: %v", col.OrderedItems)
+	}
+}
+
+func TestRepo_Each(t *testing.T) {
+	r := newTestRepo(t)
+	outbox := pub.IRI("https://example.com/actors/jdoe/outbox")
+	r.Create(pub.OrderedCollectionNew(outbox))
+	for _, iri := range []pub.IRI{
+		"https://example.com/objects/1",
+		"https://example.com/objects/10",
+		"https://example.com/objects/1/replies/1",
+		"https://example.org/objects/1",
+	} {
+		ob := pub.ObjectNew(pub.NoteType)
+		ob.ID = iri
+		r.Save(ob)
+	}
+	r.AddTo(outbox, pub.IRI("https://example.com/objects/10"))
+	r.AddTo(outbox, pub.IRI("https://example.com/objects/2"))
+
+	tests := []struct {
+		name string
+		f    pub.IRI
+		want int
+	}{
+		{name: "all", f: "", want: 4},
+		{name: "prefix", f: "https://example.com/objects/1", want: 2},
+		{name: "collection", f: outbox, want: 1},
+		{name: "missing", f: "https://example.net", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			count := 0
+			err := r.Each(tt.f, func(it pub.Item) error {
+				count++
+				_, err := r.Save(it)
+				return err
+			})
+			if err != nil {
+				t.Fatalf("Each returned error: %s", err)
+			}
+			if count != tt.want {
+				t.Errorf("Each returned %d items, expected %d", count, tt.want)
+			}
+		})
+	}
+
+	stop := errors.New("stop")
+	if err := r.Each(pub.IRI(""), func(pub.Item) error { return stop }); !errors.Is(err, stop) {
+		t.Errorf("Each returned %v, expected the error of the callback", err)
+	}
+}
+
+func TestRepo_Exists(t *testing.T) {
+	r := newTestRepo(t)
+	ob := pub.ObjectNew(pub.NoteType)
+	ob.ID = "https://example.com/objects/1"
+	r.Save(ob)
+	r.Create(pub.OrderedCollectionNew("https://example.com/actors/jdoe/outbox"))
+
+	for iri, want := range map[pub.IRI]bool{
+		"https://example.com/objects/1":          true,
+		"https://example.com/actors/jdoe/outbox": true,
+		"https://example.com/actors/jdoe":        false,
+		"https://example.com/objects/2":          false,
+	} {
+		if got, err := r.Exists(iri); err != nil || got != want {
+			t.Errorf("Exists(%s) returned %t, %v, expected %t", iri, got, err, want)
+		}
+	}
+}
+
+func TestRepo_Metadata(t *testing.T) {
+	r := newTestRepo(t)
+	actor := pub.PersonNew("https://example.com/actors/jdoe")
+	r.Save(actor)
+
+	if _, err := r.LoadMetadata(actor.ID, "key"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("LoadMetadata of missing metadata returned %v, expected %s", err, storage.ErrNotFound)
+	}
+	for ns, data := range map[string]string{"key": "secret", "oauth/client": "id"} {
+		if err := r.SaveMetadata(actor.ID, ns, []byte(data)); err != nil {
+			t.Fatalf("SaveMetadata returned error: %s", err)
+		}
+		got, err := r.LoadMetadata(actor.ID, ns)
+		if err != nil {
+			t.Fatalf("LoadMetadata returned error: %s", err)
+		}
+		if string(got) != data {
+			t.Errorf("LoadMetadata returned %q, expected %q", got, data)
+		}
+	}
+	p, _ := r.itemPath(actor.ID)
+	if fi, err := os.Stat(filepath.Join(p, metadataFile("key"))); err != nil || fi.Mode().Perm() != 0o600 {
+		t.Errorf("metadata files should be readable only by the owner: %v", err)
+	}
+
+	r.Delete(actor)
+	if _, err := os.Stat(p); !os.IsNotExist(err) {
+		t.Errorf("Delete should remove the metadata of the item: %v", err)
+	}
+}
+
+func TestRepo_Counters(t *testing.T) {
+	dir := t.TempDir()
+	r, _ := New(Config{Path: dir})
+	ob := pub.ObjectNew(pub.NoteType)
+	ob.ID = "https://example.com/objects/1"
+	ob.Likes = pub.IRI("https://example.com/objects/1/likes")
+	r.Save(ob)
+
+	for _, delta := range []int{1, 1, -1, 3} {
+		if _, err := r.IncrementCounter(ob.ID, "likes", delta); err != nil {
+			t.Fatalf("IncrementCounter returned error: %s", err)
+		}
+	}
+	r.Close()
+
+	r, _ = New(Config{Path: dir, MergeCounters: true})
+	counters, err := r.LoadCounters(ob.ID)
+	if err != nil {
+		t.Fatalf("LoadCounters returned error: %s", err)
+	}
+	if counters["likes"] != 4 {
+		t.Errorf("expected the likes counter to be 4 after reopening the storage, got %d", counters["likes"])
+	}
+	it, err := r.Load(ob.ID)
+	if err != nil {
+		t.Fatalf("Load returned error: %s", err)
+	}
+	pub.OnObject(it, func(o *pub.Object) error {
+		likes, ok := o.Likes.(*pub.OrderedCollection)
+		if !ok || likes.TotalItems != 4 {
+			t.Errorf("Load should merge the counters into the collections of the object, got %#v", o.Likes)
+		}
+		return nil
+	})
+	outbox := pub.IRI("https://example.com/actors/jdoe/outbox")
+	r.Create(pub.OrderedCollectionNew(outbox))
+	r.AddTo(outbox, ob)
+	items, _, err := r.LoadCollection(outbox, nil)
+	if err != nil || len(items) != 1 {
+		t.Fatalf("LoadCollection returned %v, %v", items, err)
+	}
+	pub.OnObject(items[0], func(o *pub.Object) error {
+		likes, ok := o.Likes.(*pub.OrderedCollection)
+		if !ok || likes.TotalItems != 4 {
+			t.Errorf("LoadCollection should merge the counters into the collections of the members, got %#v", o.Likes)
+		}
+		return nil
+	})
+
+	r.Delete(ob)
+	if counters, _ := r.LoadCounters(ob.ID); len(counters) != 0 {
+		t.Errorf("Delete should remove the counters of the object, got %v", counters)
+	}
+}
+
+func TestRepo_RecordVote(t *testing.T) {
+	dir := t.TempDir()
+	r, _ := New(Config{Path: dir})
+	q := pub.QuestionNew("https://example.com/questions/1")
+	yes, no := pub.ObjectNew(pub.NoteType), pub.ObjectNew(pub.NoteType)
+	yes.Name.Set(pub.NilLangRef, pub.Content("yes"))
+	no.Name.Set(pub.NilLangRef, pub.Content("no"))
+	q.OneOf = pub.ItemCollection{yes, no}
+	r.Save(q)
+
+	voter := pub.IRI("https://example.com/actors/jdoe")
+	if err := r.RecordVote(q.ID, voter, "yes"); err != nil {
+		t.Fatalf("RecordVote returned error: %s", err)
+	}
+	r.Close()
+
+	r, _ = New(Config{Path: dir})
+	if err := r.RecordVote(q.ID, voter, "no"); !errors.Is(err, storage.ErrDuplicateVote) {
+		t.Errorf("second vote on a single choice poll returned %v, expected %s", err, storage.ErrDuplicateVote)
+	}
+	if err := r.RecordVote("https://example.com/questions/2", voter, "yes"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("vote on a missing question returned %v, expected %s", err, storage.ErrNotFound)
+	}
+	results, err := r.PollResults(q.ID)
+	if err != nil {
+		t.Fatalf("PollResults returned error: %s", err)
+	}
+	if len(results) != 2 || results["yes"] != 1 || results["no"] != 0 {
+		t.Errorf("invalid poll results %v", results)
+	}
+}
+
+func TestRepo_DeleteMatching(t *testing.T) {
+	tests := []struct {
+		name  string
+		f     storage.Filterable
+		count uint
+		purge bool
+	}{
+		{
+			name:  "prefix",
+			f:     pub.IRI("https://example.com/objects"),
+			count: 2,
+		},
+		{
+			name:  "filter",
+			f:     storage.Filter{Text: []string{"spam"}},
+			count: 1,
+		},
+		{
+			name:  "purge",
+			f:     purge{pub.IRI("https://example.com/objects/1")},
+			count: 1,
+			purge: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRepo(t)
+			inbox := pub.IRI("https://example.com/actors/jdoe/inbox")
+			r.Create(pub.OrderedCollectionNew(inbox))
+			for _, ob := range []*pub.Object{
+				note("https://example.com/objects/1", "spam"),
+				note("https://example.com/objects/2", "ham"),
+				note("https://example.org/objects/3", "ham"),
+			} {
+				r.Save(ob)
+				r.AddTo(inbox, ob)
+			}
+			r.Save(note("https://example.com/objects/1", "edited spam"))
+			r.SaveBinary("https://example.com/objects/1", strings.NewReader("spam"), "text/plain")
+
+			count, err := r.DeleteMatching(tt.f)
+			if err != nil {
+				t.Fatalf("DeleteMatching returned error: %s", err)
+			}
+			if count != tt.count {
+				t.Errorf("DeleteMatching returned %d, expected %d", count, tt.count)
+			}
+			it, err := r.Load("https://example.com/objects/1")
+			if tt.purge {
+				if !errors.Is(err, storage.ErrNotFound) {
+					t.Errorf("purged objects should be removed, got %v", err)
+				}
+			} else if err != nil || it.GetType() != pub.TombstoneType {
+				t.Errorf("deleted objects should be replaced with Tombstones, got %v: %v", it, err)
+			}
+			if versions, _ := r.LoadVersions("https://example.com/objects/1"); len(versions) > 0 {
+				t.Errorf("the versions of the deleted objects should be removed, got %d", len(versions))
+			}
+			if _, _, err := r.LoadBinary("https://example.com/objects/1"); !errors.Is(err, storage.ErrNotFound) {
+				t.Errorf("the binary data of the deleted objects should be removed, got %v", err)
+			}
+			it, _ = r.Load(inbox)
+			if n := len(it.(*pub.OrderedCollection).OrderedItems); n != 3-int(tt.count) {
+				t.Errorf("expected %d items in the collection after DeleteMatching, got %d", 3-tt.count, n)
+			}
+			if count, _ = r.DeleteMatching(tt.f); count != 0 {
+				t.Errorf("DeleteMatching should ignore already deleted objects, deleted %d", count)
+			}
+		})
+	}
+}
+
+type purge struct {
+	pub.IRI
+}
+
+func (p purge) Purge() bool {
+	return true
+}
+
+func TestRepo_LoadCollection(t *testing.T) {
+	r := newTestRepo(t)
+	outbox := pub.IRI("https://example.com/actors/jdoe/outbox")
+	r.Create(pub.OrderedCollectionNew(outbox))
+	for _, iri := range []pub.IRI{"https://example.com/objects/1", "https://example.com/objects/2", "https://example.com/objects/3"} {
+		ob := pub.ObjectNew(pub.NoteType)
+		ob.ID = iri
+		r.Save(ob)
+		r.AddTo(outbox, ob)
+	}
+	r.AddTo(outbox, pub.IRI("https://example.com/objects/4"))
+
+	items, next, err := r.LoadCollection(outbox, storage.Page{Max: 2})
+	if err != nil {
+		t.Fatalf("LoadCollection returned error: %s", err)
+	}
+	if len(items) != 2 || !pub.IsIRI(items[0]) || items[1].GetLink() != "https://example.com/objects/3" || next == "" {
+		t.Errorf("LoadCollection returned %v, %q, expected the 2 most recent items", items, next)
+	}
+	items, _, _ = r.LoadCollection(outbox, storage.Page{Max: 2, After: next})
+	if len(items) != 2 || items[1].GetLink() != "https://example.com/objects/1" || items[1].GetType() != pub.NoteType {
+		t.Errorf("LoadCollection returned %v, expected the oldest items", items)
+	}
+	if _, _, err := r.LoadCollection("https://example.com/actors/jdoe/inbox", storage.Page{}); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("LoadCollection of a missing collection returned %v, expected %s", err, storage.ErrNotFound)
+	}
+}
+
+// countdown is a context which is done after its Err method has been called "n" times.
+type countdown struct {
+	context.Context
+	n int
+}
+
+func (c *countdown) Err() error {
+	if c.n <= 0 {
+		return context.DeadlineExceeded
+	}
+	c.n--
+	return nil
+}
+
+func TestRepo_LoadCollectionContext(t *testing.T) {
+	r := newTestRepo(t)
+	inbox := pub.IRI("https://example.com/actors/jdoe/inbox")
+	r.Create(pub.OrderedCollectionNew(inbox))
+	for _, iri := range []pub.IRI{"https://example.com/objects/1", "https://example.com/objects/2", "https://example.com/objects/3"} {
+		r.Save(note(iri, "Hello"))
+		r.AddTo(inbox, iri)
+	}
+
+	if _, _, err := r.LoadCollectionContext(&countdown{Context: context.Background(), n: 2}, inbox, inbox); !errors.Is(err, context.DeadlineExceeded) || storage.IsPartial(err) {
+		t.Errorf("interrupted LoadCollectionContext returned %v, expected %s", err, context.DeadlineExceeded)
+	}
+	items, _, err := r.LoadCollectionContext(&countdown{Context: context.Background(), n: 2}, inbox, storage.Filter{IRI: inbox, Partial: true})
+	if !storage.IsPartial(err) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("interrupted LoadCollectionContext returned %v, expected a partial result", err)
+	}
+	if len(items) != 2 || items[0].GetLink() != "https://example.com/objects/2" {
+		t.Errorf("the partial result should have the members read before the deadline, got %v", items)
+	}
+
+	seen := 0
+	err = r.EachContext(&countdown{Context: context.Background(), n: 1}, storage.Filter{IRI: inbox, Partial: true}, func(pub.Item) error {
+		seen++
+		return nil
+	})
+	if seen != 1 || !storage.IsPartial(err) {
+		t.Errorf("interrupted EachContext called fn %d times and returned %v, expected a partial result", seen, err)
+	}
+}
+
+func TestRepo_LoadCollectionPage(t *testing.T) {
+	r := newTestRepo(t)
+	outbox := pub.IRI("https://example.com/actors/jdoe/outbox")
+	r.Create(pub.OrderedCollectionNew(outbox))
+	for _, iri := range []pub.IRI{"https://example.com/objects/1", "https://example.com/objects/2", "https://example.com/objects/3"} {
+		ob := pub.ObjectNew(pub.NoteType)
+		ob.ID = iri
+		r.Save(ob)
+		r.AddTo(outbox, ob)
+	}
+	r.RemoveFrom(outbox, pub.IRI("https://example.com/objects/2"))
+
+	page, err := r.LoadCollectionPage(storage.Page{IRI: outbox, Max: 1})
+	if err != nil {
+		t.Fatalf("LoadCollectionPage returned error: %s", err)
+	}
+	if page.TotalItems != 2 || len(page.OrderedItems) != 1 || page.OrderedItems[0].GetType() != pub.NoteType {
+		t.Errorf("LoadCollectionPage returned %v of %d items, expected the most recent of 2 items", page.OrderedItems, page.TotalItems)
+	}
+	if page.Next == nil || page.Last.GetLink() != page.Next.GetLink() || page.Prev != nil {
+		t.Errorf("LoadCollectionPage returned next %v, last %v and prev %v", page.Next, page.Last, page.Prev)
+	}
+	if _, err := r.LoadCollectionPage(storage.Page{IRI: "https://example.com/actors/jdoe/inbox"}); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("LoadCollectionPage of a missing collection returned %v, expected %s", err, storage.ErrNotFound)
+	}
+}
+
+func TestRepo_Logger(t *testing.T) {
+	logs := make([]string, 0)
+	logFn := func(format string, v ...interface{}) {
+		logs = append(logs, fmt.Sprintf(format, v...))
+	}
+	r, err := New(Config{Path: t.TempDir(), Logger: storage.LogFnLogger(logFn)})
+	if err != nil {
+		t.Fatalf("unable to initialize storage: %s", err)
+	}
+	outbox := pub.IRI("https://example.com/actors/jdoe/outbox")
+	r.Create(pub.OrderedCollectionNew(outbox))
+	ob := &pub.Object{ID: "https://example.com/objects/1", Type: pub.NoteType}
+	r.Save(ob)
+	r.AddTo(outbox, ob)
+	p, _ := r.itemPath(ob.ID)
+	os.WriteFile(filepath.Join(p, objectFile), []byte("{corrupted"), 0o600)
+
+	if err := r.Each(pub.IRI(""), func(pub.Item) error { return nil }); err != nil {
+		t.Fatalf("Each returned error: %s", err)
+	}
+	if _, _, err := r.LoadCollection(outbox, storage.Page{}); err != nil {
+		t.Fatalf("LoadCollection returned error: %s", err)
+	}
+	if len(logs) != 2 || !strings.HasPrefix(logs[0], "WARN skipping object") || !strings.Contains(logs[1], string(ob.ID)) {
+		t.Errorf("the corrupted object should be logged when skipped, got %v", logs)
+	}
+}
+
+func TestRepo_ReportCorrupted(t *testing.T) {
+	quarantine := filepath.Join(t.TempDir(), "quarantine")
+	r, err := New(Config{Path: t.TempDir(), ReportCorrupted: true, Repair: Quarantine(quarantine)})
+	if err != nil {
+		t.Fatalf("unable to initialize storage: %s", err)
+	}
+	outbox := pub.IRI("https://example.com/actors/jdoe/outbox")
+	r.Create(pub.OrderedCollectionNew(outbox))
+	for _, iri := range []pub.IRI{"https://example.com/objects/1", "https://example.com/objects/2"} {
+		ob := &pub.Object{ID: iri, Type: pub.NoteType}
+		r.Save(ob)
+		r.AddTo(outbox, ob)
+	}
+	p, _ := r.itemPath("https://example.com/objects/1")
+	os.WriteFile(filepath.Join(p, objectFile), []byte("{corrupted"), 0o600)
+
+	items, _, err := r.LoadCollection(outbox, storage.Page{})
+	ce := (*storage.CorruptionError)(nil)
+	if !errors.As(err, &ce) || !errors.Is(err, storage.ErrCorrupted) {
+		t.Fatalf("LoadCollection returned %v, expected a storage.CorruptionError", err)
+	}
+	if len(ce.Items) != 1 || ce.Items[0].IRI != "https://example.com/objects/1" {
+		t.Errorf("unexpected corrupted items %v", ce.Items)
+	}
+	if len(items) != 1 || items[0].GetLink() != "https://example.com/objects/2" {
+		t.Errorf("LoadCollection should return the valid members, got %v", items)
+	}
+	if entries, _ := os.ReadDir(quarantine); len(entries) != 1 {
+		t.Errorf("the corrupted object should have been moved to the quarantine, found %d files", len(entries))
+	}
+	if err := r.Each(pub.IRI(""), func(pub.Item) error { return nil }); err != nil {
+		t.Errorf("Each returned %v after the corrupted object was quarantined", err)
+	}
+}
+
+func TestRepo_Prepare(t *testing.T) {
+	r := newTestRepo(t)
+	outbox := pub.IRI("https://example.com/actors/jdoe/outbox")
+	r.Create(pub.OrderedCollectionNew(outbox))
+	for _, iri := range []pub.IRI{"https://example.com/objects/1", "https://example.com/objects/2", "https://example.com/objects/3"} {
+		ob := &pub.Object{ID: iri, Type: pub.NoteType}
+		r.Save(ob)
+		r.AddTo(outbox, ob)
+	}
+
+	p, err := r.Prepare(storage.Filter{IRI: outbox})
+	if err != nil {
+		t.Fatalf("Prepare returned error: %s", err)
+	}
+	page, next, err := p.Page(2, "")
+	if err != nil || len(page) != 2 || page[0].GetLink() != "https://example.com/objects/3" {
+		t.Fatalf("Page returned %v, %v, expected the 2 most recent items", page, err)
+	}
+	if page, _, _ = p.Page(2, next); len(page) != 1 || page[0].GetLink() != "https://example.com/objects/1" {
+		t.Errorf("Page returned %v, expected the oldest item", page)
+	}
+	ob := &pub.Object{ID: "https://example.com/objects/4", Type: pub.NoteType}
+	r.Save(ob)
+	r.AddTo(outbox, ob)
+	count := 0
+	p.Each(func(pub.Item) error {
+		count++
+		return nil
+	})
+	if count != 4 {
+		t.Errorf("the plan should see the items added after preparing it, iterated %d", count)
+	}
+	if _, err := r.Prepare(storage.Filter{IRI: "/objects"}); !errors.Is(err, storage.ErrNotValid) {
+		t.Errorf("Prepare returned %v for an invalid IRI, expected %s", err, storage.ErrNotValid)
+	}
+}
+
+func TestConformance(t *testing.T) {
+	storagetest.RunStoreTests(t, func() storage.Store { return newTestRepo(t) })
+}
+
+func TestConformance_codecs(t *testing.T) {
+	for name, codec := range map[string]storage.Codec{"JSONLD": storage.JSONLD, "CBOR": storage.CBOR} {
+		t.Run(name, func(t *testing.T) {
+			storagetest.RunStoreTests(t, func() storage.Store {
+				r, err := New(Config{Path: t.TempDir(), Codec: codec})
+				if err != nil {
+					t.Fatalf("New returned error: %s", err)
+				}
+				return r
+			})
+		})
+	}
+}
+
+func TestRepo_Compression(t *testing.T) {
+	if _, err := New(Config{Path: t.TempDir(), Compression: "lz4"}); !errors.Is(err, storage.ErrNotValid) {
+		t.Errorf("New with an unsupported compression returned %v, expected %s", err, storage.ErrNotValid)
+	}
+	path := t.TempDir()
+	plain, _ := New(Config{Path: path})
+	ob := pub.ObjectNew(pub.NoteType)
+	ob.ID = "https://example.com/objects/1"
+	plain.Save(ob)
+
+	r, err := New(Config{Path: path, Compression: storage.GzipCompression})
+	if err != nil {
+		t.Fatalf("New returned error: %s", err)
+	}
+	if _, err := r.Load(ob.ID); err != nil {
+		t.Errorf("Load of an object stored without compression returned error: %s", err)
+	}
+	ob.ID = "https://example.com/objects/2"
+	r.Save(ob)
+	p, _ := r.itemPath(ob.ID)
+	if data, _ := os.ReadFile(filepath.Join(p, objectFile)); len(data) < 2 || data[0] != 0x1f || data[1] != 0x8b {
+		t.Errorf("the object should be stored compressed")
+	}
+	if it, err := r.Load(ob.ID); err != nil || it.GetLink() != ob.ID {
+		t.Errorf("Load returned %v, %v, expected %s", it, err, ob.ID)
+	}
+
+	r, err = New(Config{Path: path, Compression: storage.ZstdCompression})
+	if err != nil {
+		t.Fatalf("New returned error: %s", err)
+	}
+	if it, err := r.Load(ob.ID); err != nil || it.GetLink() != ob.ID {
+		t.Errorf("Load of an object compressed with gzip returned %v, %v, expected %s", it, err, ob.ID)
+	}
+}
+
+func TestRepo_Codec(t *testing.T) {
+	r, _ := New(Config{Path: t.TempDir(), Codec: storage.CBOR})
+	ob := pub.ObjectNew(pub.NoteType)
+	ob.ID = "https://example.com/objects/1"
+	ob.Content.Set(pub.NilLangRef, pub.Content("hello"))
+	r.Save(ob)
+
+	p, _ := r.itemPath(ob.ID)
+	data, _ := os.ReadFile(filepath.Join(p, objectFile))
+	if want, _ := storage.CBOR.Marshal(ob); string(data) != string(want) {
+		t.Errorf("the object should be stored using the configured codec")
+	}
+	count := 0
+	r.Each(storage.Filter{Type: pub.ActivityVocabularyTypes{pub.NoteType}, Text: []string{"hello"}}, func(pub.Item) error {
+		count++
+		return nil
+	})
+	if count != 1 {
+		t.Errorf("Each returned %d objects, expected the object to be matched after decoding it", count)
+	}
+}
+
+func TestRepo_BackupSince(t *testing.T) {
+	r := newTestRepo(t)
+	old := &pub.Object{ID: "https://example.com/objects/1", Type: pub.NoteType}
+	recent := &pub.Object{ID: "https://example.com/objects/2", Type: pub.NoteType}
+	outbox := pub.IRI("https://example.com/actors/jdoe/outbox")
+	r.Save(old)
+	r.Save(recent)
+	r.Create(pub.OrderedCollectionNew(outbox))
+	r.AddTo(outbox, recent)
+
+	p, _ := r.itemPath(old.ID)
+	hourAgo := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join(p, objectFile), hourAgo, hourAgo); err != nil {
+		t.Fatalf("unable to change the modification time: %s", err)
+	}
+
+	buf := bytes.Buffer{}
+	if err := storage.BackupSince(r, &buf, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("BackupSince returned error: %s", err)
+	}
+	got := make(pub.IRIs, 0)
+	storage.ReadExport(&buf, func(it pub.Item) error {
+		got = append(got, it.GetLink())
+		return nil
+	})
+	want := pub.IRIs{recent.ID, outbox}
+	if len(got) != len(want) || !got.Contains(recent.ID) || !got.Contains(outbox) {
+		t.Errorf("BackupSince wrote %v, expected %v", got, want)
+	}
+}
@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// GCOptions control GC.
+type GCOptions struct {
+	// Local reports if an IRI belongs to the local server, the objects for which it returns false being
+	// copies of remote objects. It is required.
+	Local func(pub.IRI) bool
+	// Before is the moment before which the remote objects need to have been last updated,
+	// or else published, to be collected. The objects without dates are never collected.
+	Before time.Time
+	// DryRun only reports the objects that would be collected, without deleting them.
+	DryRun bool
+}
+
+// GCReport is the result of GC.
+type GCReport struct {
+	// Collected holds the IRIs of the deleted objects, or of the ones which would be deleted in a dry run.
+	Collected pub.IRIs `json:"collected"`
+	// Kept is the number of remote objects which are still referenced, or too recent.
+	Kept uint `json:"kept"`
+}
+
+// GC deletes from the "s" store the copies of remote objects, older than o.Before, which are not members
+// of any local collection, like the inboxes of the local actors, or the replies of their objects.
+// They are federation debris, which would otherwise accumulate forever. If "s" is a ReferenceStore,
+// like a RefCountStore, the objects still referenced by stored activities are kept too.
+//
+// The store needs to be an IterateStore for finding the collections.
+func GC(s Store, o GCOptions) (GCReport, error) {
+	r := GCReport{Collected: make(pub.IRIs, 0)}
+	if o.Local == nil {
+		return r, fmt.Errorf("%w GC options: missing Local", ErrNotValid)
+	}
+	cols := make(map[pub.IRI]struct{})
+	candidates := make(pub.IRIs, 0)
+	err := Each(s, pub.IRI(""), func(it pub.Item) error {
+		iri := it.GetLink()
+		local := o.Local(iri)
+		if pub.CollectionTypes.Contains(it.GetType()) {
+			if local {
+				cols[iri] = struct{}{}
+			}
+			return nil
+		}
+		if local {
+			for _, col := range CollectionsOf(it) {
+				if o.Local(col) {
+					cols[col] = struct{}{}
+				}
+			}
+			return nil
+		}
+		if t := ModifiedAt(it); !t.IsZero() && t.Before(o.Before) {
+			candidates = append(candidates, iri)
+		} else {
+			r.Kept++
+		}
+		return nil
+	})
+	if err != nil {
+		return r, err
+	}
+	if len(candidates) == 0 {
+		return r, nil
+	}
+
+	referenced := make(map[pub.IRI]struct{})
+	for iri := range cols {
+		members, err := collectionMembers(s, iri)
+		if err != nil {
+			return r, err
+		}
+		for _, member := range members {
+			referenced[member] = struct{}{}
+		}
+	}
+	for _, iri := range candidates {
+		if _, ok := referenced[iri]; ok {
+			r.Kept++
+			continue
+		}
+		if rs, ok := s.(ReferenceStore); ok {
+			n, err := rs.References(iri)
+			if err != nil {
+				return r, err
+			}
+			if n > 0 {
+				r.Kept++
+				continue
+			}
+		}
+		if !o.DryRun {
+			if err := s.Delete(iri); err != nil {
+				return r, fmt.Errorf("unable to delete %s: %w", iri, err)
+			}
+		}
+		r.Collected = append(r.Collected, iri)
+	}
+	return r, nil
+}
+
+// collectionMembers returns the IRIs of the members of the "iri" collection, which are none
+// if it's not stored.
+func collectionMembers(s ReadStore, iri pub.IRI) (pub.IRIs, error) {
+	it, err := s.Load(iri)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to load collection %s: %w", iri, err)
+	}
+	col, ok := it.(pub.CollectionInterface)
+	if !ok {
+		return nil, nil
+	}
+	members := make(pub.IRIs, 0, len(col.Collection()))
+	for _, member := range col.Collection() {
+		if !pub.IsNil(member) {
+			members = append(members, member.GetLink())
+		}
+	}
+	return members, nil
+}
@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+func isLocal(iri pub.IRI) bool {
+	return iri.Contains("https://example.com", false)
+}
+
+func TestGC(t *testing.T) {
+	s := newBackendMapStore()
+	old := time.Now().Add(-48 * time.Hour)
+	inbox := pub.IRI("https://example.com/actors/jdoe/inbox")
+	s.Save(&pub.Actor{ID: "https://example.com/actors/jdoe", Type: pub.PersonType, Inbox: inbox})
+	s.Create(pub.OrderedCollectionNew(inbox))
+
+	referenced := note("https://remote.example/objects/1", "referenced")
+	orphan := note("https://remote.example/objects/2", "orphan")
+	recent := note("https://remote.example/objects/3", "recent")
+	undated := note("https://remote.example/objects/4", "undated")
+	referenced.Published, orphan.Published, recent.Published = old, old, time.Now()
+	for _, ob := range []*pub.Object{referenced, orphan, recent, undated} {
+		s.Save(ob)
+	}
+	s.AddTo(inbox, referenced)
+
+	o := GCOptions{Local: isLocal, Before: time.Now().Add(-24 * time.Hour), DryRun: true}
+	report, err := GC(s, o)
+	if err != nil {
+		t.Fatalf("GC returned error: %s", err)
+	}
+	if len(report.Collected) != 1 || report.Collected[0] != orphan.ID || report.Kept != 3 {
+		t.Errorf("GC returned %+v, expected only %s to be collected", report, orphan.ID)
+	}
+	if ok, _ := Exists(s, orphan.ID); !ok {
+		t.Errorf("GC should not delete anything in a dry run")
+	}
+
+	o.DryRun = false
+	if _, err := GC(s, o); err != nil {
+		t.Fatalf("GC returned error: %s", err)
+	}
+	if ok, _ := Exists(s, orphan.ID); ok {
+		t.Errorf("GC should delete the orphaned object")
+	}
+	if ok, _ := Exists(s, referenced.ID); !ok {
+		t.Errorf("GC should keep the objects referenced by local collections")
+	}
+	if _, err := GC(s, GCOptions{}); !errors.Is(err, ErrNotValid) {
+		t.Errorf("GC without Local returned %v, expected %s", err, ErrNotValid)
+	}
+}
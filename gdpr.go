@@ -0,0 +1,282 @@
+package storage
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// The names of the files of the archives written by ExportActor.
+const (
+	ActorItemsFile    = "items.jsonl"
+	ActorMetadataFile = "metadata.json"
+)
+
+// ActorExportOptions control ExportActor.
+type ActorExportOptions struct {
+	// Namespaces are the namespaces of the metadata of the actor that get exported, if the store is
+	// a MetadataStore. Secrets, like the private keys, might need to be left out.
+	Namespaces []string
+}
+
+// AddressedTo reports if "it" has "actor" in any of its to, cc, bto, bcc and audience properties.
+func AddressedTo(it pub.Item, actor pub.IRI) bool {
+	addressed := false
+	pub.OnObject(it, func(ob *pub.Object) error {
+		for _, rcpts := range []pub.ItemCollection{ob.To, ob.CC, ob.Bto, ob.BCC, ob.Audience} {
+			if rcpts.Contains(actor) {
+				addressed = true
+			}
+		}
+		return nil
+	})
+	return addressed
+}
+
+// ExportActor writes to "w" a gzipped tarball with the data the "s" store holds about "actor",
+// for satisfying data portability requests. It contains:
+//
+//   - items.jsonl: in the Export format, the actor, the items attributed to it, see OwnerOf, and the ones
+//     addressed to it, see AddressedTo, followed by the collections of the actor and of its items.
+//   - metadata.json: the metadata of the actor in the o.Namespaces, as an object with the namespaces as keys,
+//     and the base64 encoded metadata as values.
+//
+// The store needs to be an IterateStore, for finding the items.
+func ExportActor(s ReadStore, actor pub.IRI, w io.Writer, o ActorExportOptions) error {
+	items := bytes.Buffer{}
+	cols := make(pub.IRIs, 0)
+	seen := make(map[pub.IRI]struct{})
+	found := false
+	err := Each(s, pub.IRI(""), func(it pub.Item) error {
+		if pub.CollectionTypes.Contains(it.GetType()) {
+			return nil
+		}
+		owned := OwnerOf(it) == actor
+		if !owned && !AddressedTo(it, actor) {
+			return nil
+		}
+		found = found || it.GetLink() == actor
+		if owned {
+			for _, col := range CollectionsOf(it) {
+				if _, ok := seen[col]; !ok {
+					seen[col] = struct{}{}
+					cols = append(cols, col)
+				}
+			}
+		}
+		return WriteExport(&items, it)
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("unable to find actor %s: %w", actor, ErrNotFound)
+	}
+	for _, iri := range cols {
+		col, err := s.Load(iri)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := WriteExport(&items, col); err != nil {
+			return err
+		}
+	}
+
+	metadata := make(map[string][]byte)
+	if ms, ok := s.(MetadataStore); ok {
+		for _, ns := range o.Namespaces {
+			data, err := ms.LoadMetadata(actor, ns)
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("unable to load the %s metadata of %s: %w", ns, actor, err)
+			}
+			metadata[ns] = data
+		}
+	}
+	raw, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+	for _, f := range []struct {
+		name string
+		data []byte
+	}{
+		{name: ActorItemsFile, data: items.Bytes()},
+		{name: ActorMetadataFile, data: raw},
+	} {
+		if err := tw.WriteHeader(&tar.Header{Name: f.name, Mode: 0o600, Size: int64(len(f.data)), ModTime: now}); err != nil {
+			return err
+		}
+		if _, err := tw.Write(f.data); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// EraseOptions control Erase.
+type EraseOptions struct {
+	// Purge deletes the items completely, instead of replacing them with Tombstones, which keep
+	// their IDs from being reused, see Tombstone. The Tombstones keep none of the recipients of the items.
+	Purge bool
+	// Namespaces are the namespaces of the metadata of the actor which get cleared, in addition to
+	// KeysNamespace, if the store is a MetadataStore.
+	Namespaces []string
+}
+
+// EraseReport is the result of Erase.
+type EraseReport struct {
+	// Deleted holds the IRIs of the deleted items.
+	Deleted pub.IRIs `json:"deleted"`
+	// Collections holds the IRIs of the deleted collections of the actor and of its items.
+	Collections pub.IRIs `json:"collections"`
+	// Memberships is the number of times one of the deleted items has been removed from a collection.
+	Memberships uint `json:"memberships"`
+	// Metadata holds the cleared namespaces of the metadata of the actor.
+	Metadata []string `json:"metadata"`
+}
+
+// Erase deletes from the "s" store the "actor" and the items attributed to it, see OwnerOf, for satisfying
+// the requests to be forgotten. Their own collections, like the outbox of the actor or the replies of its
+// objects, are deleted, and they are removed from the other collections of the stored items, see CollectionsOf,
+// if "s" is a CollectionStore.
+// The metadata of the actor in the KeysNamespace and the o.Namespaces is cleared, by replacing it with
+// empty data, as MetadataStore doesn't have a delete operation.
+// The items are deleted before being replaced by their Tombstones, see Bury, so none of their versions,
+// or binary data, is kept.
+//
+// The store needs to be an IterateStore, for finding the items. It stops at the first error, and returns
+// what has been erased until that moment.
+func Erase(s Store, actor pub.IRI, o EraseOptions) (EraseReport, error) {
+	r := EraseReport{Deleted: make(pub.IRIs, 0), Collections: make(pub.IRIs, 0), Metadata: make([]string, 0)}
+	owned := make(map[pub.IRI]pub.Item)
+	items := make(pub.IRIs, 0)
+	ownCols := make(map[pub.IRI]struct{})
+	cols := make(pub.IRIs, 0)
+	seen := make(map[pub.IRI]struct{})
+	collection := func(iri pub.IRI) {
+		if _, ok := seen[iri]; !ok {
+			seen[iri] = struct{}{}
+			cols = append(cols, iri)
+		}
+	}
+	// the collections are found as items, for the backends which iterate them, and as properties of the items
+	err := Each(s, pub.IRI(""), func(it pub.Item) error {
+		if pub.CollectionTypes.Contains(it.GetType()) {
+			collection(it.GetLink())
+			return nil
+		}
+		for _, col := range CollectionsOf(it) {
+			collection(col)
+		}
+		if OwnerOf(it) != actor || it.GetType() == pub.TombstoneType {
+			return nil
+		}
+		owned[it.GetLink()] = it
+		items = append(items, it.GetLink())
+		for _, col := range CollectionsOf(it) {
+			ownCols[col] = struct{}{}
+		}
+		return nil
+	})
+	if err != nil {
+		return r, err
+	}
+	if _, ok := owned[actor]; !ok {
+		return r, fmt.Errorf("unable to find actor %s: %w", actor, ErrNotFound)
+	}
+
+	if cs, ok := s.(CollectionStore); ok {
+		for _, col := range cols {
+			if _, ok := ownCols[col]; ok {
+				continue
+			}
+			members, err := collectionMembers(s, col)
+			if err != nil {
+				return r, err
+			}
+			for _, member := range members {
+				if _, ok := owned[member]; !ok {
+					continue
+				}
+				if err := cs.RemoveFrom(col, member); err != nil {
+					return r, fmt.Errorf("unable to remove %s from %s: %w", member, col, err)
+				}
+				r.Memberships++
+			}
+		}
+	}
+	for _, col := range cols {
+		if _, ok := ownCols[col]; !ok {
+			continue
+		}
+		err := s.Delete(col)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return r, fmt.Errorf("unable to delete collection %s: %w", col, err)
+		}
+		r.Collections = append(r.Collections, col)
+	}
+
+	if ms, ok := s.(MetadataStore); ok {
+		for _, ns := range append([]string{KeysNamespace}, o.Namespaces...) {
+			if err := ms.SaveMetadata(actor, ns, nil); err != nil {
+				return r, fmt.Errorf("unable to clear the %s metadata of %s: %w", ns, actor, err)
+			}
+			r.Metadata = append(r.Metadata, ns)
+		}
+	}
+
+	now := time.Now()
+	for _, iri := range items {
+		// the actor goes last, so it can be erased again if anything fails
+		if iri == actor {
+			continue
+		}
+		if err := erase(s, owned[iri], o.Purge, now); err != nil {
+			return r, err
+		}
+		r.Deleted = append(r.Deleted, iri)
+	}
+	if err := erase(s, owned[actor], o.Purge, now); err != nil {
+		return r, err
+	}
+	r.Deleted = append(r.Deleted, actor)
+	return r, nil
+}
+
+func erase(s Store, it pub.Item, purge bool, now time.Time) error {
+	var err error
+	if purge {
+		err = s.Delete(it.GetLink())
+	} else {
+		t := Tombstone(it, now)
+		t.To, t.Bto, t.CC, t.BCC, t.Audience = nil, nil, nil, nil, nil
+		err = Bury(s, t)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to erase %s: %w", it.GetLink(), err)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+func TestAddressedTo(t *testing.T) {
+	jdoe := pub.IRI("https://example.com/actors/jdoe")
+	ob := note("https://example.org/objects/1", "hi")
+	if AddressedTo(ob, jdoe) {
+		t.Errorf("AddressedTo returned true for an object without recipients")
+	}
+	ob.BCC = pub.ItemCollection{jdoe}
+	if !AddressedTo(ob, jdoe) {
+		t.Errorf("AddressedTo returned false for an object with %s in bcc", jdoe)
+	}
+}
+
+func TestExportActor(t *testing.T) {
+	s := newBackendMapStore()
+	jdoe := &pub.Actor{ID: "https://example.com/actors/jdoe", Type: pub.PersonType, Outbox: pub.IRI("https://example.com/actors/jdoe/outbox")}
+	s.Save(jdoe)
+	s.Create(pub.OrderedCollectionNew(jdoe.Outbox.GetLink()))
+	s.SaveMetadata(jdoe.ID, "settings", []byte(`{"theme":"dark"}`))
+	s.SaveMetadata(jdoe.ID, "key", []byte("secret"))
+
+	own := note("https://example.com/objects/1", "mine")
+	own.AttributedTo = jdoe.ID
+	mention := note("https://example.org/objects/2", "hi @jdoe")
+	mention.To = pub.ItemCollection{jdoe.ID}
+	other := note("https://example.org/objects/3", "unrelated")
+	for _, ob := range []*pub.Object{own, mention, other} {
+		s.Save(ob)
+	}
+	s.AddTo(jdoe.Outbox.GetLink(), own)
+
+	buf := bytes.Buffer{}
+	if err := ExportActor(s, jdoe.ID, &buf, ActorExportOptions{Namespaces: []string{"settings"}}); err != nil {
+		t.Fatalf("ExportActor returned error: %s", err)
+	}
+	gz, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("the export should be gzipped: %s", err)
+	}
+	files := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	for hdr, err := tr.Next(); err == nil; hdr, err = tr.Next() {
+		files[hdr.Name], _ = io.ReadAll(tr)
+	}
+	exported := make(pub.IRIs, 0)
+	ReadExport(bytes.NewReader(files[ActorItemsFile]), func(it pub.Item) error {
+		exported = append(exported, it.GetLink())
+		return nil
+	})
+	expected := pub.IRIs{jdoe.ID, own.ID, mention.ID, jdoe.Outbox.GetLink()}
+	if len(exported) != len(expected) || exported.Contains(other.ID) || !exported.Contains(mention.ID) {
+		t.Errorf("ExportActor exported %v, expected %v", exported, expected)
+	}
+	if string(files[ActorMetadataFile]) != `{"settings":"eyJ0aGVtZSI6ImRhcmsifQ=="}` {
+		t.Errorf("ExportActor exported the metadata %s, expected only the settings", files[ActorMetadataFile])
+	}
+	if err := ExportActor(s, "https://example.com/actors/alice", io.Discard, ActorExportOptions{}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ExportActor of a missing actor returned %v, expected %s", err, ErrNotFound)
+	}
+}
+
+func TestErase(t *testing.T) {
+	s := newBackendMapStore()
+	jdoe := &pub.Actor{ID: "https://example.com/actors/jdoe", Type: pub.PersonType, Outbox: pub.IRI("https://example.com/actors/jdoe/outbox")}
+	alice := &pub.Actor{ID: "https://example.com/actors/alice", Type: pub.PersonType, Inbox: pub.IRI("https://example.com/actors/alice/inbox")}
+	s.Save(jdoe)
+	s.Save(alice)
+	s.Create(pub.OrderedCollectionNew(jdoe.Outbox.GetLink()))
+	s.Create(pub.OrderedCollectionNew(alice.Inbox.GetLink()))
+	Keys(s).SaveKey(jdoe.ID, Key{})
+	s.SaveMetadata(jdoe.ID, "settings", []byte(`{"theme":"dark"}`))
+
+	own := note("https://example.com/objects/1", "mine")
+	own.AttributedTo = jdoe.ID
+	own.To = pub.ItemCollection{alice.ID}
+	own.CC = pub.ItemCollection{pub.PublicNS}
+	own.Audience = pub.ItemCollection{alice.ID}
+	reply := note("https://example.com/objects/2", "reply")
+	reply.AttributedTo = alice.ID
+	s.Save(note(own.ID, "draft"))
+	s.Save(own)
+	s.SaveBinary(own.ID, strings.NewReader("mine"), "text/plain")
+	s.Save(reply)
+	s.AddTo(jdoe.Outbox.GetLink(), own)
+	s.AddTo(alice.Inbox.GetLink(), own)
+	s.AddTo(alice.Inbox.GetLink(), reply)
+
+	report, err := Erase(s, jdoe.ID, EraseOptions{Namespaces: []string{"settings"}})
+	if err != nil {
+		t.Fatalf("Erase returned error: %s", err)
+	}
+	if len(report.Deleted) != 2 || report.Deleted[1] != jdoe.ID || report.Memberships != 1 || len(report.Metadata) != 2 {
+		t.Errorf("unexpected report %+v", report)
+	}
+	if len(report.Collections) != 1 || report.Collections[0] != jdoe.Outbox.GetLink() {
+		t.Errorf("Erase should delete the outbox of the actor, deleted %v", report.Collections)
+	}
+	for _, iri := range []pub.IRI{jdoe.ID, own.ID} {
+		if it, err := s.Load(iri); err != nil || it.GetType() != pub.TombstoneType {
+			t.Errorf("Erase should replace %s with a Tombstone, got %v, %v", iri, it, err)
+		}
+		if versions, _ := s.LoadVersions(iri); len(versions) != 0 {
+			t.Errorf("Erase should delete the versions of %s, got %v", iri, versions)
+		}
+	}
+	if _, _, err := s.LoadBinary(own.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Erase should delete the binary data of %s, LoadBinary returned %v", own.ID, err)
+	}
+	if it, _ := s.Load(own.ID); it != nil {
+		pub.OnObject(it, func(o *pub.Object) error {
+			if len(o.To)+len(o.Bto)+len(o.CC)+len(o.BCC)+len(o.Audience) > 0 {
+				t.Errorf("Erase should remove the recipients of %s, got %v", own.ID, o)
+			}
+			return nil
+		})
+	}
+	if members := s.members[alice.Inbox.GetLink()]; len(members) != 1 {
+		t.Errorf("Erase should remove the items of the actor from the other collections, got %v", members)
+	}
+	if data, _ := s.LoadMetadata(jdoe.ID, "settings"); len(data) != 0 {
+		t.Errorf("Erase should clear the metadata, got %s", data)
+	}
+	if _, err := Erase(s, jdoe.ID, EraseOptions{Purge: true}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Erase of an erased actor returned %v, expected %s", err, ErrNotFound)
+	}
+}
@@ -0,0 +1,263 @@
+// Package grpc implements a storage backend that forwards the operations over gRPC to a remote store,
+// served by the service registered with Register, so the storage can run in a separate process, or host,
+// than the ActivityPub service. It's the gRPC alternative to the rest package, for the deployments which
+// already use gRPC between their services.
+//
+// The protocol is the Storage service of storagepb/storage.proto, which exchanges the items as JSON-LD
+// documents, with the requests authenticated with a bearer token, in the "authorization" metadata.
+// The errors are sent with the code matching their kind, eg: NotFound for storage.ErrNotFound, and an
+// errdetails.ErrorInfo identifying it.
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage"
+	"github.com/go-ap/storage/grpc/storagepb"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// authorization is the metadata key of the bearer token.
+const authorization = "authorization"
+
+// Config holds the options for a Client.
+type Config struct {
+	// Address is the address of the server, in the gRPC name syntax, eg: "storage.example.com:9090".
+	Address string
+	// Token is the bearer token authenticating the requests.
+	Token string
+	// Credentials secure the connection to the server, which is not encrypted if not set, eg: for a
+	// server on the same host.
+	Credentials credentials.TransportCredentials
+	// Timeout is how long each operation waits for the server, before failing with context.DeadlineExceeded.
+	// It waits for as long as the server needs if it's not set.
+	Timeout time.Duration
+	// Conn is the connection to the server, which Close doesn't close, as it can be shared. A connection
+	// to Address is created if it's not set.
+	Conn *grpc.ClientConn
+}
+
+// Client is a storage.Store, and storage.CollectionStore, forwarding the operations to a remote server.
+type Client struct {
+	c       storagepb.StorageClient
+	conn    *grpc.ClientConn
+	auth    grpc.CallOption
+	timeout time.Duration
+	// owned is set if the Client created "conn", and needs to close it.
+	owned  bool
+	closed atomic.Bool
+}
+
+var (
+	_ storage.Store           = &Client{}
+	_ storage.CollectionStore = &Client{}
+)
+
+// New returns a Client for the server at c.Address. The connection is established on the first operation.
+func New(c Config) (*Client, error) {
+	cl := &Client{conn: c.Conn, auth: grpc.PerRPCCredentials(bearer(c.Token)), timeout: c.Timeout}
+	if cl.conn == nil {
+		if len(c.Address) == 0 {
+			return nil, fmt.Errorf("%w: the address of the storage is empty", storage.ErrNotValid)
+		}
+		creds := c.Credentials
+		if creds == nil {
+			creds = insecure.NewCredentials()
+		}
+		conn, err := grpc.NewClient(c.Address, grpc.WithTransportCredentials(creds))
+		if err != nil {
+			return nil, fmt.Errorf("%w address %s: %s", storage.ErrNotValid, c.Address, err)
+		}
+		cl.conn, cl.owned = conn, true
+	}
+	cl.c = storagepb.NewStorageClient(cl.conn)
+	return cl, nil
+}
+
+// Close closes the Client, after which its operations return storage.ErrClosed. The connection to the
+// server is closed only if it's not the one of the Config, which can be shared.
+func (c *Client) Close() error {
+	if c.closed.Swap(true) || !c.owned {
+		return nil
+	}
+	return c.conn.Close()
+}
+
+// bearer sends the token in the "authorization" metadata of the requests.
+type bearer string
+
+func (b bearer) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{authorization: "Bearer " + string(b)}, nil
+}
+
+func (b bearer) RequireTransportSecurity() bool {
+	return false
+}
+
+// call runs "fn" with the context of an operation, and returns the error matching the status it fails with.
+func (c *Client) call(fn func(ctx context.Context) error) error {
+	if c.closed.Load() {
+		return storage.ErrClosed
+	}
+	ctx := context.Background()
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	return clientError(fn(ctx))
+}
+
+// clientError returns the error for the status of a failed call, wrapping the error of the storage package
+// identified by its errdetails.ErrorInfo, if any.
+func clientError(err error) error {
+	if err == nil {
+		return nil
+	}
+	s, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	if s.Code() == codes.DeadlineExceeded {
+		return fmt.Errorf("%w: %s", context.DeadlineExceeded, s.Message())
+	}
+	for _, d := range s.Details() {
+		info, ok := d.(*errdetails.ErrorInfo)
+		if !ok || info.GetDomain() != errorDomain {
+			continue
+		}
+		for _, c := range errorCodes {
+			if c.reason == info.GetReason() {
+				return fmt.Errorf("%w: %s", c.err, s.Message())
+			}
+		}
+	}
+	return fmt.Errorf("%s: %s", s.Code(), s.Message())
+}
+
+// Load returns the "iri" item from the remote store.
+func (c *Client) Load(iri pub.IRI) (pub.Item, error) {
+	var res *storagepb.Item
+	err := c.call(func(ctx context.Context) error {
+		var err error
+		res, err = c.c.Load(ctx, &storagepb.LoadRequest{Iri: iri.String()}, c.auth)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return decode(res)
+}
+
+// Save saves "it" to the remote store, and returns it as saved.
+func (c *Client) Save(it pub.Item) (pub.Item, error) {
+	req, err := encode(it)
+	if err != nil {
+		return nil, err
+	}
+	var res *storagepb.Item
+	err = c.call(func(ctx context.Context) error {
+		res, err = c.c.Save(ctx, req, c.auth)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return decode(res)
+}
+
+// Delete deletes "it" from the remote store.
+func (c *Client) Delete(it pub.Item) error {
+	if pub.IsNil(it) {
+		return fmt.Errorf("%w: nil item", storage.ErrNotValid)
+	}
+	return c.call(func(ctx context.Context) error {
+		_, err := c.c.Delete(ctx, &storagepb.DeleteRequest{Iri: it.GetLink().String()}, c.auth)
+		return err
+	})
+}
+
+// Create creates the "col" collection in the remote store.
+func (c *Client) Create(col pub.CollectionInterface) (pub.CollectionInterface, error) {
+	if pub.IsNil(col) {
+		return nil, fmt.Errorf("%w: nil collection", storage.ErrNotValid)
+	}
+	req, err := encode(col)
+	if err != nil {
+		return nil, err
+	}
+	var res *storagepb.Item
+	err = c.call(func(ctx context.Context) error {
+		res, err = c.c.Create(ctx, req, c.auth)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	it, err := decode(res)
+	if err != nil {
+		return nil, err
+	}
+	created, ok := it.(pub.CollectionInterface)
+	if !ok {
+		return nil, errors.New("the response is not a collection")
+	}
+	return created, nil
+}
+
+// AddTo adds "it" to the "col" collection in the remote store.
+func (c *Client) AddTo(col pub.IRI, it pub.Item) error {
+	item, err := encode(it)
+	if err != nil {
+		return err
+	}
+	return c.call(func(ctx context.Context) error {
+		_, err := c.c.AddTo(ctx, &storagepb.AddToRequest{Collection: col.String(), Item: item}, c.auth)
+		return err
+	})
+}
+
+// RemoveFrom removes "it" from the "col" collection in the remote store.
+func (c *Client) RemoveFrom(col pub.IRI, it pub.Item) error {
+	if pub.IsNil(it) {
+		return fmt.Errorf("%w: nil item", storage.ErrNotValid)
+	}
+	return c.call(func(ctx context.Context) error {
+		_, err := c.c.RemoveFrom(ctx, &storagepb.RemoveFromRequest{Collection: col.String(), Member: it.GetLink().String()}, c.auth)
+		return err
+	})
+}
+
+// encode returns the message holding the JSON-LD document of "it".
+func encode(it pub.Item) (*storagepb.Item, error) {
+	if pub.IsNil(it) {
+		return nil, fmt.Errorf("%w: nil item", storage.ErrNotValid)
+	}
+	raw, err := pub.MarshalJSON(it)
+	if err != nil {
+		return nil, err
+	}
+	return &storagepb.Item{Raw: raw}, nil
+}
+
+// decode returns the item of the JSON-LD document of "m".
+func decode(m *storagepb.Item) (pub.Item, error) {
+	it, err := pub.UnmarshalJSON(m.GetRaw())
+	if err != nil {
+		return nil, fmt.Errorf("%w document: %s", storage.ErrNotValid, err)
+	}
+	if pub.IsNil(it) {
+		return nil, fmt.Errorf("%w: empty document", storage.ErrNotValid)
+	}
+	return it, nil
+}
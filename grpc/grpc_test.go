@@ -0,0 +1,123 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage"
+	"github.com/go-ap/storage/memory"
+	"github.com/go-ap/storage/storagetest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newTestClient returns a Client of the server of "s", over an in-memory connection.
+func newTestClient(t *testing.T, s storage.Store, token string) *Client {
+	lis := bufconn.Listen(1 << 20)
+	gs := grpc.NewServer()
+	Register(gs, s, "secret")
+	go gs.Serve(lis)
+	t.Cleanup(gs.Stop)
+
+	dial := func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }
+	conn, err := grpc.NewClient("passthrough:///bufnet", grpc.WithContextDialer(dial),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("unable to connect to the server: %s", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	c, err := New(Config{Conn: conn, Token: token})
+	if err != nil {
+		t.Fatalf("New returned error: %s", err)
+	}
+	return c
+}
+
+func TestConformance(t *testing.T) {
+	storagetest.RunStoreTests(t, func() storage.Store {
+		return newTestClient(t, memory.New(), "secret")
+	})
+}
+
+func TestClient_errors(t *testing.T) {
+	c := newTestClient(t, memory.New(), "secret")
+	if _, err := c.Load("https://example.com/objects/1"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Load of a missing item returned %v, expected %s", err, storage.ErrNotFound)
+	}
+	if _, err := c.Save(&pub.Object{Type: pub.NoteType}); !errors.Is(err, storage.ErrNotValid) {
+		t.Errorf("Save of an object without ID returned %v, expected %s", err, storage.ErrNotValid)
+	}
+
+	unauthorized := newTestClient(t, memory.New(), "invalid")
+	if _, err := unauthorized.Load("https://example.com/objects/1"); err == nil || errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Load with an invalid token returned %v, expected it to be rejected", err)
+	}
+	if _, err := New(Config{}); !errors.Is(err, storage.ErrNotValid) {
+		t.Errorf("New without an address returned %v, expected %s", err, storage.ErrNotValid)
+	}
+}
+
+func TestClient_unreachable(t *testing.T) {
+	c, err := New(Config{Address: "127.0.0.1:1", Token: "secret"})
+	if err != nil {
+		t.Fatalf("New returned error: %s", err)
+	}
+	defer c.Close()
+	if _, err := c.Load("https://example.com/objects/1"); err == nil || errors.Is(err, storage.ErrClosed) {
+		t.Errorf("Load from an unreachable server returned %v, expected it not to be mistaken for a closed storage", err)
+	}
+}
+
+func TestRegister_emptyToken(t *testing.T) {
+	lis := bufconn.Listen(1 << 20)
+	gs := grpc.NewServer()
+	Register(gs, memory.New(), "")
+	go gs.Serve(lis)
+	defer gs.Stop()
+
+	dial := func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }
+	conn, err := grpc.NewClient("passthrough:///bufnet", grpc.WithContextDialer(dial),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("unable to connect to the server: %s", err)
+	}
+	defer conn.Close()
+	c, _ := New(Config{Conn: conn})
+	if _, err := c.Load("https://example.com/objects/1"); err == nil || errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Load with an empty token returned %v, expected it to be rejected", err)
+	}
+}
+
+// failingStore is a Store whose operations fail with an error disclosing the details of the backend.
+type failingStore struct {
+	storage.Store
+}
+
+var errBackend = errors.New("unable to connect to postgres://admin@10.0.0.1:5432/storage")
+
+func (failingStore) Load(pub.IRI) (pub.Item, error) {
+	return nil, errBackend
+}
+
+func (failingStore) Delete(pub.Item) error {
+	return storage.ErrClosed
+}
+
+func TestServer_errors(t *testing.T) {
+	c := newTestClient(t, failingStore{}, "secret")
+	_, err := c.Load("https://example.com/objects/1")
+	if err == nil || strings.Contains(err.Error(), "10.0.0.1") {
+		t.Errorf("Load failing in the backend returned %v, expected an error without its details", err)
+	}
+	if err := c.Delete(pub.IRI("https://example.com/objects/1")); !errors.Is(err, storage.ErrClosed) {
+		t.Errorf("Delete on a closed backend returned %v, expected %s", err, storage.ErrClosed)
+	}
+	if _, err := c.Create(pub.OrderedCollectionNew("https://example.com/outbox")); err == nil {
+		t.Errorf("Create on a storage without collections should fail")
+	}
+}
@@ -0,0 +1,179 @@
+package grpc
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+
+	pub "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage"
+	"github.com/go-ap/storage/grpc/storagepb"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Register registers the service serving the "s" store to "gs", for the requests authenticated with the
+// "token" bearer token, all the requests are rejected when "token" is empty.
+// The collection methods need "s" to be a storage.CollectionStore.
+func Register(gs grpc.ServiceRegistrar, s storage.Store, token string) {
+	storagepb.RegisterStorageServer(gs, &server{s: s, token: token})
+}
+
+type server struct {
+	storagepb.UnimplementedStorageServer
+	s     storage.Store
+	token string
+}
+
+func (srv *server) authenticate(ctx context.Context) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(authorization)
+	if len(srv.token) == 0 || len(values) != 1 ||
+		subtle.ConstantTimeCompare([]byte(values[0]), []byte("Bearer "+srv.token)) != 1 {
+		return status.Error(codes.Unauthenticated, "invalid token")
+	}
+	return nil
+}
+
+func (srv *server) collectionStore() (storage.CollectionStore, error) {
+	cs, ok := srv.s.(storage.CollectionStore)
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "the storage doesn't support collections")
+	}
+	return cs, nil
+}
+
+func (srv *server) Load(ctx context.Context, req *storagepb.LoadRequest) (*storagepb.Item, error) {
+	if err := srv.authenticate(ctx); err != nil {
+		return nil, err
+	}
+	it, err := srv.s.Load(pub.IRI(req.GetIri()))
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return encode(it)
+}
+
+func (srv *server) Save(ctx context.Context, req *storagepb.Item) (*storagepb.Item, error) {
+	if err := srv.authenticate(ctx); err != nil {
+		return nil, err
+	}
+	it, err := decode(req)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	saved, err := srv.s.Save(it)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	if pub.IsNil(saved) {
+		saved = it
+	}
+	return encode(saved)
+}
+
+func (srv *server) Delete(ctx context.Context, req *storagepb.DeleteRequest) (*storagepb.Empty, error) {
+	if err := srv.authenticate(ctx); err != nil {
+		return nil, err
+	}
+	if err := srv.s.Delete(pub.IRI(req.GetIri())); err != nil {
+		return nil, statusError(err)
+	}
+	return &storagepb.Empty{}, nil
+}
+
+func (srv *server) Create(ctx context.Context, req *storagepb.Item) (*storagepb.Item, error) {
+	if err := srv.authenticate(ctx); err != nil {
+		return nil, err
+	}
+	cs, err := srv.collectionStore()
+	if err != nil {
+		return nil, err
+	}
+	it, err := decode(req)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	col, ok := it.(pub.CollectionInterface)
+	if !ok {
+		return nil, statusError(fmt.Errorf("%w: %s is not a collection", storage.ErrNotValid, it.GetLink()))
+	}
+	created, err := cs.Create(col)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return encode(created)
+}
+
+func (srv *server) AddTo(ctx context.Context, req *storagepb.AddToRequest) (*storagepb.Empty, error) {
+	if err := srv.authenticate(ctx); err != nil {
+		return nil, err
+	}
+	cs, err := srv.collectionStore()
+	if err != nil {
+		return nil, err
+	}
+	it, err := decode(req.GetItem())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	if err := cs.AddTo(pub.IRI(req.GetCollection()), it); err != nil {
+		return nil, statusError(err)
+	}
+	return &storagepb.Empty{}, nil
+}
+
+func (srv *server) RemoveFrom(ctx context.Context, req *storagepb.RemoveFromRequest) (*storagepb.Empty, error) {
+	if err := srv.authenticate(ctx); err != nil {
+		return nil, err
+	}
+	cs, err := srv.collectionStore()
+	if err != nil {
+		return nil, err
+	}
+	if err := cs.RemoveFrom(pub.IRI(req.GetCollection()), pub.IRI(req.GetMember())); err != nil {
+		return nil, statusError(err)
+	}
+	return &storagepb.Empty{}, nil
+}
+
+// errorDomain is the domain of the errdetails.ErrorInfo identifying the kind of the errors.
+const errorDomain = "storage.go-ap.github.io"
+
+// errorCodes maps the kinds of errors of the storage package to the gRPC codes sent for them, and to the
+// reasons of their errdetails.ErrorInfo, as the codes don't match them one to one, eg: the failures to
+// reach the server have the Unavailable code too.
+var errorCodes = []struct {
+	err    error
+	code   codes.Code
+	reason string
+}{
+	{err: storage.ErrNotFound, code: codes.NotFound, reason: "NOT_FOUND"},
+	{err: storage.ErrGone, code: codes.NotFound, reason: "GONE"},
+	{err: storage.ErrDuplicate, code: codes.AlreadyExists, reason: "DUPLICATE"},
+	{err: storage.ErrConflict, code: codes.Aborted, reason: "CONFLICT"},
+	{err: storage.ErrNotValid, code: codes.InvalidArgument, reason: "NOT_VALID"},
+	{err: storage.ErrReadOnly, code: codes.FailedPrecondition, reason: "READ_ONLY"},
+	{err: storage.ErrQuotaExceeded, code: codes.ResourceExhausted, reason: "QUOTA_EXCEEDED"},
+	{err: storage.ErrClosed, code: codes.Unavailable, reason: "CLOSED"},
+}
+
+// statusError returns the status matching the kind of "err", with its message for the client errors only,
+// so the details of the failures of the backend, like the addresses of its servers, are not disclosed.
+func statusError(err error) error {
+	for _, c := range errorCodes {
+		if !errors.Is(err, c.err) {
+			continue
+		}
+		s := status.New(c.code, err.Error())
+		if d, derr := s.WithDetails(&errdetails.ErrorInfo{Reason: c.reason, Domain: errorDomain}); derr == nil {
+			s = d
+		}
+		return s.Err()
+	}
+	return status.Error(codes.Internal, "internal error")
+}
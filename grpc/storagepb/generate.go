@@ -0,0 +1,4 @@
+// Package storagepb holds the code generated from storage.proto, the protocol of the grpc package.
+package storagepb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative storage.proto
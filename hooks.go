@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"sync"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// ItemHookFn is the type of the callbacks run after an item has been saved or deleted.
+type ItemHookFn func(it pub.Item) error
+
+// CollectionHookFn is the type of the callbacks run after an item has been added to, or removed from, a collection.
+type CollectionHookFn func(col pub.IRI, it pub.Item) error
+
+// HookStore is a Store that runs callbacks after each successful write, which allows applications
+// to maintain derived data, like notifications, search indexes or counters, regardless of the backend.
+//
+// The hooks run synchronously, in the order in which they have been registered. All of them run even
+// if some fail, and the write returns the error of the first one that failed, but it is not rolled back.
+type HookStore struct {
+	Store
+
+	mu           sync.RWMutex
+	onSave       []ItemHookFn
+	onDelete     []ItemHookFn
+	onAddTo      []CollectionHookFn
+	onRemoveFrom []CollectionHookFn
+}
+
+// Hooks returns a HookStore wrapping "s", without any hooks registered.
+func Hooks(s Store) *HookStore {
+	return &HookStore{Store: s}
+}
+
+// OnSave registers "fn" to be called with the saved item after each Save.
+func (h *HookStore) OnSave(fn ItemHookFn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.onSave = append(h.onSave, fn)
+}
+
+// OnDelete registers "fn" to be called with the deleted item after each Delete.
+func (h *HookStore) OnDelete(fn ItemHookFn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.onDelete = append(h.onDelete, fn)
+}
+
+// OnAddTo registers "fn" to be called after each item is added to a collection.
+func (h *HookStore) OnAddTo(fn CollectionHookFn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.onAddTo = append(h.onAddTo, fn)
+}
+
+// OnRemoveFrom registers "fn" to be called after each item is removed from a collection.
+func (h *HookStore) OnRemoveFrom(fn CollectionHookFn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.onRemoveFrom = append(h.onRemoveFrom, fn)
+}
+
+func (h *HookStore) itemHooks(hooks *[]ItemHookFn) []ItemHookFn {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return *hooks
+}
+
+func (h *HookStore) collectionHooks(hooks *[]CollectionHookFn) []CollectionHookFn {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return *hooks
+}
+
+func runItemHooks(hooks []ItemHookFn, it pub.Item) error {
+	var first error
+	for _, fn := range hooks {
+		if err := fn(it); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func runCollectionHooks(hooks []CollectionHookFn, col pub.IRI, it pub.Item) error {
+	var first error
+	for _, fn := range hooks {
+		if err := fn(col, it); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// Save saves "it" to the underlying store, and then runs the OnSave hooks.
+func (h *HookStore) Save(it pub.Item) (pub.Item, error) {
+	saved, err := h.Store.Save(it)
+	if err != nil {
+		return saved, err
+	}
+	if pub.IsNil(saved) {
+		saved = it
+	}
+	return saved, runItemHooks(h.itemHooks(&h.onSave), saved)
+}
+
+// Delete deletes "it" from the underlying store, and then runs the OnDelete hooks.
+func (h *HookStore) Delete(it pub.Item) error {
+	if err := h.Store.Delete(it); err != nil {
+		return err
+	}
+	return runItemHooks(h.itemHooks(&h.onDelete), it)
+}
+
+// Create creates the "col" collection in the underlying store, which needs to be a CollectionStore.
+func (h *HookStore) Create(col pub.CollectionInterface) (pub.CollectionInterface, error) {
+	return asCollectionStore(h.Store).Create(col)
+}
+
+// AddTo adds "it" to the "col" collection in the underlying store, which needs to be a CollectionStore,
+// and then runs the OnAddTo hooks.
+func (h *HookStore) AddTo(col pub.IRI, it pub.Item) error {
+	if err := asCollectionStore(h.Store).AddTo(col, it); err != nil {
+		return err
+	}
+	return runCollectionHooks(h.collectionHooks(&h.onAddTo), col, it)
+}
+
+// RemoveFrom removes "it" from the "col" collection in the underlying store, which needs to be
+// a CollectionStore, and then runs the OnRemoveFrom hooks.
+func (h *HookStore) RemoveFrom(col pub.IRI, it pub.Item) error {
+	if err := asCollectionStore(h.Store).RemoveFrom(col, it); err != nil {
+		return err
+	}
+	return runCollectionHooks(h.collectionHooks(&h.onRemoveFrom), col, it)
+}
@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// collectionMapStore is a mapStore that also keeps the members of collections.
+type collectionMapStore struct {
+	*mapStore
+	members map[pub.IRI]pub.IRIs
+}
+
+func newCollectionMapStore() *collectionMapStore {
+	return &collectionMapStore{mapStore: newMapStore(), members: make(map[pub.IRI]pub.IRIs)}
+}
+
+func (c *collectionMapStore) Create(col pub.CollectionInterface) (pub.CollectionInterface, error) {
+	c.members[col.GetLink()] = pub.IRIs{}
+	return col, nil
+}
+
+func (c *collectionMapStore) AddTo(col pub.IRI, it pub.Item) error {
+	if _, ok := c.members[col]; !ok {
+		return ErrNotFound
+	}
+	c.members[col] = append(c.members[col], it.GetLink())
+	return nil
+}
+
+func (c *collectionMapStore) RemoveFrom(col pub.IRI, it pub.Item) error {
+	if _, ok := c.members[col]; !ok {
+		return ErrNotFound
+	}
+	members := make(pub.IRIs, 0)
+	for _, iri := range c.members[col] {
+		if iri != it.GetLink() {
+			members = append(members, iri)
+		}
+	}
+	c.members[col] = members
+	return nil
+}
+
+func TestHooks(t *testing.T) {
+	h := Hooks(newCollectionMapStore())
+	outbox := pub.IRI("https://example.com/actors/jdoe/outbox")
+	ob := note("https://example.com/objects/1", "hello")
+
+	calls := make([]string, 0)
+	record := func(name string) ItemHookFn {
+		return func(it pub.Item) error {
+			calls = append(calls, name+" "+it.GetLink().String())
+			return nil
+		}
+	}
+	fail := errors.New("fail")
+	h.OnSave(func(pub.Item) error { return fail })
+	h.OnSave(record("save"))
+	h.OnDelete(record("delete"))
+	h.OnAddTo(func(col pub.IRI, it pub.Item) error {
+		calls = append(calls, "add "+it.GetLink().String()+" to "+col.String())
+		return nil
+	})
+	h.OnRemoveFrom(func(col pub.IRI, it pub.Item) error {
+		calls = append(calls, "remove "+it.GetLink().String()+" from "+col.String())
+		return nil
+	})
+
+	if _, err := h.Save(ob); !errors.Is(err, fail) {
+		t.Errorf("Save returned %v, expected the error of the failed hook", err)
+	}
+	if _, err := h.Store.Load(ob.ID); err != nil {
+		t.Errorf("a failed hook should not roll back the write: %s", err)
+	}
+	h.Create(pub.OrderedCollectionNew(outbox))
+	if err := h.AddTo("https://example.com/actors/jdoe/inbox", ob); err == nil {
+		t.Errorf("AddTo a missing collection should fail")
+	}
+	h.AddTo(outbox, ob)
+	h.RemoveFrom(outbox, ob)
+	h.Delete(ob)
+
+	want := []string{
+		"save https://example.com/objects/1",
+		"add https://example.com/objects/1 to https://example.com/actors/jdoe/outbox",
+		"remove https://example.com/objects/1 from https://example.com/actors/jdoe/outbox",
+		"delete https://example.com/objects/1",
+	}
+	if len(calls) != len(want) {
+		t.Fatalf("the hooks have been called %v, expected %v", calls, want)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Errorf("the hooks have been called %v, expected %v", calls, want)
+		}
+	}
+}
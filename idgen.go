@@ -0,0 +1,203 @@
+package storage
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// SequenceCounter is the name of the counter, of the collections, used by SequentialIDs.
+const SequenceCounter = "ids"
+
+// SnowflakeEpoch is the moment from which the timestamps of the SnowflakeIDs are counted.
+var SnowflakeEpoch = time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+func idIn(partOf pub.IRI, id string) (pub.ID, error) {
+	if len(partOf) == 0 {
+		return "", fmt.Errorf("%w: unable to generate an ID without a parent collection", ErrNotValid)
+	}
+	return partOf.AddPath(id), nil
+}
+
+// UUIDs returns an IDGenFn that generates the IDs as random (version 4) UUIDs under the "partOf" collection.
+func UUIDs() IDGenFn {
+	return func(it pub.Item, partOf pub.IRI, by pub.Item) (pub.ID, error) {
+		var u [16]byte
+		if _, err := rand.Read(u[:]); err != nil {
+			return "", err
+		}
+		u[6] = (u[6] & 0x0f) | 0x40
+		u[8] = (u[8] & 0x3f) | 0x80
+		return idIn(partOf, fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:]))
+	}
+}
+
+// SnowflakeIDs returns an IDGenFn that generates IDs sortable by their creation time, made of the
+// milliseconds since the SnowflakeEpoch, the "node" number, and a sequence number for the IDs
+// generated in the same millisecond.
+// Each of the processes sharing a storage needs a different node number, between 0 and 1023.
+func SnowflakeIDs(node uint16) IDGenFn {
+	var (
+		mu   sync.Mutex
+		last int64
+		seq  int64
+	)
+	return func(it pub.Item, partOf pub.IRI, by pub.Item) (pub.ID, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		ms := time.Since(SnowflakeEpoch).Milliseconds()
+		if ms < last {
+			ms = last
+		}
+		if ms == last {
+			seq = (seq + 1) & 0xfff
+			if seq == 0 {
+				// the sequence of the current millisecond is exhausted
+				ms++
+			}
+		} else {
+			seq = 0
+		}
+		last = ms
+		id := ms<<22 | int64(node&0x3ff)<<12 | seq
+		return idIn(partOf, strconv.FormatInt(id, 10))
+	}
+}
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULIDs returns an IDGenFn that generates the IDs as ULIDs: 48 bits of milliseconds since the Unix
+// epoch, followed by 80 random bits, encoded as 26 characters of Crockford's base32.
+// The IDs sort lexicographically in the order of their creation, including the ones generated in the same
+// millisecond, so backends with ordered keys can scan the most recent items without sorting them.
+func ULIDs() IDGenFn {
+	next := ULIDSequence()
+	return func(it pub.Item, partOf pub.IRI, by pub.Item) (pub.ID, error) {
+		ulid, err := next()
+		if err != nil {
+			return "", err
+		}
+		return idIn(partOf, ulid)
+	}
+}
+
+// ULIDSequence returns a function generating ULIDs, see ULIDs, each one greater than the previous ones,
+// for the backends that use them as keys ordered by the moment they've been created.
+func ULIDSequence() func() (string, error) {
+	var (
+		mu   sync.Mutex
+		last uint64
+		// entropy holds the random bits of the last ID, which is incremented for the IDs of the same millisecond
+		entropy [10]byte
+	)
+	return func() (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		ms := uint64(time.Now().UnixMilli())
+		if ms <= last {
+			ms = last
+			i := len(entropy) - 1
+			for ; i >= 0; i-- {
+				entropy[i]++
+				if entropy[i] != 0 {
+					break
+				}
+			}
+			if i < 0 {
+				// the random bits of the current millisecond are exhausted
+				ms++
+			}
+		}
+		if ms != last {
+			if _, err := rand.Read(entropy[:]); err != nil {
+				return "", err
+			}
+		}
+		last = ms
+
+		var u [16]byte
+		for i := 0; i < 6; i++ {
+			u[i] = byte(ms >> (40 - 8*i))
+		}
+		copy(u[6:], entropy[:])
+		return encodeULID(u), nil
+	}
+}
+
+func encodeULID(u [16]byte) string {
+	// the 128 bits are encoded in 26 characters of 5 bits, with the first character holding only 3 bits
+	out := make([]byte, 26)
+	var acc uint32
+	bits := 2 // the leading padding bits, so the length is a multiple of 5
+	idx := 0
+	for _, b := range u {
+		acc = acc<<8 | uint32(b)
+		bits += 8
+		for bits >= 5 {
+			bits -= 5
+			out[idx] = crockford[(acc>>uint(bits))&0x1f]
+			idx++
+		}
+	}
+	return string(out)
+}
+
+// ULIDTime returns the moment encoded in the "ulid" ULID.
+func ULIDTime(ulid string) (time.Time, error) {
+	if len(ulid) != 26 {
+		return time.Time{}, fmt.Errorf("%w: invalid ULID %q", ErrNotValid, ulid)
+	}
+	var ms uint64
+	for _, c := range []byte(ulid[:10]) {
+		v := strings.IndexByte(crockford, c)
+		if v < 0 {
+			return time.Time{}, fmt.Errorf("%w: invalid ULID %q", ErrNotValid, ulid)
+		}
+		ms = ms<<5 | uint64(v)
+	}
+	return time.UnixMilli(int64(ms)).UTC(), nil
+}
+
+// SequentialIDs returns an IDGenFn that numbers the items of each collection, using the
+// SequenceCounter of the "partOf" collection stored in "c".
+func SequentialIDs(c CounterStore) IDGenFn {
+	return func(it pub.Item, partOf pub.IRI, by pub.Item) (pub.ID, error) {
+		if len(partOf) == 0 {
+			return idIn(partOf, "")
+		}
+		n, err := c.IncrementCounter(partOf, SequenceCounter, 1)
+		if err != nil {
+			return "", err
+		}
+		return idIn(partOf, strconv.Itoa(n))
+	}
+}
+
+// ContentHashIDs returns an IDGenFn that derives the IDs from the hash of the item's JSON-LD
+// document and of the IRI of the actor creating it, so saving the same item twice results in the same IRI.
+func ContentHashIDs() IDGenFn {
+	return func(it pub.Item, partOf pub.IRI, by pub.Item) (pub.ID, error) {
+		if pub.IsNil(it) {
+			return "", fmt.Errorf("%w: unable to generate an ID for a nil item", ErrNotValid)
+		}
+		raw, err := pub.MarshalJSON(it)
+		if err != nil {
+			return "", err
+		}
+		h := sha256.New()
+		h.Write(raw)
+		if !pub.IsNil(by) {
+			h.Write([]byte(by.GetLink()))
+		}
+		return idIn(partOf, hex.EncodeToString(h.Sum(nil)[:16]))
+	}
+}
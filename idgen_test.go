@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"testing"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+type counterMap map[pub.IRI]map[string]int
+
+func (c counterMap) IncrementCounter(iri pub.IRI, field string, delta int) (int, error) {
+	if c[iri] == nil {
+		c[iri] = make(map[string]int)
+	}
+	c[iri][field] += delta
+	return c[iri][field], nil
+}
+
+func (c counterMap) LoadCounters(iri pub.IRI) (map[string]int, error) {
+	return c[iri], nil
+}
+
+func TestIDGenerators(t *testing.T) {
+	partOf := pub.IRI("https://example.com/objects")
+	tests := []struct {
+		name  string
+		gen   IDGenFn
+		match *regexp.Regexp
+	}{
+		{name: "UUIDs", gen: UUIDs(), match: regexp.MustCompile(`^https://example.com/objects/[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)},
+		{name: "ULIDs", gen: ULIDs(), match: regexp.MustCompile(`^https://example.com/objects/[0-9A-HJKMNP-TV-Z]{26}$`)},
+		{name: "SnowflakeIDs", gen: SnowflakeIDs(1), match: regexp.MustCompile(`^https://example.com/objects/[0-9]+$`)},
+		{name: "SequentialIDs", gen: SequentialIDs(counterMap{}), match: regexp.MustCompile(`^https://example.com/objects/[0-9]+$`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen := make(map[pub.ID]bool)
+			for i := 0; i < 100; i++ {
+				id, err := tt.gen(pub.ObjectNew(pub.NoteType), partOf, nil)
+				if err != nil {
+					t.Fatalf("returned error: %s", err)
+				}
+				if !tt.match.MatchString(id.String()) {
+					t.Fatalf("returned invalid ID %s", id)
+				}
+				if seen[id] {
+					t.Fatalf("returned duplicate ID %s", id)
+				}
+				seen[id] = true
+			}
+			if _, err := tt.gen(pub.ObjectNew(pub.NoteType), "", nil); !errors.Is(err, ErrNotValid) {
+				t.Errorf("returned %v for an empty collection, expected %s", err, ErrNotValid)
+			}
+		})
+	}
+}
+
+func TestSnowflakeIDs_sortable(t *testing.T) {
+	gen := SnowflakeIDs(3)
+	ids := make([]string, 0)
+	for i := 0; i < 5000; i++ {
+		id, _ := gen(nil, "https://example.com/objects", nil)
+		ids = append(ids, id.String())
+	}
+	if !sort.StringsAreSorted(ids) {
+		t.Errorf("the IDs should be sorted in the order they've been generated")
+	}
+}
+
+func TestULIDs_sortable(t *testing.T) {
+	gen := ULIDs()
+	ids := make([]string, 0)
+	for i := 0; i < 5000; i++ {
+		id, _ := gen(nil, "https://example.com/objects", nil)
+		ids = append(ids, id.String())
+	}
+	if !sort.StringsAreSorted(ids) {
+		t.Errorf("the IDs should be sorted in the order they've been generated")
+	}
+}
+
+func TestULIDSequence(t *testing.T) {
+	next := ULIDSequence()
+	last := ""
+	for i := 0; i < 5000; i++ {
+		ulid, err := next()
+		if err != nil {
+			t.Fatalf("the sequence returned error: %s", err)
+		}
+		if len(ulid) != 26 || ulid <= last {
+			t.Fatalf("the sequence returned %s after %s, expected a greater ULID", ulid, last)
+		}
+		last = ulid
+	}
+}
+
+func TestULIDTime(t *testing.T) {
+	before := time.Now().Truncate(time.Millisecond)
+	id, _ := ULIDs()(nil, "https://example.com/objects", nil)
+	ulid := id.String()[len("https://example.com/objects/"):]
+	created, err := ULIDTime(ulid)
+	if err != nil {
+		t.Fatalf("ULIDTime returned error: %s", err)
+	}
+	if created.Before(before) || created.After(time.Now()) {
+		t.Errorf("ULIDTime returned %s, expected the moment the ID was generated", created)
+	}
+	if got, _ := ULIDTime("01ARZ3NDEKTSV4RRFFQ69G5FAV"); got.UnixMilli() != 1469922850259 {
+		t.Errorf("ULIDTime returned %d, expected 1469922850259", got.UnixMilli())
+	}
+	if _, err := ULIDTime("not-an-ulid"); !errors.Is(err, ErrNotValid) {
+		t.Errorf("ULIDTime returned %v for an invalid ULID, expected %s", err, ErrNotValid)
+	}
+}
+
+func TestSequentialIDs(t *testing.T) {
+	gen := SequentialIDs(counterMap{})
+	a1, _ := gen(nil, "https://example.com/a", nil)
+	a2, _ := gen(nil, "https://example.com/a", nil)
+	b1, _ := gen(nil, "https://example.com/b", nil)
+	if a1 != "https://example.com/a/1" || a2 != "https://example.com/a/2" || b1 != "https://example.com/b/1" {
+		t.Errorf("got %s, %s, %s, expected the items of each collection to be numbered separately", a1, a2, b1)
+	}
+}
+
+func TestContentHashIDs(t *testing.T) {
+	gen := ContentHashIDs()
+	partOf := pub.IRI("https://example.com/objects")
+	actor := pub.IRI("https://example.com/actors/jdoe")
+
+	first, err := gen(note("", "hello"), partOf, actor)
+	if err != nil {
+		t.Fatalf("returned error: %s", err)
+	}
+	if same, _ := gen(note("", "hello"), partOf, actor); same != first {
+		t.Errorf("returned %s for the same content, expected %s", same, first)
+	}
+	if other, _ := gen(note("", "goodbye"), partOf, actor); other == first {
+		t.Errorf("returned the same ID for different content")
+	}
+	if other, _ := gen(note("", "hello"), partOf, pub.IRI("https://example.com/actors/other")); other == first {
+		t.Errorf("returned the same ID for different actors")
+	}
+	if _, err := gen(nil, partOf, actor); !errors.Is(err, ErrNotValid) {
+		t.Errorf("returned %v for a nil item, expected %s", err, ErrNotValid)
+	}
+}
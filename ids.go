@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"fmt"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// IDGenFn generates a new ID for the "it" item, which is going to be stored as part of the
+// "partOf" collection, on behalf of the "by" actor.
+type IDGenFn func(it pub.Item, partOf pub.IRI, by pub.Item) (pub.ID, error)
+
+// IDGenerator is implemented by backends that generate the IDs of new items, using the strategy
+// from their configuration.
+type IDGenerator interface {
+	// GenerateID returns a new ID for the "it" item, which is going to be stored as part of the
+	// "partOf" collection, on behalf of the "by" actor.
+	GenerateID(it pub.Item, partOf pub.IRI, by pub.Item) (pub.ID, error)
+}
+
+// ExistsFn reports if an item having the "iri" IRI is already present in the storage, like ExistsStore.Exists.
+type ExistsFn func(iri pub.IRI) (bool, error)
+
+// IDVerifier is implemented by backends that can check the uniqueness of the stored IDs.
+type IDVerifier interface {
+	// VerifyUniqueIDs scans the storage and returns the IRIs that are stored more than once, either as
+	// duplicate keys, or as items present in more than one bucket (eg, both as an actor and as an object).
+	VerifyUniqueIDs() (pub.IRIs, error)
+}
+
+// UniqueIDs returns an IDGenFn that calls "gen" until it returns an ID for which "exists" reports false,
+// giving up after "tries" attempts.
+// It guards against silent collisions of randomly generated IDs, at the cost of one lookup per
+// generated ID.
+func UniqueIDs(gen IDGenFn, exists ExistsFn, tries int) IDGenFn {
+	if tries < 1 {
+		tries = 1
+	}
+	return func(it pub.Item, partOf pub.IRI, by pub.Item) (pub.ID, error) {
+		for i := 0; i < tries; i++ {
+			id, err := gen(it, partOf, by)
+			if err != nil {
+				return id, err
+			}
+			found, err := exists(id)
+			if err != nil {
+				return "", err
+			}
+			if !found {
+				return id, nil
+			}
+		}
+		return "", fmt.Errorf("unable to generate an unused ID in %s after %d tries", partOf, tries)
+	}
+}
+
+// SetID sets the ID of "it" to "id". It works on all the object types, including activities, actors and
+// collections, and on links, which need to be passed as pointers for the change to be visible.
+// IRIs and item collections don't have an ID of their own, and return ErrNotValid.
+func SetID(it pub.Item, id pub.ID) error {
+	if pub.IsNil(it) {
+		return fmt.Errorf("%w: unable to set the ID of a nil item", ErrNotValid)
+	}
+	if pub.IsIRI(it) || pub.IsItemCollection(it) {
+		return fmt.Errorf("%w: unable to set the ID of %T", ErrNotValid, it)
+	}
+	var err error
+	if pub.IsLink(it) {
+		err = pub.OnLink(it, func(l *pub.Link) error {
+			l.ID = id
+			return nil
+		})
+	} else {
+		err = pub.OnObject(it, func(o *pub.Object) error {
+			o.ID = id
+			return nil
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("%w: unable to set the ID of %T: %s", ErrNotValid, it, err)
+	}
+	if it.GetLink() != id {
+		return fmt.Errorf("%w: unable to set the ID of %T, it needs to be a pointer", ErrNotValid, it)
+	}
+	return nil
+}
+
+// AssignID generates an ID for "it" with "gen", and sets it using SetID.
+func AssignID(gen IDGenFn, it pub.Item, partOf pub.IRI, by pub.Item) (pub.ID, error) {
+	id, err := gen(it, partOf, by)
+	if err != nil {
+		return "", err
+	}
+	return id, SetID(it, id)
+}
@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+func TestUniqueIDs(t *testing.T) {
+	partOf := pub.IRI("https://example.com/objects")
+	seq := 0
+	gen := func(it pub.Item, partOf pub.IRI, by pub.Item) (pub.ID, error) {
+		seq++
+		return partOf.AddPath(fmt.Sprintf("%d", seq)), nil
+	}
+	used := map[pub.IRI]bool{partOf.AddPath("1"): true, partOf.AddPath("2"): true}
+	exists := func(iri pub.IRI) (bool, error) {
+		return used[iri], nil
+	}
+
+	id, err := UniqueIDs(gen, exists, 3)(pub.ObjectNew(pub.NoteType), partOf, nil)
+	if err != nil {
+		t.Fatalf("UniqueIDs returned error: %s", err)
+	}
+	if want := partOf.AddPath("3"); id != want {
+		t.Errorf("UniqueIDs returned %s, expected %s", id, want)
+	}
+
+	seq = 0
+	if _, err := UniqueIDs(gen, exists, 2)(pub.ObjectNew(pub.NoteType), partOf, nil); err == nil {
+		t.Errorf("UniqueIDs should fail when all tries collide")
+	}
+
+	failing := func(iri pub.IRI) (bool, error) {
+		return false, fmt.Errorf("storage error")
+	}
+	if _, err := UniqueIDs(gen, failing, 2)(pub.ObjectNew(pub.NoteType), partOf, nil); err == nil {
+		t.Errorf("UniqueIDs should return the errors of the exists function")
+	}
+}
+
+func TestSetID(t *testing.T) {
+	id := pub.ID("https://example.com/objects/1")
+	tests := []struct {
+		name    string
+		it      pub.Item
+		wantErr bool
+	}{
+		{name: "object", it: pub.ObjectNew(pub.NoteType)},
+		{name: "activity", it: pub.ActivityNew("", pub.CreateType, nil)},
+		{name: "intransitive activity", it: pub.IntransitiveActivityNew("", pub.ArriveType)},
+		{name: "question", it: &pub.Question{Type: pub.QuestionType}},
+		{name: "actor", it: pub.PersonNew("")},
+		{name: "tombstone", it: &pub.Tombstone{Type: pub.TombstoneType}},
+		{name: "place", it: &pub.Place{Type: pub.PlaceType}},
+		{name: "profile", it: &pub.Profile{Type: pub.ProfileType}},
+		{name: "relationship", it: &pub.Relationship{Type: pub.RelationshipType}},
+		{name: "ordered collection", it: pub.OrderedCollectionNew("")},
+		{name: "collection page", it: &pub.CollectionPage{Type: pub.CollectionPageType}},
+		{name: "link", it: &pub.Link{Type: pub.MentionType}},
+		{name: "object value", it: pub.Object{Type: pub.NoteType}, wantErr: true},
+		{name: "IRI", it: pub.IRI("https://example.com"), wantErr: true},
+		{name: "item collection", it: pub.ItemCollection{pub.ObjectNew(pub.NoteType)}, wantErr: true},
+		{name: "nil", it: nil, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := SetID(tt.it, id)
+			if tt.wantErr {
+				if !errors.Is(err, ErrNotValid) {
+					t.Errorf("SetID returned %v, expected %s", err, ErrNotValid)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SetID returned error: %s", err)
+			}
+			if tt.it.GetLink() != id {
+				t.Errorf("the ID is %s, expected %s", tt.it.GetLink(), id)
+			}
+		})
+	}
+}
+
+func TestAssignID(t *testing.T) {
+	ob := pub.ObjectNew(pub.NoteType)
+	id, err := AssignID(SequentialIDs(counterMap{}), ob, "https://example.com/objects", nil)
+	if err != nil || id != "https://example.com/objects/1" || ob.ID != id {
+		t.Errorf("AssignID returned %s, %v and set %s, expected https://example.com/objects/1", id, err, ob.ID)
+	}
+}
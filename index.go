@@ -0,0 +1,85 @@
+package storage
+
+import (
+	pub "github.com/go-ap/activitypub"
+)
+
+// The names of the secondary indexes, which map the values of a property of the stored objects
+// to the IRIs of the objects having them.
+const (
+	IndexAttributedTo = "attributedTo"
+	IndexInReplyTo    = "inReplyTo"
+	IndexType         = "type"
+	// IndexPublished uses the UTC day of publishing as the key, formatted as IndexDateLayout.
+	IndexPublished = "published"
+)
+
+// IndexDateLayout is the layout of the keys of the IndexPublished index.
+const IndexDateLayout = "2006-01-02"
+
+// IndexStore is implemented by backends that maintain secondary indexes of the stored objects,
+// which are updated together with the objects on Save and Delete.
+type IndexStore interface {
+	// LoadIndex returns the IRIs of the stored objects that have the "key" value in the "index" index.
+	LoadIndex(index, key string) (pub.IRIs, error)
+}
+
+// IndexKeys returns the keys under which "it" is found in each of the secondary indexes.
+func IndexKeys(it pub.Item) map[string][]string {
+	keys := make(map[string][]string)
+	if pub.IsNil(it) || pub.IsIRI(it) {
+		return keys
+	}
+	if typ := it.GetType(); len(typ) > 0 {
+		keys[IndexType] = []string{string(typ)}
+	}
+	if !pub.IsObject(it) {
+		return keys
+	}
+	pub.OnObject(it, func(o *pub.Object) error {
+		for _, iri := range links(o.AttributedTo) {
+			keys[IndexAttributedTo] = append(keys[IndexAttributedTo], iri.String())
+		}
+		for _, iri := range links(o.InReplyTo) {
+			keys[IndexInReplyTo] = append(keys[IndexInReplyTo], iri.String())
+		}
+		if !o.Published.IsZero() {
+			keys[IndexPublished] = []string{o.Published.UTC().Format(IndexDateLayout)}
+		}
+		return nil
+	})
+	return keys
+}
+
+// IndexQuery returns the index, and the keys in it, that select a superset of the objects matching "f",
+// choosing the most selective one the filter has a condition for, from inReplyTo, attributedTo and type.
+// The objects found in the index still need to be checked against the filter.
+// It returns false when none of the indexes can be used.
+func IndexQuery(f Filterable) (string, []string, bool) {
+	if of, ok := f.(FilterableObject); ok {
+		if iris := of.InReplyTo(); len(iris) > 0 {
+			return IndexInReplyTo, iriKeys(iris), true
+		}
+		if iris := of.AttributedTo(); len(iris) > 0 {
+			return IndexAttributedTo, iriKeys(iris), true
+		}
+	}
+	if tf, ok := f.(FilterableTypes); ok {
+		if types := tf.Types(); len(types) > 0 {
+			keys := make([]string, 0, len(types))
+			for _, typ := range types {
+				keys = append(keys, string(typ))
+			}
+			return IndexType, keys, true
+		}
+	}
+	return "", nil, false
+}
+
+func iriKeys(iris pub.IRIs) []string {
+	keys := make([]string, 0, len(iris))
+	for _, iri := range iris {
+		keys = append(keys, iri.String())
+	}
+	return keys
+}
@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+func TestIndexKeys(t *testing.T) {
+	reply := note("https://example.com/objects/2", "reply")
+	reply.AttributedTo = pub.ItemCollection{pub.IRI("https://example.com/actors/jdoe"), pub.IRI("https://example.com/actors/alice")}
+	reply.InReplyTo = pub.IRI("https://example.com/objects/1")
+	reply.Published = time.Date(2022, time.March, 4, 23, 30, 0, 0, time.FixedZone("", -2*3600))
+
+	tests := []struct {
+		name string
+		it   pub.Item
+		want map[string][]string
+	}{
+		{name: "nil", it: nil, want: map[string][]string{}},
+		{name: "IRI", it: pub.IRI("https://example.com"), want: map[string][]string{}},
+		{name: "link", it: &pub.Link{Type: pub.MentionType}, want: map[string][]string{IndexType: {"Mention"}}},
+		{
+			name: "reply",
+			it:   reply,
+			want: map[string][]string{
+				IndexType:         {"Note"},
+				IndexAttributedTo: {"https://example.com/actors/jdoe", "https://example.com/actors/alice"},
+				IndexInReplyTo:    {"https://example.com/objects/1"},
+				IndexPublished:    {"2022-03-05"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IndexKeys(tt.it); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("IndexKeys() = %v, expected %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIndexQuery(t *testing.T) {
+	tests := []struct {
+		name      string
+		f         Filterable
+		wantIndex string
+		wantKeys  []string
+		wantOk    bool
+	}{
+		{name: "IRI", f: pub.IRI("https://example.com"), wantOk: false},
+		{name: "no conditions", f: Filter{Text: []string{"hello"}}, wantOk: false},
+		{name: "type", f: Filter{Type: pub.ActivityVocabularyTypes{pub.CreateType, pub.LikeType}}, wantIndex: IndexType, wantKeys: []string{"Create", "Like"}, wantOk: true},
+		{
+			name:      "author over type",
+			f:         Filter{Type: pub.ActivityVocabularyTypes{pub.NoteType}, Author: pub.IRIs{"https://example.com/actors/jdoe"}},
+			wantIndex: IndexAttributedTo,
+			wantKeys:  []string{"https://example.com/actors/jdoe"},
+			wantOk:    true,
+		},
+		{
+			name:      "parent over author",
+			f:         Filter{Author: pub.IRIs{"https://example.com/actors/jdoe"}, Parent: pub.IRIs{"https://example.com/objects/1"}},
+			wantIndex: IndexInReplyTo,
+			wantKeys:  []string{"https://example.com/objects/1"},
+			wantOk:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			index, keys, ok := IndexQuery(tt.f)
+			if ok != tt.wantOk || index != tt.wantIndex || !reflect.DeepEqual(keys, tt.wantKeys) {
+				t.Errorf("IndexQuery() = %s, %v, %t, expected %s, %v, %t", index, keys, ok, tt.wantIndex, tt.wantKeys, tt.wantOk)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package kv
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	pub "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage"
+)
+
+var _ storage.BackupStore = &Store{}
+
+// restoreBatch is the number of items Restore stores in each transaction, as the engines limit their size.
+const restoreBatch = 1000
+
+// Backup writes the stored objects, followed by the collections with their members, to "w" in the
+// storage.Export format. They're read in a single transaction, so the snapshot is consistent.
+func (s *Store) Backup(w io.Writer) error {
+	return s.view(func(tx Tx) error {
+		bw := bufio.NewWriter(w)
+		var werr error
+		for _, b := range []storage.Bucket{storage.BucketActors, storage.BucketActivities, storage.BucketObjects} {
+			err := tx.Scan(key(b, ""), false, func(_, value []byte) bool {
+				werr = writeLine(bw, value)
+				return werr == nil
+			})
+			if err != nil {
+				return err
+			}
+			if werr != nil {
+				return werr
+			}
+		}
+		docs := make([]*storage.CollectionDocument, 0)
+		err := tx.Scan(key(storage.BucketCollections, ""), false, func(k, value []byte) bool {
+			doc := storage.CollectionDocument{}
+			if werr = json.Unmarshal(value, &doc); werr != nil {
+				werr = fmt.Errorf("unable to decode collection %s: %w", k, werr)
+				return false
+			}
+			doc.ID = pub.IRI(k[len(storage.BucketCollections)+1:])
+			docs = append(docs, &doc)
+			return true
+		})
+		if err != nil {
+			return err
+		}
+		if werr != nil {
+			return werr
+		}
+		for _, doc := range docs {
+			doc.Items = make([]pub.IRI, 0)
+			err := members(tx, doc.ID, false, func(member pub.IRI) bool {
+				doc.Items = append(doc.Items, member)
+				return true
+			})
+			if err != nil {
+				return err
+			}
+			doc.TotalItems = uint(len(doc.Items))
+			raw, err := json.Marshal(doc)
+			if err != nil {
+				return err
+			}
+			if err := writeLine(bw, raw); err != nil {
+				return err
+			}
+		}
+		return bw.Flush()
+	})
+}
+
+func writeLine(w io.Writer, raw []byte) error {
+	if _, err := w.Write(raw); err != nil {
+		return err
+	}
+	_, err := w.Write([]byte{'\n'})
+	return err
+}
+
+// Restore saves the items read from "r", which is in the storage.Export format, overwriting the stored
+// ones with the same IRIs. Nothing is restored if any of the items is invalid, but as the items are
+// stored in transactions of restoreBatch items, a failure of the engine can leave a part of them restored.
+// The subscribers of the ChangeFeed aren't notified of the restored items.
+func (s *Store) Restore(r io.Reader) error {
+	type entry struct {
+		it   pub.Item
+		b    storage.Bucket
+		data []byte
+	}
+	entries := make([]entry, 0)
+	err := storage.ReadExport(r, func(it pub.Item) error {
+		b, data, err := encode(it)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry{it: it, b: b, data: data})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for len(entries) > 0 {
+		batch := entries[:min(restoreBatch, len(entries))]
+		err := s.update(func(tx Tx) error {
+			for _, e := range batch {
+				if err := s.save(tx, e.it, e.b, e.data); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		entries = entries[len(batch):]
+	}
+	return nil
+}
@@ -0,0 +1,698 @@
+// Package kv implements the storage interfaces over ordered key-value engines, for the backends built
+// on them, like badger.
+//
+// The items are stored under the prefix of their bucket, see storage.BucketForItem, followed by their IRI,
+// eg: "objects/https://example.com/objects/1", and the collections in the storage.CollectionDocument format,
+// without their members. Each member is stored under the "members" prefix, followed by the IRI of its
+// collection and the ULID of the moment it's been added, so scanning them in reverse returns the most recent
+// ones first, without loading the whole collection. The "positions" keys hold the ULIDs of the members.
+//
+// The version of this layout is stored under the "schema" key, see storage.SchemaStore.
+package kv
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+
+	pub "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage"
+)
+
+// ErrKeyNotFound is returned by Tx.Get for the keys that aren't stored.
+var ErrKeyNotFound = errors.New("key not found")
+
+// DB is an ordered key-value engine, with transactions isolated from each other.
+type DB interface {
+	// View runs "fn" in a read-only transaction.
+	View(fn func(Tx) error) error
+	// Update runs "fn" in a read-write transaction, which is committed if "fn" returns no error.
+	// It can run "fn" again, if the transaction conflicts with a concurrent one.
+	Update(fn func(Tx) error) error
+	// Close releases the resources of the engine.
+	Close() error
+}
+
+// Tx is a transaction of a DB.
+type Tx interface {
+	// Get returns the value of "key", or ErrKeyNotFound. The value can be kept after the transaction ends.
+	Get(key []byte) ([]byte, error)
+	// Set stores "value" under "key".
+	Set(key, value []byte) error
+	// Delete removes "key". Deleting a missing key is not an error.
+	Delete(key []byte) error
+	// Scan calls "fn" for the keys starting with "prefix", in ascending order, or descending if "reverse"
+	// is set, until it returns false. The keys and values are valid only until "fn" returns.
+	Scan(prefix []byte, reverse bool, fn func(key, value []byte) bool) error
+}
+
+// feedBuffer is the number of events each subscriber of the Store can have waiting to be received.
+const feedBuffer = 100
+
+// Store implements storage.Store and storage.CollectionStore over a DB, and broadcasts the changes
+// it commits to the subscribers of its storage.ChangeFeed.
+type Store struct {
+	db    DB
+	feed  *storage.Feed
+	idGen storage.IDGenFn
+	// seq returns the ULIDs of the members added to the collections.
+	seq      func() (string, error)
+	readOnly bool
+	// mu is held for reading by the operations, so Close waits for the running ones.
+	mu     sync.RWMutex
+	closed bool
+}
+
+var (
+	_ storage.Store                  = &Store{}
+	_ storage.CollectionStore        = &Store{}
+	_ storage.OrderedCollectionStore = &Store{}
+	_ storage.IDGenerator            = &Store{}
+	_ storage.ChangeFeed             = &Store{}
+	_ storage.SchemaStore            = &Store{}
+	_ io.Closer                      = &Store{}
+)
+
+// schemaKey holds the version of the layout of the stored data.
+var schemaKey = []byte("schema")
+
+// buckets are the buckets in which the items are looked for when their IRI doesn't identify one.
+var buckets = []storage.Bucket{
+	storage.BucketActors,
+	storage.BucketActivities,
+	storage.BucketObjects,
+	storage.BucketCollections,
+}
+
+// Options holds the options of a Store.
+type Options struct {
+	// IDGen generates the IDs of new items, using storage.ULIDs if not set.
+	IDGen storage.IDGenFn
+	// ReadOnly makes all the write operations return storage.ErrReadOnly.
+	ReadOnly bool
+}
+
+// New returns a Store keeping its data in "db".
+func New(db DB, o Options) *Store {
+	idGen := o.IDGen
+	if idGen == nil {
+		idGen = storage.ULIDs()
+	}
+	return &Store{db: db, feed: storage.NewFeed(feedBuffer), idGen: idGen, seq: storage.ULIDSequence(), readOnly: o.ReadOnly}
+}
+
+// upgrades returns the steps migrating the stored data between the versions of the layout,
+// see storage.RunUpgrades.
+func (s *Store) upgrades() []storage.UpgradeFn {
+	return []storage.UpgradeFn{
+		// the members of the collections were stored in their documents, before having their own keys
+		s.moveMembers,
+	}
+}
+
+// SchemaVersion returns the version of the layout of the stored data, which is 0 for
+// the storages created before recording it.
+func (s *Store) SchemaVersion() (int, error) {
+	v := 0
+	err := s.view(func(tx Tx) error {
+		var err error
+		v, err = schemaVersion(tx)
+		return err
+	})
+	return v, err
+}
+
+func schemaVersion(tx Tx) (int, error) {
+	data, err := tx.Get(schemaKey)
+	if errors.Is(err, ErrKeyNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.Atoi(string(data))
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid version %q", storage.ErrSchemaVersion, data)
+	}
+	return v, nil
+}
+
+// Upgrade migrates the stored data to the current version of the layout.
+// It returns storage.ErrSchemaVersion if the data has been stored by a newer version of the package,
+// and storage.ErrReadOnly if the data needs to be upgraded, but the Store is read-only.
+func (s *Store) Upgrade() error {
+	v, err := s.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	if s.readOnly && v < len(s.upgrades()) {
+		return fmt.Errorf("unable to upgrade from schema version %d: %w", v, storage.ErrReadOnly)
+	}
+	return storage.RunUpgrades(v, s.upgrades(), func(v int) error {
+		return s.update(func(tx Tx) error {
+			return tx.Set(schemaKey, []byte(strconv.Itoa(v)))
+		})
+	})
+}
+
+// moveMembers moves the members stored in the documents of the collections to their own keys.
+func (s *Store) moveMembers() error {
+	return s.update(func(tx Tx) error {
+		docs := make(map[pub.IRI]*storage.CollectionDocument)
+		err := tx.Scan(key(storage.BucketCollections, ""), false, func(k, value []byte) bool {
+			doc := storage.CollectionDocument{}
+			if json.Unmarshal(value, &doc) == nil && len(doc.Items) > 0 {
+				docs[pub.IRI(k[len(storage.BucketCollections)+1:])] = &doc
+			}
+			return true
+		})
+		if err != nil {
+			return err
+		}
+		for iri, doc := range docs {
+			for _, member := range doc.Items {
+				if _, err := s.addMember(tx, iri, member); err != nil {
+					return err
+				}
+			}
+			doc.Items = make([]pub.IRI, 0)
+			doc.TotalItems = 0
+			data, err := json.Marshal(doc)
+			if err != nil {
+				return err
+			}
+			if err := tx.Set(key(storage.BucketCollections, iri), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GenerateID returns a new ID for the "it" item, which is going to be stored as part of the "partOf" collection.
+func (s *Store) GenerateID(it pub.Item, partOf pub.IRI, by pub.Item) (pub.ID, error) {
+	return s.idGen(it, partOf, by)
+}
+
+// Subscribe returns the channel receiving the events for the items matching "f", see storage.ChangeFeed.
+func (s *Store) Subscribe(f storage.Filterable) (<-chan storage.Event, storage.CancelFn) {
+	return s.feed.Subscribe(f)
+}
+
+// Close closes the DB, after the running operations end, and ends the subscriptions to its changes.
+// After it, all the operations return storage.ErrClosed.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	s.feed.Close()
+	return s.db.Close()
+}
+
+func (s *Store) view(fn func(Tx) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return storage.ErrClosed
+	}
+	return s.db.View(fn)
+}
+
+func (s *Store) update(fn func(Tx) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return storage.ErrClosed
+	}
+	if s.readOnly {
+		return storage.ErrReadOnly
+	}
+	return s.db.Update(fn)
+}
+
+func key(b storage.Bucket, iri pub.IRI) []byte {
+	return []byte(string(b) + "/" + string(iri))
+}
+
+// membersPrefix is the prefix of the keys of the members of the "col" collection. The IRI is followed
+// by a NUL byte, so the prefix doesn't match the members of other collections with IRIs starting with it.
+func membersPrefix(col pub.IRI) []byte {
+	return []byte("members/" + string(col) + "\x00")
+}
+
+func memberKey(col pub.IRI, seq string) []byte {
+	return append(membersPrefix(col), seq...)
+}
+
+func positionKey(col, member pub.IRI) []byte {
+	return []byte("positions/" + string(col) + "\x00" + string(member))
+}
+
+func notFound(iri pub.IRI) error {
+	return fmt.Errorf("unable to find %s: %w", iri, storage.ErrNotFound)
+}
+
+// lookup returns the bucket in which "iri" is stored, and its stored value.
+// The bucket returned by storage.BucketFor is looked into first, then all of them, as the IRIs don't need
+// to follow its conventions.
+func lookup(tx Tx, iri pub.IRI) (storage.Bucket, []byte, error) {
+	order := buckets
+	if b := storage.BucketFor(iri); len(b) > 0 {
+		order = append([]storage.Bucket{b}, buckets...)
+	}
+	for _, b := range order {
+		data, err := tx.Get(key(b, iri))
+		if errors.Is(err, ErrKeyNotFound) {
+			continue
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		return b, data, nil
+	}
+	return "", nil, notFound(iri)
+}
+
+// members calls "fn" with the members of the "col" collection, in the order in which they've been added,
+// or the reverse one, until it returns false.
+func members(tx Tx, col pub.IRI, reverse bool, fn func(member pub.IRI) bool) error {
+	return tx.Scan(membersPrefix(col), reverse, func(_, value []byte) bool {
+		return fn(pub.IRI(value))
+	})
+}
+
+// addMember adds "member" to the "col" collection, and reports whether it wasn't one already.
+func (s *Store) addMember(tx Tx, col, member pub.IRI) (bool, error) {
+	_, err := tx.Get(positionKey(col, member))
+	if err == nil {
+		return false, nil
+	}
+	if !errors.Is(err, ErrKeyNotFound) {
+		return false, err
+	}
+	seq, err := s.seq()
+	if err != nil {
+		return false, err
+	}
+	if err := tx.Set(memberKey(col, seq), []byte(member)); err != nil {
+		return false, err
+	}
+	return true, tx.Set(positionKey(col, member), []byte(seq))
+}
+
+// removeMember removes "member" from the "col" collection, and reports whether it was one.
+func removeMember(tx Tx, col, member pub.IRI) (bool, error) {
+	seq, err := tx.Get(positionKey(col, member))
+	if errors.Is(err, ErrKeyNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := tx.Delete(memberKey(col, string(seq))); err != nil {
+		return false, err
+	}
+	return true, tx.Delete(positionKey(col, member))
+}
+
+// clearMembers removes all the members of the "col" collection.
+func clearMembers(tx Tx, col pub.IRI) error {
+	all := make(pub.IRIs, 0)
+	if err := members(tx, col, false, func(member pub.IRI) bool {
+		all = append(all, member)
+		return true
+	}); err != nil {
+		return err
+	}
+	for _, member := range all {
+		if _, err := removeMember(tx, col, member); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Load returns the object, or the collection with its members, identified by "iri".
+func (s *Store) Load(iri pub.IRI) (pub.Item, error) {
+	var it pub.Item
+	err := s.view(func(tx Tx) error {
+		var err error
+		it, err = load(tx, iri)
+		return err
+	})
+	return it, err
+}
+
+func load(tx Tx, iri pub.IRI) (pub.Item, error) {
+	b, data, err := lookup(tx, iri)
+	if err != nil {
+		return nil, err
+	}
+	if b != storage.BucketCollections {
+		return pub.UnmarshalJSON(data)
+	}
+	doc := storage.CollectionDocument{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	err = members(tx, iri, false, func(member pub.IRI) bool {
+		doc.Items = append(doc.Items, member)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	doc.TotalItems = uint(len(doc.Items))
+	col := doc.Collection()
+	items := col.Collection()
+	for i, member := range items {
+		b, data, err := lookup(tx, member.GetLink())
+		if err != nil || b == storage.BucketCollections {
+			continue
+		}
+		if it, err := pub.UnmarshalJSON(data); err == nil {
+			items[i] = it
+		}
+	}
+	return col, nil
+}
+
+// LoadCollection returns the members of the "iri" collection matching "f", most recent first, see
+// storage.OrderedCollectionStore. When "f" requests them in the order in which they've been added, or
+// the reverse one, without pins or a range, the members are scanned in that order until the requested
+// page is complete, otherwise they're all loaded and ordered with storage.OrderMembers.
+func (s *Store) LoadCollection(iri pub.IRI, f storage.Filterable) (pub.ItemCollection, string, error) {
+	var (
+		items pub.ItemCollection
+		next  string
+	)
+	err := s.view(func(tx Tx) error {
+		if _, err := tx.Get(key(storage.BucketCollections, iri)); errors.Is(err, ErrKeyNotFound) {
+			return notFound(iri)
+		} else if err != nil {
+			return err
+		}
+		var err error
+		if scansInserted(f) {
+			items, next, err = scan(tx, iri, f)
+			return err
+		}
+		all := make(pub.ItemCollection, 0)
+		err = members(tx, iri, false, func(member pub.IRI) bool {
+			if it, ok := loadMember(tx, member, f); ok {
+				all = append(all, it)
+			}
+			return true
+		})
+		if err != nil {
+			return err
+		}
+		items, next, err = storage.OrderMembers(all, f)
+		return err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return items, next, nil
+}
+
+// scansInserted reports whether the members requested by "f" are in the order in which they've been added,
+// or the reverse one, without pins or a range, so they can be read in the order of their keys.
+func scansInserted(f storage.Filterable) bool {
+	if storage.SortOrderOf(f).By != storage.SortInserted {
+		return false
+	}
+	if pf, ok := f.(storage.FilterablePinned); ok && len(pf.Pinned()) > 0 {
+		return false
+	}
+	if rf, ok := f.(storage.FilterableRange); ok && (len(rf.SinceID()) > 0 || len(rf.MaxID()) > 0) {
+		return false
+	}
+	return true
+}
+
+// scan returns the members of the "iri" collection matching "f", reading them in the order of their
+// keys until the page requested by "f" is complete, and the cursor of the next page, like storage.Paginate.
+func scan(tx Tx, iri pub.IRI, f storage.Filterable) (pub.ItemCollection, string, error) {
+	var (
+		after pub.IRI
+		max   = -1
+		err   error
+	)
+	if p, ok := f.(storage.FilterablePage); ok {
+		if after, err = storage.ParseCursor(p.Cursor()); err != nil {
+			return nil, "", err
+		}
+		if max = p.MaxItems(); max <= 0 {
+			max = storage.DefaultMaxItems
+		}
+	}
+	items := make(pub.ItemCollection, 0)
+	found := len(after) == 0
+	err = members(tx, iri, !storage.SortOrderOf(f).Ascending, func(member pub.IRI) bool {
+		it, ok := loadMember(tx, member, f)
+		if !ok || !storage.MatchMember(f, it) {
+			return true
+		}
+		if !found {
+			found = it.GetLink() == after
+			return true
+		}
+		items = append(items, it)
+		// one more than the page, to know if there's a next one
+		return max < 0 || len(items) <= max
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if !found {
+		return nil, "", fmt.Errorf("unable to find cursor item %s", after)
+	}
+	if max < 0 || len(items) <= max {
+		return items, "", nil
+	}
+	items = items[:max]
+	return items, storage.PageCursor(items[max-1].GetLink()), nil
+}
+
+// loadMember returns the stored "member", or its IRI if it's not stored or is a collection, and
+// reports whether it's kept by the raw filters of "f", and can be decoded.
+func loadMember(tx Tx, member pub.IRI, f storage.Filterable) (pub.Item, bool) {
+	b, data, err := lookup(tx, member)
+	if err != nil || b == storage.BucketCollections {
+		return member, true
+	}
+	if rf, ok := f.(storage.FilterableRaw); ok && !storage.MatchRaw(data, rf.RawFilters()...) {
+		return nil, false
+	}
+	it, err := pub.UnmarshalJSON(data)
+	return it, err == nil
+}
+
+// Save stores "it", which needs to have an ID. Collections are stored with their members as IRIs.
+func (s *Store) Save(it pub.Item) (pub.Item, error) {
+	b, data, err := encode(it)
+	if err != nil {
+		return nil, err
+	}
+	iri := it.GetLink()
+	typ := storage.EventCreate
+	err = s.update(func(tx Tx) error {
+		typ = storage.EventCreate
+		if _, _, err := lookup(tx, iri); err == nil {
+			typ = storage.EventUpdate
+		}
+		return s.save(tx, it, b, data)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.feed.Broadcast(storage.Event{Type: typ, Item: it})
+	return it, nil
+}
+
+// save stores "it", encoded as "data" in the "b" bucket, see encode, replacing the members of the
+// collection stored with its IRI.
+func (s *Store) save(tx Tx, it pub.Item, b storage.Bucket, data []byte) error {
+	iri := it.GetLink()
+	if err := put(tx, b, iri, data); err != nil {
+		return err
+	}
+	if err := clearMembers(tx, iri); err != nil {
+		return err
+	}
+	if b != storage.BucketCollections {
+		return nil
+	}
+	for _, member := range storage.DocumentOf(it.(pub.CollectionInterface)).Items {
+		if _, err := s.addMember(tx, iri, member); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encode validates "it", and returns the bucket in which it's stored and its encoded value.
+func encode(it pub.Item) (storage.Bucket, []byte, error) {
+	if pub.IsNil(it) {
+		return "", nil, fmt.Errorf("%w: unable to save nil item", storage.ErrNotValid)
+	}
+	if len(it.GetLink()) == 0 {
+		return "", nil, fmt.Errorf("%w: unable to save %s item without an ID", storage.ErrNotValid, it.GetType())
+	}
+	b := storage.BucketForItem(it)
+	if b == storage.BucketCollections {
+		col, ok := it.(pub.CollectionInterface)
+		if !ok {
+			return "", nil, fmt.Errorf("%w collection %T", storage.ErrNotValid, it)
+		}
+		data, err := header(col)
+		return b, data, err
+	}
+	if len(b) == 0 {
+		b = storage.BucketObjects
+	}
+	data, err := pub.MarshalJSON(it)
+	return b, data, err
+}
+
+// header returns the document of "col" without its members, which are stored separately.
+func header(col pub.CollectionInterface) ([]byte, error) {
+	doc := storage.DocumentOf(col)
+	doc.Items = make([]pub.IRI, 0)
+	doc.TotalItems = 0
+	return json.Marshal(doc)
+}
+
+// put stores "data" in the "b" bucket, and removes the "iri" item from the other buckets, in which
+// it's been stored with a type of another bucket, eg: an actor replaced by its Tombstone.
+func put(tx Tx, b storage.Bucket, iri pub.IRI, data []byte) error {
+	for _, other := range buckets {
+		if other == b {
+			continue
+		}
+		if err := tx.Delete(key(other, iri)); err != nil {
+			return err
+		}
+	}
+	return tx.Set(key(b, iri), data)
+}
+
+// Delete removes "it" from the storage.
+func (s *Store) Delete(it pub.Item) error {
+	deleted := false
+	err := s.update(func(tx Tx) error {
+		if pub.IsNil(it) {
+			return nil
+		}
+		_, _, err := lookup(tx, it.GetLink())
+		deleted = err == nil
+		for _, b := range buckets {
+			if err := tx.Delete(key(b, it.GetLink())); err != nil {
+				return err
+			}
+		}
+		return clearMembers(tx, it.GetLink())
+	})
+	if err != nil {
+		return err
+	}
+	if deleted {
+		s.feed.Broadcast(storage.Event{Type: storage.EventDelete, Item: it})
+	}
+	return nil
+}
+
+// Create creates the "col" collection, if it doesn't exist already, in which case it returns the stored one.
+func (s *Store) Create(col pub.CollectionInterface) (pub.CollectionInterface, error) {
+	if pub.IsNil(col) || len(col.GetLink()) == 0 {
+		return nil, fmt.Errorf("%w: unable to create collection without an ID", storage.ErrNotValid)
+	}
+	data, err := header(col)
+	if err != nil {
+		return nil, err
+	}
+	iri := col.GetLink()
+	var created pub.CollectionInterface
+	exists := false
+	err = s.update(func(tx Tx) error {
+		_, err := tx.Get(key(storage.BucketCollections, iri))
+		if exists = err == nil; exists {
+			stored, err := load(tx, iri)
+			if err != nil {
+				return err
+			}
+			created = stored.(pub.CollectionInterface)
+			return nil
+		}
+		if !errors.Is(err, ErrKeyNotFound) {
+			return err
+		}
+		created = col
+		if err := put(tx, storage.BucketCollections, iri, data); err != nil {
+			return err
+		}
+		for _, member := range storage.DocumentOf(col).Items {
+			if _, err := s.addMember(tx, iri, member); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		s.feed.Broadcast(storage.Event{Type: storage.EventCreate, Item: created})
+	}
+	return created, nil
+}
+
+// AddTo appends "it" to the "col" collection. Adding an item that is already in the collection is a no-op.
+func (s *Store) AddTo(col pub.IRI, it pub.Item) error {
+	if pub.IsNil(it) {
+		return fmt.Errorf("%w: unable to add nil item to %s", storage.ErrNotValid, col)
+	}
+	return s.updateCollection(col, storage.Event{Type: storage.EventAdd, Item: it, Collection: col}, s.addMember)
+}
+
+// RemoveFrom removes "it" from the "col" collection.
+func (s *Store) RemoveFrom(col pub.IRI, it pub.Item) error {
+	if pub.IsNil(it) {
+		return fmt.Errorf("%w: unable to remove nil item from %s", storage.ErrNotValid, col)
+	}
+	return s.updateCollection(col, storage.Event{Type: storage.EventRemove, Item: it, Collection: col}, removeMember)
+}
+
+// updateCollection applies "fn" to the "col" collection and the item of the "e" event, which is broadcast
+// if "fn" reports that it changed the members of the collection.
+func (s *Store) updateCollection(col pub.IRI, e storage.Event, fn func(tx Tx, col, member pub.IRI) (bool, error)) error {
+	changed := false
+	err := s.update(func(tx Tx) error {
+		_, err := tx.Get(key(storage.BucketCollections, col))
+		if errors.Is(err, ErrKeyNotFound) {
+			return notFound(col)
+		}
+		if err != nil {
+			return err
+		}
+		changed, err = fn(tx, col, e.Item.GetLink())
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if changed {
+		s.feed.Broadcast(e)
+	}
+	return nil
+}
@@ -0,0 +1,338 @@
+package kv
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage"
+	"github.com/go-ap/storage/storagetest"
+)
+
+// mapDB is a DB keeping the values in a map, whose transactions are applied to a copy of it.
+type mapDB struct {
+	mu     sync.RWMutex
+	values map[string][]byte
+}
+
+type mapTx struct {
+	values   map[string][]byte
+	readOnly bool
+}
+
+func newMapDB() *mapDB {
+	return &mapDB{values: make(map[string][]byte)}
+}
+
+func (d *mapDB) View(fn func(Tx) error) error {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return fn(&mapTx{values: d.values, readOnly: true})
+}
+
+func (d *mapDB) Update(fn func(Tx) error) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	tx := &mapTx{values: make(map[string][]byte, len(d.values))}
+	for k, v := range d.values {
+		tx.values[k] = v
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	d.values = tx.values
+	return nil
+}
+
+func (d *mapDB) Close() error {
+	return nil
+}
+
+func (t *mapTx) Get(key []byte) ([]byte, error) {
+	v, ok := t.values[string(key)]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	return v, nil
+}
+
+func (t *mapTx) Set(key, value []byte) error {
+	if t.readOnly {
+		return errors.New("read-only transaction")
+	}
+	t.values[string(key)] = value
+	return nil
+}
+
+func (t *mapTx) Delete(key []byte) error {
+	if t.readOnly {
+		return errors.New("read-only transaction")
+	}
+	delete(t.values, string(key))
+	return nil
+}
+
+func (t *mapTx) Scan(prefix []byte, reverse bool, fn func(key, value []byte) bool) error {
+	keys := make([]string, 0)
+	for k := range t.values {
+		if strings.HasPrefix(k, string(prefix)) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for i := range keys {
+		k := keys[i]
+		if reverse {
+			k = keys[len(keys)-1-i]
+		}
+		if !fn([]byte(k), t.values[k]) {
+			return nil
+		}
+	}
+	return nil
+}
+
+func TestConformance(t *testing.T) {
+	storagetest.RunStoreTests(t, func() storage.Store { return New(newMapDB(), Options{}) })
+}
+
+func TestStore_buckets(t *testing.T) {
+	db := newMapDB()
+	s := New(db, Options{})
+	actor := &pub.Actor{ID: "https://example.com/~jdoe", Type: pub.PersonType}
+	if _, err := s.Save(actor); err != nil {
+		t.Fatalf("Save returned error: %s", err)
+	}
+	if _, ok := db.values["actors/"+string(actor.ID)]; !ok {
+		t.Errorf("the actor should be stored in the actors bucket, got %v", db.values)
+	}
+	// the IRI doesn't follow the conventions of storage.BucketFor, so all the buckets are looked into
+	if it, err := s.Load(actor.ID); err != nil || it.GetType() != pub.PersonType {
+		t.Errorf("Load returned %v, %v", it, err)
+	}
+
+	tomb := storage.Tombstone(actor, actor.Published)
+	if _, err := s.Save(tomb); err != nil {
+		t.Fatalf("Save returned error: %s", err)
+	}
+	if len(db.values) != 1 {
+		t.Errorf("the Tombstone should replace the actor, got %v", db.values)
+	}
+	if it, err := s.Load(actor.ID); err != nil || it.GetType() != pub.TombstoneType {
+		t.Errorf("Load returned %v, %v, expected the Tombstone", it, err)
+	}
+}
+
+func TestStore_Subscribe(t *testing.T) {
+	s := New(newMapDB(), Options{})
+	events, cancel := s.Subscribe(pub.IRI(""))
+	defer cancel()
+
+	outbox := pub.IRI("https://example.com/actors/jdoe/outbox")
+	ob := &pub.Object{ID: "https://example.com/objects/1", Type: pub.NoteType}
+	s.Save(ob)
+	s.Save(ob)
+	s.Create(pub.OrderedCollectionNew(outbox))
+	s.Create(pub.OrderedCollectionNew(outbox))
+	s.AddTo(outbox, ob)
+	s.AddTo(outbox, ob)
+	s.RemoveFrom(outbox, ob)
+	s.Delete(ob)
+	s.Delete(ob)
+	s.Save(nil)
+
+	want := []storage.EventType{
+		storage.EventCreate, storage.EventUpdate, storage.EventCreate, storage.EventAdd, storage.EventRemove, storage.EventDelete,
+	}
+	for _, typ := range want {
+		select {
+		case e := <-events:
+			if e.Type != typ {
+				t.Errorf("received a %s event, expected %s", e.Type, typ)
+			}
+		default:
+			t.Fatalf("no %s event received", typ)
+		}
+	}
+	select {
+	case e := <-events:
+		t.Errorf("received %v, expected no events for the operations that didn't change anything", e)
+	default:
+	}
+
+	s.Close()
+	if _, ok := <-events; ok {
+		t.Errorf("the channel should be closed after closing the store")
+	}
+}
+
+func TestStore_LoadCollection(t *testing.T) {
+	s := New(newMapDB(), Options{})
+	outbox := pub.IRI("https://example.com/actors/jdoe/outbox")
+	s.Create(pub.OrderedCollectionNew(outbox))
+	// a collection with an IRI starting with the one of the outbox, whose members aren't the outbox's
+	s.Create(pub.OrderedCollectionNew(outbox + "/archived"))
+	s.AddTo(outbox+"/archived", pub.IRI("https://example.com/objects/0"))
+	for i := 1; i <= 5; i++ {
+		ob := &pub.Object{ID: pub.IRI("https://example.com/objects/" + strconv.Itoa(i)), Type: pub.NoteType}
+		s.Save(ob)
+		s.AddTo(outbox, ob)
+	}
+	s.RemoveFrom(outbox, pub.IRI("https://example.com/objects/3"))
+
+	tests := []struct {
+		name string
+		f    storage.Filterable
+		want []string
+		next bool
+	}{
+		{name: "all", f: outbox, want: []string{"5", "4", "2", "1"}},
+		{name: "first page", f: storage.Page{IRI: outbox, Max: 2}, want: []string{"5", "4"}, next: true},
+		{name: "next page", f: storage.Page{IRI: outbox, Max: 2, After: storage.PageCursor("https://example.com/objects/4")}, want: []string{"2", "1"}},
+		{name: "ascending", f: storage.Page{IRI: outbox, Max: 3, Order: storage.SortOrder{Ascending: true}}, want: []string{"1", "2", "4"}, next: true},
+		{name: "pinned", f: storage.Page{IRI: outbox, Pins: pub.IRIs{"https://example.com/objects/2"}}, want: []string{"2", "5", "4", "1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, next, err := s.LoadCollection(outbox, tt.f)
+			if err != nil {
+				t.Fatalf("LoadCollection returned error: %s", err)
+			}
+			got := make([]string, 0)
+			for _, it := range items {
+				got = append(got, strings.TrimPrefix(string(it.GetLink()), "https://example.com/objects/"))
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") || (len(next) > 0) != tt.next {
+				t.Errorf("LoadCollection returned %v, %q, expected %v", got, next, tt.want)
+			}
+		})
+	}
+
+	if _, _, err := s.LoadCollection("https://example.com/actors/jdoe/inbox", outbox); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("LoadCollection of a missing collection returned %v, expected %s", err, storage.ErrNotFound)
+	}
+	if _, _, err := s.LoadCollection(outbox, storage.Page{IRI: outbox, After: storage.PageCursor("https://example.com/objects/3")}); err == nil {
+		t.Errorf("LoadCollection should fail for a cursor which is not a member")
+	}
+}
+
+func TestStore_GenerateID(t *testing.T) {
+	partOf := pub.IRI("https://example.com/objects")
+	id, err := New(newMapDB(), Options{}).GenerateID(pub.ObjectNew(pub.NoteType), partOf, nil)
+	if err != nil {
+		t.Fatalf("GenerateID returned error: %s", err)
+	}
+	if _, err := storage.ULIDTime(strings.TrimPrefix(string(id), string(partOf)+"/")); err != nil {
+		t.Errorf("GenerateID returned %s, expected an ULID under %s", id, partOf)
+	}
+}
+
+func TestStore_Upgrade(t *testing.T) {
+	db := newMapDB()
+	s := New(db, Options{})
+	current := len(s.upgrades())
+	if err := s.Upgrade(); err != nil {
+		t.Fatalf("Upgrade returned error: %s", err)
+	}
+	if v, err := s.SchemaVersion(); err != nil || v != current {
+		t.Errorf("SchemaVersion returned %d, %v, expected %d", v, err, current)
+	}
+
+	// a storage created before recording the schema version, with the members in the collection document
+	outbox := pub.IRI("https://example.com/actors/jdoe/outbox")
+	members := []pub.IRI{"https://example.com/objects/1", "https://example.com/objects/2"}
+	db.values = map[string][]byte{
+		"collections/" + outbox.String(): []byte(`{"id":"` + outbox.String() + `","type":"OrderedCollection","totalItems":2,"orderedItems":["` + members[0].String() + `","` + members[1].String() + `"]}`),
+	}
+	for _, m := range members {
+		s.Save(&pub.Object{ID: m, Type: pub.NoteType})
+	}
+	if v, _ := s.SchemaVersion(); v != 0 {
+		t.Errorf("SchemaVersion returned %d for an unversioned storage, expected 0", v)
+	}
+	if err := s.Upgrade(); err != nil {
+		t.Fatalf("Upgrade returned error: %s", err)
+	}
+	if v, _ := s.SchemaVersion(); v != current {
+		t.Errorf("SchemaVersion returned %d after Upgrade, expected %d", v, current)
+	}
+	items, _, err := s.LoadCollection(outbox, storage.Filter{})
+	if err != nil {
+		t.Fatalf("LoadCollection returned error: %s", err)
+	}
+	if len(items) != 2 || items[0].GetLink() != members[1] || items[1].GetLink() != members[0] {
+		t.Errorf("LoadCollection after Upgrade returned %v, expected the members moved out of the document", items)
+	}
+	if it, _ := s.Load(outbox); it == nil || it.(*pub.OrderedCollection).TotalItems != 2 {
+		t.Errorf("Load after Upgrade returned %#v, expected the collection with its 2 members", it)
+	}
+
+	db.values[string(schemaKey)] = []byte(strconv.Itoa(current + 1))
+	if err := s.Upgrade(); !errors.Is(err, storage.ErrSchemaVersion) {
+		t.Errorf("Upgrade returned %v for a newer schema version, expected %v", err, storage.ErrSchemaVersion)
+	}
+}
+
+func TestStore_ReadOnly(t *testing.T) {
+	db := newMapDB()
+	s := New(db, Options{ReadOnly: true})
+	if err := s.Upgrade(); !errors.Is(err, storage.ErrReadOnly) {
+		t.Errorf("Upgrade of an unversioned storage returned %v, expected %s", err, storage.ErrReadOnly)
+	}
+	New(db, Options{}).Upgrade()
+	if err := s.Upgrade(); err != nil {
+		t.Errorf("Upgrade of an up to date storage returned error: %s", err)
+	}
+	ob := &pub.Object{ID: "https://example.com/objects/1", Type: pub.NoteType}
+	if _, err := s.Save(ob); !errors.Is(err, storage.ErrReadOnly) {
+		t.Errorf("Save returned %v, expected %s", err, storage.ErrReadOnly)
+	}
+	if _, err := s.Load(ob.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Load returned %v, expected %s", err, storage.ErrNotFound)
+	}
+}
+
+func TestStore_BackupRestore(t *testing.T) {
+	s := New(newMapDB(), Options{})
+	outbox := pub.IRI("https://example.com/actors/jdoe/outbox")
+	s.Save(&pub.Actor{ID: "https://example.com/actors/jdoe", Type: pub.PersonType})
+	s.Create(pub.OrderedCollectionNew(outbox))
+	for _, id := range []pub.IRI{"https://example.com/objects/2", "https://example.com/objects/1"} {
+		s.Save(&pub.Object{ID: id, Type: pub.NoteType})
+		s.AddTo(outbox, id)
+	}
+
+	buf := bytes.Buffer{}
+	if err := s.Backup(&buf); err != nil {
+		t.Fatalf("Backup returned error: %s", err)
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != 4 {
+		t.Errorf("Backup wrote %d lines, expected the 3 items and the collection", lines)
+	}
+	restored := New(newMapDB(), Options{})
+	if err := restored.Restore(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("Restore returned error: %s", err)
+	}
+	if _, err := restored.Load("https://example.com/actors/jdoe"); err != nil {
+		t.Errorf("Load of the restored actor returned error: %s", err)
+	}
+	items, _, err := restored.LoadCollection(outbox, storage.Filter{})
+	if err != nil || len(items) != 2 || items[0].GetLink() != "https://example.com/objects/1" || items[0].GetType() != pub.NoteType {
+		t.Errorf("LoadCollection returned %v, %v, expected the restored members, in the same order", items, err)
+	}
+
+	invalid := append(buf.Bytes(), []byte("{not json\n")...)
+	empty := New(newMapDB(), Options{})
+	if err := empty.Restore(bytes.NewReader(invalid)); !errors.Is(err, storage.ErrNotValid) {
+		t.Errorf("Restore of an invalid backup returned %v, expected %s", err, storage.ErrNotValid)
+	}
+	if _, err := empty.Load(outbox); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Load after an invalid Restore returned %v, expected nothing to be restored", err)
+	}
+}
@@ -0,0 +1,161 @@
+package sqlstore
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	pub "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage"
+)
+
+const (
+	selectAllItems       = "SELECT raw FROM items ORDER BY id"
+	selectAllCollections = "SELECT iri, raw FROM collections ORDER BY iri"
+	selectMemberIRIs     = "SELECT member FROM members WHERE collection = ? ORDER BY seq"
+)
+
+var _ storage.BackupStore = &Store{}
+
+// snapshot are the options of the transactions reading all the tables at the same moment.
+var snapshot = &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+
+// Backup writes the stored objects, followed by the collections with their members, to "w" in the
+// storage.Export format. They're read in a single repeatable read transaction, so the snapshot is consistent.
+func (s *Store) Backup(w io.Writer) error {
+	return s.txWith(snapshot, func(tx *queryTx) error {
+		bw := bufio.NewWriter(w)
+		if err := writeItems(tx, bw); err != nil {
+			return err
+		}
+		docs, err := collectionDocuments(tx)
+		if err != nil {
+			return err
+		}
+		for _, doc := range docs {
+			if doc.Items, err = s.memberIRIs(tx, doc.ID); err != nil {
+				return err
+			}
+			doc.TotalItems = uint(len(doc.Items))
+			raw, err := json.Marshal(doc)
+			if err != nil {
+				return err
+			}
+			if err := writeLine(bw, raw); err != nil {
+				return err
+			}
+		}
+		return bw.Flush()
+	})
+}
+
+func writeItems(tx *queryTx, w io.Writer) error {
+	rows, err := tx.Query(selectAllItems)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var raw []byte
+		if err := rows.Scan(&raw); err != nil {
+			return err
+		}
+		if err := writeLine(w, raw); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// collectionDocuments returns the headers of the stored collections, which are read before their
+// members, as some drivers can't run a query while reading the rows of another one.
+func collectionDocuments(tx *queryTx) ([]*storage.CollectionDocument, error) {
+	rows, err := tx.Query(selectAllCollections)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	docs := make([]*storage.CollectionDocument, 0)
+	for rows.Next() {
+		var iri string
+		var raw []byte
+		if err := rows.Scan(&iri, &raw); err != nil {
+			return nil, err
+		}
+		doc := storage.CollectionDocument{}
+		if err := json.Unmarshal(raw, &doc); err != nil {
+			return nil, fmt.Errorf("unable to decode collection %s: %w", iri, err)
+		}
+		doc.ID = pub.IRI(iri)
+		docs = append(docs, &doc)
+	}
+	return docs, rows.Err()
+}
+
+func (s *Store) memberIRIs(tx *queryTx, col pub.IRI) ([]pub.IRI, error) {
+	rows, err := tx.Query(s.d.Rebind(selectMemberIRIs), col.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := make([]pub.IRI, 0)
+	for rows.Next() {
+		var member string
+		if err := rows.Scan(&member); err != nil {
+			return nil, err
+		}
+		members = append(members, pub.IRI(member))
+	}
+	return members, rows.Err()
+}
+
+func writeLine(w io.Writer, raw []byte) error {
+	if _, err := w.Write(raw); err != nil {
+		return err
+	}
+	_, err := w.Write([]byte{'\n'})
+	return err
+}
+
+// Restore saves the items read from "r", which is in the storage.Export format, overwriting the stored
+// ones with the same IRIs, in a single transaction. Nothing is restored if any of the items is invalid.
+// The subscribers of the ChangeFeed aren't notified of the restored items.
+func (s *Store) Restore(r io.Reader) error {
+	puts := make([]func(*queryTx) error, 0)
+	err := storage.ReadExport(r, func(it pub.Item) error {
+		iri := it.GetLink()
+		if len(iri) == 0 {
+			return fmt.Errorf("%w: unable to restore %s item without an ID", storage.ErrNotValid, it.GetType())
+		}
+		if col, ok := it.(pub.CollectionInterface); ok && pub.CollectionTypes.Contains(it.GetType()) {
+			raw, err := header(col)
+			if err != nil {
+				return err
+			}
+			puts = append(puts, func(tx *queryTx) error { return s.putCollection(tx, col, raw) })
+			return nil
+		}
+		raw, err := pub.MarshalJSON(it)
+		if err != nil {
+			return err
+		}
+		puts = append(puts, func(tx *queryTx) error { return s.putItem(tx, iri, string(raw)) })
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return s.update(func(tx *queryTx) error {
+		for _, put := range puts {
+			if err := put(tx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,650 @@
+// Package sqlstore implements the storage interfaces over SQL databases, for the backends built on them,
+// like postgres. The engines differ only by their Dialect.
+//
+// The objects are stored as JSON documents in the "items" table, which has columns generated from their
+// id, type, attributedTo and published properties. The collections are stored in the "collections" table,
+// in the storage.CollectionDocument format without their members, which are stored in the "members" table,
+// numbered in the order in which they've been added. The version of the schema is stored in the
+// "schema_version" table, see storage.SchemaStore.
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage"
+)
+
+// Dialect holds the statements that differ between the SQL engines, which use '?' as placeholders.
+type Dialect struct {
+	// Schema holds the statements creating the tables, and their indexes, if they don't exist.
+	Schema []string
+	// Upgrades holds the statements migrating the tables from each version of the schema to the next one,
+	// see storage.RunUpgrades, so the current version is len(Upgrades). They run after the Schema ones,
+	// which create the tables at the current version for a new database.
+	Upgrades [][]string
+	// Rebind replaces the '?' placeholders of the statements with the ones of the engine, if they differ.
+	Rebind func(query string) string
+	// UpsertItem stores the JSON document of an item, replacing the one with the same ID.
+	UpsertItem string
+	// UpsertCollection stores the document of a collection, with its IRI as the first parameter, replacing
+	// the one with the same IRI.
+	UpsertCollection string
+	// InsertCollection stores the document of a collection, like UpsertCollection, unless it exists.
+	InsertCollection string
+	// InsertMember adds a member, the second parameter, to a collection, unless it's one already.
+	InsertMember string
+	// Retry reports whether a transaction that failed with the error can be run again, for the engines
+	// which expect their clients to retry the transactions they abort, eg: on serialization failures.
+	// The transactions aren't run again if it's not set.
+	Retry func(err error) bool
+}
+
+// NumberedPlaceholders replaces the '?' placeholders of "query" with numbered ones, eg: $1, $2...
+func NumberedPlaceholders(query string) string {
+	b := strings.Builder{}
+	n := 0
+	for _, c := range query {
+		if c != '?' {
+			b.WriteRune(c)
+			continue
+		}
+		n++
+		b.WriteString("$" + strconv.Itoa(n))
+	}
+	return b.String()
+}
+
+const (
+	createSchemaVersion = "CREATE TABLE IF NOT EXISTS schema_version (version INTEGER NOT NULL)"
+	selectSchemaVersion = "SELECT version FROM schema_version"
+	deleteSchemaVersion = "DELETE FROM schema_version"
+	insertSchemaVersion = "INSERT INTO schema_version (version) VALUES (?)"
+	// checkItems fails if the items table doesn't exist, without reading it
+	checkItems = "SELECT 1 FROM items WHERE 1 = 0"
+
+	countItems       = "SELECT (SELECT COUNT(*) FROM items WHERE id = ?) + (SELECT COUNT(*) FROM collections WHERE iri = ?)"
+	selectItem       = "SELECT raw FROM items WHERE id = ?"
+	selectCollection = "SELECT raw FROM collections WHERE iri = ?"
+	selectMembers    = "SELECT m.member, i.raw FROM members m LEFT JOIN items i ON i.id = m.member WHERE m.collection = ? ORDER BY m.seq"
+	deleteItem       = "DELETE FROM items WHERE id = ?"
+	deleteCollection = "DELETE FROM collections WHERE iri = ?"
+	deleteMembers    = "DELETE FROM members WHERE collection = ?"
+	deleteMember     = "DELETE FROM members WHERE collection = ? AND member = ?"
+)
+
+// feedBuffer is the number of events each subscriber of the Store can have waiting to be received.
+const feedBuffer = 100
+
+const (
+	// retryTries is the number of times a transaction is run, when it fails with an error the dialect retries.
+	retryTries = 10
+	// retryDelay is the time waited before running a transaction again, multiplied by the number of tries.
+	retryDelay = 10 * time.Millisecond
+)
+
+// Store implements storage.Store and storage.CollectionStore over a SQL database, and broadcasts the
+// changes it commits to the subscribers of its storage.ChangeFeed.
+type Store struct {
+	db       *sql.DB
+	d        Dialect
+	readOnly bool
+	timeout  time.Duration
+	feed     *storage.Feed
+	// mu is held for reading by the operations, so Close waits for the running ones.
+	mu     sync.RWMutex
+	closed bool
+}
+
+var (
+	_ storage.Store           = &Store{}
+	_ storage.CollectionStore = &Store{}
+	_ storage.ChangeFeed      = &Store{}
+	_ storage.SchemaStore     = &Store{}
+	_ io.Closer               = &Store{}
+)
+
+// Options holds the options of a Store.
+type Options struct {
+	// CreateIfMissing creates the tables if they don't exist, otherwise New fails for a database without them.
+	CreateIfMissing bool
+	// ReadOnly makes all the write operations return storage.ErrReadOnly, and New fail with it for
+	// tables which need to be created, or upgraded.
+	ReadOnly bool
+	// MaxOpenConns is the maximum number of connections to the database, unlimited if not set.
+	MaxOpenConns int
+	// MaxIdleConns is the maximum number of idle connections kept open, 2 if not set, or none if negative.
+	MaxIdleConns int
+	// ConnMaxLifetime is how long the connections are reused before being closed, forever if not set.
+	ConnMaxLifetime time.Duration
+	// QueryTimeout is how long each operation waits for the database, before being canceled with
+	// context.DeadlineExceeded. It waits for as long as the database needs if it's not set.
+	QueryTimeout time.Duration
+}
+
+// New returns a Store keeping its data in "db", using the statements of the "d" dialect, and upgrades
+// its tables to the current version of the schema.
+func New(db *sql.DB, d Dialect, o Options) (*Store, error) {
+	if d.Rebind == nil {
+		d.Rebind = func(query string) string { return query }
+	}
+	if o.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(o.MaxOpenConns)
+	}
+	if o.MaxIdleConns != 0 {
+		db.SetMaxIdleConns(o.MaxIdleConns)
+	}
+	if o.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(o.ConnMaxLifetime)
+	}
+	if !o.CreateIfMissing || o.ReadOnly {
+		if _, err := db.Exec(checkItems); err != nil {
+			return nil, fmt.Errorf("the tables of the storage don't exist, see Bootstrap: %w", err)
+		}
+	}
+	if !o.ReadOnly {
+		for _, stmt := range append([]string{createSchemaVersion}, d.Schema...) {
+			if _, err := db.Exec(stmt); err != nil {
+				return nil, fmt.Errorf("unable to create the tables: %w", err)
+			}
+		}
+	}
+	s := &Store{db: db, d: d, readOnly: o.ReadOnly, timeout: o.QueryTimeout, feed: storage.NewFeed(feedBuffer)}
+	if err := s.Upgrade(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// SchemaVersion returns the version of the schema of the tables, which is 0 for the databases
+// created before recording it.
+func (s *Store) SchemaVersion() (int, error) {
+	v := 0
+	err := s.tx(func(tx *queryTx) error {
+		err := tx.QueryRow(selectSchemaVersion).Scan(&v)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	})
+	return v, err
+}
+
+// Upgrade migrates the tables to the current version of the schema, running the statements of
+// Dialect.Upgrades. It returns storage.ErrSchemaVersion if the tables have been created by a newer
+// version of the package, and storage.ErrReadOnly if they need to be upgraded, but the Store is read-only.
+func (s *Store) Upgrade() error {
+	v, err := s.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	if s.readOnly && v < len(s.d.Upgrades) {
+		return fmt.Errorf("unable to upgrade from schema version %d: %w", v, storage.ErrReadOnly)
+	}
+	upgrades := make([]storage.UpgradeFn, len(s.d.Upgrades))
+	for i, stmts := range s.d.Upgrades {
+		if len(stmts) == 0 {
+			continue
+		}
+		upgrades[i] = func() error {
+			return s.update(func(tx *queryTx) error {
+				for _, stmt := range stmts {
+					if _, err := tx.Exec(stmt); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+		}
+	}
+	return storage.RunUpgrades(v, upgrades, func(v int) error {
+		return s.update(func(tx *queryTx) error {
+			if _, err := tx.Exec(deleteSchemaVersion); err != nil {
+				return err
+			}
+			_, err := tx.Exec(s.d.Rebind(insertSchemaVersion), v)
+			return err
+		})
+	})
+}
+
+// DB returns the database in which the Store keeps its data.
+func (s *Store) DB() *sql.DB {
+	return s.db
+}
+
+// Stats returns the state of the connection pool of the database, see sql.DBStats.
+func (s *Store) Stats() (map[string]int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return nil, storage.ErrClosed
+	}
+	st := s.db.Stats()
+	return map[string]int64{
+		"open_connections":    int64(st.OpenConnections),
+		"in_use":              int64(st.InUse),
+		"idle":                int64(st.Idle),
+		"wait_count":          st.WaitCount,
+		"wait_duration_ms":    st.WaitDuration.Milliseconds(),
+		"max_idle_closed":     st.MaxIdleClosed,
+		"max_lifetime_closed": st.MaxLifetimeClosed,
+	}, nil
+}
+
+// Subscribe returns the channel receiving the events for the items matching "f", see storage.ChangeFeed.
+func (s *Store) Subscribe(f storage.Filterable) (<-chan storage.Event, storage.CancelFn) {
+	return s.feed.Subscribe(f)
+}
+
+// Close closes the database, after the running operations end, and ends the subscriptions to its changes.
+// After it, all the operations return storage.ErrClosed.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	s.feed.Close()
+	return s.db.Close()
+}
+
+// update runs "fn" in a transaction, like tx, if the Store isn't read-only.
+func (s *Store) update(fn func(*queryTx) error) error {
+	if s.readOnly {
+		return storage.ErrReadOnly
+	}
+	return s.tx(fn)
+}
+
+// tx runs "fn" in a transaction, which is committed if it returns no error.
+func (s *Store) tx(fn func(*queryTx) error) error {
+	return s.txWith(nil, fn)
+}
+
+// txWith runs "fn" in a transaction with the "opts" options, like tx. The transactions failing with
+// the errors the dialect retries are run again, up to retryTries times.
+func (s *Store) txWith(opts *sql.TxOptions, fn func(*queryTx) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return storage.ErrClosed
+	}
+	var err error
+	for try := 1; try <= retryTries; try++ {
+		if err = s.run(opts, fn); err == nil || s.d.Retry == nil || !s.d.Retry(err) {
+			return err
+		}
+		time.Sleep(time.Duration(try) * retryDelay)
+	}
+	return err
+}
+
+func (s *Store) run(opts *sql.TxOptions, fn func(*queryTx) error) error {
+	ctx := context.Background()
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+	tx, err := s.db.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+	if err := fn(&queryTx{Tx: tx, ctx: ctx}); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// queryTx is a transaction whose statements are canceled with the context of the operation running them.
+type queryTx struct {
+	*sql.Tx
+	ctx context.Context
+}
+
+func (t *queryTx) Exec(query string, args ...any) (sql.Result, error) {
+	return t.Tx.ExecContext(t.ctx, query, args...)
+}
+
+func (t *queryTx) Query(query string, args ...any) (*sql.Rows, error) {
+	return t.Tx.QueryContext(t.ctx, query, args...)
+}
+
+func (t *queryTx) QueryRow(query string, args ...any) *sql.Row {
+	return t.Tx.QueryRowContext(t.ctx, query, args...)
+}
+
+// eventFor returns the type of the event for saving "iri", depending on whether it's already stored.
+func (s *Store) eventFor(tx *queryTx, iri pub.IRI) (storage.EventType, error) {
+	n := 0
+	if err := tx.QueryRow(s.d.Rebind(countItems), iri.String(), iri.String()).Scan(&n); err != nil {
+		return "", err
+	}
+	if n > 0 {
+		return storage.EventUpdate, nil
+	}
+	return storage.EventCreate, nil
+}
+
+func notFound(iri pub.IRI) error {
+	return fmt.Errorf("unable to find %s: %w", iri, storage.ErrNotFound)
+}
+
+// Load returns the object, or the collection with its members, identified by "iri".
+func (s *Store) Load(iri pub.IRI) (pub.Item, error) {
+	var it pub.Item
+	err := s.tx(func(tx *queryTx) error {
+		var err error
+		if storage.BucketFor(iri) == storage.BucketCollections {
+			if it, err = s.loadCollection(tx, iri); !errors.Is(err, storage.ErrNotFound) {
+				return err
+			}
+			it, err = s.loadItem(tx, iri)
+			return err
+		}
+		if it, err = s.loadItem(tx, iri); !errors.Is(err, storage.ErrNotFound) {
+			return err
+		}
+		it, err = s.loadCollection(tx, iri)
+		return err
+	})
+	return it, err
+}
+
+func (s *Store) loadItem(tx *queryTx, iri pub.IRI) (pub.Item, error) {
+	var raw []byte
+	err := tx.QueryRow(s.d.Rebind(selectItem), iri.String()).Scan(&raw)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, notFound(iri)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return pub.UnmarshalJSON(raw)
+}
+
+func (s *Store) loadCollection(tx *queryTx, iri pub.IRI) (pub.CollectionInterface, error) {
+	var raw []byte
+	err := tx.QueryRow(s.d.Rebind(selectCollection), iri.String()).Scan(&raw)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, notFound(iri)
+	}
+	if err != nil {
+		return nil, err
+	}
+	doc := storage.CollectionDocument{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	rows, err := tx.Query(s.d.Rebind(selectMembers), iri.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := make(map[pub.IRI]pub.Item)
+	for rows.Next() {
+		var member string
+		var raw []byte
+		if err := rows.Scan(&member, &raw); err != nil {
+			return nil, err
+		}
+		doc.Items = append(doc.Items, pub.IRI(member))
+		if len(raw) == 0 {
+			continue
+		}
+		if it, err := pub.UnmarshalJSON(raw); err == nil {
+			members[pub.IRI(member)] = it
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	doc.TotalItems = uint(len(doc.Items))
+	col := doc.Collection()
+	items := col.Collection()
+	for i, member := range items {
+		if it, ok := members[member.GetLink()]; ok {
+			items[i] = it
+		}
+	}
+	return col, nil
+}
+
+// Save stores "it", which needs to have an ID. Collections are stored with their members as IRIs.
+func (s *Store) Save(it pub.Item) (pub.Item, error) {
+	if pub.IsNil(it) {
+		return nil, fmt.Errorf("%w: unable to save nil item", storage.ErrNotValid)
+	}
+	iri := it.GetLink()
+	if len(iri) == 0 {
+		return nil, fmt.Errorf("%w: unable to save %s item without an ID", storage.ErrNotValid, it.GetType())
+	}
+	if pub.CollectionTypes.Contains(it.GetType()) {
+		col, ok := it.(pub.CollectionInterface)
+		if !ok {
+			return nil, fmt.Errorf("%w collection %T", storage.ErrNotValid, it)
+		}
+		if err := s.saveCollection(col); err != nil {
+			return nil, err
+		}
+		return it, nil
+	}
+	raw, err := pub.MarshalJSON(it)
+	if err != nil {
+		return nil, err
+	}
+	var typ storage.EventType
+	err = s.update(func(tx *queryTx) error {
+		if typ, err = s.eventFor(tx, iri); err != nil {
+			return err
+		}
+		return s.putItem(tx, iri, string(raw))
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.feed.Broadcast(storage.Event{Type: typ, Item: it})
+	return it, nil
+}
+
+// putItem replaces the stored "iri" item, or collection, with the "raw" JSON document of an object.
+func (s *Store) putItem(tx *queryTx, iri pub.IRI, raw string) error {
+	if _, err := s.deleteCollection(tx, iri); err != nil {
+		return err
+	}
+	_, err := tx.Exec(s.d.Rebind(s.d.UpsertItem), raw)
+	return err
+}
+
+// header returns the document of "col" without its members, which are stored separately.
+func header(col pub.CollectionInterface) (string, error) {
+	doc := storage.DocumentOf(col)
+	doc.Items = make([]pub.IRI, 0)
+	doc.TotalItems = 0
+	raw, err := json.Marshal(doc)
+	return string(raw), err
+}
+
+// saveCollection replaces the stored "col" collection, and its members.
+func (s *Store) saveCollection(col pub.CollectionInterface) error {
+	raw, err := header(col)
+	if err != nil {
+		return err
+	}
+	iri := col.GetLink()
+	var typ storage.EventType
+	err = s.update(func(tx *queryTx) error {
+		if typ, err = s.eventFor(tx, iri); err != nil {
+			return err
+		}
+		return s.putCollection(tx, col, raw)
+	})
+	if err != nil {
+		return err
+	}
+	s.feed.Broadcast(storage.Event{Type: typ, Item: col})
+	return nil
+}
+
+// putCollection replaces the stored "col" item, or collection, with its "raw" header and its members.
+func (s *Store) putCollection(tx *queryTx, col pub.CollectionInterface, raw string) error {
+	iri := col.GetLink()
+	if _, err := tx.Exec(s.d.Rebind(deleteItem), iri.String()); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(s.d.Rebind(s.d.UpsertCollection), iri.String(), raw); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(s.d.Rebind(deleteMembers), iri.String()); err != nil {
+		return err
+	}
+	for _, member := range storage.DocumentOf(col).Items {
+		if _, err := tx.Exec(s.d.Rebind(s.d.InsertMember), iri.String(), member.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteCollection removes the "iri" collection and its members, and reports whether it was stored.
+func (s *Store) deleteCollection(tx *queryTx, iri pub.IRI) (bool, error) {
+	if _, err := tx.Exec(s.d.Rebind(deleteMembers), iri.String()); err != nil {
+		return false, err
+	}
+	return s.exec(tx, deleteCollection, iri.String())
+}
+
+// exec runs the "query" statement, and reports whether it changed any rows.
+func (s *Store) exec(tx *queryTx, query string, args ...any) (bool, error) {
+	res, err := tx.Exec(s.d.Rebind(query), args...)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Delete removes "it" from the storage.
+func (s *Store) Delete(it pub.Item) error {
+	deleted := false
+	err := s.update(func(tx *queryTx) error {
+		if pub.IsNil(it) {
+			return nil
+		}
+		item, err := s.exec(tx, deleteItem, it.GetLink().String())
+		if err != nil {
+			return err
+		}
+		col, err := s.deleteCollection(tx, it.GetLink())
+		deleted = item || col
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if deleted {
+		s.feed.Broadcast(storage.Event{Type: storage.EventDelete, Item: it})
+	}
+	return nil
+}
+
+// Create creates the "col" collection, if it doesn't exist already, in which case it returns the stored one.
+func (s *Store) Create(col pub.CollectionInterface) (pub.CollectionInterface, error) {
+	if pub.IsNil(col) || len(col.GetLink()) == 0 {
+		return nil, fmt.Errorf("%w: unable to create collection without an ID", storage.ErrNotValid)
+	}
+	raw, err := header(col)
+	if err != nil {
+		return nil, err
+	}
+	iri := col.GetLink()
+	var created pub.CollectionInterface
+	exists := false
+	err = s.update(func(tx *queryTx) error {
+		inserted, err := s.exec(tx, s.d.InsertCollection, iri.String(), raw)
+		if err != nil {
+			return err
+		}
+		if exists = !inserted; exists {
+			created, err = s.loadCollection(tx, iri)
+			return err
+		}
+		for _, member := range storage.DocumentOf(col).Items {
+			if _, err := tx.Exec(s.d.Rebind(s.d.InsertMember), iri.String(), member.String()); err != nil {
+				return err
+			}
+		}
+		created = col
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		s.feed.Broadcast(storage.Event{Type: storage.EventCreate, Item: created})
+	}
+	return created, nil
+}
+
+// AddTo appends "it" to the "col" collection. Adding an item that is already in the collection is a no-op.
+func (s *Store) AddTo(col pub.IRI, it pub.Item) error {
+	if pub.IsNil(it) {
+		return fmt.Errorf("%w: unable to add nil item to %s", storage.ErrNotValid, col)
+	}
+	return s.updateCollection(col, storage.Event{Type: storage.EventAdd, Item: it, Collection: col}, s.d.InsertMember)
+}
+
+// RemoveFrom removes "it" from the "col" collection.
+func (s *Store) RemoveFrom(col pub.IRI, it pub.Item) error {
+	if pub.IsNil(it) {
+		return fmt.Errorf("%w: unable to remove nil item from %s", storage.ErrNotValid, col)
+	}
+	return s.updateCollection(col, storage.Event{Type: storage.EventRemove, Item: it, Collection: col}, deleteMember)
+}
+
+// updateCollection runs the "query" statement, with the IRIs of the "col" collection and of the item
+// of the "e" event, which is broadcast if it changes the members of the collection.
+func (s *Store) updateCollection(col pub.IRI, e storage.Event, query string) error {
+	changed := false
+	err := s.update(func(tx *queryTx) error {
+		if err := s.exists(tx, col); err != nil {
+			return err
+		}
+		var err error
+		changed, err = s.exec(tx, query, col.String(), e.Item.GetLink().String())
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if changed {
+		s.feed.Broadcast(e)
+	}
+	return nil
+}
+
+// exists returns storage.ErrNotFound if the "col" collection isn't stored.
+func (s *Store) exists(tx *queryTx, col pub.IRI) error {
+	var raw []byte
+	err := tx.QueryRow(s.d.Rebind(selectCollection), col.String()).Scan(&raw)
+	if errors.Is(err, sql.ErrNoRows) {
+		return notFound(col)
+	}
+	return err
+}
@@ -0,0 +1,101 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-ap/storage"
+	_ "modernc.org/sqlite"
+)
+
+func TestNumberedPlaceholders(t *testing.T) {
+	tests := map[string]string{
+		"SELECT raw FROM items":                          "SELECT raw FROM items",
+		"SELECT raw FROM items WHERE id = ?":             "SELECT raw FROM items WHERE id = $1",
+		"INSERT INTO members (a, b) VALUES (?, ?)":       "INSERT INTO members (a, b) VALUES ($1, $2)",
+		"DELETE FROM members WHERE a = ? AND b = ? OR ?": "DELETE FROM members WHERE a = $1 AND b = $2 OR $3",
+	}
+	for query, want := range tests {
+		if got := NumberedPlaceholders(query); got != want {
+			t.Errorf("NumberedPlaceholders(%q) = %q, expected %q", query, got, want)
+		}
+	}
+}
+
+func TestStore_txRetry(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("unable to open the database: %s", err)
+	}
+	defer db.Close()
+
+	errAborted := errors.New("aborted")
+	s := &Store{db: db, d: Dialect{Retry: func(err error) bool { return errors.Is(err, errAborted) }}}
+	runs := 0
+	err = s.tx(func(*queryTx) error {
+		if runs++; runs < 3 {
+			return errAborted
+		}
+		return nil
+	})
+	if err != nil || runs != 3 {
+		t.Errorf("tx returned %v after %d runs, expected the aborted transactions to be run again", err, runs)
+	}
+
+	runs = 0
+	errFailed := errors.New("failed")
+	if err := s.tx(func(*queryTx) error { runs++; return errFailed }); !errors.Is(err, errFailed) || runs != 1 {
+		t.Errorf("tx returned %v after %d runs, expected the other errors not to be retried", err, runs)
+	}
+	runs = 0
+	if err := s.tx(func(*queryTx) error { runs++; return errAborted }); !errors.Is(err, errAborted) || runs != retryTries {
+		t.Errorf("tx returned %v after %d runs, expected it to give up after %d", err, runs, retryTries)
+	}
+}
+
+func TestStore_Stats(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("unable to open the database: %s", err)
+	}
+	s := &Store{db: db}
+	if err := db.Ping(); err != nil {
+		t.Fatalf("unable to connect to the database: %s", err)
+	}
+	stats, err := s.Stats()
+	if err != nil {
+		t.Fatalf("Stats returned error: %s", err)
+	}
+	if stats["open_connections"] != 1 || stats["idle"] != 1 || stats["in_use"] != 0 {
+		t.Errorf("Stats returned %v, expected one idle connection", stats)
+	}
+	s.closed = true
+	defer db.Close()
+	if _, err := s.Stats(); !errors.Is(err, storage.ErrClosed) {
+		t.Errorf("Stats after Close returned %v, expected %s", err, storage.ErrClosed)
+	}
+}
+
+func TestStore_queryTimeout(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("unable to open the database: %s", err)
+	}
+	defer db.Close()
+
+	s := &Store{db: db, timeout: 10 * time.Millisecond}
+	err = s.tx(func(tx *queryTx) error {
+		time.Sleep(20 * time.Millisecond)
+		_, err := tx.Exec("SELECT 1")
+		return err
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("tx returned %v, expected %s", err, context.DeadlineExceeded)
+	}
+	if err := s.tx(func(tx *queryTx) error { _, err := tx.Exec("SELECT 1"); return err }); err != nil {
+		t.Errorf("tx returned error: %s", err)
+	}
+}
@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"strings"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// FoldPathCase returns "iri" with its path converted to lower case, so backends which are
+// configured to use case-insensitive paths can use the result both as a storage key and as
+// a lookup key.
+//
+// Only the path is folded: the scheme and host are left untouched, as they are case-insensitive
+// by their own rules, as are the query and fragment, which are case-sensitive.
+// IRIs without an authority component (eg, "urn:...") are returned unchanged.
+func FoldPathCase(iri pub.IRI) pub.IRI {
+	s := string(iri)
+	auth := strings.Index(s, "://")
+	if auth < 0 {
+		return iri
+	}
+	start := strings.IndexAny(s[auth+3:], "/?#")
+	if start < 0 || s[auth+3+start] != '/' {
+		return iri
+	}
+	start += auth + 3
+	end := len(s)
+	if i := strings.IndexAny(s[start:], "?#"); i >= 0 {
+		end = start + i
+	}
+	return pub.IRI(s[:start] + strings.ToLower(s[start:end]) + s[end:])
+}
+
+// UnderIRI checks if "iri" is "base", or one of the IRIs nested under it. An empty base matches all IRIs.
+func UnderIRI(iri, base pub.IRI) bool {
+	if len(base) == 0 || iri == base {
+		return true
+	}
+	return strings.HasPrefix(iri.String(), strings.TrimSuffix(base.String(), "/")+"/")
+}
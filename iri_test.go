@@ -0,0 +1,32 @@
+package storage
+
+import (
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+func TestFoldPathCase(t *testing.T) {
+	tests := []struct {
+		iri  pub.IRI
+		want pub.IRI
+	}{
+		{iri: "", want: ""},
+		{iri: "https://example.com", want: "https://example.com"},
+		{iri: "https://Example.COM/", want: "https://Example.COM/"},
+		{iri: "https://example.com/Actors/JDoe", want: "https://example.com/actors/jdoe"},
+		{iri: "HTTPS://Example.com:8443/Users/Alice", want: "HTTPS://Example.com:8443/users/alice"},
+		{iri: "https://example.com/Objects/1?Page=2#Top", want: "https://example.com/objects/1?Page=2#Top"},
+		{iri: "https://example.com/Objects#Main-Key", want: "https://example.com/objects#Main-Key"},
+		{iri: "https://example.com?Page=2", want: "https://example.com?Page=2"},
+		{iri: "https://example.com?Next=/Page/2", want: "https://example.com?Next=/Page/2"},
+		{iri: "urn:uuid:ABC", want: "urn:uuid:ABC"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.iri), func(t *testing.T) {
+			if got := FoldPathCase(tt.iri); got != tt.want {
+				t.Errorf("FoldPathCase(%q) = %q, want %q", tt.iri, got, tt.want)
+			}
+		})
+	}
+}
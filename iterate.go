@@ -0,0 +1,82 @@
+package storage
+
+import (
+	pub "github.com/go-ap/activitypub"
+)
+
+// IterateStore can go through the stored items one by one, which allows processing large numbers
+// of objects without loading all of them in memory.
+type IterateStore interface {
+	// Each calls "fn" for every item matching the "f" filter.
+	//
+	// When f.GetLink() is the IRI of a collection, the items are its stored members, otherwise
+	// they are the stored objects with IRIs under it. An empty IRI matches all the objects.
+	// When "fn" returns an error the iteration stops, and Each returns it.
+	Each(f Filterable, fn func(pub.Item) error) error
+}
+
+// Each calls "fn" for every item matching "f" in the "s" store, see IterateStore.
+// For stores that are not IterateStores, it loads f.GetLink() and goes through the collection
+// members, or the single object, that it returns.
+func Each(s ReadStore, f Filterable, fn func(pub.Item) error) error {
+	if is, ok := s.(IterateStore); ok {
+		return is.Each(f, fn)
+	}
+	it, err := s.Load(f.GetLink())
+	if err != nil {
+		return err
+	}
+	items := pub.ItemCollection{it}
+	if pub.CollectionTypes.Contains(it.GetType()) {
+		if col, ok := it.(pub.CollectionInterface); ok {
+			items = col.Collection()
+		}
+	}
+	for _, it := range items {
+		if pub.IsIRI(it) || !MatchItem(f, it) {
+			continue
+		}
+		if err := fn(it); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MatchItem checks "it" against the conditions of "f" that can be verified on a loaded item:
+// the languages of FilterableLanguage filters, and the conditions of Matcher filters, or, for
+// the other filters, their prefixes and exclusions, see MatchExclusions.
+func MatchItem(f Filterable, it pub.Item) bool {
+	if pub.IsNil(it) {
+		return false
+	}
+	if lf, ok := f.(FilterableLanguage); ok && !MatchLanguage(it, lf.Language()...) {
+		return false
+	}
+	if m, ok := f.(Matcher); ok {
+		return m.Match(it)
+	}
+	return MatchExclusions(f, it)
+}
+
+// MatchDocument checks the "raw" JSON-LD document against the types of "f", if it's a FilterableTypes,
+// and against its raw filters, if it's a FilterableRaw, and then decodes it, and checks the resulting
+// item using MatchItem.
+func MatchDocument(f Filterable, raw []byte) (pub.Item, bool) {
+	it, ok, _ := matchDocument(f, raw)
+	return it, ok
+}
+
+func matchDocument(f Filterable, raw []byte) (pub.Item, bool, error) {
+	if !MatchRawType(f, raw) {
+		return nil, false, nil
+	}
+	if rf, ok := f.(FilterableRaw); ok && !MatchRaw(raw, rf.RawFilters()...) {
+		return nil, false, nil
+	}
+	it, err := pub.UnmarshalJSON(raw)
+	if err != nil {
+		return nil, false, err
+	}
+	return it, MatchItem(f, it), nil
+}
@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// langFilter is a FilterableLanguage and FilterableRaw filter used for testing.
+type langFilter struct {
+	pub.IRI
+	langs []string
+	raw   []RawFilterFn
+}
+
+func (l langFilter) Language() []string {
+	return l.langs
+}
+
+func (l langFilter) RawFilters() []RawFilterFn {
+	return l.raw
+}
+
+func TestEach(t *testing.T) {
+	s := newMapStore()
+	outbox := pub.IRI("https://example.com/actors/jdoe/outbox")
+	col := pub.OrderedCollectionNew(outbox)
+	col.OrderedItems = pub.ItemCollection{
+		note("https://example.com/objects/1", "hello"),
+		pub.IRI("https://example.com/objects/2"),
+		note("https://example.com/objects/3", "goodbye"),
+	}
+	s.Save(col)
+	s.Save(note("https://example.com/objects/1", "hello"))
+
+	tests := []struct {
+		name string
+		f    Filterable
+		want []string
+	}{
+		{name: "collection", f: outbox, want: []string{"hello", "goodbye"}},
+		{name: "filter", f: Filter{IRI: outbox, Text: []string{"bye"}}, want: []string{"goodbye"}},
+		{name: "object", f: pub.IRI("https://example.com/objects/1"), want: []string{"hello"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make([]string, 0)
+			err := Each(s, tt.f, func(it pub.Item) error {
+				got = append(got, contentOf(it))
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Each returned error: %s", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Each returned %v, expected %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("Each returned %v, expected %v", got, tt.want)
+				}
+			}
+		})
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err := Each(s, outbox, func(pub.Item) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("Each should stop at the first error, got %v after %d calls", err, calls)
+	}
+	if err := Each(s, pub.IRI("https://example.com/missing"), func(pub.Item) error { return nil }); err == nil {
+		t.Errorf("Each should return the Load error of stores that are not IterateStores")
+	}
+}
+
+func TestMatchDocument(t *testing.T) {
+	ob := note("https://example.com/objects/1", "hello")
+	raw, _ := pub.MarshalJSON(ob)
+
+	tests := []struct {
+		name string
+		f    Filterable
+		raw  []byte
+		want bool
+	}{
+		{name: "iri", f: ob.ID, raw: raw, want: true},
+		{name: "language", f: langFilter{langs: []string{UnknownLanguage}}, raw: raw, want: true},
+		{name: "other language", f: langFilter{langs: []string{"fr"}}, raw: raw, want: false},
+		{name: "raw", f: langFilter{raw: []RawFilterFn{MissingField("summary")}}, raw: raw, want: true},
+		{name: "failing raw", f: langFilter{raw: []RawFilterFn{MissingField("content")}}, raw: raw, want: false},
+		{name: "matcher", f: Filter{Text: []string{"HELLO"}}, raw: raw, want: true},
+		{name: "invalid", f: ob.ID, raw: []byte("{"), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			it, got := MatchDocument(tt.f, tt.raw)
+			if got != tt.want {
+				t.Errorf("MatchDocument returned %t, expected %t", got, tt.want)
+			}
+			if got && it.GetLink() != ob.ID {
+				t.Errorf("MatchDocument returned %v, expected the decoded item", it)
+			}
+		})
+	}
+}
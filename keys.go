@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// KeysNamespace is the MetadataStore namespace under which Keys stores the keys of the actors.
+const KeysNamespace = "keys"
+
+// Key is a PEM encoded key pair of an actor.
+type Key struct {
+	// ID is the IRI of the key, which the HTTP Signatures reference in their keyId, eg: "https://example.com/actors/jdoe#main-key".
+	ID pub.IRI `json:"id"`
+	// PublicKey is the PEM encoded public key.
+	PublicKey string `json:"publicKeyPem"`
+	// PrivateKey is the PEM encoded private key, which is missing for the keys of remote actors.
+	PrivateKey string `json:"privateKeyPem,omitempty"`
+	// Created is the moment the key has been saved.
+	Created time.Time `json:"created"`
+}
+
+// KeyStore persists the keys of the actors, together with the ones they have used previously,
+// so signatures made with a rotated key can still be verified.
+type KeyStore interface {
+	// SaveKey saves "key" as the current key of the "actor". Saving a key with the ID of an existing
+	// one replaces it, otherwise the previous keys are kept in the history.
+	SaveKey(actor pub.IRI, key Key) error
+	// LoadKey returns the current key of the "actor", or an error wrapping ErrNotFound.
+	LoadKey(actor pub.IRI) (Key, error)
+	// LoadKeys returns all the keys of the "actor", starting with the current one.
+	LoadKeys(actor pub.IRI) ([]Key, error)
+}
+
+// Keys returns a KeyStore that keeps the keys of each actor as a JSON document in the
+// KeysNamespace metadata of the "m" store.
+func Keys(m MetadataStore) KeyStore {
+	return &metadataKeys{m: m}
+}
+
+type metadataKeys struct {
+	m  MetadataStore
+	mu sync.Mutex
+}
+
+// ValidateKey checks that the keys of "k" are PEM encoded.
+func ValidateKey(k Key) error {
+	if len(k.ID) == 0 {
+		return fmt.Errorf("%w key: missing ID", ErrNotValid)
+	}
+	if b, _ := pem.Decode([]byte(k.PublicKey)); b == nil {
+		return fmt.Errorf("%w key %s: invalid public key PEM", ErrNotValid, k.ID)
+	}
+	if len(k.PrivateKey) == 0 {
+		return nil
+	}
+	if b, _ := pem.Decode([]byte(k.PrivateKey)); b == nil {
+		return fmt.Errorf("%w key %s: invalid private key PEM", ErrNotValid, k.ID)
+	}
+	return nil
+}
+
+func (k *metadataKeys) SaveKey(actor pub.IRI, key Key) error {
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+	if key.Created.IsZero() {
+		key.Created = time.Now().UTC()
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	keys, err := k.LoadKeys(actor)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	history := []Key{key}
+	for _, old := range keys {
+		if old.ID != key.ID {
+			history = append(history, old)
+		}
+	}
+	data, err := json.Marshal(history)
+	if err != nil {
+		return err
+	}
+	return k.m.SaveMetadata(actor, KeysNamespace, data)
+}
+
+func (k *metadataKeys) LoadKey(actor pub.IRI) (Key, error) {
+	keys, err := k.LoadKeys(actor)
+	if err != nil {
+		return Key{}, err
+	}
+	return keys[0], nil
+}
+
+func (k *metadataKeys) LoadKeys(actor pub.IRI) ([]Key, error) {
+	data, err := k.m.LoadMetadata(actor, KeysNamespace)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		// the keys have been cleared, eg: by Erase
+		return nil, fmt.Errorf("unable to find keys for %s: %w", actor, ErrNotFound)
+	}
+	keys := make([]Key, 0)
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("invalid keys for %s: %w", actor, err)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("unable to find keys for %s: %w", actor, ErrNotFound)
+	}
+	return keys, nil
+}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"encoding/pem"
+	"errors"
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+func testKey(id pub.IRI, private bool) Key {
+	k := Key{
+		ID:        id,
+		PublicKey: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: []byte(id)})),
+	}
+	if private {
+		k.PrivateKey = string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte(id)}))
+	}
+	return k
+}
+
+func TestValidateKey(t *testing.T) {
+	invalidPrivate := testKey("https://example.com/actors/jdoe#main-key", false)
+	invalidPrivate.PrivateKey = "secret"
+
+	tests := []struct {
+		name    string
+		key     Key
+		wantErr bool
+	}{
+		{name: "key pair", key: testKey("https://example.com/actors/jdoe#main-key", true)},
+		{name: "public key", key: testKey("https://example.com/actors/jdoe#main-key", false)},
+		{name: "missing ID", key: testKey("", true), wantErr: true},
+		{name: "invalid public key", key: Key{ID: "https://example.com/actors/jdoe#main-key", PublicKey: "key"}, wantErr: true},
+		{name: "invalid private key", key: invalidPrivate, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateKey(tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateKey returned %v, expected error %t", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrNotValid) {
+				t.Errorf("ValidateKey returned %v, expected it to match %s", err, ErrNotValid)
+			}
+		})
+	}
+}
+
+func TestKeys(t *testing.T) {
+	ks := Keys(metadataMap{})
+	actor := pub.IRI("https://example.com/actors/jdoe")
+
+	if _, err := ks.LoadKey(actor); !errors.Is(err, ErrNotFound) {
+		t.Errorf("LoadKey of an actor without keys returned %v, expected %s", err, ErrNotFound)
+	}
+	if err := ks.SaveKey(actor, Key{ID: actor + "#main-key"}); err == nil {
+		t.Errorf("SaveKey of an invalid key should fail")
+	}
+
+	first, second := testKey(actor+"#key-1", true), testKey(actor+"#key-2", true)
+	for _, k := range []Key{first, second, second} {
+		if err := ks.SaveKey(actor, k); err != nil {
+			t.Fatalf("SaveKey returned error: %s", err)
+		}
+	}
+	current, err := ks.LoadKey(actor)
+	if err != nil {
+		t.Fatalf("LoadKey returned error: %s", err)
+	}
+	if current.ID != second.ID || current.Created.IsZero() {
+		t.Errorf("LoadKey returned %v, expected the last saved key", current)
+	}
+	keys, err := ks.LoadKeys(actor)
+	if err != nil {
+		t.Fatalf("LoadKeys returned error: %s", err)
+	}
+	if len(keys) != 2 || keys[0].ID != second.ID || keys[1].ID != first.ID {
+		t.Errorf("LoadKeys returned %v, expected the rotated keys, newest first", keys)
+	}
+}
@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"strings"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// UnknownLanguage is the BCP47 tag for an undetermined language.
+// When present in a language filter it allows objects that don't have language tagged values,
+// like the ones having just a plain "content" property, instead of a "contentMap".
+const UnknownLanguage = "und"
+
+// MatchLanguage checks if "it" has any Name, Summary or Content values in one of the "langs" languages.
+//
+// The comparison is case-insensitive, and a filter language without a region matches all its regional
+// variants, eg: "en" matches "en-GB", but "en-GB" doesn't match "en".
+// Values without a language tag are matched only when "langs" contains UnknownLanguage.
+// An empty "langs" list matches everything.
+func MatchLanguage(it pub.Item, langs ...string) bool {
+	if len(langs) == 0 {
+		return true
+	}
+	if pub.IsNil(it) {
+		return false
+	}
+	match := false
+	pub.OnObject(it, func(o *pub.Object) error {
+		for _, values := range []pub.NaturalLanguageValues{o.Content, o.Name, o.Summary} {
+			for _, v := range values {
+				if languageMatches(v.Ref, langs) {
+					match = true
+					return nil
+				}
+			}
+		}
+		return nil
+	})
+	return match
+}
+
+func languageMatches(ref pub.LangRef, langs []string) bool {
+	tag := strings.ToLower(string(ref))
+	if len(tag) == 0 || ref == pub.NilLangRef {
+		tag = UnknownLanguage
+	}
+	for _, lang := range langs {
+		lang = strings.ToLower(lang)
+		if tag == lang || strings.HasPrefix(tag, lang+"-") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+func TestMatchLanguage(t *testing.T) {
+	multi := pub.ObjectNew(pub.NoteType)
+	multi.Content.Set("en-GB", pub.Content("Hello"))
+	multi.Content.Set("fr", pub.Content("Bonjour"))
+
+	named := pub.ObjectNew(pub.PageType)
+	named.Name.Set("de", pub.Content("Seite"))
+
+	plain := pub.ObjectNew(pub.NoteType)
+	plain.Content.Set(pub.NilLangRef, pub.Content("No language"))
+
+	tests := []struct {
+		name  string
+		item  pub.Item
+		langs []string
+		want  bool
+	}{
+		{name: "no filter", item: plain, want: true},
+		{name: "nil item", item: nil, langs: []string{"en"}, want: false},
+		{name: "exact match", item: multi, langs: []string{"fr"}, want: true},
+		{name: "case insensitive", item: multi, langs: []string{"EN-gb"}, want: true},
+		{name: "primary language matches region", item: multi, langs: []string{"en"}, want: true},
+		{name: "region doesn't match other region", item: multi, langs: []string{"en-US"}, want: false},
+		{name: "no match", item: multi, langs: []string{"de", "ro"}, want: false},
+		{name: "match on name", item: named, langs: []string{"ro", "de"}, want: true},
+		{name: "unknown excluded", item: plain, langs: []string{"en"}, want: false},
+		{name: "unknown allowed", item: plain, langs: []string{"en", UnknownLanguage}, want: true},
+		{name: "unknown doesn't match tagged", item: multi, langs: []string{UnknownLanguage}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MatchLanguage(tt.item, tt.langs...); got != tt.want {
+				t.Errorf("MatchLanguage() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// LogFn is the type of the printf-like functions used for logging, eg: log.Printf.
+type LogFn func(format string, v ...interface{})
+
+// Fields are the key-value pairs giving the context of a logged message.
+type Fields map[string]interface{}
+
+// Logger is the leveled logger used by the backends and the Store decorators in this package for
+// reporting the failures they can't return to the caller, eg: the stored objects that can't be decoded.
+// It can be adapted to the logging package of the application.
+type Logger interface {
+	Debug(msg string, f Fields)
+	Info(msg string, f Fields)
+	Warn(msg string, f Fields)
+	Error(msg string, f Fields)
+}
+
+// NopLogger is a Logger discarding all the messages.
+var NopLogger Logger = nopLogger{}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, Fields) {}
+func (nopLogger) Info(string, Fields)  {}
+func (nopLogger) Warn(string, Fields)  {}
+func (nopLogger) Error(string, Fields) {}
+
+// LogFnLogger returns a Logger writing the messages using "fn", prefixed by their level and followed
+// by their fields as key=value pairs, sorted by key. A nil "fn" discards the messages.
+func LogFnLogger(fn LogFn) Logger {
+	if fn == nil {
+		return NopLogger
+	}
+	return fnLogger(fn)
+}
+
+type fnLogger LogFn
+
+func (l fnLogger) log(level, msg string, f Fields) {
+	keys := make([]string, 0, len(f))
+	for k := range f {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	b := strings.Builder{}
+	b.WriteString(level)
+	b.WriteString(" ")
+	b.WriteString(msg)
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %s=%v", k, f[k])
+	}
+	l("%s", b.String())
+}
+
+func (l fnLogger) Debug(msg string, f Fields) { l.log("DEBUG", msg, f) }
+func (l fnLogger) Info(msg string, f Fields)  { l.log("INFO", msg, f) }
+func (l fnLogger) Warn(msg string, f Fields)  { l.log("WARN", msg, f) }
+func (l fnLogger) Error(msg string, f Fields) { l.log("ERROR", msg, f) }
@@ -0,0 +1,23 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestLogFnLogger(t *testing.T) {
+	logs := make([]string, 0)
+	l := LogFnLogger(func(format string, v ...interface{}) {
+		logs = append(logs, fmt.Sprintf(format, v...))
+	})
+	l.Warn("unable to decode", Fields{"iri": "https://example.com/1", "err": fmt.Errorf("invalid")})
+	l.Info("done", nil)
+
+	expected := []string{"WARN unable to decode err=invalid iri=https://example.com/1", "INFO done"}
+	if fmt.Sprint(logs) != fmt.Sprint(expected) {
+		t.Errorf("LogFnLogger wrote %q, expected %q", logs, expected)
+	}
+	if LogFnLogger(nil) != NopLogger {
+		t.Errorf("LogFnLogger(nil) should return NopLogger")
+	}
+}
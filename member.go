@@ -0,0 +1,30 @@
+package storage
+
+import (
+	"fmt"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// MembershipStore can check if an item is part of a collection without loading the collection.
+type MembershipStore interface {
+	// IsMember reports if "it" is one of the members of the "col" collection.
+	IsMember(col pub.IRI, it pub.Item) (bool, error)
+}
+
+// IsMember reports if "it" is a member of the "col" collection in the "s" store. For stores that
+// are not MembershipStores, it loads the collection and looks for the item in it.
+func IsMember(s ReadStore, col pub.IRI, it pub.Item) (bool, error) {
+	if ms, ok := s.(MembershipStore); ok {
+		return ms.IsMember(col, it)
+	}
+	c, err := s.Load(col)
+	if err != nil {
+		return false, err
+	}
+	cc, ok := c.(pub.CollectionInterface)
+	if !ok {
+		return false, fmt.Errorf("%w: %s is not a collection", ErrNotValid, col)
+	}
+	return cc.Contains(it.GetLink()), nil
+}
@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+func TestIsMember(t *testing.T) {
+	s := newMapStore()
+	outbox := pub.IRI("https://example.com/actors/jdoe/outbox")
+	col := pub.OrderedCollectionNew(outbox)
+	col.OrderedItems = pub.ItemCollection{pub.IRI("https://example.com/objects/1")}
+	s.Save(col)
+	s.Save(note("https://example.com/objects/2", "hello"))
+
+	tests := []struct {
+		name    string
+		col     pub.IRI
+		it      pub.Item
+		want    bool
+		wantErr bool
+	}{
+		{name: "member", col: outbox, it: note("https://example.com/objects/1", "hello"), want: true},
+		{name: "not a member", col: outbox, it: pub.IRI("https://example.com/objects/2")},
+		{name: "missing collection", col: "https://example.com/actors/jdoe/inbox", it: pub.IRI("https://example.com/objects/1"), wantErr: true},
+		{name: "not a collection", col: "https://example.com/objects/2", it: pub.IRI("https://example.com/objects/1"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := IsMember(s, tt.col, tt.it)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("IsMember returned error %v, expected error %t", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("IsMember returned %t, expected %t", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package memory
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"sort"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage"
+)
+
+var (
+	_ storage.BackupStore            = &repo{}
+	_ storage.IncrementalBackupStore = &repo{}
+)
+
+// Backup writes the stored objects, followed by the collections, to "w" in the storage.Export format.
+// The storage is locked for reading while it runs, so the snapshot is consistent.
+func (r *repo) Backup(w io.Writer) error {
+	return r.BackupSince(w, time.Time{})
+}
+
+// BackupSince writes the objects, followed by the collections, that have been modified at, or after,
+// the "since" moment to "w" in the storage.Export format.
+func (r *repo) BackupSince(w io.Writer, since time.Time) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.closed {
+		return storage.ErrClosed
+	}
+	bw := bufio.NewWriter(w)
+	items := make(pub.IRIs, 0, len(r.items))
+	for iri := range r.items {
+		if !r.modified[iri].Before(since) {
+			items = append(items, iri)
+		}
+	}
+	for _, iri := range sorted(items) {
+		if err := writeLine(bw, r.items[iri]); err != nil {
+			return err
+		}
+	}
+	cols := make(pub.IRIs, 0, len(r.collections))
+	for iri := range r.collections {
+		if !r.modified[iri].Before(since) {
+			cols = append(cols, iri)
+		}
+	}
+	for _, iri := range sorted(cols) {
+		raw, err := json.Marshal(r.collections[iri])
+		if err != nil {
+			return err
+		}
+		if err := writeLine(bw, raw); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// Restore saves the items read from "r", which is in the storage.Export format, overwriting
+// the stored ones with the same IRIs. Nothing is restored if any of the items is invalid.
+func (r *repo) Restore(rd io.Reader) error {
+	stores := make([]func(), 0)
+	err := storage.ReadExport(rd, func(it pub.Item) error {
+		store, err := r.prepare(it)
+		if err != nil {
+			return err
+		}
+		stores = append(stores, store)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return storage.ErrClosed
+	}
+	for _, store := range stores {
+		store()
+	}
+	return nil
+}
+
+func writeLine(w io.Writer, raw []byte) error {
+	if _, err := w.Write(raw); err != nil {
+		return err
+	}
+	_, err := w.Write([]byte{'\n'})
+	return err
+}
+
+func sorted(iris pub.IRIs) pub.IRIs {
+	sort.Slice(iris, func(i, j int) bool { return iris[i] < iris[j] })
+	return iris
+}
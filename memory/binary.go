@@ -0,0 +1,64 @@
+package memory
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	pub "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage"
+)
+
+type binary struct {
+	data        []byte
+	contentType string
+}
+
+var _ storage.BinaryStore = &repo{}
+
+// SaveBinary stores the data read from "rd" under "iri".
+func (r *repo) SaveBinary(iri pub.IRI, rd io.Reader, contentType string) error {
+	if len(iri) == 0 {
+		return fmt.Errorf("%w: unable to save binary data without an IRI", storage.ErrNotValid)
+	}
+	data, err := io.ReadAll(rd)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return storage.ErrClosed
+	}
+	r.binaries[iri] = binary{data: data, contentType: contentType}
+	return nil
+}
+
+// LoadBinary returns the data stored under "iri", and its MIME type.
+func (r *repo) LoadBinary(iri pub.IRI) (io.ReadCloser, string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.closed {
+		return nil, "", storage.ErrClosed
+	}
+	b, ok := r.binaries[iri]
+	if !ok {
+		return nil, "", fmt.Errorf("unable to find binary data for %s: %w", iri, storage.ErrNotFound)
+	}
+	return io.NopCloser(bytes.NewReader(b.data)), b.contentType, nil
+}
+
+// DeleteBinary removes the data stored under "iri".
+func (r *repo) DeleteBinary(iri pub.IRI) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return storage.ErrClosed
+	}
+	delete(r.binaries, iri)
+	return nil
+}
@@ -0,0 +1,113 @@
+package memory
+
+import (
+	"strconv"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage"
+)
+
+type queued struct {
+	storage.Delivery
+	leased time.Time
+}
+
+func (r *repo) findDelivery(id string) (int, error) {
+	for i, q := range r.queue {
+		if q.ID == id {
+			return i, nil
+		}
+	}
+	return -1, storage.ErrNotFound
+}
+
+// Enqueue adds the delivery of "activity" to "inbox" to the queue, or returns the pending one.
+func (r *repo) Enqueue(activity, inbox pub.IRI) (storage.Delivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return storage.Delivery{}, storage.ErrClosed
+	}
+	for _, q := range r.queue {
+		if q.Activity == activity && q.Inbox == inbox {
+			return q.Delivery, nil
+		}
+	}
+	r.deliveries++
+	q := &queued{Delivery: storage.Delivery{
+		ID:          strconv.FormatUint(r.deliveries, 10),
+		Activity:    activity,
+		Inbox:       inbox,
+		NextAttempt: time.Now().UTC(),
+	}}
+	r.queue = append(r.queue, q)
+	return q.Delivery, nil
+}
+
+// Dequeue leases up to "max" of the deliveries that are due.
+func (r *repo) Dequeue(max int, lease time.Duration) ([]storage.Delivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil, storage.ErrClosed
+	}
+	now := time.Now().UTC()
+	due := make([]storage.Delivery, 0)
+	for _, q := range r.queue {
+		if len(due) >= max {
+			break
+		}
+		if q.NextAttempt.After(now) || q.leased.After(now) {
+			continue
+		}
+		q.leased = now.Add(lease)
+		due = append(due, q.Delivery)
+	}
+	return due, nil
+}
+
+// MarkDelivered removes the "id" delivery from the queue.
+func (r *repo) MarkDelivered(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return storage.ErrClosed
+	}
+	i, err := r.findDelivery(id)
+	if err != nil {
+		return err
+	}
+	r.queue = append(r.queue[:i], r.queue[i+1:]...)
+	return nil
+}
+
+// MarkFailed records a failed attempt of the "id" delivery, and releases its lease until "retry".
+func (r *repo) MarkFailed(id string, reason error, retry time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return storage.ErrClosed
+	}
+	i, err := r.findDelivery(id)
+	if err != nil {
+		return err
+	}
+	if retry.IsZero() {
+		r.queue = append(r.queue[:i], r.queue[i+1:]...)
+		return nil
+	}
+	q := r.queue[i]
+	q.Attempts++
+	q.LastError = ""
+	if reason != nil {
+		q.LastError = reason.Error()
+	}
+	q.NextAttempt = retry.UTC()
+	q.leased = time.Time{}
+	return nil
+}
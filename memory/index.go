@@ -0,0 +1,195 @@
+package memory
+
+import (
+	pub "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage"
+)
+
+// termsIndex is the secondary index of the search terms of the objects, see storage.ItemTerms.
+const termsIndex = "terms"
+
+// sortIndex returns the name of the secondary index of the sort keys of the objects in the "by" order.
+func sortIndex(by storage.SortKey) string {
+	return "sort:" + string(by)
+}
+
+// indexKeys returns the keys of "it" in the storage.IndexKeys secondary indexes, in the termsIndex,
+// and in the sortIndex of each order.
+func indexKeys(it pub.Item) map[string][]string {
+	keys := storage.IndexKeys(it)
+	if terms := storage.ItemTerms(it); len(terms) > 0 {
+		keys[termsIndex] = terms
+	}
+	for _, by := range []storage.SortKey{storage.SortPublished, storage.SortUpdated} {
+		if key := storage.SortKeyOf(it, by); len(key) > 0 {
+			keys[sortIndex(by)] = []string{key}
+		}
+	}
+	return keys
+}
+
+// sortKey returns the key of the "iri" object in the sortIndex of the "by" order.
+// It needs to be called with the lock held.
+func (r *repo) sortKey(iri pub.IRI, by storage.SortKey) string {
+	if keys := r.indexed[iri][sortIndex(by)]; len(keys) > 0 {
+		return keys[0]
+	}
+	return ""
+}
+
+// indexItem adds "iri" to the secondary indexes under its "keys", replacing its previous keys.
+// It needs to be called with the lock held.
+func (r *repo) indexItem(iri pub.IRI, keys map[string][]string) {
+	r.unindexItem(iri)
+	for index, values := range keys {
+		if r.index[index] == nil {
+			r.index[index] = make(map[string]map[pub.IRI]struct{})
+		}
+		for _, key := range values {
+			if r.index[index][key] == nil {
+				r.index[index][key] = make(map[pub.IRI]struct{})
+			}
+			r.index[index][key][iri] = struct{}{}
+		}
+	}
+	r.indexed[iri] = keys
+}
+
+// indexRaw indexes the "raw" JSON-LD document stored for "iri".
+// It needs to be called with the lock held.
+func (r *repo) indexRaw(iri pub.IRI, raw []byte) {
+	if it, err := pub.UnmarshalJSON(raw); err == nil {
+		r.indexItem(iri, indexKeys(it))
+	}
+}
+
+// unindexItem removes "iri" from the secondary indexes.
+// It needs to be called with the lock held.
+func (r *repo) unindexItem(iri pub.IRI) {
+	for index, values := range r.indexed[iri] {
+		for _, key := range values {
+			delete(r.index[index][key], iri)
+			if len(r.index[index][key]) == 0 {
+				delete(r.index[index], key)
+			}
+		}
+	}
+	delete(r.indexed, iri)
+}
+
+// LoadIndex returns the IRIs of the objects having "key" in the "index" secondary index.
+func (r *repo) LoadIndex(index, key string) (pub.IRIs, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.closed {
+		return nil, storage.ErrClosed
+	}
+	iris := make(pub.IRIs, 0, len(r.index[index][key]))
+	for iri := range r.index[index][key] {
+		iris = append(iris, iri)
+	}
+	return iris, nil
+}
+
+// lookup returns the objects that the secondary indexes select for "f", under the "base" IRI,
+// and false if "f" can't use any index. It needs to be called with the lock held.
+func (r *repo) lookup(f storage.Filterable, base pub.IRI) ([]stored, bool) {
+	index, keys, ok := storage.IndexQuery(f)
+	if !ok {
+		return nil, false
+	}
+	seen := make(map[pub.IRI]bool)
+	result := make([]stored, 0)
+	for _, key := range keys {
+		for iri := range r.index[index][key] {
+			if seen[iri] || !storage.UnderIRI(iri, base) || storage.SkipIRI(f, iri) {
+				continue
+			}
+			seen[iri] = true
+			if raw, ok := r.items[iri]; ok {
+				result = append(result, stored{iri: iri, raw: raw})
+			}
+		}
+	}
+	return result, true
+}
+
+// Search returns the objects matching "f" that contain all the terms of "query", using the termsIndex.
+func (r *repo) Search(query string, f storage.Filterable) (pub.ItemCollection, error) {
+	terms := storage.SearchTerms(query)
+
+	r.mu.RLock()
+	if r.closed {
+		r.mu.RUnlock()
+		return nil, storage.ErrClosed
+	}
+	var candidates []stored
+	if _, isCollection := r.collections[f.GetLink()]; isCollection || len(terms) == 0 {
+		candidates = r.scope(f)
+	} else {
+		candidates = r.searchTerms(terms, f)
+	}
+	r.mu.RUnlock()
+
+	result := make(pub.ItemCollection, 0)
+	for _, st := range candidates {
+		it, ok := storage.MatchDocument(f, st.raw)
+		if ok && storage.MatchTerms(it, terms) {
+			result = append(result, it)
+		}
+	}
+	storage.SortByPublished(result)
+	return result, nil
+}
+
+// searchTerms returns the objects under the IRI of "f" that have all the "terms" in the termsIndex.
+// It needs to be called with the lock held.
+func (r *repo) searchTerms(terms []string, f storage.Filterable) []stored {
+	smallest := r.index[termsIndex][terms[0]]
+	for _, term := range terms[1:] {
+		if len(r.index[termsIndex][term]) < len(smallest) {
+			smallest = r.index[termsIndex][term]
+		}
+	}
+	result := make([]stored, 0)
+	for iri := range smallest {
+		if !storage.UnderIRI(iri, f.GetLink()) || storage.SkipIRI(f, iri) {
+			continue
+		}
+		all := true
+		for _, term := range terms {
+			if _, ok := r.index[termsIndex][term][iri]; !ok {
+				all = false
+				break
+			}
+		}
+		if raw, ok := r.items[iri]; ok && all {
+			result = append(result, stored{iri: iri, raw: raw})
+		}
+	}
+	return result
+}
+
+// LoadReplies returns the "iri" object with the tree of its replies, using the storage.IndexInReplyTo index.
+func (r *repo) LoadReplies(iri pub.IRI, depth int) (*storage.Thread, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.closed {
+		return nil, storage.ErrClosed
+	}
+	it, err := r.loadItem(iri)
+	if err != nil {
+		return nil, err
+	}
+	return storage.BuildThread(it, depth, func(parent pub.IRI) (pub.ItemCollection, error) {
+		replies := make(pub.ItemCollection, 0, len(r.index[storage.IndexInReplyTo][parent.String()]))
+		for iri := range r.index[storage.IndexInReplyTo][parent.String()] {
+			if it, err := r.loadItem(iri); err == nil {
+				replies = append(replies, it)
+			}
+		}
+		return replies, nil
+	})
+}
@@ -0,0 +1,931 @@
+// Package memory implements a storage backend that keeps everything in memory, suitable for
+// testing the consumers of the storage interfaces, and for ephemeral services that don't need persistence.
+package memory
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage"
+)
+
+type repo struct {
+	mu sync.RWMutex
+	// items holds the JSON-LD documents of the stored objects, so the callers can't modify
+	// them after they've been saved.
+	items       map[pub.IRI][]byte
+	collections map[pub.IRI]*storage.CollectionDocument
+	counters    map[pub.IRI]map[string]int
+	// votes holds for each question, the options each voter has voted for.
+	votes map[pub.IRI]map[pub.IRI][]string
+	// metadata holds for each item, the metadata saved under each namespace.
+	metadata map[pub.IRI]map[string][]byte
+	// versions holds the previous JSON-LD documents of the objects that have been overwritten, oldest first.
+	versions map[pub.IRI][][]byte
+	// index holds for each secondary index, the IRIs of the objects having each key.
+	index map[string]map[string]map[pub.IRI]struct{}
+	// indexed holds the keys under which each object is indexed, so they can be removed.
+	indexed map[pub.IRI]map[string][]string
+	// modified holds the moment each object, or collection, has been last modified, for incremental backups.
+	modified map[pub.IRI]time.Time
+	// remote holds the objects fetched from remote servers, see storage.CacheStore.
+	remote map[pub.IRI]cached
+	// binaries holds the binary data stored under the IRIs of their objects, see storage.BinaryStore.
+	binaries map[pub.IRI]binary
+	// queue holds the pending deliveries, in the order they've been enqueued.
+	queue      []*queued
+	deliveries uint64
+	closed     bool
+}
+
+var (
+	_ storage.Store                         = &repo{}
+	_ storage.CollectionStore               = &repo{}
+	_ storage.BatchStore                    = &repo{}
+	_ storage.IterateStore                  = &repo{}
+	_ storage.CountStore                    = &repo{}
+	_ storage.ExistsStore                   = &repo{}
+	_ storage.TxStore                       = &repo{}
+	_ storage.MetadataStore                 = &repo{}
+	_ storage.OrderedCollectionStore        = &repo{}
+	_ storage.MembershipStore               = &repo{}
+	_ storage.UpdateStore                   = &repo{}
+	_ storage.VersionedStore                = &repo{}
+	_ storage.ContextStore                  = &repo{}
+	_ storage.ContextCollectionStore        = &repo{}
+	_ storage.ContextIterateStore           = &repo{}
+	_ storage.ContextOrderedCollectionStore = &repo{}
+	_ storage.CounterStore                  = &repo{}
+	_ storage.VoteStore                     = &repo{}
+	_ storage.BulkDeleteStore               = &repo{}
+	_ storage.IDVerifier                    = &repo{}
+	_ storage.PageStore                     = &repo{}
+	_ storage.DeliveryQueueStore            = &repo{}
+	_ storage.IndexStore                    = &repo{}
+	_ storage.SearchStore                   = &repo{}
+	_ storage.ReplyStore                    = &repo{}
+	_ storage.StatsStore                    = &repo{}
+	_ storage.FanOutStore                   = &repo{}
+	_ storage.CollectionPageStore           = &repo{}
+	_ io.Closer                             = &repo{}
+)
+
+// New returns an empty in-memory storage.
+func New() *repo {
+	return &repo{
+		items:       make(map[pub.IRI][]byte),
+		collections: make(map[pub.IRI]*storage.CollectionDocument),
+		counters:    make(map[pub.IRI]map[string]int),
+		votes:       make(map[pub.IRI]map[pub.IRI][]string),
+		metadata:    make(map[pub.IRI]map[string][]byte),
+		versions:    make(map[pub.IRI][][]byte),
+		index:       make(map[string]map[string]map[pub.IRI]struct{}),
+		indexed:     make(map[pub.IRI]map[string][]string),
+		modified:    make(map[pub.IRI]time.Time),
+		remote:      make(map[pub.IRI]cached),
+		binaries:    make(map[pub.IRI]binary),
+	}
+}
+
+// Close releases the stored data. After it, all the operations return storage.ErrClosed.
+func (r *repo) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	r.items = make(map[pub.IRI][]byte)
+	r.collections = make(map[pub.IRI]*storage.CollectionDocument)
+	r.counters = make(map[pub.IRI]map[string]int)
+	r.votes = make(map[pub.IRI]map[pub.IRI][]string)
+	r.metadata = make(map[pub.IRI]map[string][]byte)
+	r.versions = make(map[pub.IRI][][]byte)
+	r.index = make(map[string]map[string]map[pub.IRI]struct{})
+	r.indexed = make(map[pub.IRI]map[string][]string)
+	r.modified = make(map[pub.IRI]time.Time)
+	r.remote = make(map[pub.IRI]cached)
+	r.binaries = make(map[pub.IRI]binary)
+	r.queue = nil
+	return nil
+}
+
+// Stats returns the number of stored objects, collections, previous versions, binaries, and the total
+// size of the JSON-LD documents of the objects.
+func (r *repo) Stats() (map[string]int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.closed {
+		return nil, storage.ErrClosed
+	}
+	stats := map[string]int64{
+		"items":       int64(len(r.items)),
+		"collections": int64(len(r.collections)),
+		"binaries":    int64(len(r.binaries)),
+	}
+	for _, raw := range r.items {
+		stats["bytes"] += int64(len(raw))
+	}
+	for _, versions := range r.versions {
+		stats["versions"] += int64(len(versions))
+	}
+	return stats, nil
+}
+
+func notFound(iri pub.IRI) error {
+	return fmt.Errorf("unable to find %s: %w", iri, storage.ErrNotFound)
+}
+
+// Exists reports if "iri" is stored, either as an object or as a collection.
+func (r *repo) Exists(iri pub.IRI) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.closed {
+		return false, storage.ErrClosed
+	}
+	if _, ok := r.items[iri]; ok {
+		return true, nil
+	}
+	_, ok := r.collections[iri]
+	return ok, nil
+}
+
+// Load returns the object, or the collection with its members, identified by "iri".
+func (r *repo) Load(iri pub.IRI) (pub.Item, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.closed {
+		return nil, storage.ErrClosed
+	}
+	return r.load(iri)
+}
+
+func (r *repo) load(iri pub.IRI) (pub.Item, error) {
+	if doc, ok := r.collections[iri]; ok {
+		col := doc.Collection()
+		items := col.Collection()
+		for i, it := range items {
+			if ob, err := r.loadItem(it.GetLink()); err == nil {
+				items[i] = ob
+			}
+		}
+		return col, nil
+	}
+	return r.loadItem(iri)
+}
+
+func (r *repo) loadItem(iri pub.IRI) (pub.Item, error) {
+	raw, ok := r.items[iri]
+	if !ok {
+		return nil, notFound(iri)
+	}
+	return pub.UnmarshalJSON(raw)
+}
+
+// Save stores "it", which needs to have an ID. Collections are stored with their members as IRIs.
+func (r *repo) Save(it pub.Item) (pub.Item, error) {
+	store, err := r.prepare(it)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil, storage.ErrClosed
+	}
+	store()
+	return it, nil
+}
+
+// prepare validates and encodes "it", and returns the function that stores it, which needs
+// to be called with the lock held.
+func (r *repo) prepare(it pub.Item) (func(), error) {
+	if pub.IsNil(it) {
+		return nil, fmt.Errorf("%w: unable to save nil item", storage.ErrNotValid)
+	}
+	iri := it.GetLink()
+	if len(iri) == 0 {
+		return nil, fmt.Errorf("%w: unable to save %s item without an ID", storage.ErrNotValid, it.GetType())
+	}
+
+	if pub.CollectionTypes.Contains(it.GetType()) {
+		col, ok := it.(pub.CollectionInterface)
+		if !ok {
+			return nil, fmt.Errorf("%w collection %T", storage.ErrNotValid, it)
+		}
+		doc := storage.DocumentOf(col)
+		return func() {
+			r.collections[iri] = doc
+			r.modify(iri)
+		}, nil
+	}
+	raw, err := pub.MarshalJSON(it)
+	if err != nil {
+		return nil, err
+	}
+	keys := indexKeys(it)
+	return func() {
+		if old, ok := r.items[iri]; ok {
+			r.versions[iri] = append(r.versions[iri], old)
+		}
+		r.items[iri] = raw
+		r.indexItem(iri, keys)
+		r.modify(iri)
+	}, nil
+}
+
+// SaveAll stores all the "items", or none of them if any is invalid.
+func (r *repo) SaveAll(items []pub.Item) ([]pub.Item, error) {
+	stores := make([]func(), 0, len(items))
+	for _, it := range items {
+		store, err := r.prepare(it)
+		if err != nil {
+			return nil, err
+		}
+		stores = append(stores, store)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil, storage.ErrClosed
+	}
+	for _, store := range stores {
+		store()
+	}
+	return items, nil
+}
+
+// Update saves "it" if the stored version has the "expected" revision, see storage.UpdateStore.
+func (r *repo) Update(it pub.Item, expected time.Time) (pub.Item, error) {
+	if pub.IsNil(it) || pub.CollectionTypes.Contains(it.GetType()) {
+		return nil, fmt.Errorf("%w: unable to update %T", storage.ErrNotValid, it)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil, storage.ErrClosed
+	}
+	stored, err := r.loadItem(it.GetLink())
+	if err != nil {
+		return nil, err
+	}
+	if err := storage.CheckRevision(stored, expected); err != nil {
+		return nil, err
+	}
+	if err := storage.SetRevision(it, stored, time.Now()); err != nil {
+		return nil, err
+	}
+	store, err := r.prepare(it)
+	if err != nil {
+		return nil, err
+	}
+	store()
+	return it, nil
+}
+
+// LoadVersions returns the previous versions of the "iri" object, most recent first.
+func (r *repo) LoadVersions(iri pub.IRI) (pub.ItemCollection, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.closed {
+		return nil, storage.ErrClosed
+	}
+	if _, ok := r.items[iri]; !ok {
+		return nil, notFound(iri)
+	}
+	versions := r.versions[iri]
+	items := make(pub.ItemCollection, 0, len(versions))
+	for i := len(versions) - 1; i >= 0; i-- {
+		it, err := pub.UnmarshalJSON(versions[i])
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	return items, nil
+}
+
+// Delete removes "it" from the storage, together with its counters, votes and binary data.
+func (r *repo) Delete(it pub.Item) error {
+	if pub.IsNil(it) {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return storage.ErrClosed
+	}
+	r.delete(it.GetLink())
+	return nil
+}
+
+// DeleteAll removes all the "items" from the storage.
+func (r *repo) DeleteAll(items []pub.Item) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return storage.ErrClosed
+	}
+	for _, it := range items {
+		if !pub.IsNil(it) {
+			r.delete(it.GetLink())
+		}
+	}
+	return nil
+}
+
+func (r *repo) delete(iri pub.IRI) {
+	delete(r.items, iri)
+	delete(r.collections, iri)
+	delete(r.counters, iri)
+	delete(r.votes, iri)
+	delete(r.metadata, iri)
+	delete(r.versions, iri)
+	delete(r.modified, iri)
+	delete(r.binaries, iri)
+	r.unindexItem(iri)
+}
+
+// modify records that "iri" has been modified now.
+func (r *repo) modify(iri pub.IRI) {
+	r.modified[iri] = time.Now()
+}
+
+// Create creates the "col" collection, if it doesn't exist already.
+func (r *repo) Create(col pub.CollectionInterface) (pub.CollectionInterface, error) {
+	if pub.IsNil(col) || len(col.GetLink()) == 0 {
+		return nil, fmt.Errorf("%w: unable to create collection without an ID", storage.ErrNotValid)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil, storage.ErrClosed
+	}
+	return r.create(col), nil
+}
+
+func (r *repo) create(col pub.CollectionInterface) pub.CollectionInterface {
+	if doc, ok := r.collections[col.GetLink()]; ok {
+		return doc.Collection()
+	}
+	r.collections[col.GetLink()] = storage.DocumentOf(col)
+	r.modify(col.GetLink())
+	return col
+}
+
+// AddTo appends "it" to the "col" collection. Adding an item that is already in the collection is a no-op.
+func (r *repo) AddTo(col pub.IRI, it pub.Item) error {
+	if pub.IsNil(it) {
+		return fmt.Errorf("%w: unable to add nil item to %s", storage.ErrNotValid, col)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return storage.ErrClosed
+	}
+	return r.addTo(col, it.GetLink())
+}
+
+func (r *repo) addTo(col pub.IRI, iri pub.IRI) error {
+	doc, ok := r.collections[col]
+	if !ok {
+		return notFound(col)
+	}
+	for _, member := range doc.Items {
+		if member == iri {
+			return nil
+		}
+	}
+	doc.Items = append(doc.Items, iri)
+	doc.TotalItems = uint(len(doc.Items))
+	r.modify(col)
+	return nil
+}
+
+// FanOut appends "it" to all the "cols" collections, or to none of them if any is missing.
+func (r *repo) FanOut(it pub.Item, cols ...pub.IRI) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return storage.ErrClosed
+	}
+	for _, col := range cols {
+		if _, ok := r.collections[col]; !ok {
+			return notFound(col)
+		}
+	}
+	for _, col := range cols {
+		if err := r.addTo(col, it.GetLink()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveFrom removes "it" from the "col" collection.
+func (r *repo) RemoveFrom(col pub.IRI, it pub.Item) error {
+	if pub.IsNil(it) {
+		return fmt.Errorf("%w: unable to remove nil item from %s", storage.ErrNotValid, col)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return storage.ErrClosed
+	}
+	return r.removeFrom(col, it.GetLink())
+}
+
+func (r *repo) removeFrom(col pub.IRI, iri pub.IRI) error {
+	doc, ok := r.collections[col]
+	if !ok {
+		return notFound(col)
+	}
+	if removeMember(doc, iri) {
+		r.modify(col)
+	}
+	return nil
+}
+
+// IsMember reports if "it" is a member of the "col" collection.
+func (r *repo) IsMember(col pub.IRI, it pub.Item) (bool, error) {
+	if pub.IsNil(it) {
+		return false, fmt.Errorf("%w: unable to check the membership of nil item in %s", storage.ErrNotValid, col)
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.closed {
+		return false, storage.ErrClosed
+	}
+	doc, ok := r.collections[col]
+	if !ok {
+		return false, notFound(col)
+	}
+	for _, member := range doc.Items {
+		if member == it.GetLink() {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func removeMember(doc *storage.CollectionDocument, iri pub.IRI) bool {
+	for i, member := range doc.Items {
+		if member == iri {
+			doc.Items = append(doc.Items[:i], doc.Items[i+1:]...)
+			doc.TotalItems = uint(len(doc.Items))
+			return true
+		}
+	}
+	return false
+}
+
+// IncrementCounter adds "delta" to the "field" counter of the "iri" object and returns the new value.
+func (r *repo) IncrementCounter(iri pub.IRI, field string, delta int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return 0, storage.ErrClosed
+	}
+	counters, ok := r.counters[iri]
+	if !ok {
+		counters = make(map[string]int)
+		r.counters[iri] = counters
+	}
+	counters[field] += delta
+	return counters[field], nil
+}
+
+// LoadCounters returns a copy of the counters of the "iri" object.
+func (r *repo) LoadCounters(iri pub.IRI) (map[string]int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.closed {
+		return nil, storage.ErrClosed
+	}
+	counters := make(map[string]int, len(r.counters[iri]))
+	for k, v := range r.counters[iri] {
+		counters[k] = v
+	}
+	return counters, nil
+}
+
+// LoadMetadata returns a copy of the "namespace" metadata of the "iri" item.
+func (r *repo) LoadMetadata(iri pub.IRI, namespace string) ([]byte, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.closed {
+		return nil, storage.ErrClosed
+	}
+	data, ok := r.metadata[iri][namespace]
+	if !ok {
+		return nil, fmt.Errorf("unable to find %s metadata for %s: %w", namespace, iri, storage.ErrNotFound)
+	}
+	return append([]byte(nil), data...), nil
+}
+
+// SaveMetadata stores a copy of "data" as the "namespace" metadata of the "iri" item.
+func (r *repo) SaveMetadata(iri pub.IRI, namespace string, data []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return storage.ErrClosed
+	}
+	m, ok := r.metadata[iri]
+	if !ok {
+		m = make(map[string][]byte)
+		r.metadata[iri] = m
+	}
+	m[namespace] = append([]byte(nil), data...)
+	return nil
+}
+
+// RecordVote saves the vote of "voter" for "option" in the "question" poll, which needs to be stored.
+func (r *repo) RecordVote(question pub.IRI, voter pub.IRI, option string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return storage.ErrClosed
+	}
+	q, err := r.loadItem(question)
+	if err != nil {
+		return err
+	}
+	if err := storage.ValidateVote(q, option); err != nil {
+		return err
+	}
+	_, multiple := storage.PollOptions(q)
+
+	voters, ok := r.votes[question]
+	if !ok {
+		voters = make(map[pub.IRI][]string)
+		r.votes[question] = voters
+	}
+	for _, voted := range voters[voter] {
+		if !multiple || voted == option {
+			return storage.ErrDuplicateVote
+		}
+	}
+	voters[voter] = append(voters[voter], option)
+	return nil
+}
+
+// PollResults returns the number of votes for each option of the "question" poll.
+func (r *repo) PollResults(question pub.IRI) (map[string]uint, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.closed {
+		return nil, storage.ErrClosed
+	}
+	results := make(map[string]uint)
+	if q, err := r.loadItem(question); err == nil {
+		options, _ := storage.PollOptions(q)
+		for _, o := range options {
+			results[o] = 0
+		}
+	}
+	for _, options := range r.votes[question] {
+		for _, o := range options {
+			results[o]++
+		}
+	}
+	return results, nil
+}
+
+// VerifyUniqueIDs returns the IRIs that are stored both as objects and as collections.
+func (r *repo) VerifyUniqueIDs() (pub.IRIs, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.closed {
+		return nil, storage.ErrClosed
+	}
+	dups := make(pub.IRIs, 0)
+	for iri := range r.collections {
+		if _, ok := r.items[iri]; ok {
+			dups = append(dups, iri)
+		}
+	}
+	return dups, nil
+}
+
+// stored holds the stored JSON-LD representation of an object.
+type stored struct {
+	iri pub.IRI
+	raw []byte
+}
+
+// scope returns the stored objects selected by the IRI of the "f" filter, see storage.IterateStore.
+// The objects outside collections are selected using the secondary indexes, if "f" has conditions for them.
+// It needs to be called with the lock held.
+func (r *repo) scope(f storage.Filterable) []stored {
+	base := f.GetLink()
+	if doc, ok := r.collections[base]; ok {
+		result := make([]stored, 0, len(doc.Items))
+		for _, iri := range doc.Items {
+			if storage.SkipIRI(f, iri) {
+				continue
+			}
+			if raw, ok := r.items[iri]; ok {
+				result = append(result, stored{iri: iri, raw: raw})
+			}
+		}
+		return result
+	}
+	if result, ok := r.lookup(f, base); ok {
+		return result
+	}
+	result := make([]stored, 0)
+	for iri, raw := range r.items {
+		if storage.UnderIRI(iri, base) && !storage.SkipIRI(f, iri) {
+			result = append(result, stored{iri: iri, raw: raw})
+		}
+	}
+	return result
+}
+
+// Each calls "fn" for every object matching "f". The objects are collected before calling "fn",
+// which can modify the storage.
+func (r *repo) Each(f storage.Filterable, fn func(pub.Item) error) error {
+	return r.EachContext(context.Background(), f, fn)
+}
+
+// EachContext calls "fn" for every object matching "f", until "ctx" is done, see storage.ContextIterateStore.
+func (r *repo) EachContext(ctx context.Context, f storage.Filterable, fn func(pub.Item) error) error {
+	r.mu.RLock()
+	closed := r.closed
+	items := r.scope(f)
+	r.mu.RUnlock()
+
+	if closed {
+		return storage.ErrClosed
+	}
+	for _, st := range items {
+		if err := ctx.Err(); err != nil {
+			return storage.Interrupted(f, err)
+		}
+		it, ok := storage.MatchDocument(f, st.raw)
+		if !ok {
+			continue
+		}
+		if err := fn(it); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Count returns the number of objects matching "f". It decodes the objects only when the
+// filter has conditions that need them.
+func (r *repo) Count(f storage.Filterable) (uint, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.closed {
+		return 0, storage.ErrClosed
+	}
+	items := r.scope(f)
+	if !hasConditions(f) {
+		return uint(len(items)), nil
+	}
+	count := uint(0)
+	for _, st := range items {
+		if _, ok := storage.MatchDocument(f, st.raw); ok {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// hasConditions checks if "f", besides selecting the objects by IRI, has conditions
+// that can be checked only on their contents.
+func hasConditions(f storage.Filterable) bool {
+	switch f.(type) {
+	case storage.Matcher, storage.FilterableLanguage, storage.FilterableRaw, storage.FilterableExclusions, storage.FilterableTypes:
+		return true
+	}
+	return false
+}
+
+// DeleteMatching replaces all the objects matching "f" with Tombstones, or removes them if
+// "f" requests it, and removes them from all collections.
+func (r *repo) DeleteMatching(f storage.Filterable) (uint, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return 0, storage.ErrClosed
+	}
+	purge := storage.Purges(f)
+	now := time.Now()
+	count := uint(0)
+	for _, st := range r.scope(f) {
+		it, ok := storage.MatchDocument(f, st.raw)
+		if !ok || it.GetType() == pub.TombstoneType {
+			continue
+		}
+		r.delete(st.iri)
+		if !purge {
+			// like storage.Bury, the Tombstone replaces all the data stored with the object
+			data, err := pub.MarshalJSON(storage.Tombstone(it, now))
+			if err != nil {
+				return count, err
+			}
+			r.items[st.iri] = data
+			r.indexRaw(st.iri, data)
+			r.modify(st.iri)
+		}
+		for col, doc := range r.collections {
+			if removeMember(doc, st.iri) {
+				r.modify(col)
+			}
+		}
+		count++
+	}
+	return count, nil
+}
+
+// LoadCollection returns the members of the "iri" collection matching "f", most recent first.
+func (r *repo) LoadCollection(iri pub.IRI, f storage.Filterable) (pub.ItemCollection, string, error) {
+	return r.LoadCollectionContext(context.Background(), iri, f)
+}
+
+// LoadCollectionContext returns the members of the "iri" collection matching "f", most recent first,
+// see storage.ContextOrderedCollectionStore.
+// When "ctx" is done, the result is limited to the members that have been added before reaching that point.
+func (r *repo) LoadCollectionContext(ctx context.Context, iri pub.IRI, f storage.Filterable) (pub.ItemCollection, string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.closed {
+		return nil, "", storage.ErrClosed
+	}
+	doc, ok := r.collections[iri]
+	if !ok {
+		return nil, "", notFound(iri)
+	}
+	rf, _ := f.(storage.FilterableRaw)
+	members := make(pub.ItemCollection, 0, len(doc.Items))
+	for _, member := range doc.Items {
+		if err := ctx.Err(); err != nil {
+			if !storage.AllowsPartial(f) {
+				return nil, "", err
+			}
+			members, next, oerr := storage.OrderMembers(members, f)
+			if oerr != nil {
+				return nil, "", oerr
+			}
+			return members, next, storage.Partial(err)
+		}
+		raw, ok := r.items[member]
+		if !ok {
+			members = append(members, member)
+			continue
+		}
+		if rf != nil && !storage.MatchRaw(raw, rf.RawFilters()...) {
+			continue
+		}
+		if it, err := pub.UnmarshalJSON(raw); err == nil {
+			members = append(members, it)
+		}
+	}
+	return storage.OrderMembers(members, f)
+}
+
+// LoadPage returns the page of the collection identified by "f" and the cursor for the next one.
+func (r *repo) LoadPage(f storage.FilterablePage) (pub.ItemCollection, string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.closed {
+		return nil, "", storage.ErrClosed
+	}
+	doc, ok := r.collections[f.GetLink()]
+	if !ok {
+		return nil, "", notFound(f.GetLink())
+	}
+	items := make(pub.ItemCollection, 0, len(doc.Items))
+	for _, iri := range doc.Items {
+		items = append(items, iri)
+	}
+	page, next, err := storage.Paginate(items, f)
+	if err != nil {
+		return nil, "", err
+	}
+	result := make(pub.ItemCollection, 0, len(page))
+	for _, it := range page {
+		if ob, err := r.loadItem(it.GetLink()); err == nil {
+			it = ob
+		}
+		result = append(result, it)
+	}
+	return result, next, nil
+}
+
+// LoadCollectionPage returns the page of the collection requested by "p", most recent first, or in
+// the order requested by "p", using the sort keys maintained together with the secondary indexes.
+// The totalItems are kept up to date by AddTo and RemoveFrom.
+func (r *repo) LoadCollectionPage(p storage.FilterablePage) (*pub.OrderedCollectionPage, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.closed {
+		return nil, storage.ErrClosed
+	}
+	doc, ok := r.collections[p.GetLink()]
+	if !ok {
+		return nil, notFound(p.GetLink())
+	}
+	members := make(pub.IRIs, 0, len(doc.Items))
+	for i := len(doc.Items) - 1; i >= 0; i-- {
+		members = append(members, doc.Items[i])
+	}
+	o := storage.SortOrderOf(p)
+	storage.SortIRIs(members, o, func(iri pub.IRI) string {
+		return r.sortKey(iri, o.By)
+	})
+	page, err := storage.CollectionPage(p.GetLink(), members, doc.TotalItems, p)
+	if err != nil {
+		return nil, err
+	}
+	for i, it := range page.OrderedItems {
+		if ob, err := r.loadItem(it.GetLink()); err == nil {
+			page.OrderedItems[i] = ob
+		}
+	}
+	return page, nil
+}
+
+// The operations of the in-memory storage don't block, so the context aware methods only need
+// to check that the context is still valid.
+
+func (r *repo) LoadContext(ctx context.Context, iri pub.IRI) (pub.Item, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.Load(iri)
+}
+
+func (r *repo) SaveContext(ctx context.Context, it pub.Item) (pub.Item, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.Save(it)
+}
+
+func (r *repo) DeleteContext(ctx context.Context, it pub.Item) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return r.Delete(it)
+}
+
+func (r *repo) CreateContext(ctx context.Context, col pub.CollectionInterface) (pub.CollectionInterface, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.Create(col)
+}
+
+func (r *repo) AddToContext(ctx context.Context, col pub.IRI, it pub.Item) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return r.AddTo(col, it)
+}
+
+func (r *repo) RemoveFromContext(ctx context.Context, col pub.IRI, it pub.Item) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return r.RemoveFrom(col, it)
+}
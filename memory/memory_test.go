@@ -0,0 +1,907 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage"
+	"github.com/go-ap/storage/storagetest"
+)
+
+func note(iri pub.IRI, content string) *pub.Object {
+	ob := pub.ObjectNew(pub.NoteType)
+	ob.ID = iri
+	ob.Content.Set(pub.NilLangRef, pub.Content(content))
+	return ob
+}
+
+func TestRepo_SaveLoad(t *testing.T) {
+	r := New()
+
+	ob := note("https://example.com/objects/1", "Hello")
+	if _, err := r.Save(ob); err != nil {
+		t.Fatalf("Save returned error: %s", err)
+	}
+	ob.Content.Set(pub.NilLangRef, pub.Content("Changed"))
+
+	it, err := r.Load(ob.ID)
+	if err != nil {
+		t.Fatalf("Load returned error: %s", err)
+	}
+	if !note(ob.ID, "Hello").Equals(it) {
+		t.Errorf("Load returned %#v, changes after Save should not be stored", it)
+	}
+
+	if _, err := r.Load("https://example.com/objects/2"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Load of missing object returned %v, expected %s", err, storage.ErrNotFound)
+	}
+	if _, err := r.Save(pub.ObjectNew(pub.NoteType)); !errors.Is(err, storage.ErrNotValid) {
+		t.Errorf("Save of an object without ID returned %v, expected %s", err, storage.ErrNotValid)
+	}
+
+	if err := r.Delete(ob); err != nil {
+		t.Fatalf("Delete returned error: %s", err)
+	}
+	if _, err := r.Load(ob.ID); err == nil {
+		t.Errorf("Load after Delete should fail")
+	}
+}
+
+func TestRepo_Collections(t *testing.T) {
+	r := New()
+	outbox := pub.IRI("https://example.com/actors/jdoe/outbox")
+
+	if err := r.AddTo(outbox, pub.IRI("https://example.com/activities/1")); err == nil {
+		t.Errorf("AddTo a missing collection should fail")
+	}
+	if _, err := r.Create(pub.OrderedCollectionNew(outbox)); err != nil {
+		t.Fatalf("Create returned error: %s", err)
+	}
+
+	act := pub.CreateNew("https://example.com/activities/1", pub.IRI("https://example.com/objects/1"))
+	r.Save(act)
+	for _, it := range []pub.Item{act, pub.IRI("https://example.com/activities/2"), act} {
+		if err := r.AddTo(outbox, it); err != nil {
+			t.Fatalf("AddTo returned error: %s", err)
+		}
+	}
+
+	it, err := r.Load(outbox)
+	if err != nil {
+		t.Fatalf("Load returned error: %s", err)
+	}
+	col, ok := it.(*pub.OrderedCollection)
+	if !ok {
+		t.Fatalf("Load returned %T, expected %T", it, col)
+	}
+	if col.TotalItems != 2 || len(col.OrderedItems) != 2 {
+		t.Fatalf("expected 2 items in the collection, got %d", col.TotalItems)
+	}
+	if col.OrderedItems[0].GetType() != pub.CreateType {
+		t.Errorf("expected stored members to be loaded, got %T", col.OrderedItems[0])
+	}
+	if !pub.IsIRI(col.OrderedItems[1]) {
+		t.Errorf("expected members that are not stored to be returned as IRIs, got %T", col.OrderedItems[1])
+	}
+
+	if ok, err := r.IsMember(outbox, act); err != nil || !ok {
+		t.Errorf("IsMember returned %t, %v, expected the activity to be a member", ok, err)
+	}
+	if ok, _ := r.IsMember(outbox, pub.IRI("https://example.com/activities/3")); ok {
+		t.Errorf("IsMember returned true for an item that is not a member")
+	}
+	if _, err := r.IsMember("https://example.com/actors/jdoe/inbox", act); err == nil {
+		t.Errorf("IsMember should fail for a missing collection")
+	}
+
+	if err := r.RemoveFrom(outbox, act); err != nil {
+		t.Fatalf("RemoveFrom returned error: %s", err)
+	}
+	it, _ = r.Load(outbox)
+	col = it.(*pub.OrderedCollection)
+	if col.TotalItems != 1 || col.OrderedItems[0].GetLink() != "https://example.com/activities/2" {
+		t.Errorf("invalid collection after RemoveFrom: %v", col.OrderedItems)
+	}
+}
+
+func TestRepo_Counters(t *testing.T) {
+	r := New()
+	iri := pub.IRI("https://example.com/objects/1")
+
+	for _, delta := range []int{1, 1, -1, 3} {
+		if _, err := r.IncrementCounter(iri, "likes", delta); err != nil {
+			t.Fatalf("IncrementCounter returned error: %s", err)
+		}
+	}
+	counters, err := r.LoadCounters(iri)
+	if err != nil {
+		t.Fatalf("LoadCounters returned error: %s", err)
+	}
+	if counters["likes"] != 4 {
+		t.Errorf("expected likes counter to be 4, got %d", counters["likes"])
+	}
+	counters["likes"] = 0
+	if counters, _ = r.LoadCounters(iri); counters["likes"] != 4 {
+		t.Errorf("LoadCounters should return a copy of the counters")
+	}
+}
+
+func TestRepo_RecordVote(t *testing.T) {
+	r := New()
+	q := pub.QuestionNew("https://example.com/questions/1")
+	yes, no := pub.ObjectNew(pub.NoteType), pub.ObjectNew(pub.NoteType)
+	yes.Name.Set(pub.NilLangRef, pub.Content("yes"))
+	no.Name.Set(pub.NilLangRef, pub.Content("no"))
+	q.OneOf = pub.ItemCollection{yes, no}
+	r.Save(q)
+
+	voter := pub.IRI("https://example.com/actors/jdoe")
+	if err := r.RecordVote(q.ID, voter, "yes"); err != nil {
+		t.Fatalf("RecordVote returned error: %s", err)
+	}
+	if err := r.RecordVote(q.ID, voter, "no"); !errors.Is(err, storage.ErrDuplicateVote) {
+		t.Errorf("second vote on a single choice poll returned %v, expected %s", err, storage.ErrDuplicateVote)
+	}
+	if err := r.RecordVote(q.ID, "https://example.com/actors/alice", "maybe"); err == nil {
+		t.Errorf("vote for an invalid option should fail")
+	}
+	if err := r.RecordVote("https://example.com/questions/2", voter, "yes"); err == nil {
+		t.Errorf("vote on a missing question should fail")
+	}
+
+	results, err := r.PollResults(q.ID)
+	if err != nil {
+		t.Fatalf("PollResults returned error: %s", err)
+	}
+	if len(results) != 2 || results["yes"] != 1 || results["no"] != 0 {
+		t.Errorf("invalid poll results %v", results)
+	}
+}
+
+func TestRepo_DeleteMatching(t *testing.T) {
+	tests := []struct {
+		name  string
+		f     storage.Filterable
+		count uint
+		purge bool
+	}{
+		{
+			name:  "prefix",
+			f:     pub.IRI("https://example.com/objects"),
+			count: 2,
+		},
+		{
+			name:  "filter",
+			f:     storage.Filter{Text: []string{"spam"}},
+			count: 1,
+		},
+		{
+			name:  "purge",
+			f:     purge{pub.IRI("https://example.com/objects/1")},
+			count: 1,
+			purge: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := New()
+			inbox := pub.IRI("https://example.com/actors/jdoe/inbox")
+			r.Create(pub.OrderedCollectionNew(inbox))
+			for _, ob := range []*pub.Object{
+				note("https://example.com/objects/1", "spam"),
+				note("https://example.com/objects/2", "ham"),
+				note("https://example.org/objects/3", "ham"),
+			} {
+				r.Save(ob)
+				r.AddTo(inbox, ob)
+			}
+			r.Save(note("https://example.com/objects/1", "more spam"))
+			r.IncrementCounter("https://example.com/objects/1", "likes", 1)
+
+			count, err := r.DeleteMatching(tt.f)
+			if err != nil {
+				t.Fatalf("DeleteMatching returned error: %s", err)
+			}
+			if count != tt.count {
+				t.Errorf("DeleteMatching returned %d, expected %d", count, tt.count)
+			}
+			it, err := r.Load("https://example.com/objects/1")
+			if tt.purge {
+				if err == nil {
+					t.Errorf("purged objects should be removed")
+				}
+			} else if err != nil || it.GetType() != pub.TombstoneType {
+				t.Errorf("deleted objects should be replaced with Tombstones, got %v: %v", it, err)
+			}
+			if versions, _ := r.LoadVersions("https://example.com/objects/1"); len(versions) > 0 {
+				t.Errorf("deleted objects should not keep their versions, got %d", len(versions))
+			}
+			if counters, _ := r.LoadCounters("https://example.com/objects/1"); len(counters) > 0 {
+				t.Errorf("deleted objects should not keep their counters, got %v", counters)
+			}
+			it, _ = r.Load(inbox)
+			if n := len(it.(*pub.OrderedCollection).OrderedItems); n != 3-int(tt.count) {
+				t.Errorf("expected %d items in the collection after DeleteMatching, got %d", 3-tt.count, n)
+			}
+			if count, _ = r.DeleteMatching(tt.f); count != 0 {
+				t.Errorf("DeleteMatching should ignore already deleted objects, deleted %d", count)
+			}
+		})
+	}
+}
+
+type purge struct {
+	pub.IRI
+}
+
+func (p purge) Purge() bool {
+	return true
+}
+
+func TestRepo_VerifyUniqueIDs(t *testing.T) {
+	r := New()
+	iri := pub.IRI("https://example.com/actors/jdoe/outbox")
+	r.Save(note(iri, "duplicate"))
+	r.Create(pub.OrderedCollectionNew(iri))
+	r.Create(pub.OrderedCollectionNew("https://example.com/actors/jdoe/inbox"))
+
+	dups, err := r.VerifyUniqueIDs()
+	if err != nil {
+		t.Fatalf("VerifyUniqueIDs returned error: %s", err)
+	}
+	if len(dups) != 1 || dups[0] != iri {
+		t.Errorf("VerifyUniqueIDs returned %v, expected [%s]", dups, iri)
+	}
+}
+
+func TestRepo_LoadPage(t *testing.T) {
+	r := New()
+	outbox := pub.IRI("https://example.com/actors/jdoe/outbox")
+	r.Create(pub.OrderedCollectionNew(outbox))
+	for _, iri := range []pub.IRI{"https://example.com/objects/1", "https://example.com/objects/2", "https://example.com/objects/3"} {
+		r.Save(note(iri, "Hello"))
+		r.AddTo(outbox, iri)
+	}
+
+	page, next, err := r.LoadPage(storage.Page{IRI: outbox, Max: 2})
+	if err != nil {
+		t.Fatalf("LoadPage returned error: %s", err)
+	}
+	if len(page) != 2 || page[0].GetType() != pub.NoteType || next == "" {
+		t.Fatalf("invalid first page %v, next %q", page, next)
+	}
+	page, next, err = r.LoadPage(storage.Page{IRI: outbox, Max: 2, After: next})
+	if err != nil {
+		t.Fatalf("LoadPage returned error: %s", err)
+	}
+	if len(page) != 1 || page[0].GetLink() != "https://example.com/objects/3" || next != "" {
+		t.Errorf("invalid last page %v, next %q", page, next)
+	}
+	if _, _, err := r.LoadPage(storage.Page{IRI: "https://example.com/actors/jdoe/inbox"}); err == nil {
+		t.Errorf("LoadPage of a missing collection should fail")
+	}
+}
+
+func TestRepo_Context(t *testing.T) {
+	r := New()
+	ctx, cancel := context.WithCancel(context.Background())
+	ob := note("https://example.com/objects/1", "Hello")
+	if _, err := r.SaveContext(ctx, ob); err != nil {
+		t.Fatalf("SaveContext returned error: %s", err)
+	}
+	cancel()
+	if _, err := r.LoadContext(ctx, ob.ID); !errors.Is(err, context.Canceled) {
+		t.Errorf("LoadContext with a cancelled context returned %v, expected %s", err, context.Canceled)
+	}
+	if err := r.DeleteContext(ctx, ob); !errors.Is(err, context.Canceled) {
+		t.Errorf("DeleteContext with a cancelled context returned %v, expected %s", err, context.Canceled)
+	}
+	if _, err := r.Load(ob.ID); err != nil {
+		t.Errorf("cancelled operations should not change the storage: %s", err)
+	}
+}
+
+// countdown is a context which is done after its Err method has been called "n" times.
+type countdown struct {
+	context.Context
+	n int
+}
+
+func (c *countdown) Err() error {
+	if c.n <= 0 {
+		return context.DeadlineExceeded
+	}
+	c.n--
+	return nil
+}
+
+func TestRepo_LoadCollectionContext(t *testing.T) {
+	r := New()
+	inbox := pub.IRI("https://example.com/actors/jdoe/inbox")
+	r.Create(pub.OrderedCollectionNew(inbox))
+	for _, iri := range []pub.IRI{"https://example.com/objects/1", "https://example.com/objects/2", "https://example.com/objects/3"} {
+		r.Save(note(iri, "Hello"))
+		r.AddTo(inbox, iri)
+	}
+
+	if _, _, err := r.LoadCollectionContext(&countdown{Context: context.Background(), n: 2}, inbox, inbox); !errors.Is(err, context.DeadlineExceeded) || storage.IsPartial(err) {
+		t.Errorf("interrupted LoadCollectionContext returned %v, expected %s", err, context.DeadlineExceeded)
+	}
+	items, _, err := r.LoadCollectionContext(&countdown{Context: context.Background(), n: 2}, inbox, storage.Filter{IRI: inbox, Partial: true})
+	if !storage.IsPartial(err) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("interrupted LoadCollectionContext returned %v, expected a partial result", err)
+	}
+	if len(items) != 2 || items[0].GetLink() != "https://example.com/objects/2" {
+		t.Errorf("the partial result should have the members read before the deadline, got %v", items)
+	}
+
+	seen := 0
+	err = r.EachContext(&countdown{Context: context.Background(), n: 1}, storage.Filter{IRI: inbox, Partial: true}, func(pub.Item) error {
+		seen++
+		return nil
+	})
+	if seen != 1 || !storage.IsPartial(err) {
+		t.Errorf("interrupted EachContext called fn %d times and returned %v, expected a partial result", seen, err)
+	}
+}
+
+func TestRepo_LoadCollectionPage(t *testing.T) {
+	r := New()
+	outbox := pub.IRI("https://example.com/actors/jdoe/outbox")
+	r.Create(pub.OrderedCollectionNew(outbox))
+	for _, iri := range []pub.IRI{"https://example.com/objects/1", "https://example.com/objects/2", "https://example.com/objects/3"} {
+		ob := note(iri, "hello")
+		r.Save(ob)
+		r.AddTo(outbox, ob)
+	}
+	r.AddTo(outbox, pub.IRI("https://example.com/objects/4"))
+
+	page, err := storage.LoadCollectionPage(r, storage.Page{IRI: outbox, Max: 3})
+	if err != nil {
+		t.Fatalf("LoadCollectionPage returned error: %s", err)
+	}
+	if page.TotalItems != 4 || len(page.OrderedItems) != 3 || !pub.IsIRI(page.OrderedItems[0]) || page.OrderedItems[1].GetType() != pub.NoteType {
+		t.Errorf("LoadCollectionPage returned %v of %d items, expected the 3 most recent of 4 items", page.OrderedItems, page.TotalItems)
+	}
+	next, err := storage.LoadCollectionPage(r, storage.Page{IRI: outbox, Max: 3, After: storage.PageCursor("https://example.com/objects/2")})
+	if err != nil {
+		t.Fatalf("LoadCollectionPage returned error: %s", err)
+	}
+	if next.ID != page.Next.GetLink() || len(next.OrderedItems) != 1 || next.Next != nil {
+		t.Errorf("LoadCollectionPage returned %v, expected the oldest item on the last page", next.OrderedItems)
+	}
+
+	old := note("https://example.com/objects/2", "hello")
+	old.Published = time.Now().UTC().Add(-time.Hour)
+	r.Save(old)
+	sorted, err := storage.LoadCollectionPage(r, storage.Page{IRI: outbox, Max: 2, Order: storage.SortOrder{By: storage.SortPublished}})
+	if err != nil {
+		t.Fatalf("LoadCollectionPage returned error: %s", err)
+	}
+	if len(sorted.OrderedItems) != 2 || sorted.OrderedItems[0].GetLink() != old.ID || !pub.IsIRI(sorted.OrderedItems[1]) {
+		t.Errorf("LoadCollectionPage returned %v, expected the published member, then the ones without dates", sorted.OrderedItems)
+	}
+	if !strings.HasSuffix(sorted.Next.GetLink().String(), "&sort=published") {
+		t.Errorf("the next page %s should keep the sort order", sorted.Next.GetLink())
+	}
+}
+
+func TestRepo_FanOut(t *testing.T) {
+	r := New()
+	ob := note("https://example.com/objects/1", "1")
+	r.Save(ob)
+	inboxes := pub.IRIs{"https://example.com/actors/jdoe/inbox", "https://example.com/actors/alice/inbox"}
+	for _, inbox := range inboxes {
+		r.Create(pub.OrderedCollectionNew(inbox))
+	}
+
+	if err := r.FanOut(ob, append(inboxes, "https://example.com/actors/bob/inbox")...); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("FanOut to a missing collection returned %v, expected %s", err, storage.ErrNotFound)
+	}
+	for _, inbox := range inboxes {
+		if ok, _ := r.IsMember(inbox, ob); ok {
+			t.Errorf("FanOut should not add %s to any collection when one is missing", ob.ID)
+		}
+	}
+	if err := storage.FanOut(r, ob, inboxes...); err != nil {
+		t.Fatalf("FanOut returned error: %s", err)
+	}
+	for _, inbox := range inboxes {
+		if ok, _ := r.IsMember(inbox, ob); !ok {
+			t.Errorf("FanOut should add %s to %s", ob.ID, inbox)
+		}
+	}
+}
+
+func TestRepo_SaveAll(t *testing.T) {
+	r := New()
+	items := []pub.Item{
+		note("https://example.com/objects/1", "1"),
+		pub.OrderedCollectionNew("https://example.com/actors/jdoe/outbox"),
+	}
+
+	if _, err := r.SaveAll(append(items, pub.ObjectNew(pub.NoteType))); err == nil {
+		t.Errorf("SaveAll with an invalid item should fail")
+	}
+	if len(r.items) != 0 || len(r.collections) != 0 {
+		t.Errorf("SaveAll should not store any item when one is invalid")
+	}
+	if _, err := r.SaveAll(items); err != nil {
+		t.Fatalf("SaveAll returned error: %s", err)
+	}
+	for _, it := range items {
+		if _, err := r.Load(it.GetLink()); err != nil {
+			t.Errorf("Load after SaveAll returned error: %s", err)
+		}
+	}
+	if err := r.DeleteAll(items); err != nil {
+		t.Fatalf("DeleteAll returned error: %s", err)
+	}
+	if len(r.items) != 0 || len(r.collections) != 0 {
+		t.Errorf("DeleteAll should remove all items")
+	}
+}
+
+func TestRepo_Each(t *testing.T) {
+	r := New()
+	outbox := pub.IRI("https://example.com/actors/jdoe/outbox")
+	r.Create(pub.OrderedCollectionNew(outbox))
+	for _, ob := range []*pub.Object{
+		note("https://example.com/objects/1", "hello"),
+		note("https://example.com/objects/10", "goodbye"),
+		note("https://example.com/objects/1/replies/1", "reply"),
+		note("https://example.org/objects/1", "hello"),
+	} {
+		r.Save(ob)
+	}
+	r.AddTo(outbox, pub.IRI("https://example.com/objects/10"))
+	r.AddTo(outbox, pub.IRI("https://example.com/objects/2"))
+
+	tests := []struct {
+		name string
+		f    storage.Filterable
+		want int
+	}{
+		{name: "all", f: pub.IRI(""), want: 4},
+		{name: "prefix", f: pub.IRI("https://example.com/objects/1"), want: 2},
+		{name: "collection", f: outbox, want: 1},
+		{name: "filter", f: storage.Filter{Text: []string{"hello"}}, want: 2},
+		{name: "prefixes", f: storage.Filter{Prefix: pub.IRIs{"https://example.com/objects/10", "https://example.org"}}, want: 2},
+		{name: "exclusions", f: storage.Filter{Exclude: pub.IRIs{"https://example.org", "https://example.com/objects/1/replies"}}, want: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			count := 0
+			err := r.Each(tt.f, func(it pub.Item) error {
+				count++
+				// the callback can write to the storage
+				_, err := r.Save(it)
+				return err
+			})
+			if err != nil {
+				t.Fatalf("Each returned error: %s", err)
+			}
+			if count != tt.want {
+				t.Errorf("Each returned %d items, expected %d", count, tt.want)
+			}
+		})
+	}
+}
+
+func TestRepo_Count(t *testing.T) {
+	r := New()
+	outbox := pub.IRI("https://example.com/actors/jdoe/outbox")
+	r.Create(pub.OrderedCollectionNew(outbox))
+	for _, ob := range []*pub.Object{
+		note("https://example.com/objects/1", "hello"),
+		note("https://example.com/objects/2", "goodbye"),
+	} {
+		r.Save(ob)
+		r.AddTo(outbox, ob)
+	}
+	r.AddTo(outbox, pub.IRI("https://example.com/objects/3"))
+
+	tests := []struct {
+		name string
+		f    storage.Filterable
+		want uint
+	}{
+		{name: "collection", f: outbox, want: 2},
+		{name: "prefix", f: pub.IRI("https://example.com/objects"), want: 2},
+		{name: "filter", f: storage.Filter{IRI: outbox, Text: []string{"bye"}}, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.Count(tt.f)
+			if err != nil {
+				t.Fatalf("Count returned error: %s", err)
+			}
+			if got != tt.want {
+				t.Errorf("Count returned %d, expected %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRepo_Exists(t *testing.T) {
+	r := New()
+	r.Save(note("https://example.com/objects/1", "hello"))
+	r.Create(pub.OrderedCollectionNew("https://example.com/actors/jdoe/outbox"))
+
+	for iri, want := range map[pub.IRI]bool{
+		"https://example.com/objects/1":          true,
+		"https://example.com/actors/jdoe/outbox": true,
+		"https://example.com/objects/2":          false,
+	} {
+		if got, err := r.Exists(iri); err != nil || got != want {
+			t.Errorf("Exists(%s) returned %t, %v, expected %t", iri, got, err, want)
+		}
+	}
+	if _, err := r.Load("https://example.com/objects/2"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Load of missing object returned %v, expected %s", err, storage.ErrNotFound)
+	}
+}
+
+func TestRepo_Metadata(t *testing.T) {
+	r := New()
+	actor := pub.PersonNew("https://example.com/actors/jdoe")
+	r.Save(actor)
+
+	if _, err := r.LoadMetadata(actor.ID, "key"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("LoadMetadata of missing metadata returned %v, expected %s", err, storage.ErrNotFound)
+	}
+	data := []byte("secret")
+	if err := r.SaveMetadata(actor.ID, "key", data); err != nil {
+		t.Fatalf("SaveMetadata returned error: %s", err)
+	}
+	data[0] = 'S'
+	r.SaveMetadata(actor.ID, "password", []byte("hash"))
+
+	got, err := r.LoadMetadata(actor.ID, "key")
+	if err != nil {
+		t.Fatalf("LoadMetadata returned error: %s", err)
+	}
+	if string(got) != "secret" {
+		t.Errorf("LoadMetadata returned %q, expected %q", got, "secret")
+	}
+
+	r.Delete(actor)
+	if _, err := r.LoadMetadata(actor.ID, "password"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Delete should remove the metadata of the item, got %v", err)
+	}
+}
+
+func TestRepo_LoadCollection(t *testing.T) {
+	r := New()
+	outbox := pub.IRI("https://example.com/actors/jdoe/outbox")
+	r.Create(pub.OrderedCollectionNew(outbox))
+	for _, ob := range []*pub.Object{
+		note("https://example.com/objects/1", "hello"),
+		note("https://example.com/objects/2", "goodbye"),
+		note("https://example.com/objects/3", "hello again"),
+	} {
+		r.Save(ob)
+		r.AddTo(outbox, ob)
+	}
+
+	items, next, err := r.LoadCollection(outbox, storage.Page{Max: 2})
+	if err != nil {
+		t.Fatalf("LoadCollection returned error: %s", err)
+	}
+	if len(items) != 2 || items[0].GetLink() != "https://example.com/objects/3" || next == "" {
+		t.Errorf("LoadCollection returned %v, %q, expected the 2 most recent items", items, next)
+	}
+	items, _, _ = r.LoadCollection(outbox, storage.Filter{Text: []string{"bye"}})
+	if len(items) != 1 || items[0].GetType() != pub.NoteType {
+		t.Errorf("LoadCollection returned %v, expected the matching item", items)
+	}
+	if _, _, err := r.LoadCollection("https://example.com/actors/jdoe/inbox", storage.Page{}); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("LoadCollection of a missing collection returned %v, expected %s", err, storage.ErrNotFound)
+	}
+}
+
+func TestRepo_LoadIndex(t *testing.T) {
+	r := New()
+	jdoe := pub.IRI("https://example.com/actors/jdoe")
+	alice := pub.IRI("https://example.com/actors/alice")
+	ob := note("https://example.com/objects/1", "hello")
+	ob.AttributedTo = jdoe
+	r.Save(ob)
+
+	if iris, _ := r.LoadIndex(storage.IndexAttributedTo, jdoe.String()); len(iris) != 1 || iris[0] != ob.ID {
+		t.Errorf("LoadIndex returned %v, expected %s", iris, ob.ID)
+	}
+	ob.AttributedTo = alice
+	r.Save(ob)
+	if iris, _ := r.LoadIndex(storage.IndexAttributedTo, jdoe.String()); len(iris) != 0 {
+		t.Errorf("LoadIndex returned %v, expected the previous keys to be removed", iris)
+	}
+
+	r.WithTx(func(tx storage.Store) error {
+		tx.Delete(ob.ID)
+		return errors.New("rollback")
+	})
+	if iris, _ := r.LoadIndex(storage.IndexAttributedTo, alice.String()); len(iris) != 1 {
+		t.Errorf("LoadIndex returned %v, expected the index to be restored on rollback", iris)
+	}
+
+	count := 0
+	r.Each(storage.Filter{Author: pub.IRIs{alice}}, func(it pub.Item) error {
+		count++
+		return nil
+	})
+	if count != 1 {
+		t.Errorf("Each returned %d objects attributed to %s, expected 1", count, alice)
+	}
+
+	r.Delete(ob.ID)
+	if iris, _ := r.LoadIndex(storage.IndexType, string(pub.NoteType)); len(iris) != 0 {
+		t.Errorf("LoadIndex returned %v, expected deleted objects to be removed from the index", iris)
+	}
+}
+
+func TestRepo_Search(t *testing.T) {
+	r := New()
+	older := note("https://example.com/objects/1", "<p>Hello world</p>")
+	older.Published = time.Now().Add(-time.Hour)
+	newer := note("https://example.com/objects/2", "hello there")
+	newer.Published = time.Now()
+	r.Save(older)
+	r.Save(newer)
+	r.Save(note("https://example.org/objects/1", "hello world"))
+
+	tests := []struct {
+		name  string
+		query string
+		f     storage.Filterable
+		want  pub.IRIs
+	}{
+		{name: "single term", query: "hello", f: pub.IRI("https://example.com"), want: pub.IRIs{newer.ID, older.ID}},
+		{name: "all terms", query: "world hello", f: pub.IRI("https://example.com"), want: pub.IRIs{older.ID}},
+		{name: "filter", query: "hello", f: storage.Filter{Text: []string{"there"}}, want: pub.IRIs{newer.ID}},
+		{name: "no match", query: "goodbye", f: pub.IRI(""), want: pub.IRIs{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := r.Search(tt.query, tt.f)
+			if err != nil {
+				t.Fatalf("Search returned error: %s", err)
+			}
+			if len(result) != len(tt.want) {
+				t.Fatalf("Search returned %v, expected %v", result, tt.want)
+			}
+			for i, it := range result {
+				if it.GetLink() != tt.want[i] {
+					t.Errorf("Search returned %s at position %d, expected %s", it.GetLink(), i, tt.want[i])
+				}
+			}
+		})
+	}
+
+	r.Save(note(older.ID, "goodbye"))
+	if result, _ := r.Search("world", pub.IRI("https://example.com")); len(result) != 0 {
+		t.Errorf("Search returned %v, expected the index to be updated on Save", result)
+	}
+}
+
+func TestRepo_LoadReplies(t *testing.T) {
+	r := New()
+	root := note("https://example.com/objects/1", "hello")
+	r.Save(root)
+	for i, parent := range []pub.IRI{root.ID, root.ID, "https://example.com/objects/r1"} {
+		ob := note(pub.IRI(fmt.Sprintf("https://example.com/objects/r%d", i+1)), "reply")
+		ob.InReplyTo = parent
+		ob.Published = time.Now().Add(time.Duration(i) * time.Minute)
+		r.Save(ob)
+	}
+
+	thread, err := r.LoadReplies(root.ID, 0)
+	if err != nil {
+		t.Fatalf("LoadReplies returned error: %s", err)
+	}
+	if len(thread.Replies) != 2 || thread.Replies[0].Item.GetLink() != "https://example.com/objects/r1" {
+		t.Fatalf("LoadReplies returned %v, expected the direct replies, oldest first", thread.Replies)
+	}
+	if len(thread.Replies[0].Replies) != 1 || thread.Replies[0].Replies[0].Item.GetLink() != "https://example.com/objects/r3" {
+		t.Errorf("LoadReplies returned %v, expected the nested reply", thread.Replies[0].Replies)
+	}
+	if _, err := r.LoadReplies("https://example.com/missing", 0); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("LoadReplies of a missing object returned %v, expected %s", err, storage.ErrNotFound)
+	}
+}
+
+func TestConformance(t *testing.T) {
+	storagetest.RunStoreTests(t, func() storage.Store { return New() })
+}
+
+func TestRepo_BackupRestore(t *testing.T) {
+	r := New()
+	ob := note("https://example.com/objects/1", "hello")
+	outbox := pub.IRI("https://example.com/actors/jdoe/outbox")
+	r.Save(ob)
+	r.Create(pub.OrderedCollectionNew(outbox))
+	r.AddTo(outbox, ob)
+
+	buf := bytes.Buffer{}
+	if err := storage.Backup(r, &buf); err != nil {
+		t.Fatalf("Backup returned error: %s", err)
+	}
+	restored := New()
+	if err := storage.Restore(restored, bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("Restore returned error: %s", err)
+	}
+	if it, err := restored.Load(ob.ID); err != nil || !it.GetLink().Equals(ob.ID, false) {
+		t.Errorf("Load(%s) returned %v, %v, expected the restored object", ob.ID, it, err)
+	}
+	if ok, err := restored.IsMember(outbox, ob); err != nil || !ok {
+		t.Errorf("IsMember returned %t, %v, expected the restored collection to contain %s", ok, err, ob.ID)
+	}
+
+	invalid := append(buf.Bytes(), []byte("{not json\n")...)
+	if err := New().Restore(bytes.NewReader(invalid)); !errors.Is(err, storage.ErrNotValid) {
+		t.Errorf("Restore of an invalid backup returned %v, expected %s", err, storage.ErrNotValid)
+	}
+}
+
+func TestRepo_BackupSince(t *testing.T) {
+	r := New()
+	old := note("https://example.com/objects/1", "old")
+	recent := note("https://example.com/objects/2", "recent")
+	outbox := pub.IRI("https://example.com/actors/jdoe/outbox")
+	r.Save(old)
+	r.Save(recent)
+	r.Create(pub.OrderedCollectionNew(outbox))
+	r.modified[old.ID] = time.Now().Add(-time.Hour)
+	r.modified[outbox] = time.Now().Add(-time.Hour)
+
+	since := time.Now().Add(-time.Minute)
+	backup := func() pub.IRIs {
+		buf := bytes.Buffer{}
+		if err := storage.BackupSince(r, &buf, since); err != nil {
+			t.Fatalf("BackupSince returned error: %s", err)
+		}
+		got := make(pub.IRIs, 0)
+		storage.ReadExport(&buf, func(it pub.Item) error {
+			got = append(got, it.GetLink())
+			return nil
+		})
+		return got
+	}
+	if got := backup(); len(got) != 1 || !got.Contains(recent.ID) {
+		t.Errorf("BackupSince wrote %v, expected only %s", got, recent.ID)
+	}
+	r.AddTo(outbox, old)
+	if got := backup(); len(got) != 2 || !got.Contains(outbox) {
+		t.Errorf("BackupSince wrote %v, expected the modified %s collection", got, outbox)
+	}
+}
+
+func TestRepo_CacheStore(t *testing.T) {
+	r := New()
+	now := time.Now()
+	fresh := note("https://remote.example/objects/1", "fresh")
+	stale := note("https://remote.example/objects/2", "stale")
+	r.SaveRemote(fresh, storage.Freshness{FetchedAt: now, ETag: "v1", MaxAge: time.Hour})
+	r.SaveRemote(stale, storage.Freshness{FetchedAt: now.Add(-2 * time.Hour), MaxAge: time.Hour})
+
+	if _, err := r.Load(fresh.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Load of a cached remote object returned %v, expected %s", err, storage.ErrNotFound)
+	}
+	it, f, err := r.LoadRemote(fresh.ID)
+	if err != nil {
+		t.Fatalf("LoadRemote returned error: %s", err)
+	}
+	if !it.GetLink().Equals(fresh.ID, false) || f.ETag != "v1" || !f.Fresh(now) {
+		t.Errorf("LoadRemote returned %v, %+v, expected the fresh object", it, f)
+	}
+
+	if n, err := r.Expire(now); err != nil || n != 1 {
+		t.Errorf("Expire returned %d, %v, expected 1 expired object", n, err)
+	}
+	if _, _, err := r.LoadRemote(stale.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("LoadRemote of an expired object returned %v, expected %s", err, storage.ErrNotFound)
+	}
+	r.DeleteRemote(fresh.ID)
+	if _, _, err := r.LoadRemote(fresh.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("LoadRemote of a deleted object returned %v, expected %s", err, storage.ErrNotFound)
+	}
+}
+
+func TestRepo_Close(t *testing.T) {
+	r := New()
+	iri := pub.IRI("https://example.com/objects/1")
+	r.Save(note(iri, "hello"))
+	r.Create(pub.OrderedCollectionNew("https://example.com/inbox"))
+	r.IncrementCounter(iri, "likes", 1)
+	r.SaveMetadata(iri, "keys", []byte("secret"))
+	r.Enqueue("https://example.com/activities/1", "https://remote.example/inbox")
+
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close returned error: %s", err)
+	}
+	if len(r.counters) > 0 || len(r.metadata) > 0 || len(r.votes) > 0 || len(r.queue) > 0 {
+		t.Errorf("Close should release all the stored data")
+	}
+
+	ops := map[string]func() error{
+		"Update":           func() error { _, err := r.Update(note(iri, "again"), time.Time{}); return err },
+		"IncrementCounter": func() error { _, err := r.IncrementCounter(iri, "likes", 1); return err },
+		"LoadCounters":     func() error { _, err := r.LoadCounters(iri); return err },
+		"LoadMetadata":     func() error { _, err := r.LoadMetadata(iri, "keys"); return err },
+		"SaveMetadata":     func() error { return r.SaveMetadata(iri, "keys", nil) },
+		"IsMember":         func() error { _, err := r.IsMember("https://example.com/inbox", iri); return err },
+		"WithTx":           func() error { return r.WithTx(func(storage.Store) error { return nil }) },
+		"Enqueue": func() error {
+			_, err := r.Enqueue("https://example.com/activities/1", "https://remote.example/inbox")
+			return err
+		},
+		"Search": func() error { _, err := r.Search("hello", storage.Filter{}); return err },
+	}
+	for name, op := range ops {
+		if err := op(); !errors.Is(err, storage.ErrClosed) {
+			t.Errorf("%s after Close returned %v, expected %s", name, err, storage.ErrClosed)
+		}
+	}
+}
+
+func TestRepo_Stats(t *testing.T) {
+	r := New()
+	r.Save(note("https://example.com/objects/1", "hello"))
+	r.Save(note("https://example.com/objects/1", "hello again"))
+	r.Create(pub.OrderedCollectionNew("https://example.com/inbox"))
+
+	stats, err := r.Stats()
+	if err != nil {
+		t.Fatalf("Stats returned error: %s", err)
+	}
+	if stats["items"] != 1 || stats["collections"] != 1 || stats["versions"] != 1 || stats["bytes"] == 0 {
+		t.Errorf("unexpected stats %v", stats)
+	}
+}
+
+func TestRepo_Rename(t *testing.T) {
+	r := New()
+	old, renamed := pub.IRI("https://old.example/actors/jdoe"), pub.IRI("https://example.com/actors/jdoe")
+	followers := pub.IRI("https://example.com/actors/alice/followers")
+	r.Save(&pub.Actor{ID: old, Type: pub.PersonType, Followers: followers})
+	r.Save(note("https://example.com/objects/1", "hello"))
+	r.Create(pub.OrderedCollectionNew(followers))
+	r.AddTo(followers, pub.IRI("https://example.com/actors/bob"))
+	r.AddTo(followers, pub.IRI(old))
+	r.SaveMetadata(old, "settings", []byte(`{"theme":"dark"}`))
+
+	if err := storage.Rename(r, old, "https://example.com/objects/1"); !errors.Is(err, storage.ErrConflict) {
+		t.Errorf("Rename to an existing IRI returned %v, expected %s", err, storage.ErrConflict)
+	}
+	if err := storage.Rename(r, old, renamed); err != nil {
+		t.Fatalf("Rename returned error: %s", err)
+	}
+	if _, err := r.Load(old); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Load of the old IRI returned %v, expected %s", err, storage.ErrNotFound)
+	}
+	it, err := r.Load(renamed)
+	if err != nil || it.GetLink() != renamed || it.GetType() != pub.PersonType {
+		t.Errorf("Load of the new IRI returned %v, %v", it, err)
+	}
+	if ok, _ := r.IsMember(followers, renamed); !ok {
+		t.Errorf("Rename should replace %s in %s", old, followers)
+	}
+	if ok, _ := r.IsMember(followers, old); ok {
+		t.Errorf("Rename should remove %s from %s", old, followers)
+	}
+	if iris, _ := r.LoadIndex(storage.IndexType, string(pub.PersonType)); len(iris) != 1 || iris[0] != renamed {
+		t.Errorf("the type index holds %v, expected only %s", iris, renamed)
+	}
+	if data, _ := r.LoadMetadata(renamed, "settings"); len(data) == 0 {
+		t.Errorf("Rename should move the metadata")
+	}
+	if doc := r.collections[followers]; doc.Items[1] != renamed {
+		t.Errorf("Rename should keep the position of the member, got %v", doc.Items)
+	}
+}
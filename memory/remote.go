@@ -0,0 +1,82 @@
+package memory
+
+import (
+	"fmt"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage"
+)
+
+type cached struct {
+	raw       []byte
+	freshness storage.Freshness
+}
+
+var _ storage.CacheStore = &repo{}
+
+// SaveRemote caches the remote "it" object, with its freshness.
+func (r *repo) SaveRemote(it pub.Item, f storage.Freshness) error {
+	if pub.IsNil(it) || len(it.GetLink()) == 0 {
+		return fmt.Errorf("%w: unable to cache item without an ID", storage.ErrNotValid)
+	}
+	raw, err := pub.MarshalJSON(it)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return storage.ErrClosed
+	}
+	r.remote[it.GetLink()] = cached{raw: raw, freshness: f}
+	return nil
+}
+
+// LoadRemote returns the cached "iri" remote object, with its freshness.
+func (r *repo) LoadRemote(iri pub.IRI) (pub.Item, storage.Freshness, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.closed {
+		return nil, storage.Freshness{}, storage.ErrClosed
+	}
+	c, ok := r.remote[iri]
+	if !ok {
+		return nil, storage.Freshness{}, notFound(iri)
+	}
+	it, err := pub.UnmarshalJSON(c.raw)
+	return it, c.freshness, err
+}
+
+// DeleteRemote removes the cached "iri" remote object.
+func (r *repo) DeleteRemote(iri pub.IRI) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return storage.ErrClosed
+	}
+	delete(r.remote, iri)
+	return nil
+}
+
+// Expire removes the cached remote objects that have expired before the "before" moment.
+func (r *repo) Expire(before time.Time) (uint, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return 0, storage.ErrClosed
+	}
+	count := uint(0)
+	for iri, c := range r.remote {
+		if c.freshness.Expires().Before(before) {
+			delete(r.remote, iri)
+			count++
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,83 @@
+package memory
+
+import (
+	"fmt"
+
+	pub "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage"
+)
+
+var _ storage.RenameStore = &repo{}
+
+// Rename moves the "old" item, or collection, to the "new" IRI, together with its counters, metadata,
+// versions, binary data and index keys, and replaces it in the collections containing it.
+func (r *repo) Rename(old, new pub.IRI) error {
+	if len(old) == 0 || len(new) == 0 {
+		return fmt.Errorf("%w: unable to rename without an IRI", storage.ErrNotValid)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return storage.ErrClosed
+	}
+	if _, ok := r.items[new]; ok {
+		return fmt.Errorf("%w: %s already exists", storage.ErrConflict, new)
+	}
+	if _, ok := r.collections[new]; ok {
+		return fmt.Errorf("%w: %s already exists", storage.ErrConflict, new)
+	}
+
+	if doc, ok := r.collections[old]; ok {
+		renamed := *doc
+		renamed.ID = new
+		r.collections[new] = &renamed
+	} else if raw, ok := r.items[old]; ok {
+		it, err := pub.UnmarshalJSON(raw)
+		if err != nil {
+			return err
+		}
+		if err := pub.OnObject(it, func(ob *pub.Object) error {
+			ob.ID = new
+			return nil
+		}); err != nil {
+			return fmt.Errorf("%w: unable to rename %s: %s", storage.ErrNotValid, old, err)
+		}
+		if raw, err = pub.MarshalJSON(it); err != nil {
+			return err
+		}
+		r.items[new] = raw
+		r.indexItem(new, indexKeys(it))
+	} else {
+		return notFound(old)
+	}
+
+	if counters, ok := r.counters[old]; ok {
+		r.counters[new] = counters
+	}
+	if votes, ok := r.votes[old]; ok {
+		r.votes[new] = votes
+	}
+	if metadata, ok := r.metadata[old]; ok {
+		r.metadata[new] = metadata
+	}
+	if versions, ok := r.versions[old]; ok {
+		r.versions[new] = versions
+	}
+	if bin, ok := r.binaries[old]; ok {
+		r.binaries[new] = bin
+	}
+	r.delete(old)
+	r.modify(new)
+
+	for iri, doc := range r.collections {
+		for i, member := range doc.Items {
+			if member == old {
+				doc.Items[i] = new
+				r.modify(iri)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package memory
+
+import (
+	"fmt"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage"
+)
+
+// tx is the Store received by the functions running in a transaction. It keeps the state that
+// the items had before being modified for the first time, so it can be restored on rollback.
+type tx struct {
+	r      *repo
+	closed bool
+	undo   map[pub.IRI]snapshot
+}
+
+type snapshot struct {
+	raw      []byte
+	doc      *storage.CollectionDocument
+	counters map[string]int
+	votes    map[pub.IRI][]string
+	metadata map[string][]byte
+	versions [][]byte
+	modified time.Time
+	binary   *binary
+}
+
+var (
+	_ storage.Store           = &tx{}
+	_ storage.CollectionStore = &tx{}
+)
+
+// WithTx calls "fn" with a Store that applies its operations to the storage, and reverts them
+// if "fn" returns an error or panics.
+// The storage is locked while "fn" runs, so it must use only the Store it receives.
+func (r *repo) WithTx(fn func(storage.Store) error) (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return storage.ErrClosed
+	}
+	t := &tx{r: r, undo: make(map[pub.IRI]snapshot)}
+	defer func() {
+		t.closed = true
+		if p := recover(); p != nil {
+			t.rollback()
+			panic(p)
+		}
+		if err != nil {
+			t.rollback()
+		}
+	}()
+	return fn(t)
+}
+
+// Close does nothing, as the transaction ends when the function it's passed to returns, and the
+// storage is closed by its own Close.
+func (t *tx) Close() error {
+	return nil
+}
+
+// touch saves the current state of "iri", if it hasn't been modified before in the transaction.
+func (t *tx) touch(iri pub.IRI) {
+	if _, ok := t.undo[iri]; ok {
+		return
+	}
+	snap := snapshot{
+		raw:      t.r.items[iri],
+		counters: t.r.counters[iri],
+		votes:    t.r.votes[iri],
+		metadata: t.r.metadata[iri],
+		versions: t.r.versions[iri],
+		modified: t.r.modified[iri],
+	}
+	if b, ok := t.r.binaries[iri]; ok {
+		snap.binary = &b
+	}
+	if doc, ok := t.r.collections[iri]; ok {
+		cp := *doc
+		cp.Items = append([]pub.IRI(nil), doc.Items...)
+		snap.doc = &cp
+	}
+	t.undo[iri] = snap
+}
+
+func (t *tx) rollback() {
+	for iri, snap := range t.undo {
+		t.r.delete(iri)
+		if snap.binary != nil {
+			t.r.binaries[iri] = *snap.binary
+		}
+		if !snap.modified.IsZero() {
+			t.r.modified[iri] = snap.modified
+		}
+		if snap.raw != nil {
+			t.r.items[iri] = snap.raw
+			t.r.indexRaw(iri, snap.raw)
+		}
+		if snap.doc != nil {
+			t.r.collections[iri] = snap.doc
+		}
+		if snap.counters != nil {
+			t.r.counters[iri] = snap.counters
+		}
+		if snap.votes != nil {
+			t.r.votes[iri] = snap.votes
+		}
+		if snap.metadata != nil {
+			t.r.metadata[iri] = snap.metadata
+		}
+		if snap.versions != nil {
+			t.r.versions[iri] = snap.versions
+		}
+	}
+}
+
+func (t *tx) Load(iri pub.IRI) (pub.Item, error) {
+	if t.closed {
+		return nil, storage.ErrTxClosed
+	}
+	return t.r.load(iri)
+}
+
+func (t *tx) Save(it pub.Item) (pub.Item, error) {
+	if t.closed {
+		return nil, storage.ErrTxClosed
+	}
+	store, err := t.r.prepare(it)
+	if err != nil {
+		return nil, err
+	}
+	t.touch(it.GetLink())
+	store()
+	return it, nil
+}
+
+func (t *tx) Delete(it pub.Item) error {
+	if t.closed {
+		return storage.ErrTxClosed
+	}
+	if pub.IsNil(it) {
+		return nil
+	}
+	t.touch(it.GetLink())
+	t.r.delete(it.GetLink())
+	return nil
+}
+
+func (t *tx) Create(col pub.CollectionInterface) (pub.CollectionInterface, error) {
+	if t.closed {
+		return nil, storage.ErrTxClosed
+	}
+	if pub.IsNil(col) || len(col.GetLink()) == 0 {
+		return nil, fmt.Errorf("%w: unable to create collection without an ID", storage.ErrNotValid)
+	}
+	t.touch(col.GetLink())
+	return t.r.create(col), nil
+}
+
+func (t *tx) AddTo(col pub.IRI, it pub.Item) error {
+	if t.closed {
+		return storage.ErrTxClosed
+	}
+	t.touch(col)
+	return t.r.addTo(col, it.GetLink())
+}
+
+func (t *tx) RemoveFrom(col pub.IRI, it pub.Item) error {
+	if t.closed {
+		return storage.ErrTxClosed
+	}
+	t.touch(col)
+	return t.r.removeFrom(col, it.GetLink())
+}
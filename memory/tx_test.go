@@ -0,0 +1,98 @@
+package memory
+
+import (
+	"errors"
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage"
+)
+
+func TestRepo_WithTx(t *testing.T) {
+	outbox := pub.IRI("https://example.com/actors/jdoe/outbox")
+	ob := note("https://example.com/objects/1", "hello")
+	act := pub.CreateNew("https://example.com/activities/1", ob.ID)
+
+	store := func(tx storage.Store) error {
+		if _, err := tx.Save(ob); err != nil {
+			return err
+		}
+		if _, err := tx.Save(act); err != nil {
+			return err
+		}
+		return tx.(storage.CollectionStore).AddTo(outbox, act)
+	}
+
+	t.Run("commit", func(t *testing.T) {
+		r := New()
+		r.Create(pub.OrderedCollectionNew(outbox))
+		if err := r.WithTx(store); err != nil {
+			t.Fatalf("WithTx returned error: %s", err)
+		}
+		it, _ := r.Load(outbox)
+		if col := it.(*pub.OrderedCollection); len(col.OrderedItems) != 1 || col.OrderedItems[0].GetType() != pub.CreateType {
+			t.Errorf("invalid collection after commit: %v", col.OrderedItems)
+		}
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		r := New()
+		r.Create(pub.OrderedCollectionNew(outbox))
+		r.Save(note(ob.ID, "original"))
+		r.IncrementCounter(ob.ID, "likes", 1)
+
+		fail := errors.New("fail")
+		err := r.WithTx(func(tx storage.Store) error {
+			if err := store(tx); err != nil {
+				return err
+			}
+			if err := tx.Delete(ob); err != nil {
+				return err
+			}
+			return fail
+		})
+		if !errors.Is(err, fail) {
+			t.Fatalf("WithTx returned %v, expected %s", err, fail)
+		}
+		if it, err := r.Load(ob.ID); err != nil || !note(ob.ID, "original").Equals(it) {
+			t.Errorf("the object should have been restored, got %v: %v", it, err)
+		}
+		if counters, _ := r.LoadCounters(ob.ID); counters["likes"] != 1 {
+			t.Errorf("the counters of the object should have been restored, got %v", counters)
+		}
+		if ok, _ := r.Exists(act.ID); ok {
+			t.Errorf("the activity saved in the transaction should have been removed")
+		}
+		it, _ := r.Load(outbox)
+		if col := it.(*pub.OrderedCollection); len(col.OrderedItems) != 0 {
+			t.Errorf("the collection should have been restored, got %v", col.OrderedItems)
+		}
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		r := New()
+		r.Create(pub.OrderedCollectionNew(outbox))
+		func() {
+			defer func() { recover() }()
+			r.WithTx(func(tx storage.Store) error {
+				store(tx)
+				panic("fail")
+			})
+		}()
+		if ok, _ := r.Exists(ob.ID); ok {
+			t.Errorf("the transaction should have been rolled back on panic")
+		}
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		r := New()
+		var saved storage.Store
+		r.WithTx(func(tx storage.Store) error {
+			saved = tx
+			return nil
+		})
+		if _, err := saved.Save(ob); !errors.Is(err, storage.ErrTxClosed) {
+			t.Errorf("Save after the transaction ended returned %v, expected %s", err, storage.ErrTxClosed)
+		}
+	})
+}
@@ -0,0 +1,18 @@
+package storage
+
+import (
+	pub "github.com/go-ap/activitypub"
+)
+
+// MetadataStore persists the data that is associated with an item, but isn't an ActivityStreams object
+// itself, like the private key of an actor, or the hash of the password of a user.
+//
+// The data is opaque to the storage. It gets stored separately from the item, under a namespace
+// chosen by the caller, so different components can keep their own metadata for the same IRI.
+type MetadataStore interface {
+	// LoadMetadata returns the "namespace" metadata of the "iri" item, or an error wrapping
+	// ErrNotFound if there isn't any.
+	LoadMetadata(iri pub.IRI, namespace string) ([]byte, error)
+	// SaveMetadata replaces the "namespace" metadata of the "iri" item with "data".
+	SaveMetadata(iri pub.IRI, namespace string, data []byte) error
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// metadataMap is a minimal MetadataStore used for testing.
+type metadataMap map[string][]byte
+
+func (m metadataMap) LoadMetadata(iri pub.IRI, namespace string) ([]byte, error) {
+	data, ok := m[iri.String()+namespace]
+	if !ok {
+		return nil, fmt.Errorf("missing %s metadata: %w", namespace, ErrNotFound)
+	}
+	return data, nil
+}
+
+func (m metadataMap) SaveMetadata(iri pub.IRI, namespace string, data []byte) error {
+	m[iri.String()+namespace] = data
+	return nil
+}
+
+func TestMetadataStore_namespaces(t *testing.T) {
+	m := newBackendMapStore()
+	jdoe := pub.IRI("https://example.com/actors/jdoe")
+	follow := &pub.Activity{ID: "https://example.org/activities/1", Type: pub.FollowType, Actor: pub.IRI("https://example.org/actors/alice"), Object: jdoe}
+
+	// the components sharing the store keep their metadata of the same IRI in different namespaces
+	if err := Keys(m).SaveKey(jdoe, testKey(jdoe+"#main-key", true)); err != nil {
+		t.Fatalf("SaveKey returned error: %s", err)
+	}
+	if _, err := FollowRequests(m).Create(follow); err != nil {
+		t.Fatalf("Create returned error: %s", err)
+	}
+	if err := m.SaveMetadata(jdoe, "settings", []byte(`{"theme":"dark"}`)); err != nil {
+		t.Fatalf("SaveMetadata returned error: %s", err)
+	}
+
+	if k, err := Keys(m).LoadKey(jdoe); err != nil || k.ID != jdoe+"#main-key" {
+		t.Errorf("LoadKey returned %v, %v", k, err)
+	}
+	if pending, err := FollowRequests(m).ListPending(jdoe); err != nil || len(pending) != 1 {
+		t.Errorf("ListPending returned %v, %v", pending, err)
+	}
+	if data, err := m.LoadMetadata(jdoe, "settings"); err != nil || string(data) != `{"theme":"dark"}` {
+		t.Errorf("LoadMetadata returned %s, %v", data, err)
+	}
+}
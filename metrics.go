@@ -0,0 +1,205 @@
+package storage
+
+import (
+	"errors"
+	"expvar"
+	"sync"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// Observation describes one operation run by an InstrumentedStore.
+type Observation struct {
+	// Op is the name of the operation: "load", "save", "delete", "create", "add", or "remove".
+	Op string
+	// IRI is the IRI of the loaded, saved or deleted item, or of the collection for the collection operations.
+	IRI pub.IRI
+	// Item is the loaded, or saved, item. It is nil for the operations which failed, or don't have one.
+	Item pub.Item
+	// Duration is the time the operation took in the underlying store.
+	Duration time.Duration
+	// Err is the error returned by the operation.
+	Err error
+}
+
+// ObserveFn is the type of the callbacks receiving the Observation of each operation of an InstrumentedStore,
+// which allows exporting them to a metrics system, like Prometheus, without the storage depending on it.
+type ObserveFn func(Observation)
+
+// StatsStore is a store that can report backend specific statistics, like the number of stored items,
+// the page usage of a database file, or the state of a connection pool.
+type StatsStore interface {
+	Stats() (map[string]int64, error)
+}
+
+// InstrumentedStore is a Store that reports the operations run on the underlying store to an ObserveFn.
+type InstrumentedStore struct {
+	Store
+	observe ObserveFn
+}
+
+// Instrument returns an InstrumentedStore reporting the operations run on "s" to "fn".
+func Instrument(s Store, fn ObserveFn) *InstrumentedStore {
+	return &InstrumentedStore{Store: s, observe: fn}
+}
+
+func (i *InstrumentedStore) run(op string, iri pub.IRI, fn func() (pub.Item, error)) (pub.Item, error) {
+	start := time.Now()
+	it, err := fn()
+	o := Observation{Op: op, IRI: iri, Duration: time.Since(start), Err: err}
+	if err == nil {
+		o.Item = it
+	}
+	i.observe(o)
+	return it, err
+}
+
+// Load loads "iri" from the underlying store.
+func (i *InstrumentedStore) Load(iri pub.IRI) (pub.Item, error) {
+	return i.run("load", iri, func() (pub.Item, error) {
+		return i.Store.Load(iri)
+	})
+}
+
+// Save saves "it" to the underlying store.
+func (i *InstrumentedStore) Save(it pub.Item) (pub.Item, error) {
+	return i.run("save", linkOf(it), func() (pub.Item, error) {
+		saved, err := i.Store.Save(it)
+		if err == nil && pub.IsNil(saved) {
+			saved = it
+		}
+		return saved, err
+	})
+}
+
+// Delete deletes "it" from the underlying store.
+func (i *InstrumentedStore) Delete(it pub.Item) error {
+	_, err := i.run("delete", linkOf(it), func() (pub.Item, error) {
+		return nil, i.Store.Delete(it)
+	})
+	return err
+}
+
+// Create creates the "col" collection in the underlying store, which needs to be a CollectionStore.
+func (i *InstrumentedStore) Create(col pub.CollectionInterface) (pub.CollectionInterface, error) {
+	var created pub.CollectionInterface
+	_, err := i.run("create", linkOf(col), func() (pub.Item, error) {
+		var err error
+		created, err = asCollectionStore(i.Store).Create(col)
+		return nil, err
+	})
+	return created, err
+}
+
+// AddTo adds "it" to the "col" collection in the underlying store, which needs to be a CollectionStore.
+func (i *InstrumentedStore) AddTo(col pub.IRI, it pub.Item) error {
+	_, err := i.run("add", col, func() (pub.Item, error) {
+		return nil, asCollectionStore(i.Store).AddTo(col, it)
+	})
+	return err
+}
+
+// RemoveFrom removes "it" from the "col" collection in the underlying store, which needs to be a CollectionStore.
+func (i *InstrumentedStore) RemoveFrom(col pub.IRI, it pub.Item) error {
+	_, err := i.run("remove", col, func() (pub.Item, error) {
+		return nil, asCollectionStore(i.Store).RemoveFrom(col, it)
+	})
+	return err
+}
+
+// Stats returns the statistics of the underlying store, if it's a StatsStore.
+func (i *InstrumentedStore) Stats() (map[string]int64, error) {
+	if s, ok := i.Store.(StatsStore); ok {
+		return s.Stats()
+	}
+	return nil, nil
+}
+
+// Close closes the underlying store, see Close.
+func (i *InstrumentedStore) Close() error {
+	return Close(i.Store)
+}
+
+// OpMetrics are the metrics aggregated by Metrics for one operation.
+type OpMetrics struct {
+	// Count is the number of times the operation ran.
+	Count uint64 `json:"count"`
+	// Errors is the number of times the operation failed, for reasons other than a missing item.
+	Errors uint64 `json:"errors"`
+	// NotFound is the number of times the operation failed because the item didn't exist.
+	NotFound uint64 `json:"notFound"`
+	// Duration is the total time the operation took.
+	Duration time.Duration `json:"duration"`
+	// MaxDuration is the longest time the operation took.
+	MaxDuration time.Duration `json:"maxDuration"`
+	// Bytes is the total size of the JSON-LD documents of the loaded, or saved, items, if Metrics.Sizes is set.
+	Bytes uint64 `json:"bytes"`
+}
+
+// Metrics aggregates in memory the Observations of an InstrumentedStore, and can publish them with expvar,
+// for the applications which don't use another metrics system. Its zero value is ready to use.
+type Metrics struct {
+	// Sizes enables measuring the size of the loaded and saved items, which requires encoding them again.
+	Sizes bool
+
+	mu  sync.Mutex
+	ops map[string]OpMetrics
+}
+
+// Observe adds "o" to the metrics of its operation, it can be used as the ObserveFn of an InstrumentedStore.
+func (m *Metrics) Observe(o Observation) {
+	var size int
+	if m.Sizes && !pub.IsNil(o.Item) {
+		if raw, err := pub.MarshalJSON(o.Item); err == nil {
+			size = len(raw)
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.ops == nil {
+		m.ops = make(map[string]OpMetrics)
+	}
+	op := m.ops[o.Op]
+	op.Count++
+	switch {
+	case errors.Is(o.Err, ErrNotFound):
+		op.NotFound++
+	case o.Err != nil:
+		op.Errors++
+	}
+	op.Duration += o.Duration
+	if o.Duration > op.MaxDuration {
+		op.MaxDuration = o.Duration
+	}
+	op.Bytes += uint64(size)
+	m.ops[o.Op] = op
+}
+
+// Snapshot returns a copy of the metrics of each operation.
+func (m *Metrics) Snapshot() map[string]OpMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ops := make(map[string]OpMetrics, len(m.ops))
+	for name, op := range m.ops {
+		ops[name] = op
+	}
+	return ops
+}
+
+// Publish publishes the metrics with expvar under "name", together with the statistics of "s",
+// if it's a StatsStore. Like expvar.Publish, it panics if "name" is already in use.
+func (m *Metrics) Publish(name string, s ReadStore) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		v := map[string]interface{}{"operations": m.Snapshot()}
+		if ss, ok := s.(StatsStore); ok {
+			if stats, err := ss.Stats(); err == nil {
+				v["backend"] = stats
+			}
+		}
+		return v
+	}))
+}
@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+func TestInstrumentedStore(t *testing.T) {
+	m := &Metrics{Sizes: true}
+	observed := make([]Observation, 0)
+	s := Instrument(newMapStore(), func(o Observation) {
+		observed = append(observed, o)
+		m.Observe(o)
+	})
+
+	ob := note("https://example.com/objects/1", "hello")
+	s.Save(ob)
+	s.Load(ob.ID)
+	s.Load("https://example.com/objects/2")
+	if _, err := s.Create(pub.OrderedCollectionNew("https://example.com/inbox")); err == nil {
+		t.Errorf("Create should fail for a store which isn't a CollectionStore")
+	}
+
+	if len(observed) != 4 || observed[0].Op != "save" || observed[0].IRI != ob.ID || observed[1].Item == nil {
+		t.Errorf("unexpected observations %+v", observed)
+	}
+	if !errors.Is(observed[2].Err, ErrNotFound) || observed[2].Item != nil {
+		t.Errorf("the failed load should be observed with its error, got %+v", observed[2])
+	}
+	ops := m.Snapshot()
+	if load := ops["load"]; load.Count != 2 || load.NotFound != 1 || load.Errors != 0 || load.Bytes == 0 {
+		t.Errorf("unexpected load metrics %+v", load)
+	}
+	if create := ops["create"]; create.Count != 1 || create.Errors != 1 {
+		t.Errorf("unexpected create metrics %+v", create)
+	}
+	if save := ops["save"]; save.Bytes != ops["load"].Bytes {
+		t.Errorf("the saved and loaded sizes should match, got %d and %d", save.Bytes, ops["load"].Bytes)
+	}
+}
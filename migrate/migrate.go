@@ -0,0 +1,174 @@
+// Package migrate copies the contents of a storage backend to another one.
+package migrate
+
+import (
+	"errors"
+	"fmt"
+
+	pub "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage"
+)
+
+// Source is a storage from which the objects can be copied.
+type Source interface {
+	storage.ReadStore
+	storage.IterateStore
+}
+
+// Destination is a storage to which the objects and collections can be copied.
+type Destination interface {
+	storage.Store
+	storage.CollectionStore
+}
+
+// Progress reports the state of a copy.
+type Progress struct {
+	// Objects is the number of copied objects.
+	Objects uint
+	// Collections is the number of copied collections.
+	Collections uint
+	// Skipped is the number of objects which were present in the destination already.
+	Skipped uint
+	// Last is the IRI of the last copied, or skipped, object.
+	Last pub.IRI
+}
+
+// Options control a copy.
+type Options struct {
+	// Filter selects the objects to copy, all of them if it's nil.
+	Filter storage.Filterable
+	// Resume skips the objects, and their collections, which are present in the destination already,
+	// so an interrupted copy can be continued.
+	Resume bool
+	// OnProgress, if set, gets called after each object.
+	OnProgress func(Progress)
+}
+
+// Copy copies the objects of "src" matching o.Filter to "dst", together with the collections of actors
+// (inbox, outbox, followers, following, liked) and of objects (replies, likes, shares) that "src" has.
+// The members of the collections are copied as IRIs, the objects they reference are copied on their own.
+// It stops at the first error, and returns the progress until that moment.
+func Copy(src Source, dst Destination, o Options) (Progress, error) {
+	progress := Progress{}
+	f := o.Filter
+	if f == nil {
+		f = pub.IRI("")
+	}
+	copied := make(map[pub.IRI]struct{})
+	copyCol := func(iri pub.IRI) error {
+		if _, ok := copied[iri]; ok {
+			return nil
+		}
+		ok, err := copyCollection(src, dst, iri)
+		if err != nil {
+			return err
+		}
+		if ok {
+			copied[iri] = struct{}{}
+			progress.Collections++
+		}
+		return nil
+	}
+	err := src.Each(f, func(it pub.Item) error {
+		iri := it.GetLink()
+		progress.Last = iri
+		if pub.CollectionTypes.Contains(it.GetType()) {
+			if err := copyCol(iri); err != nil {
+				return fmt.Errorf("unable to copy the %s collection: %w", iri, err)
+			}
+			report(o, progress)
+			return nil
+		}
+		if o.Resume {
+			exists, err := storage.Exists(dst, iri)
+			if err != nil {
+				return err
+			}
+			if exists {
+				progress.Skipped++
+				report(o, progress)
+				return nil
+			}
+		}
+		// the collections are copied first, so the object is present in the destination only
+		// after it has been fully copied, and resuming doesn't skip incomplete objects
+		for _, col := range storage.CollectionsOf(it) {
+			if err := copyCol(col); err != nil {
+				return fmt.Errorf("unable to copy the %s collection of %s: %w", col, iri, err)
+			}
+		}
+		if _, err := dst.Save(it); err != nil {
+			return fmt.Errorf("unable to copy %s: %w", iri, err)
+		}
+		progress.Objects++
+		report(o, progress)
+		return nil
+	})
+	return progress, err
+}
+
+func report(o Options, p Progress) {
+	if o.OnProgress != nil {
+		o.OnProgress(p)
+	}
+}
+
+// copyCollection copies the "iri" collection with its members, as IRIs, from "src" to "dst".
+// It returns false if "src" doesn't have the collection.
+func copyCollection(src Source, dst Destination, iri pub.IRI) (bool, error) {
+	it, err := src.Load(iri)
+	if errors.Is(err, storage.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	col, ok := it.(pub.CollectionInterface)
+	if !ok || !pub.CollectionTypes.Contains(it.GetType()) {
+		return false, nil
+	}
+	members := col.Collection()
+	empty, err := emptyCollection(col)
+	if err != nil {
+		return false, err
+	}
+	if _, err := dst.Create(empty); err != nil {
+		return false, err
+	}
+	for _, member := range members {
+		if pub.IsNil(member) {
+			continue
+		}
+		if err := dst.AddTo(iri, member.GetLink()); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// emptyCollection returns a collection with the same properties as "col", without members.
+func emptyCollection(col pub.CollectionInterface) (pub.CollectionInterface, error) {
+	raw, err := pub.MarshalJSON(col)
+	if err != nil {
+		return nil, err
+	}
+	it, err := pub.UnmarshalJSON(raw)
+	if err != nil {
+		return nil, err
+	}
+	empty, ok := it.(pub.CollectionInterface)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is not a collection", storage.ErrNotValid, col.GetLink())
+	}
+	switch c := empty.(type) {
+	case *pub.OrderedCollection:
+		c.OrderedItems, c.TotalItems = nil, 0
+	case *pub.Collection:
+		c.Items, c.TotalItems = nil, 0
+	case *pub.OrderedCollectionPage:
+		c.OrderedItems, c.TotalItems = nil, 0
+	case *pub.CollectionPage:
+		c.Items, c.TotalItems = nil, 0
+	}
+	return empty, nil
+}
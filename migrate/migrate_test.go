@@ -0,0 +1,119 @@
+package migrate
+
+import (
+	"errors"
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage/memory"
+)
+
+func note(iri pub.IRI, content string) *pub.Object {
+	ob := pub.ObjectNew(pub.NoteType)
+	ob.ID = iri
+	ob.Content.Set(pub.NilLangRef, pub.Content(content))
+	return ob
+}
+
+// failingDestination fails the Save calls after the first "saves" ones.
+type failingDestination struct {
+	Destination
+	saves int
+}
+
+var errFail = errors.New("fail")
+
+func (f *failingDestination) Save(it pub.Item) (pub.Item, error) {
+	if f.saves == 0 {
+		return nil, errFail
+	}
+	f.saves--
+	return f.Destination.Save(it)
+}
+
+func source(t *testing.T) Source {
+	src := memory.New()
+	actor := &pub.Actor{
+		ID:     "https://example.com/actors/jdoe",
+		Type:   pub.PersonType,
+		Outbox: pub.IRI("https://example.com/actors/jdoe/outbox"),
+		Inbox:  pub.IRI("https://example.com/actors/jdoe/inbox"),
+	}
+	ob := note("https://example.com/objects/1", "hello")
+	if _, err := src.Save(actor); err != nil {
+		t.Fatalf("Save returned error: %s", err)
+	}
+	if _, err := src.Save(ob); err != nil {
+		t.Fatalf("Save returned error: %s", err)
+	}
+	if _, err := src.Create(pub.OrderedCollectionNew(actor.Outbox.GetLink())); err != nil {
+		t.Fatalf("Create returned error: %s", err)
+	}
+	if err := src.AddTo(actor.Outbox.GetLink(), ob); err != nil {
+		t.Fatalf("AddTo returned error: %s", err)
+	}
+	return src
+}
+
+func TestCopy(t *testing.T) {
+	src := source(t)
+	dst := memory.New()
+
+	reports := 0
+	p, err := Copy(src, dst, Options{OnProgress: func(Progress) { reports++ }})
+	if err != nil {
+		t.Fatalf("Copy returned error: %s", err)
+	}
+	if p.Objects != 2 || p.Collections != 1 || p.Skipped != 0 {
+		t.Errorf("Copy returned %+v, expected 2 objects and 1 collection", p)
+	}
+	if reports == 0 {
+		t.Errorf("the progress has not been reported")
+	}
+	for _, iri := range (pub.IRIs{"https://example.com/actors/jdoe", "https://example.com/objects/1"}) {
+		it, err := dst.Load(iri)
+		if err != nil {
+			t.Fatalf("Load(%s) returned error: %s", iri, err)
+		}
+		if !it.GetLink().Equals(iri, false) {
+			t.Errorf("Load(%s) returned %s", iri, it.GetLink())
+		}
+	}
+	it, err := dst.Load("https://example.com/actors/jdoe/outbox")
+	if err != nil {
+		t.Fatalf("Load returned error: %s", err)
+	}
+	col, ok := it.(pub.CollectionInterface)
+	if !ok || !col.Contains(pub.IRI("https://example.com/objects/1")) {
+		t.Errorf("the outbox has not been copied with its members: %#v", it)
+	}
+	if _, err := dst.Load("https://example.com/actors/jdoe/inbox"); err == nil {
+		t.Errorf("the inbox missing from the source should not be created")
+	}
+}
+
+func TestCopy_Resume(t *testing.T) {
+	src := source(t)
+	dst := memory.New()
+
+	p, err := Copy(src, &failingDestination{Destination: dst, saves: 1}, Options{})
+	if !errors.Is(err, errFail) {
+		t.Fatalf("Copy returned %v, expected the destination's error", err)
+	}
+	if p.Objects != 1 {
+		t.Fatalf("Copy returned %+v, expected 1 object before failing", p)
+	}
+
+	p, err = Copy(src, dst, Options{Resume: true})
+	if err != nil {
+		t.Fatalf("Copy returned error: %s", err)
+	}
+	if p.Objects != 1 || p.Skipped != 1 {
+		t.Errorf("Copy returned %+v, expected 1 copied and 1 skipped object", p)
+	}
+	for _, iri := range (pub.IRIs{"https://example.com/actors/jdoe", "https://example.com/objects/1"}) {
+		if _, err := dst.Load(iri); err != nil {
+			t.Errorf("Load(%s) returned error: %s", iri, err)
+		}
+	}
+}
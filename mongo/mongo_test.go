@@ -0,0 +1,80 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage"
+	"github.com/go-ap/storage/storagetest"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// testURI returns the connection string of the test server, from the MONGO_URI environment
+// variable, skipping the test if it's not set.
+func testURI(t *testing.T) string {
+	uri := os.Getenv("MONGO_URI")
+	if len(uri) == 0 {
+		t.Skip("MONGO_URI is not set")
+	}
+	return uri
+}
+
+// newTestRepo returns a storage on the emptied "fedbox_test" database of the "uri" server.
+func newTestRepo(t *testing.T, uri string) *repo {
+	r, err := New(Config{URI: uri, Database: "fedbox_test"})
+	if err != nil {
+		t.Fatalf("New returned error: %s", err)
+	}
+	ctx := context.Background()
+	for _, c := range []string{"items", "collections", "members"} {
+		if _, err := r.items.Database().Collection(c).DeleteMany(ctx, bson.D{}); err != nil {
+			t.Fatalf("unable to empty the %s collection: %s", c, err)
+		}
+	}
+	t.Cleanup(func() { r.Close() })
+	return r
+}
+
+func TestConformance(t *testing.T) {
+	uri := testURI(t)
+	storagetest.RunStoreTests(t, func() storage.Store { return newTestRepo(t, uri) })
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(Config{URI: "mongodb://localhost"}); !errors.Is(err, storage.ErrNotValid) {
+		t.Errorf("New without a database returned %v, expected %s", err, storage.ErrNotValid)
+	}
+	c := Config{URI: "mongodb://127.0.0.1:1", Database: "fedbox", Timeout: 100 * time.Millisecond}
+	if _, err := New(c); err == nil {
+		t.Errorf("New of an unreachable server should fail")
+	}
+}
+
+func TestEncode(t *testing.T) {
+	published := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	ob := &pub.Object{
+		ID:           "https://example.com/objects/1",
+		Type:         pub.NoteType,
+		AttributedTo: pub.IRI("https://example.com/actors/jdoe"),
+		Published:    published,
+	}
+	ob.Content.Set(pub.NilLangRef, pub.Content("hello"))
+	doc, err := encode(ob)
+	if err != nil {
+		t.Fatalf("encode returned error: %s", err)
+	}
+	if doc.ID != ob.ID.String() || doc.Type != string(pub.NoteType) || doc.AttributedTo != "https://example.com/actors/jdoe" || !doc.Published.Equal(published) {
+		t.Errorf("encode returned %+v, expected the properties of the object to be extracted", doc)
+	}
+	it, err := decode(ob.ID, doc.Object)
+	if err != nil {
+		t.Fatalf("decode returned error: %s", err)
+	}
+	if !ob.Equals(it) {
+		t.Errorf("decode returned %#v, expected %#v", it, ob)
+	}
+}
@@ -0,0 +1,151 @@
+// Package mysql implements a storage backend on top of MySQL, or MariaDB, which is the database many
+// hosting providers offer, using the go-sql-driver driver.
+//
+// The objects are stored in JSON columns, with the id, type, attributedTo and published properties
+// extracted in generated columns which are indexed. The IRIs are stored as binary strings, so they're
+// compared like in the other backends, and fit in the size limits of the indexes.
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/go-ap/storage"
+	"github.com/go-ap/storage/internal/sqlstore"
+	"github.com/go-sql-driver/mysql"
+)
+
+// Config holds the options for the MySQL storage.
+type Config struct {
+	// DSN is the connection string of the database, eg: "jdoe:secret@tcp(localhost:3306)/fedbox".
+	DSN string
+	// CreateIfMissing creates the tables of the storage if they don't exist, otherwise New fails for a
+	// database without them, so a wrong DSN isn't mistaken for an empty storage. See Bootstrap.
+	CreateIfMissing bool
+	// Timeout is how long New waits for the connection to the database, before failing. It waits for
+	// as long as the network allows if it's not set, or for the timeout of the DSN.
+	Timeout time.Duration
+	// ReadOnly makes all the write operations return storage.ErrReadOnly, eg: for maintenance tools,
+	// or replicas serving GET requests.
+	ReadOnly bool
+	// MaxOpenConns is the maximum number of connections to the database, unlimited if not set.
+	MaxOpenConns int
+	// MaxIdleConns is the maximum number of idle connections kept open, 2 if not set, or none if negative.
+	MaxIdleConns int
+	// ConnMaxLifetime is how long the connections are reused before being closed, eg: to balance them
+	// between the servers behind a proxy. They're reused forever if it's not set.
+	ConnMaxLifetime time.Duration
+	// QueryTimeout is how long each operation waits for the database, before failing with
+	// context.DeadlineExceeded. It waits for as long as the database needs if it's not set.
+	QueryTimeout time.Duration
+}
+
+type repo struct {
+	*sqlstore.Store
+}
+
+var (
+	_ storage.Store           = &repo{}
+	_ storage.CollectionStore = &repo{}
+	_ storage.ChangeFeed      = &repo{}
+	_ storage.SchemaStore     = &repo{}
+	_ storage.BackupStore     = &repo{}
+	_ storage.StatsStore      = &repo{}
+	_ io.Closer               = &repo{}
+)
+
+// MySQL doesn't support "CREATE INDEX IF NOT EXISTS", so the indexes are created with their tables,
+// and MariaDB doesn't allow generated columns in the primary keys.
+var dialect = sqlstore.Dialect{
+	Schema: []string{
+		`CREATE TABLE IF NOT EXISTS items (
+			raw JSON NOT NULL,
+			id VARBINARY(1024) GENERATED ALWAYS AS (JSON_UNQUOTE(JSON_EXTRACT(raw, '$.id'))) STORED NOT NULL,
+			type VARCHAR(64) GENERATED ALWAYS AS (JSON_UNQUOTE(JSON_EXTRACT(raw, '$.type'))) STORED,
+			attributed_to VARBINARY(1024) GENERATED ALWAYS AS (COALESCE(JSON_UNQUOTE(JSON_EXTRACT(raw, '$.attributedTo.id')), JSON_UNQUOTE(JSON_EXTRACT(raw, '$.attributedTo')))) STORED,
+			published VARCHAR(64) GENERATED ALWAYS AS (JSON_UNQUOTE(JSON_EXTRACT(raw, '$.published'))) STORED,
+			UNIQUE KEY items_id (id),
+			KEY items_type (type),
+			KEY items_attributed_to (attributed_to),
+			KEY items_published (published)
+		)`,
+		`CREATE TABLE IF NOT EXISTS collections (
+			iri VARBINARY(1024) PRIMARY KEY,
+			raw JSON NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS members (
+			seq BIGINT AUTO_INCREMENT PRIMARY KEY,
+			collection VARBINARY(1024) NOT NULL,
+			member VARBINARY(1024) NOT NULL,
+			UNIQUE KEY members_member (collection, member),
+			KEY members_collection (collection, seq),
+			FOREIGN KEY (collection) REFERENCES collections (iri) ON DELETE CASCADE
+		)`,
+	},
+	Upgrades: [][]string{
+		// the tables created by Schema are the first version
+		nil,
+	},
+	UpsertItem:       "INSERT INTO items (raw) VALUES (?) ON DUPLICATE KEY UPDATE raw = VALUES(raw)",
+	UpsertCollection: "INSERT INTO collections (iri, raw) VALUES (?, ?) ON DUPLICATE KEY UPDATE raw = VALUES(raw)",
+	// the rows which are already stored are "updated" without changes, which doesn't count as affecting them,
+	// unlike INSERT IGNORE, this doesn't turn the other errors into warnings
+	InsertCollection: "INSERT INTO collections (iri, raw) VALUES (?, ?) ON DUPLICATE KEY UPDATE iri = iri",
+	InsertMember:     "INSERT INTO members (collection, member) VALUES (?, ?) ON DUPLICATE KEY UPDATE seq = seq",
+}
+
+// New connects to the c.DSN database, and creates the tables of the storage if they don't exist
+// and c.CreateIfMissing is set.
+func New(c Config) (*repo, error) {
+	if len(c.DSN) == 0 {
+		return nil, fmt.Errorf("%w: the DSN of the storage is empty", storage.ErrNotValid)
+	}
+	if _, err := mysql.ParseDSN(c.DSN); err != nil {
+		return nil, fmt.Errorf("%w DSN of the storage: %s", storage.ErrNotValid, err)
+	}
+	db, err := sql.Open("mysql", c.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open the mysql storage: %w", err)
+	}
+	ctx := context.Background()
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("unable to connect to the mysql storage: %w", err)
+	}
+	s, err := sqlstore.New(db, dialect, c.options())
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &repo{Store: s}, nil
+}
+
+func (c Config) options() sqlstore.Options {
+	return sqlstore.Options{
+		CreateIfMissing: c.CreateIfMissing,
+		ReadOnly:        c.ReadOnly,
+		MaxOpenConns:    c.MaxOpenConns,
+		MaxIdleConns:    c.MaxIdleConns,
+		ConnMaxLifetime: c.ConnMaxLifetime,
+		QueryTimeout:    c.QueryTimeout,
+	}
+}
+
+// Bootstrap creates the tables of the storage in the c.DSN database, if they don't exist, and records
+// the version of their schema, so the first run of a service doesn't need any manual setup.
+func Bootstrap(c Config) error {
+	c.CreateIfMissing = true
+	r, err := New(c)
+	if err != nil {
+		return err
+	}
+	return r.Close()
+}
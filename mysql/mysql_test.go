@@ -0,0 +1,86 @@
+package mysql
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage"
+	"github.com/go-ap/storage/storagetest"
+)
+
+// testDSN returns the connection string of the test database, from the MYSQL_DSN environment
+// variable, skipping the test if it's not set.
+func testDSN(t *testing.T) string {
+	dsn := os.Getenv("MYSQL_DSN")
+	if len(dsn) == 0 {
+		t.Skip("MYSQL_DSN is not set")
+	}
+	return dsn
+}
+
+// newTestRepo returns a storage on the emptied "dsn" database.
+func newTestRepo(t *testing.T, dsn string) *repo {
+	r, err := New(Config{DSN: dsn, CreateIfMissing: true})
+	if err != nil {
+		t.Fatalf("New returned error: %s", err)
+	}
+	for _, table := range []string{"members", "collections", "items"} {
+		if _, err := r.DB().Exec("DELETE FROM " + table); err != nil {
+			t.Fatalf("unable to empty the %s table: %s", table, err)
+		}
+	}
+	t.Cleanup(func() { r.Close() })
+	return r
+}
+
+func TestConformance(t *testing.T) {
+	dsn := testDSN(t)
+	storagetest.RunStoreTests(t, func() storage.Store { return newTestRepo(t, dsn) })
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(Config{}); !errors.Is(err, storage.ErrNotValid) {
+		t.Errorf("New without a DSN returned %v, expected %s", err, storage.ErrNotValid)
+	}
+	if _, err := New(Config{DSN: "jdoe@localhost/fedbox"}); !errors.Is(err, storage.ErrNotValid) {
+		t.Errorf("New with an invalid DSN returned %v, expected %s", err, storage.ErrNotValid)
+	}
+	start := time.Now()
+	if _, err := New(Config{DSN: "jdoe@tcp(192.0.2.1:3306)/fedbox", Timeout: 100 * time.Millisecond}); err == nil {
+		t.Errorf("New of an unreachable database should fail")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("New failed after %s, expected it to stop waiting after the timeout", elapsed)
+	}
+}
+
+func TestBackupRestore(t *testing.T) {
+	dsn := testDSN(t)
+	r := newTestRepo(t, dsn)
+	outbox := pub.IRI("https://example.com/actors/jdoe/outbox")
+	ob := &pub.Object{ID: "https://example.com/objects/1", Type: pub.NoteType}
+	r.Save(ob)
+	r.Create(pub.OrderedCollectionNew(outbox))
+	r.AddTo(outbox, ob)
+
+	buf := bytes.Buffer{}
+	if err := storage.Backup(r, &buf); err != nil {
+		t.Fatalf("Backup returned error: %s", err)
+	}
+	restored := newTestRepo(t, dsn)
+	if err := storage.Restore(restored, bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("Restore returned error: %s", err)
+	}
+	it, err := restored.Load(outbox)
+	if err != nil {
+		t.Fatalf("Load returned error: %s", err)
+	}
+	col, ok := it.(*pub.OrderedCollection)
+	if !ok || len(col.OrderedItems) != 1 || col.OrderedItems[0].GetType() != pub.NoteType {
+		t.Errorf("Load after Restore returned %#v, expected the collection with the restored object", it)
+	}
+}
@@ -0,0 +1,282 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// NotificationsNamespace is the MetadataStore namespace under which Notifications stores the notifications
+// of each recipient.
+const NotificationsNamespace = "notifications"
+
+// NotificationKind is the reason of a notification.
+type NotificationKind string
+
+const (
+	// NotifyMention is the kind of the notifications of objects mentioning the recipient.
+	NotifyMention NotificationKind = "mention"
+	// NotifyLike is the kind of the notifications of Likes of the objects of the recipient.
+	NotifyLike NotificationKind = "like"
+	// NotifyFollow is the kind of the notifications of Follows of the recipient.
+	NotifyFollow NotificationKind = "follow"
+	// NotifyBoost is the kind of the notifications of Announces of the objects of the recipient.
+	NotifyBoost NotificationKind = "boost"
+)
+
+// Notification is an activity, of interest to its recipient, which it hasn't necessarily seen yet.
+type Notification struct {
+	// ID identifies the notification among the ones of its recipient.
+	ID        string           `json:"id"`
+	Kind      NotificationKind `json:"kind"`
+	Recipient pub.IRI          `json:"recipient"`
+	// Actor is the IRI of the actor of the Activity.
+	Actor    pub.IRI `json:"actor"`
+	Activity pub.IRI `json:"activity"`
+	// Object is the IRI of the object of the Activity, eg: the liked note.
+	Object  pub.IRI   `json:"object,omitempty"`
+	Created time.Time `json:"created"`
+	Read    bool      `json:"read"`
+}
+
+// NotificationStore persists the notifications of the actors, with their read state.
+type NotificationStore interface {
+	// Notify records the "n" notification as unread. Recording a notification of the same kind,
+	// for the same recipient and activity, returns the existing one.
+	Notify(n Notification) (Notification, error)
+	// LoadNotifications returns the notifications of the "recipient", newest first, or only
+	// the unread ones when "unread" is true.
+	LoadNotifications(recipient pub.IRI, unread bool) ([]Notification, error)
+	// MarkRead marks the "ids" notifications of the "recipient" as read.
+	MarkRead(recipient pub.IRI, ids ...string) error
+	// MarkSeen marks the notifications of the "recipient" created until the "seen" moment as read,
+	// and records it as the last seen marker.
+	MarkSeen(recipient pub.IRI, seen time.Time) error
+	// LastSeen returns the last seen marker of the "recipient", which is zero if it's never been set.
+	LastSeen(recipient pub.IRI) (time.Time, error)
+}
+
+// Notifications returns a NotificationStore that keeps the notifications of each recipient, as a JSON
+// document, in the NotificationsNamespace metadata of the "m" store.
+func Notifications(m MetadataStore) NotificationStore {
+	return &metadataNotifications{m: m}
+}
+
+type notificationsDoc struct {
+	LastSeen time.Time `json:"lastSeen,omitempty"`
+	// Sequence is the numeric ID of the last recorded notification.
+	Sequence      uint64         `json:"sequence"`
+	Notifications []Notification `json:"notifications"`
+}
+
+type metadataNotifications struct {
+	m  MetadataStore
+	mu sync.Mutex
+}
+
+func (n *metadataNotifications) load(recipient pub.IRI) (notificationsDoc, error) {
+	doc := notificationsDoc{Notifications: make([]Notification, 0)}
+	data, err := n.m.LoadMetadata(recipient, NotificationsNamespace)
+	if errors.Is(err, ErrNotFound) || (err == nil && len(data) == 0) {
+		return doc, nil
+	}
+	if err != nil {
+		return doc, err
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return doc, fmt.Errorf("invalid notifications for %s: %w", recipient, err)
+	}
+	return doc, nil
+}
+
+func (n *metadataNotifications) save(recipient pub.IRI, doc notificationsDoc) error {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return n.m.SaveMetadata(recipient, NotificationsNamespace, data)
+}
+
+func (n *metadataNotifications) update(recipient pub.IRI, fn func(*notificationsDoc)) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	doc, err := n.load(recipient)
+	if err != nil {
+		return err
+	}
+	fn(&doc)
+	return n.save(recipient, doc)
+}
+
+func (n *metadataNotifications) Notify(not Notification) (Notification, error) {
+	if len(not.Recipient) == 0 || len(not.Activity) == 0 || len(not.Kind) == 0 {
+		return not, fmt.Errorf("%w notification: missing recipient, activity, or kind", ErrNotValid)
+	}
+	err := n.update(not.Recipient, func(doc *notificationsDoc) {
+		for _, existing := range doc.Notifications {
+			if existing.Kind == not.Kind && existing.Activity == not.Activity {
+				not = existing
+				return
+			}
+		}
+		doc.Sequence++
+		not.ID = strconv.FormatUint(doc.Sequence, 10)
+		if not.Created.IsZero() {
+			not.Created = time.Now().UTC()
+		}
+		not.Read = !doc.LastSeen.IsZero() && !not.Created.After(doc.LastSeen)
+		doc.Notifications = append(doc.Notifications, not)
+	})
+	return not, err
+}
+
+func (n *metadataNotifications) LoadNotifications(recipient pub.IRI, unread bool) ([]Notification, error) {
+	doc, err := n.load(recipient)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Notification, 0, len(doc.Notifications))
+	for i := len(doc.Notifications) - 1; i >= 0; i-- {
+		if not := doc.Notifications[i]; !unread || !not.Read {
+			result = append(result, not)
+		}
+	}
+	return result, nil
+}
+
+func (n *metadataNotifications) MarkRead(recipient pub.IRI, ids ...string) error {
+	read := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		read[id] = struct{}{}
+	}
+	return n.update(recipient, func(doc *notificationsDoc) {
+		for i := range doc.Notifications {
+			if _, ok := read[doc.Notifications[i].ID]; ok {
+				doc.Notifications[i].Read = true
+			}
+		}
+	})
+}
+
+func (n *metadataNotifications) MarkSeen(recipient pub.IRI, seen time.Time) error {
+	return n.update(recipient, func(doc *notificationsDoc) {
+		if seen.After(doc.LastSeen) {
+			doc.LastSeen = seen.UTC()
+		}
+		for i := range doc.Notifications {
+			if !doc.Notifications[i].Created.After(seen) {
+				doc.Notifications[i].Read = true
+			}
+		}
+	})
+}
+
+func (n *metadataNotifications) LastSeen(recipient pub.IRI) (time.Time, error) {
+	doc, err := n.load(recipient)
+	return doc.LastSeen, err
+}
+
+// ActivityNotifications returns the notifications the "recipient" should get for the "it" activity:
+// for Follows of the recipient, for Likes and Announces of its objects, and for Creates of objects
+// mentioning it. The objects referenced only by their IRIs are loaded from "s".
+func ActivityNotifications(s ReadStore, recipient pub.IRI, it pub.Item) []Notification {
+	nots := make([]Notification, 0, 1)
+	if pub.IsNil(it) || !pub.ActivityTypes.Contains(it.GetType()) {
+		return nots
+	}
+	actor := OwnerOf(it)
+	if len(actor) == 0 || actor.Equals(recipient, false) {
+		return nots
+	}
+	pub.OnActivity(it, func(a *pub.Activity) error {
+		if pub.IsNil(a.Object) {
+			return nil
+		}
+		object := a.Object
+		if pub.IsIRI(object) && a.Type != pub.FollowType {
+			if ob, err := s.Load(object.GetLink()); err == nil && !pub.IsNil(ob) {
+				object = ob
+			}
+		}
+		kind := NotificationKind("")
+		switch a.Type {
+		case pub.FollowType:
+			if object.GetLink().Equals(recipient, false) {
+				kind = NotifyFollow
+			}
+		case pub.LikeType:
+			if OwnerOf(object).Equals(recipient, false) {
+				kind = NotifyLike
+			}
+		case pub.AnnounceType:
+			if OwnerOf(object).Equals(recipient, false) {
+				kind = NotifyBoost
+			}
+		case pub.CreateType:
+			if mentions(object, recipient) {
+				kind = NotifyMention
+			}
+		}
+		if len(kind) > 0 {
+			nots = append(nots, Notification{
+				Kind:      kind,
+				Recipient: recipient,
+				Actor:     actor,
+				Activity:  a.GetLink(),
+				Object:    object.GetLink(),
+			})
+		}
+		return nil
+	})
+	return nots
+}
+
+// mentions reports if the tags of "it" have a Mention of "actor".
+func mentions(it pub.Item, actor pub.IRI) bool {
+	found := false
+	pub.OnObject(it, func(ob *pub.Object) error {
+		for _, tag := range ob.Tag {
+			if pub.IsNil(tag) || tag.GetType() != pub.MentionType {
+				continue
+			}
+			pub.OnLink(tag, func(l *pub.Link) error {
+				found = found || l.Href.Equals(actor, false)
+				return nil
+			})
+		}
+		return nil
+	})
+	return found
+}
+
+// NotifyOnDelivery registers an OnAddTo hook on "h" that records, in "n", the ActivityNotifications of
+// the activities added to the inboxes, for the owners of the inboxes.
+func NotifyOnDelivery(h *HookStore, n NotificationStore) {
+	h.OnAddTo(func(col pub.IRI, it pub.Item) error {
+		inbox := strings.TrimSuffix(string(col), "/")
+		if !strings.HasSuffix(inbox, "/"+InboxCollection) || pub.IsNil(it) {
+			return nil
+		}
+		recipient := pub.IRI(strings.TrimSuffix(inbox, "/"+InboxCollection))
+		if pub.IsIRI(it) {
+			activity, err := h.Store.Load(it.GetLink())
+			if err != nil {
+				return nil
+			}
+			it = activity
+		}
+		for _, not := range ActivityNotifications(h.Store, recipient, it) {
+			if _, err := n.Notify(not); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+func TestNotifyOnDelivery(t *testing.T) {
+	h := Hooks(newCollectionMapStore())
+	ns := Notifications(metadataMap{})
+	NotifyOnDelivery(h, ns)
+
+	jdoe := pub.IRI("https://example.com/actors/jdoe")
+	alice := pub.IRI("https://example.org/actors/alice")
+	inbox := jdoe.AddPath(InboxCollection)
+	h.Create(pub.OrderedCollectionNew(inbox))
+
+	ob := note("https://example.com/objects/1", "hello")
+	ob.AttributedTo = jdoe
+	h.Save(ob)
+
+	reply := note("https://example.org/objects/2", "hi @jdoe")
+	reply.AttributedTo = alice
+	mention := pub.MentionNew("")
+	mention.Href = jdoe
+	reply.Tag = pub.ItemCollection{mention}
+	create := pub.CreateNew("https://example.org/activities/1", reply)
+	create.Actor = alice
+
+	like := pub.LikeNew("https://example.org/activities/2", ob.ID)
+	like.Actor = alice
+	follow := pub.FollowNew("https://example.org/activities/3", jdoe)
+	follow.Actor = alice
+	own := pub.LikeNew("https://example.com/activities/4", ob.ID)
+	own.Actor = jdoe
+	unrelated := pub.CreateNew("https://example.org/activities/5", note("https://example.org/objects/3", "hi"))
+	unrelated.Actor = alice
+
+	for _, it := range []pub.Item{create, like, follow, own, unrelated, like} {
+		h.Save(it)
+		if err := h.AddTo(inbox, it.GetLink()); err != nil {
+			t.Fatalf("AddTo returned error: %s", err)
+		}
+	}
+	nots, err := ns.LoadNotifications(jdoe, true)
+	if err != nil {
+		t.Fatalf("LoadNotifications returned error: %s", err)
+	}
+	want := []NotificationKind{NotifyFollow, NotifyLike, NotifyMention}
+	if len(nots) != len(want) {
+		t.Fatalf("LoadNotifications returned %v, expected %v", nots, want)
+	}
+	for i, not := range nots {
+		if not.Kind != want[i] || not.Actor != alice || not.Read {
+			t.Errorf("notification %d is %v, expected an unread %s by %s", i, not, want[i], alice)
+		}
+	}
+
+	if err := ns.MarkRead(jdoe, nots[0].ID); err != nil {
+		t.Fatalf("MarkRead returned error: %s", err)
+	}
+	if unread, _ := ns.LoadNotifications(jdoe, true); len(unread) != 2 {
+		t.Errorf("LoadNotifications returned %d unread notifications, expected 2", len(unread))
+	}
+	seen := time.Now().UTC()
+	if err := ns.MarkSeen(jdoe, seen); err != nil {
+		t.Fatalf("MarkSeen returned error: %s", err)
+	}
+	if unread, _ := ns.LoadNotifications(jdoe, true); len(unread) != 0 {
+		t.Errorf("LoadNotifications returned %v, expected no unread notifications", unread)
+	}
+	if all, _ := ns.LoadNotifications(jdoe, false); len(all) != 3 {
+		t.Errorf("LoadNotifications returned %d notifications, expected 3", len(all))
+	}
+	if last, err := ns.LastSeen(jdoe); err != nil || !last.Equal(seen) {
+		t.Errorf("LastSeen returned %s, %v, expected %s", last, err, seen)
+	}
+}
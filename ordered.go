@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"fmt"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// OrderedCollectionStore can read back the members of a collection in reverse chronological order,
+// which is the order in which they are presented in OrderedCollectionPages.
+type OrderedCollectionStore interface {
+	// LoadCollection returns the members of the "iri" collection matching "f", starting with the
+	// most recently added one.
+	// If "f" is a FilterablePage, only the requested page is returned, together with the cursor of
+	// the next one, which is empty when the returned page is the last one.
+	LoadCollection(iri pub.IRI, f Filterable) (pub.ItemCollection, string, error)
+}
+
+// LoadCollection returns the members of the "iri" collection in the "s" store, see OrderedCollectionStore.
+// For stores that are not OrderedCollectionStores it loads the whole collection, and assumes that
+// its members are in the order in which they have been added.
+func LoadCollection(s ReadStore, iri pub.IRI, f Filterable) (pub.ItemCollection, string, error) {
+	if cs, ok := s.(OrderedCollectionStore); ok {
+		return cs.LoadCollection(iri, f)
+	}
+	it, err := s.Load(iri)
+	if err != nil {
+		return nil, "", err
+	}
+	col, ok := it.(pub.CollectionInterface)
+	if !ok {
+		return nil, "", fmt.Errorf("%w: %s is not a collection", ErrNotValid, iri)
+	}
+	return OrderMembers(col.Collection(), f)
+}
+
+// MatchMember reports whether "f" keeps the "it" member of a collection, see OrderMembers.
+// Members that are only IRIs are kept only if "f" doesn't have conditions that need the items.
+func MatchMember(f Filterable, it pub.Item) bool {
+	if pub.IsNil(it) {
+		return false
+	}
+	if !pub.IsIRI(it) {
+		return MatchItem(f, it)
+	}
+	_, matcher := f.(Matcher)
+	_, language := f.(FilterableLanguage)
+	return !matcher && !language
+}
+
+// OrderMembers returns the "members" matching "f", in reverse order, or in the one requested by "f",
+// limited to its range if it's a FilterableRange, with the ones it pins first, and paginated if it's
+// a FilterablePage. Like in LoadCollectionPage, the pinned members outside of the range aren't returned. It is meant for backends that keep the members of the collections in the order
+// in which they have been added.
+// Members that are only IRIs are kept only if "f" doesn't have conditions that need the items.
+func OrderMembers(members pub.ItemCollection, f Filterable) (pub.ItemCollection, string, error) {
+	items := make(pub.ItemCollection, 0, len(members))
+	for i := len(members) - 1; i >= 0; i-- {
+		if it := members[i]; MatchMember(f, it) {
+			items = append(items, it)
+		}
+	}
+	SortMembers(items, SortOrderOf(f))
+	start, end, err := RangeBounds(len(items), func(i int) pub.IRI { return items[i].GetLink() }, f)
+	if err != nil {
+		return nil, "", err
+	}
+	items = PinMembers(items[start:end], f)
+	if p, ok := f.(FilterablePage); ok {
+		return Paginate(items, p)
+	}
+	return items, "", nil
+}
@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// filterPage is a Filter that also requests a Page.
+type filterPage struct {
+	Filter
+	Page
+}
+
+func (f filterPage) GetLink() pub.IRI {
+	return f.Filter.GetLink()
+}
+
+func TestMatchMember(t *testing.T) {
+	ob := note("https://example.com/objects/1", "hello")
+	iri := pub.IRI("https://example.com/objects/2")
+	if !MatchMember(pub.IRI(""), iri) || !MatchMember(pub.IRI(""), ob) {
+		t.Errorf("the members should match a filter without conditions")
+	}
+	if MatchMember(Filter{Text: []string{"hello"}}, iri) {
+		t.Errorf("the members that are only IRIs shouldn't match a filter with conditions on the items")
+	}
+	if !MatchMember(Filter{Text: []string{"hello"}}, ob) || MatchMember(Filter{Text: []string{"goodbye"}}, ob) {
+		t.Errorf("the items should match the filters they satisfy")
+	}
+	if MatchMember(pub.IRI(""), nil) {
+		t.Errorf("nil members shouldn't match")
+	}
+}
+
+func TestOrderMembers(t *testing.T) {
+	members := pub.ItemCollection{
+		note("https://example.com/objects/1", "hello"),
+		pub.IRI("https://example.com/objects/2"),
+		note("https://example.com/objects/3", "goodbye"),
+		note("https://example.com/objects/4", "hello again"),
+	}
+	tests := []struct {
+		name string
+		f    Filterable
+		want []pub.IRI
+		next bool
+	}{
+		{
+			name: "all",
+			f:    pub.IRI(""),
+			want: []pub.IRI{"https://example.com/objects/4", "https://example.com/objects/3", "https://example.com/objects/2", "https://example.com/objects/1"},
+		},
+		{
+			name: "filter",
+			f:    Filter{Text: []string{"hello"}},
+			want: []pub.IRI{"https://example.com/objects/4", "https://example.com/objects/1"},
+		},
+		{
+			name: "page",
+			f:    Page{Max: 2},
+			want: []pub.IRI{"https://example.com/objects/4", "https://example.com/objects/3"},
+			next: true,
+		},
+		{
+			name: "pins in range",
+			f:    Page{Pins: pub.IRIs{"https://example.com/objects/3"}, Max: 2},
+			want: []pub.IRI{"https://example.com/objects/3", "https://example.com/objects/4"},
+			next: true,
+		},
+		{
+			name: "pins with since",
+			f:    Page{Pins: pub.IRIs{"https://example.com/objects/1"}, Since: "https://example.com/objects/3"},
+			want: []pub.IRI{"https://example.com/objects/4"},
+		},
+		{
+			name: "pins with max",
+			f:    Page{Pins: pub.IRIs{"https://example.com/objects/4", "https://example.com/objects/1"}, Until: "https://example.com/objects/3"},
+			want: []pub.IRI{"https://example.com/objects/1", "https://example.com/objects/2"},
+		},
+		{
+			name: "filtered page",
+			f:    filterPage{Filter: Filter{Text: []string{"hello"}}, Page: Page{Max: 1, After: PageCursor("https://example.com/objects/4")}},
+			want: []pub.IRI{"https://example.com/objects/1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, next, err := OrderMembers(members, tt.f)
+			if err != nil {
+				t.Fatalf("OrderMembers returned error: %s", err)
+			}
+			if (len(next) > 0) != tt.next {
+				t.Errorf("OrderMembers returned next cursor %q, expected one %t", next, tt.next)
+			}
+			if len(items) != len(tt.want) {
+				t.Fatalf("OrderMembers returned %v, expected %v", items, tt.want)
+			}
+			for i, it := range items {
+				if it.GetLink() != tt.want[i] {
+					t.Errorf("OrderMembers returned %v, expected %v", items, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadCollection(t *testing.T) {
+	s := newMapStore()
+	outbox := pub.IRI("https://example.com/actors/jdoe/outbox")
+	col := pub.OrderedCollectionNew(outbox)
+	col.OrderedItems = pub.ItemCollection{
+		note("https://example.com/objects/1", "hello"),
+		note("https://example.com/objects/2", "goodbye"),
+	}
+	s.Save(col)
+	s.Save(note("https://example.com/objects/1", "hello"))
+
+	items, _, err := LoadCollection(s, outbox, Page{Max: 1})
+	if err != nil {
+		t.Fatalf("LoadCollection returned error: %s", err)
+	}
+	if len(items) != 1 || items[0].GetLink() != "https://example.com/objects/2" {
+		t.Errorf("LoadCollection returned %v, expected the last added item", items)
+	}
+	if _, _, err := LoadCollection(s, "https://example.com/objects/1", Page{}); err == nil {
+		t.Errorf("LoadCollection of an object should fail")
+	}
+}
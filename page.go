@@ -0,0 +1,283 @@
+package storage
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// DefaultMaxItems is the page size used when a pagination request doesn't specify one.
+const DefaultMaxItems = 100
+
+// FilterablePage can request a page of the items of a collection.
+type FilterablePage interface {
+	Filterable
+	// MaxItems returns the maximum number of items in the page.
+	MaxItems() int
+	// Cursor returns the opaque token, received with a previous page, after which the requested
+	// page starts. An empty cursor requests the first page.
+	Cursor() string
+}
+
+// PageStore can load collections one page at a time, without loading all their items in memory.
+type PageStore interface {
+	// LoadPage returns the page of the collection identified by the "f" filter, and the cursor
+	// of the next page, which is empty when the returned page is the last one.
+	LoadPage(f FilterablePage) (pub.ItemCollection, string, error)
+}
+
+// Page is a pagination request for the IRI collection.
+type Page struct {
+	IRI   pub.IRI
+	Max   int
+	After string
+	Order SortOrder
+	// Since and Until are the bounds of the FilterableRange of the page.
+	Since pub.IRI
+	Until pub.IRI
+	// Pins are the members returned first, see FilterablePinned.
+	Pins pub.IRIs
+}
+
+func (p Page) GetLink() pub.IRI {
+	return p.IRI
+}
+
+// MaxItems returns the size of the page, or DefaultMaxItems if it's not set.
+func (p Page) MaxItems() int {
+	if p.Max <= 0 {
+		return DefaultMaxItems
+	}
+	return p.Max
+}
+
+func (p Page) Cursor() string {
+	return p.After
+}
+
+func (p Page) SortOrder() SortOrder {
+	return p.Order
+}
+
+func (p Page) SinceID() pub.IRI {
+	return p.Since
+}
+
+func (p Page) MaxID() pub.IRI {
+	return p.Until
+}
+
+func (p Page) Pinned() pub.IRIs {
+	return p.Pins
+}
+
+// PageCursor returns the cursor for the page that starts after the "iri" item.
+func PageCursor(iri pub.IRI) string {
+	if len(iri) == 0 {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(iri))
+}
+
+// ParseCursor returns the IRI of the item after which the page for "cursor" starts.
+func ParseCursor(cursor string) (pub.IRI, error) {
+	if len(cursor) == 0 {
+		return "", nil
+	}
+	iri, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", fmt.Errorf("invalid cursor %q: %w", cursor, err)
+	}
+	return pub.IRI(iri), nil
+}
+
+// Paginate returns the page of "items" corresponding to the "p" request and the cursor for the next page.
+// It is meant for backends that already have the items in memory, the others should implement
+// PageStore using the native capabilities of their storage layer.
+func Paginate(items pub.ItemCollection, p FilterablePage) (pub.ItemCollection, string, error) {
+	after, err := ParseCursor(p.Cursor())
+	if err != nil {
+		return nil, "", err
+	}
+	start := 0
+	if len(after) > 0 {
+		start = -1
+		for i, it := range items {
+			if !pub.IsNil(it) && it.GetLink() == after {
+				start = i + 1
+				break
+			}
+		}
+		if start < 0 {
+			return nil, "", fmt.Errorf("unable to find cursor item %s", after)
+		}
+	}
+	max := p.MaxItems()
+	if max <= 0 {
+		max = DefaultMaxItems
+	}
+	end := start + max
+	if end >= len(items) {
+		return items[start:], "", nil
+	}
+	page := items[start:end]
+	return page, PageCursor(page[len(page)-1].GetLink()), nil
+}
+
+// CollectionPageStore can load a page of a collection as a fully populated OrderedCollectionPage,
+// using the totalItems persisted together with the collection instead of counting its members.
+type CollectionPageStore interface {
+	// LoadCollectionPage returns the page of the collection requested by "p", with its members in
+	// reverse chronological order, or in the order requested by "p", and the IRIs of the first, last, previous and next pages.
+	// The members are not filtered, only their IRIs are read to compute the cursors of the pages,
+	// and only the members of the returned page are loaded.
+	LoadCollectionPage(p FilterablePage) (*pub.OrderedCollectionPage, error)
+}
+
+// PageIRI returns the IRI of the page of the "col" collection having at most "max" items, starting
+// after the "cursor" one, eg: "https://example.com/actors/jdoe/outbox?maxItems=100&after=...".
+func PageIRI(col pub.IRI, max int, cursor string) pub.IRI {
+	q := url.Values{}
+	q.Set("maxItems", strconv.Itoa(max))
+	if len(cursor) > 0 {
+		q.Set("after", cursor)
+	}
+	sep := "?"
+	if strings.Contains(string(col), "?") {
+		sep = "&"
+	}
+	return pub.IRI(string(col) + sep + q.Encode())
+}
+
+// CollectionPage returns the page of the "col" collection requested by "p", given the IRIs of its
+// "members" in the order requested by "p", see SortOrderOf, and its persisted "totalItems".
+// Only the members in the range requested by "p" are paginated, if it's a FilterableRange, with the
+// ones it pins first, if it's a FilterablePinned.
+// The IRIs of the pages include the sort order, when it's not the default one, and the range. The items of the page
+// are the IRIs of the members, which the backends replace with the stored objects.
+func CollectionPage(col pub.IRI, members pub.IRIs, totalItems uint, p FilterablePage) (*pub.OrderedCollectionPage, error) {
+	after, err := ParseCursor(p.Cursor())
+	if err != nil {
+		return nil, err
+	}
+	first, last, err := RangeBounds(len(members), func(i int) pub.IRI { return members[i] }, p)
+	if err != nil {
+		return nil, err
+	}
+	members = members[first:last]
+	if pf, ok := p.(FilterablePinned); ok && len(pf.Pinned()) > 0 {
+		items := make(pub.ItemCollection, 0, len(members))
+		for _, iri := range members {
+			items = append(items, iri)
+		}
+		members = make(pub.IRIs, 0, len(items))
+		for _, it := range PinMembers(items, p) {
+			members = append(members, it.GetLink())
+		}
+	}
+	start := 0
+	if len(after) > 0 {
+		start = -1
+		for i, iri := range members {
+			if iri == after {
+				start = i + 1
+				break
+			}
+		}
+		if start < 0 {
+			return nil, fmt.Errorf("unable to find cursor item %s", after)
+		}
+	}
+	max := p.MaxItems()
+	if max <= 0 {
+		max = DefaultMaxItems
+	}
+	end := start + max
+	if end > len(members) {
+		end = len(members)
+	}
+	q := url.Values{}
+	if order := SortOrderOf(p); order != (SortOrder{}) {
+		q.Set("sort", order.String())
+	}
+	if rf, ok := p.(FilterableRange); ok {
+		if since := rf.SinceID(); len(since) > 0 {
+			q.Set("since_id", since.String())
+		}
+		if max := rf.MaxID(); len(max) > 0 {
+			q.Set("max_id", max.String())
+		}
+	}
+	pageIRI := func(cursor string) pub.IRI {
+		iri := PageIRI(col, max, cursor)
+		if len(q) > 0 {
+			iri = pub.IRI(string(iri) + "&" + q.Encode())
+		}
+		return iri
+	}
+	// cursor returns the cursor of the page starting at the "i" member.
+	cursor := func(i int) string {
+		if i <= 0 {
+			return ""
+		}
+		return PageCursor(members[i-1])
+	}
+
+	page := &pub.OrderedCollectionPage{
+		ID:         pageIRI(p.Cursor()),
+		Type:       pub.OrderedCollectionPageType,
+		PartOf:     col,
+		TotalItems: totalItems,
+		StartIndex: uint(start),
+		First:      pageIRI(""),
+	}
+	if len(members) > 0 {
+		page.Last = pageIRI(cursor((len(members) - 1) / max * max))
+	}
+	if start > 0 {
+		prev := start - max
+		if prev < 0 {
+			prev = 0
+		}
+		page.Prev = pageIRI(cursor(prev))
+	}
+	if end < len(members) {
+		page.Next = pageIRI(cursor(end))
+	}
+	page.OrderedItems = make(pub.ItemCollection, 0, end-start)
+	for _, iri := range members[start:end] {
+		page.OrderedItems = append(page.OrderedItems, iri)
+	}
+	return page, nil
+}
+
+// LoadCollectionPage returns the page requested by "p" of a collection in the "s" store, see CollectionPageStore.
+// For stores that are not CollectionPageStores it loads the whole collection, and counts its members.
+func LoadCollectionPage(s ReadStore, p FilterablePage) (*pub.OrderedCollectionPage, error) {
+	if cs, ok := s.(CollectionPageStore); ok {
+		return cs.LoadCollectionPage(p)
+	}
+	members, _, err := LoadCollection(s, p.GetLink(), pub.IRI(p.GetLink()))
+	if err != nil {
+		return nil, err
+	}
+	SortMembers(members, SortOrderOf(p))
+	iris := make(pub.IRIs, 0, len(members))
+	loaded := make(map[pub.IRI]pub.Item, len(members))
+	for _, it := range members {
+		iris = append(iris, it.GetLink())
+		loaded[it.GetLink()] = it
+	}
+	page, err := CollectionPage(p.GetLink(), iris, uint(len(iris)), p)
+	if err != nil {
+		return nil, err
+	}
+	for i, it := range page.OrderedItems {
+		page.OrderedItems[i] = loaded[it.GetLink()]
+	}
+	return page, nil
+}
@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+func TestPageCursor(t *testing.T) {
+	iri := pub.IRI("https://example.com/objects/1?a=b")
+	cursor := PageCursor(iri)
+	got, err := ParseCursor(cursor)
+	if err != nil {
+		t.Fatalf("ParseCursor returned error: %s", err)
+	}
+	if got != iri {
+		t.Errorf("ParseCursor(PageCursor(%s)) = %s", iri, got)
+	}
+	if PageCursor("") != "" {
+		t.Errorf("PageCursor for an empty IRI should be empty")
+	}
+	if _, err := ParseCursor("not base64!"); err == nil {
+		t.Errorf("ParseCursor should fail for invalid cursors")
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	items := make(pub.ItemCollection, 0)
+	for i := 1; i <= 5; i++ {
+		items = append(items, pub.IRI(fmt.Sprintf("https://example.com/objects/%d", i)))
+	}
+	col := pub.IRI("https://example.com/outbox")
+
+	seen := make(pub.IRIs, 0)
+	pages := 0
+	cursor := ""
+	for {
+		page, next, err := Paginate(items, Page{IRI: col, Max: 2, After: cursor})
+		if err != nil {
+			t.Fatalf("Paginate returned error: %s", err)
+		}
+		pages++
+		for _, it := range page {
+			seen = append(seen, it.GetLink())
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	if pages != 3 {
+		t.Errorf("expected 3 pages, got %d", pages)
+	}
+	if len(seen) != len(items) {
+		t.Fatalf("expected %d items, got %d", len(items), len(seen))
+	}
+	for i, iri := range seen {
+		if iri != items[i].GetLink() {
+			t.Errorf("item %d is %s, expected %s", i, iri, items[i].GetLink())
+		}
+	}
+
+	page, next, _ := Paginate(items, Page{IRI: col})
+	if len(page) != len(items) || next != "" {
+		t.Errorf("default page size should return all %d items, got %d, next %q", len(items), len(page), next)
+	}
+	if _, _, err := Paginate(items, Page{IRI: col, After: PageCursor("https://example.com/missing")}); err == nil {
+		t.Errorf("Paginate should fail for an unknown cursor")
+	}
+}
+
+func TestCollectionPage(t *testing.T) {
+	col := pub.IRI("https://example.com/outbox")
+	members := make(pub.IRIs, 0)
+	for i := 5; i >= 1; i-- {
+		members = append(members, pub.IRI(fmt.Sprintf("https://example.com/objects/%d", i)))
+	}
+
+	first, err := CollectionPage(col, members, 5, Page{IRI: col, Max: 2})
+	if err != nil {
+		t.Fatalf("CollectionPage returned error: %s", err)
+	}
+	if first.TotalItems != 5 || len(first.OrderedItems) != 2 || first.OrderedItems[0] != members[0] {
+		t.Errorf("CollectionPage returned %v, expected the 2 most recent of 5 items", first.OrderedItems)
+	}
+	if first.Prev != nil || first.PartOf != col || first.First.GetLink() != PageIRI(col, 2, "") {
+		t.Errorf("the first page has prev %v, partOf %v and first %v", first.Prev, first.PartOf, first.First)
+	}
+	if want := PageIRI(col, 2, PageCursor(members[3])); first.Last.GetLink() != want {
+		t.Errorf("the last page is %s, expected %s", first.Last.GetLink(), want)
+	}
+
+	second, err := CollectionPage(col, members, 5, Page{IRI: col, Max: 2, After: PageCursor(members[1])})
+	if err != nil {
+		t.Fatalf("CollectionPage returned error: %s", err)
+	}
+	if second.ID != first.Next.GetLink() || second.Prev.GetLink() != first.ID || second.StartIndex != 2 {
+		t.Errorf("the second page %s, with prev %v, doesn't follow the first one %s", second.ID, second.Prev, first.ID)
+	}
+
+	last, _ := CollectionPage(col, members, 5, Page{IRI: col, Max: 2, After: PageCursor(members[3])})
+	if len(last.OrderedItems) != 1 || last.Next != nil || last.Prev.GetLink() != second.ID {
+		t.Errorf("the last page has items %v, next %v and prev %v", last.OrderedItems, last.Next, last.Prev)
+	}
+	if _, err := CollectionPage(col, members, 5, Page{IRI: col, After: PageCursor("https://example.com/objects/6")}); err == nil {
+		t.Errorf("CollectionPage should fail for a cursor which isn't a member")
+	}
+}
@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"errors"
+)
+
+// ErrPartialResult signals that a load operation has been interrupted, usually by reaching its
+// deadline, and that the returned result contains only the items gathered until that moment.
+//
+// It is a non-fatal error: the accompanying result is valid and can be used, it's just incomplete.
+// Backends return it only when the filter used for the load implements FilterablePartial and
+// opts in, otherwise an interrupted load returns just the cause of the interruption.
+//
+// Partial results are supported only by the operations that accumulate multiple items: loading
+// collections and iterating, see LoadCollectionContext and EachContext, with the result gathered
+// until the context was done. Loading a single object, and all the write operations, are never partial.
+var ErrPartialResult = errors.New("partial result")
+
+// FilterablePartial can be implemented by filters for best-effort queries, which prefer an
+// incomplete result over an error, see ErrPartialResult.
+type FilterablePartial interface {
+	Filterable
+	AllowPartial() bool
+}
+
+// Partial wraps the "err" cause of an interruption into an error that matches ErrPartialResult.
+// The cause is preserved, so errors.Is(err, context.DeadlineExceeded) works as expected.
+func Partial(err error) error {
+	if err == nil {
+		return ErrPartialResult
+	}
+	return partialError{err: err}
+}
+
+// Interrupted returns the error of an operation on the items matching "f" interrupted by "err":
+// a partial result error, if "f" opts in to partial results, or "err" otherwise.
+func Interrupted(f Filterable, err error) error {
+	if AllowsPartial(f) {
+		return Partial(err)
+	}
+	return err
+}
+
+// IsPartial returns true if "err" signals a partial result.
+func IsPartial(err error) bool {
+	return errors.Is(err, ErrPartialResult)
+}
+
+// AllowsPartial checks if the "f" filter opts in to receiving partial results.
+func AllowsPartial(f Filterable) bool {
+	p, ok := f.(FilterablePartial)
+	return ok && p.AllowPartial()
+}
+
+type partialError struct {
+	err error
+}
+
+func (p partialError) Error() string {
+	return ErrPartialResult.Error() + ": " + p.err.Error()
+}
+
+func (p partialError) Unwrap() error {
+	return p.err
+}
+
+func (p partialError) Is(target error) bool {
+	return target == ErrPartialResult
+}
@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+type partialFilter struct {
+	pub.IRI
+	allow bool
+}
+
+func (p partialFilter) AllowPartial() bool {
+	return p.allow
+}
+
+func TestPartial(t *testing.T) {
+	err := Partial(context.DeadlineExceeded)
+	if !IsPartial(err) {
+		t.Errorf("Partial(%s) should match ErrPartialResult", context.DeadlineExceeded)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Partial(%s) should preserve the cause", context.DeadlineExceeded)
+	}
+	if want := "partial result: context deadline exceeded"; err.Error() != want {
+		t.Errorf("invalid error message %q, expected %q", err.Error(), want)
+	}
+	if !IsPartial(Partial(nil)) {
+		t.Errorf("Partial(nil) should match ErrPartialResult")
+	}
+	if IsPartial(context.DeadlineExceeded) {
+		t.Errorf("%s should not match ErrPartialResult", context.DeadlineExceeded)
+	}
+}
+
+func TestAllowsPartial(t *testing.T) {
+	iri := pub.IRI("https://example.com/inbox")
+	if AllowsPartial(iri) {
+		t.Errorf("plain IRI filters should not allow partial results")
+	}
+	if AllowsPartial(partialFilter{IRI: iri}) {
+		t.Errorf("filter should not allow partial results when not opting in")
+	}
+	if !AllowsPartial(partialFilter{IRI: iri, allow: true}) {
+		t.Errorf("filter should allow partial results when opting in")
+	}
+}
+
+// countdown is a context which is done after its Err method has been called "n" times,
+// for interrupting the operations at a known point.
+type countdown struct {
+	context.Context
+	n int
+}
+
+func (c *countdown) Err() error {
+	if c.n <= 0 {
+		return context.DeadlineExceeded
+	}
+	c.n--
+	return nil
+}
+
+func TestInterrupted(t *testing.T) {
+	iri := pub.IRI("https://example.com/inbox")
+	if err := Interrupted(iri, context.DeadlineExceeded); IsPartial(err) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Interrupted returned %v for a filter not allowing partial results", err)
+	}
+	if err := Interrupted(Filter{IRI: iri, Partial: true}, context.DeadlineExceeded); !IsPartial(err) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Interrupted returned %v for a filter allowing partial results", err)
+	}
+}
+
+func TestEachContext(t *testing.T) {
+	s := newMapStore()
+	inbox := pub.OrderedCollectionNew("https://example.com/inbox")
+	for _, iri := range []pub.IRI{"https://example.com/objects/1", "https://example.com/objects/2", "https://example.com/objects/3"} {
+		inbox.OrderedItems = append(inbox.OrderedItems, note(iri, "Hello"))
+	}
+	s.Save(inbox)
+
+	for _, allow := range []bool{false, true} {
+		seen := 0
+		err := EachContext(&countdown{Context: context.Background(), n: 3}, s, Filter{IRI: inbox.ID, Partial: allow}, func(pub.Item) error {
+			seen++
+			return nil
+		})
+		if seen != 2 || !errors.Is(err, context.DeadlineExceeded) || IsPartial(err) != allow {
+			t.Errorf("EachContext called fn %d times and returned %v, with partial results allowed: %t", seen, err, allow)
+		}
+	}
+}
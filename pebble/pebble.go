@@ -0,0 +1,218 @@
+// Package pebble implements a storage backend on top of Pebble, the key-value store of CockroachDB,
+// built as a log-structured merge tree, as a higher throughput alternative to the badger backend.
+//
+// The items are stored in the bucket matching their type, see storage.Bucket, as keys prefixed by
+// the name of the bucket, eg: "actors/", or "objects/", which are read with prefix iterators, and the
+// collections in the storage.CollectionDocument format.
+package pebble
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/cockroachdb/pebble/v2"
+	"github.com/cockroachdb/pebble/v2/vfs"
+	"github.com/go-ap/storage"
+	"github.com/go-ap/storage/internal/kv"
+)
+
+// Config holds the options for the Pebble storage.
+type Config struct {
+	// Path is the directory in which the database files are stored.
+	Path string
+	// InMemory keeps the database in memory, without writing it to Path, eg: for tests.
+	InMemory bool
+	// CreateIfMissing creates the database if the c.Path directory doesn't hold one, otherwise New fails
+	// for it, so a wrong path isn't mistaken for an empty storage. See Bootstrap.
+	CreateIfMissing bool
+	// Timeout is how long New waits for the database to be closed by the process which has it open,
+	// before failing. It fails right away if it's not set.
+	Timeout time.Duration
+	// ReadOnly opens an existing database without modifying it, with all the write operations returning
+	// storage.ErrReadOnly, eg: for maintenance tools. Unlike Badger, Pebble locks the database in read-only
+	// mode too, so it can't be opened while another process has it open.
+	ReadOnly bool
+	// IDGen generates the IDs of new items, using storage.ULIDs if not set.
+	IDGen storage.IDGenFn
+}
+
+type repo struct {
+	*kv.Store
+}
+
+var (
+	_ storage.Store                  = &repo{}
+	_ storage.CollectionStore        = &repo{}
+	_ storage.OrderedCollectionStore = &repo{}
+	_ storage.IDGenerator            = &repo{}
+	_ storage.ChangeFeed             = &repo{}
+	_ storage.SchemaStore            = &repo{}
+	_ storage.BackupStore            = &repo{}
+	_ io.Closer                      = &repo{}
+)
+
+// New opens the Pebble database in the c.Path directory, creating it if it doesn't exist and
+// c.CreateIfMissing is set.
+// Only one process can have the database open at the same time, see Config.Timeout.
+func New(c Config) (*repo, error) {
+	if len(c.Path) == 0 && !c.InMemory {
+		return nil, fmt.Errorf("%w: the path of the storage is empty", storage.ErrNotValid)
+	}
+	opts := &pebble.Options{
+		ErrorIfNotExists: !c.InMemory && !c.CreateIfMissing,
+		ReadOnly:         c.ReadOnly,
+	}
+	if c.InMemory {
+		opts.FS = vfs.NewMem()
+	}
+	p, err := open(c.Path, opts, c.Timeout)
+	if errors.Is(err, pebble.ErrDBDoesNotExist) {
+		return nil, fmt.Errorf("unable to open the pebble storage %s, see Bootstrap: %w", c.Path, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to open the pebble storage %s: %w", c.Path, err)
+	}
+	s := kv.New(&db{DB: p}, kv.Options{IDGen: c.IDGen, ReadOnly: c.ReadOnly})
+	if err := s.Upgrade(); err != nil {
+		s.Close()
+		return nil, fmt.Errorf("unable to open the pebble storage %s: %w", c.Path, err)
+	}
+	return &repo{Store: s}, nil
+}
+
+// lockRetry is the interval at which open retries to acquire the lock of the database.
+const lockRetry = 50 * time.Millisecond
+
+// open opens the database, retrying while it's locked by another process, until "timeout" passes.
+func open(path string, opts *pebble.Options, timeout time.Duration) (*pebble.DB, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		p, err := pebble.Open(path, opts)
+		if err == nil || !isLocked(err) || time.Now().Add(lockRetry).After(deadline) {
+			return p, err
+		}
+		time.Sleep(lockRetry)
+	}
+}
+
+// isLocked reports whether "err" is the failure to acquire the lock of the database directory, which
+// is held by another process, or by another database opened by this one.
+func isLocked(err error) bool {
+	return errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EACCES) ||
+		strings.Contains(err.Error(), "lock held by current process")
+}
+
+// Bootstrap creates the Pebble database in the c.Path directory, if it doesn't exist, and records the
+// version of its layout, so the first run of a service doesn't need any manual setup.
+func Bootstrap(c Config) error {
+	c.CreateIfMissing = true
+	r, err := New(c)
+	if err != nil {
+		return err
+	}
+	return r.Close()
+}
+
+// db is the kv.DB of a Pebble database. Pebble doesn't have transactions, so the reads see a snapshot of
+// the database, and the writes are done in indexed batches, which are committed one at a time.
+type db struct {
+	*pebble.DB
+	// mu serializes the read-write transactions, as the batches aren't checked for conflicts.
+	mu sync.Mutex
+}
+
+func (d *db) View(fn func(kv.Tx) error) error {
+	s := d.DB.NewSnapshot()
+	defer s.Close()
+	return fn(tx{r: s})
+}
+
+func (d *db) Update(fn func(kv.Tx) error) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	b := d.DB.NewIndexedBatch()
+	defer b.Close()
+	if err := fn(tx{r: b, b: b}); err != nil {
+		return err
+	}
+	return b.Commit(pebble.Sync)
+}
+
+// reader is implemented by the snapshots, and the indexed batches, of a database.
+type reader interface {
+	Get(key []byte) ([]byte, io.Closer, error)
+	NewIter(o *pebble.IterOptions) (*pebble.Iterator, error)
+}
+
+// tx reads from "r", and writes to "b", which is nil for the read-only transactions.
+type tx struct {
+	r reader
+	b *pebble.Batch
+}
+
+func (t tx) Get(key []byte) ([]byte, error) {
+	value, closer, err := t.r.Get(key)
+	if errors.Is(err, pebble.ErrNotFound) {
+		return nil, kv.ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+	return append([]byte{}, value...), nil
+}
+
+func (t tx) Set(key, value []byte) error {
+	if t.b == nil {
+		return storage.ErrReadOnly
+	}
+	return t.b.Set(key, value, nil)
+}
+
+func (t tx) Delete(key []byte) error {
+	if t.b == nil {
+		return storage.ErrReadOnly
+	}
+	return t.b.Delete(key, nil)
+}
+
+func (t tx) Scan(prefix []byte, reverse bool, fn func(key, value []byte) bool) error {
+	it, err := t.r.NewIter(&pebble.IterOptions{LowerBound: prefix, UpperBound: upperBound(prefix)})
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+
+	first, next := it.First, it.Next
+	if reverse {
+		first, next = it.Last, it.Prev
+	}
+	for ok := first(); ok; ok = next() {
+		value, err := it.ValueAndErr()
+		if err != nil {
+			return err
+		}
+		if !fn(it.Key(), value) {
+			return nil
+		}
+	}
+	return it.Error()
+}
+
+// upperBound returns the smallest key greater than all the keys starting with "prefix",
+// or nil if there's none, eg: for an empty prefix.
+func upperBound(prefix []byte) []byte {
+	end := append([]byte{}, prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i]++; end[i] != 0 {
+			return end[:i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// PinnedNamespace is the MetadataStore namespace under which PinStore stores the pinned members of each collection.
+const PinnedNamespace = "pinned"
+
+// FilterablePinned can request the pinned members of a collection to be returned before the other ones.
+type FilterablePinned interface {
+	Filterable
+	// Pinned returns the IRIs of the pinned members, in the order in which they're returned.
+	Pinned() pub.IRIs
+}
+
+// PinMembers moves the members of "items" which are pinned by "f", if it's a FilterablePinned,
+// to the beginning, in the order of the pins. The other members keep their order.
+func PinMembers(items pub.ItemCollection, f Filterable) pub.ItemCollection {
+	pf, ok := f.(FilterablePinned)
+	if !ok || len(pf.Pinned()) == 0 {
+		return items
+	}
+	pins := make(map[pub.IRI]int)
+	for i, iri := range pf.Pinned() {
+		if _, ok := pins[iri]; !ok {
+			pins[iri] = i
+		}
+	}
+	pinned := make([]pub.Item, len(pf.Pinned()))
+	result := make(pub.ItemCollection, 0, len(items))
+	for _, it := range items {
+		if i, ok := pins[it.GetLink()]; ok {
+			pinned[i] = it
+			continue
+		}
+		result = append(result, it)
+	}
+	first := make(pub.ItemCollection, 0, len(items))
+	for _, it := range pinned {
+		if it != nil {
+			first = append(first, it)
+		}
+	}
+	return append(first, result...)
+}
+
+// PinStore is a Store that keeps the pinned members of the collections, like the featured collections
+// of the actors, in the PinnedNamespace metadata of a MetadataStore, and returns them first when
+// reading the collections with a Page, or a Filter.
+type PinStore struct {
+	Store
+	m  MetadataStore
+	mu sync.Mutex
+}
+
+// Pins returns a PinStore keeping the pins of the collections of "s" in "m", which is usually "s" itself.
+func Pins(s Store, m MetadataStore) *PinStore {
+	return &PinStore{Store: s, m: m}
+}
+
+// LoadPinned returns the pinned members of the "col" collection, most recently pinned first.
+func (p *PinStore) LoadPinned(col pub.IRI) (pub.IRIs, error) {
+	pins := make(pub.IRIs, 0)
+	data, err := p.m.LoadMetadata(col, PinnedNamespace)
+	if errors.Is(err, ErrNotFound) || (err == nil && len(data) == 0) {
+		return pins, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	iris := make([]pub.IRI, 0)
+	if err := json.Unmarshal(data, &iris); err != nil {
+		return nil, fmt.Errorf("invalid pins of %s: %w", col, err)
+	}
+	return append(pins, iris...), nil
+}
+
+func (p *PinStore) savePinned(col pub.IRI, pins pub.IRIs) error {
+	data, err := json.Marshal([]pub.IRI(pins))
+	if err != nil {
+		return err
+	}
+	return p.m.SaveMetadata(col, PinnedNamespace, data)
+}
+
+// Pin pins "it", which needs to be a member of the "col" collection, before the other pinned members.
+// Pinning a member which is already pinned is a no-op.
+func (p *PinStore) Pin(col pub.IRI, it pub.Item) error {
+	if pub.IsNil(it) {
+		return fmt.Errorf("%w: unable to pin nil item", ErrNotValid)
+	}
+	member, err := IsMember(p.Store, col, it)
+	if err != nil {
+		return err
+	}
+	if !member {
+		return fmt.Errorf("%w: %s is not a member of %s", ErrNotValid, it.GetLink(), col)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pins, err := p.LoadPinned(col)
+	if err != nil || pins.Contains(it.GetLink()) {
+		return err
+	}
+	return p.savePinned(col, append(pub.IRIs{it.GetLink()}, pins...))
+}
+
+// Unpin unpins "it" in the "col" collection.
+func (p *PinStore) Unpin(col pub.IRI, it pub.Item) error {
+	if pub.IsNil(it) {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pins, err := p.LoadPinned(col)
+	if err != nil || !pins.Contains(it.GetLink()) {
+		return err
+	}
+	remaining := make(pub.IRIs, 0, len(pins))
+	for _, iri := range pins {
+		if iri != it.GetLink() {
+			remaining = append(remaining, iri)
+		}
+	}
+	return p.savePinned(col, remaining)
+}
+
+// pinned returns "f" requesting the pinned members of the "iri" collection first, when it's a Page,
+// or a Filter, which don't request any pins already.
+func (p *PinStore) pinned(iri pub.IRI, f Filterable) (Filterable, error) {
+	switch ff := f.(type) {
+	case Page:
+		if len(ff.Pins) > 0 {
+			return f, nil
+		}
+		pins, err := p.LoadPinned(iri)
+		ff.Pins = pins
+		return ff, err
+	case Filter:
+		if len(ff.Pins) > 0 {
+			return f, nil
+		}
+		pins, err := p.LoadPinned(iri)
+		ff.Pins = pins
+		return ff, err
+	}
+	return f, nil
+}
+
+// LoadCollection returns the members of the "iri" collection matching "f", with the pinned ones first,
+// see LoadCollection.
+func (p *PinStore) LoadCollection(iri pub.IRI, f Filterable) (pub.ItemCollection, string, error) {
+	f, err := p.pinned(iri, f)
+	if err != nil {
+		return nil, "", err
+	}
+	return LoadCollection(p.Store, iri, f)
+}
+
+// LoadCollectionPage returns the page requested by "pp" of a collection, with the pinned members first,
+// see LoadCollectionPage.
+func (p *PinStore) LoadCollectionPage(pp FilterablePage) (*pub.OrderedCollectionPage, error) {
+	f, err := p.pinned(pp.GetLink(), pp)
+	if err != nil {
+		return nil, err
+	}
+	return LoadCollectionPage(p.Store, f.(FilterablePage))
+}
+
+// Create creates the "col" collection in the underlying store, which needs to be a CollectionStore.
+func (p *PinStore) Create(col pub.CollectionInterface) (pub.CollectionInterface, error) {
+	return asCollectionStore(p.Store).Create(col)
+}
+
+// AddTo adds "it" to the "col" collection in the underlying store, which needs to be a CollectionStore.
+func (p *PinStore) AddTo(col pub.IRI, it pub.Item) error {
+	return asCollectionStore(p.Store).AddTo(col, it)
+}
+
+// RemoveFrom removes "it" from the "col" collection in the underlying store, which needs to be
+// a CollectionStore, and unpins it.
+func (p *PinStore) RemoveFrom(col pub.IRI, it pub.Item) error {
+	if err := asCollectionStore(p.Store).RemoveFrom(col, it); err != nil {
+		return err
+	}
+	return p.Unpin(col, it)
+}
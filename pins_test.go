@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+func TestPins(t *testing.T) {
+	cs := newCollectionMapStore()
+	p := Pins(cs, metadataMap{})
+	featured := pub.IRI("https://example.com/actors/jdoe/featured")
+	p.Create(pub.OrderedCollectionNew(featured))
+	col := pub.OrderedCollectionNew(featured)
+	for _, iri := range []pub.IRI{"https://example.com/objects/1", "https://example.com/objects/2", "https://example.com/objects/3", "https://example.com/objects/4"} {
+		p.AddTo(featured, iri)
+		col.OrderedItems = append(col.OrderedItems, note(iri, "hello"))
+	}
+	// the collectionMapStore doesn't load the members of the collections
+	p.Save(col)
+
+	if err := p.Pin(featured, pub.IRI("https://example.com/objects/5")); !errors.Is(err, ErrNotValid) {
+		t.Errorf("Pin of an item which isn't a member returned %v, expected %s", err, ErrNotValid)
+	}
+	for _, iri := range []pub.IRI{"https://example.com/objects/1", "https://example.com/objects/3", "https://example.com/objects/1"} {
+		if err := p.Pin(featured, iri); err != nil {
+			t.Fatalf("Pin returned error: %s", err)
+		}
+	}
+	pins, err := p.LoadPinned(featured)
+	if err != nil {
+		t.Fatalf("LoadPinned returned error: %s", err)
+	}
+	if len(pins) != 2 || pins[0] != "https://example.com/objects/3" || pins[1] != "https://example.com/objects/1" {
+		t.Errorf("LoadPinned returned %v, expected the pins, most recent first", pins)
+	}
+
+	items, next, err := p.LoadCollection(featured, Page{IRI: featured, Max: 3})
+	if err != nil {
+		t.Fatalf("LoadCollection returned error: %s", err)
+	}
+	want := pub.IRIs{"https://example.com/objects/3", "https://example.com/objects/1", "https://example.com/objects/4"}
+	if len(items) != len(want) || next == "" {
+		t.Fatalf("LoadCollection returned %v, %q, expected %v and a next page", items, next, want)
+	}
+	for i, it := range items {
+		if it.GetLink() != want[i] {
+			t.Errorf("member %d is %s, expected %s", i, it.GetLink(), want[i])
+		}
+	}
+	page, err := p.LoadCollectionPage(Page{IRI: featured, Max: 3, After: next})
+	if err != nil || len(page.OrderedItems) != 1 || page.OrderedItems[0].GetLink() != "https://example.com/objects/2" {
+		t.Errorf("LoadCollectionPage returned %v, %v, expected the last unpinned member", page, err)
+	}
+
+	if err := p.RemoveFrom(featured, pub.IRI("https://example.com/objects/3")); err != nil {
+		t.Fatalf("RemoveFrom returned error: %s", err)
+	}
+	if pins, _ := p.LoadPinned(featured); len(pins) != 1 || pins[0] != "https://example.com/objects/1" {
+		t.Errorf("LoadPinned returned %v, expected the removed member to be unpinned", pins)
+	}
+}
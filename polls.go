@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"fmt"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+var (
+	// ErrDuplicateVote is returned when a voter tries to vote again in a single choice poll,
+	// or to vote for the same option twice in a multiple choice one.
+	ErrDuplicateVote = fmt.Errorf("duplicate vote: %w", ErrConflict)
+	// ErrPollClosed is returned when trying to vote in a Question that doesn't accept answers anymore.
+	ErrPollClosed = fmt.Errorf("poll is closed: %w", ErrNotValid)
+)
+
+// VoteStore keeps track of the votes cast in Question polls, deduplicated per voter.
+type VoteStore interface {
+	// RecordVote saves the vote of "voter" for the "option" answer of the "question" poll.
+	// It returns ErrDuplicateVote if the voter has already voted in the poll, when the poll is single choice,
+	// or if they already voted for the same option, when the poll is multiple choice.
+	RecordVote(question pub.IRI, voter pub.IRI, option string) error
+	// PollResults returns the number of votes for each of the options of the "question" poll.
+	PollResults(question pub.IRI) (map[string]uint, error)
+}
+
+// PollOptions returns the names of the answers of the "q" Question, and whether it accepts
+// multiple answers from the same voter (ie, it uses anyOf instead of oneOf).
+func PollOptions(q pub.Item) (options []string, multiple bool) {
+	pub.OnQuestion(q, func(q *pub.Question) error {
+		answers := q.OneOf
+		if pub.IsNil(answers) {
+			answers, multiple = q.AnyOf, true
+		}
+		pub.OnObject(answers, func(o *pub.Object) error {
+			if name := o.Name.First().Value.String(); len(name) > 0 {
+				options = append(options, name)
+			}
+			return nil
+		})
+		return nil
+	})
+	return options, multiple
+}
+
+// ValidateVote checks that "option" is one of the answers of the "q" Question, and that
+// the Question is still accepting votes.
+func ValidateVote(q pub.Item, option string) error {
+	if pub.IsNil(q) || q.GetType() != pub.QuestionType {
+		return fmt.Errorf("%w poll %T, expected %s", ErrNotValid, q, pub.QuestionType)
+	}
+	closed := false
+	pub.OnQuestion(q, func(q *pub.Question) error {
+		closed = q.Closed
+		return nil
+	})
+	if closed {
+		return ErrPollClosed
+	}
+	options, _ := PollOptions(q)
+	for _, o := range options {
+		if o == option {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w option %q for poll %s", ErrNotValid, option, q.GetLink())
+}
@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+func answer(name string) *pub.Object {
+	o := pub.ObjectNew(pub.NoteType)
+	o.Name.Set(pub.NilLangRef, pub.Content(name))
+	return o
+}
+
+func TestPollOptions(t *testing.T) {
+	single := pub.QuestionNew("https://example.com/questions/1")
+	single.OneOf = pub.ItemCollection{answer("yes"), answer("no")}
+
+	options, multiple := PollOptions(single)
+	if multiple {
+		t.Errorf("oneOf Question should not allow multiple answers")
+	}
+	if len(options) != 2 || options[0] != "yes" || options[1] != "no" {
+		t.Errorf("invalid options %v", options)
+	}
+
+	multi := pub.QuestionNew("https://example.com/questions/2")
+	multi.AnyOf = pub.ItemCollection{answer("red"), answer("green"), answer("blue")}
+	options, multiple = PollOptions(multi)
+	if !multiple {
+		t.Errorf("anyOf Question should allow multiple answers")
+	}
+	if len(options) != 3 {
+		t.Errorf("invalid options %v", options)
+	}
+}
+
+func TestValidateVote(t *testing.T) {
+	q := pub.QuestionNew("https://example.com/questions/1")
+	q.OneOf = pub.ItemCollection{answer("yes"), answer("no")}
+
+	if err := ValidateVote(q, "yes"); err != nil {
+		t.Errorf("valid vote returned error: %s", err)
+	}
+	if err := ValidateVote(q, "maybe"); err == nil {
+		t.Errorf("vote for unknown option should fail")
+	}
+	if err := ValidateVote(pub.ObjectNew(pub.NoteType), "yes"); err == nil {
+		t.Errorf("vote on a non Question should fail")
+	}
+	q.Closed = true
+	if err := ValidateVote(q, "yes"); !errors.Is(err, ErrPollClosed) {
+		t.Errorf("vote on closed poll returned %v, expected %s", err, ErrPollClosed)
+	}
+}
@@ -0,0 +1,153 @@
+package postgres
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage"
+	"github.com/go-ap/storage/storagetest"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// testDSN returns the connection string of the test database, from the POSTGRES_DSN environment
+// variable, skipping the test if it's not set.
+func testDSN(t *testing.T) string {
+	dsn := os.Getenv("POSTGRES_DSN")
+	if len(dsn) == 0 {
+		t.Skip("POSTGRES_DSN is not set")
+	}
+	return dsn
+}
+
+// newTestRepo returns a storage on the emptied "dsn" database.
+func newTestRepo(t *testing.T, dsn string) *repo {
+	r, err := New(Config{DSN: dsn, CreateIfMissing: true})
+	if err != nil {
+		t.Fatalf("New returned error: %s", err)
+	}
+	for _, table := range []string{"members", "collections", "items"} {
+		if _, err := r.DB().Exec("DELETE FROM " + table); err != nil {
+			t.Fatalf("unable to empty the %s table: %s", table, err)
+		}
+	}
+	t.Cleanup(func() { r.Close() })
+	return r
+}
+
+func TestConformance(t *testing.T) {
+	dsn := testDSN(t)
+	storagetest.RunStoreTests(t, func() storage.Store { return newTestRepo(t, dsn) })
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(Config{}); !errors.Is(err, storage.ErrNotValid) {
+		t.Errorf("New without a DSN returned %v, expected %s", err, storage.ErrNotValid)
+	}
+	if _, err := New(Config{DSN: "postgres://jdoe@localhost:port/fedbox"}); !errors.Is(err, storage.ErrNotValid) {
+		t.Errorf("New with an invalid DSN returned %v, expected %s", err, storage.ErrNotValid)
+	}
+	if _, err := New(Config{DSN: "postgres://jdoe@127.0.0.1:1/fedbox?connect_timeout=1"}); err == nil {
+		t.Errorf("New of an unreachable database should fail")
+	}
+	start := time.Now()
+	if _, err := New(Config{DSN: "postgres://jdoe@192.0.2.1:5432/fedbox", Timeout: 100 * time.Millisecond}); err == nil {
+		t.Errorf("New of an unreachable database should fail")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("New failed after %s, expected it to stop waiting after the timeout", elapsed)
+	}
+}
+
+func TestBackupRestore(t *testing.T) {
+	dsn := testDSN(t)
+	r := newTestRepo(t, dsn)
+	outbox := pub.IRI("https://example.com/actors/jdoe/outbox")
+	ob := &pub.Object{ID: "https://example.com/objects/1", Type: pub.NoteType}
+	r.Save(ob)
+	r.Create(pub.OrderedCollectionNew(outbox))
+	r.AddTo(outbox, ob)
+
+	buf := bytes.Buffer{}
+	if err := storage.Backup(r, &buf); err != nil {
+		t.Fatalf("Backup returned error: %s", err)
+	}
+	restored := newTestRepo(t, dsn)
+	if err := storage.Restore(restored, bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("Restore returned error: %s", err)
+	}
+	it, err := restored.Load(outbox)
+	if err != nil {
+		t.Fatalf("Load returned error: %s", err)
+	}
+	col, ok := it.(*pub.OrderedCollection)
+	if !ok || len(col.OrderedItems) != 1 || col.OrderedItems[0].GetType() != pub.NoteType {
+		t.Errorf("Load after Restore returned %#v, expected the collection with the restored object", it)
+	}
+}
+
+func TestCockroach(t *testing.T) {
+	d := cockroach()
+	for _, stmt := range d.Schema {
+		if strings.Contains(stmt, "jsonb_path_ops") {
+			t.Errorf("the CockroachDB schema uses the jsonb_path_ops operator class: %s", stmt)
+		}
+	}
+	if len(d.Schema) != len(dialect.Schema) || !strings.Contains(dialect.Schema[4], "jsonb_path_ops") {
+		t.Errorf("cockroach should not change the PostgreSQL dialect")
+	}
+	aborted := fmt.Errorf("unable to commit: %w", &pgconn.PgError{Code: serializationFailure})
+	if !d.Retry(aborted) {
+		t.Errorf("the transactions aborted with serialization failures should be run again")
+	}
+	if d.Retry(&pgconn.PgError{Code: "23505"}) || d.Retry(errors.New("failed")) {
+		t.Errorf("the transactions failing with other errors should not be run again")
+	}
+}
+
+// TestConformance_cockroach runs the conformance tests on the CockroachDB database of the COCKROACH_DSN
+// environment variable, skipping them if it's not set.
+func TestConformance_cockroach(t *testing.T) {
+	dsn := os.Getenv("COCKROACH_DSN")
+	if len(dsn) == 0 {
+		t.Skip("COCKROACH_DSN is not set")
+	}
+	storagetest.RunStoreTests(t, func() storage.Store {
+		r, err := New(Config{DSN: dsn, CreateIfMissing: true, CockroachDB: true})
+		if err != nil {
+			t.Fatalf("New returned error: %s", err)
+		}
+		for _, table := range []string{"members", "collections", "items"} {
+			if _, err := r.DB().Exec("DELETE FROM " + table); err != nil {
+				t.Fatalf("unable to empty the %s table: %s", table, err)
+			}
+		}
+		t.Cleanup(func() { r.Close() })
+		return r
+	})
+}
+
+func TestStatementCache(t *testing.T) {
+	dsn := testDSN(t)
+	newTestRepo(t, dsn)
+	r, err := New(Config{DSN: dsn, StatementCache: -1, MaxOpenConns: 2, QueryTimeout: 5 * time.Second})
+	if err != nil {
+		t.Fatalf("New returned error: %s", err)
+	}
+	defer r.Close()
+	ob := &pub.Object{ID: "https://example.com/objects/1", Type: pub.NoteType}
+	if _, err := r.Save(ob); err != nil {
+		t.Fatalf("Save without prepared statements returned error: %s", err)
+	}
+	if it, err := r.Load(ob.ID); err != nil || it.GetType() != pub.NoteType {
+		t.Errorf("Load without prepared statements returned %v, %v, expected the object", it, err)
+	}
+	if n := r.DB().Stats().MaxOpenConnections; n != 2 {
+		t.Errorf("the pool allows %d connections, expected 2", n)
+	}
+}
@@ -0,0 +1,49 @@
+package storage
+
+import (
+	pub "github.com/go-ap/activitypub"
+)
+
+// Plan is a filter prepared by a backend, which took once the decisions depending only on the filter,
+// like the location of the matching items, or the index to use, so it can run the query many times,
+// eg: for loading the pages of an actor's inbox.
+type Plan interface {
+	// Each calls "fn" for each item matching the prepared filter, see IterateStore.
+	Each(fn func(pub.Item) error) error
+	// Page returns at most "max" members, of the collection identified by the prepared filter, that match it,
+	// starting after the "cursor" of a previous page, and the cursor of the next page, see PageStore.
+	Page(max int, cursor string) (pub.ItemCollection, string, error)
+}
+
+// PrepareStore can prepare filters, see Plan.
+type PrepareStore interface {
+	// Prepare returns the Plan for running the "f" filter, which shouldn't be a FilterablePage,
+	// as the pages are requested when running the plan.
+	Prepare(f Filterable) (Plan, error)
+}
+
+// Prepare returns the Plan for running the "f" filter on the "s" store, which is prepared by the store
+// if it's a PrepareStore. For the other stores the plan runs Each and LoadCollection every time.
+func Prepare(s ReadStore, f Filterable) (Plan, error) {
+	if ps, ok := s.(PrepareStore); ok {
+		return ps.Prepare(f)
+	}
+	return plan{s: s, f: f}, nil
+}
+
+type plan struct {
+	s ReadStore
+	f Filterable
+}
+
+func (p plan) Each(fn func(pub.Item) error) error {
+	return Each(p.s, p.f, fn)
+}
+
+func (p plan) Page(max int, cursor string) (pub.ItemCollection, string, error) {
+	items, _, err := LoadCollection(p.s, p.f.GetLink(), p.f)
+	if err != nil {
+		return nil, "", err
+	}
+	return Paginate(items, Page{IRI: p.f.GetLink(), Max: max, After: cursor})
+}
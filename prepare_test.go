@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+func TestPrepare(t *testing.T) {
+	s := newMapStore()
+	inbox := pub.IRI("https://example.com/actors/jdoe/inbox")
+	col := pub.OrderedCollectionNew(inbox)
+	for _, iri := range []pub.IRI{"https://example.com/objects/1", "https://example.com/objects/2", "https://example.com/objects/3"} {
+		col.OrderedItems.Append(note(iri, "hello"))
+	}
+	col.OrderedItems.Append(&pub.Activity{ID: "https://example.com/activities/1", Type: pub.LikeType})
+	s.Save(col)
+
+	p, err := Prepare(s, Filter{IRI: inbox, Type: pub.ActivityVocabularyTypes{pub.NoteType}})
+	if err != nil {
+		t.Fatalf("Prepare returned error: %s", err)
+	}
+	count := 0
+	p.Each(func(pub.Item) error {
+		count++
+		return nil
+	})
+	if count != 3 {
+		t.Errorf("Each iterated %d items, expected the 3 notes", count)
+	}
+
+	page, next, err := p.Page(2, "")
+	if err != nil {
+		t.Fatalf("Page returned error: %s", err)
+	}
+	if len(page) != 2 || page[0].GetLink() != "https://example.com/objects/3" || next == "" {
+		t.Errorf("Page returned %v, %q, expected the 2 most recent notes", page, next)
+	}
+	page, next, _ = p.Page(2, next)
+	if len(page) != 1 || page[0].GetLink() != "https://example.com/objects/1" || next != "" {
+		t.Errorf("Page returned %v, %q, expected the last note", page, next)
+	}
+}
@@ -0,0 +1,124 @@
+// Package prom exports the operations of a storage.InstrumentedStore, and the statistics of its backend,
+// as Prometheus metrics:
+//
+//	c := prom.New(s, prom.Options{Namespace: "fedbox", Sizes: true})
+//	prometheus.MustRegister(c)
+//	s = storage.Instrument(s, c.Observe)
+//
+// The applications which don't use Prometheus can publish the same operations with storage.Metrics and expvar.
+package prom
+
+import (
+	"errors"
+
+	pub "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Options holds the options of a Collector.
+type Options struct {
+	// Namespace is prepended to the names of the metrics, eg: "fedbox" for "fedbox_storage_operations_total".
+	Namespace string
+	// Sizes enables measuring the size of the loaded and saved items, which requires encoding them again.
+	Sizes bool
+	// Buckets are the upper bounds, in seconds, of the buckets of the durations of the operations,
+	// using prometheus.DefBuckets if not set.
+	Buckets []float64
+}
+
+// Collector is a prometheus.Collector of the operations of a storage.InstrumentedStore, which are reported
+// to its Observe method, and of the statistics of the backend, when it's a storage.StatsStore.
+// The statistics are read on each collection, eg: the state of the connection pool of the SQL backends.
+type Collector struct {
+	s         storage.ReadStore
+	measure   bool
+	ops       *prometheus.CounterVec
+	durations *prometheus.HistogramVec
+	sizes     *prometheus.HistogramVec
+	backend   *prometheus.Desc
+}
+
+var _ prometheus.Collector = &Collector{}
+
+// New returns a Collector of the statistics of "s", whose operations need to be reported to Observe.
+func New(s storage.ReadStore, o Options) *Collector {
+	buckets := o.Buckets
+	if len(buckets) == 0 {
+		buckets = prometheus.DefBuckets
+	}
+	return &Collector{
+		s:       s,
+		measure: o.Sizes,
+		ops: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: o.Namespace,
+			Subsystem: "storage",
+			Name:      "operations_total",
+			Help:      "The number of storage operations, by their result: ok, not_found, or error.",
+		}, []string{"op", "result"}),
+		durations: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: o.Namespace,
+			Subsystem: "storage",
+			Name:      "operation_duration_seconds",
+			Help:      "The time the storage operations took.",
+			Buckets:   buckets,
+		}, []string{"op"}),
+		sizes: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: o.Namespace,
+			Subsystem: "storage",
+			Name:      "item_size_bytes",
+			Help:      "The size of the JSON-LD documents of the loaded and saved items.",
+			Buckets:   prometheus.ExponentialBuckets(256, 4, 8),
+		}, []string{"op"}),
+		backend: prometheus.NewDesc(
+			prometheus.BuildFQName(o.Namespace, "storage", "backend"),
+			"The statistics reported by the storage backend, like the state of its connection pool.",
+			[]string{"stat"}, nil,
+		),
+	}
+}
+
+// Observe adds "o" to the metrics of its operation, it's the storage.ObserveFn of the InstrumentedStore.
+func (c *Collector) Observe(o storage.Observation) {
+	result := "ok"
+	switch {
+	case errors.Is(o.Err, storage.ErrNotFound):
+		result = "not_found"
+	case o.Err != nil:
+		result = "error"
+	}
+	c.ops.WithLabelValues(o.Op, result).Inc()
+	c.durations.WithLabelValues(o.Op).Observe(o.Duration.Seconds())
+	if c.measure && !pub.IsNil(o.Item) {
+		if raw, err := pub.MarshalJSON(o.Item); err == nil {
+			c.sizes.WithLabelValues(o.Op).Observe(float64(len(raw)))
+		}
+	}
+}
+
+// Describe sends the descriptions of the metrics to "ch".
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.ops.Describe(ch)
+	c.durations.Describe(ch)
+	c.sizes.Describe(ch)
+	ch <- c.backend
+}
+
+// Collect sends the metrics to "ch", reading the statistics of the backend. The statistics are skipped
+// when the backend fails to report them, eg: after being closed.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.ops.Collect(ch)
+	c.durations.Collect(ch)
+	c.sizes.Collect(ch)
+	ss, ok := c.s.(storage.StatsStore)
+	if !ok {
+		return
+	}
+	stats, err := ss.Stats()
+	if err != nil {
+		return
+	}
+	for name, v := range stats {
+		ch <- prometheus.MustNewConstMetric(c.backend, prometheus.GaugeValue, float64(v), name)
+	}
+}
@@ -0,0 +1,51 @@
+package prom
+
+import (
+	"strings"
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage"
+	"github.com/go-ap/storage/memory"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollector(t *testing.T) {
+	m := memory.New()
+	c := New(m, Options{Namespace: "fedbox", Sizes: true})
+	s := storage.Instrument(m, c.Observe)
+
+	ob := &pub.Object{ID: "https://example.com/objects/1", Type: pub.NoteType}
+	s.Save(ob)
+	s.Load(ob.ID)
+	s.Load("https://example.com/objects/2")
+
+	expected := `
+# HELP fedbox_storage_operations_total The number of storage operations, by their result: ok, not_found, or error.
+# TYPE fedbox_storage_operations_total counter
+fedbox_storage_operations_total{op="load",result="not_found"} 1
+fedbox_storage_operations_total{op="load",result="ok"} 1
+fedbox_storage_operations_total{op="save",result="ok"} 1
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected), "fedbox_storage_operations_total"); err != nil {
+		t.Errorf("unexpected operations: %s", err)
+	}
+	if n := testutil.CollectAndCount(c, "fedbox_storage_operation_duration_seconds"); n != 2 {
+		t.Errorf("expected the durations of 2 operations, got %d", n)
+	}
+	if n := testutil.CollectAndCount(c, "fedbox_storage_item_size_bytes"); n != 2 {
+		t.Errorf("expected the sizes of the loaded and saved items, got %d", n)
+	}
+	if n := testutil.CollectAndCount(c, "fedbox_storage_backend"); n == 0 {
+		t.Errorf("expected the statistics of the memory storage")
+	}
+	if err := prometheus.NewPedanticRegistry().Register(c); err != nil {
+		t.Errorf("unable to register the collector: %s", err)
+	}
+
+	m.Close()
+	if n := testutil.CollectAndCount(c, "fedbox_storage_backend"); n != 0 {
+		t.Errorf("expected no statistics of the closed storage, got %d", n)
+	}
+}
@@ -0,0 +1,196 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// The names of the counters holding the usage of an actor, see QuotaStore.
+const (
+	QuotaObjectsCounter = "quotaObjects"
+	QuotaBytesCounter   = "quotaBytes"
+)
+
+// Usage is the storage used by an actor.
+type Usage struct {
+	// Objects is the number of stored items attributed to the actor.
+	Objects int `json:"objects"`
+	// Bytes is the size of the JSON-LD documents of the items.
+	Bytes int `json:"bytes"`
+}
+
+// Quota limits the Usage of an actor. The zero values don't limit anything.
+type Quota struct {
+	MaxObjects int
+	MaxBytes   int
+}
+
+// exceededBy reports if "u" is beyond the quota.
+func (q Quota) exceededBy(u Usage) bool {
+	return (q.MaxObjects > 0 && u.Objects > q.MaxObjects) || (q.MaxBytes > 0 && u.Bytes > q.MaxBytes)
+}
+
+// QuotaFn is the type of the functions returning the Quota of an actor, which can return different
+// quotas for the local actors, eg: by their role, and no limits for the remote ones.
+type QuotaFn func(actor pub.IRI) Quota
+
+// OwnerOf returns the actor to which "it" is attributed: the actor of an activity, the attributedTo
+// property of an object, or the IRI of an actor itself. It returns an empty IRI for the other items.
+func OwnerOf(it pub.Item) pub.IRI {
+	if pub.IsNil(it) || pub.IsIRI(it) || pub.CollectionTypes.Contains(it.GetType()) {
+		return ""
+	}
+	if pub.ActorTypes.Contains(it.GetType()) {
+		return it.GetLink()
+	}
+	var owner pub.Item
+	if pub.ActivityTypes.Contains(it.GetType()) || pub.IntransitiveActivityTypes.Contains(it.GetType()) {
+		pub.OnIntransitiveActivity(it, func(a *pub.IntransitiveActivity) error {
+			owner = a.Actor
+			return nil
+		})
+	} else {
+		pub.OnObject(it, func(ob *pub.Object) error {
+			owner = ob.AttributedTo
+			return nil
+		})
+	}
+	if iris := links(owner); len(iris) > 0 {
+		return iris[0]
+	}
+	return ""
+}
+
+// QuotaStore is a Store that tracks the Usage of each actor, in counters of a CounterStore, and rejects
+// the saves which would take it beyond the Quota of the actor with ErrQuotaExceeded.
+// The items are attributed to the actors by OwnerOf. The saves are serialized, so the concurrent ones
+// can't exceed the quotas.
+type QuotaStore struct {
+	Store
+	counters CounterStore
+	quota    QuotaFn
+	mu       sync.Mutex
+}
+
+// Quotas returns a QuotaStore keeping the usage of the actors of "s" in "counters", which is usually
+// "s" itself, and limiting them to the quotas returned by "quota".
+func Quotas(s Store, counters CounterStore, quota QuotaFn) *QuotaStore {
+	return &QuotaStore{Store: s, counters: counters, quota: quota}
+}
+
+// Usage returns the current usage of "actor".
+func (q *QuotaStore) Usage(actor pub.IRI) (Usage, error) {
+	counters, err := q.counters.LoadCounters(actor)
+	if errors.Is(err, ErrNotFound) {
+		return Usage{}, nil
+	}
+	if err != nil {
+		return Usage{}, err
+	}
+	return Usage{Objects: counters[QuotaObjectsCounter], Bytes: counters[QuotaBytesCounter]}, nil
+}
+
+// usage returns the owner and the size of "it".
+func usage(it pub.Item) (pub.IRI, int, error) {
+	owner := OwnerOf(it)
+	if len(owner) == 0 {
+		return "", 0, nil
+	}
+	raw, err := pub.MarshalJSON(it)
+	return owner, len(raw), err
+}
+
+// stored returns the owner and the size of the stored version of "iri".
+func (q *QuotaStore) stored(iri pub.IRI) (pub.IRI, int, error) {
+	it, err := q.Store.Load(iri)
+	if errors.Is(err, ErrNotFound) {
+		return "", 0, nil
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	return usage(it)
+}
+
+func (q *QuotaStore) count(actor pub.IRI, objects, bytes int) error {
+	if len(actor) == 0 || (objects == 0 && bytes == 0) {
+		return nil
+	}
+	if _, err := q.counters.IncrementCounter(actor, QuotaObjectsCounter, objects); err != nil {
+		return fmt.Errorf("unable to update the usage of %s: %w", actor, err)
+	}
+	if _, err := q.counters.IncrementCounter(actor, QuotaBytesCounter, bytes); err != nil {
+		return fmt.Errorf("unable to update the usage of %s: %w", actor, err)
+	}
+	return nil
+}
+
+// Save saves "it", if the usage of its owner, with it replacing its stored version, is within its quota,
+// or else it returns an error wrapping ErrQuotaExceeded.
+func (q *QuotaStore) Save(it pub.Item) (pub.Item, error) {
+	owner, size, err := usage(it)
+	if err != nil {
+		return nil, err
+	}
+	if len(owner) == 0 {
+		return q.Store.Save(it)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	prevOwner, prevSize, err := q.stored(it.GetLink())
+	if err != nil {
+		return nil, err
+	}
+	u, err := q.Usage(owner)
+	if err != nil {
+		return nil, err
+	}
+	objects := 1
+	if prevOwner == owner {
+		objects, size = 0, size-prevSize
+	}
+	u.Objects += objects
+	u.Bytes += size
+	// the writes which don't increase the usage, like edits making an object smaller, are always accepted
+	if (objects > 0 || size > 0) && q.quota(owner).exceededBy(u) {
+		return nil, fmt.Errorf("%w: unable to save %s for %s", ErrQuotaExceeded, it.GetLink(), owner)
+	}
+	saved, err := q.Store.Save(it)
+	if err != nil {
+		return saved, err
+	}
+	if prevOwner != owner {
+		if err := q.count(prevOwner, -1, -prevSize); err != nil {
+			return saved, err
+		}
+	}
+	return saved, q.count(owner, objects, size)
+}
+
+// Delete deletes "it", and releases the usage of its owner.
+func (q *QuotaStore) Delete(it pub.Item) error {
+	if pub.IsNil(it) {
+		return q.Store.Delete(it)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	owner, size, err := q.stored(it.GetLink())
+	if err != nil {
+		return err
+	}
+	if err := q.Store.Delete(it); err != nil {
+		return err
+	}
+	if owner == it.GetLink() {
+		// the usage of a deleted actor doesn't matter anymore
+		return nil
+	}
+	return q.count(owner, -1, -size)
+}
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+func TestOwnerOf(t *testing.T) {
+	jdoe := pub.IRI("https://example.com/actors/jdoe")
+	ob := note("https://example.com/objects/1", "hello")
+	ob.AttributedTo = jdoe
+	tests := []struct {
+		name string
+		it   pub.Item
+		want pub.IRI
+	}{
+		{name: "actor", it: &pub.Actor{ID: jdoe, Type: pub.PersonType}, want: jdoe},
+		{name: "activity", it: &pub.Activity{ID: "https://example.com/activities/1", Type: pub.CreateType, Actor: jdoe}, want: jdoe},
+		{name: "object", it: ob, want: jdoe},
+		{name: "collection", it: pub.OrderedCollectionNew("https://example.com/actors/jdoe/outbox"), want: ""},
+		{name: "IRI", it: jdoe, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := OwnerOf(tt.it); got != tt.want {
+				t.Errorf("OwnerOf returned %q, expected %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuotas(t *testing.T) {
+	b := newBackendMapStore()
+	jdoe := pub.IRI("https://example.com/actors/jdoe")
+	s := Quotas(b, b, func(actor pub.IRI) Quota {
+		if actor == jdoe {
+			return Quota{MaxObjects: 2}
+		}
+		return Quota{}
+	})
+	first := note("https://example.com/objects/1", "hello")
+	first.AttributedTo = jdoe
+	create := &pub.Activity{ID: "https://example.com/activities/1", Type: pub.CreateType, Actor: jdoe, Object: first.ID}
+	for _, it := range []pub.Item{first, create} {
+		if _, err := s.Save(it); err != nil {
+			t.Fatalf("Save returned error: %s", err)
+		}
+	}
+	u, _ := s.Usage(jdoe)
+	if u.Objects != 2 || u.Bytes == 0 {
+		t.Errorf("Usage returned %+v, expected 2 objects", u)
+	}
+
+	second := note("https://example.com/objects/2", "hello")
+	second.AttributedTo = jdoe
+	if _, err := s.Save(second); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Save beyond the quota returned %v, expected %s", err, ErrQuotaExceeded)
+	}
+	edited := note(first.ID, "hi")
+	edited.AttributedTo = jdoe
+	if _, err := s.Save(edited); err != nil {
+		t.Errorf("Save of an existing object should not count as a new one, got %s", err)
+	}
+	if after, _ := s.Usage(jdoe); after.Objects != 2 || after.Bytes >= u.Bytes {
+		t.Errorf("Usage returned %+v after an edit making an object smaller, expected 2 objects and less than %d bytes", after, u.Bytes)
+	}
+	if err := s.Delete(first.ID); err != nil {
+		t.Fatalf("Delete returned error: %s", err)
+	}
+	if _, err := s.Save(second); err != nil {
+		t.Errorf("Save after a delete returned error: %s", err)
+	}
+	if after, _ := s.Usage(jdoe); after.Objects != 2 {
+		t.Errorf("Usage returned %+v, expected 2 objects", after)
+	}
+	other := note("https://example.com/objects/3", "hello")
+	other.AttributedTo = pub.IRI("https://example.com/actors/alice")
+	if _, err := s.Save(other); err != nil {
+		t.Errorf("Save for an actor without quota returned error: %s", err)
+	}
+}
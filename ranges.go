@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"fmt"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// FilterableRange can request the members of a collection between two of its members, like the
+// since_id and max_id parameters of the Mastodon API. The bounds are excluded, and are compared
+// by their position in the requested order, see SortOrderOf, which by default is the reverse
+// chronological order in which the members have been added.
+type FilterableRange interface {
+	Filterable
+	// SinceID returns the member before which the requested ones are, eg: the newest one a client has
+	// already seen. An empty IRI means no bound.
+	SinceID() pub.IRI
+	// MaxID returns the member after which the requested ones are, eg: the oldest one a client has
+	// already seen. An empty IRI means no bound.
+	MaxID() pub.IRI
+}
+
+// RangeBounds returns the positions, among "n" members of a collection, of the first member after
+// the MaxID of "f", and of its SinceID, so the requested members are the ones in [start:end].
+// The member at position "i" is returned by "member". If "f" isn't a FilterableRange, the range
+// covers all the members, and if a bound isn't a member, it returns an error wrapping ErrNotFound.
+func RangeBounds(n int, member func(i int) pub.IRI, f Filterable) (int, int, error) {
+	rf, ok := f.(FilterableRange)
+	if !ok {
+		return 0, n, nil
+	}
+	find := func(iri pub.IRI) (int, error) {
+		for i := 0; i < n; i++ {
+			if member(i) == iri {
+				return i, nil
+			}
+		}
+		return -1, fmt.Errorf("%w: %s is not a member of %s", ErrNotFound, iri, f.GetLink())
+	}
+	start, end := 0, n
+	if max := rf.MaxID(); len(max) > 0 {
+		i, err := find(max)
+		if err != nil {
+			return 0, 0, err
+		}
+		start = i + 1
+	}
+	if since := rf.SinceID(); len(since) > 0 {
+		i, err := find(since)
+		if err != nil {
+			return 0, 0, err
+		}
+		end = i
+	}
+	if end < start {
+		end = start
+	}
+	return start, end, nil
+}
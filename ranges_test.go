@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+func TestRangeBounds(t *testing.T) {
+	// the members of a collection in the order in which they've been added
+	members := make(pub.ItemCollection, 0)
+	for i := 1; i <= 6; i++ {
+		members = append(members, pub.IRI(fmt.Sprintf("https://example.com/objects/%d", i)))
+	}
+	col := pub.IRI("https://example.com/outbox")
+	iri := func(i int) pub.IRI {
+		return members[i-1].GetLink()
+	}
+
+	tests := []struct {
+		name string
+		page Page
+		want pub.IRIs
+	}{
+		{name: "no range", page: Page{IRI: col, Max: 2}, want: pub.IRIs{iri(6), iri(5)}},
+		{name: "since", page: Page{IRI: col, Since: iri(4)}, want: pub.IRIs{iri(6), iri(5)}},
+		{name: "until", page: Page{IRI: col, Max: 2, Until: iri(4)}, want: pub.IRIs{iri(3), iri(2)}},
+		{name: "between", page: Page{IRI: col, Since: iri(2), Until: iri(5)}, want: pub.IRIs{iri(4), iri(3)}},
+		{name: "empty", page: Page{IRI: col, Since: iri(5), Until: iri(3)}, want: pub.IRIs{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, _, err := OrderMembers(members, tt.page)
+			if err != nil {
+				t.Fatalf("OrderMembers returned error: %s", err)
+			}
+			if len(items) != len(tt.want) {
+				t.Fatalf("OrderMembers returned %v, expected %v", items, tt.want)
+			}
+			for i, it := range items {
+				if it.GetLink() != tt.want[i] {
+					t.Errorf("member %d is %s, expected %s", i, it.GetLink(), tt.want[i])
+				}
+			}
+		})
+	}
+
+	if _, _, err := OrderMembers(members, Page{IRI: col, Since: "https://example.com/objects/7"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("OrderMembers with a bound which isn't a member returned %v, expected %s", err, ErrNotFound)
+	}
+
+	reversed := pub.IRIs{iri(6), iri(5), iri(4), iri(3), iri(2), iri(1)}
+	page, err := CollectionPage(col, reversed, 6, Page{IRI: col, Max: 1, Since: iri(4)})
+	if err != nil {
+		t.Fatalf("CollectionPage returned error: %s", err)
+	}
+	if len(page.OrderedItems) != 1 || page.OrderedItems[0] != iri(6) || page.Next == nil || page.Last.GetLink() != page.Next.GetLink() {
+		t.Errorf("CollectionPage returned %v, with next %v and last %v, expected 2 pages", page.OrderedItems, page.Next, page.Last)
+	}
+	if want := PageIRI(col, 1, PageCursor(iri(6))) + "&since_id=https%3A%2F%2Fexample.com%2Fobjects%2F4"; page.Next.GetLink() != want {
+		t.Errorf("the next page is %s, expected %s", page.Next.GetLink(), want)
+	}
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	pub "github.com/go-ap/activitypub"
+)
+
+// ReadOnlyStore is a Store decorator that rejects all the writes with ErrReadOnly, for exposing
+// a store to code that must not modify it, like maintenance tools, or replicas serving GET requests.
+type ReadOnlyStore struct {
+	Store
+}
+
+// ReadOnly returns a read-only view of the "s" store.
+func ReadOnly(s Store) *ReadOnlyStore {
+	return &ReadOnlyStore{Store: s}
+}
+
+// Save returns ErrReadOnly.
+func (r *ReadOnlyStore) Save(pub.Item) (pub.Item, error) {
+	return nil, ErrReadOnly
+}
+
+// Delete returns ErrReadOnly.
+func (r *ReadOnlyStore) Delete(pub.Item) error {
+	return ErrReadOnly
+}
+
+// Create returns ErrReadOnly.
+func (r *ReadOnlyStore) Create(pub.CollectionInterface) (pub.CollectionInterface, error) {
+	return nil, ErrReadOnly
+}
+
+// AddTo returns ErrReadOnly.
+func (r *ReadOnlyStore) AddTo(pub.IRI, pub.Item) error {
+	return ErrReadOnly
+}
+
+// RemoveFrom returns ErrReadOnly.
+func (r *ReadOnlyStore) RemoveFrom(pub.IRI, pub.Item) error {
+	return ErrReadOnly
+}
+
+// Exists reports if "iri" is stored in the underlying store, see Exists.
+func (r *ReadOnlyStore) Exists(iri pub.IRI) (bool, error) {
+	return Exists(r.Store, iri)
+}
+
+// Each calls "fn" for every item of the underlying store matching "f", see Each.
+func (r *ReadOnlyStore) Each(f Filterable, fn func(pub.Item) error) error {
+	return Each(r.Store, f, fn)
+}
@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+func TestReadOnly(t *testing.T) {
+	s := newMapStore()
+	ob := note("https://example.com/objects/1", "hello")
+	s.Save(ob)
+
+	r := ReadOnly(s)
+	if it, err := r.Load(ob.ID); err != nil || contentOf(it) != "hello" {
+		t.Errorf("Load returned %v, %v, expected the stored object", it, err)
+	}
+	if ok, err := r.Exists(ob.ID); err != nil || !ok {
+		t.Errorf("Exists returned %t, %v, expected the object to exist", ok, err)
+	}
+
+	writes := map[string]func() error{
+		"Save": func() error {
+			_, err := r.Save(note(ob.ID, "changed"))
+			return err
+		},
+		"Delete": func() error { return r.Delete(ob) },
+		"Create": func() error {
+			_, err := r.Create(pub.OrderedCollectionNew("https://example.com/outbox"))
+			return err
+		},
+		"AddTo":      func() error { return r.AddTo("https://example.com/outbox", ob) },
+		"RemoveFrom": func() error { return r.RemoveFrom("https://example.com/outbox", ob) },
+	}
+	for name, write := range writes {
+		t.Run(name, func(t *testing.T) {
+			if err := write(); !errors.Is(err, ErrReadOnly) {
+				t.Errorf("%s returned %v, expected %s", name, err, ErrReadOnly)
+			}
+		})
+	}
+	if it, _ := s.Load(ob.ID); contentOf(it) != "hello" {
+		t.Errorf("the underlying store has been modified")
+	}
+}
@@ -0,0 +1,92 @@
+package redis
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	pub "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage"
+	"github.com/go-ap/storage/storagetest"
+)
+
+// newTestRepo returns a storage on a new in-memory server, which is stopped at the end of the test.
+func newTestRepo(t *testing.T, c Config) (*repo, *miniredis.Miniredis) {
+	srv := miniredis.RunT(t)
+	c.URL = "redis://" + srv.Addr()
+	r, err := New(c)
+	if err != nil {
+		t.Fatalf("New returned error: %s", err)
+	}
+	t.Cleanup(func() { r.Close() })
+	return r, srv
+}
+
+func TestConformance(t *testing.T) {
+	storagetest.RunStoreTests(t, func() storage.Store {
+		r, _ := newTestRepo(t, Config{Prefix: "fedbox:"})
+		return r
+	})
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(Config{}); !errors.Is(err, storage.ErrNotValid) {
+		t.Errorf("New without a URL returned %v, expected %s", err, storage.ErrNotValid)
+	}
+	if _, err := New(Config{URL: "http://localhost"}); !errors.Is(err, storage.ErrNotValid) {
+		t.Errorf("New with an invalid URL returned %v, expected %s", err, storage.ErrNotValid)
+	}
+	if _, err := New(Config{URL: "redis://127.0.0.1:1", Timeout: 100 * time.Millisecond}); err == nil {
+		t.Errorf("New of an unreachable server should fail")
+	}
+}
+
+func TestTTL(t *testing.T) {
+	r, srv := newTestRepo(t, Config{TTL: time.Minute})
+	ob := &pub.Object{ID: "https://example.com/objects/1", Type: pub.NoteType}
+	r.Save(ob)
+	outbox := pub.IRI("https://example.com/actors/jdoe/outbox")
+	r.Create(pub.OrderedCollectionNew(outbox))
+	r.AddTo(outbox, ob)
+
+	srv.FastForward(2 * time.Minute)
+	if _, err := r.Load(ob.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Load of an expired object returned %v, expected %s", err, storage.ErrNotFound)
+	}
+	if ok, err := r.IsMember(outbox, ob); err != nil || !ok {
+		t.Errorf("IsMember returned %t, %v, expected the collections not to expire", ok, err)
+	}
+}
+
+func TestIsMember(t *testing.T) {
+	r, _ := newTestRepo(t, Config{})
+	outbox := pub.IRI("https://example.com/actors/jdoe/outbox")
+	ob := pub.IRI("https://example.com/objects/1")
+	if _, err := r.IsMember(outbox, ob); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("IsMember of a missing collection returned %v, expected %s", err, storage.ErrNotFound)
+	}
+	r.Create(pub.OrderedCollectionNew(outbox))
+	if ok, err := r.IsMember(outbox, ob); err != nil || ok {
+		t.Errorf("IsMember returned %t, %v, expected false", ok, err)
+	}
+	r.AddTo(outbox, ob)
+	if ok, err := r.IsMember(outbox, ob); err != nil || !ok {
+		t.Errorf("IsMember returned %t, %v, expected true", ok, err)
+	}
+}
+
+func TestStats(t *testing.T) {
+	r, _ := newTestRepo(t, Config{})
+	stats, err := r.Stats()
+	if err != nil {
+		t.Fatalf("Stats returned error: %s", err)
+	}
+	if stats["total_connections"] < 1 {
+		t.Errorf("Stats returned %v, expected the connection opened by New", stats)
+	}
+	r.Close()
+	if _, err := r.Stats(); !errors.Is(err, storage.ErrClosed) {
+		t.Errorf("Stats after Close returned %v, expected %s", err, storage.ErrClosed)
+	}
+}
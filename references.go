@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// ReferencesCounter is the name of the counter holding the number of activities referencing an item.
+const ReferencesCounter = "references"
+
+// ReferenceStore can tell how many stored activities reference an item.
+type ReferenceStore interface {
+	// References returns the number of stored activities having "iri" as their object, or target.
+	References(iri pub.IRI) (int, error)
+}
+
+// ActivityReferences returns the IRIs of the items referenced by the "it" activity: its object and target.
+func ActivityReferences(it pub.Item) pub.IRIs {
+	iris := make(pub.IRIs, 0, 2)
+	if pub.IsNil(it) || !pub.ActivityTypes.Contains(it.GetType()) {
+		return iris
+	}
+	pub.OnActivity(it, func(a *pub.Activity) error {
+		for _, ref := range []pub.Item{a.Object, a.Target} {
+			iris = append(iris, links(ref)...)
+		}
+		return nil
+	})
+	return iris
+}
+
+// RefCountStore is a Store that counts the activities referencing each item, in the ReferencesCounter
+// of a CounterStore, and refuses to delete the items which are still referenced, eg: a remote object
+// shared by multiple local activities.
+type RefCountStore struct {
+	Store
+	counters CounterStore
+}
+
+var _ ReferenceStore = &RefCountStore{}
+
+// RefCount returns a RefCountStore keeping the reference counts of the items of "s" in "counters",
+// which is usually "s" itself.
+func RefCount(s Store, counters CounterStore) *RefCountStore {
+	return &RefCountStore{Store: s, counters: counters}
+}
+
+// References returns the number of activities referencing "iri".
+func (r *RefCountStore) References(iri pub.IRI) (int, error) {
+	counters, err := r.counters.LoadCounters(iri)
+	if errors.Is(err, ErrNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return counters[ReferencesCounter], nil
+}
+
+// stored returns the references of the stored version of "iri".
+func (r *RefCountStore) stored(iri pub.IRI) (pub.IRIs, error) {
+	it, err := r.Store.Load(iri)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ActivityReferences(it), nil
+}
+
+func (r *RefCountStore) count(iris pub.IRIs, delta int) error {
+	for _, iri := range iris {
+		if _, err := r.counters.IncrementCounter(iri, ReferencesCounter, delta); err != nil {
+			return fmt.Errorf("unable to count the references of %s: %w", iri, err)
+		}
+	}
+	return nil
+}
+
+// Save saves "it", and updates the reference counts of the items it references, and of the ones
+// that its previous version referenced.
+func (r *RefCountStore) Save(it pub.Item) (pub.Item, error) {
+	var old pub.IRIs
+	if !pub.IsNil(it) && pub.ActivityTypes.Contains(it.GetType()) {
+		var err error
+		if old, err = r.stored(it.GetLink()); err != nil {
+			return nil, err
+		}
+	}
+	saved, err := r.Store.Save(it)
+	if err != nil {
+		return saved, err
+	}
+	if err := r.count(ActivityReferences(it), 1); err != nil {
+		return saved, err
+	}
+	return saved, r.count(old, -1)
+}
+
+// Delete deletes "it", if it's not referenced by any activity, or else it returns an error wrapping
+// ErrConflict. The reference counts of the items it references get decremented.
+func (r *RefCountStore) Delete(it pub.Item) error {
+	if pub.IsNil(it) {
+		return r.Store.Delete(it)
+	}
+	iri := it.GetLink()
+	n, err := r.References(iri)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return fmt.Errorf("%w: %s is referenced by %d activities", ErrConflict, iri, n)
+	}
+	old, err := r.stored(iri)
+	if err != nil {
+		return err
+	}
+	if err := r.Store.Delete(it); err != nil {
+		return err
+	}
+	return r.count(old, -1)
+}
+
+// Unreferenced calls "fn" for each item matching "f" that is not referenced by any activity, see Each.
+func (r *RefCountStore) Unreferenced(f Filterable, fn func(pub.Item) error) error {
+	return Each(r.Store, f, func(it pub.Item) error {
+		n, err := r.References(it.GetLink())
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return nil
+		}
+		return fn(it)
+	})
+}
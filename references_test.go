@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+func TestRefCount(t *testing.T) {
+	b := newBackendMapStore()
+	s := RefCount(b, b)
+	shared := note("https://remote.example/objects/1", "shared")
+	shared.Published = time.Now().Add(-48 * time.Hour)
+	s.Save(shared)
+	announce := &pub.Activity{ID: "https://example.com/activities/1", Type: pub.AnnounceType, Object: shared.ID}
+	like := &pub.Activity{ID: "https://example.com/activities/2", Type: pub.LikeType, Object: shared.ID}
+	s.Save(announce)
+	s.Save(like)
+
+	if n, _ := s.References(shared.ID); n != 2 {
+		t.Errorf("References returned %d, expected 2", n)
+	}
+	if err := s.Delete(shared.ID); !errors.Is(err, ErrConflict) {
+		t.Errorf("Delete of a referenced object returned %v, expected %s", err, ErrConflict)
+	}
+	if report, _ := GC(s, GCOptions{Local: isLocal, Before: time.Now()}); len(report.Collected) != 0 {
+		t.Errorf("GC should keep the referenced objects, collected %v", report.Collected)
+	}
+
+	if err := s.Delete(announce.ID); err != nil {
+		t.Fatalf("Delete returned error: %s", err)
+	}
+	like = &pub.Activity{ID: like.ID, Type: pub.LikeType, Object: pub.IRI("https://remote.example/objects/2")}
+	s.Save(like)
+	if n, _ := s.References(shared.ID); n != 0 {
+		t.Errorf("References returned %d after the deletes, expected 0", n)
+	}
+	unreferenced := make(pub.IRIs, 0)
+	s.Unreferenced(pub.IRI(""), func(it pub.Item) error {
+		unreferenced = append(unreferenced, it.GetLink())
+		return nil
+	})
+	if !unreferenced.Contains(shared.ID) {
+		t.Errorf("Unreferenced should contain %s, got %v", shared.ID, unreferenced)
+	}
+	if err := s.Delete(shared.ID); err != nil {
+		t.Errorf("Delete of an unreferenced object returned %s", err)
+	}
+}
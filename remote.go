@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// Freshness is the caching metadata of an object fetched from a remote server.
+type Freshness struct {
+	// FetchedAt is the moment the object has been fetched.
+	FetchedAt time.Time
+	// ETag is the entity tag returned by the remote server, for revalidating the object.
+	ETag string
+	// MaxAge is how long the object can be used after it has been fetched.
+	MaxAge time.Duration
+}
+
+// Expires returns the moment after which the object needs to be fetched again.
+func (f Freshness) Expires() time.Time {
+	return f.FetchedAt.Add(f.MaxAge)
+}
+
+// Fresh reports if the object can still be used at the "now" moment.
+func (f Freshness) Fresh(now time.Time) bool {
+	return now.Before(f.Expires())
+}
+
+// FreshnessFromHeaders returns the freshness of an object fetched at the "fetched" moment, using
+// the ETag and the Cache-Control max-age of the "h" response headers. The max-age is zero if the response
+// is not cacheable, and "fallback" if the response doesn't specify it.
+func FreshnessFromHeaders(h http.Header, fetched time.Time, fallback time.Duration) Freshness {
+	f := Freshness{FetchedAt: fetched, ETag: h.Get("ETag"), MaxAge: fallback}
+	for _, directive := range strings.Split(h.Get("Cache-Control"), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch strings.ToLower(name) {
+		case "no-store", "no-cache":
+			return Freshness{FetchedAt: fetched, ETag: f.ETag}
+		case "max-age":
+			if secs, err := strconv.Atoi(strings.Trim(value, `"`)); err == nil && secs >= 0 {
+				f.MaxAge = time.Duration(secs) * time.Second
+			}
+		}
+	}
+	return f
+}
+
+// CacheStore keeps the objects fetched from remote servers, together with their Freshness,
+// separately from the local objects, for which the storage is authoritative.
+//
+// The cached objects are not returned by the Load of the ReadStore.
+type CacheStore interface {
+	// SaveRemote caches "it", which has been fetched from a remote server, replacing any previous version.
+	SaveRemote(it pub.Item, f Freshness) error
+	// LoadRemote returns the cached "iri" object, and its freshness, or an error wrapping ErrNotFound.
+	// Expired objects are returned until they're removed by Expire, so they can be revalidated using their ETag.
+	LoadRemote(iri pub.IRI) (pub.Item, Freshness, error)
+	// DeleteRemote removes the cached "iri" object.
+	DeleteRemote(iri pub.IRI) error
+	// Expire removes the cached objects that have expired before the "before" moment,
+	// and returns their number.
+	Expire(before time.Time) (uint, error)
+}
@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestFreshnessFromHeaders(t *testing.T) {
+	fetched := time.Date(2022, 5, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		cacheControl string
+		want         time.Duration
+	}{
+		{cacheControl: "", want: time.Hour},
+		{cacheControl: "public, max-age=300", want: 5 * time.Minute},
+		{cacheControl: "max-age=\"60\"", want: time.Minute},
+		{cacheControl: "max-age=invalid", want: time.Hour},
+		{cacheControl: "max-age=300, no-store", want: 0},
+		{cacheControl: "no-cache", want: 0},
+	}
+	for _, tt := range tests {
+		h := http.Header{}
+		h.Set("ETag", `"abc"`)
+		if len(tt.cacheControl) > 0 {
+			h.Set("Cache-Control", tt.cacheControl)
+		}
+		f := FreshnessFromHeaders(h, fetched, time.Hour)
+		if f.MaxAge != tt.want || f.ETag != `"abc"` || !f.FetchedAt.Equal(fetched) {
+			t.Errorf("FreshnessFromHeaders(%q) returned %+v, expected a max-age of %s", tt.cacheControl, f, tt.want)
+		}
+	}
+}
+
+func TestFreshness_Fresh(t *testing.T) {
+	fetched := time.Date(2022, 5, 1, 10, 0, 0, 0, time.UTC)
+	f := Freshness{FetchedAt: fetched, MaxAge: time.Minute}
+	if !f.Fresh(fetched.Add(30 * time.Second)) {
+		t.Errorf("the object should be fresh before its max-age")
+	}
+	if f.Fresh(fetched.Add(time.Minute)) {
+		t.Errorf("the object should be stale after its max-age")
+	}
+}
@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"fmt"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// RenameStore can change the IRI of a stored item, like an actor moving to another IRI,
+// atomically, together with everything the backend keeps under the IRI.
+type RenameStore interface {
+	// Rename moves the "old" item to the "new" IRI, replacing its ID, and the "old" members of
+	// the collections with "new", in the same positions. The counters, metadata and secondary
+	// indexes of the item are moved too. Renaming to an IRI which is already stored returns
+	// an error wrapping ErrConflict.
+	Rename(old, new pub.IRI) error
+}
+
+// Rename moves the "old" item of the "s" store to the "new" IRI, see RenameStore. For the stores
+// that are not RenameStores, it saves the item with the new ID, replaces it in the collections
+// containing it, in a single transaction if "s" is a TxStore, and then deletes the old item.
+// The collections are found like in Erase, and the item is added to them as their most recent member.
+//
+// The IRIs of the collections of the item, like the inbox of an actor, are kept, and so are the
+// references to the "old" IRI in the properties of the other items, which an AliasStore can resolve.
+func Rename(s Store, old, new pub.IRI) error {
+	if rs, ok := s.(RenameStore); ok {
+		return rs.Rename(old, new)
+	}
+	if len(old) == 0 || len(new) == 0 {
+		return fmt.Errorf("%w: unable to rename without an IRI", ErrNotValid)
+	}
+	if exists, err := Exists(s, new); err != nil || exists {
+		if err == nil {
+			err = fmt.Errorf("%w: %s already exists", ErrConflict, new)
+		}
+		return err
+	}
+	it, err := s.Load(old)
+	if err != nil {
+		return err
+	}
+	members := make(pub.IRIs, 0)
+	err = eachCollection(s, func(col pub.IRI) error {
+		iris, err := collectionMembers(s, col)
+		if err != nil {
+			return err
+		}
+		if iris.Contains(old) {
+			members = append(members, col)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := pub.OnObject(it, func(ob *pub.Object) error {
+		ob.ID = new
+		return nil
+	}); err != nil {
+		return fmt.Errorf("%w: unable to rename %s: %s", ErrNotValid, old, err)
+	}
+
+	rename := func(tx Store) error {
+		if _, err := tx.Save(it); err != nil {
+			return fmt.Errorf("unable to save %s: %w", new, err)
+		}
+		cs := asCollectionStore(tx)
+		for _, col := range members {
+			if err := cs.RemoveFrom(col, old); err != nil {
+				return fmt.Errorf("unable to remove %s from %s: %w", old, col, err)
+			}
+			if err := cs.AddTo(col, new); err != nil {
+				return fmt.Errorf("unable to add %s to %s: %w", new, col, err)
+			}
+		}
+		return tx.Delete(old)
+	}
+	if ts, ok := s.(TxStore); ok {
+		return ts.WithTx(rename)
+	}
+	return rename(s)
+}
+
+// eachCollection calls "fn" for the IRIs of the collections of the "s" store, which are found
+// as items, for the backends which iterate them, and as properties of the items.
+func eachCollection(s Store, fn func(pub.IRI) error) error {
+	seen := make(map[pub.IRI]struct{})
+	cols := make(pub.IRIs, 0)
+	collection := func(iri pub.IRI) {
+		if _, ok := seen[iri]; !ok {
+			seen[iri] = struct{}{}
+			cols = append(cols, iri)
+		}
+	}
+	err := Each(s, pub.IRI(""), func(it pub.Item) error {
+		if pub.CollectionTypes.Contains(it.GetType()) {
+			collection(it.GetLink())
+			return nil
+		}
+		for _, col := range CollectionsOf(it) {
+			collection(col)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, col := range cols {
+		if err := fn(col); err != nil {
+			return err
+		}
+	}
+	return nil
+}
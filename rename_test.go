@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+func TestRename(t *testing.T) {
+	s := newBackendMapStore()
+	old, renamed := pub.IRI("https://old.example/actors/jdoe"), pub.IRI("https://example.com/actors/jdoe")
+	followers := pub.IRI("https://example.com/actors/alice/followers")
+	s.Save(&pub.Actor{ID: old, Type: pub.PersonType, Followers: followers})
+	s.Save(note("https://example.com/objects/1", "hello"))
+	s.Create(pub.OrderedCollectionNew(followers))
+	s.AddTo(followers, pub.IRI("https://example.com/actors/bob"))
+	s.AddTo(followers, old)
+
+	if err := Rename(s, old, "https://example.com/objects/1"); !errors.Is(err, ErrConflict) {
+		t.Errorf("Rename to an existing IRI returned %v, expected %s", err, ErrConflict)
+	}
+	if err := Rename(s, old, ""); !errors.Is(err, ErrNotValid) {
+		t.Errorf("Rename to an empty IRI returned %v, expected %s", err, ErrNotValid)
+	}
+	if err := Rename(s, old, renamed); err != nil {
+		t.Fatalf("Rename returned error: %s", err)
+	}
+	if s.txs != 1 {
+		t.Errorf("Rename should use a single transaction, used %d", s.txs)
+	}
+	if _, err := s.Load(old); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Load of the old IRI returned %v, expected %s", err, ErrNotFound)
+	}
+	it, err := s.Load(renamed)
+	if err != nil || it.GetLink() != renamed || it.GetType() != pub.PersonType {
+		t.Errorf("Load of the new IRI returned %v, %v", it, err)
+	}
+	if members := s.members[followers]; len(members) != 2 || members[1] != renamed || members.Contains(old) {
+		t.Errorf("Rename should replace %s with %s in %s, got %v", old, renamed, followers, members)
+	}
+}
+
+// renamingStore is a backendMapStore that is also a RenameStore.
+type renamingStore struct {
+	*backendMapStore
+	renamed map[pub.IRI]pub.IRI
+}
+
+func (r renamingStore) Rename(old, new pub.IRI) error {
+	r.renamed[old] = new
+	return nil
+}
+
+func TestRename_RenameStore(t *testing.T) {
+	s := renamingStore{backendMapStore: newBackendMapStore(), renamed: make(map[pub.IRI]pub.IRI)}
+	if err := Rename(s, "https://old.example/actors/jdoe", "https://example.com/actors/jdoe"); err != nil {
+		t.Fatalf("Rename returned error: %s", err)
+	}
+	if s.renamed["https://old.example/actors/jdoe"] != "https://example.com/actors/jdoe" {
+		t.Errorf("Rename should use the RenameStore, got %v", s.renamed)
+	}
+}
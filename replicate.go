@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// ReplicaError is the failure of a write on one of the stores of a ReplicatedStore.
+type ReplicaError struct {
+	// Index is the position of the store in the arguments of Replicate.
+	Index int
+	Err   error
+}
+
+// ReplicationError reports the stores of a ReplicatedStore on which a write failed.
+// The write has been applied to the other stores.
+type ReplicationError struct {
+	Op     string
+	IRI    pub.IRI
+	Failed []ReplicaError
+	// Total is the number of stores to which the write has been applied, or attempted.
+	Total int
+}
+
+func (e *ReplicationError) Error() string {
+	errs := make([]string, 0, len(e.Failed))
+	for _, f := range e.Failed {
+		errs = append(errs, fmt.Sprintf("store %d: %s", f.Index, f.Err))
+	}
+	return fmt.Sprintf("unable to %s %s on %d of %d stores: %s", e.Op, e.IRI, len(e.Failed), e.Total, strings.Join(errs, "; "))
+}
+
+// Is reports if any of the failures matches "target".
+func (e *ReplicationError) Is(target error) bool {
+	for _, f := range e.Failed {
+		if errors.Is(f.Err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Partial reports if the write has been applied to at least one of the stores.
+func (e *ReplicationError) Partial() bool {
+	return len(e.Failed) < e.Total
+}
+
+// ReplicatedStore is a Store that applies every write synchronously to multiple stores, eg: for keeping
+// a live backup mirror. Unlike TeeStore, the failures of any of the stores are returned to the caller,
+// as a *ReplicationError.
+//
+// Reads are served by the first store.
+type ReplicatedStore struct {
+	stores []Store
+}
+
+var (
+	_ Store           = &ReplicatedStore{}
+	_ CollectionStore = &ReplicatedStore{}
+)
+
+// Replicate returns a ReplicatedStore writing to all the "stores", in order, and reading from the first one.
+func Replicate(first Store, others ...Store) *ReplicatedStore {
+	return &ReplicatedStore{stores: append([]Store{first}, others...)}
+}
+
+// Close closes all the stores, and returns their errors joined.
+func (r *ReplicatedStore) Close() error {
+	errs := make([]error, 0, len(r.stores))
+	for _, s := range r.stores {
+		errs = append(errs, s.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// write calls "fn" for each store, and returns the failures as a *ReplicationError.
+func (r *ReplicatedStore) write(op string, iri pub.IRI, fn func(int, Store) error) error {
+	var failed []ReplicaError
+	for i, s := range r.stores {
+		if err := fn(i, s); err != nil {
+			failed = append(failed, ReplicaError{Index: i, Err: err})
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return &ReplicationError{Op: op, IRI: iri, Failed: failed, Total: len(r.stores)}
+}
+
+// Load loads the "iri" item from the first store.
+func (r *ReplicatedStore) Load(iri pub.IRI) (pub.Item, error) {
+	return r.stores[0].Load(iri)
+}
+
+// Save saves "it" in all the stores, and returns the item saved by the first one.
+func (r *ReplicatedStore) Save(it pub.Item) (pub.Item, error) {
+	var saved pub.Item
+	err := r.write("save", linkOf(it), func(i int, s Store) error {
+		res, err := s.Save(it)
+		if i == 0 {
+			saved = res
+		}
+		return err
+	})
+	return saved, err
+}
+
+// Delete deletes "it" from all the stores.
+func (r *ReplicatedStore) Delete(it pub.Item) error {
+	return r.write("delete", linkOf(it), func(_ int, s Store) error {
+		return s.Delete(it)
+	})
+}
+
+// Create creates the "col" collection in all the stores, which need to be CollectionStores, and returns
+// the collection created by the first one.
+func (r *ReplicatedStore) Create(col pub.CollectionInterface) (pub.CollectionInterface, error) {
+	var created pub.CollectionInterface
+	err := r.write("create", linkOf(col), func(i int, s Store) error {
+		res, err := asCollectionStore(s).Create(col)
+		if i == 0 {
+			created = res
+		}
+		return err
+	})
+	return created, err
+}
+
+// AddTo adds "it" to the "col" collection in all the stores, which need to be CollectionStores.
+func (r *ReplicatedStore) AddTo(col pub.IRI, it pub.Item) error {
+	return r.write("add to", col, func(_ int, s Store) error {
+		return asCollectionStore(s).AddTo(col, it)
+	})
+}
+
+// RemoveFrom removes "it" from the "col" collection in all the stores, which need to be CollectionStores.
+func (r *ReplicatedStore) RemoveFrom(col pub.IRI, it pub.Item) error {
+	return r.write("remove from", col, func(_ int, s Store) error {
+		return asCollectionStore(s).RemoveFrom(col, it)
+	})
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// failingSaveStore is a mapStore whose Save calls fail with "err".
+type failingSaveStore struct {
+	*mapStore
+	err error
+}
+
+func (f failingSaveStore) Save(pub.Item) (pub.Item, error) {
+	return nil, f.err
+}
+
+func TestReplicatedStore(t *testing.T) {
+	first, second := newMapStore(), newMapStore()
+	r := Replicate(first, second)
+
+	ob := note("https://example.com/objects/1", "hello")
+	if _, err := r.Save(ob); err != nil {
+		t.Fatalf("Save returned error: %s", err)
+	}
+	for i, s := range []*mapStore{first, second} {
+		if it, err := s.Load(ob.ID); err != nil || contentOf(it) != "hello" {
+			t.Errorf("store %d returned %v, %v, expected the saved object", i, it, err)
+		}
+	}
+	if err := r.Delete(ob); err != nil {
+		t.Fatalf("Delete returned error: %s", err)
+	}
+	if len(first.items) != 0 || len(second.items) != 0 {
+		t.Errorf("Delete should remove the object from all the stores")
+	}
+	if err := r.AddTo("https://example.com/outbox", ob); err == nil {
+		t.Errorf("AddTo should fail for stores that are not CollectionStores")
+	}
+}
+
+func TestReplicatedStore_partialFailure(t *testing.T) {
+	errFull := errors.New("disk full")
+	first := newMapStore()
+	r := Replicate(first, failingSaveStore{mapStore: newMapStore(), err: errFull})
+
+	ob := note("https://example.com/objects/1", "hello")
+	saved, err := r.Save(ob)
+	var rerr *ReplicationError
+	if !errors.As(err, &rerr) {
+		t.Fatalf("Save returned %v, expected a *ReplicationError", err)
+	}
+	if !rerr.Partial() || len(rerr.Failed) != 1 || rerr.Failed[0].Index != 1 {
+		t.Errorf("Save returned %+v, expected the second store to fail", rerr)
+	}
+	if !errors.Is(err, errFull) {
+		t.Errorf("the ReplicationError should match the error of the failed store")
+	}
+	if pub.IsNil(saved) {
+		t.Errorf("Save should return the item saved by the first store")
+	}
+	if _, err := first.Load(ob.ID); err != nil {
+		t.Errorf("the object should be saved in the first store: %s", err)
+	}
+}
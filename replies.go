@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"sort"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// Thread is an object together with the tree of its replies.
+type Thread struct {
+	Item pub.Item
+	// Replies holds the objects that are inReplyTo Item, oldest first.
+	Replies []*Thread
+}
+
+// ReplyStore is implemented by backends that can load a conversation in one operation, usually
+// using the IndexInReplyTo secondary index.
+type ReplyStore interface {
+	// LoadReplies returns the "iri" object with the tree of its replies, up to "depth" levels deep.
+	// A "depth" lower than 1 doesn't limit the levels of replies.
+	LoadReplies(iri pub.IRI, depth int) (*Thread, error)
+}
+
+// LoadReplies returns the thread of replies to the "iri" object stored in "s", see ReplyStore.
+// For stores that are not ReplyStores, the replies are found using the IndexInReplyTo index
+// of IndexStores, or by iterating the objects with the inReplyTo property set to each of the parents.
+func LoadReplies(s ReadStore, iri pub.IRI, depth int) (*Thread, error) {
+	if rs, ok := s.(ReplyStore); ok {
+		return rs.LoadReplies(iri, depth)
+	}
+	it, err := s.Load(iri)
+	if err != nil {
+		return nil, err
+	}
+	replies := func(parent pub.IRI) (pub.ItemCollection, error) {
+		result := make(pub.ItemCollection, 0)
+		if is, ok := s.(IndexStore); ok {
+			iris, err := is.LoadIndex(IndexInReplyTo, parent.String())
+			if err != nil {
+				return nil, err
+			}
+			for _, iri := range iris {
+				if it, err := s.Load(iri); err == nil {
+					result = append(result, it)
+				}
+			}
+			return result, nil
+		}
+		err := Each(s, Filter{Parent: pub.IRIs{parent}}, func(it pub.Item) error {
+			result = append(result, it)
+			return nil
+		})
+		return result, err
+	}
+	return BuildThread(it, depth, replies)
+}
+
+// BuildThread returns the thread rooted in "root", using "replies" to find the direct replies of each
+// object, up to "depth" levels deep, see ReplyStore.
+// Objects that are replies of their own replies are included only once.
+func BuildThread(root pub.Item, depth int, replies func(parent pub.IRI) (pub.ItemCollection, error)) (*Thread, error) {
+	seen := map[pub.IRI]bool{root.GetLink(): true}
+	var build func(t *Thread, level int) error
+	build = func(t *Thread, level int) error {
+		if depth > 0 && level > depth {
+			return nil
+		}
+		items, err := replies(t.Item.GetLink())
+		if err != nil {
+			return err
+		}
+		sort.SliceStable(items, func(i, j int) bool {
+			return published(items[i]).Before(published(items[j]))
+		})
+		for _, it := range items {
+			if pub.IsNil(it) || seen[it.GetLink()] {
+				continue
+			}
+			seen[it.GetLink()] = true
+			reply := &Thread{Item: it}
+			if err := build(reply, level+1); err != nil {
+				return err
+			}
+			t.Replies = append(t.Replies, reply)
+		}
+		return nil
+	}
+	t := &Thread{Item: root}
+	return t, build(t, 1)
+}
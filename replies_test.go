@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// indexedMapStore is a mapStore that implements the IndexInReplyTo index of IndexStore.
+type indexedMapStore struct {
+	*mapStore
+}
+
+func (m indexedMapStore) LoadIndex(index, key string) (pub.IRIs, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	iris := make(pub.IRIs, 0)
+	for iri, it := range m.items {
+		for _, k := range IndexKeys(it)[index] {
+			if k == key {
+				iris = append(iris, iri)
+			}
+		}
+	}
+	return iris, nil
+}
+
+func reply(iri, parent pub.IRI, published time.Time) *pub.Object {
+	ob := note(iri, "reply")
+	ob.InReplyTo = parent
+	ob.Published = published
+	return ob
+}
+
+// threadIRIs flattens the thread, depth first, to the IRIs of its items, with their levels.
+func threadIRIs(t *Thread, level int) []string {
+	result := []string{fmt.Sprintf("%d %s", level, t.Item.GetLink())}
+	for _, r := range t.Replies {
+		result = append(result, threadIRIs(r, level+1)...)
+	}
+	return result
+}
+
+func TestLoadReplies(t *testing.T) {
+	now := time.Now()
+	s := indexedMapStore{newMapStore()}
+	root := note("https://example.com/objects/1", "hello")
+	s.Save(root)
+	s.Save(reply("https://example.com/objects/3", root.ID, now.Add(2*time.Minute)))
+	s.Save(reply("https://example.com/objects/2", root.ID, now.Add(time.Minute)))
+	s.Save(reply("https://example.com/objects/4", "https://example.com/objects/2", now.Add(3*time.Minute)))
+	s.Save(reply("https://example.com/objects/5", "https://example.com/objects/4", now.Add(4*time.Minute)))
+
+	tests := []struct {
+		name  string
+		depth int
+		want  []string
+	}{
+		{
+			name:  "unlimited",
+			depth: 0,
+			want: []string{
+				"0 https://example.com/objects/1",
+				"1 https://example.com/objects/2",
+				"2 https://example.com/objects/4",
+				"3 https://example.com/objects/5",
+				"1 https://example.com/objects/3",
+			},
+		},
+		{
+			name:  "direct replies",
+			depth: 1,
+			want: []string{
+				"0 https://example.com/objects/1",
+				"1 https://example.com/objects/2",
+				"1 https://example.com/objects/3",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			thread, err := LoadReplies(s, root.ID, tt.depth)
+			if err != nil {
+				t.Fatalf("LoadReplies returned error: %s", err)
+			}
+			got := threadIRIs(thread, 0)
+			if len(got) != len(tt.want) {
+				t.Fatalf("LoadReplies returned %v, expected %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("LoadReplies returned %s at position %d, expected %s", got[i], i, tt.want[i])
+				}
+			}
+		})
+	}
+
+	if _, err := LoadReplies(s, "https://example.com/missing", 0); !errors.Is(err, ErrNotFound) {
+		t.Errorf("LoadReplies of a missing object returned %v, expected %s", err, ErrNotFound)
+	}
+}
+
+func TestBuildThread_cycles(t *testing.T) {
+	a := reply("https://example.com/objects/a", "https://example.com/objects/b", time.Now())
+	b := reply("https://example.com/objects/b", "https://example.com/objects/a", time.Now())
+	replies := func(parent pub.IRI) (pub.ItemCollection, error) {
+		if parent == a.ID {
+			return pub.ItemCollection{b}, nil
+		}
+		return pub.ItemCollection{a}, nil
+	}
+	thread, err := BuildThread(a, 0, replies)
+	if err != nil {
+		t.Fatalf("BuildThread returned error: %s", err)
+	}
+	if got := threadIRIs(thread, 0); len(got) != 2 {
+		t.Errorf("BuildThread returned %v, expected each object only once", got)
+	}
+}
@@ -0,0 +1,203 @@
+package rest
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	pub "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage"
+)
+
+// maxBodySize is the size limit of the documents accepted by the Handler.
+const maxBodySize = 10 << 20
+
+// Handler returns the http.Handler serving the "s" store over the protocol used by Client, for the requests
+// authenticated with the "token" bearer token, all the requests are rejected when "token" is empty.
+// The collection endpoints need "s" to be a storage.CollectionStore.
+// It can be served under a path prefix using http.StripPrefix.
+func Handler(s storage.Store, token string) http.Handler {
+	h := &handler{s: s, token: token}
+	mux := http.NewServeMux()
+	mux.HandleFunc(ItemsPath, h.items)
+	mux.HandleFunc(CollectionsPath, h.collections)
+	mux.HandleFunc(MembersPath, h.members)
+	return h.authenticated(mux)
+}
+
+type handler struct {
+	s     storage.Store
+	token string
+}
+
+func (h *handler) authenticated(next http.Handler) http.Handler {
+	expected := []byte("Bearer " + h.token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(h.token) == 0 || subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *handler) items(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		it, err := h.s.Load(pub.IRI(r.URL.Query().Get("iri")))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeItem(w, http.StatusOK, it)
+	case http.MethodPut:
+		it, err := readItem(r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		saved, err := h.s.Save(it)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if pub.IsNil(saved) {
+			saved = it
+		}
+		writeItem(w, http.StatusOK, saved)
+	case http.MethodDelete:
+		if err := h.s.Delete(pub.IRI(r.URL.Query().Get("iri"))); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		notAllowed(w, http.MethodGet, http.MethodPut, http.MethodDelete)
+	}
+}
+
+func (h *handler) collectionStore(w http.ResponseWriter) (storage.CollectionStore, bool) {
+	cs, ok := h.s.(storage.CollectionStore)
+	if !ok {
+		http.Error(w, "the storage doesn't support collections", http.StatusNotImplemented)
+	}
+	return cs, ok
+}
+
+func (h *handler) collections(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		notAllowed(w, http.MethodPut)
+		return
+	}
+	cs, ok := h.collectionStore(w)
+	if !ok {
+		return
+	}
+	it, err := readItem(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	col, ok := it.(pub.CollectionInterface)
+	if !ok {
+		writeError(w, fmt.Errorf("%w: %s is not a collection", storage.ErrNotValid, it.GetLink()))
+		return
+	}
+	created, err := cs.Create(col)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeItem(w, http.StatusOK, created)
+}
+
+func (h *handler) members(w http.ResponseWriter, r *http.Request) {
+	cs, ok := h.collectionStore(w)
+	if !ok {
+		return
+	}
+	col := pub.IRI(r.URL.Query().Get("iri"))
+	var err error
+	switch r.Method {
+	case http.MethodPost:
+		var it pub.Item
+		if it, err = readItem(r); err == nil {
+			err = cs.AddTo(col, it)
+		}
+	case http.MethodDelete:
+		err = cs.RemoveFrom(col, pub.IRI(r.URL.Query().Get("member")))
+	default:
+		notAllowed(w, http.MethodPost, http.MethodDelete)
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func readItem(r *http.Request) (pub.Item, error) {
+	raw, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize))
+	if err != nil {
+		return nil, err
+	}
+	it, err := pub.UnmarshalJSON(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w document: %s", storage.ErrNotValid, err)
+	}
+	if pub.IsNil(it) {
+		return nil, fmt.Errorf("%w: empty document", storage.ErrNotValid)
+	}
+	return it, nil
+}
+
+func writeItem(w http.ResponseWriter, status int, it pub.Item) {
+	raw, err := pub.MarshalJSON(it)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(status)
+	w.Write(raw)
+}
+
+// statuses maps the kinds of errors of the storage package to the HTTP statuses sent for them.
+var statuses = []struct {
+	err    error
+	status int
+}{
+	{err: storage.ErrNotFound, status: http.StatusNotFound},
+	{err: storage.ErrGone, status: http.StatusGone},
+	{err: storage.ErrConflict, status: http.StatusConflict},
+	{err: storage.ErrNotValid, status: http.StatusBadRequest},
+	{err: storage.ErrReadOnly, status: http.StatusForbidden},
+	{err: storage.ErrClosed, status: http.StatusServiceUnavailable},
+}
+
+// writeError sends the status matching the kind of "err", with its message for the client errors only,
+// so the details of the failures of the backend, like the addresses of its servers, are not disclosed.
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	for _, s := range statuses {
+		if errors.Is(err, s.err) {
+			status = s.status
+			break
+		}
+	}
+	msg := err.Error()
+	if status >= http.StatusInternalServerError {
+		msg = http.StatusText(status)
+	}
+	http.Error(w, msg, status)
+}
+
+func notAllowed(w http.ResponseWriter, methods ...string) {
+	for _, m := range methods {
+		w.Header().Add("Allow", m)
+	}
+	http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+}
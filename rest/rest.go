@@ -0,0 +1,216 @@
+// Package rest implements a storage backend that forwards the operations over HTTP to a remote store,
+// served by Handler, so the storage can run in a separate process, or host, than the ActivityPub service.
+//
+// The protocol has the following endpoints, with the IRIs passed as query parameters, the items as
+// JSON-LD documents, and the requests authenticated with a bearer token:
+//
+//   - GET /items?iri=IRI loads an item.
+//   - PUT /items saves the item in the body, and returns it.
+//   - DELETE /items?iri=IRI deletes an item.
+//   - PUT /collections creates the collection in the body, and returns it.
+//   - POST /collections/members?iri=IRI adds the item in the body to a collection.
+//   - DELETE /collections/members?iri=IRI&member=IRI removes an item from a collection.
+//
+// The errors are sent as plain text, with the status matching their kind, eg: 404 for storage.ErrNotFound.
+package rest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+
+	pub "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage"
+)
+
+// The paths of the endpoints of the protocol.
+const (
+	ItemsPath       = "/items"
+	CollectionsPath = "/collections"
+	MembersPath     = "/collections/members"
+)
+
+// ContentType is the media type of the documents exchanged by the Client and the Handler.
+const ContentType = `application/ld+json; profile="https://www.w3.org/ns/activitystreams"`
+
+// Config holds the options for a Client.
+type Config struct {
+	// URL is the base URL of the Handler.
+	URL string
+	// Token is the bearer token authenticating the requests.
+	Token string
+	// Client does the requests, using one with its own connections if not set, which Close releases.
+	Client *http.Client
+}
+
+// Client is a storage.Store, and storage.CollectionStore, forwarding the operations to a remote Handler.
+type Client struct {
+	base  *url.URL
+	token string
+	c     *http.Client
+	// owned is set if the Client created "c", and can close its connections.
+	owned bool
+	// closed is set to 1 by Close.
+	closed int32
+}
+
+var (
+	_ storage.Store           = &Client{}
+	_ storage.CollectionStore = &Client{}
+)
+
+// New returns a Client for the Handler at c.URL.
+func New(c Config) (*Client, error) {
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return nil, fmt.Errorf("%w URL %s: %s", storage.ErrNotValid, c.URL, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("%w URL %q: the scheme needs to be http or https", storage.ErrNotValid, c.URL)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	if c.Client == nil {
+		hc := &http.Client{Transport: http.DefaultTransport.(*http.Transport).Clone()}
+		return &Client{base: u, token: c.Token, c: hc, owned: true}, nil
+	}
+	return &Client{base: u, token: c.Token, c: c.Client}, nil
+}
+
+// Close closes the Client, after which its operations return storage.ErrClosed. The idle connections
+// of the http.Client are closed only if it's not the one of the Config, which can be shared.
+func (c *Client) Close() error {
+	if atomic.SwapInt32(&c.closed, 1) == 1 {
+		return nil
+	}
+	if c.owned {
+		c.c.CloseIdleConnections()
+	}
+	return nil
+}
+
+func (c *Client) url(p string, query url.Values) string {
+	u := *c.base
+	u.Path += p
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// do sends the request, with "it" as body if it's not nil, and returns the item in the response body, if any.
+func (c *Client) do(method, p string, query url.Values, it pub.Item) (pub.Item, error) {
+	if atomic.LoadInt32(&c.closed) == 1 {
+		return nil, storage.ErrClosed
+	}
+	var body io.Reader
+	if it != nil {
+		raw, err := pub.MarshalJSON(it)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequest(method, c.url(p, query), body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Accept", ContentType)
+	if body != nil {
+		req.Header.Set("Content-Type", ContentType)
+	}
+	res, err := c.c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	raw, err := io.ReadAll(io.LimitReader(res.Body, maxBodySize))
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= http.StatusBadRequest {
+		return nil, responseError(res.StatusCode, raw)
+	}
+	if res.StatusCode == http.StatusNoContent {
+		return nil, nil
+	}
+	return pub.UnmarshalJSON(raw)
+}
+
+// responseError returns the error for the "status" of a failed response, wrapping the matching error
+// of the storage package, if any.
+func responseError(status int, body []byte) error {
+	msg := strings.TrimSpace(string(body))
+	for _, s := range statuses {
+		if s.status != status {
+			continue
+		}
+		err := s.err
+		if status == http.StatusNotFound {
+			err = storage.ErrNotFound
+		}
+		return fmt.Errorf("%w: %s", err, msg)
+	}
+	return fmt.Errorf("%s: %s", http.StatusText(status), msg)
+}
+
+// Load returns the "iri" item from the remote store.
+func (c *Client) Load(iri pub.IRI) (pub.Item, error) {
+	return c.do(http.MethodGet, ItemsPath, url.Values{"iri": {iri.String()}}, nil)
+}
+
+// Save saves "it" to the remote store, and returns it as saved.
+func (c *Client) Save(it pub.Item) (pub.Item, error) {
+	if pub.IsNil(it) {
+		return nil, fmt.Errorf("%w: nil item", storage.ErrNotValid)
+	}
+	return c.do(http.MethodPut, ItemsPath, nil, it)
+}
+
+// Delete deletes "it" from the remote store.
+func (c *Client) Delete(it pub.Item) error {
+	if pub.IsNil(it) {
+		return fmt.Errorf("%w: nil item", storage.ErrNotValid)
+	}
+	_, err := c.do(http.MethodDelete, ItemsPath, url.Values{"iri": {it.GetLink().String()}}, nil)
+	return err
+}
+
+// Create creates the "col" collection in the remote store.
+func (c *Client) Create(col pub.CollectionInterface) (pub.CollectionInterface, error) {
+	if pub.IsNil(col) {
+		return nil, fmt.Errorf("%w: nil collection", storage.ErrNotValid)
+	}
+	it, err := c.do(http.MethodPut, CollectionsPath, nil, col)
+	if err != nil {
+		return nil, err
+	}
+	created, ok := it.(pub.CollectionInterface)
+	if !ok {
+		return nil, errors.New("the response is not a collection")
+	}
+	return created, nil
+}
+
+// AddTo adds "it" to the "col" collection in the remote store.
+func (c *Client) AddTo(col pub.IRI, it pub.Item) error {
+	if pub.IsNil(it) {
+		return fmt.Errorf("%w: nil item", storage.ErrNotValid)
+	}
+	_, err := c.do(http.MethodPost, MembersPath, url.Values{"iri": {col.String()}}, it)
+	return err
+}
+
+// RemoveFrom removes "it" from the "col" collection in the remote store.
+func (c *Client) RemoveFrom(col pub.IRI, it pub.Item) error {
+	if pub.IsNil(it) {
+		return fmt.Errorf("%w: nil item", storage.ErrNotValid)
+	}
+	query := url.Values{"iri": {col.String()}, "member": {it.GetLink().String()}}
+	_, err := c.do(http.MethodDelete, MembersPath, query, nil)
+	return err
+}
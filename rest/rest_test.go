@@ -0,0 +1,87 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage"
+	"github.com/go-ap/storage/memory"
+	"github.com/go-ap/storage/storagetest"
+)
+
+func newTestClient(t *testing.T, s storage.Store, token string) *Client {
+	srv := httptest.NewServer(http.StripPrefix("/storage", Handler(s, "secret")))
+	t.Cleanup(srv.Close)
+	c, err := New(Config{URL: srv.URL + "/storage/", Token: token})
+	if err != nil {
+		t.Fatalf("New returned error: %s", err)
+	}
+	return c
+}
+
+func TestConformance(t *testing.T) {
+	storagetest.RunStoreTests(t, func() storage.Store {
+		return newTestClient(t, memory.New(), "secret")
+	})
+}
+
+func TestClient_errors(t *testing.T) {
+	c := newTestClient(t, memory.New(), "secret")
+	if _, err := c.Load("https://example.com/objects/1"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Load of a missing item returned %v, expected %s", err, storage.ErrNotFound)
+	}
+	if _, err := c.Save(&pub.Object{Type: pub.NoteType}); !errors.Is(err, storage.ErrNotValid) {
+		t.Errorf("Save of an object without ID returned %v, expected %s", err, storage.ErrNotValid)
+	}
+
+	unauthorized := newTestClient(t, memory.New(), "invalid")
+	if _, err := unauthorized.Load("https://example.com/objects/1"); err == nil || errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Load with an invalid token returned %v, expected it to be rejected", err)
+	}
+	if _, err := New(Config{URL: "example.com"}); !errors.Is(err, storage.ErrNotValid) {
+		t.Errorf("New with an URL without scheme returned %v, expected %s", err, storage.ErrNotValid)
+	}
+}
+
+func TestHandler_emptyToken(t *testing.T) {
+	h := Handler(memory.New(), "")
+	for _, auth := range []string{"", "Bearer ", "Bearer"} {
+		req := httptest.NewRequest(http.MethodGet, ItemsPath+"?iri=https://example.com/objects/1", nil)
+		req.Header.Set("Authorization", auth)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("request with the %q Authorization header returned %d, expected %d", auth, rec.Code, http.StatusUnauthorized)
+		}
+	}
+}
+
+// failingStore is a Store whose operations fail with an error disclosing the details of the backend.
+type failingStore struct {
+	storage.Store
+}
+
+var errBackend = errors.New("unable to connect to postgres://admin@10.0.0.1:5432/storage")
+
+func (failingStore) Load(pub.IRI) (pub.Item, error) {
+	return nil, errBackend
+}
+
+func (failingStore) Delete(pub.Item) error {
+	return storage.ErrClosed
+}
+
+func TestHandler_serverErrors(t *testing.T) {
+	c := newTestClient(t, failingStore{}, "secret")
+	_, err := c.Load("https://example.com/objects/1")
+	if err == nil || strings.Contains(err.Error(), "10.0.0.1") {
+		t.Errorf("Load failing in the backend returned %v, expected an error without its details", err)
+	}
+	if err := c.Delete(pub.IRI("https://example.com/objects/1")); !errors.Is(err, storage.ErrClosed) {
+		t.Errorf("Delete on a closed backend returned %v, expected %s", err, storage.ErrClosed)
+	}
+}
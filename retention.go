@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// RetentionRule limits the members kept in the collections it applies to. The members removed from
+// a collection are not deleted, the ones which are not referenced any more can be removed using GC.
+type RetentionRule struct {
+	// Match reports if the rule applies to the "col" collection, see CollectionNamed.
+	Match func(col pub.IRI) bool
+	// MaxAge, if set, is how long the members are kept after they have been last updated, or else published.
+	// The members without dates are kept.
+	MaxAge time.Duration
+	// MaxItems, if set, is the number of members kept, the least recently added ones being removed.
+	MaxItems int
+}
+
+// CollectionNamed returns a RetentionRule.Match function matching the collections with "name" as the
+// last segment of their IRIs, eg: "inbox" matches both the inboxes of the actors and the shared inbox.
+func CollectionNamed(name string) func(pub.IRI) bool {
+	return func(col pub.IRI) bool {
+		s := strings.TrimSuffix(string(col), "/")
+		return strings.HasSuffix(s, "/"+name)
+	}
+}
+
+// PruneCollection removes from the "col" collection of the "s" store the members exceeding the limits
+// of the "rules" that apply to it, and returns their number. The store needs to be a CollectionStore.
+func PruneCollection(s Store, col pub.IRI, rules ...RetentionRule) (uint, error) {
+	applied := make([]RetentionRule, 0, len(rules))
+	for _, rule := range rules {
+		if rule.Match != nil && rule.Match(col) {
+			applied = append(applied, rule)
+		}
+	}
+	if len(applied) == 0 {
+		return 0, nil
+	}
+	members, _, err := LoadCollection(s, col, col)
+	if errors.Is(err, ErrNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	cs := asCollectionStore(s)
+	now := time.Now()
+	count := uint(0)
+	// the members are loaded starting with the most recently added one
+	for i, it := range members {
+		if !exceeds(applied, i, it, now) {
+			continue
+		}
+		if err := cs.RemoveFrom(col, it.GetLink()); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// exceeds reports if the "it" member, which is the i-th most recently added one, exceeds the limits of the "rules".
+func exceeds(rules []RetentionRule, i int, it pub.Item, now time.Time) bool {
+	for _, rule := range rules {
+		if rule.MaxItems > 0 && i >= rule.MaxItems {
+			return true
+		}
+		if rule.MaxAge <= 0 || pub.IsIRI(it) {
+			continue
+		}
+		if t := ModifiedAt(it); !t.IsZero() && now.Sub(t) > rule.MaxAge {
+			return true
+		}
+	}
+	return false
+}
+
+// Prune enforces the "rules" on all the collections of the "s" store that they apply to, see PruneCollection,
+// and returns the number of removed members. It's meant to be called periodically.
+// The collections are found through the stored objects, see CollectionsOf, so the store needs to be
+// an IterateStore, and a CollectionStore. Other collections, like the shared inbox, can be pruned
+// using PruneCollection.
+func Prune(s Store, rules ...RetentionRule) (uint, error) {
+	cols := make(pub.IRIs, 0)
+	seen := make(map[pub.IRI]struct{})
+	add := func(col pub.IRI) {
+		if _, ok := seen[col]; !ok {
+			seen[col] = struct{}{}
+			cols = append(cols, col)
+		}
+	}
+	err := Each(s, pub.IRI(""), func(it pub.Item) error {
+		if pub.CollectionTypes.Contains(it.GetType()) {
+			add(it.GetLink())
+			return nil
+		}
+		for _, col := range CollectionsOf(it) {
+			add(col)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	count := uint(0)
+	for _, col := range cols {
+		n, err := PruneCollection(s, col, rules...)
+		count += n
+		if err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
+
+// Retain enforces the "rules" on write, by pruning each collection an item gets added to using
+// an OnAddTo hook of "h". As the whole collection is loaded, it's better suited for small caps,
+// Prune being cheaper for large collections.
+func Retain(h *HookStore, rules ...RetentionRule) {
+	h.OnAddTo(func(col pub.IRI, _ pub.Item) error {
+		_, err := PruneCollection(h.Store, col, rules...)
+		return err
+	})
+}
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+func TestCollectionNamed(t *testing.T) {
+	inboxes := CollectionNamed(InboxCollection)
+	for iri, want := range map[pub.IRI]bool{
+		"https://example.com/actors/jdoe/inbox":  true,
+		"https://example.com/inbox/":             true,
+		"https://example.com/actors/jdoe/outbox": false,
+		"https://example.com/actors/inbox/jdoe":  false,
+	} {
+		if got := inboxes(iri); got != want {
+			t.Errorf("CollectionNamed(%q)(%s) returned %t, expected %t", InboxCollection, iri, got, want)
+		}
+	}
+}
+
+func TestPrune(t *testing.T) {
+	s := newBackendMapStore()
+	inbox := pub.IRI("https://example.com/actors/jdoe/inbox")
+	s.Save(&pub.Actor{ID: "https://example.com/actors/jdoe", Type: pub.PersonType, Inbox: inbox})
+	s.Create(pub.OrderedCollectionNew(inbox))
+	for i := 0; i < 5; i++ {
+		ob := note(pub.IRI(fmt.Sprintf("https://example.com/objects/%d", i)), "hello")
+		ob.Published = time.Now().Add(-time.Duration(5-i) * 24 * time.Hour)
+		s.Save(ob)
+		s.AddTo(inbox, ob)
+	}
+
+	rules := []RetentionRule{
+		{Match: CollectionNamed(InboxCollection), MaxAge: 84 * time.Hour},
+		{Match: CollectionNamed(OutboxCollection), MaxItems: 1},
+	}
+	n, err := Prune(s, rules...)
+	if err != nil {
+		t.Fatalf("Prune returned error: %s", err)
+	}
+	if n != 2 {
+		t.Errorf("Prune removed %d members, expected the 2 older than 3.5 days", n)
+	}
+	if members := s.members[inbox]; members.Contains("https://example.com/objects/0") {
+		t.Errorf("Prune should remove the old members, got %v", members)
+	}
+	if ok, _ := Exists(s, "https://example.com/objects/0"); !ok {
+		t.Errorf("Prune should not delete the removed members")
+	}
+	if n, _ := PruneCollection(s, "https://example.com/actors/jdoe/followers", rules...); n != 0 {
+		t.Errorf("PruneCollection removed %d members of a collection without rules", n)
+	}
+}
+
+func TestRetain(t *testing.T) {
+	s := newBackendMapStore()
+	inbox := pub.IRI("https://example.com/actors/jdoe/inbox")
+	s.Create(pub.OrderedCollectionNew(inbox))
+
+	h := Hooks(s)
+	Retain(h, RetentionRule{Match: CollectionNamed(InboxCollection), MaxItems: 2})
+	for i := 0; i < 3; i++ {
+		if err := h.AddTo(inbox, pub.IRI(fmt.Sprintf("https://example.com/objects/%d", i))); err != nil {
+			t.Fatalf("AddTo returned error: %s", err)
+		}
+	}
+	members, _, _ := LoadCollection(s, inbox, inbox)
+	if len(members) != 2 || members[0].GetLink() != "https://example.com/objects/2" {
+		t.Errorf("the inbox has %v members, expected the 2 most recently added ones", members)
+	}
+}
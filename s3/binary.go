@@ -0,0 +1,100 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	pub "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage"
+	"github.com/minio/minio-go/v7"
+)
+
+// Binaries is a Store keeping the binary data of its objects in an S3 bucket, and the objects themselves
+// in the underlying store. Deleting an object with it deletes its data too.
+type Binaries struct {
+	storage.Store
+	b *bucket
+}
+
+var (
+	_ storage.Store       = &Binaries{}
+	_ storage.BinaryStore = &Binaries{}
+)
+
+// NewBinaries returns a Binaries keeping the objects in "s", and their binary data in the c.Bucket bucket.
+func NewBinaries(s storage.Store, c Config) (*Binaries, error) {
+	b, err := open(c)
+	if err != nil {
+		return nil, err
+	}
+	return &Binaries{Store: s, b: b}, nil
+}
+
+// Delete deletes "it" from the underlying store, and its binary data from the bucket.
+func (s *Binaries) Delete(it pub.Item) error {
+	if err := s.Store.Delete(it); err != nil {
+		return err
+	}
+	if pub.IsNil(it) {
+		return nil
+	}
+	return s.DeleteBinary(it.GetLink())
+}
+
+// SaveBinary uploads the data read from "r" under the key of "iri", with "contentType" as its content type.
+func (s *Binaries) SaveBinary(iri pub.IRI, r io.Reader, contentType string) error {
+	if len(iri) == 0 {
+		return fmt.Errorf("%w: unable to save binary data without an IRI", storage.ErrNotValid)
+	}
+	return s.b.saveBinary(iri, r, contentType)
+}
+
+// LoadBinary returns the data stored under the key of "iri", and its content type.
+func (s *Binaries) LoadBinary(iri pub.IRI) (io.ReadCloser, string, error) {
+	return s.b.loadBinary(iri)
+}
+
+// DeleteBinary removes the data stored under the key of "iri". Deleting missing data is not an error.
+func (s *Binaries) DeleteBinary(iri pub.IRI) error {
+	return s.b.deleteBinary(iri)
+}
+
+func (b *bucket) saveBinary(iri pub.IRI, r io.Reader, contentType string) error {
+	key, err := b.key(iri, binaryFile)
+	if err != nil {
+		return err
+	}
+	_, err = b.c.PutObject(context.Background(), b.name, key, r, -1, minio.PutObjectOptions{ContentType: contentType})
+	return err
+}
+
+func (b *bucket) loadBinary(iri pub.IRI) (io.ReadCloser, string, error) {
+	key, err := b.key(iri, binaryFile)
+	if err != nil {
+		return nil, "", err
+	}
+	obj, err := b.c.GetObject(context.Background(), b.name, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, "", err
+	}
+	// the request is sent by Stat, as GetObject returns before it
+	info, err := obj.Stat()
+	if isNotFound(err) {
+		obj.Close()
+		return nil, "", fmt.Errorf("unable to find binary data for %s: %w", iri, storage.ErrNotFound)
+	}
+	if err != nil {
+		obj.Close()
+		return nil, "", err
+	}
+	return obj, info.ContentType, nil
+}
+
+func (b *bucket) deleteBinary(iri pub.IRI) error {
+	key, err := b.key(iri, binaryFile)
+	if err != nil {
+		return err
+	}
+	return b.c.RemoveObject(context.Background(), b.name, key, minio.RemoveObjectOptions{})
+}
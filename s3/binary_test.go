@@ -0,0 +1,20 @@
+package s3
+
+import (
+	"testing"
+
+	"github.com/go-ap/storage"
+	"github.com/go-ap/storage/memory"
+	"github.com/go-ap/storage/storagetest"
+)
+
+func TestBinaries(t *testing.T) {
+	c := testConfig(t)
+	storagetest.RunStoreTests(t, func() storage.Store {
+		s, err := NewBinaries(memory.New(), c)
+		if err != nil {
+			t.Fatalf("NewBinaries returned error: %s", err)
+		}
+		return s
+	})
+}
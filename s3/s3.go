@@ -0,0 +1,106 @@
+// Package s3 implements a storage backend keeping the items in an S3-compatible bucket, eg: AWS S3, or MinIO,
+// so the servers using it don't need any local state, and the storage of the binary data of the objects
+// saved to any other backend, like the media of their attachments, see Binaries.
+//
+// The items are stored under keys derived from their IRIs, like the paths of the fs backend: the objects as
+// JSON-LD documents, eg: "example.com/objects/1/object.json" for https://example.com/objects/1, and the
+// collections as storage.CollectionDocument indexes, eg: "example.com/actors/jdoe/outbox/index.json".
+// The binary data is stored under the "binary" key of the object, eg: "example.com/media/1/binary",
+// with its MIME type as the content type.
+//
+// The indexes of the collections are updated with conditional writes, which fail if another server changed
+// them since they've been read, so the bucket needs to support them, as AWS S3 and MinIO do.
+package s3
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	pub "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// Config holds the options for the S3 bucket.
+type Config struct {
+	// Endpoint is the host, and port, of the S3 API, eg: "s3.amazonaws.com", or "localhost:9000".
+	Endpoint string
+	// Bucket is the name of the bucket, which needs to exist.
+	Bucket string
+	// Prefix is prepended to the keys, so the bucket can be shared with other data, eg: "fedbox/".
+	Prefix string
+	// Region is the region of the bucket, which is looked up if it's not set.
+	Region string
+	// AccessKey and SecretKey are the credentials of the requests.
+	AccessKey string
+	SecretKey string
+	// Insecure sends the requests over HTTP instead of HTTPS, eg: for a MinIO server on the same host.
+	Insecure bool
+	// Transport sends the requests, eg: trusting the certificate of a self-hosted server. The default
+	// one of the S3 client is used if it's not set.
+	Transport http.RoundTripper
+}
+
+// binaryFile is the name of the key holding the binary data of an object.
+const binaryFile = "binary"
+
+// bucket sends the requests for the keys of a Config.
+type bucket struct {
+	c      *minio.Client
+	name   string
+	prefix string
+}
+
+// open returns the bucket of "c", after checking that it exists.
+func open(c Config) (*bucket, error) {
+	if len(c.Endpoint) == 0 || len(c.Bucket) == 0 {
+		return nil, fmt.Errorf("%w: the endpoint and the bucket of the storage need to be set", storage.ErrNotValid)
+	}
+	client, err := minio.New(c.Endpoint, &minio.Options{
+		Creds:     credentials.NewStaticV4(c.AccessKey, c.SecretKey, ""),
+		Secure:    !c.Insecure,
+		Region:    c.Region,
+		Transport: c.Transport,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to open the s3 storage %s: %w", c.Endpoint, err)
+	}
+	ok, err := client.BucketExists(context.Background(), c.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open the s3 bucket %s: %w", c.Bucket, err)
+	}
+	if !ok {
+		return nil, fmt.Errorf("unable to find the s3 bucket %s: %w", c.Bucket, storage.ErrNotFound)
+	}
+	return &bucket{c: client, name: c.Bucket, prefix: c.Prefix}, nil
+}
+
+// key returns the key of the "name" data of the "iri" item: its host and path, like the directories
+// of the fs backend, followed by "name".
+func (b *bucket) key(iri pub.IRI, name string) (string, error) {
+	u, err := url.Parse(iri.String())
+	if err != nil {
+		return "", fmt.Errorf("%w IRI %s: %s", storage.ErrNotValid, iri, err)
+	}
+	if len(u.Host) == 0 || strings.Contains(u.Host, "/") {
+		return "", fmt.Errorf("%w IRI %q: invalid host", storage.ErrNotValid, iri)
+	}
+	p := path.Clean("/" + u.Path)
+	if len(u.RawQuery) > 0 || u.ForceQuery {
+		p = path.Join(p, "%3F"+url.PathEscape(u.RawQuery))
+	}
+	if len(u.Fragment) > 0 {
+		p = path.Join(p, "%23"+url.PathEscape(u.Fragment))
+	}
+	return b.prefix + path.Join(u.Host, p, name), nil
+}
+
+// isNotFound reports whether "err" is the response to a request for a missing key.
+func isNotFound(err error) bool {
+	return minio.ToErrorResponse(err).Code == minio.NoSuchKey
+}
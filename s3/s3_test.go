@@ -0,0 +1,60 @@
+package s3
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage"
+	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/backend/s3mem"
+)
+
+// testConfig returns the Config of a bucket of an in-memory S3 server, which is stopped at the end of the test.
+func testConfig(t *testing.T) Config {
+	backend := s3mem.New()
+	if err := backend.CreateBucket("fedbox"); err != nil {
+		t.Fatalf("unable to create the bucket: %s", err)
+	}
+	srv := httptest.NewTLSServer(gofakes3.New(backend).Server())
+	t.Cleanup(srv.Close)
+	return Config{
+		Endpoint:  strings.TrimPrefix(srv.URL, "https://"),
+		Bucket:    "fedbox",
+		Region:    "us-east-1",
+		AccessKey: "jdoe",
+		SecretKey: "secret",
+		Transport: srv.Client().Transport,
+	}
+}
+
+func TestOpen(t *testing.T) {
+	c := testConfig(t)
+	if _, err := open(Config{Endpoint: c.Endpoint}); !errors.Is(err, storage.ErrNotValid) {
+		t.Errorf("open without a bucket returned %v, expected %s", err, storage.ErrNotValid)
+	}
+	c.Bucket = "missing"
+	if _, err := open(c); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("open of a missing bucket returned %v, expected %s", err, storage.ErrNotFound)
+	}
+}
+
+func TestBucket_key(t *testing.T) {
+	b := &bucket{prefix: "fedbox/"}
+	tests := map[pub.IRI]string{
+		"https://example.com/media/1":        "fedbox/example.com/media/1/binary",
+		"https://example.com/media/../../1":  "fedbox/example.com/1/binary",
+		"https://example.com/media?page=2":   "fedbox/example.com/media/%3Fpage=2/binary",
+		"https://example.com/actors/jdoe#me": "fedbox/example.com/actors/jdoe/%23me/binary",
+	}
+	for iri, want := range tests {
+		if got, err := b.key(iri, binaryFile); err != nil || got != want {
+			t.Errorf("key(%s) = %q, %v, expected %q", iri, got, err, want)
+		}
+	}
+	if _, err := b.key("/media/1", binaryFile); !errors.Is(err, storage.ErrNotValid) {
+		t.Errorf("key of an IRI without a host returned %v, expected %s", err, storage.ErrNotValid)
+	}
+}
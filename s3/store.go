@@ -0,0 +1,336 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	pub "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage"
+	"github.com/minio/minio-go/v7"
+)
+
+const (
+	objectFile = "object.json"
+	indexFile  = "index.json"
+)
+
+// updateTries is the number of times a collection is read and written again, when another writer
+// changes it in between.
+const updateTries = 10
+
+// errKeyNotFound is returned by get for the keys that aren't stored.
+var errKeyNotFound = errors.New("key not found")
+
+type repo struct {
+	b *bucket
+	// mu serializes the updates of the collections made by this process, so they don't need to be retried,
+	// the ones made by other processes are detected from the ETags of the collections.
+	mu     sync.Mutex
+	closed atomic.Bool
+}
+
+var (
+	_ storage.Store           = &repo{}
+	_ storage.CollectionStore = &repo{}
+	_ storage.BinaryStore     = &repo{}
+)
+
+// New returns a storage keeping the items in the c.Bucket bucket, which needs to exist.
+func New(c Config) (*repo, error) {
+	b, err := open(c)
+	if err != nil {
+		return nil, err
+	}
+	return &repo{b: b}, nil
+}
+
+// Close makes the operations of the storage return storage.ErrClosed. The S3 client doesn't hold any
+// resources that need to be released.
+func (r *repo) Close() error {
+	r.closed.Store(true)
+	return nil
+}
+
+// key returns the key of the "name" data of the "iri" item, or storage.ErrClosed after the storage
+// has been closed, which all the operations on items go through.
+func (r *repo) key(iri pub.IRI, name string) (string, error) {
+	if r.closed.Load() {
+		return "", storage.ErrClosed
+	}
+	return r.b.key(iri, name)
+}
+
+func notFound(iri pub.IRI) error {
+	return fmt.Errorf("unable to find %s: %w", iri, storage.ErrNotFound)
+}
+
+// Load returns the object, or the collection with its members, identified by "iri".
+// The members are loaded one request at a time, the ones that aren't stored are returned as IRIs.
+func (r *repo) Load(iri pub.IRI) (pub.Item, error) {
+	// the IRIs of collections are looked up first as collections, and the others as objects,
+	// which saves a request for a missing key for most loads, see storage.BucketFor
+	collection := storage.BucketFor(iri) == storage.BucketCollections
+	if !collection {
+		if it, err := r.loadObject(iri); !errors.Is(err, errKeyNotFound) {
+			return it, err
+		}
+	}
+	col, _, err := r.loadCollection(iri)
+	if err == nil {
+		items := col.Collection()
+		for i, it := range items {
+			ob, err := r.loadObject(it.GetLink())
+			if errors.Is(err, errKeyNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			items[i] = ob
+		}
+		return col, nil
+	}
+	if !errors.Is(err, errKeyNotFound) {
+		return nil, err
+	}
+	if !collection {
+		return nil, notFound(iri)
+	}
+	it, err := r.loadObject(iri)
+	if errors.Is(err, errKeyNotFound) {
+		return nil, notFound(iri)
+	}
+	return it, err
+}
+
+func (r *repo) loadObject(iri pub.IRI) (pub.Item, error) {
+	key, err := r.key(iri, objectFile)
+	if err != nil {
+		return nil, err
+	}
+	data, _, err := r.b.get(key)
+	if err != nil {
+		return nil, err
+	}
+	it, err := pub.UnmarshalJSON(data)
+	if err != nil {
+		return nil, &storage.CorruptedItemError{IRI: iri, Path: key, Err: err}
+	}
+	return it, nil
+}
+
+// loadCollection returns the "iri" collection, without loading its members, and the ETag of its index.
+func (r *repo) loadCollection(iri pub.IRI) (pub.CollectionInterface, string, error) {
+	key, err := r.key(iri, indexFile)
+	if err != nil {
+		return nil, "", err
+	}
+	data, etag, err := r.b.get(key)
+	if err != nil {
+		return nil, "", err
+	}
+	col, err := storage.UnmarshalCollection(data)
+	if err != nil {
+		return nil, "", &storage.CorruptedItemError{IRI: iri, Path: key, Err: err}
+	}
+	return col, etag, nil
+}
+
+// Save saves "it" as a JSON-LD document, or as an index if it's a collection. The item needs to have an ID.
+func (r *repo) Save(it pub.Item) (pub.Item, error) {
+	if pub.IsNil(it) {
+		return nil, fmt.Errorf("%w: unable to save nil item", storage.ErrNotValid)
+	}
+	if len(it.GetLink()) == 0 {
+		return nil, fmt.Errorf("%w: unable to save %s item without an ID", storage.ErrNotValid, it.GetType())
+	}
+	if pub.CollectionTypes.Contains(it.GetType()) {
+		col, ok := it.(pub.CollectionInterface)
+		if !ok {
+			return nil, fmt.Errorf("%w collection %T", storage.ErrNotValid, it)
+		}
+		return it, r.saveCollection(col, minio.PutObjectOptions{})
+	}
+	key, err := r.key(it.GetLink(), objectFile)
+	if err != nil {
+		return nil, err
+	}
+	data, err := pub.MarshalJSON(it)
+	if err != nil {
+		return nil, err
+	}
+	return it, r.b.put(key, data, minio.PutObjectOptions{})
+}
+
+func (r *repo) saveCollection(col pub.CollectionInterface, opts minio.PutObjectOptions) error {
+	key, err := r.key(col.GetLink(), indexFile)
+	if err != nil {
+		return err
+	}
+	data, err := storage.MarshalCollection(col)
+	if err != nil {
+		return err
+	}
+	return r.b.put(key, data, opts)
+}
+
+// Delete removes the object, or the collection, identified by the IRI of "it", and its binary data.
+func (r *repo) Delete(it pub.Item) error {
+	if pub.IsNil(it) {
+		return nil
+	}
+	for _, name := range []string{objectFile, indexFile, binaryFile} {
+		key, err := r.key(it.GetLink(), name)
+		if err != nil {
+			return err
+		}
+		if err := r.b.c.RemoveObject(context.Background(), r.b.name, key, minio.RemoveObjectOptions{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Create saves the "col" collection, unless it exists, in which case the existing one is returned.
+func (r *repo) Create(col pub.CollectionInterface) (pub.CollectionInterface, error) {
+	if pub.IsNil(col) || len(col.GetLink()) == 0 {
+		return nil, fmt.Errorf("%w: unable to create collection without an ID", storage.ErrNotValid)
+	}
+	opts := minio.PutObjectOptions{}
+	// the index is written only if there's none, so a concurrent Create doesn't overwrite the members
+	opts.SetMatchETagExcept("*")
+	err := r.saveCollection(col, opts)
+	if isPreconditionFailed(err) {
+		existing, _, err := r.loadCollection(col.GetLink())
+		return existing, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	return col, nil
+}
+
+// AddTo appends "it" to the "col" collection. Adding an item that is already in the collection is a no-op.
+func (r *repo) AddTo(col pub.IRI, it pub.Item) error {
+	if pub.IsNil(it) {
+		return fmt.Errorf("%w: unable to add nil item to %s", storage.ErrNotValid, col)
+	}
+	return r.updateCollection(col, func(c pub.CollectionInterface) (bool, error) {
+		if c.Contains(it.GetLink()) {
+			return false, nil
+		}
+		return true, c.Append(it.GetLink())
+	})
+}
+
+// RemoveFrom removes "it" from the "col" collection.
+func (r *repo) RemoveFrom(col pub.IRI, it pub.Item) error {
+	if pub.IsNil(it) {
+		return fmt.Errorf("%w: unable to remove nil item from %s", storage.ErrNotValid, col)
+	}
+	return r.updateCollection(col, func(c pub.CollectionInterface) (bool, error) {
+		if !c.Contains(it.GetLink()) {
+			return false, nil
+		}
+		switch cc := c.(type) {
+		case *pub.OrderedCollection:
+			cc.OrderedItems.Remove(it.GetLink())
+		case *pub.Collection:
+			cc.Items.Remove(it.GetLink())
+		}
+		return true, nil
+	})
+}
+
+// updateCollection calls "fn" for the "col" collection, and saves it if "fn" reports that it changed it.
+// The index is written only if its ETag hasn't changed since it's been read, otherwise the update is
+// done again on the new version, up to updateTries times, after which storage.ErrConflict is returned.
+func (r *repo) updateCollection(col pub.IRI, fn func(pub.CollectionInterface) (bool, error)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for range updateTries {
+		c, etag, err := r.loadCollection(col)
+		if errors.Is(err, errKeyNotFound) {
+			return notFound(col)
+		}
+		if err != nil {
+			return err
+		}
+		changed, err := fn(c)
+		if err != nil || !changed {
+			return err
+		}
+		opts := minio.PutObjectOptions{}
+		opts.SetMatchETag(etag)
+		if err = r.saveCollection(c, opts); !isPreconditionFailed(err) {
+			return err
+		}
+	}
+	return fmt.Errorf("unable to update collection %s: %w", col, storage.ErrConflict)
+}
+
+// SaveBinary uploads the data read from "rd" under the key of "iri", with "contentType" as its content type.
+func (r *repo) SaveBinary(iri pub.IRI, rd io.Reader, contentType string) error {
+	if len(iri) == 0 {
+		return fmt.Errorf("%w: unable to save binary data without an IRI", storage.ErrNotValid)
+	}
+	if r.closed.Load() {
+		return storage.ErrClosed
+	}
+	return r.b.saveBinary(iri, rd, contentType)
+}
+
+// LoadBinary returns the data stored under the key of "iri", and its content type.
+func (r *repo) LoadBinary(iri pub.IRI) (io.ReadCloser, string, error) {
+	if r.closed.Load() {
+		return nil, "", storage.ErrClosed
+	}
+	return r.b.loadBinary(iri)
+}
+
+// DeleteBinary removes the data stored under the key of "iri". Deleting missing data is not an error.
+func (r *repo) DeleteBinary(iri pub.IRI) error {
+	if r.closed.Load() {
+		return storage.ErrClosed
+	}
+	return r.b.deleteBinary(iri)
+}
+
+// get returns the data stored under "key", and its ETag, or errKeyNotFound.
+func (b *bucket) get(key string) ([]byte, string, error) {
+	obj, err := b.c.GetObject(context.Background(), b.name, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, "", err
+	}
+	defer obj.Close()
+
+	info, err := obj.Stat()
+	if isNotFound(err) {
+		return nil, "", errKeyNotFound
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	data, err := io.ReadAll(obj)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, info.ETag, nil
+}
+
+func (b *bucket) put(key string, data []byte, opts minio.PutObjectOptions) error {
+	opts.ContentType = "application/activity+json"
+	_, err := b.c.PutObject(context.Background(), b.name, key, bytes.NewReader(data), int64(len(data)), opts)
+	return err
+}
+
+// isPreconditionFailed reports whether "err" is the response to a conditional write that wasn't done.
+func isPreconditionFailed(err error) bool {
+	return err != nil && minio.ToErrorResponse(err).Code == "PreconditionFailed"
+}
@@ -0,0 +1,62 @@
+package s3
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage"
+	"github.com/go-ap/storage/storagetest"
+)
+
+func TestConformance(t *testing.T) {
+	storagetest.RunStoreTests(t, func() storage.Store {
+		r, err := New(testConfig(t))
+		if err != nil {
+			t.Fatalf("New returned error: %s", err)
+		}
+		return r
+	})
+}
+
+func TestUpdateCollection_conflict(t *testing.T) {
+	c := testConfig(t)
+	r, err := New(c)
+	if err != nil {
+		t.Fatalf("New returned error: %s", err)
+	}
+	// another server, with its own lock, writing the same collection
+	other, _ := New(c)
+	outbox := pub.IRI("https://example.com/actors/jdoe/outbox")
+	r.Create(pub.OrderedCollectionNew(outbox))
+
+	tries := 0
+	err = r.updateCollection(outbox, func(col pub.CollectionInterface) (bool, error) {
+		if tries++; tries == 1 {
+			if err := other.AddTo(outbox, pub.IRI("https://example.com/objects/1")); err != nil {
+				t.Fatalf("AddTo returned error: %s", err)
+			}
+		}
+		return true, col.Append(pub.IRI("https://example.com/objects/2"))
+	})
+	if err != nil {
+		t.Fatalf("updateCollection returned error: %s", err)
+	}
+	if tries != 2 {
+		t.Errorf("updateCollection called the update %d times, expected it to be done again after the conflict", tries)
+	}
+	col, _, _ := r.loadCollection(outbox)
+	if items := col.Collection(); len(items) != 2 {
+		t.Errorf("expected the members added by both servers, got %v", items)
+	}
+
+	err = r.updateCollection(outbox, func(col pub.CollectionInterface) (bool, error) {
+		tries++
+		other.AddTo(outbox, pub.IRI(fmt.Sprintf("https://example.com/objects/%d", tries)))
+		return true, nil
+	})
+	if !errors.Is(err, storage.ErrConflict) {
+		t.Errorf("updateCollection of a collection that always changes returned %v, expected %s", err, storage.ErrConflict)
+	}
+}
@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"fmt"
+)
+
+// ErrSchemaVersion is returned by the backends for stored data with a layout newer than the ones
+// they know of, which they can't read without risking to damage it.
+var ErrSchemaVersion = fmt.Errorf("unsupported schema version: %w", ErrNotValid)
+
+// SchemaStore is implemented by the backends that record the version of the layout of their stored data,
+// like the organization of buckets and the format of the keys, so they can migrate older data when the
+// layout changes, instead of failing to read it.
+type SchemaStore interface {
+	// SchemaVersion returns the version of the layout of the stored data.
+	SchemaVersion() (int, error)
+	// Upgrade migrates the stored data to the current version of the layout.
+	Upgrade() error
+}
+
+// UpgradeFn migrates stored data from a version of a layout to the next one.
+type UpgradeFn func() error
+
+// RunUpgrades runs the "upgrades" steps needed to migrate stored data from the "from" version,
+// where upgrades[i] migrates the data from version i to version i+1, so the current version
+// is len(upgrades).
+// After each step it calls "done" with the version reached, which should record it, so an
+// interrupted upgrade continues from the step that failed.
+// It returns ErrSchemaVersion if "from" is newer than the current version.
+func RunUpgrades(from int, upgrades []UpgradeFn, done func(version int) error) error {
+	if from < 0 || from > len(upgrades) {
+		return fmt.Errorf("%w %d, the current one is %d", ErrSchemaVersion, from, len(upgrades))
+	}
+	for v := from; v < len(upgrades); v++ {
+		if upgrades[v] != nil {
+			if err := upgrades[v](); err != nil {
+				return fmt.Errorf("unable to upgrade from schema version %d: %w", v, err)
+			}
+		}
+		if err := done(v + 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
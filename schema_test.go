@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRunUpgrades(t *testing.T) {
+	fail := errors.New("fail")
+	tests := []struct {
+		name    string
+		from    int
+		failing int
+		ran     []int
+		version int
+		err     error
+	}{
+		{name: "from the start", from: 0, failing: -1, ran: []int{0, 1, 2}, version: 3},
+		{name: "from the middle", from: 2, failing: -1, ran: []int{2}, version: 3},
+		{name: "up to date", from: 3, failing: -1, ran: []int{}, version: 3},
+		{name: "newer", from: 4, failing: -1, ran: []int{}, version: 4, err: ErrSchemaVersion},
+		{name: "failing step", from: 0, failing: 1, ran: []int{0, 1}, version: 1, err: fail},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ran := make([]int, 0)
+			step := func(v int) UpgradeFn {
+				return func() error {
+					ran = append(ran, v)
+					if v == tt.failing {
+						return fail
+					}
+					return nil
+				}
+			}
+			version := tt.from
+			err := RunUpgrades(tt.from, []UpgradeFn{step(0), step(1), step(2)}, func(v int) error {
+				version = v
+				return nil
+			})
+			if !errors.Is(err, tt.err) || (tt.err == nil && err != nil) {
+				t.Errorf("RunUpgrades returned %v, expected %v", err, tt.err)
+			}
+			if len(ran) != len(tt.ran) {
+				t.Fatalf("RunUpgrades ran the steps %v, expected %v", ran, tt.ran)
+			}
+			for i := range ran {
+				if ran[i] != tt.ran[i] {
+					t.Errorf("RunUpgrades ran the steps %v, expected %v", ran, tt.ran)
+				}
+			}
+			if version != tt.version {
+				t.Errorf("the recorded version is %d, expected %d", version, tt.version)
+			}
+		})
+	}
+}
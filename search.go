@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// SearchStore is implemented by backends that maintain a full-text index of the stored objects,
+// which is updated together with the objects on Save and Delete.
+type SearchStore interface {
+	// Search returns the objects matching "f" that contain all the terms of "query" in their name,
+	// summary or content, or, for actors, their preferredUsername, most recently published first.
+	Search(query string, f Filterable) (pub.ItemCollection, error)
+}
+
+// Search returns the objects stored in "s" that match "query" and "f", see SearchStore.
+// Stores that don't implement SearchStore are scanned using Each.
+func Search(s ReadStore, query string, f Filterable) (pub.ItemCollection, error) {
+	if ss, ok := s.(SearchStore); ok {
+		return ss.Search(query, f)
+	}
+	terms := SearchTerms(query)
+	result := make(pub.ItemCollection, 0)
+	err := Each(s, f, func(it pub.Item) error {
+		if MatchTerms(it, terms) {
+			result = append(result, it)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	SortByPublished(result)
+	return result, nil
+}
+
+// SearchTerms splits "text" into the lower case words it contains, skipping HTML tags and duplicates.
+func SearchTerms(text string) []string {
+	seen := make(map[string]bool)
+	terms := make([]string, 0)
+	inTag := false
+	word := strings.Builder{}
+	flush := func() {
+		if word.Len() > 0 && !seen[word.String()] {
+			seen[word.String()] = true
+			terms = append(terms, word.String())
+		}
+		word.Reset()
+	}
+	for _, r := range text {
+		switch {
+		case r == '<':
+			flush()
+			inTag = true
+		case r == '>' && inTag:
+			inTag = false
+		case inTag:
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word.WriteRune(unicode.ToLower(r))
+		default:
+			flush()
+		}
+	}
+	flush()
+	return terms
+}
+
+// ItemTerms returns the search terms of the name, summary and content of "it", and of the
+// preferredUsername of actors.
+func ItemTerms(it pub.Item) []string {
+	if pub.IsNil(it) || !pub.IsObject(it) {
+		return nil
+	}
+	values := make([]pub.NaturalLanguageValues, 0)
+	pub.OnObject(it, func(o *pub.Object) error {
+		values = append(values, o.Name, o.Summary, o.Content)
+		return nil
+	})
+	if pub.ActorTypes.Contains(it.GetType()) {
+		pub.OnActor(it, func(a *pub.Actor) error {
+			values = append(values, a.PreferredUsername)
+			return nil
+		})
+	}
+	text := strings.Builder{}
+	for _, nlv := range values {
+		for _, v := range nlv {
+			text.Write(v.Value)
+			text.WriteByte(' ')
+		}
+	}
+	return SearchTerms(text.String())
+}
+
+// MatchTerms checks if "it" contains all the search "terms", see ItemTerms.
+func MatchTerms(it pub.Item, terms []string) bool {
+	found := make(map[string]bool)
+	for _, term := range ItemTerms(it) {
+		found[term] = true
+	}
+	for _, term := range terms {
+		if !found[term] {
+			return false
+		}
+	}
+	return true
+}
+
+// SortByPublished sorts "items" by their publishing date, most recent first.
+func SortByPublished(items pub.ItemCollection) {
+	sort.SliceStable(items, func(i, j int) bool {
+		return published(items[i]).After(published(items[j]))
+	})
+}
+
+func published(it pub.Item) time.Time {
+	var t time.Time
+	if pub.IsObject(it) {
+		pub.OnObject(it, func(o *pub.Object) error {
+			t = o.Published
+			return nil
+		})
+	}
+	return t
+}
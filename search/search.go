@@ -0,0 +1,235 @@
+// Package search implements storage.SearchStore with a bleve full-text index, for the backends which
+// don't maintain one themselves.
+//
+// The index holds the terms of the name, summary and content of the objects, and of the preferredUsername
+// of the actors, see storage.ItemTerms, and it is updated by the Save and Delete operations of the Searcher.
+// The terms are matched whole, like storage.MatchTerms does, without stemming, or fuzzy matching, and
+// the items changed directly in the underlying store are indexed only by Reindex.
+package search
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/keyword"
+	"github.com/blevesearch/bleve/v2/mapping"
+	pub "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage"
+)
+
+// termsField is the field of the indexed documents holding the terms of the objects.
+const termsField = "terms"
+
+// Config holds the options of the search index.
+type Config struct {
+	// Path is the directory in which the index is stored. The index is kept in memory if it's empty.
+	Path string
+}
+
+// Searcher is a Store which indexes the objects it saves, so they can be searched without scanning
+// the underlying store. When the underlying store is a CollectionStore, so is the Searcher.
+type Searcher struct {
+	storage.Store
+	index bleve.Index
+
+	mu     sync.Mutex
+	closed bool
+}
+
+var (
+	_ storage.SearchStore     = &Searcher{}
+	_ storage.CollectionStore = &Searcher{}
+	_ io.Closer               = &Searcher{}
+)
+
+// document is the indexed representation of an object.
+type document struct {
+	Terms []string `json:"terms"`
+}
+
+// New returns a Searcher for "s", with the index in the c.Path directory, which is created if it doesn't exist.
+func New(s storage.Store, c Config) (*Searcher, error) {
+	var (
+		index bleve.Index
+		err   error
+	)
+	switch {
+	case len(c.Path) == 0:
+		index, err = bleve.NewMemOnly(indexMapping())
+	case exists(c.Path):
+		index, err = bleve.Open(c.Path)
+	default:
+		index, err = bleve.New(c.Path, indexMapping())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to open the search index %s: %w", c.Path, err)
+	}
+	return &Searcher{Store: s, index: index}, nil
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// indexMapping indexes each term as it is, as they're already split and lower cased by storage.ItemTerms.
+func indexMapping() mapping.IndexMapping {
+	terms := bleve.NewTextFieldMapping()
+	terms.Analyzer = keyword.Name
+	terms.Store = false
+	terms.IncludeTermVectors = false
+	doc := bleve.NewDocumentStaticMapping()
+	doc.AddFieldMappingsAt(termsField, terms)
+	m := bleve.NewIndexMapping()
+	m.DefaultMapping = doc
+	return m
+}
+
+// Close closes the index. It doesn't close the underlying store.
+func (s *Searcher) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.index.Close()
+}
+
+// Save saves "it" to the underlying store, and indexes the terms of the saved item.
+func (s *Searcher) Save(it pub.Item) (pub.Item, error) {
+	saved, err := s.Store.Save(it)
+	if err != nil {
+		return saved, err
+	}
+	if pub.IsNil(saved) {
+		saved = it
+	}
+	return saved, s.indexItem(saved)
+}
+
+func (s *Searcher) indexItem(it pub.Item) error {
+	terms := storage.ItemTerms(it)
+	if len(terms) == 0 {
+		return s.index.Delete(it.GetLink().String())
+	}
+	return s.index.Index(it.GetLink().String(), document{Terms: terms})
+}
+
+// Delete deletes "it" from the underlying store, and from the index.
+func (s *Searcher) Delete(it pub.Item) error {
+	if err := s.Store.Delete(it); err != nil {
+		return err
+	}
+	if pub.IsNil(it) {
+		return nil
+	}
+	return s.index.Delete(it.GetLink().String())
+}
+
+// Create creates the "col" collection in the underlying store, which needs to be a CollectionStore.
+func (s *Searcher) Create(col pub.CollectionInterface) (pub.CollectionInterface, error) {
+	cs, err := collectionStore(s.Store)
+	if err != nil {
+		return nil, err
+	}
+	return cs.Create(col)
+}
+
+// AddTo adds "it" to the "col" collection in the underlying store, which needs to be a CollectionStore.
+func (s *Searcher) AddTo(col pub.IRI, it pub.Item) error {
+	cs, err := collectionStore(s.Store)
+	if err != nil {
+		return err
+	}
+	return cs.AddTo(col, it)
+}
+
+// RemoveFrom removes "it" from the "col" collection in the underlying store, which needs to be a CollectionStore.
+func (s *Searcher) RemoveFrom(col pub.IRI, it pub.Item) error {
+	cs, err := collectionStore(s.Store)
+	if err != nil {
+		return err
+	}
+	return cs.RemoveFrom(col, it)
+}
+
+func collectionStore(s storage.Store) (storage.CollectionStore, error) {
+	if cs, ok := s.(storage.CollectionStore); ok {
+		return cs, nil
+	}
+	return nil, fmt.Errorf("storage %T is not a CollectionStore", s)
+}
+
+// Reindex indexes the items of the underlying store matching "f", see storage.Each, eg: after they've been
+// saved without the Searcher, or to build the index of an existing store.
+func (s *Searcher) Reindex(f storage.Filterable) error {
+	batch := s.index.NewBatch()
+	err := storage.Each(s.Store, f, func(it pub.Item) error {
+		if terms := storage.ItemTerms(it); len(terms) > 0 {
+			return batch.Index(it.GetLink().String(), document{Terms: terms})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return s.index.Batch(batch)
+}
+
+// Search returns the objects matching "f" that contain all the terms of "query", see storage.SearchStore.
+// The objects are looked up in the index, unless "query" has no terms, or f.GetLink() is a collection, in
+// which case the underlying store is searched, with storage.Search.
+func (s *Searcher) Search(query string, f storage.Filterable) (pub.ItemCollection, error) {
+	terms := storage.SearchTerms(query)
+	if len(terms) == 0 || s.isCollection(f.GetLink()) {
+		return storage.Search(s.Store, query, f)
+	}
+	q := bleve.NewConjunctionQuery()
+	for _, term := range terms {
+		tq := bleve.NewTermQuery(term)
+		tq.SetField(termsField)
+		q.AddQuery(tq)
+	}
+	count, err := s.index.DocCount()
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.index.Search(bleve.NewSearchRequestOptions(q, int(count), 0, false))
+	if err != nil {
+		return nil, err
+	}
+	result := make(pub.ItemCollection, 0, len(res.Hits))
+	for _, hit := range res.Hits {
+		iri := pub.IRI(hit.ID)
+		if !storage.UnderIRI(iri, f.GetLink()) || storage.SkipIRI(f, iri) {
+			continue
+		}
+		it, err := s.Store.Load(iri)
+		if errors.Is(err, storage.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		// the terms are checked again, as the item might have been changed without the Searcher
+		if storage.MatchItem(f, it) && storage.MatchTerms(it, terms) {
+			result = append(result, it)
+		}
+	}
+	storage.SortByPublished(result)
+	return result, nil
+}
+
+func (s *Searcher) isCollection(iri pub.IRI) bool {
+	if len(iri) == 0 {
+		return false
+	}
+	it, err := s.Store.Load(iri)
+	return err == nil && !pub.IsNil(it) && pub.CollectionTypes.Contains(it.GetType())
+}
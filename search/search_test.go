@@ -0,0 +1,99 @@
+package search
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage"
+	"github.com/go-ap/storage/fs"
+)
+
+func note(id pub.IRI, content string, published time.Time) *pub.Object {
+	return &pub.Object{
+		ID:        id,
+		Type:      pub.NoteType,
+		Content:   pub.NaturalLanguageValues{{Ref: pub.NilLangRef, Value: pub.Content(content)}},
+		Published: published,
+	}
+}
+
+func newTestSearcher(t *testing.T, path string) (*Searcher, storage.Store) {
+	st, err := fs.New(fs.Config{Path: t.TempDir()})
+	if err != nil {
+		t.Fatalf("fs.New returned error: %s", err)
+	}
+	s, err := New(st, Config{Path: path})
+	if err != nil {
+		t.Fatalf("New returned error: %s", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s, st
+}
+
+func ids(items pub.ItemCollection) []pub.IRI {
+	result := make([]pub.IRI, 0, len(items))
+	for _, it := range items {
+		result = append(result, it.GetLink())
+	}
+	return result
+}
+
+func TestSearcher_Search(t *testing.T) {
+	s, _ := newTestSearcher(t, "")
+	now := time.Now().UTC().Truncate(time.Second)
+	s.Save(note("https://example.com/objects/1", "Hello <b>world</b>", now.Add(-time.Hour)))
+	s.Save(note("https://example.com/objects/2", "hello there", now))
+	s.Save(note("https://example.com/objects/3", "goodbye world", now))
+	s.Save(note("https://example.org/objects/1", "hello world", now))
+
+	result, err := s.Search("hello", pub.IRI("https://example.com/objects"))
+	if err != nil {
+		t.Fatalf("Search returned error: %s", err)
+	}
+	if got := ids(result); len(got) != 2 || got[0] != "https://example.com/objects/2" || got[1] != "https://example.com/objects/1" {
+		t.Errorf("Search returned %v, expected the matching objects under the IRI, most recent first", got)
+	}
+	if got, _ := s.Search("World hello", pub.IRI("")); len(got) != 2 {
+		t.Errorf("Search returned %v, expected the objects having all the terms", ids(got))
+	}
+
+	s.Save(note("https://example.com/objects/2", "goodbye", now))
+	s.Delete(pub.IRI("https://example.com/objects/1"))
+	if got, _ := s.Search("hello", pub.IRI("https://example.com/objects")); len(got) != 0 {
+		t.Errorf("Search returned %v, expected no objects after they've been changed, or deleted", ids(got))
+	}
+	if got, _ := s.Search("goodbye", storage.Filter{IRI: "https://example.com/objects", Text: []string{"world"}}); len(got) != 1 {
+		t.Errorf("Search returned %v, expected only the objects matching the filter", ids(got))
+	}
+}
+
+func TestSearcher_Reindex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index")
+	s, st := newTestSearcher(t, path)
+	outbox := pub.IRI("https://example.com/actors/jdoe/outbox")
+	col := pub.OrderedCollectionNew(outbox)
+	col.OrderedItems = pub.ItemCollection{note("https://example.com/objects/1", "hello", time.Now())}
+	st.Save(col.OrderedItems[0])
+	st.Save(col)
+
+	if got, _ := s.Search("hello", pub.IRI("")); len(got) != 0 {
+		t.Errorf("Search returned %v, expected the objects saved without the Searcher not to be indexed", ids(got))
+	}
+	if err := s.Reindex(outbox); err != nil {
+		t.Fatalf("Reindex returned error: %s", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close returned error: %s", err)
+	}
+
+	s, err := New(st, Config{Path: path})
+	if err != nil {
+		t.Fatalf("New returned error: %s", err)
+	}
+	defer s.Close()
+	if got, _ := s.Search("hello", pub.IRI("")); len(got) != 1 {
+		t.Errorf("Search returned %v, expected the reindexed object, after reopening the index", ids(got))
+	}
+}
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+func TestSearchTerms(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{text: "", want: []string{}},
+		{text: "Hello, World! hello", want: []string{"hello", "world"}},
+		{text: `<p>Visit <a href="https://example.com">Example</a></p>`, want: []string{"visit", "example"}},
+		{text: "Ünïcode 2022", want: []string{"ünïcode", "2022"}},
+	}
+	for _, tt := range tests {
+		if got := SearchTerms(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SearchTerms(%q) = %v, expected %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestMatchTerms(t *testing.T) {
+	ob := note("https://example.com/objects/1", "<p>Hello world</p>")
+	ob.Name.Set(pub.NilLangRef, pub.Content("Greeting"))
+	actor := pub.PersonNew("https://example.com/actors/jdoe")
+	actor.PreferredUsername.Set(pub.NilLangRef, pub.Content("jdoe"))
+
+	tests := []struct {
+		name  string
+		it    pub.Item
+		query string
+		want  bool
+	}{
+		{name: "content", it: ob, query: "world", want: true},
+		{name: "name and content", it: ob, query: "greeting HELLO", want: true},
+		{name: "missing term", it: ob, query: "hello goodbye", want: false},
+		{name: "markup", it: ob, query: "p", want: false},
+		{name: "preferred username", it: actor, query: "jdoe", want: true},
+		{name: "IRI", it: pub.IRI("https://example.com/hello"), query: "hello", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MatchTerms(tt.it, SearchTerms(tt.query)); got != tt.want {
+				t.Errorf("MatchTerms() = %t, expected %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearch(t *testing.T) {
+	s := newMapStore()
+	outbox := pub.IRI("https://example.com/actors/jdoe/outbox")
+	older := note("https://example.com/objects/1", "hello world")
+	older.Published = time.Now().Add(-time.Hour)
+	newer := note("https://example.com/objects/2", "hello there")
+	newer.Published = time.Now()
+	col := pub.OrderedCollectionNew(outbox)
+	col.OrderedItems = pub.ItemCollection{older, note("https://example.com/objects/3", "goodbye"), newer}
+	s.Save(col)
+
+	result, err := Search(s, "Hello", outbox)
+	if err != nil {
+		t.Fatalf("Search returned error: %s", err)
+	}
+	if len(result) != 2 || result[0].GetLink() != newer.ID || result[1].GetLink() != older.ID {
+		t.Errorf("Search returned %v, expected the matching objects, most recent first", result)
+	}
+	if result, _ := Search(s, "hello", Filter{IRI: outbox, Text: []string{"world"}}); len(result) != 1 {
+		t.Errorf("Search returned %v, expected only the objects matching the filter", result)
+	}
+}
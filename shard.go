@@ -0,0 +1,166 @@
+package storage
+
+import (
+	"errors"
+	"hash/fnv"
+	"strings"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// ShardedStore is a Store that partitions the items across multiple stores, by the hash of their
+// ShardKey, which spreads the writes of a single node over multiple files, or databases.
+//
+// The collections of an item are kept in the same store as the item. The stores can't be added, or removed,
+// once they contain items, as that would change the store in which the existing items are looked for.
+type ShardedStore struct {
+	shards []Store
+}
+
+var (
+	_ Store           = &ShardedStore{}
+	_ CollectionStore = &ShardedStore{}
+	_ IterateStore    = &ShardedStore{}
+	_ ExistsStore     = &ShardedStore{}
+)
+
+// Shard returns a ShardedStore partitioning the items across the "shards" stores, which need
+// to be passed always in the same order.
+func Shard(shards ...Store) *ShardedStore {
+	return &ShardedStore{shards: shards}
+}
+
+// ShardKey returns the IRI which determines the shard of "iri". For the standard collections
+// of actors and objects, it's the IRI of their owner, otherwise it's "iri" itself.
+func ShardKey(iri pub.IRI) pub.IRI {
+	s := strings.TrimSuffix(string(iri), "/")
+	i := strings.LastIndex(s, "/")
+	if i < 0 {
+		return iri
+	}
+	switch s[i+1:] {
+	case InboxCollection, OutboxCollection, FollowersCollection, FollowingCollection, LikedCollection,
+		"replies", "likes", "shares":
+		return pub.IRI(s[:i])
+	}
+	return iri
+}
+
+func (s *ShardedStore) shard(iri pub.IRI) Store {
+	h := fnv.New32a()
+	h.Write([]byte(ShardKey(iri)))
+	return s.shards[int(h.Sum32()%uint32(len(s.shards)))]
+}
+
+// Load loads the "iri" item from its shard. The members of collections are loaded from their own shards.
+func (s *ShardedStore) Load(iri pub.IRI) (pub.Item, error) {
+	it, err := s.shard(iri).Load(iri)
+	if err != nil || pub.IsNil(it) || !pub.CollectionTypes.Contains(it.GetType()) {
+		return it, err
+	}
+	if col, ok := it.(pub.CollectionInterface); ok {
+		items := col.Collection()
+		for i, member := range items {
+			if !pub.IsIRI(member) {
+				continue
+			}
+			if ob, err := s.shard(member.GetLink()).Load(member.GetLink()); err == nil {
+				items[i] = ob
+			}
+		}
+	}
+	return it, nil
+}
+
+// Save saves "it" in its shard.
+func (s *ShardedStore) Save(it pub.Item) (pub.Item, error) {
+	if pub.IsNil(it) {
+		return s.shards[0].Save(it)
+	}
+	return s.shard(it.GetLink()).Save(it)
+}
+
+// Delete deletes "it" from its shard.
+func (s *ShardedStore) Delete(it pub.Item) error {
+	if pub.IsNil(it) {
+		return nil
+	}
+	return s.shard(it.GetLink()).Delete(it)
+}
+
+// Create creates the "col" collection in its shard, which needs to be a CollectionStore.
+func (s *ShardedStore) Create(col pub.CollectionInterface) (pub.CollectionInterface, error) {
+	if pub.IsNil(col) {
+		return asCollectionStore(s.shards[0]).Create(col)
+	}
+	return asCollectionStore(s.shard(col.GetLink())).Create(col)
+}
+
+// AddTo adds "it" to the "col" collection in the shard of the collection.
+func (s *ShardedStore) AddTo(col pub.IRI, it pub.Item) error {
+	return asCollectionStore(s.shard(col)).AddTo(col, it)
+}
+
+// RemoveFrom removes "it" from the "col" collection in the shard of the collection.
+func (s *ShardedStore) RemoveFrom(col pub.IRI, it pub.Item) error {
+	return asCollectionStore(s.shard(col)).RemoveFrom(col, it)
+}
+
+// Exists reports if "iri" is stored in its shard.
+func (s *ShardedStore) Exists(iri pub.IRI) (bool, error) {
+	return Exists(s.shard(iri), iri)
+}
+
+// Each calls "fn" for the items matching "f" in all the shards, see IterateStore.
+// When f.GetLink() is a collection, its members are loaded from their own shards, and checked using MatchItem.
+func (s *ShardedStore) Each(f Filterable, fn func(pub.Item) error) error {
+	if base := f.GetLink(); len(base) > 0 {
+		it, err := s.shard(base).Load(base)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+		if col, ok := it.(pub.CollectionInterface); ok && pub.CollectionTypes.Contains(it.GetType()) {
+			return s.eachMember(col, f, fn)
+		}
+	}
+	for _, shard := range s.shards {
+		if err := Each(shard, f, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *ShardedStore) eachMember(col pub.CollectionInterface, f Filterable, fn func(pub.Item) error) error {
+	for _, member := range col.Collection() {
+		iri := member.GetLink()
+		if SkipIRI(f, iri) {
+			continue
+		}
+		it, err := s.shard(iri).Load(iri)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !MatchItem(f, it) {
+			continue
+		}
+		if err := fn(it); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes all the shards, see Close, and returns the first error.
+func (s *ShardedStore) Close() error {
+	var first error
+	for _, shard := range s.shards {
+		if err := Close(shard); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// collectionLoadStore is a collectionMapStore which loads the collections, with their members as IRIs.
+type collectionLoadStore struct {
+	*collectionMapStore
+}
+
+func (c collectionLoadStore) Load(iri pub.IRI) (pub.Item, error) {
+	if members, ok := c.members[iri]; ok {
+		col := pub.OrderedCollectionNew(iri)
+		for _, member := range members {
+			col.OrderedItems = append(col.OrderedItems, member)
+		}
+		return col, nil
+	}
+	return c.mapStore.Load(iri)
+}
+
+func TestShardKey(t *testing.T) {
+	tests := map[pub.IRI]pub.IRI{
+		"https://example.com/actors/jdoe":           "https://example.com/actors/jdoe",
+		"https://example.com/actors/jdoe/outbox":    "https://example.com/actors/jdoe",
+		"https://example.com/actors/jdoe/followers": "https://example.com/actors/jdoe",
+		"https://example.com/objects/1/replies":     "https://example.com/objects/1",
+		"https://example.com/objects/1":             "https://example.com/objects/1",
+	}
+	for iri, want := range tests {
+		if got := ShardKey(iri); got != want {
+			t.Errorf("ShardKey(%s) = %s, expected %s", iri, got, want)
+		}
+	}
+}
+
+func TestShardedStore(t *testing.T) {
+	shards := []collectionLoadStore{
+		{newCollectionMapStore()}, {newCollectionMapStore()}, {newCollectionMapStore()},
+	}
+	s := Shard(shards[0], shards[1], shards[2])
+
+	actor := pub.IRI("https://example.com/actors/jdoe")
+	outbox := actor.AddPath(OutboxCollection)
+	s.Save(pub.PersonNew(actor))
+	if _, err := s.Create(pub.OrderedCollectionNew(outbox)); err != nil {
+		t.Fatalf("Create returned error: %s", err)
+	}
+	for i := 0; i < 20; i++ {
+		ob := note(pub.IRI(fmt.Sprintf("https://example.com/objects/%d", i)), "hello")
+		if _, err := s.Save(ob); err != nil {
+			t.Fatalf("Save returned error: %s", err)
+		}
+		if err := s.AddTo(outbox, ob); err != nil {
+			t.Fatalf("AddTo returned error: %s", err)
+		}
+	}
+
+	for i, shard := range shards {
+		if len(shard.items) == 0 {
+			t.Errorf("shard %d has no items", i)
+		}
+		_, hasActor := shard.items[actor]
+		_, hasOutbox := shard.members[outbox]
+		if hasActor != hasOutbox {
+			t.Errorf("the outbox should be stored in the same shard as its actor")
+		}
+	}
+
+	it, err := s.Load(outbox)
+	if err != nil {
+		t.Fatalf("Load returned error: %s", err)
+	}
+	col, _ := it.(pub.CollectionInterface)
+	if col == nil || len(col.Collection()) != 20 {
+		t.Fatalf("Load returned %v, expected the outbox with 20 members", it)
+	}
+	for _, member := range col.Collection() {
+		if pub.IsIRI(member) || contentOf(member) != "hello" {
+			t.Errorf("the %s member has not been loaded from its shard", member.GetLink())
+		}
+	}
+
+	count := 0
+	if err := s.Each(outbox, func(pub.Item) error { count++; return nil }); err != nil {
+		t.Fatalf("Each returned error: %s", err)
+	}
+	if count != 20 {
+		t.Errorf("Each went through %d members, expected 20", count)
+	}
+	if ok, err := s.Exists("https://example.com/objects/3"); err != nil || !ok {
+		t.Errorf("Exists returned %t, %v, expected the object to exist", ok, err)
+	}
+}
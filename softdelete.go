@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// SoftDelete returns a Store that, instead of removing the deleted objects from "s", replaces them with
+// their Tombstones, as ActivityPub servers are expected to do, see Tombstone and Bury.
+//
+// Saving an object over a Tombstone returns ErrGone, so the IDs of the deleted objects can't be reused.
+// Collections are deleted completely.
+func SoftDelete(s Store) Store {
+	return &softDelete{Store: s, now: time.Now}
+}
+
+type softDelete struct {
+	Store
+	now func() time.Time
+}
+
+// Save saves "it" to the underlying store, unless it replaces the Tombstone of a deleted object.
+func (d *softDelete) Save(it pub.Item) (pub.Item, error) {
+	if !pub.IsNil(it) && it.GetType() != pub.TombstoneType && len(it.GetLink()) > 0 {
+		old, err := d.Store.Load(it.GetLink())
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+		if err == nil && old.GetType() == pub.TombstoneType {
+			return nil, fmt.Errorf("unable to save %s: %w", it.GetLink(), ErrGone)
+		}
+	}
+	return d.Store.Save(it)
+}
+
+// Delete replaces the stored version of "it" with its Tombstone, see Bury. Deleting a missing object,
+// or one that has already been deleted, is a no-op.
+func (d *softDelete) Delete(it pub.Item) error {
+	if pub.IsNil(it) {
+		return nil
+	}
+	stored, err := d.Store.Load(it.GetLink())
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if pub.CollectionTypes.Contains(stored.GetType()) {
+		return d.Store.Delete(it)
+	}
+	if stored.GetType() == pub.TombstoneType {
+		return nil
+	}
+	return Bury(d.Store, Tombstone(stored, d.now()))
+}
+
+// Create creates the "col" collection in the underlying store, which needs to be a CollectionStore.
+func (d *softDelete) Create(col pub.CollectionInterface) (pub.CollectionInterface, error) {
+	return asCollectionStore(d.Store).Create(col)
+}
+
+// AddTo adds "it" to the "col" collection in the underlying store, which needs to be a CollectionStore.
+func (d *softDelete) AddTo(col pub.IRI, it pub.Item) error {
+	return asCollectionStore(d.Store).AddTo(col, it)
+}
+
+// RemoveFrom removes "it" from the "col" collection in the underlying store, which needs to be a CollectionStore.
+func (d *softDelete) RemoveFrom(col pub.IRI, it pub.Item) error {
+	return asCollectionStore(d.Store).RemoveFrom(col, it)
+}
@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+func TestSoftDelete(t *testing.T) {
+	m := newBackendMapStore()
+	s := SoftDelete(m)
+
+	ob := note("https://example.com/objects/1", "hello")
+	ob.To = pub.ItemCollection{pub.PublicNS}
+	s.Save(note(ob.ID, "draft"))
+	s.Save(ob)
+	col := pub.OrderedCollectionNew("https://example.com/actors/jdoe/outbox")
+	s.Save(col)
+
+	if err := s.Delete(ob.ID); err != nil {
+		t.Fatalf("Delete returned error: %s", err)
+	}
+	it, err := s.Load(ob.ID)
+	if err != nil {
+		t.Fatalf("Load of a deleted object returned error: %s", err)
+	}
+	tomb, err := pub.ToTombstone(it)
+	if err != nil {
+		t.Fatalf("Load of a deleted object returned %T, expected a Tombstone", it)
+	}
+	if tomb.FormerType != pub.NoteType || tomb.Deleted.IsZero() || !tomb.To.Contains(pub.PublicNS) {
+		t.Errorf("the Tombstone should keep the properties of the stored object, got %#v", tomb)
+	}
+	if versions, _ := m.LoadVersions(ob.ID); len(versions) > 0 {
+		t.Errorf("deleted objects should not keep their versions, got %d", len(versions))
+	}
+
+	if err := s.Delete(ob); err != nil {
+		t.Errorf("Delete of a deleted object returned error: %s", err)
+	}
+	if err := s.Delete(pub.IRI("https://example.com/objects/2")); err != nil {
+		t.Errorf("Delete of a missing object returned error: %s", err)
+	}
+	if _, err := s.Save(note(ob.ID, "again")); !errors.Is(err, ErrGone) {
+		t.Errorf("Save over a Tombstone returned %v, expected %s", err, ErrGone)
+	}
+
+	if err := s.Delete(col); err != nil {
+		t.Fatalf("Delete of a collection returned error: %s", err)
+	}
+	if _, err := m.Load(col.ID); err == nil {
+		t.Errorf("collections should be removed completely")
+	}
+}
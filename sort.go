@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// SortKey is the property by which the members of a collection are ordered.
+type SortKey string
+
+const (
+	// SortInserted orders the members of a collection by the moment they've been added to it,
+	// which is the default.
+	SortInserted SortKey = ""
+	// SortPublished orders the members of a collection by their published property.
+	SortPublished SortKey = "published"
+	// SortUpdated orders the members of a collection by their updated property, or by their published
+	// one for the objects which haven't been updated.
+	SortUpdated SortKey = "updated"
+)
+
+// SortKeyLayout is the layout of the sort keys of the SortPublished and SortUpdated orders, which sort
+// lexicographically in chronological order, so backends with ordered keys can use them as they are.
+const SortKeyLayout = "2006-01-02T15:04:05.000000000Z"
+
+// SortOrder is the order in which the members of a collection are read. The zero value is the
+// reverse chronological order in which they've been added, the order of the OrderedCollectionPages.
+type SortOrder struct {
+	By SortKey
+	// Ascending reads the members oldest first.
+	Ascending bool
+}
+
+// FilterableSort can request the members of a collection in a specific order.
+type FilterableSort interface {
+	Filterable
+	SortOrder() SortOrder
+}
+
+// SortOrderOf returns the SortOrder requested by "f", which is the default one if it's not a FilterableSort.
+func SortOrderOf(f Filterable) SortOrder {
+	if sf, ok := f.(FilterableSort); ok {
+		return sf.SortOrder()
+	}
+	return SortOrder{}
+}
+
+// String returns the SortOrder in the format accepted by ParseSortOrder, eg: "published:asc".
+func (o SortOrder) String() string {
+	if o.Ascending {
+		return string(o.By) + ":asc"
+	}
+	return string(o.By)
+}
+
+// ParseSortOrder parses the SortOrder from "s", which is the name of a SortKey, optionally followed
+// by ":asc", or ":desc". An empty string is the default order.
+func ParseSortOrder(s string) (SortOrder, error) {
+	o := SortOrder{}
+	key, dir, _ := strings.Cut(s, ":")
+	switch SortKey(key) {
+	case SortInserted, SortPublished, SortUpdated:
+		o.By = SortKey(key)
+	default:
+		return SortOrder{}, fmt.Errorf("%w sort order %q", ErrNotValid, s)
+	}
+	switch dir {
+	case "", "desc":
+	case "asc":
+		o.Ascending = true
+	default:
+		return SortOrder{}, fmt.Errorf("%w sort order %q", ErrNotValid, s)
+	}
+	return o, nil
+}
+
+// SortKeyOf returns the key of "it" in the "by" order, formatted as SortKeyLayout, which is empty
+// for SortInserted, and for the items without the property.
+func SortKeyOf(it pub.Item, by SortKey) string {
+	if by == SortInserted || pub.IsNil(it) || !pub.IsObject(it) {
+		return ""
+	}
+	var t time.Time
+	pub.OnObject(it, func(o *pub.Object) error {
+		t = o.Published
+		if by == SortUpdated && !o.Updated.IsZero() {
+			t = o.Updated
+		}
+		return nil
+	})
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(SortKeyLayout)
+}
+
+// SortIRIs orders "iris", which are the members of a collection in reverse chronological order
+// of their insertion, in the "o" order. The sort keys of the members are returned by "key",
+// which backends can read from the indexes they maintain. The members without keys are the oldest.
+func SortIRIs(iris pub.IRIs, o SortOrder, key func(pub.IRI) string) {
+	if o.By == SortInserted {
+		if o.Ascending {
+			reverse(len(iris), func(i, j int) { iris[i], iris[j] = iris[j], iris[i] })
+		}
+		return
+	}
+	keys := make(map[pub.IRI]string, len(iris))
+	for _, iri := range iris {
+		keys[iri] = key(iri)
+	}
+	sort.SliceStable(iris, func(i, j int) bool {
+		if o.Ascending {
+			return keys[iris[i]] < keys[iris[j]]
+		}
+		return keys[iris[i]] > keys[iris[j]]
+	})
+}
+
+// SortMembers orders "items", which are the members of a collection in reverse chronological order
+// of their insertion, in the "o" order. It's meant for backends which don't maintain sort keys,
+// and have loaded the members.
+func SortMembers(items pub.ItemCollection, o SortOrder) {
+	if o.By == SortInserted {
+		if o.Ascending {
+			reverse(len(items), func(i, j int) { items[i], items[j] = items[j], items[i] })
+		}
+		return
+	}
+	keys := make([]string, len(items))
+	for i, it := range items {
+		keys[i] = SortKeyOf(it, o.By)
+	}
+	sort.Stable(memberKeys{items: items, keys: keys, asc: o.Ascending})
+}
+
+func reverse(n int, swap func(i, j int)) {
+	for i, j := 0, n-1; i < j; i, j = i+1, j-1 {
+		swap(i, j)
+	}
+}
+
+// memberKeys sorts the members of a collection together with their keys.
+type memberKeys struct {
+	items pub.ItemCollection
+	keys  []string
+	asc   bool
+}
+
+func (m memberKeys) Len() int {
+	return len(m.items)
+}
+
+func (m memberKeys) Less(i, j int) bool {
+	if m.asc {
+		return m.keys[i] < m.keys[j]
+	}
+	return m.keys[i] > m.keys[j]
+}
+
+func (m memberKeys) Swap(i, j int) {
+	m.items[i], m.items[j] = m.items[j], m.items[i]
+	m.keys[i], m.keys[j] = m.keys[j], m.keys[i]
+}
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+func TestParseSortOrder(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    SortOrder
+		wantErr bool
+	}{
+		{s: ""},
+		{s: "published", want: SortOrder{By: SortPublished}},
+		{s: "updated:asc", want: SortOrder{By: SortUpdated, Ascending: true}},
+		{s: "published:desc", want: SortOrder{By: SortPublished}},
+		{s: "name", wantErr: true},
+		{s: "published:up", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSortOrder(tt.s)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseSortOrder(%q) = %v, %v, expected %v", tt.s, got, err, tt.want)
+		}
+		if err == nil && tt.s != "published:desc" && got.String() != tt.s {
+			t.Errorf("%v.String() = %q, expected %q", got, got.String(), tt.s)
+		}
+	}
+}
+
+func TestSortMembers(t *testing.T) {
+	now := time.Now().UTC()
+	first, second, third := note("https://example.com/objects/1", "1"), note("https://example.com/objects/2", "2"), note("https://example.com/objects/3", "3")
+	first.Published = now.Add(-time.Hour)
+	second.Published = now.Add(-3 * time.Hour)
+	third.Published = now.Add(-2 * time.Hour)
+	second.Updated = now
+
+	// the members in reverse chronological order of their insertion
+	members := pub.ItemCollection{third, second, first}
+	tests := []struct {
+		order SortOrder
+		want  pub.IRIs
+	}{
+		{order: SortOrder{}, want: pub.IRIs{third.ID, second.ID, first.ID}},
+		{order: SortOrder{Ascending: true}, want: pub.IRIs{first.ID, second.ID, third.ID}},
+		{order: SortOrder{By: SortPublished}, want: pub.IRIs{first.ID, third.ID, second.ID}},
+		{order: SortOrder{By: SortPublished, Ascending: true}, want: pub.IRIs{second.ID, third.ID, first.ID}},
+		{order: SortOrder{By: SortUpdated}, want: pub.IRIs{second.ID, first.ID, third.ID}},
+	}
+	keys := func(by SortKey) func(pub.IRI) string {
+		return func(iri pub.IRI) string {
+			for _, m := range members {
+				if m.GetLink() == iri {
+					return SortKeyOf(m, by)
+				}
+			}
+			return ""
+		}
+	}
+	for _, tt := range tests {
+		items := append(pub.ItemCollection{}, members...)
+		SortMembers(items, tt.order)
+		iris := pub.IRIs{third.ID, second.ID, first.ID}
+		SortIRIs(iris, tt.order, keys(tt.order.By))
+		for i, iri := range tt.want {
+			if items[i].GetLink() != iri || iris[i] != iri {
+				t.Errorf("in the %q order, member %d is %s and %s, expected %s", tt.order, i, items[i].GetLink(), iris[i], iri)
+			}
+		}
+	}
+
+	page, _, err := OrderMembers(pub.ItemCollection{first, second, third}, Page{Max: 1, Order: SortOrder{By: SortPublished}})
+	if err != nil || len(page) != 1 || page[0].GetLink() != first.ID {
+		t.Errorf("OrderMembers returned %v, %v, expected the most recently published member", page, err)
+	}
+}
@@ -0,0 +1,165 @@
+// Package sqlite implements a storage backend on top of SQLite, for the small servers that keep all their
+// data in a single file. It uses the modernc.org/sqlite driver, which doesn't need CGO, and the database
+// runs in WAL mode, so the reads don't wait for the writes.
+//
+// The objects are stored as JSON documents, with the id, type, attributedTo and published properties
+// extracted in generated columns which are indexed, so they can be queried with SQL.
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-ap/storage"
+	"github.com/go-ap/storage/internal/sqlstore"
+	_ "modernc.org/sqlite"
+)
+
+// Config holds the options for the SQLite storage.
+type Config struct {
+	// Path is the file in which the database is stored.
+	Path string
+	// CreateIfMissing creates the database and its tables if they don't exist, otherwise New fails
+	// for them, so a wrong path isn't mistaken for an empty storage. See Bootstrap.
+	CreateIfMissing bool
+	// Timeout is how long the operations wait for the lock of the database, while another process,
+	// or connection, writes to it, before failing. It's 5 seconds if not set.
+	Timeout time.Duration
+	// ReadOnly opens an existing database without modifying it, with all the write operations returning
+	// storage.ErrReadOnly, eg: for maintenance tools, or replicas serving GET requests.
+	ReadOnly bool
+	// MaxOpenConns is the maximum number of connections to the database, unlimited if not set. Only one
+	// of them writes at a time, the others wait for the lock, see Timeout.
+	MaxOpenConns int
+	// MaxIdleConns is the maximum number of idle connections kept open, 2 if not set, or none if negative.
+	MaxIdleConns int
+	// ConnMaxLifetime is how long the connections are reused before being closed, forever if not set.
+	ConnMaxLifetime time.Duration
+	// QueryTimeout is how long each operation waits for the database, before failing with
+	// context.DeadlineExceeded. It waits for as long as the database needs if it's not set.
+	QueryTimeout time.Duration
+}
+
+// defaultTimeout is the Timeout of the storages which don't set one.
+const defaultTimeout = 5 * time.Second
+
+type repo struct {
+	*sqlstore.Store
+}
+
+var (
+	_ storage.Store           = &repo{}
+	_ storage.CollectionStore = &repo{}
+	_ storage.ChangeFeed      = &repo{}
+	_ storage.SchemaStore     = &repo{}
+	_ storage.BackupStore     = &repo{}
+	_ storage.StatsStore      = &repo{}
+	_ io.Closer               = &repo{}
+)
+
+var dialect = sqlstore.Dialect{
+	Schema: []string{
+		`CREATE TABLE IF NOT EXISTS items (
+			raw TEXT NOT NULL,
+			id TEXT GENERATED ALWAYS AS (json_extract(raw, '$.id')) STORED NOT NULL,
+			type TEXT GENERATED ALWAYS AS (json_extract(raw, '$.type')) STORED,
+			attributed_to TEXT GENERATED ALWAYS AS (COALESCE(json_extract(raw, '$.attributedTo.id'), json_extract(raw, '$.attributedTo'))) STORED,
+			published TEXT GENERATED ALWAYS AS (json_extract(raw, '$.published')) STORED
+		)`,
+		// SQLite doesn't allow generated columns in the primary keys
+		`CREATE UNIQUE INDEX IF NOT EXISTS items_id ON items (id)`,
+		`CREATE INDEX IF NOT EXISTS items_type ON items (type)`,
+		`CREATE INDEX IF NOT EXISTS items_attributed_to ON items (attributed_to)`,
+		`CREATE INDEX IF NOT EXISTS items_published ON items (published)`,
+		`CREATE TABLE IF NOT EXISTS collections (
+			iri TEXT PRIMARY KEY,
+			raw TEXT NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS members (
+			seq INTEGER PRIMARY KEY AUTOINCREMENT,
+			collection TEXT NOT NULL REFERENCES collections (iri) ON DELETE CASCADE,
+			member TEXT NOT NULL,
+			UNIQUE (collection, member)
+		)`,
+		`CREATE INDEX IF NOT EXISTS members_collection ON members (collection, seq)`,
+	},
+	Upgrades: [][]string{
+		// the tables created by Schema are the first version
+		nil,
+	},
+	UpsertItem:       "INSERT INTO items (raw) VALUES (?) ON CONFLICT (id) DO UPDATE SET raw = excluded.raw",
+	UpsertCollection: "INSERT INTO collections (iri, raw) VALUES (?, ?) ON CONFLICT (iri) DO UPDATE SET raw = excluded.raw",
+	InsertCollection: "INSERT INTO collections (iri, raw) VALUES (?, ?) ON CONFLICT (iri) DO NOTHING",
+	InsertMember:     "INSERT INTO members (collection, member) VALUES (?, ?) ON CONFLICT (collection, member) DO NOTHING",
+}
+
+// New opens the SQLite database in the c.Path file, creating it and its tables if they don't exist
+// and c.CreateIfMissing is set.
+func New(c Config) (*repo, error) {
+	if len(c.Path) == 0 {
+		return nil, fmt.Errorf("%w: the path of the storage is empty", storage.ErrNotValid)
+	}
+	if !c.CreateIfMissing || c.ReadOnly {
+		if _, err := os.Stat(c.Path); err != nil {
+			return nil, fmt.Errorf("unable to open the sqlite storage %s, see Bootstrap: %w", c.Path, err)
+		}
+	}
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	db, err := sql.Open("sqlite", dsn(c.Path, timeout, c.ReadOnly))
+	if err != nil {
+		return nil, fmt.Errorf("unable to open the sqlite storage %s: %w", c.Path, err)
+	}
+	s, err := sqlstore.New(db, dialect, c.options())
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("unable to open the sqlite storage %s: %w", c.Path, err)
+	}
+	return &repo{Store: s}, nil
+}
+
+func (c Config) options() sqlstore.Options {
+	return sqlstore.Options{
+		CreateIfMissing: c.CreateIfMissing,
+		ReadOnly:        c.ReadOnly,
+		MaxOpenConns:    c.MaxOpenConns,
+		MaxIdleConns:    c.MaxIdleConns,
+		ConnMaxLifetime: c.ConnMaxLifetime,
+		QueryTimeout:    c.QueryTimeout,
+	}
+}
+
+// Bootstrap creates the SQLite database in the c.Path file, and its tables, if they don't exist, and
+// records the version of their schema, so the first run of a service doesn't need any manual setup.
+func Bootstrap(c Config) error {
+	c.CreateIfMissing = true
+	r, err := New(c)
+	if err != nil {
+		return err
+	}
+	return r.Close()
+}
+
+// dsn returns the connection string of the "path" database, which enables WAL mode and the foreign keys,
+// and waits up to "timeout" for the locks. In "readOnly" mode the database is opened without write access.
+// The transactions take the write lock when they start, as the ones upgrading a read lock fail right away
+// when another one writes, instead of waiting for the busy timeout.
+func dsn(path string, timeout time.Duration, readOnly bool) string {
+	q := url.Values{}
+	if readOnly {
+		q.Set("mode", "ro")
+	} else {
+		q.Add("_pragma", "journal_mode(WAL)")
+	}
+	q.Add("_pragma", "busy_timeout("+strconv.FormatInt(timeout.Milliseconds(), 10)+")")
+	q.Add("_pragma", "foreign_keys(1)")
+	q.Set("_txlock", "immediate")
+	return "file:" + path + "?" + q.Encode()
+}
@@ -0,0 +1,236 @@
+package sqlite
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage"
+	"github.com/go-ap/storage/storagetest"
+)
+
+func newTestRepo(t *testing.T) *repo {
+	r, err := New(Config{Path: filepath.Join(t.TempDir(), "storage.sqlite"), CreateIfMissing: true})
+	if err != nil {
+		t.Fatalf("New returned error: %s", err)
+	}
+	t.Cleanup(func() { r.Close() })
+	return r
+}
+
+func TestConformance(t *testing.T) {
+	storagetest.RunStoreTests(t, func() storage.Store { return newTestRepo(t) })
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(Config{}); !errors.Is(err, storage.ErrNotValid) {
+		t.Errorf("New without a path returned %v, expected %s", err, storage.ErrNotValid)
+	}
+
+	path := filepath.Join(t.TempDir(), "storage.sqlite")
+	r, err := New(Config{Path: path, CreateIfMissing: true})
+	if err != nil {
+		t.Fatalf("New returned error: %s", err)
+	}
+	var mode string
+	if err := r.DB().QueryRow("PRAGMA journal_mode").Scan(&mode); err != nil || mode != "wal" {
+		t.Errorf("the journal mode is %q, %v, expected wal", mode, err)
+	}
+	var timeout int
+	if err := r.DB().QueryRow("PRAGMA busy_timeout").Scan(&timeout); err != nil || timeout != int(defaultTimeout.Milliseconds()) {
+		t.Errorf("the busy timeout is %d, %v, expected %d", timeout, err, defaultTimeout.Milliseconds())
+	}
+	outbox := pub.IRI("https://example.com/actors/jdoe/outbox")
+	ob := &pub.Object{ID: "https://example.com/objects/1", Type: pub.NoteType}
+	r.Save(ob)
+	r.Create(pub.OrderedCollectionNew(outbox))
+	r.AddTo(outbox, ob)
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close returned error: %s", err)
+	}
+
+	r, err = New(Config{Path: path})
+	if err != nil {
+		t.Fatalf("New returned error: %s", err)
+	}
+	defer r.Close()
+	it, err := r.Load(outbox)
+	if err != nil {
+		t.Fatalf("Load returned error: %s", err)
+	}
+	col, ok := it.(*pub.OrderedCollection)
+	if !ok || len(col.OrderedItems) != 1 || col.OrderedItems[0].GetType() != pub.NoteType {
+		t.Errorf("Load after reopening the storage returned %#v, expected the collection with the object", it)
+	}
+	var typ string
+	if err := r.DB().QueryRow("SELECT type FROM items WHERE id = ?", ob.ID.String()).Scan(&typ); err != nil || typ != string(pub.NoteType) {
+		t.Errorf("the type column of the object is %q, %v, expected %s", typ, err, pub.NoteType)
+	}
+}
+
+func TestBootstrap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.sqlite")
+	if _, err := New(Config{Path: path}); err == nil {
+		t.Errorf("New of a missing storage should fail without CreateIfMissing")
+	}
+	if _, err := os.Stat(path); err == nil {
+		t.Errorf("New without CreateIfMissing created the database file")
+	}
+	if err := Bootstrap(Config{Path: path}); err != nil {
+		t.Fatalf("Bootstrap returned error: %s", err)
+	}
+	r, err := New(Config{Path: path})
+	if err != nil {
+		t.Fatalf("New after Bootstrap returned error: %s", err)
+	}
+	defer r.Close()
+	if v, err := r.SchemaVersion(); err != nil || v == 0 {
+		t.Errorf("SchemaVersion returned %d, %v, expected Bootstrap to record the version", v, err)
+	}
+
+	// a database without the tables of the storage
+	empty := filepath.Join(t.TempDir(), "empty.sqlite")
+	os.WriteFile(empty, nil, 0o600)
+	if _, err := New(Config{Path: empty}); err == nil {
+		t.Errorf("New of a database without the tables should fail without CreateIfMissing")
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	r := newTestRepo(t)
+	events, cancel := r.Subscribe(pub.IRI("https://example.com/actors/jdoe"))
+	defer cancel()
+
+	outbox := pub.IRI("https://example.com/actors/jdoe/outbox")
+	ob := &pub.Object{ID: "https://example.com/objects/1", Type: pub.NoteType}
+	r.Save(ob)
+	r.Create(pub.OrderedCollectionNew(outbox))
+	r.Create(pub.OrderedCollectionNew(outbox))
+	r.AddTo(outbox, ob)
+	r.AddTo(outbox, ob)
+	r.RemoveFrom(outbox, ob)
+	r.Delete(outbox)
+
+	want := []storage.EventType{storage.EventCreate, storage.EventAdd, storage.EventRemove, storage.EventDelete}
+	for _, typ := range want {
+		select {
+		case e := <-events:
+			if e.Type != typ {
+				t.Errorf("received a %s event, expected %s", e.Type, typ)
+			}
+		default:
+			t.Fatalf("no %s event received", typ)
+		}
+	}
+	select {
+	case e := <-events:
+		t.Errorf("received %v, expected only the events of the changes under the IRI of the subscription", e)
+	default:
+	}
+}
+
+func TestUpgrade(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.sqlite")
+	r, err := New(Config{Path: path, CreateIfMissing: true})
+	if err != nil {
+		t.Fatalf("New returned error: %s", err)
+	}
+	current := len(dialect.Upgrades)
+	if v, err := r.SchemaVersion(); err != nil || v != current {
+		t.Errorf("SchemaVersion returned %d, %v, expected %d", v, err, current)
+	}
+
+	// a database created before recording the schema version
+	r.DB().Exec("DELETE FROM schema_version")
+	if v, _ := r.SchemaVersion(); v != 0 {
+		t.Errorf("SchemaVersion returned %d for an unversioned database, expected 0", v)
+	}
+	if err := r.Upgrade(); err != nil {
+		t.Fatalf("Upgrade returned error: %s", err)
+	}
+	if v, _ := r.SchemaVersion(); v != current {
+		t.Errorf("SchemaVersion returned %d after Upgrade, expected %d", v, current)
+	}
+
+	r.DB().Exec("UPDATE schema_version SET version = ?", current+1)
+	r.Close()
+	if _, err := New(Config{Path: path}); !errors.Is(err, storage.ErrSchemaVersion) {
+		t.Errorf("New returned %v for a newer schema version, expected %v", err, storage.ErrSchemaVersion)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.sqlite")
+	if _, err := New(Config{Path: path, ReadOnly: true}); err == nil {
+		t.Errorf("New in read-only mode should fail for a missing storage")
+	}
+	w, err := New(Config{Path: path, CreateIfMissing: true})
+	if err != nil {
+		t.Fatalf("New returned error: %s", err)
+	}
+	ob := &pub.Object{ID: "https://example.com/objects/1", Type: pub.NoteType}
+	w.Save(ob)
+	w.Close()
+
+	r, err := New(Config{Path: path, ReadOnly: true})
+	if err != nil {
+		t.Fatalf("New returned error: %s", err)
+	}
+	defer r.Close()
+	if _, err := r.Load(ob.ID); err != nil {
+		t.Errorf("Load returned error: %s", err)
+	}
+	if _, err := r.Save(ob); !errors.Is(err, storage.ErrReadOnly) {
+		t.Errorf("Save returned %v, expected %s", err, storage.ErrReadOnly)
+	}
+	if err := r.Delete(ob); !errors.Is(err, storage.ErrReadOnly) {
+		t.Errorf("Delete returned %v, expected %s", err, storage.ErrReadOnly)
+	}
+	if _, err := r.Create(pub.OrderedCollectionNew("https://example.com/actors/jdoe/outbox")); !errors.Is(err, storage.ErrReadOnly) {
+		t.Errorf("Create returned %v, expected %s", err, storage.ErrReadOnly)
+	}
+	if err := r.AddTo("https://example.com/actors/jdoe/outbox", ob); !errors.Is(err, storage.ErrReadOnly) {
+		t.Errorf("AddTo returned %v, expected %s", err, storage.ErrReadOnly)
+	}
+}
+
+func TestBackupRestore(t *testing.T) {
+	r := newTestRepo(t)
+	outbox := pub.IRI("https://example.com/actors/jdoe/outbox")
+	ob := &pub.Object{ID: "https://example.com/objects/1", Type: pub.NoteType}
+	r.Save(ob)
+	r.Create(pub.OrderedCollectionNew(outbox))
+	r.AddTo(outbox, ob)
+
+	buf := bytes.Buffer{}
+	if err := storage.Backup(r, &buf); err != nil {
+		t.Fatalf("Backup returned error: %s", err)
+	}
+	restored := newTestRepo(t)
+	if err := storage.Restore(restored, bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("Restore returned error: %s", err)
+	}
+	it, err := restored.Load(outbox)
+	if err != nil {
+		t.Fatalf("Load returned error: %s", err)
+	}
+	col, ok := it.(*pub.OrderedCollection)
+	if !ok || len(col.OrderedItems) != 1 || col.OrderedItems[0].GetType() != pub.NoteType {
+		t.Errorf("Load after Restore returned %#v, expected the collection with the restored object", it)
+	}
+}
+
+func TestNew_pool(t *testing.T) {
+	c := Config{Path: filepath.Join(t.TempDir(), "storage.sqlite"), CreateIfMissing: true, MaxOpenConns: 4}
+	r, err := New(c)
+	if err != nil {
+		t.Fatalf("New returned error: %s", err)
+	}
+	defer r.Close()
+	if n := r.DB().Stats().MaxOpenConnections; n != c.MaxOpenConns {
+		t.Errorf("the pool allows %d connections, expected %d", n, c.MaxOpenConns)
+	}
+}
@@ -1,12 +1,19 @@
 package storage
 
 import (
+	"io"
+
 	pub "github.com/go-ap/activitypub"
 )
 
+// Store is the interface that the storage backends implement.
+//
+// Close releases the resources the backend holds, like file handles, locks or connections, and the callers
+// should call it when they're done with the store. After it, the operations of the store return ErrClosed.
 type Store interface {
 	ReadStore
 	WriteStore
+	io.Closer
 }
 
 // ReadStore
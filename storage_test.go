@@ -0,0 +1,245 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// backendMapStore is a mapStore implementing the optional interfaces the way the backends do, for testing
+// the helpers that use them. The collections are kept with their members as IRIs, which get loaded as the
+// stored items, and the data kept with an item, its versions, counters, metadata and binary data, is
+// deleted together with it.
+type backendMapStore struct {
+	*mapStore
+	members  map[pub.IRI]pub.IRIs
+	versions map[pub.IRI]pub.ItemCollection
+	counters map[pub.IRI]map[string]int
+	metadata map[pub.IRI]map[string][]byte
+	binaries map[pub.IRI]binaryData
+	// txs is the number of transactions that have been used.
+	txs int
+}
+
+type binaryData struct {
+	data        []byte
+	contentType string
+}
+
+var (
+	_ CollectionStore = &backendMapStore{}
+	_ IterateStore    = &backendMapStore{}
+	_ CounterStore    = &backendMapStore{}
+	_ MetadataStore   = &backendMapStore{}
+	_ VersionedStore  = &backendMapStore{}
+	_ BinaryStore     = &backendMapStore{}
+	_ TxStore         = &backendMapStore{}
+)
+
+func newBackendMapStore() *backendMapStore {
+	return &backendMapStore{
+		mapStore: newMapStore(),
+		members:  make(map[pub.IRI]pub.IRIs),
+		versions: make(map[pub.IRI]pub.ItemCollection),
+		counters: make(map[pub.IRI]map[string]int),
+		metadata: make(map[pub.IRI]map[string][]byte),
+		binaries: make(map[pub.IRI]binaryData),
+	}
+}
+
+func (b *backendMapStore) Load(iri pub.IRI) (pub.Item, error) {
+	it, err := b.mapStore.Load(iri)
+	if err != nil {
+		return nil, err
+	}
+	members, ok := b.members[iri]
+	if !ok {
+		return it, nil
+	}
+	doc := DocumentOf(it.(pub.CollectionInterface))
+	doc.Items = members
+	col := doc.Collection()
+	items := col.Collection()
+	for i, member := range items {
+		if ob, err := b.mapStore.Load(member.GetLink()); err == nil {
+			items[i] = ob
+		}
+	}
+	return col, nil
+}
+
+func (b *backendMapStore) Save(it pub.Item) (pub.Item, error) {
+	iri := it.GetLink()
+	if col, ok := it.(pub.CollectionInterface); ok && pub.CollectionTypes.Contains(it.GetType()) {
+		b.members[iri] = DocumentOf(col).Items
+	} else if old, err := b.mapStore.Load(iri); err == nil {
+		b.versions[iri] = append(pub.ItemCollection{old}, b.versions[iri]...)
+	}
+	return b.mapStore.Save(it)
+}
+
+func (b *backendMapStore) Delete(it pub.Item) error {
+	iri := it.GetLink()
+	delete(b.members, iri)
+	delete(b.versions, iri)
+	delete(b.counters, iri)
+	delete(b.metadata, iri)
+	delete(b.binaries, iri)
+	return b.mapStore.Delete(it)
+}
+
+func (b *backendMapStore) Create(col pub.CollectionInterface) (pub.CollectionInterface, error) {
+	if it, err := b.Load(col.GetLink()); err == nil {
+		return it.(pub.CollectionInterface), nil
+	}
+	_, err := b.Save(col)
+	return col, err
+}
+
+func (b *backendMapStore) AddTo(col pub.IRI, it pub.Item) error {
+	members, ok := b.members[col]
+	if !ok {
+		return fmt.Errorf("unable to find collection %s: %w", col, ErrNotFound)
+	}
+	// the members are replaced, not modified, so the snapshots of the transactions stay valid
+	b.members[col] = append(append(pub.IRIs{}, members...), it.GetLink())
+	return nil
+}
+
+func (b *backendMapStore) RemoveFrom(col pub.IRI, it pub.Item) error {
+	members, ok := b.members[col]
+	if !ok {
+		return fmt.Errorf("unable to find collection %s: %w", col, ErrNotFound)
+	}
+	kept := make(pub.IRIs, 0, len(members))
+	for _, member := range members {
+		if member != it.GetLink() {
+			kept = append(kept, member)
+		}
+	}
+	b.members[col] = kept
+	return nil
+}
+
+// Each calls "fn" for the stored items, including the collections, under the IRI of "f", in the order of their IRIs.
+func (b *backendMapStore) Each(f Filterable, fn func(pub.Item) error) error {
+	iris := make(pub.IRIs, 0, len(b.items))
+	for iri := range b.items {
+		if UnderIRI(iri, f.GetLink()) {
+			iris = append(iris, iri)
+		}
+	}
+	sort.Slice(iris, func(i, j int) bool { return iris[i] < iris[j] })
+	for _, iri := range iris {
+		it, err := b.Load(iri)
+		if err != nil || !MatchItem(f, it) {
+			continue
+		}
+		if err := fn(it); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *backendMapStore) IncrementCounter(iri pub.IRI, field string, delta int) (int, error) {
+	counters := make(map[string]int)
+	for k, v := range b.counters[iri] {
+		counters[k] = v
+	}
+	counters[field] += delta
+	b.counters[iri] = counters
+	return counters[field], nil
+}
+
+func (b *backendMapStore) LoadCounters(iri pub.IRI) (map[string]int, error) {
+	return b.counters[iri], nil
+}
+
+func (b *backendMapStore) LoadMetadata(iri pub.IRI, namespace string) ([]byte, error) {
+	data, ok := b.metadata[iri][namespace]
+	if !ok {
+		return nil, fmt.Errorf("missing %s metadata: %w", namespace, ErrNotFound)
+	}
+	return data, nil
+}
+
+func (b *backendMapStore) SaveMetadata(iri pub.IRI, namespace string, data []byte) error {
+	metadata := map[string][]byte{namespace: data}
+	for ns, data := range b.metadata[iri] {
+		if ns != namespace {
+			metadata[ns] = data
+		}
+	}
+	b.metadata[iri] = metadata
+	return nil
+}
+
+func (b *backendMapStore) LoadVersions(iri pub.IRI) (pub.ItemCollection, error) {
+	if _, err := b.mapStore.Load(iri); err != nil {
+		return nil, err
+	}
+	return b.versions[iri], nil
+}
+
+func (b *backendMapStore) SaveBinary(iri pub.IRI, r io.Reader, contentType string) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	b.binaries[iri] = binaryData{data: data, contentType: contentType}
+	return nil
+}
+
+func (b *backendMapStore) LoadBinary(iri pub.IRI) (io.ReadCloser, string, error) {
+	bin, ok := b.binaries[iri]
+	if !ok {
+		return nil, "", fmt.Errorf("missing binary data for %s: %w", iri, ErrNotFound)
+	}
+	return io.NopCloser(bytes.NewReader(bin.data)), bin.contentType, nil
+}
+
+func (b *backendMapStore) DeleteBinary(iri pub.IRI) error {
+	delete(b.binaries, iri)
+	return nil
+}
+
+// WithTx calls "fn" with the store itself, and restores the state it had before, if "fn" fails.
+func (b *backendMapStore) WithTx(fn func(Store) error) error {
+	b.txs++
+	snapshot := b.clone()
+	if err := fn(b); err != nil {
+		snapshot.txs = b.txs
+		*b = *snapshot
+		return err
+	}
+	return nil
+}
+
+// clone returns a copy of the store, whose maps can be modified without affecting the original.
+// It relies on the values of the maps being replaced, instead of modified, by the store operations.
+func (b *backendMapStore) clone() *backendMapStore {
+	cp := newBackendMapStore()
+	for iri, it := range b.items {
+		cp.items[iri] = it
+	}
+	for iri, members := range b.members {
+		cp.members[iri] = members
+	}
+	for iri, versions := range b.versions {
+		cp.versions[iri] = versions
+	}
+	for iri, counters := range b.counters {
+		cp.counters[iri] = counters
+	}
+	for iri, metadata := range b.metadata {
+		cp.metadata[iri] = metadata
+	}
+	for iri, bin := range b.binaries {
+		cp.binaries[iri] = bin
+	}
+	return cp
+}
@@ -0,0 +1,479 @@
+// Package storagetest provides a conformance test suite for the implementations of the storage interfaces.
+//
+// Backends run it from their own tests, passing a function that returns a new, empty, instance:
+//
+//	func TestConformance(t *testing.T) {
+//		storagetest.RunStoreTests(t, func() storage.Store { return New() })
+//	}
+//
+// The tests for the optional interfaces, like storage.CollectionStore, are run only if the
+// returned Store implements them.
+package storagetest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage"
+)
+
+// RunStoreTests checks that the stores returned by "factory" satisfy the contracts of the storage
+// interfaces. Each test gets a new store.
+func RunStoreTests(t *testing.T, factory func() storage.Store) {
+	tests := []struct {
+		name string
+		fn   func(*testing.T, storage.Store)
+	}{
+		{name: "SaveLoad", fn: testSaveLoad},
+		{name: "Overwrite", fn: testOverwrite},
+		{name: "Delete", fn: testDelete},
+		{name: "Collections", fn: testCollections},
+		{name: "Ordering", fn: testOrdering},
+		{name: "Tombstones", fn: testTombstones},
+		{name: "Update", fn: testUpdate},
+		{name: "Versions", fn: testVersions},
+		{name: "Deliveries", fn: testDeliveries},
+		{name: "Binaries", fn: testBinaries},
+		{name: "Concurrency", fn: testConcurrency},
+		{name: "Close", fn: testClose},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, factory())
+		})
+	}
+}
+
+func note(iri pub.IRI, content string) *pub.Object {
+	ob := pub.ObjectNew(pub.NoteType)
+	ob.ID = iri
+	ob.Content.Set(pub.NilLangRef, pub.Content(content))
+	return ob
+}
+
+func testSaveLoad(t *testing.T, s storage.Store) {
+	ob := note("https://example.com/objects/1", "hello")
+	if _, err := s.Save(ob); err != nil {
+		t.Fatalf("Save returned error: %s", err)
+	}
+	it, err := s.Load(ob.ID)
+	if err != nil {
+		t.Fatalf("Load returned error: %s", err)
+	}
+	if !ob.Equals(it) {
+		t.Errorf("Load returned %#v, expected %#v", it, ob)
+	}
+	if _, err := s.Load("https://example.com/objects/2"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Load of a missing object returned %v, expected %s", err, storage.ErrNotFound)
+	}
+	if _, err := s.Save(pub.ObjectNew(pub.NoteType)); !errors.Is(err, storage.ErrNotValid) {
+		t.Errorf("Save of an object without ID returned %v, expected %s", err, storage.ErrNotValid)
+	}
+}
+
+func testOverwrite(t *testing.T, s storage.Store) {
+	iri := pub.IRI("https://example.com/objects/1")
+	s.Save(note(iri, "first"))
+	if _, err := s.Save(note(iri, "second")); err != nil {
+		t.Fatalf("Save returned error: %s", err)
+	}
+	it, err := s.Load(iri)
+	if err != nil {
+		t.Fatalf("Load returned error: %s", err)
+	}
+	if !note(iri, "second").Equals(it) {
+		t.Errorf("Load returned %#v, expected the last saved version", it)
+	}
+}
+
+func testDelete(t *testing.T, s storage.Store) {
+	ob := note("https://example.com/objects/1", "hello")
+	s.Save(ob)
+	if err := s.Delete(ob); err != nil {
+		t.Fatalf("Delete returned error: %s", err)
+	}
+	if _, err := s.Load(ob.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Load after Delete returned %v, expected %s", err, storage.ErrNotFound)
+	}
+	if err := s.Delete(ob); err != nil {
+		t.Errorf("Delete of a missing object returned error: %s", err)
+	}
+}
+
+func collectionStore(t *testing.T, s storage.Store) storage.CollectionStore {
+	cs, ok := s.(storage.CollectionStore)
+	if !ok {
+		t.Skipf("%T is not a CollectionStore", s)
+	}
+	return cs
+}
+
+func members(t *testing.T, s storage.Store, col pub.IRI) pub.ItemCollection {
+	it, err := s.Load(col)
+	if err != nil {
+		t.Fatalf("Load of collection %s returned error: %s", col, err)
+	}
+	c, ok := it.(pub.CollectionInterface)
+	if !ok {
+		t.Fatalf("Load of collection %s returned %T", col, it)
+	}
+	return c.Collection()
+}
+
+func testCollections(t *testing.T, s storage.Store) {
+	cs := collectionStore(t, s)
+	outbox := pub.IRI("https://example.com/actors/jdoe/outbox")
+	ob := note("https://example.com/objects/1", "hello")
+	s.Save(ob)
+
+	if err := cs.AddTo(outbox, ob); err == nil {
+		t.Errorf("AddTo a missing collection should fail")
+	}
+	if _, err := cs.Create(pub.OrderedCollectionNew(outbox)); err != nil {
+		t.Fatalf("Create returned error: %s", err)
+	}
+	if _, err := cs.Create(pub.OrderedCollectionNew(outbox)); err != nil {
+		t.Errorf("Create of an existing collection returned error: %s", err)
+	}
+	if err := cs.AddTo(outbox, nil); !errors.Is(err, storage.ErrNotValid) {
+		t.Errorf("AddTo of a nil item returned %v, expected %s", err, storage.ErrNotValid)
+	}
+	if err := cs.RemoveFrom(outbox, nil); !errors.Is(err, storage.ErrNotValid) {
+		t.Errorf("RemoveFrom of a nil item returned %v, expected %s", err, storage.ErrNotValid)
+	}
+	if ms, ok := s.(storage.MembershipStore); ok {
+		if _, err := ms.IsMember(outbox, nil); !errors.Is(err, storage.ErrNotValid) {
+			t.Errorf("IsMember of a nil item returned %v, expected %s", err, storage.ErrNotValid)
+		}
+	}
+	for _, it := range []pub.Item{ob, pub.IRI("https://example.com/objects/2"), ob} {
+		if err := cs.AddTo(outbox, it); err != nil {
+			t.Fatalf("AddTo returned error: %s", err)
+		}
+	}
+	items := members(t, s, outbox)
+	if len(items) != 2 {
+		t.Fatalf("expected 2 members after adding one of them twice, got %v", items)
+	}
+	if !items.Contains(ob.ID) || items.Contains(outbox) {
+		t.Errorf("invalid collection members %v", items)
+	}
+	for _, it := range items {
+		if it.GetLink() == ob.ID && it.GetType() != pub.NoteType {
+			t.Errorf("expected stored members to be loaded, got %v", it)
+		}
+	}
+
+	if err := cs.RemoveFrom(outbox, ob); err != nil {
+		t.Fatalf("RemoveFrom returned error: %s", err)
+	}
+	if items := members(t, s, outbox); len(items) != 1 || items.Contains(ob.ID) {
+		t.Errorf("invalid collection members after RemoveFrom %v", items)
+	}
+	if _, err := s.Load(ob.ID); err != nil {
+		t.Errorf("RemoveFrom should not delete the item: %s", err)
+	}
+}
+
+func testOrdering(t *testing.T, s storage.Store) {
+	cs := collectionStore(t, s)
+	outbox := pub.IRI("https://example.com/actors/jdoe/outbox")
+	cs.Create(pub.OrderedCollectionNew(outbox))
+	iris := make([]pub.IRI, 0)
+	for i := 1; i <= 5; i++ {
+		iri := pub.IRI(fmt.Sprintf("https://example.com/objects/%d", i))
+		s.Save(note(iri, "hello"))
+		cs.AddTo(outbox, iri)
+		iris = append(iris, iri)
+	}
+
+	items := members(t, s, outbox)
+	for i, it := range items {
+		if it.GetLink() != iris[i] {
+			t.Fatalf("Load returned the members %v, expected them in the order they were added %v", items, iris)
+		}
+	}
+
+	if _, ok := s.(storage.OrderedCollectionStore); !ok {
+		return
+	}
+	items, next, err := storage.LoadCollection(s, outbox, storage.Page{Max: 3})
+	if err != nil {
+		t.Fatalf("LoadCollection returned error: %s", err)
+	}
+	if len(items) != 3 || items[0].GetLink() != iris[4] || items[2].GetLink() != iris[2] || len(next) == 0 {
+		t.Fatalf("LoadCollection returned %v, expected the 3 most recent members", items)
+	}
+	items, next, err = storage.LoadCollection(s, outbox, storage.Page{Max: 3, After: next})
+	if err != nil {
+		t.Fatalf("LoadCollection returned error: %s", err)
+	}
+	if len(items) != 2 || items[0].GetLink() != iris[1] || items[1].GetLink() != iris[0] || len(next) > 0 {
+		t.Errorf("LoadCollection returned %v, %q, expected the 2 oldest members and no next page", items, next)
+	}
+}
+
+func testTombstones(t *testing.T, s storage.Store) {
+	bs, ok := s.(storage.BulkDeleteStore)
+	if !ok {
+		t.Skipf("%T is not a BulkDeleteStore", s)
+	}
+	ob := note("https://example.com/objects/1", "hello")
+	s.Save(ob)
+
+	count, err := bs.DeleteMatching(ob.ID)
+	if err != nil {
+		t.Fatalf("DeleteMatching returned error: %s", err)
+	}
+	if count != 1 {
+		t.Errorf("DeleteMatching returned %d, expected 1", count)
+	}
+	it, err := s.Load(ob.ID)
+	if err != nil {
+		t.Fatalf("Load of a deleted object returned error: %s", err)
+	}
+	tomb, err := pub.ToTombstone(it)
+	if err != nil {
+		t.Fatalf("Load of a deleted object returned %T, expected a Tombstone", it)
+	}
+	if tomb.ID != ob.ID || tomb.FormerType != ob.Type || tomb.Deleted.IsZero() {
+		t.Errorf("invalid Tombstone %#v", tomb)
+	}
+}
+
+func testUpdate(t *testing.T, s storage.Store) {
+	us, ok := s.(storage.UpdateStore)
+	if !ok {
+		t.Skipf("%T is not an UpdateStore", s)
+	}
+	ob := note("https://example.com/objects/1", "hello")
+	if _, err := us.Update(ob, time.Time{}); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Update of a missing object returned %v, expected %s", err, storage.ErrNotFound)
+	}
+	s.Save(ob)
+
+	first, err := us.Update(note(ob.ID, "first"), time.Time{})
+	if err != nil {
+		t.Fatalf("Update returned error: %s", err)
+	}
+	rev := storage.Revision(first)
+	if rev.IsZero() {
+		t.Fatalf("Update should set the revision of the object")
+	}
+	if _, err := us.Update(note(ob.ID, "concurrent"), time.Time{}); !errors.Is(err, storage.ErrConflict) {
+		t.Errorf("Update with a stale revision returned %v, expected %s", err, storage.ErrConflict)
+	}
+	second, err := us.Update(note(ob.ID, "second"), rev)
+	if err != nil {
+		t.Fatalf("Update with the current revision returned error: %s", err)
+	}
+	if !storage.Revision(second).After(rev) {
+		t.Errorf("Update should increment the revision, got %s after %s", storage.Revision(second), rev)
+	}
+	it, _ := s.Load(ob.ID)
+	if !storage.Revision(it).Equal(storage.Revision(second)) {
+		t.Errorf("Load returned revision %s, expected %s", storage.Revision(it), storage.Revision(second))
+	}
+}
+
+func testDeliveries(t *testing.T, s storage.Store) {
+	qs, ok := s.(storage.DeliveryQueueStore)
+	if !ok {
+		t.Skipf("%T is not a DeliveryQueueStore", s)
+	}
+	activity := pub.IRI("https://example.com/activities/1")
+	first, err := qs.Enqueue(activity, "https://example.org/inbox")
+	if err != nil {
+		t.Fatalf("Enqueue returned error: %s", err)
+	}
+	if again, _ := qs.Enqueue(activity, "https://example.org/inbox"); again.ID != first.ID {
+		t.Errorf("Enqueue of a pending delivery returned %s, expected the existing %s", again.ID, first.ID)
+	}
+	second, _ := qs.Enqueue(activity, "https://example.net/inbox")
+
+	due, err := qs.Dequeue(10, time.Minute)
+	if err != nil {
+		t.Fatalf("Dequeue returned error: %s", err)
+	}
+	if len(due) != 2 || due[0].ID != first.ID || due[1].ID != second.ID {
+		t.Fatalf("Dequeue returned %v, expected the deliveries in the order they've been enqueued", due)
+	}
+	if due, _ := qs.Dequeue(10, time.Minute); len(due) != 0 {
+		t.Errorf("Dequeue returned leased deliveries %v", due)
+	}
+
+	if err := qs.MarkDelivered(first.ID); err != nil {
+		t.Errorf("MarkDelivered returned error: %s", err)
+	}
+	if err := qs.MarkDelivered(first.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("MarkDelivered of a removed delivery returned %v, expected %s", err, storage.ErrNotFound)
+	}
+	if err := qs.MarkFailed(second.ID, errors.New("unavailable"), time.Now().Add(-time.Second)); err != nil {
+		t.Errorf("MarkFailed returned error: %s", err)
+	}
+	due, _ = qs.Dequeue(10, time.Minute)
+	if len(due) != 1 || due[0].ID != second.ID || due[0].Attempts != 1 || due[0].LastError != "unavailable" {
+		t.Fatalf("Dequeue returned %v, expected the failed delivery with its retry state", due)
+	}
+
+	if err := qs.MarkFailed(second.ID, errors.New("gone"), time.Time{}); err != nil {
+		t.Errorf("MarkFailed returned error: %s", err)
+	}
+	if err := qs.MarkDelivered(second.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("a delivery that was given up on should be removed from the queue, got %v", err)
+	}
+}
+
+func testVersions(t *testing.T, s storage.Store) {
+	vs, ok := s.(storage.VersionedStore)
+	if !ok {
+		t.Skipf("%T is not a VersionedStore", s)
+	}
+	iri := pub.IRI("https://example.com/objects/1")
+	if _, err := vs.LoadVersions(iri); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("LoadVersions of a missing object returned %v, expected %s", err, storage.ErrNotFound)
+	}
+	s.Save(note(iri, "first"))
+	if versions, err := vs.LoadVersions(iri); err != nil || len(versions) != 0 {
+		t.Errorf("LoadVersions of a new object returned %v, %v, expected no versions", versions, err)
+	}
+	s.Save(note(iri, "second"))
+	if us, ok := s.(storage.UpdateStore); ok {
+		us.Update(note(iri, "third"), time.Time{})
+	} else {
+		s.Save(note(iri, "third"))
+	}
+
+	versions, err := vs.LoadVersions(iri)
+	if err != nil {
+		t.Fatalf("LoadVersions returned error: %s", err)
+	}
+	if len(versions) != 2 || !note(iri, "second").Equals(versions[0]) || !note(iri, "first").Equals(versions[1]) {
+		t.Errorf("LoadVersions returned %v, expected the previous versions, most recent first", versions)
+	}
+
+	s.Delete(note(iri, "third"))
+	s.Save(note(iri, "new"))
+	if versions, _ := vs.LoadVersions(iri); len(versions) != 0 {
+		t.Errorf("the versions of an object should be removed when it gets deleted, got %v", versions)
+	}
+}
+
+func testBinaries(t *testing.T, s storage.Store) {
+	bs, ok := s.(storage.BinaryStore)
+	if !ok {
+		t.Skipf("%T is not a BinaryStore", s)
+	}
+	iri := pub.IRI("https://example.com/media/1")
+	if _, _, err := bs.LoadBinary(iri); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("LoadBinary of missing data returned %v, expected %s", err, storage.ErrNotFound)
+	}
+	image := &pub.Object{ID: iri, Type: pub.ImageType, MediaType: "image/png"}
+	s.Save(image)
+	if err := bs.SaveBinary(iri, strings.NewReader("data"), "image/png"); err != nil {
+		t.Fatalf("SaveBinary returned error: %s", err)
+	}
+	rc, contentType, err := bs.LoadBinary(iri)
+	if err != nil {
+		t.Fatalf("LoadBinary returned error: %s", err)
+	}
+	data, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil || string(data) != "data" || contentType != "image/png" {
+		t.Errorf("LoadBinary returned %q, %q, %v, expected the saved data", data, contentType, err)
+	}
+
+	s.Delete(image)
+	if _, _, err := bs.LoadBinary(iri); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("the binary data should be deleted with its object, got %v", err)
+	}
+	bs.SaveBinary(iri, strings.NewReader("data"), "image/png")
+	if err := bs.DeleteBinary(iri); err != nil {
+		t.Fatalf("DeleteBinary returned error: %s", err)
+	}
+	if _, _, err := bs.LoadBinary(iri); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("LoadBinary of deleted data returned %v, expected %s", err, storage.ErrNotFound)
+	}
+}
+
+func testConcurrency(t *testing.T, s storage.Store) {
+	const n = 20
+	cs, _ := s.(storage.CollectionStore)
+	outbox := pub.IRI("https://example.com/actors/jdoe/outbox")
+	if cs != nil {
+		cs.Create(pub.OrderedCollectionNew(outbox))
+	}
+
+	wg := sync.WaitGroup{}
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ob := note(pub.IRI(fmt.Sprintf("https://example.com/objects/%d", i)), "hello")
+			if _, err := s.Save(ob); err != nil {
+				errs <- err
+				return
+			}
+			if _, err := s.Load(ob.ID); err != nil {
+				errs <- err
+				return
+			}
+			if cs != nil {
+				if err := cs.AddTo(outbox, ob); err != nil {
+					errs <- err
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("concurrent operation returned error: %s", err)
+	}
+
+	for i := 0; i < n; i++ {
+		if _, err := s.Load(pub.IRI(fmt.Sprintf("https://example.com/objects/%d", i))); err != nil {
+			t.Errorf("Load after concurrent Save returned error: %s", err)
+		}
+	}
+	if cs != nil {
+		if items := members(t, s, outbox); len(items) != n {
+			t.Errorf("expected %d members after concurrent AddTo, got %d", n, len(items))
+		}
+	}
+}
+
+func testClose(t *testing.T, s storage.Store) {
+	ob := note("https://example.com/objects/1", "hello")
+	if _, err := s.Save(ob); err != nil {
+		t.Fatalf("Save returned error: %s", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close returned error: %s", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("closing again returned error: %s", err)
+	}
+	if _, err := s.Load(ob.ID); !errors.Is(err, storage.ErrClosed) {
+		t.Errorf("Load after Close returned %v, expected %s", err, storage.ErrClosed)
+	}
+	if _, err := s.Save(ob); !errors.Is(err, storage.ErrClosed) {
+		t.Errorf("Save after Close returned %v, expected %s", err, storage.ErrClosed)
+	}
+	if err := s.Delete(ob); !errors.Is(err, storage.ErrClosed) {
+		t.Errorf("Delete after Close returned %v, expected %s", err, storage.ErrClosed)
+	}
+	if is, ok := s.(storage.IterateStore); ok {
+		err := is.Each(pub.IRI(""), func(pub.Item) error { return nil })
+		if !errors.Is(err, storage.ErrClosed) {
+			t.Errorf("Each after Close returned %v, expected %s", err, storage.ErrClosed)
+		}
+	}
+}
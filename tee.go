@@ -0,0 +1,261 @@
+package storage
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// TeeConfig holds the options for a TeeStore.
+type TeeConfig struct {
+	// QueueSize is the number of pending writes that can be buffered for each secondary store.
+	// When the queue is full, writes block until the secondary catches up.
+	QueueSize int
+	// Retries is the number of times a failed write to a secondary store is retried.
+	Retries int
+	// RetryWait is the time to wait before the first retry, it doubles for every subsequent one.
+	RetryWait time.Duration
+	// Logger is used for reporting the writes that failed on a secondary store after all retries.
+	Logger Logger
+}
+
+// TeeStore is a Store that writes synchronously to a primary store and asynchronously to one or more
+// secondary stores, which allows running two backends side by side during a migration.
+//
+// Reads are served only by the primary. Failures of the secondary stores are retried and logged,
+// but they are never returned to the caller. Each secondary receives the writes in the same order
+// in which they have been applied to the primary.
+type TeeStore struct {
+	primary     Store
+	secondaries []*teeSecondary
+	c           TeeConfig
+
+	mu     sync.RWMutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+type teeOp struct {
+	name string
+	iri  pub.IRI
+	fn   func(Store) error
+}
+
+type teeSecondary struct {
+	s     Store
+	queue chan teeOp
+}
+
+// Tee returns a TeeStore that writes to "primary" and mirrors the successful writes to the "secondaries".
+func Tee(c TeeConfig, primary Store, secondaries ...Store) *TeeStore {
+	if c.Logger == nil {
+		c.Logger = NopLogger
+	}
+	t := &TeeStore{primary: primary, c: c}
+	for _, s := range secondaries {
+		sec := &teeSecondary{s: s, queue: make(chan teeOp, c.QueueSize)}
+		t.secondaries = append(t.secondaries, sec)
+		t.wg.Add(1)
+		go t.run(sec)
+	}
+	return t
+}
+
+func (t *TeeStore) run(sec *teeSecondary) {
+	defer t.wg.Done()
+	for op := range sec.queue {
+		err := op.fn(sec.s)
+		wait := t.c.RetryWait
+		for i := 0; err != nil && i < t.c.Retries; i++ {
+			time.Sleep(wait)
+			wait *= 2
+			err = op.fn(sec.s)
+		}
+		if err != nil {
+			t.c.Logger.Error("unable to write to secondary store", Fields{
+				"op": op.name, "iri": op.iri, "store": fmt.Sprintf("%T", sec.s), "err": err,
+			})
+		}
+	}
+}
+
+func (t *TeeStore) mirror(op teeOp) {
+	for _, sec := range t.secondaries {
+		sec.queue <- op
+	}
+}
+
+// write applies "fn" to the primary store, and when it succeeds, it queues "op" for the secondaries.
+// The read lock is held until the operation is queued, so Close waits for it.
+func (t *TeeStore) write(fn func() error, op func() teeOp) error {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.closed {
+		return ErrClosed
+	}
+	if err := fn(); err != nil {
+		return err
+	}
+	t.mirror(op())
+	return nil
+}
+
+// Load loads the "iri" item from the primary store.
+func (t *TeeStore) Load(iri pub.IRI) (pub.Item, error) {
+	return t.primary.Load(iri)
+}
+
+// Save saves "it" to the primary store, and queues the saved item for the secondary stores.
+func (t *TeeStore) Save(it pub.Item) (pub.Item, error) {
+	var saved pub.Item
+	err := t.write(func() (err error) {
+		saved, err = t.primary.Save(it)
+		return err
+	}, func() teeOp {
+		if pub.IsNil(saved) {
+			saved = it
+		}
+		cp, cerr := snapshot(saved)
+		return teeOp{name: "save", iri: saved.GetLink(), fn: func(s Store) error {
+			if cerr != nil {
+				return cerr
+			}
+			_, err := s.Save(cp)
+			return err
+		}}
+	})
+	return saved, err
+}
+
+// Delete deletes "it" from the primary store, and queues the deletion for the secondary stores.
+func (t *TeeStore) Delete(it pub.Item) error {
+	return t.write(func() error {
+		return t.primary.Delete(it)
+	}, func() teeOp {
+		iri := it.GetLink()
+		return teeOp{name: "delete", iri: iri, fn: func(s Store) error {
+			return s.Delete(iri)
+		}}
+	})
+}
+
+// Create creates the "col" collection in the primary store, which needs to be a CollectionStore,
+// and queues the operation for the secondary stores.
+func (t *TeeStore) Create(col pub.CollectionInterface) (pub.CollectionInterface, error) {
+	cs, ok := t.primary.(CollectionStore)
+	if !ok {
+		return nil, fmt.Errorf("primary storage %T is not a CollectionStore", t.primary)
+	}
+	var created pub.CollectionInterface
+	err := t.write(func() (err error) {
+		created, err = cs.Create(col)
+		return err
+	}, func() teeOp {
+		if pub.IsNil(created) {
+			created = col
+		}
+		cp, cerr := snapshot(created)
+		return teeOp{name: "create", iri: created.GetLink(), fn: func(s Store) error {
+			if cerr != nil {
+				return cerr
+			}
+			c, ok := cp.(pub.CollectionInterface)
+			if !ok {
+				return fmt.Errorf("%w collection %T", ErrNotValid, cp)
+			}
+			_, err := asCollectionStore(s).Create(c)
+			return err
+		}}
+	})
+	return created, err
+}
+
+// AddTo adds "it" to the "col" collection in the primary store, which needs to be a CollectionStore,
+// and queues the operation for the secondary stores.
+func (t *TeeStore) AddTo(col pub.IRI, it pub.Item) error {
+	cs, ok := t.primary.(CollectionStore)
+	if !ok {
+		return fmt.Errorf("primary storage %T is not a CollectionStore", t.primary)
+	}
+	return t.write(func() error {
+		return cs.AddTo(col, it)
+	}, func() teeOp {
+		iri := it.GetLink()
+		return teeOp{name: "add to", iri: col, fn: func(s Store) error {
+			return asCollectionStore(s).AddTo(col, iri)
+		}}
+	})
+}
+
+// RemoveFrom removes "it" from the "col" collection in the primary store, which needs to be
+// a CollectionStore, and queues the operation for the secondary stores.
+func (t *TeeStore) RemoveFrom(col pub.IRI, it pub.Item) error {
+	cs, ok := t.primary.(CollectionStore)
+	if !ok {
+		return fmt.Errorf("primary storage %T is not a CollectionStore", t.primary)
+	}
+	return t.write(func() error {
+		return cs.RemoveFrom(col, it)
+	}, func() teeOp {
+		iri := it.GetLink()
+		return teeOp{name: "remove from", iri: col, fn: func(s Store) error {
+			return asCollectionStore(s).RemoveFrom(col, iri)
+		}}
+	})
+}
+
+// snapshot returns a copy of "it", which the secondary stores receive, so the callers can modify
+// their items after the writes return, without racing with the goroutines writing them.
+func snapshot(it pub.Item) (pub.Item, error) {
+	raw, err := JSON.Marshal(it)
+	if err != nil {
+		return nil, err
+	}
+	return JSON.Unmarshal(raw)
+}
+
+// Close stops accepting writes and waits until all the queued writes have been applied
+// to the secondary stores. It doesn't close the underlying stores.
+func (t *TeeStore) Close() error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil
+	}
+	t.closed = true
+	for _, sec := range t.secondaries {
+		close(sec.queue)
+	}
+	t.mu.Unlock()
+	t.wg.Wait()
+	return nil
+}
+
+type notCollectionStore struct {
+	Store
+}
+
+func asCollectionStore(s Store) CollectionStore {
+	if cs, ok := s.(CollectionStore); ok {
+		return cs
+	}
+	return notCollectionStore{s}
+}
+
+func (n notCollectionStore) err() error {
+	return fmt.Errorf("storage %T is not a CollectionStore", n.Store)
+}
+
+func (n notCollectionStore) Create(pub.CollectionInterface) (pub.CollectionInterface, error) {
+	return nil, n.err()
+}
+
+func (n notCollectionStore) AddTo(pub.IRI, pub.Item) error {
+	return n.err()
+}
+
+func (n notCollectionStore) RemoveFrom(pub.IRI, pub.Item) error {
+	return n.err()
+}
@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// flakyStore fails the first "failures" writes it receives.
+type flakyStore struct {
+	*mapStore
+	failures int
+	writes   []pub.IRI
+}
+
+func (f *flakyStore) Save(it pub.Item) (pub.Item, error) {
+	if f.failures > 0 {
+		f.failures--
+		return nil, fmt.Errorf("unavailable")
+	}
+	f.writes = append(f.writes, it.GetLink())
+	return f.mapStore.Save(it)
+}
+
+func TestTee(t *testing.T) {
+	primary := newMapStore()
+	mirror := &flakyStore{mapStore: newMapStore(), failures: 2}
+	broken := &flakyStore{mapStore: newMapStore(), failures: 100}
+
+	logs := make([]string, 0)
+	mu := sync.Mutex{}
+	logFn := func(format string, v ...interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		logs = append(logs, fmt.Sprintf(format, v...))
+	}
+
+	tee := Tee(TeeConfig{QueueSize: 2, Retries: 2, RetryWait: time.Millisecond, Logger: LogFnLogger(logFn)}, primary, mirror, broken)
+
+	iris := pub.IRIs{"https://example.com/objects/1", "https://example.com/objects/2", "https://example.com/objects/3"}
+	for _, iri := range iris {
+		if _, err := tee.Save(note(iri, "test")); err != nil {
+			t.Fatalf("Save returned error: %s", err)
+		}
+		if _, err := tee.Load(iri); err != nil {
+			t.Errorf("Load from primary returned error: %s", err)
+		}
+	}
+	if err := tee.Delete(note(iris[2], "")); err != nil {
+		t.Fatalf("Delete returned error: %s", err)
+	}
+	tee.Close()
+
+	if len(mirror.writes) != len(iris) {
+		t.Fatalf("expected %d writes on the secondary, got %d", len(iris), len(mirror.writes))
+	}
+	for i, iri := range iris {
+		if mirror.writes[i] != iri {
+			t.Errorf("secondary write %d is %s, expected %s", i, mirror.writes[i], iri)
+		}
+	}
+	if _, err := mirror.Load(iris[2]); err == nil {
+		t.Errorf("expected deleted item to be removed from the secondary")
+	}
+	if len(logs) != len(iris) {
+		t.Errorf("expected %d logged failures for the broken secondary, got %d: %v", len(iris), len(logs), logs)
+	}
+
+	if _, err := tee.Save(note(iris[0], "test")); err != ErrClosed {
+		t.Errorf("Save on closed TeeStore returned %v, expected %s", err, ErrClosed)
+	}
+	if err := tee.AddTo("https://example.com/inbox", iris[0]); err == nil {
+		t.Errorf("AddTo should fail when the primary is not a CollectionStore")
+	}
+}
+
+func TestTee_Copies(t *testing.T) {
+	iri := pub.IRI("https://example.com/objects/1")
+	mirror := newMapStore()
+	tee := Tee(TeeConfig{}, newMapStore(), mirror)
+
+	ob := note(iri, "saved")
+	if _, err := tee.Save(ob); err != nil {
+		t.Fatalf("Save returned error: %s", err)
+	}
+	ob.Content.Set(pub.NilLangRef, pub.Content("changed after saving"))
+	tee.Close()
+
+	it, err := mirror.Load(iri)
+	if err != nil {
+		t.Fatalf("Load from the secondary returned error: %s", err)
+	}
+	if got := contentOf(it); got != "saved" {
+		t.Errorf("the secondary should receive the saved version, got %q", got)
+	}
+}
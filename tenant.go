@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// TenantOpenFn is the type of the functions opening the store of a tenant, eg: a filesystem storage
+// in a directory named after its host, or a database with a schema, or key prefix, for it.
+type TenantOpenFn func(host string) (Store, error)
+
+// Tenants holds the stores of the tenants of a multi-domain service, one for each host, so the items
+// of a tenant, including its copies of remote objects, can't be read through the store of another.
+// The stores are opened when they're first needed, and kept open until Close.
+type Tenants struct {
+	open TenantOpenFn
+
+	mu     sync.Mutex
+	stores map[string]Store
+	closed bool
+}
+
+// Tenancy returns the Tenants whose stores are opened with "open".
+func Tenancy(open TenantOpenFn) *Tenants {
+	return &Tenants{open: open, stores: make(map[string]Store)}
+}
+
+// TenantHost returns the normalized form of "host", which is lower case, and reports if it's valid:
+// it needs to be a host name, optionally with a port, which can be used as a directory name.
+func TenantHost(host string) (string, bool) {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if len(host) == 0 || host == "." || host == ".." || strings.ContainsAny(host, `/\?#@ `) {
+		return "", false
+	}
+	return host, true
+}
+
+// Tenant returns the store of the "host" tenant, opening it if needed.
+func (t *Tenants) Tenant(host string) (Store, error) {
+	h, ok := TenantHost(host)
+	if !ok {
+		return nil, fmt.Errorf("%w tenant host %q", ErrNotValid, host)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return nil, ErrClosed
+	}
+	if s, ok := t.stores[h]; ok {
+		return s, nil
+	}
+	s, err := t.open(h)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open the storage of tenant %s: %w", h, err)
+	}
+	t.stores[h] = s
+	return s, nil
+}
+
+// For returns the store of the tenant owning "iri", which is identified by its host.
+func (t *Tenants) For(iri pub.IRI) (Store, error) {
+	u, err := url.Parse(iri.String())
+	if err != nil {
+		return nil, fmt.Errorf("%w IRI %s: %s", ErrNotValid, iri, err)
+	}
+	return t.Tenant(u.Host)
+}
+
+// Hosts returns the hosts of the tenants whose stores are open.
+func (t *Tenants) Hosts() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	hosts := make([]string, 0, len(t.stores))
+	for h := range t.stores {
+		hosts = append(hosts, h)
+	}
+	return hosts
+}
+
+// Close closes the stores of all the tenants, see Close, and returns the first error.
+// After it, Tenant returns ErrClosed.
+func (t *Tenants) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return nil
+	}
+	t.closed = true
+	var first error
+	for h, s := range t.stores {
+		if err := Close(s); err != nil && first == nil {
+			first = fmt.Errorf("unable to close the storage of tenant %s: %w", h, err)
+		}
+	}
+	t.stores = make(map[string]Store)
+	return first
+}
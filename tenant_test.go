@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTenants(t *testing.T) {
+	opened := make([]string, 0)
+	tenants := Tenancy(func(host string) (Store, error) {
+		opened = append(opened, host)
+		return newMapStore(), nil
+	})
+
+	a, err := tenants.Tenant("Example.com")
+	if err != nil {
+		t.Fatalf("Tenant returned error: %s", err)
+	}
+	b, _ := tenants.Tenant("example.org")
+	ob := note("https://remote.example/objects/1", "hello")
+	a.Save(ob)
+	if _, err := b.Load(ob.ID); err == nil {
+		t.Errorf("the items of a tenant should not be visible to the others")
+	}
+	if s, err := tenants.For("https://example.com/actors/jdoe"); err != nil || s != a {
+		t.Errorf("For returned %v, %v, expected the store of example.com", s, err)
+	}
+	if len(opened) != 2 || opened[0] != "example.com" {
+		t.Errorf("the stores should be opened once for each host, opened %v", opened)
+	}
+	for _, host := range []string{"", "..", "example.com/../other"} {
+		if _, err := tenants.Tenant(host); !errors.Is(err, ErrNotValid) {
+			t.Errorf("Tenant(%q) returned %v, expected %s", host, err, ErrNotValid)
+		}
+	}
+
+	tenants.Close()
+	if _, err := tenants.Tenant("example.com"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Tenant returned %v after Close, expected %s", err, ErrClosed)
+	}
+}
@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"errors"
+	"sync"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// TieredStore is a Store that reads through a fast cache store, like the memory backend, or a Redis one,
+// in front of a slower primary store, which holds the authoritative data.
+//
+// The writes go to the primary store, and remove the written items from the cache store, which gets
+// them again on their next Load. Only single objects are cached, the collections are always loaded
+// from the primary store.
+type TieredStore struct {
+	primary Store
+	cache   Store
+
+	mu    sync.Mutex
+	loads inflight
+}
+
+var (
+	_ Store           = &TieredStore{}
+	_ CollectionStore = &TieredStore{}
+)
+
+// Tiered returns a TieredStore reading through "cache" in front of "primary".
+func Tiered(primary, cache Store) *TieredStore {
+	return &TieredStore{primary: primary, cache: cache}
+}
+
+// Close closes the primary and the cache stores.
+func (t *TieredStore) Close() error {
+	return errors.Join(t.primary.Close(), t.cache.Close())
+}
+
+// Load returns the "iri" item from the cache store, or else loads it from the primary store,
+// and saves it in the cache store.
+func (t *TieredStore) Load(iri pub.IRI) (pub.Item, error) {
+	if it, err := t.cache.Load(iri); err == nil && cacheable(iri, it) {
+		return it, nil
+	}
+
+	t.mu.Lock()
+	load := t.loads.start(iri)
+	t.mu.Unlock()
+
+	it, err := t.primary.Load(iri)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.loads.done(iri, load) && err == nil && cacheable(iri, it) {
+		// failing to cache the item doesn't affect the result
+		t.cache.Save(it)
+	}
+	return it, err
+}
+
+// invalidate removes "iri" from the cache store, and prevents the loads in progress from caching it.
+func (t *TieredStore) invalidate(iri pub.IRI) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.loads.invalidate(iri)
+	err := t.cache.Delete(iri)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// Save saves "it" in the primary store, and removes it from the cache store.
+func (t *TieredStore) Save(it pub.Item) (pub.Item, error) {
+	saved, err := t.primary.Save(it)
+	if err != nil {
+		return saved, err
+	}
+	if !pub.IsNil(saved) {
+		it = saved
+	}
+	return saved, t.invalidate(it.GetLink())
+}
+
+// Delete deletes "it" from the primary store, and from the cache store.
+func (t *TieredStore) Delete(it pub.Item) error {
+	if err := t.primary.Delete(it); err != nil {
+		return err
+	}
+	if pub.IsNil(it) {
+		return nil
+	}
+	return t.invalidate(it.GetLink())
+}
+
+// Create creates the "col" collection in the primary store, which needs to be a CollectionStore.
+func (t *TieredStore) Create(col pub.CollectionInterface) (pub.CollectionInterface, error) {
+	return asCollectionStore(t.primary).Create(col)
+}
+
+// AddTo adds "it" to the "col" collection in the primary store, which needs to be a CollectionStore.
+func (t *TieredStore) AddTo(col pub.IRI, it pub.Item) error {
+	return asCollectionStore(t.primary).AddTo(col, it)
+}
+
+// RemoveFrom removes "it" from the "col" collection in the primary store, which needs to be a CollectionStore.
+func (t *TieredStore) RemoveFrom(col pub.IRI, it pub.Item) error {
+	return asCollectionStore(t.primary).RemoveFrom(col, it)
+}
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+func TestTieredStore(t *testing.T) {
+	primary, cache := newMapStore(), newMapStore()
+	s := Tiered(primary, cache)
+
+	ob := note("https://example.com/objects/1", "hello")
+	primary.Save(ob)
+	for i := 0; i < 2; i++ {
+		if it, err := s.Load(ob.ID); err != nil || contentOf(it) != "hello" {
+			t.Fatalf("Load returned %v, %v, expected the stored object", it, err)
+		}
+	}
+	if primary.loads != 1 {
+		t.Errorf("the primary store has been loaded %d times, expected the object to be cached", primary.loads)
+	}
+
+	if _, err := s.Save(note(ob.ID, "changed")); err != nil {
+		t.Fatalf("Save returned error: %s", err)
+	}
+	if _, err := cache.Load(ob.ID); err == nil {
+		t.Errorf("Save should remove the object from the cache store")
+	}
+	if it, _ := s.Load(ob.ID); contentOf(it) != "changed" {
+		t.Errorf("Load returned %v, expected the saved object", it)
+	}
+
+	if err := s.Delete(ob); err != nil {
+		t.Fatalf("Delete returned error: %s", err)
+	}
+	if _, err := s.Load(ob.ID); err == nil {
+		t.Errorf("Load of a deleted object should fail")
+	}
+
+	col := pub.OrderedCollectionNew("https://example.com/outbox")
+	primary.Save(col)
+	s.Load(col.ID)
+	if _, err := cache.Load(col.ID); err == nil {
+		t.Errorf("collections should not be cached")
+	}
+}
+
+func TestTieredStore_raceWithSave(t *testing.T) {
+	primary, cache := newMapStore(), newMapStore()
+	s := Tiered(primary, cache)
+	iri := pub.IRI("https://example.com/objects/1")
+	primary.Save(note(iri, "old"))
+
+	primary.beforeLoad = func(pub.IRI) {
+		primary.beforeLoad = nil
+		s.Save(note(iri, "new"))
+	}
+	s.Load(iri)
+	if it, _ := s.Load(iri); contentOf(it) != "new" {
+		t.Errorf("Load returned %v, expected the object saved during the previous load", it)
+	}
+}
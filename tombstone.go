@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// Tombstone returns the Tombstone that replaces "it" when it gets deleted at the "deleted" moment.
+//
+// The Tombstone keeps the ID of the item, its type as FormerType, its publishing date and its recipients,
+// so it's still addressed to the same audience, but none of the content.
+// Items that are already Tombstones are returned unchanged.
+func Tombstone(it pub.Item, deleted time.Time) *pub.Tombstone {
+	if pub.IsNil(it) {
+		return nil
+	}
+	if it.GetType() == pub.TombstoneType {
+		if t, err := pub.ToTombstone(it); err == nil {
+			return t
+		}
+	}
+	t := &pub.Tombstone{
+		ID:         it.GetLink(),
+		Type:       pub.TombstoneType,
+		FormerType: it.GetType(),
+		Deleted:    deleted.UTC(),
+	}
+	if !pub.IsObject(it) {
+		t.FormerType = pub.ObjectType
+		return t
+	}
+	pub.OnObject(it, func(o *pub.Object) error {
+		t.Published = o.Published
+		t.To = o.To
+		t.Bto = o.Bto
+		t.CC = o.CC
+		t.BCC = o.BCC
+		t.Audience = o.Audience
+		return nil
+	})
+	return t
+}
+
+// TombstoneOf returns the Tombstone for the "iri" object stored in "s", deleted at the "deleted" moment.
+// It's meant for delete operations that receive only the IRI, or a partial representation, of the object,
+// so the Tombstone can keep the properties of the stored version.
+func TombstoneOf(s ReadStore, iri pub.IRI, deleted time.Time) (*pub.Tombstone, error) {
+	it, err := s.Load(iri)
+	if err != nil {
+		return nil, err
+	}
+	if pub.CollectionTypes.Contains(it.GetType()) {
+		return nil, fmt.Errorf("%w: %s is a collection", ErrNotValid, iri)
+	}
+	return Tombstone(it, deleted), nil
+}
+
+// Bury replaces the object stored in "s" with its "t" Tombstone.
+// The object is deleted before saving the Tombstone, so none of the data that the backends keep
+// together with it, like its previous versions, its counters or its binary data, outlives it.
+// When "s" is a TxStore, the two operations run in the same transaction.
+func Bury(s Store, t *pub.Tombstone) error {
+	bury := func(s Store) error {
+		if err := s.Delete(t); err != nil {
+			return err
+		}
+		_, err := s.Save(t)
+		return err
+	}
+	if ts, ok := s.(TxStore); ok {
+		return ts.WithTx(bury)
+	}
+	return bury(s)
+}
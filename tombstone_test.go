@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+func TestTombstone(t *testing.T) {
+	deleted := time.Date(2022, 6, 1, 10, 0, 0, 0, time.FixedZone("EEST", 3*3600))
+
+	if Tombstone(nil, deleted) != nil {
+		t.Errorf("Tombstone of nil item should be nil")
+	}
+
+	ob := note("https://example.com/objects/1", "secret")
+	ob.Published = deleted.Add(-time.Hour)
+	ob.To = pub.ItemCollection{pub.PublicNS}
+
+	ts := Tombstone(ob, deleted)
+	if ts.ID != ob.ID || ts.Type != pub.TombstoneType || ts.FormerType != pub.NoteType {
+		t.Errorf("invalid Tombstone %s %s %s", ts.ID, ts.Type, ts.FormerType)
+	}
+	if !ts.Deleted.Equal(deleted) || ts.Deleted.Location() != time.UTC {
+		t.Errorf("invalid deleted time %s, expected %s in UTC", ts.Deleted, deleted)
+	}
+	if !ts.Published.Equal(ob.Published) || !ts.To.Contains(pub.PublicNS) {
+		t.Errorf("Tombstone should preserve the published time and recipients")
+	}
+	if len(ts.Content) > 0 {
+		t.Errorf("Tombstone should not preserve the content")
+	}
+
+	if again := Tombstone(ts, time.Now()); again != ts {
+		t.Errorf("Tombstone of a Tombstone should return it unchanged")
+	}
+
+	fromIRI := Tombstone(pub.IRI("https://example.com/objects/2"), deleted)
+	if fromIRI.ID != "https://example.com/objects/2" || fromIRI.FormerType != pub.ObjectType {
+		t.Errorf("invalid Tombstone for IRI %s %s", fromIRI.ID, fromIRI.FormerType)
+	}
+}
+
+func TestTombstoneOf(t *testing.T) {
+	s := newMapStore()
+	ob := note("https://example.com/objects/1", "hello")
+	s.Save(ob)
+	s.Save(pub.OrderedCollectionNew("https://example.com/actors/jdoe/outbox"))
+
+	tomb, err := TombstoneOf(s, ob.ID, time.Now())
+	if err != nil {
+		t.Fatalf("TombstoneOf returned error: %s", err)
+	}
+	if tomb.ID != ob.ID || tomb.FormerType != pub.NoteType {
+		t.Errorf("invalid Tombstone %#v", tomb)
+	}
+	if _, err := TombstoneOf(s, "https://example.com/objects/2", time.Now()); err == nil {
+		t.Errorf("TombstoneOf a missing object should fail")
+	}
+	if _, err := TombstoneOf(s, "https://example.com/actors/jdoe/outbox", time.Now()); !errors.Is(err, ErrNotValid) {
+		t.Errorf("TombstoneOf a collection returned %v, expected %s", err, ErrNotValid)
+	}
+}
+
+func TestBury(t *testing.T) {
+	s := newBackendMapStore()
+	ob := note("https://example.com/objects/1", "hello")
+	s.Save(note(ob.ID, "draft"))
+	s.Save(ob)
+
+	if err := Bury(s, Tombstone(ob, time.Now())); err != nil {
+		t.Fatalf("Bury returned error: %s", err)
+	}
+	if it, err := s.Load(ob.ID); err != nil || it.GetType() != pub.TombstoneType {
+		t.Errorf("Bury should replace the object with its Tombstone, got %v: %v", it, err)
+	}
+	if versions, _ := s.LoadVersions(ob.ID); len(versions) > 0 {
+		t.Errorf("Bury should remove the versions of the object, got %d", len(versions))
+	}
+	if s.txs != 1 {
+		t.Errorf("Bury should use a single transaction, used %d", s.txs)
+	}
+}
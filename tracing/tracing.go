@@ -0,0 +1,162 @@
+// Package tracing decorates a storage.Store with OpenTelemetry spans, so the time spent in the storage shows up
+// in the distributed traces of the requests, like the processing of the activities received in an inbox:
+//
+//	s := tracing.New(db, "postgres", otel.Tracer("fedbox"))
+//	it, err := s.WithContext(r.Context()).Load(iri)
+//
+// The operations of the Store interfaces don't receive a context, so the spans are children of the one set
+// with WithContext, or new traces without it.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	pub "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// The attributes of the spans.
+const (
+	// BackendKey is the name of the storage backend, eg: "postgres".
+	BackendKey = attribute.Key("storage.backend")
+	// IRIKey is the IRI of the item, or collection, the operation runs on.
+	IRIKey = attribute.Key("storage.iri")
+	// ItemKey is the IRI of the item added to, or removed from, a collection.
+	ItemKey = attribute.Key("storage.item")
+	// NotFoundKey is set for the operations which failed because the item didn't exist, which aren't
+	// recorded as errors, as it's the expected result of many of them.
+	NotFoundKey = attribute.Key("storage.not_found")
+)
+
+// Store is a storage.Store which runs the operations of the underlying store in spans.
+type Store struct {
+	storage.Store
+	backend string
+	tracer  trace.Tracer
+	ctx     context.Context
+}
+
+var (
+	_ storage.Store           = &Store{}
+	_ storage.CollectionStore = &Store{}
+)
+
+// New returns a Store running the operations of "s" in spans started with "tracer", with "backend" as the
+// value of their BackendKey attribute.
+func New(s storage.Store, backend string, tracer trace.Tracer) *Store {
+	return &Store{Store: s, backend: backend, tracer: tracer, ctx: context.Background()}
+}
+
+// WithContext returns a copy of the Store, whose spans are children of the span of "ctx".
+func (s *Store) WithContext(ctx context.Context) *Store {
+	c := *s
+	c.ctx = ctx
+	return &c
+}
+
+func (s *Store) run(op string, iri pub.IRI, fn func() error, attrs ...attribute.KeyValue) error {
+	attrs = append(attrs, BackendKey.String(s.backend), IRIKey.String(iri.String()))
+	_, span := s.tracer.Start(s.ctx, "storage."+op, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	defer span.End()
+
+	err := fn()
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		span.SetAttributes(NotFoundKey.Bool(true))
+	case err != nil:
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
+
+// Load loads "iri" from the underlying store.
+func (s *Store) Load(iri pub.IRI) (pub.Item, error) {
+	var it pub.Item
+	err := s.run("load", iri, func() error {
+		var err error
+		it, err = s.Store.Load(iri)
+		return err
+	})
+	return it, err
+}
+
+// Save saves "it" to the underlying store.
+func (s *Store) Save(it pub.Item) (pub.Item, error) {
+	var saved pub.Item
+	err := s.run("save", linkOf(it), func() error {
+		var err error
+		saved, err = s.Store.Save(it)
+		return err
+	})
+	return saved, err
+}
+
+// Delete deletes "it" from the underlying store.
+func (s *Store) Delete(it pub.Item) error {
+	return s.run("delete", linkOf(it), func() error {
+		return s.Store.Delete(it)
+	})
+}
+
+// Create creates the "col" collection in the underlying store, which needs to be a storage.CollectionStore.
+func (s *Store) Create(col pub.CollectionInterface) (pub.CollectionInterface, error) {
+	var created pub.CollectionInterface
+	err := s.run("create", linkOf(col), func() error {
+		cs, err := s.collections()
+		if err != nil {
+			return err
+		}
+		created, err = cs.Create(col)
+		return err
+	})
+	return created, err
+}
+
+// AddTo adds "it" to the "col" collection in the underlying store, which needs to be a storage.CollectionStore.
+func (s *Store) AddTo(col pub.IRI, it pub.Item) error {
+	return s.run("add", col, func() error {
+		cs, err := s.collections()
+		if err != nil {
+			return err
+		}
+		return cs.AddTo(col, it)
+	}, ItemKey.String(linkOf(it).String()))
+}
+
+// RemoveFrom removes "it" from the "col" collection in the underlying store, which needs to be a
+// storage.CollectionStore.
+func (s *Store) RemoveFrom(col pub.IRI, it pub.Item) error {
+	return s.run("remove", col, func() error {
+		cs, err := s.collections()
+		if err != nil {
+			return err
+		}
+		return cs.RemoveFrom(col, it)
+	}, ItemKey.String(linkOf(it).String()))
+}
+
+// Close closes the underlying store, see storage.Close.
+func (s *Store) Close() error {
+	return storage.Close(s.Store)
+}
+
+func (s *Store) collections() (storage.CollectionStore, error) {
+	cs, ok := s.Store.(storage.CollectionStore)
+	if !ok {
+		return nil, fmt.Errorf("storage %T is not a CollectionStore", s.Store)
+	}
+	return cs, nil
+}
+
+func linkOf(it pub.Item) pub.IRI {
+	if pub.IsNil(it) {
+		return ""
+	}
+	return it.GetLink()
+}
@@ -0,0 +1,63 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage"
+	"github.com/go-ap/storage/memory"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestStore(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)).Tracer("test")
+
+	ctx, parent := tracer.Start(context.Background(), "inbox")
+	s := New(memory.New(), "memory", tracer).WithContext(ctx)
+
+	outbox := pub.IRI("https://example.com/actors/jdoe/outbox")
+	ob := &pub.Object{ID: "https://example.com/objects/1", Type: pub.NoteType}
+	s.Save(ob)
+	s.Create(pub.OrderedCollectionNew(outbox))
+	s.AddTo(outbox, ob)
+	if _, err := s.Load("https://example.com/objects/2"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Load of a missing object returned %v, expected %s", err, storage.ErrNotFound)
+	}
+	s.Close()
+	if _, err := s.Load(ob.ID); err == nil {
+		t.Errorf("Load after Close should fail")
+	}
+	parent.End()
+
+	spans := rec.Ended()
+	names := []string{"storage.save", "storage.create", "storage.add", "storage.load", "storage.load", "inbox"}
+	if len(spans) != len(names) {
+		t.Fatalf("expected %d spans, got %d", len(names), len(spans))
+	}
+	for i, sp := range spans[:len(spans)-1] {
+		if sp.Name() != names[i] {
+			t.Errorf("span %d is %q, expected %q", i, sp.Name(), names[i])
+		}
+		if sp.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("span %q should be a child of the span of the context", sp.Name())
+		}
+	}
+	attrs := make(map[string]string)
+	for _, kv := range spans[2].Attributes() {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	if attrs["storage.backend"] != "memory" || attrs["storage.iri"] != outbox.String() || attrs["storage.item"] != ob.ID.String() {
+		t.Errorf("unexpected attributes of the add span %v", attrs)
+	}
+	if spans[3].Status().Code == codes.Error || len(spans[3].Attributes()) != 3 {
+		t.Errorf("the load of a missing object should be marked as not found, got %v", spans[3].Attributes())
+	}
+	if spans[4].Status().Code != codes.Error || len(spans[4].Events()) != 1 {
+		t.Errorf("the failed load should record its error, got %v", spans[4].Status())
+	}
+}
@@ -0,0 +1,13 @@
+package storage
+
+// TxStore can group multiple operations in a single transaction, which allows storing an activity,
+// its object, and adding them to collections atomically.
+type TxStore interface {
+	// WithTx calls "fn" with a Store whose operations are part of the same transaction.
+	// The transaction is committed when "fn" returns nil, and rolled back when it returns an error,
+	// which WithTx returns.
+	//
+	// The Store received by "fn" is valid only until it returns, afterwards its operations return
+	// ErrTxClosed. When the backend supports it, the Store is also a CollectionStore.
+	WithTx(fn func(tx Store) error) error
+}
@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+func TestTxStore_FanOut(t *testing.T) {
+	s := newBackendMapStore()
+	ob := note("https://example.com/objects/1", "hello")
+	inboxes := pub.IRIs{"https://example.com/actors/jdoe/inbox", "https://example.com/actors/alice/inbox"}
+	for _, inbox := range inboxes {
+		s.Create(pub.OrderedCollectionNew(inbox))
+	}
+
+	missing := pub.IRI("https://example.com/actors/bob/inbox")
+	if err := FanOut(s, ob, append(inboxes, missing)...); !errors.Is(err, ErrNotFound) {
+		t.Errorf("FanOut to a missing collection returned %v, expected %s", err, ErrNotFound)
+	}
+	for _, inbox := range inboxes {
+		if members := s.members[inbox]; len(members) > 0 {
+			t.Errorf("the failed transaction should be rolled back, %s has members %v", inbox, members)
+		}
+	}
+	if err := FanOut(s, ob, inboxes...); err != nil {
+		t.Fatalf("FanOut returned error: %s", err)
+	}
+	for _, inbox := range inboxes {
+		if members := s.members[inbox]; len(members) != 1 {
+			t.Errorf("%s has members %v, expected only %s", inbox, members, ob.ID)
+		}
+	}
+	if s.txs != 2 {
+		t.Errorf("FanOut should use a transaction for each call, used %d", s.txs)
+	}
+}
@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// RevisionPrecision is the precision of the revisions of the objects, which is the one their
+// Updated property keeps when encoded as JSON-LD.
+const RevisionPrecision = time.Second
+
+// UpdateStore can update objects using optimistic concurrency control, so concurrent writers
+// can't silently overwrite each other's changes.
+//
+// The revision of an object is its Updated property, see Revision.
+type UpdateStore interface {
+	// Update saves "it" only if the revision of the stored version is "expected", otherwise it returns
+	// an error wrapping ErrConflict. On success the Updated property of "it" is set to its new revision.
+	// Updating a missing object returns an error wrapping ErrNotFound.
+	Update(it pub.Item, expected time.Time) (pub.Item, error)
+}
+
+// Revision returns the revision of "it", which is its Updated property, truncated to RevisionPrecision.
+// Objects that have never been updated have the zero revision.
+func Revision(it pub.Item) time.Time {
+	rev := time.Time{}
+	pub.OnObject(it, func(o *pub.Object) error {
+		if !o.Updated.IsZero() {
+			rev = o.Updated.UTC().Truncate(RevisionPrecision)
+		}
+		return nil
+	})
+	return rev
+}
+
+// CheckRevision returns an error wrapping ErrConflict if the revision of the "stored" object is not "expected".
+func CheckRevision(stored pub.Item, expected time.Time) error {
+	rev := Revision(stored)
+	if !expected.IsZero() {
+		expected = expected.UTC().Truncate(RevisionPrecision)
+	}
+	if !rev.Equal(expected) {
+		return fmt.Errorf("%w: %s has been updated at %s, expected revision %s", ErrConflict, stored.GetLink(),
+			rev.Format(time.RFC3339), expected.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// SetRevision sets the Updated property of "it" to the revision that follows the one of "stored",
+// using the "now" moment if it's more recent.
+func SetRevision(it, stored pub.Item, now time.Time) error {
+	rev := now.UTC().Truncate(RevisionPrecision)
+	if prev := Revision(stored); !rev.After(prev) {
+		rev = prev.Add(RevisionPrecision)
+	}
+	return pub.OnObject(it, func(o *pub.Object) error {
+		o.Updated = rev
+		return nil
+	})
+}
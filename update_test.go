@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+func TestCheckRevision(t *testing.T) {
+	updated := time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
+	ob := note("https://example.com/objects/1", "hello")
+	ob.Updated = updated
+
+	tests := []struct {
+		name     string
+		stored   pub.Item
+		expected time.Time
+		wantErr  bool
+	}{
+		{name: "same revision", stored: ob, expected: updated},
+		{name: "same revision in another time zone", stored: ob, expected: updated.In(time.FixedZone("EEST", 3*3600))},
+		{name: "same revision with more precision", stored: ob, expected: updated.Add(time.Millisecond)},
+		{name: "older revision", stored: ob, expected: updated.Add(-time.Hour), wantErr: true},
+		{name: "never updated", stored: note("https://example.com/objects/2", "hello"), expected: time.Time{}},
+		{name: "expected update", stored: note("https://example.com/objects/2", "hello"), expected: updated, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckRevision(tt.stored, tt.expected)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckRevision returned %v, expected error %t", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrConflict) {
+				t.Errorf("CheckRevision returned %v, expected it to match %s", err, ErrConflict)
+			}
+		})
+	}
+}
+
+func TestSetRevision(t *testing.T) {
+	now := time.Date(2022, 6, 1, 10, 0, 0, 500, time.UTC)
+	stored := note("https://example.com/objects/1", "hello")
+
+	ob := note(stored.ID, "changed")
+	SetRevision(ob, stored, now)
+	if want := now.Truncate(RevisionPrecision); !ob.Updated.Equal(want) {
+		t.Errorf("SetRevision set %s, expected %s", ob.Updated, want)
+	}
+
+	stored.Updated = now.Add(time.Hour)
+	SetRevision(ob, stored, now)
+	if want := stored.Updated.Truncate(RevisionPrecision).Add(RevisionPrecision); !ob.Updated.Equal(want) {
+		t.Errorf("SetRevision set %s, expected the revision after the stored one %s", ob.Updated, want)
+	}
+}
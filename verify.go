@@ -0,0 +1,190 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// ProblemKind is the kind of inconsistency found by Verify.
+type ProblemKind string
+
+const (
+	// ProblemMissingMember is a collection member that isn't stored.
+	ProblemMissingMember ProblemKind = "missing-member"
+	// ProblemTombstoneMember is a collection member that has been deleted, and replaced by a Tombstone.
+	ProblemTombstoneMember ProblemKind = "tombstone-member"
+	// ProblemDuplicateID is an IRI stored more than once, see IDVerifier.
+	ProblemDuplicateID ProblemKind = "duplicate-id"
+	// ProblemIndex is an object missing from one of the secondary indexes, see IndexStore.
+	ProblemIndex ProblemKind = "index"
+)
+
+// Problem is an inconsistency of the stored data.
+type Problem struct {
+	Kind ProblemKind `json:"kind"`
+	// IRI identifies the item with the problem, eg: the collection with a missing member.
+	IRI pub.IRI `json:"iri"`
+	// Ref is the item that the problem refers to, eg: the missing member.
+	Ref string `json:"ref,omitempty"`
+	// Repaired reports if the problem has been fixed.
+	Repaired bool `json:"repaired"`
+	// Error is the reason for which the repair failed.
+	Error string `json:"error,omitempty"`
+}
+
+// Report is the result of Verify, which can be encoded as JSON.
+type Report struct {
+	Objects     uint      `json:"objects"`
+	Collections uint      `json:"collections"`
+	Problems    []Problem `json:"problems"`
+}
+
+// VerifyOptions control Verify.
+type VerifyOptions struct {
+	// Repair fixes the problems which can be fixed, when the store supports the required operations:
+	// the missing and deleted members are removed from their collections, and the objects missing
+	// from the indexes are saved again.
+	Repair bool
+	// Local reports if an IRI is expected to be stored. The collection members for which it returns false,
+	// like the IRIs of remote objects, are not checked. All of them are checked if it's nil.
+	Local func(pub.IRI) bool
+}
+
+// Verify goes through the objects of the "s" store, checking that:
+//
+//   - the members of their collections, see CollectionsOf, are stored and not deleted,
+//   - no IRI is stored more than once, if "s" is an IDVerifier,
+//   - they are found in the secondary indexes, if "s" is an IndexStore.
+//
+// It returns the problems it finds in the Report, and an error only if the verification can't go on.
+func Verify(s ReadStore, o VerifyOptions) (Report, error) {
+	r := Report{Problems: make([]Problem, 0)}
+	cols := make(pub.IRIs, 0)
+	seen := make(map[pub.IRI]struct{})
+	is, isIndexStore := s.(IndexStore)
+	err := Each(s, pub.IRI(""), func(it pub.Item) error {
+		if pub.CollectionTypes.Contains(it.GetType()) {
+			if _, ok := seen[it.GetLink()]; !ok {
+				seen[it.GetLink()] = struct{}{}
+				cols = append(cols, it.GetLink())
+			}
+			return nil
+		}
+		r.Objects++
+		for _, col := range CollectionsOf(it) {
+			if _, ok := seen[col]; !ok {
+				seen[col] = struct{}{}
+				cols = append(cols, col)
+			}
+		}
+		if isIndexStore {
+			r.Problems = append(r.Problems, verifyIndexes(s, is, it, o)...)
+		}
+		return nil
+	})
+	if err != nil {
+		return r, err
+	}
+	for _, iri := range cols {
+		problems, found, err := verifyCollection(s, iri, o)
+		if err != nil {
+			return r, err
+		}
+		if found {
+			r.Collections++
+		}
+		r.Problems = append(r.Problems, problems...)
+	}
+	if iv, ok := s.(IDVerifier); ok {
+		duplicates, err := iv.VerifyUniqueIDs()
+		if err != nil {
+			return r, err
+		}
+		for _, iri := range duplicates {
+			r.Problems = append(r.Problems, Problem{Kind: ProblemDuplicateID, IRI: iri})
+		}
+	}
+	return r, nil
+}
+
+// verifyIndexes checks that "it" is found in the indexes of "is" under all its IndexKeys.
+func verifyIndexes(s ReadStore, is IndexStore, it pub.Item, o VerifyOptions) []Problem {
+	var problems []Problem
+	for index, keys := range IndexKeys(it) {
+		for _, key := range keys {
+			iris, err := is.LoadIndex(index, key)
+			if err == nil && iris.Contains(it.GetLink()) {
+				continue
+			}
+			problems = append(problems, Problem{Kind: ProblemIndex, IRI: it.GetLink(), Ref: index + "=" + key})
+		}
+	}
+	if len(problems) == 0 || !o.Repair {
+		return problems
+	}
+	ws, ok := s.(WriteStore)
+	if !ok {
+		return problems
+	}
+	// saving the object again updates all its index keys
+	_, err := ws.Save(it)
+	for i := range problems {
+		repaired(&problems[i], err)
+	}
+	return problems
+}
+
+// verifyCollection checks that the members of the "iri" collection are stored, and not deleted.
+// It returns false if the collection is not stored.
+func verifyCollection(s ReadStore, iri pub.IRI, o VerifyOptions) ([]Problem, bool, error) {
+	it, err := s.Load(iri)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("unable to load collection %s: %w", iri, err)
+	}
+	col, ok := it.(pub.CollectionInterface)
+	if !ok || !pub.CollectionTypes.Contains(it.GetType()) {
+		return nil, false, nil
+	}
+	cs, isCollectionStore := s.(CollectionStore)
+	var problems []Problem
+	for _, member := range col.Collection() {
+		if pub.IsNil(member) || (o.Local != nil && !o.Local(member.GetLink())) {
+			continue
+		}
+		p := Problem{IRI: iri, Ref: member.GetLink().String()}
+		switch {
+		case pub.IsIRI(member):
+			// the backends load the stored members, but the members which are collections can remain IRIs
+			exists, err := Exists(s, member.GetLink())
+			if err != nil {
+				return nil, true, err
+			}
+			if exists {
+				continue
+			}
+			p.Kind = ProblemMissingMember
+		case member.GetType() == pub.TombstoneType:
+			p.Kind = ProblemTombstoneMember
+		default:
+			continue
+		}
+		if o.Repair && isCollectionStore {
+			repaired(&p, cs.RemoveFrom(iri, member.GetLink()))
+		}
+		problems = append(problems, p)
+	}
+	return problems, true, nil
+}
+
+func repaired(p *Problem, err error) {
+	if err != nil {
+		p.Error = err.Error()
+		return
+	}
+	p.Repaired = true
+}
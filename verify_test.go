@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// indexedBackendStore is a backendMapStore with secondary indexes, built from the IndexKeys of the stored
+// objects, except for the "unindexed" ones, which get indexed again when they're saved.
+type indexedBackendStore struct {
+	*backendMapStore
+	unindexed  map[pub.IRI]struct{}
+	duplicates pub.IRIs
+}
+
+func (s *indexedBackendStore) Save(it pub.Item) (pub.Item, error) {
+	delete(s.unindexed, it.GetLink())
+	return s.backendMapStore.Save(it)
+}
+
+func (s *indexedBackendStore) LoadIndex(index, key string) (pub.IRIs, error) {
+	iris := make(pub.IRIs, 0)
+	for iri, it := range s.items {
+		if _, ok := s.unindexed[iri]; ok {
+			continue
+		}
+		for _, k := range IndexKeys(it)[index] {
+			if k == key {
+				iris = append(iris, iri)
+			}
+		}
+	}
+	return iris, nil
+}
+
+func (s *indexedBackendStore) VerifyUniqueIDs() (pub.IRIs, error) {
+	return s.duplicates, nil
+}
+
+func TestVerify(t *testing.T) {
+	s := &indexedBackendStore{backendMapStore: newBackendMapStore(), unindexed: make(map[pub.IRI]struct{})}
+	actor := &pub.Actor{
+		ID:     "https://example.com/actors/jdoe",
+		Type:   pub.PersonType,
+		Outbox: pub.IRI("https://example.com/actors/jdoe/outbox"),
+	}
+	ob := note("https://example.com/objects/1", "hello")
+	deleted := note("https://example.com/objects/2", "deleted")
+	s.Save(actor)
+	s.Save(ob)
+	s.Save(deleted)
+	s.Create(pub.OrderedCollectionNew(actor.Outbox.GetLink()))
+	for _, member := range (pub.IRIs{ob.ID, deleted.ID, "https://example.com/objects/missing", "https://remote.example/objects/1"}) {
+		s.AddTo(actor.Outbox.GetLink(), member)
+	}
+	Bury(s, Tombstone(deleted, time.Now()))
+	s.unindexed[ob.ID] = struct{}{}
+	s.duplicates = pub.IRIs{"https://example.com/actors/jdoe/outbox"}
+
+	report, err := Verify(s, VerifyOptions{Local: isLocal})
+	if err != nil {
+		t.Fatalf("Verify returned error: %s", err)
+	}
+	if report.Objects != 3 || report.Collections != 1 {
+		t.Errorf("Verify returned %d objects and %d collections, expected 3 and 1", report.Objects, report.Collections)
+	}
+	kinds := make(map[ProblemKind]int)
+	for _, p := range report.Problems {
+		kinds[p.Kind]++
+		if p.Repaired {
+			t.Errorf("the %s problem should not be repaired", p.Kind)
+		}
+	}
+	want := map[ProblemKind]int{
+		ProblemMissingMember:   1,
+		ProblemTombstoneMember: 1,
+		ProblemIndex:           1,
+		ProblemDuplicateID:     1,
+	}
+	if len(kinds) != len(want) {
+		t.Errorf("Verify returned %+v, expected %v", report.Problems, want)
+	}
+	for kind, n := range want {
+		if kinds[kind] != n {
+			t.Errorf("Verify returned %d %s problems, expected %d", kinds[kind], kind, n)
+		}
+	}
+
+	s.duplicates = nil
+	report, _ = Verify(s, VerifyOptions{Local: isLocal, Repair: true})
+	for _, p := range report.Problems {
+		if !p.Repaired {
+			t.Errorf("the %s problem should be repaired: %s", p.Kind, p.Error)
+		}
+	}
+	if report, _ = Verify(s, VerifyOptions{Local: isLocal}); len(report.Problems) != 0 {
+		t.Errorf("Verify returned %+v after the repair, expected no problems", report.Problems)
+	}
+}
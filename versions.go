@@ -0,0 +1,16 @@
+package storage
+
+import (
+	pub "github.com/go-ap/activitypub"
+)
+
+// VersionedStore keeps the previous versions of the objects that get overwritten, by Save or by
+// UpdateStore.Update, which allows showing their edit history, or reverting an Update.
+//
+// Collections are not versioned, and the versions of an object are removed when it gets deleted,
+// including when it gets replaced by its Tombstone, see Bury.
+type VersionedStore interface {
+	// LoadVersions returns the previous versions of the "iri" object, starting with the most recent one.
+	// The current version is not included, so objects that have never been overwritten have no versions.
+	LoadVersions(iri pub.IRI) (pub.ItemCollection, error)
+}
@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+func TestVersionedStore_deleted(t *testing.T) {
+	tests := map[string]func(Store, pub.Item) error{
+		"SoftDelete": func(s Store, it pub.Item) error {
+			return SoftDelete(s).Delete(it.GetLink())
+		},
+		"Bury": func(s Store, it pub.Item) error {
+			return Bury(s, Tombstone(it, time.Now()))
+		},
+	}
+	for name, del := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := newBackendMapStore()
+			ob := note("https://example.com/objects/1", "hello")
+			s.Save(note(ob.ID, "draft"))
+			s.Save(ob)
+			if versions, _ := s.LoadVersions(ob.ID); len(versions) != 1 {
+				t.Fatalf("LoadVersions returned %d versions, expected 1", len(versions))
+			}
+
+			if err := del(s, ob); err != nil {
+				t.Fatalf("%s returned error: %s", name, err)
+			}
+			if versions, _ := s.LoadVersions(ob.ID); len(versions) > 0 {
+				t.Errorf("the versions of a deleted object should be removed, got %d", len(versions))
+			}
+		})
+	}
+}
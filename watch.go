@@ -0,0 +1,242 @@
+package storage
+
+import (
+	"sync"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+// EventType is the kind of change reported by an Event.
+type EventType string
+
+const (
+	// EventCreate reports an item saved for the first time.
+	EventCreate EventType = "create"
+	// EventUpdate reports an item saved over a previous version.
+	EventUpdate EventType = "update"
+	// EventDelete reports a deleted item.
+	EventDelete EventType = "delete"
+	// EventAdd reports an item added to a collection.
+	EventAdd EventType = "add"
+	// EventRemove reports an item removed from a collection.
+	EventRemove EventType = "remove"
+)
+
+// Event is a change of the storage, emitted after it has been committed.
+type Event struct {
+	Type EventType
+	// Item is the saved item, or, for deletes, the item that was passed to Delete.
+	// For the changes of the members of a collection, it's the item passed to AddTo, or RemoveFrom.
+	Item pub.Item
+	// Collection is the IRI of the collection, for the EventAdd and EventRemove events.
+	Collection pub.IRI
+}
+
+// CancelFn stops a subscription and closes its channel. It can be called multiple times.
+type CancelFn func()
+
+// ChangeFeed can notify the changes of the stored items, which allows driving streams of
+// updates, like timelines served over SSE or websockets, directly from the storage.
+type ChangeFeed interface {
+	// Subscribe returns a channel that receives the events for the items matching "f", and the function
+	// that ends the subscription. When f.GetLink() is not empty, only the items with IRIs under it match,
+	// and, for the changes of the members of collections, the collections with IRIs under it.
+	//
+	// Subscribers that don't keep up with the events have their channel closed, so they can
+	// subscribe again after reloading the state they're interested in.
+	Subscribe(f Filterable) (<-chan Event, CancelFn)
+}
+
+// Feed is a ChangeFeed that broadcasts the events it's given, for the backends that emit them
+// when they commit their changes.
+type Feed struct {
+	buffer int
+
+	mu   sync.Mutex
+	next int
+	subs map[int]*subscription
+}
+
+type subscription struct {
+	f  Filterable
+	ch chan Event
+}
+
+var _ ChangeFeed = &Feed{}
+
+// NewFeed returns a Feed in which each subscriber can have up to "buffer" events waiting to be received.
+func NewFeed(buffer int) *Feed {
+	if buffer < 1 {
+		buffer = 1
+	}
+	return &Feed{buffer: buffer, subs: make(map[int]*subscription)}
+}
+
+// Subscribe returns the channel receiving the events for the items matching "f", see ChangeFeed.
+func (fd *Feed) Subscribe(f Filterable) (<-chan Event, CancelFn) {
+	fd.mu.Lock()
+	defer fd.mu.Unlock()
+
+	id := fd.next
+	fd.next++
+	sub := &subscription{f: f, ch: make(chan Event, fd.buffer)}
+	fd.subs[id] = sub
+	return sub.ch, func() {
+		fd.mu.Lock()
+		defer fd.mu.Unlock()
+		if _, ok := fd.subs[id]; ok {
+			delete(fd.subs, id)
+			close(sub.ch)
+		}
+	}
+}
+
+func (s *subscription) matches(e Event) bool {
+	iri := e.Item.GetLink()
+	if len(e.Collection) > 0 {
+		iri = e.Collection
+	}
+	if !UnderIRI(iri, s.f.GetLink()) {
+		return false
+	}
+	// deleted items are usually passed to Delete as IRIs, so they can only be matched by it
+	if e.Type == EventDelete || e.Type == EventRemove || pub.IsIRI(e.Item) {
+		return true
+	}
+	return MatchItem(s.f, e.Item)
+}
+
+// Broadcast sends "e" to the subscribers whose filter it matches.
+func (fd *Feed) Broadcast(e Event) {
+	fd.mu.Lock()
+	defer fd.mu.Unlock()
+
+	for id, sub := range fd.subs {
+		if !sub.matches(e) {
+			continue
+		}
+		select {
+		case sub.ch <- e:
+		default:
+			delete(fd.subs, id)
+			close(sub.ch)
+		}
+	}
+}
+
+// Close ends all the subscriptions.
+func (fd *Feed) Close() error {
+	fd.mu.Lock()
+	defer fd.mu.Unlock()
+
+	for id, sub := range fd.subs {
+		delete(fd.subs, id)
+		close(sub.ch)
+	}
+	return nil
+}
+
+// WatchStore is a Store that broadcasts its changes to the subscribers of its ChangeFeed.
+// When the underlying store is a CollectionStore, so is the WatchStore, and the changes of the collections
+// are broadcast too.
+type WatchStore struct {
+	Store
+	feed *Feed
+}
+
+var (
+	_ ChangeFeed      = &WatchStore{}
+	_ CollectionStore = &WatchStore{}
+)
+
+// Watch returns a WatchStore for "s", in which each subscriber can have up to "buffer"
+// events waiting to be received.
+func Watch(s Store, buffer int) *WatchStore {
+	return &WatchStore{Store: s, feed: NewFeed(buffer)}
+}
+
+// Subscribe returns the channel receiving the events for the items matching "f", see ChangeFeed.
+func (w *WatchStore) Subscribe(f Filterable) (<-chan Event, CancelFn) {
+	return w.feed.Subscribe(f)
+}
+
+func (w *WatchStore) broadcast(e Event) {
+	w.feed.Broadcast(e)
+}
+
+// Save saves "it" to the underlying store, and notifies the subscribers of its creation, or update.
+func (w *WatchStore) Save(it pub.Item) (pub.Item, error) {
+	typ := EventCreate
+	if !pub.IsNil(it) && len(it.GetLink()) > 0 {
+		if exists, _ := Exists(w.Store, it.GetLink()); exists {
+			typ = EventUpdate
+		}
+	}
+	saved, err := w.Store.Save(it)
+	if err != nil {
+		return saved, err
+	}
+	if pub.IsNil(saved) {
+		saved = it
+	}
+	w.broadcast(Event{Type: typ, Item: saved})
+	return saved, nil
+}
+
+// Delete deletes "it" from the underlying store, and notifies the subscribers.
+func (w *WatchStore) Delete(it pub.Item) error {
+	if err := w.Store.Delete(it); err != nil {
+		return err
+	}
+	if !pub.IsNil(it) {
+		w.broadcast(Event{Type: EventDelete, Item: it})
+	}
+	return nil
+}
+
+// Create creates the "col" collection in the underlying store, which needs to be a CollectionStore,
+// and notifies the subscribers, unless it already existed.
+func (w *WatchStore) Create(col pub.CollectionInterface) (pub.CollectionInterface, error) {
+	exists := false
+	if !pub.IsNil(col) && len(col.GetLink()) > 0 {
+		exists, _ = Exists(w.Store, col.GetLink())
+	}
+	created, err := asCollectionStore(w.Store).Create(col)
+	if err != nil || exists {
+		return created, err
+	}
+	if pub.IsNil(created) {
+		created = col
+	}
+	w.broadcast(Event{Type: EventCreate, Item: created})
+	return created, nil
+}
+
+// AddTo adds "it" to the "col" collection in the underlying store, which needs to be a CollectionStore,
+// and notifies the subscribers.
+func (w *WatchStore) AddTo(col pub.IRI, it pub.Item) error {
+	if err := asCollectionStore(w.Store).AddTo(col, it); err != nil {
+		return err
+	}
+	if !pub.IsNil(it) {
+		w.broadcast(Event{Type: EventAdd, Item: it, Collection: col})
+	}
+	return nil
+}
+
+// RemoveFrom removes "it" from the "col" collection in the underlying store, which needs to be
+// a CollectionStore, and notifies the subscribers.
+func (w *WatchStore) RemoveFrom(col pub.IRI, it pub.Item) error {
+	if err := asCollectionStore(w.Store).RemoveFrom(col, it); err != nil {
+		return err
+	}
+	if !pub.IsNil(it) {
+		w.broadcast(Event{Type: EventRemove, Item: it, Collection: col})
+	}
+	return nil
+}
+
+// Close ends all the subscriptions. It doesn't close the underlying store.
+func (w *WatchStore) Close() error {
+	return w.feed.Close()
+}
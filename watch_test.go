@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"testing"
+
+	pub "github.com/go-ap/activitypub"
+)
+
+func receive(t *testing.T, ch <-chan Event) []Event {
+	t.Helper()
+	events := make([]Event, 0)
+	for {
+		select {
+		case e, ok := <-ch:
+			if !ok {
+				return events
+			}
+			events = append(events, e)
+		default:
+			return events
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	w := Watch(newMapStore(), 10)
+	all, cancelAll := w.Subscribe(pub.IRI(""))
+	defer cancelAll()
+	objects, cancelObjects := w.Subscribe(Filter{IRI: "https://example.com/objects", Text: []string{"hello"}})
+
+	ob := note("https://example.com/objects/1", "hello")
+	w.Save(ob)
+	w.Save(note(ob.ID, "hello again"))
+	w.Save(note("https://example.com/objects/2", "goodbye"))
+	w.Save(note("https://example.org/objects/1", "hello"))
+	w.Delete(ob.ID)
+
+	events := receive(t, all)
+	want := []EventType{EventCreate, EventUpdate, EventCreate, EventCreate, EventDelete}
+	if len(events) != len(want) {
+		t.Fatalf("received %v, expected %d events", events, len(want))
+	}
+	for i, e := range events {
+		if e.Type != want[i] {
+			t.Errorf("received a %s event, expected %s", e.Type, want[i])
+		}
+	}
+
+	events = receive(t, objects)
+	if len(events) != 3 || events[0].Type != EventCreate || events[1].Type != EventUpdate || events[2].Type != EventDelete {
+		t.Errorf("received %v, expected the events for the objects under the IRI matching the filter", events)
+	}
+
+	cancelObjects()
+	cancelObjects()
+	if _, ok := <-objects; ok {
+		t.Errorf("the channel should be closed after cancelling the subscription")
+	}
+}
+
+func TestWatch_slowSubscriber(t *testing.T) {
+	w := Watch(newMapStore(), 1)
+	ch, cancel := w.Subscribe(pub.IRI(""))
+	defer cancel()
+
+	w.Save(note("https://example.com/objects/1", "hello"))
+	w.Save(note("https://example.com/objects/2", "hello"))
+
+	if events := receive(t, ch); len(events) != 1 {
+		t.Errorf("received %v, expected only the event that fit in the buffer", events)
+	}
+	if _, ok := <-ch; ok {
+		t.Errorf("the channel of a subscriber that didn't keep up should be closed")
+	}
+
+	w.Close()
+}
+
+func TestWatch_collections(t *testing.T) {
+	w := Watch(newCollectionMapStore(), 10)
+	inbox := pub.IRI("https://example.com/actors/jdoe/inbox")
+	ch, cancel := w.Subscribe(inbox)
+	defer cancel()
+	others, cancelOthers := w.Subscribe(pub.IRI("https://example.org"))
+	defer cancelOthers()
+
+	ob := note("https://example.org/objects/1", "hello")
+	if _, err := w.Create(pub.OrderedCollectionNew(inbox)); err != nil {
+		t.Fatalf("Create returned error: %s", err)
+	}
+	if err := w.AddTo(inbox, ob); err != nil {
+		t.Fatalf("AddTo returned error: %s", err)
+	}
+	if err := w.RemoveFrom(inbox, ob.ID); err != nil {
+		t.Fatalf("RemoveFrom returned error: %s", err)
+	}
+	if err := w.AddTo("https://example.com/actors/jdoe/outbox", ob); err == nil {
+		t.Errorf("AddTo a missing collection should fail")
+	}
+
+	events := receive(t, ch)
+	want := []EventType{EventCreate, EventAdd, EventRemove}
+	if len(events) != len(want) {
+		t.Fatalf("received %v, expected %d events", events, len(want))
+	}
+	for i, e := range events {
+		if e.Type != want[i] {
+			t.Errorf("received a %s event, expected %s", e.Type, want[i])
+		}
+	}
+	if events[1].Collection != inbox || events[1].Item.GetLink() != ob.ID {
+		t.Errorf("the add event should have the collection and the item, got %#v", events[1])
+	}
+	if events := receive(t, others); len(events) > 0 {
+		t.Errorf("the changes of a collection should match its IRI, not the ones of its members, received %v", events)
+	}
+}